@@ -7,19 +7,24 @@ import (
 	"log"
 
 	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/infra/database"
 	"chat-assistant-backend/internal/infra/elasticsearch"
 	"chat-assistant-backend/internal/repositories"
 	"chat-assistant-backend/internal/services"
 
-	"gorm.io/driver/postgres"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 func main() {
 	// 命令行参数
 	var (
-		dryRun = flag.Bool("dry-run", false, "试运行，不实际同步")
-		help   = flag.Bool("help", false, "显示帮助信息")
+		dryRun    = flag.Bool("dry-run", false, "试运行，不实际同步")
+		optimize  = flag.Bool("optimize", false, "同步期间关闭索引刷新和副本，完成后恢复；只能在 API 服务停止时使用")
+		force     = flag.Bool("force", false, "重新索引所有对话，不跳过内容未变化的对话")
+		batchSize = flag.Int("batch-size", 500, "每批读取和索引的对话数")
+		userIDStr = flag.String("user-id", "", "只重新索引该用户的对话，并删除其在 ES 中多余的文档")
+		help      = flag.Bool("help", false, "显示帮助信息")
 	)
 	flag.Parse()
 
@@ -28,6 +33,18 @@ func main() {
 		return
 	}
 
+	var userID uuid.UUID
+	if *userIDStr != "" {
+		parsed, err := uuid.Parse(*userIDStr)
+		if err != nil {
+			log.Fatalf("Invalid -user-id %q: %v", *userIDStr, err)
+		}
+		if *dryRun {
+			log.Fatal("-dry-run cannot be combined with -user-id")
+		}
+		userID = parsed
+	}
+
 	// 加载配置
 	cfg, err := config.Load()
 	if err != nil {
@@ -48,35 +65,61 @@ func main() {
 
 	// 创建 repositories
 	conversationRepo := repositories.NewConversationRepository(db)
-	indexer := repositories.NewElasticsearchIndexer(esClient.GetClient(), cfg.Elasticsearch.Index.Conversations)
+	indexer := elasticsearch.NewElasticsearchIndexerFromClient(esClient, cfg)
 
 	// 创建同步服务
-	syncService := services.NewSyncService(conversationRepo, indexer)
+	syncService := services.NewSyncServiceWithOptions(conversationRepo, indexer, services.SyncOptions{Force: *force, BatchSize: *batchSize})
 
 	// 执行同步
 	if *dryRun {
-		log.Println("Dry run mode - fetching sample data...")
-		conversations, err := conversationRepo.FindAll()
+		log.Println("Dry run mode - comparing database with Elasticsearch...")
+		plan, err := syncService.Plan()
 		if err != nil {
-			log.Fatalf("Failed to fetch conversations: %v", err)
-		}
-		log.Printf("Dry run: Found %d conversations to sync", len(conversations))
-
-		// 显示前几个 conversation 的示例
-		if len(conversations) > 0 {
-			log.Printf("Sample conversation: ID=%s, Title=%s, Messages=%d",
-				conversations[0].ID, conversations[0].Title, len(conversations[0].Messages))
+			log.Fatalf("Failed to plan sync: %v", err)
 		}
+		printPlan(plan)
 		log.Println("Dry run completed - no data was actually synced")
 	} else {
 		log.Println("Starting data sync...")
-		if err := syncService.SyncAll(); err != nil {
+		runSync := syncService.SyncAll
+		if userID != uuid.Nil {
+			log.Printf("Syncing conversations of user %s only", userID)
+			runSync = func() error { return syncService.SyncUser(userID) }
+		} else if *optimize {
+			// 批量写入期间延长 refresh 间隔、关闭副本，结束后恢复原设置并刷新
+			initializer := elasticsearch.NewInitializer(esClient, indexer)
+			runSync = func() error {
+				return initializer.WithBulkSettings(context.Background(), syncService.SyncAll)
+			}
+		}
+		if err := runSync(); err != nil {
 			log.Fatalf("Sync failed: %v", err)
 		}
 		log.Println("Data sync completed successfully")
 	}
 }
 
+// planSampleSize 试运行时每类变更最多列出的对话 ID 数
+const planSampleSize = 5
+
+func printPlan(plan *services.SyncPlan) {
+	log.Printf("Dry run: %d to index, %d to update, %d unchanged, %d stale (not deleted)",
+		len(plan.ToIndex), len(plan.ToUpdate), plan.Unchanged, len(plan.Stale))
+
+	printIDs := func(label string, ids []uuid.UUID) {
+		for i, id := range ids {
+			if i == planSampleSize {
+				log.Printf("  %s: ... and %d more", label, len(ids)-planSampleSize)
+				break
+			}
+			log.Printf("  %s: %s", label, id)
+		}
+	}
+	printIDs("index", plan.ToIndex)
+	printIDs("update", plan.ToUpdate)
+	printIDs("stale", plan.Stale)
+}
+
 func showHelp() {
 	fmt.Println("Data Sync Tool - 同步数据库数据到 Elasticsearch")
 	fmt.Println()
@@ -85,30 +128,32 @@ func showHelp() {
 	fmt.Println()
 	fmt.Println("Options:")
 	fmt.Println("  -dry-run")
-	fmt.Println("       试运行，不实际同步")
+	fmt.Println("       试运行，比较数据库与 ES，输出需要新建、更新的文档数和多余（不会删除）的文档数，不实际同步")
+	fmt.Println("  -optimize")
+	fmt.Println("       同步期间设置 refresh_interval=30s、number_of_replicas=0，完成后恢复并刷新索引；")
+	fmt.Println("       API 服务可以继续运行，期间新写入的数据最多延迟 30s 可搜索")
+	fmt.Println("  -force")
+	fmt.Println("       重新索引所有对话；默认跳过索引中内容哈希与数据库一致的对话")
+	fmt.Println("  -batch-size N")
+	fmt.Println("       每批从数据库读取并写入 ES 的对话数（默认 500），内存占用与之成正比")
+	fmt.Println("  -user-id UUID")
+	fmt.Println("       只重新索引该用户的全部对话，并删除 ES 中属于该用户但数据库中已不存在的文档；不能与 -dry-run 同时使用，忽略 -optimize")
 	fmt.Println("  -help")
 	fmt.Println("       显示帮助信息")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  data-sync                    # 同步所有数据")
 	fmt.Println("  data-sync -dry-run          # 试运行")
+	fmt.Println("  data-sync -optimize         # 全量同步，优化写入速度")
+	fmt.Println("  data-sync -force            # 修改索引配置后重新索引所有对话")
+	fmt.Println("  data-sync -user-id <uuid>   # 修复某个用户的数据后只重新索引该用户")
 }
 
 func initializeDatabase(cfg *config.Config) (*gorm.DB, error) {
-	dsn := cfg.Database.GetDSN()
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// Postgres 未就绪时按 database.connect_retries 重试
+	db, err := database.Connect(&cfg.Database)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
-	}
-
-	// 测试连接
-	sqlDB, err := db.DB()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get database instance: %w", err)
-	}
-
-	if err := sqlDB.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		return nil, err
 	}
 
 	log.Println("Database connection established")
@@ -116,18 +161,8 @@ func initializeDatabase(cfg *config.Config) (*gorm.DB, error) {
 }
 
 func initializeElasticsearch(cfg *config.Config) (*elasticsearch.Client, error) {
-	esConfig := &elasticsearch.Config{
-		Hosts:    cfg.Elasticsearch.Hosts,
-		Username: cfg.Elasticsearch.Username,
-		Password: cfg.Elasticsearch.Password,
-		Timeout:  cfg.Elasticsearch.Timeout,
-		Index: elasticsearch.IndexConfig{
-			Conversations: cfg.Elasticsearch.Index.Conversations,
-			Messages:      cfg.Elasticsearch.Index.Messages,
-		},
-	}
-
-	client, err := elasticsearch.NewClient(esConfig)
+	// TLS、重试等设置与服务端一致
+	client, err := elasticsearch.NewElasticsearchClientFromConfig(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Elasticsearch client: %w", err)
 	}
@@ -23,18 +23,8 @@ func main() {
 	}
 
 	// 创建 ES 客户端
-	esConfig := &elasticsearch.Config{
-		Hosts:    cfg.Elasticsearch.Hosts,
-		Username: cfg.Elasticsearch.Username,
-		Password: cfg.Elasticsearch.Password,
-		Timeout:  cfg.Elasticsearch.Timeout,
-		Index: elasticsearch.IndexConfig{
-			Conversations: cfg.Elasticsearch.Index.Conversations,
-			Messages:      cfg.Elasticsearch.Index.Messages,
-		},
-	}
-
-	client, err := elasticsearch.NewClient(esConfig)
+	// TLS、重试等设置与服务端一致
+	client, err := elasticsearch.NewElasticsearchClientFromConfig(cfg)
 	if err != nil {
 		log.Fatalf("Failed to create Elasticsearch client: %v", err)
 	}
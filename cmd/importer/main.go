@@ -1,39 +1,58 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 
 	"chat-assistant-backend/internal/config"
 	"chat-assistant-backend/internal/importer"
+	importerrors "chat-assistant-backend/internal/importer/errors"
 	"chat-assistant-backend/internal/importer/parsers"
+	"chat-assistant-backend/internal/infra/elasticsearch"
 	"chat-assistant-backend/internal/logger"
 )
 
 func main() {
 	var (
-		file     = flag.String("file", "", "Path to the JSON file to import (required)")
-		platform = flag.String("platform", "", "Platform type: chatgpt, claude, gemini (required)")
+		file     = flag.String("file", "", "Path to the JSON file to import (required unless -dir is set)")
+		dir      = flag.String("dir", "", "Directory or glob pattern of JSON files to import")
+		parallel = flag.Int("parallel", 1, "Number of files to import concurrently when using -dir")
+		platform = flag.String("platform", "", "Platform type: chatgpt, claude, gemini (auto-detected from file content when omitted)")
 		userID   = flag.String("user-id", "", "User ID to associate with imported data (required)")
 		dryRun   = flag.Bool("dry-run", false, "Perform a dry run without writing to database")
 		verbose  = flag.Bool("verbose", false, "Enable verbose logging")
+		index    = flag.Bool("index", false, "Index imported conversations into Elasticsearch after loading")
+		diff     = flag.Bool("diff", false, "Print the differences between the file and the stored conversations without writing")
 	)
 	flag.Parse()
 
 	// Validate required flags
-	if *file == "" || *platform == "" || *userID == "" {
-		fmt.Fprintf(os.Stderr, "Error: --file, --platform, and --user-id are required\n")
+	if (*file == "" && *dir == "") || *userID == "" {
+		fmt.Fprintf(os.Stderr, "Error: --file (or --dir) and --user-id are required\n")
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	// Validate file exists
-	if _, err := os.Stat(*file); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "Error: file %s does not exist\n", *file)
+	if *file != "" && *dir != "" {
+		fmt.Fprintf(os.Stderr, "Error: --file and --dir cannot be used together\n")
+		os.Exit(1)
+	}
+
+	if *diff && *file == "" {
+		fmt.Fprintf(os.Stderr, "Error: --diff requires --file\n")
 		os.Exit(1)
 	}
 
+	// Validate file exists
+	if *file != "" {
+		if _, err := os.Stat(*file); os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Error: file %s does not exist\n", *file)
+			os.Exit(1)
+		}
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -54,13 +73,57 @@ func main() {
 	defer logger.Sync()
 
 	// Register all parsers
-	parsers.RegisterAll()
+	parsers.RegisterAllWithConfig(&cfg.Import)
+
+	// 干运行不需要数据库，连接失败时不必等待重试
+	if *dryRun && !*diff {
+		cfg.Database.ConnectRetries = 0
+	}
 
 	// Execute import
 	importerService := importer.NewService(cfg)
+
+	if *diff {
+		diffResult, err := importerService.Diff(*file, *platform, *userID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Diff failed: %v\n", err)
+			printJSONErrorLocation(*file, err)
+			os.Exit(1)
+		}
+		printDiff(diffResult)
+		return
+	}
+
+	// 只有指定 -index 时才连接 Elasticsearch，保证无 ES 环境下仍可导入
+	if *index && !*dryRun {
+		esClient, err := elasticsearch.NewElasticsearchClientFromConfig(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize Elasticsearch: %v\n", err)
+			os.Exit(1)
+		}
+		importerService.EnableIndexing(elasticsearch.NewElasticsearchIndexerFromClient(esClient, cfg))
+	}
+
+	if *dir != "" {
+		batchResult, err := importerService.ImportDir(*dir, *platform, *userID, *dryRun, *parallel)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Import failed: %v\n", err)
+			os.Exit(1)
+		}
+
+		printBatchResults(batchResult)
+		if batchResult.FailedFiles > 0 {
+			fmt.Fprintf(os.Stderr, "Import completed with %d failed file(s)\n", batchResult.FailedFiles)
+			os.Exit(1)
+		}
+		fmt.Println("Import completed successfully!")
+		return
+	}
+
 	result, err := importerService.Import(*file, *platform, *userID, *dryRun)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Import failed: %v\n", err)
+		printJSONErrorLocation(*file, err)
 		os.Exit(1)
 	}
 
@@ -76,6 +139,10 @@ func printResults(result *importer.ImportResult) {
 	fmt.Printf("Messages: %d\n", result.MessageCount)
 	fmt.Printf("Success: %d\n", result.SuccessCount)
 	fmt.Printf("Errors: %d\n", result.ErrorCount)
+	fmt.Printf("Indexed: %d\n", result.IndexedCount)
+	if result.TruncatedCount > 0 {
+		fmt.Printf("Truncated (over max_conversations): %d\n", result.TruncatedCount)
+	}
 	fmt.Printf("Duration: %s\n", result.Duration)
 
 	if len(result.Errors) > 0 {
@@ -85,3 +152,71 @@ func printResults(result *importer.ImportResult) {
 		}
 	}
 }
+
+func printDiff(result *importer.ImportDiff) {
+	fmt.Printf("\n=== Import Diff ===\n")
+	fmt.Printf("Platform: %s\n", result.Platform)
+	fmt.Printf("New: %d, Changed: %d, Unchanged: %d\n", result.NewCount, result.ChangedCount, result.UnchangedCount)
+
+	for _, conv := range result.Conversations {
+		if conv.Status == importer.DiffStatusUnchanged {
+			continue
+		}
+		fmt.Printf("\n[%s] %s (%s)\n", conv.Status, conv.Title, conv.SourceID)
+		if conv.TitleChanged {
+			fmt.Printf("  ~ title changed\n")
+		}
+		printMessageDiffs("+", conv.AddedMessages)
+		printMessageDiffs("~", conv.ChangedMessages)
+		printMessageDiffs("-", conv.RemovedMessages)
+	}
+}
+
+func printMessageDiffs(marker string, messages []importer.MessageDiff) {
+	for _, msg := range messages {
+		fmt.Printf("  %s %s %s: %s\n", marker, msg.Role, msg.SourceID, msg.Preview)
+	}
+}
+
+// printJSONErrorLocation 文件不是合法 JSON 时单独输出出错位置，便于在编辑器中定位
+func printJSONErrorLocation(file string, err error) {
+	var jsonErr *importerrors.JSONError
+	if !errors.As(err, &jsonErr) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "  at %s:%d:%d (byte offset %d)\n", file, jsonErr.Line, jsonErr.Column, jsonErr.Offset)
+	if jsonErr.Snippet != "" {
+		fmt.Fprintf(os.Stderr, "  near: %s\n", jsonErr.Snippet)
+	}
+}
+
+func printBatchResults(result *importer.BatchImportResult) {
+	fmt.Printf("\n=== Files ===\n")
+	for _, fileResult := range result.Files {
+		if fileResult.Error != "" {
+			fmt.Printf("- %s: FAILED (%s)\n", fileResult.File, fileResult.Error)
+			continue
+		}
+		fmt.Printf("- %s [%s]: %d conversations, %d messages, %d indexed\n", fileResult.File, fileResult.Result.Platform,
+			fileResult.Result.ConversationCount, fileResult.Result.MessageCount, fileResult.Result.IndexedCount)
+	}
+
+	for _, skipped := range result.Skipped {
+		fmt.Printf("- %s: skipped (not a JSON file)\n", skipped)
+	}
+
+	platform := result.Platform
+	if platform == "" {
+		platform = "auto-detected per file"
+	}
+
+	fmt.Printf("\n=== Import Results ===\n")
+	fmt.Printf("Platform: %s\n", platform)
+	fmt.Printf("Files: %d (failed: %d, skipped: %d)\n", result.FileCount, result.FailedFiles, len(result.Skipped))
+	fmt.Printf("Conversations: %d\n", result.ConversationCount)
+	fmt.Printf("Messages: %d\n", result.MessageCount)
+	fmt.Printf("Success: %d\n", result.SuccessCount)
+	fmt.Printf("Errors: %d\n", result.ErrorCount)
+	fmt.Printf("Indexed: %d\n", result.IndexedCount)
+	fmt.Printf("Duration: %s\n", result.Duration)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/infra/database"
+	"chat-assistant-backend/internal/infra/elasticsearch"
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/repositories"
+	"chat-assistant-backend/internal/seed"
+
+	"gorm.io/gorm"
+)
+
+// indexBatchSize 每次 bulk 写入 ES 的对话数
+const indexBatchSize = 200
+
+func main() {
+	// 命令行参数
+	var (
+		users         = flag.Int("users", 3, "生成的用户数")
+		conversations = flag.Int("conversations", 20, "每个用户的对话数")
+		messages      = flag.Int("messages", 10, "每个对话的消息数")
+		seedValue     = flag.Int64("seed", 1, "随机种子，相同的种子生成相同的数据")
+		index         = flag.Bool("index", false, "同时索引到 Elasticsearch")
+		force         = flag.Bool("force", false, "允许写入非本地数据库")
+		help          = flag.Bool("help", false, "显示帮助信息")
+	)
+	flag.Parse()
+
+	if *help {
+		showHelp()
+		return
+	}
+
+	if *users < 0 || *conversations < 0 || *messages < 0 {
+		log.Fatal("-users, -conversations and -messages must not be negative")
+	}
+
+	// 加载配置
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// 防止误把测试数据写入共享或生产数据库
+	if !seed.IsLocalHost(cfg.Database.Host) && !*force {
+		log.Fatalf("Refusing to seed non-local database host %q; pass -force if this is really a development database", cfg.Database.Host)
+	}
+
+	// 初始化数据库
+	db, err := initializeDatabase(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	dataset := seed.Generate(seed.Options{
+		Users:                   *users,
+		ConversationsPerUser:    *conversations,
+		MessagesPerConversation: *messages,
+		Seed:                    *seedValue,
+	})
+	if len(dataset.Users) == 0 {
+		log.Println("Nothing to seed")
+		return
+	}
+
+	// ID 由种子决定，同一种子再次运行时第一个用户已存在
+	existing, err := repositories.NewUserRepository(db).GetByID(dataset.Users[0].ID)
+	if err != nil {
+		log.Fatalf("Failed to check existing seed data: %v", err)
+	}
+	if existing != nil {
+		log.Printf("Seed %d is already loaded (user %s exists); use a different -seed to add more data", *seedValue, existing.Username)
+		return
+	}
+
+	if err := insertDataset(db, dataset); err != nil {
+		log.Fatalf("Seed failed: %v", err)
+	}
+
+	messageCount := len(dataset.Conversations) * *messages
+	log.Printf("Seeded %d users, %d conversations, %d messages, %d tags (seed=%d)",
+		len(dataset.Users), len(dataset.Conversations), messageCount, len(dataset.TagNames), *seedValue)
+
+	if !*index {
+		log.Println("Run data-sync (or seed with -index) to make the data searchable in Elasticsearch")
+		return
+	}
+
+	if err := indexDataset(cfg, dataset); err != nil {
+		log.Fatalf("Indexing failed: %v", err)
+	}
+	log.Printf("Indexed %d conversations to Elasticsearch", len(dataset.Conversations))
+}
+
+// insertDataset 在一个事务中写入用户、标签、对话和消息
+func insertDataset(db *gorm.DB, dataset *seed.Dataset) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		userRepo := repositories.NewUserRepository(tx)
+		tagRepo := repositories.NewTagRepository(tx)
+		conversationRepo := repositories.NewConversationRepository(tx)
+
+		for _, user := range dataset.Users {
+			if err := userRepo.Create(user); err != nil {
+				return fmt.Errorf("failed to create user %s: %w", user.Username, err)
+			}
+		}
+
+		tags, err := tagRepo.CreateOrGetTags(dataset.TagNames)
+		if err != nil {
+			return fmt.Errorf("failed to create tags: %w", err)
+		}
+		tagsByName := make(map[string]models.Tag, len(tags))
+		for _, tag := range tags {
+			tagsByName[tag.Name] = *tag
+		}
+
+		for _, conversation := range dataset.Conversations {
+			// 生成的标签只有名称，替换为已创建的标签
+			for i, tag := range conversation.Tags {
+				conversation.Tags[i] = tagsByName[tag.Name]
+			}
+			if err := conversationRepo.Create(conversation); err != nil {
+				return fmt.Errorf("failed to create conversation %s: %w", conversation.SourceID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// indexDataset 通过与导入、同步相同的 ToESDocument 路径写入 ES
+func indexDataset(cfg *config.Config, dataset *seed.Dataset) error {
+	client, err := elasticsearch.NewElasticsearchClientFromConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create Elasticsearch client: %w", err)
+	}
+	indexer := elasticsearch.NewElasticsearchIndexerFromClient(client, cfg)
+
+	for start := 0; start < len(dataset.Conversations); start += indexBatchSize {
+		end := min(start+indexBatchSize, len(dataset.Conversations))
+
+		docs := make([]*models.ConversationDocument, 0, end-start)
+		for _, conversation := range dataset.Conversations[start:end] {
+			docs = append(docs, conversation.ToESDocument())
+		}
+		if err := indexer.BulkIndexConversations(docs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func showHelp() {
+	fmt.Println("Seed - 生成本地开发用的用户、对话、消息和标签")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  seed [options]")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  -users N")
+	fmt.Println("       生成的用户数（默认 3）")
+	fmt.Println("  -conversations N")
+	fmt.Println("       每个用户的对话数（默认 20）")
+	fmt.Println("  -messages N")
+	fmt.Println("       每个对话的消息数（默认 10）")
+	fmt.Println("  -seed N")
+	fmt.Println("       随机种子（默认 1），相同的种子生成相同的 ID、内容和时间")
+	fmt.Println("  -index")
+	fmt.Println("       同时索引到 Elasticsearch")
+	fmt.Println("  -force")
+	fmt.Println("       允许写入非本地数据库（database.host 不是 localhost、回环地址或 postgres）")
+	fmt.Println("  -help")
+	fmt.Println("       显示帮助信息")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  seed                                   # 3 个用户，每人 20 个对话")
+	fmt.Println("  seed -users 10 -conversations 100 -index")
+	fmt.Println("  seed -seed 2                           # 在已有数据之外再生成一批")
+	fmt.Println()
+	fmt.Println("同一种子重复运行时检测到数据已存在会直接退出。")
+}
+
+func initializeDatabase(cfg *config.Config) (*gorm.DB, error) {
+	// Postgres 未就绪时按 database.connect_retries 重试
+	db, err := database.Connect(&cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Database connection established")
+	return db, nil
+}
@@ -9,6 +9,7 @@ import (
 	"chat-assistant-backend/internal/handlers"
 	"chat-assistant-backend/internal/infra/database"
 	"chat-assistant-backend/internal/infra/elasticsearch"
+	"chat-assistant-backend/internal/jobs"
 	"chat-assistant-backend/internal/repositories"
 	"chat-assistant-backend/internal/services"
 
@@ -24,12 +25,15 @@ func InitializeApp() (*app.App, error) {
 		// Infrastructure
 		database.DatabaseSet,
 		elasticsearch.ElasticsearchSet,
+		jobs.JobsSet,
 
 		// Repositories
 		repositories.RepositorySet,
 
 		// Services
 		services.ServiceSet,
+		wire.Bind(new(services.Reindexer), new(*elasticsearch.Initializer)),
+		wire.Bind(new(services.MappingClient), new(*elasticsearch.Client)),
 
 		// Handlers
 		handlers.HandlerSet,
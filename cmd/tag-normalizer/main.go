@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/infra/database"
+	"chat-assistant-backend/internal/repositories"
+	"chat-assistant-backend/internal/services"
+
+	"gorm.io/gorm"
+)
+
+func main() {
+	// 命令行参数
+	var (
+		dryRun = flag.Bool("dry-run", false, "试运行，只显示变更不实际修改")
+		help   = flag.Bool("help", false, "显示帮助信息")
+	)
+	flag.Parse()
+
+	if *help {
+		showHelp()
+		return
+	}
+
+	// 加载配置
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// 初始化数据库
+	db, err := initializeDatabase(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	tagRepo := repositories.NewTagRepository(db)
+	// 规范化完成后通过 data-sync 同步到 ES，这里不连接 ES
+	tagService := services.NewTagService(tagRepo, nil, nil, cfg)
+
+	log.Printf("Normalizing tags (case_insensitive=%v, dry_run=%v)...", cfg.Tags.CaseInsensitive, *dryRun)
+	changes, err := tagService.NormalizeExistingTags(*dryRun)
+	if err != nil {
+		log.Fatalf("Tag normalization failed: %v", err)
+	}
+
+	merged := 0
+	for _, change := range changes {
+		merged += len(change.DuplicateIDs)
+		log.Printf("  [%s] -> %q (target=%s, merged=%d)",
+			strings.Join(quoteAll(change.OldNames), ", "), change.NewName, change.TargetID, len(change.DuplicateIDs))
+	}
+
+	if *dryRun {
+		log.Printf("Dry run: %d tag groups would be normalized, %d duplicate tags would be merged", len(changes), merged)
+		return
+	}
+
+	log.Printf("Tag normalization completed: %d tag groups normalized, %d duplicate tags merged", len(changes), merged)
+	if len(changes) > 0 {
+		log.Println("Run data-sync to refresh tags in Elasticsearch")
+	}
+}
+
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return quoted
+}
+
+func showHelp() {
+	fmt.Println("Tag Normalizer - 规范化已有标签名称并合并重复标签")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  tag-normalizer [options]")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  -dry-run")
+	fmt.Println("       试运行，只显示变更不实际修改")
+	fmt.Println("  -help")
+	fmt.Println("       显示帮助信息")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  tag-normalizer                # 规范化并合并标签")
+	fmt.Println("  tag-normalizer -dry-run       # 试运行")
+	fmt.Println()
+	fmt.Println("是否忽略大小写由配置项 tags.case_insensitive 决定。")
+	fmt.Println("执行完成后请运行 data-sync 将标签变更同步到 Elasticsearch。")
+}
+
+func initializeDatabase(cfg *config.Config) (*gorm.DB, error) {
+	// Postgres 未就绪时按 database.connect_retries 重试
+	db, err := database.Connect(&cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Database connection established")
+	return db, nil
+}
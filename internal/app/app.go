@@ -9,8 +9,10 @@ import (
 	"chat-assistant-backend/internal/config"
 	"chat-assistant-backend/internal/handlers"
 	"chat-assistant-backend/internal/infra/elasticsearch"
+	"chat-assistant-backend/internal/jobs"
 	"chat-assistant-backend/internal/logger"
 	"chat-assistant-backend/internal/server"
+	"chat-assistant-backend/internal/services"
 )
 
 // App represents the application
@@ -21,10 +23,24 @@ type App struct {
 }
 
 // New creates a new application instance
-func New(cfg *config.Config, db *gorm.DB, esClient *elasticsearch.Client, userHandler *handlers.UserHandler, conversationHandler *handlers.ConversationHandler, messageHandler *handlers.MessageHandler, tagHandler *handlers.TagHandler, searchHandler *handlers.SearchHandler) *App {
+func New(cfg *config.Config, db *gorm.DB, esClient *elasticsearch.Client, jobManager *jobs.Manager, auditService services.AuditService, userHandler *handlers.UserHandler, conversationHandler *handlers.ConversationHandler, messageHandler *handlers.MessageHandler, tagHandler *handlers.TagHandler, searchHandler *handlers.SearchHandler, adminHandler *handlers.AdminHandler) *App {
+	srv := server.New(cfg, db, userHandler, conversationHandler, messageHandler, tagHandler, searchHandler, adminHandler)
+
+	// 在接收请求之前创建缺失的索引并预热 ES，未启用时为 nil；预热查询依赖索引，先注册初始化
+	if autoInit := elasticsearch.NewAutoInitFromConfig(esClient, cfg); autoInit != nil {
+		srv.Register(autoInit)
+	}
+	if warmUp := elasticsearch.NewWarmUpFromConfig(esClient, cfg); warmUp != nil {
+		srv.Register(warmUp)
+	}
+
+	// 后台组件在服务器关闭时等待处理完成；审计日志最后停止，保证其他组件产生的记录也能写入
+	srv.Register(auditService)
+	srv.Register(jobManager)
+
 	return &App{
 		config: cfg,
-		server: server.New(cfg, db, userHandler, conversationHandler, messageHandler, tagHandler, searchHandler),
+		server: srv,
 		logger: logger.GetLogger(),
 	}
 }
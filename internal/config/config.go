@@ -1,7 +1,14 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -18,15 +25,28 @@ type Config struct {
 	I18n          I18nConfig          `mapstructure:"i18n"`
 	Shutdown      ShutdownConfig      `mapstructure:"shutdown"`
 	Import        ImportConfig        `mapstructure:"import"`
+	Tags          TagsConfig          `mapstructure:"tags"`
+	Admin         AdminConfig         `mapstructure:"admin"`
+	Jobs          JobsConfig          `mapstructure:"jobs"`
 }
 
 // ServerConfig holds server configuration
 type ServerConfig struct {
-	Host         string        `mapstructure:"host"`
-	Port         int           `mapstructure:"port"`
-	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
-	WriteTimeout time.Duration `mapstructure:"write_timeout"`
-	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
+	Host         string          `mapstructure:"host"`
+	Port         int             `mapstructure:"port"`
+	ReadTimeout  time.Duration   `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration   `mapstructure:"write_timeout"`
+	IdleTimeout  time.Duration   `mapstructure:"idle_timeout"`
+	BodyLimit    BodyLimitConfig `mapstructure:"body_limit"`
+	// TrustedProxies lists proxy IPs/CIDRs whose X-Forwarded-For/X-Real-IP headers are honored.
+	// Empty trusts nothing and uses the direct peer address as the client IP
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+// BodyLimitConfig holds request body size limits in bytes
+type BodyLimitConfig struct {
+	Default int64 `mapstructure:"default"` // 所有 /api/v1 接口
+	Create  int64 `mapstructure:"create"`  // 创建标签和对话，叠加在 Default 之上，大于 Default 时不生效
 }
 
 // DatabaseConfig holds database configuration
@@ -41,6 +61,17 @@ type DatabaseConfig struct {
 	MaxOpenConns    int           `mapstructure:"max_open_conns"`
 	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
 	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	// ReadReplicas lists replica hosts ("host" or "host:port") sharing the primary's credentials;
+	// queries are routed to them while writes and transactions stay on the primary
+	ReadReplicas []string `mapstructure:"read_replicas"`
+	// ForcePrimary ignores ReadReplicas and routes all traffic to the primary,
+	// e.g. when replica lag breaks read-after-write consistency
+	ForcePrimary bool `mapstructure:"force_primary"`
+	// ConnectRetries is how many times the initial connection is retried while Postgres
+	// is not ready yet; 0 fails on the first error
+	ConnectRetries int `mapstructure:"connect_retries"`
+	// ConnectRetryInterval is the wait before the first retry, doubled after each one
+	ConnectRetryInterval time.Duration `mapstructure:"connect_retry_interval"`
 }
 
 // CORSConfig holds CORS configuration
@@ -76,12 +107,93 @@ type ImportConfig struct {
 	BatchSize   int                       `mapstructure:"batch_size"`
 	TempDir     string                    `mapstructure:"temp_dir"`
 	Providers   map[string]ProviderConfig `mapstructure:"providers"`
+	// TruncateOverLimit imports only the conversations that fit under MaxConversations
+	// (logging a warning) instead of rejecting the whole import
+	TruncateOverLimit bool `mapstructure:"truncate_over_limit"`
+	// MaxMessageChars 消息内容索引到 Elasticsearch 时的最大字符数，超出部分只保存在数据库中，0 表示不限制
+	MaxMessageChars int `mapstructure:"max_message_chars"`
+	// MaxIndexedMessages 每个对话索引到 Elasticsearch 的最大消息数，只保留最近的消息，数据库保留全部消息，0 表示不限制
+	MaxIndexedMessages int `mapstructure:"max_indexed_messages"`
+	// MissingTimestampStep 缺少时间的消息按顺序补齐时间的间隔（对话创建时间 + 序号 * 间隔），0 表示使用导入时的当前时间
+	MissingTimestampStep time.Duration `mapstructure:"missing_timestamp_step"`
+	// MaxMessagesPerConversation 单个对话的最大消息数，超出时按 OversizedPolicy 处理，0 表示不限制
+	MaxMessagesPerConversation int `mapstructure:"max_messages_per_conversation"`
+	// OversizedPolicy 对话消息数超过 MaxMessagesPerConversation 时的处理方式：
+	// split（拆分为多个对话，为空时的默认值）或 truncate（只保留最近的消息）
+	OversizedPolicy string `mapstructure:"oversized_policy"`
+	// DeterministicIDs 由用户、平台和原始 ID（没有时为内容哈希）派生 UUIDv5 作为对话、消息的 ID，
+	// 重复导入同一份导出得到相同的 ID
+	DeterministicIDs bool `mapstructure:"deterministic_ids"`
 }
 
+// Oversized conversation policies, see ImportConfig.OversizedPolicy
+const (
+	OversizedPolicySplit    = "split"
+	OversizedPolicyTruncate = "truncate"
+)
+
 // ProviderConfig holds provider-specific configuration
 type ProviderConfig struct {
 	Enabled          bool `mapstructure:"enabled"`
-	MaxConversations int  `mapstructure:"max_conversations"`
+	MaxConversations int  `mapstructure:"max_conversations"` // 每个用户在该平台下的对话数上限，0 表示不限制
+	// TimestampSource 消息时间优先使用的字段：create（create_time）或 update（update_time），目前仅 chatgpt 支持
+	TimestampSource string `mapstructure:"timestamp_source"`
+	// DefaultModel 导出数据中没有模型时对话使用的模型，启用的平台必须设置
+	DefaultModel string `mapstructure:"default_model"`
+}
+
+// DefaultModels returns the configured default model of each provider, keyed by platform
+func (c *ImportConfig) DefaultModels() map[string]string {
+	models := make(map[string]string, len(c.Providers))
+	for platform, provider := range c.Providers {
+		if provider.DefaultModel != "" {
+			models[platform] = provider.DefaultModel
+		}
+	}
+	return models
+}
+
+// Validate checks the import settings
+func (c *ImportConfig) Validate() error {
+	// 按名称顺序检查，错误信息稳定
+	platforms := make([]string, 0, len(c.Providers))
+	for platform := range c.Providers {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	if c.MaxMessagesPerConversation < 0 {
+		return fmt.Errorf("max_messages_per_conversation must not be negative")
+	}
+	switch c.OversizedPolicy {
+	case "", OversizedPolicySplit, OversizedPolicyTruncate:
+	default:
+		return fmt.Errorf("oversized_policy must be %q or %q, got %q", OversizedPolicySplit, OversizedPolicyTruncate, c.OversizedPolicy)
+	}
+
+	for _, platform := range platforms {
+		provider := c.Providers[platform]
+		if provider.Enabled && strings.TrimSpace(provider.DefaultModel) == "" {
+			return fmt.Errorf("providers.%s.default_model must be set for enabled providers", platform)
+		}
+	}
+	return nil
+}
+
+// TagsConfig holds tag configuration
+type TagsConfig struct {
+	CaseInsensitive bool `mapstructure:"case_insensitive"` // 标签名忽略大小写（按 Unicode 大小写折叠后存储）
+}
+
+// AdminConfig holds admin API configuration
+type AdminConfig struct {
+	Token string `mapstructure:"token"` // 管理接口令牌，为空时禁用管理接口
+}
+
+// JobsConfig holds background job configuration
+type JobsConfig struct {
+	MaxHistory int           `mapstructure:"max_history"` // 最多保留的已结束任务数
+	TTL        time.Duration `mapstructure:"ttl"`         // 已结束任务的保留时长
 }
 
 // ElasticsearchConfig holds Elasticsearch configuration
@@ -91,18 +203,109 @@ type ElasticsearchConfig struct {
 	Password string        `mapstructure:"password"`
 	Timeout  time.Duration `mapstructure:"timeout"`
 	Index    IndexConfig   `mapstructure:"index"`
+	// StartupTimeout bounds connection retries at server startup before starting in degraded mode
+	StartupTimeout time.Duration `mapstructure:"startup_timeout"`
+	// ReconnectInterval is how often a degraded server re-checks Elasticsearch
+	ReconnectInterval time.Duration             `mapstructure:"reconnect_interval"`
+	Refresh           RefreshConfig             `mapstructure:"refresh"`
+	TLS               ElasticsearchTLSConfig    `mapstructure:"tls"`
+	Retry             ElasticsearchRetryConfig  `mapstructure:"retry"`
+	WarmUp            ElasticsearchWarmUpConfig `mapstructure:"warm_up"`
+	// AutoInit creates missing indices when the server starts (never deletes or changes existing ones)
+	AutoInit bool `mapstructure:"auto_init"`
+}
+
+// ElasticsearchWarmUpConfig holds the startup warm-up of the conversation index
+type ElasticsearchWarmUpConfig struct {
+	Enabled bool          `mapstructure:"enabled"` // 启动时先发送一次轻量搜索，预热连接和索引缓存
+	Timeout time.Duration `mapstructure:"timeout"` // 预热的最长耗时，超时只记录日志，不影响启动
+}
+
+// ElasticsearchTLSConfig holds TLS settings for https hosts
+type ElasticsearchTLSConfig struct {
+	CACertFile         string `mapstructure:"ca_cert_file"`         // PEM 格式的 CA 证书路径，为空时使用系统证书
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // 跳过证书校验，仅用于本地开发
+}
+
+// ElasticsearchRetryConfig holds retry settings for failed requests
+type ElasticsearchRetryConfig struct {
+	MaxRetries    int   `mapstructure:"max_retries"`     // 失败请求的最大重试次数，0 表示不重试
+	RetryOnStatus []int `mapstructure:"retry_on_status"` // 需要重试的 HTTP 状态码
+}
+
+// RefreshConfig holds the refresh policy ("true", "false" or "wait_for") for index writes
+type RefreshConfig struct {
+	Write string `mapstructure:"write"` // 单文档写入
+	Bulk  string `mapstructure:"bulk"`  // 批量写入和按标签批量更新
 }
 
 // IndexConfig holds index-specific configuration
 type IndexConfig struct {
 	Conversations string `mapstructure:"conversations"`
 	Messages      string `mapstructure:"messages"`
+	// SourceAnalyzer 原文字段（source_title、source_content）的分析器，修改后需要重建索引
+	SourceAnalyzer string `mapstructure:"source_analyzer"`
+	// ExactAnalyzer exact 子字段（精确短语匹配）的分析器，修改后需要重建索引
+	ExactAnalyzer string `mapstructure:"exact_analyzer"`
 }
 
 // SearchConfig holds search configuration
 type SearchConfig struct {
 	Strategy string `mapstructure:"strategy"` // "postgres", "elasticsearch", "hybrid"
 	Fallback bool   `mapstructure:"fallback"` // fallback to postgres if ES is unavailable
+	// IncludeContextMessages returns the first messages as context when only the title or tags match
+	IncludeContextMessages bool `mapstructure:"include_context_messages"`
+	// MinScore drops keyword hits scoring below this value in Elasticsearch (0 disables)
+	MinScore float64 `mapstructure:"min_score"`
+	// SlowSearchThreshold logs searches taking longer than this duration (0 disables)
+	SlowSearchThreshold time.Duration `mapstructure:"slow_search_threshold"`
+	// PostFilter re-checks keyword hits for an exact match and re-sorts them in Go;
+	// when false the Elasticsearch ranking is returned as is
+	PostFilter bool `mapstructure:"post_filter"`
+	// SnippetChars returns a snippet of this many characters before and after the first
+	// keyword match instead of the full message content (0 returns full content)
+	SnippetChars int `mapstructure:"snippet_chars"`
+	// PostProcessConcurrency caps how many conversations are checked and scored by the
+	// post filter at the same time across all requests (0 uses GOMAXPROCS)
+	PostProcessConcurrency int `mapstructure:"post_process_concurrency"`
+	// PostProcessMaxMessages caps how many messages of each conversation the post filter
+	// scores, bounding the re-ranking work per request (0 scores every message); the exact
+	// match check always looks at every message so genuine matches are never dropped
+	PostProcessMaxMessages int `mapstructure:"post_process_max_messages"`
+	// RequireUserID rejects searches without a user_id so one user cannot search across
+	// everyone's conversations; admin callers (X-Admin-Token) may still omit it
+	RequireUserID bool `mapstructure:"require_user_id"`
+	// CacheTTL caches search results in memory for this long (0 disables the cache); writes
+	// do not invalidate cached results, so keep it short
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	// CacheSize caps how many search results are cached, evicting the least recently used
+	CacheSize int `mapstructure:"cache_size"`
+	// MessageIndexMode controls the flat message index (elasticsearch.index.messages):
+	// nested only uses the messages nested in conversation documents, dual also writes every
+	// message to the flat index, flat writes both and matches keywords against the flat index
+	MessageIndexMode string `mapstructure:"message_index_mode"`
+}
+
+// Message index modes for SearchConfig.MessageIndexMode
+const (
+	MessageIndexModeNested = "nested" // 默认：消息只作为对话文档的嵌套字段索引和搜索
+	MessageIndexModeDual   = "dual"   // 同时写入独立消息索引，搜索仍使用嵌套消息，用于切换前回填
+	MessageIndexModeFlat   = "flat"   // 同时写入两处，关键词在独立消息索引中匹配后关联回对话
+)
+
+// WritesMessageIndex reports whether messages are also written to the flat message index
+func (c SearchConfig) WritesMessageIndex() bool {
+	return c.MessageIndexMode == MessageIndexModeDual || c.MessageIndexMode == MessageIndexModeFlat
+}
+
+// Validate checks the search settings
+func (c SearchConfig) Validate() error {
+	switch c.MessageIndexMode {
+	case "", MessageIndexModeNested, MessageIndexModeDual, MessageIndexModeFlat:
+		return nil
+	default:
+		return fmt.Errorf("message_index_mode must be one of: nested, dual, flat, got %q", c.MessageIndexMode)
+	}
 }
 
 // Load loads configuration from file and environment variables
@@ -131,9 +334,95 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return &config, nil
 }
 
+// Validate checks settings that would otherwise only fail at runtime
+func (c *Config) Validate() error {
+	if err := c.Elasticsearch.Validate(); err != nil {
+		return fmt.Errorf("elasticsearch: %w", err)
+	}
+	if err := c.Import.Validate(); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	if err := c.Search.Validate(); err != nil {
+		return fmt.Errorf("search: %w", err)
+	}
+	return nil
+}
+
+// analyzerNamePattern 分析器名称，内置分析器（如 standard、cjk）和自定义分析器都符合该格式
+var analyzerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Validate checks the Elasticsearch settings; it does not connect to the cluster
+func (c *ElasticsearchConfig) Validate() error {
+	if len(c.Hosts) == 0 {
+		return errors.New("hosts must not be empty")
+	}
+	for _, host := range c.Hosts {
+		u, err := url.Parse(strings.TrimSpace(host))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("host %q must be an http(s) URL such as http://localhost:9200", host)
+		}
+	}
+
+	if strings.TrimSpace(c.Index.Conversations) == "" {
+		return errors.New("index.conversations must not be empty")
+	}
+	if strings.TrimSpace(c.Index.Messages) == "" {
+		return errors.New("index.messages must not be empty")
+	}
+	if c.Index.SourceAnalyzer != "" && !analyzerNamePattern.MatchString(c.Index.SourceAnalyzer) {
+		return fmt.Errorf("index.source_analyzer %q is not a valid analyzer name", c.Index.SourceAnalyzer)
+	}
+	if c.Index.ExactAnalyzer != "" && !analyzerNamePattern.MatchString(c.Index.ExactAnalyzer) {
+		return fmt.Errorf("index.exact_analyzer %q is not a valid analyzer name", c.Index.ExactAnalyzer)
+	}
+
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive, got %s", c.Timeout)
+	}
+	if c.StartupTimeout < 0 {
+		return fmt.Errorf("startup_timeout must not be negative, got %s", c.StartupTimeout)
+	}
+	if c.ReconnectInterval < 0 {
+		return fmt.Errorf("reconnect_interval must not be negative, got %s", c.ReconnectInterval)
+	}
+	if c.WarmUp.Timeout < 0 {
+		return fmt.Errorf("warm_up.timeout must not be negative, got %s", c.WarmUp.Timeout)
+	}
+
+	for _, refresh := range []struct{ name, policy string }{
+		{"refresh.write", c.Refresh.Write},
+		{"refresh.bulk", c.Refresh.Bulk},
+	} {
+		switch refresh.policy {
+		case "", "true", "false", "wait_for":
+		default:
+			return fmt.Errorf("%s must be one of: true, false, wait_for, got %q", refresh.name, refresh.policy)
+		}
+	}
+
+	if c.TLS.CACertFile != "" && c.TLS.InsecureSkipVerify {
+		return errors.New("tls.ca_cert_file and tls.insecure_skip_verify cannot both be set")
+	}
+
+	if c.Retry.MaxRetries < 0 {
+		return fmt.Errorf("retry.max_retries must not be negative, got %d", c.Retry.MaxRetries)
+	}
+	for _, status := range c.Retry.RetryOnStatus {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("retry.retry_on_status contains invalid HTTP status %d", status)
+		}
+	}
+
+	return nil
+}
+
 // setDefaults sets default configuration values
 func setDefaults() {
 	// Server defaults
@@ -142,6 +431,9 @@ func setDefaults() {
 	viper.SetDefault("server.read_timeout", "30s")
 	viper.SetDefault("server.write_timeout", "30s")
 	viper.SetDefault("server.idle_timeout", "120s")
+	viper.SetDefault("server.body_limit.default", 1048576) // 1MB
+	viper.SetDefault("server.body_limit.create", 65536)    // 64KB
+	viper.SetDefault("server.trusted_proxies", []string{})
 
 	// Database defaults
 	viper.SetDefault("database.host", "localhost")
@@ -154,10 +446,14 @@ func setDefaults() {
 	viper.SetDefault("database.max_open_conns", 25)
 	viper.SetDefault("database.max_idle_conns", 25)
 	viper.SetDefault("database.conn_max_lifetime", "5m")
+	viper.SetDefault("database.read_replicas", []string{})
+	viper.SetDefault("database.force_primary", false)
+	viper.SetDefault("database.connect_retries", 5)
+	viper.SetDefault("database.connect_retry_interval", "1s")
 
 	// CORS defaults
 	viper.SetDefault("cors.allowed_origins", []string{"http://localhost:3000"})
-	viper.SetDefault("cors.allowed_methods", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
+	viper.SetDefault("cors.allowed_methods", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"})
 	viper.SetDefault("cors.allowed_headers", []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID"})
 	viper.SetDefault("cors.allow_credentials", true)
 
@@ -170,6 +466,13 @@ func setDefaults() {
 	viper.SetDefault("i18n.default_language", "en")
 	viper.SetDefault("i18n.supported_languages", []string{"en", "zh"})
 
+	// Admin defaults
+	viper.SetDefault("admin.token", "")
+
+	// Jobs defaults
+	viper.SetDefault("jobs.max_history", 100)
+	viper.SetDefault("jobs.ttl", "24h")
+
 	// Shutdown defaults
 	viper.SetDefault("shutdown.timeout", "30s")
 
@@ -178,24 +481,62 @@ func setDefaults() {
 	viper.SetDefault("import.timeout", "600s")          // 10 minutes
 	viper.SetDefault("import.batch_size", 100)
 	viper.SetDefault("import.temp_dir", "/tmp/imports")
+	viper.SetDefault("import.truncate_over_limit", false)
+	viper.SetDefault("import.max_message_chars", 50000)
+	viper.SetDefault("import.max_indexed_messages", 2000)
+	viper.SetDefault("import.missing_timestamp_step", "1s")
+	viper.SetDefault("import.max_messages_per_conversation", 10000)
+	viper.SetDefault("import.oversized_policy", "split")
+	viper.SetDefault("import.deterministic_ids", false)
 	viper.SetDefault("import.providers.chatgpt.enabled", true)
 	viper.SetDefault("import.providers.chatgpt.max_conversations", 1000)
+	viper.SetDefault("import.providers.chatgpt.timestamp_source", "create")
+	viper.SetDefault("import.providers.chatgpt.default_model", "gpt-4o")
 	viper.SetDefault("import.providers.claude.enabled", true)
 	viper.SetDefault("import.providers.claude.max_conversations", 1000)
+	viper.SetDefault("import.providers.claude.default_model", "claude-3-5-sonnet")
 	viper.SetDefault("import.providers.gemini.enabled", true)
 	viper.SetDefault("import.providers.gemini.max_conversations", 1000)
+	viper.SetDefault("import.providers.gemini.default_model", "gemini-1.5-pro")
+
+	// Tags defaults
+	viper.SetDefault("tags.case_insensitive", false)
 
 	// Elasticsearch defaults
 	viper.SetDefault("elasticsearch.hosts", []string{"http://localhost:9200"})
 	viper.SetDefault("elasticsearch.username", "")
 	viper.SetDefault("elasticsearch.password", "")
 	viper.SetDefault("elasticsearch.timeout", "30s")
+	viper.SetDefault("elasticsearch.startup_timeout", "30s")
+	viper.SetDefault("elasticsearch.reconnect_interval", "10s")
+	viper.SetDefault("elasticsearch.refresh.write", "wait_for")
+	viper.SetDefault("elasticsearch.refresh.bulk", "false")
 	viper.SetDefault("elasticsearch.index.conversations", "conversations")
 	viper.SetDefault("elasticsearch.index.messages", "messages")
+	viper.SetDefault("elasticsearch.index.source_analyzer", "standard")
+	viper.SetDefault("elasticsearch.index.exact_analyzer", "exact_phrase")
+	viper.SetDefault("elasticsearch.auto_init", false)
+	viper.SetDefault("elasticsearch.warm_up.enabled", false)
+	viper.SetDefault("elasticsearch.warm_up.timeout", "5s")
+	viper.SetDefault("elasticsearch.tls.ca_cert_file", "")
+	viper.SetDefault("elasticsearch.tls.insecure_skip_verify", false)
+	viper.SetDefault("elasticsearch.retry.max_retries", 3)
+	viper.SetDefault("elasticsearch.retry.retry_on_status", []int{502, 503, 504})
 
 	// Search defaults
 	viper.SetDefault("search.strategy", "postgres") // postgres, elasticsearch, hybrid
 	viper.SetDefault("search.fallback", true)
+	viper.SetDefault("search.include_context_messages", true)
+	viper.SetDefault("search.min_score", 1.0)
+	viper.SetDefault("search.slow_search_threshold", "500ms")
+	viper.SetDefault("search.post_filter", true)
+	viper.SetDefault("search.snippet_chars", 100)
+	viper.SetDefault("search.post_process_concurrency", 0)
+	viper.SetDefault("search.post_process_max_messages", 1000)
+	viper.SetDefault("search.require_user_id", false)
+	viper.SetDefault("search.cache_ttl", "0s")
+	viper.SetDefault("search.cache_size", 1000)
+	viper.SetDefault("search.message_index_mode", "nested")
 }
 
 // GetDSN returns the database connection string
@@ -203,3 +544,27 @@ func (c *DatabaseConfig) GetDSN() string {
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s TimeZone=%s",
 		c.Host, c.Port, c.User, c.Password, c.DBName, c.SSLMode, c.Timezone)
 }
+
+// GetReplicaDSNs returns the DSN of each read replica; replicas without a port use the primary's port.
+// Returns nil when ForcePrimary is set
+func (c *DatabaseConfig) GetReplicaDSNs() []string {
+	if c.ForcePrimary || len(c.ReadReplicas) == 0 {
+		return nil
+	}
+
+	dsns := make([]string, 0, len(c.ReadReplicas))
+	for _, replica := range c.ReadReplicas {
+		host, port := replica, c.Port
+		if h, p, err := net.SplitHostPort(replica); err == nil {
+			if n, err := strconv.Atoi(p); err == nil {
+				host, port = h, n
+			}
+		}
+
+		replicaCfg := *c
+		replicaCfg.Host = host
+		replicaCfg.Port = port
+		dsns = append(dsns, replicaCfg.GetDSN())
+	}
+	return dsns
+}
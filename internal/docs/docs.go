@@ -22,9 +22,9 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/conversations": {
+        "/api/v1/admin/audit-logs": {
             "get": {
-                "description": "Retrieve conversations list with pagination",
+                "description": "Retrieve audit logs of destructive operations, newest first",
                 "consumes": [
                     "application/json"
                 ],
@@ -32,18 +32,56 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Conversations"
+                    "Admin"
                 ],
-                "summary": "Get Conversations",
+                "summary": "List Audit Logs",
                 "parameters": [
                     {
                         "type": "string",
-                        "format": "uuid",
-                        "description": "User ID",
-                        "name": "user_id",
-                        "in": "query",
+                        "description": "Admin API token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
                         "required": true
                     },
+                    {
+                        "type": "string",
+                        "description": "Filter by action, e.g. conversation.delete",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "conversation",
+                            "message",
+                            "tag",
+                            "user"
+                        ],
+                        "type": "string",
+                        "description": "Filter by resource type",
+                        "name": "resource_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Filter by resource ID",
+                        "name": "resource_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date",
+                        "description": "Only logs on or after this date",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date",
+                        "description": "Only logs on or before this date",
+                        "name": "end_date",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
                         "default": 1,
@@ -53,7 +91,7 @@ const docTemplate = `{
                     },
                     {
                         "type": "integer",
-                        "default": 10,
+                        "default": 20,
                         "description": "Items per page",
                         "name": "limit",
                         "in": "query"
@@ -61,7 +99,7 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Conversations list",
+                        "description": "Audit logs",
                         "schema": {
                             "allOf": [
                                 {
@@ -71,7 +109,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/response.ConversationListResponse"
+                                            "$ref": "#/definitions/response.AuditLogListResponse"
                                         }
                                     }
                                 }
@@ -84,6 +122,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Response"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin API disabled",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -91,9 +141,11 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Create a new conversation with tags",
+            }
+        },
+        "/api/v1/admin/es/mapping": {
+            "get": {
+                "description": "Retrieve the live mapping of the conversations index from Elasticsearch",
                 "consumes": [
                     "application/json"
                 ],
@@ -101,23 +153,21 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Conversations"
+                    "Admin"
                 ],
-                "summary": "Create Conversation",
+                "summary": "Get Index Mapping",
                 "parameters": [
                     {
-                        "description": "Conversation data",
-                        "name": "conversation",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/request.CreateConversationRequest"
-                        }
+                        "type": "string",
+                        "description": "Admin API token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Conversation created successfully",
+                    "200": {
+                        "description": "Index mapping",
                         "schema": {
                             "allOf": [
                                 {
@@ -127,15 +177,21 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/response.ConversationResponse"
+                                            "type": "object"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
-                    "400": {
-                        "description": "Bad request",
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin API disabled",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
@@ -147,11 +203,9 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/api/v1/conversations/{id}": {
-            "get": {
-                "description": "Retrieve a specific conversation by ID",
+            },
+            "put": {
+                "description": "Add new fields to the mapping of the conversations index. The body uses the format of the Elasticsearch put mapping API, e.g. {\"properties\": {...}}. Changing existing fields is rejected; that requires a new index and a reindex",
                 "consumes": [
                     "application/json"
                 ],
@@ -159,22 +213,30 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Conversations"
+                    "Admin"
                 ],
-                "summary": "Get Conversation",
+                "summary": "Update Index Mapping",
                 "parameters": [
                     {
                         "type": "string",
-                        "format": "uuid",
-                        "description": "Conversation ID",
-                        "name": "id",
-                        "in": "path",
+                        "description": "Admin API token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
                         "required": true
+                    },
+                    {
+                        "description": "Mapping additions",
+                        "name": "mapping",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Conversation details",
+                        "description": "Updated index mapping",
                         "schema": {
                             "allOf": [
                                 {
@@ -184,7 +246,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/response.ConversationResponse"
+                                            "type": "object"
                                         }
                                     }
                                 }
@@ -192,13 +254,19 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Bad request",
+                        "description": "Invalid mapping or breaking change",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
                     },
-                    "404": {
-                        "description": "Conversation not found",
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin API disabled",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
@@ -210,9 +278,11 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete a specific conversation by ID",
+            }
+        },
+        "/api/v1/admin/reindex": {
+            "post": {
+                "description": "Rebuild the search index from the database in the background",
                 "consumes": [
                     "application/json"
                 ],
@@ -220,40 +290,51 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Conversations"
+                    "Admin"
                 ],
-                "summary": "Delete Conversation",
+                "summary": "Start Reindex",
                 "parameters": [
                     {
                         "type": "string",
-                        "format": "uuid",
-                        "description": "Conversation ID",
-                        "name": "id",
-                        "in": "path",
+                        "description": "Admin API token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Conversation deleted successfully",
+                    "202": {
+                        "description": "Reindex job started",
                         "schema": {
-                            "$ref": "#/definitions/response.Response"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.JobResponse"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "400": {
-                        "description": "Bad request",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
                     },
-                    "404": {
-                        "description": "Conversation not found",
+                    "403": {
+                        "description": "Admin API disabled",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "409": {
+                        "description": "Reindex already in progress",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
@@ -261,9 +342,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/conversations/{id}/messages": {
+        "/api/v1/admin/reindex/{jobId}": {
             "get": {
-                "description": "Retrieve all messages in a specific conversation with pagination",
+                "description": "Retrieve the progress of a reindex job",
                 "consumes": [
                     "application/json"
                 ],
@@ -271,60 +352,58 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Conversations"
+                    "Admin"
                 ],
-                "summary": "Get Conversation Messages",
+                "summary": "Get Reindex Job",
                 "parameters": [
                     {
                         "type": "string",
-                        "format": "uuid",
-                        "description": "Conversation ID",
-                        "name": "id",
-                        "in": "path",
+                        "description": "Admin API token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "default": 1,
-                        "description": "Page number",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "default": 10,
-                        "description": "Items per page",
-                        "name": "limit",
-                        "in": "query"
+                        "type": "string",
+                        "description": "Reindex job ID",
+                        "name": "jobId",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Messages list",
+                        "description": "Reindex job status",
                         "schema": {
                             "allOf": [
                                 {
-                                    "$ref": "#/definitions/response.PaginatedResponse"
+                                    "$ref": "#/definitions/response.Response"
                                 },
                                 {
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/response.MessageListResponse"
+                                            "$ref": "#/definitions/response.JobResponse"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
-                    "400": {
-                        "description": "Bad request",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "403": {
+                        "description": "Admin API disabled",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Reindex job not found",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
@@ -332,9 +411,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/conversations/{id}/tags": {
-            "put": {
-                "description": "Update tags for a specific conversation",
+        "/api/v1/admin/search/cache": {
+            "get": {
+                "description": "Retrieve the hit/miss counters of the in-memory search result cache (search.cache_ttl)",
                 "consumes": [
                     "application/json"
                 ],
@@ -342,49 +421,45 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Conversations"
+                    "Admin"
                 ],
-                "summary": "Update Conversation Tags",
+                "summary": "Get Search Cache Stats",
                 "parameters": [
                     {
                         "type": "string",
-                        "format": "uuid",
-                        "description": "Conversation ID",
-                        "name": "id",
-                        "in": "path",
+                        "description": "Admin API token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
                         "required": true
-                    },
-                    {
-                        "description": "Tags data",
-                        "name": "tags",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/request.UpdateConversationTagsRequest"
-                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Tags updated successfully",
-                        "schema": {
-                            "$ref": "#/definitions/response.Response"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad request",
+                        "description": "Search cache stats",
                         "schema": {
-                            "$ref": "#/definitions/response.Response"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.SearchCacheStatsResponse"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "404": {
-                        "description": "Conversation not found",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "403": {
+                        "description": "Admin API disabled",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
@@ -392,9 +467,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/messages": {
+        "/api/v1/conversations": {
             "get": {
-                "description": "Retrieve messages list with pagination",
+                "description": "Retrieve conversations list with pagination",
                 "consumes": [
                     "application/json"
                 ],
@@ -402,10 +477,18 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Messages"
+                    "Conversations"
                 ],
-                "summary": "Get Messages",
+                "summary": "Get Conversations",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
                     {
                         "type": "integer",
                         "default": 1,
@@ -419,11 +502,43 @@ const docTemplate = `{
                         "description": "Items per page",
                         "name": "limit",
                         "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Include last message preview for each conversation",
+                        "name": "with_preview",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "created",
+                            "activity"
+                        ],
+                        "type": "string",
+                        "default": "created",
+                        "description": "Sort by creation time or by last message time (most recently active first)",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date",
+                        "description": "Only conversations created (last active with order=activity) on or after this date",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date",
+                        "description": "Only conversations created (last active with order=activity) on or before this date",
+                        "name": "end_date",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Messages list",
+                        "description": "Conversations list",
                         "schema": {
                             "allOf": [
                                 {
@@ -433,7 +548,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/response.MessageListResponse"
+                                            "$ref": "#/definitions/response.ConversationListResponse"
                                         }
                                     }
                                 }
@@ -453,34 +568,33 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/api/v1/messages/{id}": {
-            "get": {
-                "description": "Retrieve a specific message by ID",
-                "consumes": [
+            },
+            "post": {
+                "description": "Create a new conversation with tags",
+                "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Messages"
+                    "Conversations"
                 ],
-                "summary": "Get Message",
+                "summary": "Create Conversation",
                 "parameters": [
                     {
-                        "type": "string",
-                        "format": "uuid",
-                        "description": "Message ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Conversation data",
+                        "name": "conversation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.CreateConversationRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Message details",
+                    "201": {
+                        "description": "Conversation created successfully",
                         "schema": {
                             "allOf": [
                                 {
@@ -490,7 +604,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/response.MessageResponse"
+                                            "$ref": "#/definitions/response.ConversationResponse"
                                         }
                                     }
                                 }
@@ -503,61 +617,6 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Response"
                         }
                     },
-                    "404": {
-                        "description": "Message not found",
-                        "schema": {
-                            "$ref": "#/definitions/response.Response"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal server error",
-                        "schema": {
-                            "$ref": "#/definitions/response.Response"
-                        }
-                    }
-                }
-            },
-            "delete": {
-                "description": "Delete a specific message by ID",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Messages"
-                ],
-                "summary": "Delete Message",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "format": "uuid",
-                        "description": "Message ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "Message deleted successfully",
-                        "schema": {
-                            "$ref": "#/definitions/response.Response"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad request",
-                        "schema": {
-                            "$ref": "#/definitions/response.Response"
-                        }
-                    },
-                    "404": {
-                        "description": "Message not found",
-                        "schema": {
-                            "$ref": "#/definitions/response.Response"
-                        }
-                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -567,9 +626,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/search": {
+        "/api/v1/conversations/by-source": {
             "get": {
-                "description": "Search conversations by title or message content, returns conversation list with matched messages",
+                "description": "Retrieve a user's conversation by the provider and the conversation's original ID on that provider, e.g. for integrations that track external IDs",
                 "consumes": [
                     "application/json"
                 ],
@@ -577,79 +636,46 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Search"
+                    "Conversations"
                 ],
-                "summary": "Search Conversations",
+                "summary": "Get Conversation By Source ID",
                 "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Search query",
-                        "name": "q",
-                        "in": "query",
-                        "required": true
-                    },
                     {
                         "type": "string",
                         "format": "uuid",
                         "description": "User ID",
                         "name": "user_id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Provider ID (e.g., openai, gemini, claude)",
-                        "name": "provider_id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "format": "uuid",
-                        "description": "Tag ID for filtering conversations",
-                        "name": "tag_id",
-                        "in": "query"
+                        "in": "query",
+                        "required": true
                     },
                     {
                         "type": "string",
-                        "format": "date",
-                        "description": "Start date for filtering conversations",
-                        "name": "start_date",
-                        "in": "query"
+                        "description": "Provider the conversation was imported from, e.g. chatgpt",
+                        "name": "provider",
+                        "in": "query",
+                        "required": true
                     },
                     {
                         "type": "string",
-                        "format": "date",
-                        "description": "End date for filtering conversations",
-                        "name": "end_date",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "default": 1,
-                        "description": "Page number",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "default": 10,
-                        "description": "Items per page",
-                        "name": "limit",
-                        "in": "query"
+                        "description": "Conversation ID on the provider",
+                        "name": "source_id",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Search results",
+                        "description": "Conversation details",
                         "schema": {
                             "allOf": [
                                 {
-                                    "$ref": "#/definitions/response.PaginatedResponse"
+                                    "$ref": "#/definitions/response.Response"
                                 },
                                 {
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/response.SearchResponse"
+                                            "$ref": "#/definitions/response.ConversationDetailResponse"
                                         }
                                     }
                                 }
@@ -662,6 +688,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Response"
                         }
                     },
+                    "404": {
+                        "description": "Conversation not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -671,9 +703,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/tags": {
-            "get": {
-                "description": "Retrieve all tags",
+        "/api/v1/conversations/merge": {
+            "post": {
+                "description": "Move all messages and tags of the source conversations into the target conversation and delete the sources.\nMessages are renumbered in chronological order. All conversations must belong to the same user",
                 "consumes": [
                     "application/json"
                 ],
@@ -681,12 +713,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Tags"
+                    "Conversations"
+                ],
+                "summary": "Merge Conversations",
+                "parameters": [
+                    {
+                        "description": "Target and source conversations",
+                        "name": "merge",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.MergeConversationsRequest"
+                        }
+                    }
                 ],
-                "summary": "Get Tags",
                 "responses": {
                     "200": {
-                        "description": "Tags list",
+                        "description": "Conversations merged successfully",
                         "schema": {
                             "allOf": [
                                 {
@@ -696,13 +739,25 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/response.TagListResponse"
+                                            "$ref": "#/definitions/response.ConversationResponse"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
+                    "400": {
+                        "description": "Bad request or conversations of different users",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -710,9 +765,11 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Create a new tag",
+            }
+        },
+        "/api/v1/conversations/{id}": {
+            "get": {
+                "description": "Retrieve a specific conversation by ID",
                 "consumes": [
                     "application/json"
                 ],
@@ -720,23 +777,34 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Tags"
+                    "Conversations"
                 ],
-                "summary": "Create Tag",
+                "summary": "Get Conversation",
                 "parameters": [
                     {
-                        "description": "Tag data",
-                        "name": "tag",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/request.CreateTagRequest"
-                        }
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Conversation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated related data to include: messages (first page), tags (always included)",
+                        "name": "include",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Tag created successfully",
+                    "200": {
+                        "description": "Conversation details",
                         "schema": {
                             "allOf": [
                                 {
@@ -746,21 +814,24 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/response.TagResponse"
+                                            "$ref": "#/definitions/response.ConversationDetailResponse"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
+                    "304": {
+                        "description": "Conversation not modified"
+                    },
                     "400": {
                         "description": "Bad request",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
                     },
-                    "409": {
-                        "description": "Tag name already exists",
+                    "404": {
+                        "description": "Conversation not found",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
@@ -772,11 +843,9 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/api/v1/tags/{id}": {
-            "get": {
-                "description": "Retrieve a specific tag by ID",
+            },
+            "delete": {
+                "description": "Delete a specific conversation by ID",
                 "consumes": [
                     "application/json"
                 ],
@@ -784,14 +853,14 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Tags"
+                    "Conversations"
                 ],
-                "summary": "Get Tag",
+                "summary": "Delete Conversation",
                 "parameters": [
                     {
                         "type": "string",
                         "format": "uuid",
-                        "description": "Tag ID",
+                        "description": "Conversation ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -799,7 +868,7 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Tag details",
+                        "description": "Conversation deleted successfully",
                         "schema": {
                             "allOf": [
                                 {
@@ -809,7 +878,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/response.TagResponse"
+                                            "$ref": "#/definitions/response.DeleteResponse"
                                         }
                                     }
                                 }
@@ -823,7 +892,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Tag not found",
+                        "description": "Conversation not found",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
@@ -836,8 +905,8 @@ const docTemplate = `{
                     }
                 }
             },
-            "put": {
-                "description": "Update an existing tag",
+            "patch": {
+                "description": "Partially update a conversation. Only the fields present in the body are applied: title and model are\nset (an empty string is a value), add_tags are attached and remove_tags detached while other tags are kept",
                 "consumes": [
                     "application/json"
                 ],
@@ -845,31 +914,31 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Tags"
+                    "Conversations"
                 ],
-                "summary": "Update Tag",
+                "summary": "Patch Conversation",
                 "parameters": [
                     {
                         "type": "string",
                         "format": "uuid",
-                        "description": "Tag ID",
+                        "description": "Conversation ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Tag data",
-                        "name": "tag",
+                        "description": "Fields to update",
+                        "name": "patch",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/request.UpdateTagRequest"
+                            "$ref": "#/definitions/request.PatchConversationRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Tag updated successfully",
+                        "description": "Conversation updated successfully",
                         "schema": {
                             "allOf": [
                                 {
@@ -879,7 +948,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/response.TagResponse"
+                                            "$ref": "#/definitions/response.ConversationResponse"
                                         }
                                     }
                                 }
@@ -893,13 +962,1291 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Tag not found",
-                        "schema": {
-                            "$ref": "#/definitions/response.Response"
-                        }
-                    },
-                    "409": {
-                        "description": "Tag name already exists",
+                        "description": "Conversation not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/conversations/{id}/clone": {
+            "post": {
+                "description": "Copy a conversation with its tags and all messages, optionally to another user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Conversations"
+                ],
+                "summary": "Clone Conversation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Conversation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Target user (defaults to the original owner)",
+                        "name": "clone",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/request.CloneConversationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Conversation cloned successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.ConversationResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation or user not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/conversations/{id}/export": {
+            "get": {
+                "description": "Download a conversation with all messages in chronological order, its tags, provider/model and metadata.\nformat=markdown puts the conversation fields, tags and metadata in a YAML front matter block",
+                "produces": [
+                    "application/json",
+                    "text/markdown"
+                ],
+                "tags": [
+                    "Conversations"
+                ],
+                "summary": "Export Conversation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Conversation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "markdown"
+                        ],
+                        "type": "string",
+                        "default": "json",
+                        "description": "Export format",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Conversation export",
+                        "schema": {
+                            "$ref": "#/definitions/services.ConversationExport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/conversations/{id}/messages": {
+            "get": {
+                "description": "Retrieve all messages in a specific conversation with pagination. order=desc returns the newest messages first\nand sets pagination.next_cursor; pass it back as cursor to load older messages without deep offsets",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Conversations"
+                ],
+                "summary": "Get Conversation Messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Conversation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number, ignored when cursor is set",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Items per page",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "default": "asc",
+                        "description": "Message order",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from pagination.next_cursor, only with order=desc",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Messages list",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.PaginatedResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.MessageListResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/conversations/{id}/tags": {
+            "put": {
+                "description": "Update tags for a specific conversation",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Conversations"
+                ],
+                "summary": "Update Conversation Tags",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Conversation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Tags data",
+                        "name": "tags",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.UpdateConversationTagsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tags updated successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.UpdateResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/conversations/{id}/transfer": {
+            "post": {
+                "description": "Move a conversation to another user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Conversations"
+                ],
+                "summary": "Transfer Conversation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Conversation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Target user",
+                        "name": "transfer",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.TransferConversationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Conversation transferred successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.ConversationResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation or user not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Target user already has a conversation with the same source_id (SOURCE_ID_CONFLICT)",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages": {
+            "get": {
+                "description": "Retrieve messages list with pagination",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Messages"
+                ],
+                "summary": "Get Messages",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Items per page",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Messages list",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.PaginatedResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.MessageListResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/{id}": {
+            "get": {
+                "description": "Retrieve a specific message by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Messages"
+                ],
+                "summary": "Get Message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Message details",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.MessageResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a specific message by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Messages"
+                ],
+                "summary": "Delete Message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Message deleted successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.DeleteResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/{id}/context": {
+            "get": {
+                "description": "Retrieve a message together with its neighboring messages in the same conversation, ordered by creation time",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Messages"
+                ],
+                "summary": "Get Message Context",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 2,
+                        "description": "Number of messages before the target (max 10)",
+                        "name": "before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 2,
+                        "description": "Number of messages after the target (max 10)",
+                        "name": "after",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Message with context",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.MessageContextResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/search": {
+            "get": {
+                "description": "Search conversations by title or message content, returns conversation list with matched messages",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Search"
+                ],
+                "summary": "Search Conversations",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search query (optional, can be empty for filter-only queries)",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "User ID (required when search.require_user_id is enabled, unless an admin token is sent)",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin API token; allows searching across users when search.require_user_id is enabled",
+                        "name": "X-Admin-Token",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "example": "chatgpt",
+                        "description": "Provider ID; free-form, imported conversations use chatgpt, claude or gemini",
+                        "name": "provider_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Tag ID for filtering conversations",
+                        "name": "tag_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Tag IDs for filtering conversations (repeated or comma-separated)",
+                        "name": "tag_ids",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "all",
+                            "any"
+                        ],
+                        "type": "string",
+                        "default": "all",
+                        "description": "How tag_ids are matched: all tags or any tag",
+                        "name": "tag_match",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date",
+                        "description": "Start date for filtering conversations",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date",
+                        "description": "End date for filtering conversations",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "user",
+                            "assistant",
+                            "system"
+                        ],
+                        "type": "string",
+                        "description": "Only search messages with this role",
+                        "name": "role",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by conversation metadata; any meta.\u003ckey\u003e is accepted for keys project, account, summary, tags_raw, import_policy, import_note",
+                        "name": "meta.project",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Advanced filter expression as JSON, e.g. {\\",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Minimum relevance score for keyword hits (0 disables, defaults to search.min_score)",
+                        "name": "min_score",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "relevance",
+                            "date"
+                        ],
+                        "type": "string",
+                        "default": "relevance",
+                        "description": "Sort by relevance (newest first among equal scores) or by date (creation time, newest first, no relevance re-ranking)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "relevance",
+                            "activity",
+                            "created"
+                        ],
+                        "type": "string",
+                        "description": "Alias of sort that also accepts activity (last message time, most recently active first); relevance, activity or created (= sort=date). Must agree with sort when both are given",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated field groups the keyword is matched against: title, messages, tags (default all)",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Items per page",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Search results",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.PaginatedResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.SearchResponse"
+                                        },
+                                        "meta": {
+                                            "$ref": "#/definitions/models.SearchMeta"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Filter or metadata search while Elasticsearch is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Search conversations with all options in a JSON body; supports arrays such as tag_ids and the advanced filter expression as a JSON object",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Search"
+                ],
+                "summary": "Search Conversations (JSON body)",
+                "parameters": [
+                    {
+                        "description": "Search options",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.SearchRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin API token; allows searching across users when search.require_user_id is enabled",
+                        "name": "X-Admin-Token",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Search results",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.PaginatedResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.SearchResponse"
+                                        },
+                                        "meta": {
+                                            "$ref": "#/definitions/models.SearchMeta"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "user_id is required",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid search options",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Filter or metadata search while Elasticsearch is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/search/similar/{conversationId}": {
+            "get": {
+                "description": "Find conversations of the same user whose title and messages are similar to the given conversation; returns an empty list when the conversation has too little text",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Search"
+                ],
+                "summary": "Find Similar Conversations",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Conversation ID",
+                        "name": "conversationId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "maximum": 20,
+                        "type": "integer",
+                        "default": 5,
+                        "description": "Maximum number of similar conversations",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Similar conversations",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.SimilarConversationsResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Elasticsearch is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tags": {
+            "get": {
+                "description": "Retrieve all tags",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tags"
+                ],
+                "summary": "Get Tags",
+                "responses": {
+                    "200": {
+                        "description": "Tags list",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.TagListResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new tag",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tags"
+                ],
+                "summary": "Create Tag",
+                "parameters": [
+                    {
+                        "description": "Tag data",
+                        "name": "tag",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.CreateTagRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Tag created successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.TagResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Tag name already exists",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tags/batch": {
+            "post": {
+                "description": "Create several tags at once; names are normalized and deduplicated, existing tags are returned with created=false",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tags"
+                ],
+                "summary": "Batch Create Tags",
+                "parameters": [
+                    {
+                        "description": "Tag names (at most 100)",
+                        "name": "tags",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.BatchCreateTagsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Created and existing tags",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.BatchTagListResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tags/{id}": {
+            "get": {
+                "description": "Retrieve a specific tag by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tags"
+                ],
+                "summary": "Get Tag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Tag ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tag details",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.TagResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Tag not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Update an existing tag",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tags"
+                ],
+                "summary": "Update Tag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Tag ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Tag data",
+                        "name": "tag",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.UpdateTagRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tag updated successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.TagResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Tag not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Tag name already exists",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
@@ -913,7 +2260,210 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Delete a specific tag by ID",
+                "description": "Delete a specific tag by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tags"
+                ],
+                "summary": "Delete Tag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Tag ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tag deleted successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.DeleteResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Tag not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tags/{id}/conversations": {
+            "get": {
+                "description": "Retrieve the conversations carrying a tag with pagination, newest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tags"
+                ],
+                "summary": "Get Conversations By Tag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Tag ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Items per page",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Conversations list",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.PaginatedResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.ConversationListResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Tag not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}": {
+            "get": {
+                "description": "Retrieve a specific user by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get User",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User details",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.UserResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/conversations": {
+            "delete": {
+                "description": "Permanently delete all conversations and messages of a user from the database and the search index, e.g. for GDPR erasure. Requires the admin token; the user and their tags are kept",
                 "consumes": [
                     "application/json"
                 ],
@@ -921,14 +2471,21 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Tags"
+                    "Users"
                 ],
-                "summary": "Delete Tag",
+                "summary": "Delete User Conversations",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin API token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
+                        "required": true
+                    },
                     {
                         "type": "string",
                         "format": "uuid",
-                        "description": "Tag ID",
+                        "description": "User ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -936,10 +2493,167 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Tag deleted successfully",
+                        "description": "Number of deleted conversations",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.BulkDeleteResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin API disabled",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/providers": {
+            "get": {
+                "description": "List the distinct providers and models in a user's conversations with conversation counts",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get User Providers",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Providers with models",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.ProviderListResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/recent": {
+            "get": {
+                "description": "Retrieve a user's most recently active conversations across all providers, ordered by last message time (update time for conversations without messages), with last message previews",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get Recent Conversations",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of conversations",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Recent conversations",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.ConversationListResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
                     },
                     "400": {
                         "description": "Bad request",
@@ -948,7 +2662,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Tag not found",
+                        "description": "User not found",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
@@ -962,9 +2676,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users/{id}": {
+        "/api/v1/users/{id}/tags": {
             "get": {
-                "description": "Retrieve a specific user by ID",
+                "description": "List the tags used by a user's conversations with the number of that user's conversations per tag, most used first",
                 "consumes": [
                     "application/json"
                 ],
@@ -974,7 +2688,7 @@ const docTemplate = `{
                 "tags": [
                     "Users"
                 ],
-                "summary": "Get User",
+                "summary": "Get User Tags",
                 "parameters": [
                     {
                         "type": "string",
@@ -987,7 +2701,7 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "User details",
+                        "description": "Tags with usage counts",
                         "schema": {
                             "allOf": [
                                 {
@@ -997,7 +2711,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/response.UserResponse"
+                                            "$ref": "#/definitions/response.TagListResponse"
                                         }
                                     }
                                 }
@@ -1027,6 +2741,111 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "models.ConversationMetadata": {
+            "type": "object",
+            "properties": {
+                "account": {
+                    "type": "string"
+                },
+                "import_note": {
+                    "description": "ImportNote 超限处理的说明，如保留了哪些消息、属于拆分后的第几部分",
+                    "type": "string"
+                },
+                "import_policy": {
+                    "description": "ImportPolicy 导入时对话消息数超过上限所采用的处理方式（truncate 或 split），未超限时为空",
+                    "type": "string"
+                },
+                "project": {
+                    "type": "string"
+                },
+                "summary": {
+                    "type": "string"
+                },
+                "tags_raw": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SearchMeta": {
+            "type": "object",
+            "properties": {
+                "cached": {
+                    "description": "结果来自搜索缓存，其余字段为首次查询时的值",
+                    "type": "boolean"
+                },
+                "fallback": {
+                    "description": "Fallback ES 不可用时由 Postgres 搜索返回的结果（search.fallback），没有相关性排序和高亮",
+                    "type": "boolean"
+                },
+                "post_process_ms": {
+                    "description": "服务端过滤、排序等后处理耗时",
+                    "type": "integer"
+                },
+                "shards_failed": {
+                    "description": "失败的分片数（\u003e0 时结果可能不完整）",
+                    "type": "integer"
+                },
+                "shards_skipped": {
+                    "description": "跳过的分片数",
+                    "type": "integer"
+                },
+                "shards_successful": {
+                    "description": "成功的分片数",
+                    "type": "integer"
+                },
+                "shards_total": {
+                    "description": "分片总数",
+                    "type": "integer"
+                },
+                "timed_out": {
+                    "description": "ES 是否超时",
+                    "type": "boolean"
+                },
+                "took_ms": {
+                    "description": "ES 自身耗时",
+                    "type": "integer"
+                }
+            }
+        },
+        "repositories.SearchSort": {
+            "type": "string",
+            "enum": [
+                "relevance",
+                "date"
+            ],
+            "x-enum-comments": {
+                "SortDate": "只按创建时间倒序，不按相关性重排，即 OrderCreated",
+                "SortRelevance": "相关性，得分相同时按创建时间倒序，即 OrderRelevance"
+            },
+            "x-enum-varnames": [
+                "SortRelevance",
+                "SortDate"
+            ]
+        },
+        "request.BatchCreateTagsRequest": {
+            "type": "object",
+            "required": [
+                "names"
+            ],
+            "properties": {
+                "names": {
+                    "type": "array",
+                    "maxItems": 100,
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "request.CloneConversationRequest": {
+            "type": "object",
+            "properties": {
+                "target_user_id": {
+                    "type": "string"
+                }
+            }
+        },
         "request.CreateConversationRequest": {
             "type": "object",
             "required": [
@@ -1073,6 +2892,152 @@ const docTemplate = `{
                 }
             }
         },
+        "request.MergeConversationsRequest": {
+            "type": "object",
+            "required": [
+                "source_ids",
+                "target_id"
+            ],
+            "properties": {
+                "source_ids": {
+                    "type": "array",
+                    "maxItems": 100,
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "target_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "request.PatchConversationRequest": {
+            "type": "object",
+            "properties": {
+                "add_tags": {
+                    "type": "array",
+                    "maxItems": 100,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "model": {
+                    "type": "string"
+                },
+                "remove_tags": {
+                    "type": "array",
+                    "maxItems": 100,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "request.SearchRequest": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "description": "YYYY-MM-DD",
+                    "type": "string",
+                    "example": "2024-12-31"
+                },
+                "fields": {
+                    "description": "关键词匹配的字段组：title、messages、tags，为空时匹配全部",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "filter": {
+                    "type": "object"
+                },
+                "limit": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "meta": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "min_score": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "order": {
+                    "description": "sort 的别名，另支持 activity；与 sort 同时给出时必须一致",
+                    "type": "string",
+                    "enum": [
+                        "relevance",
+                        "activity",
+                        "created"
+                    ]
+                },
+                "page": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "provider_id": {
+                    "type": "string",
+                    "example": "chatgpt"
+                },
+                "q": {
+                    "type": "string",
+                    "example": "kubernetes"
+                },
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "user",
+                        "assistant",
+                        "system"
+                    ]
+                },
+                "sort": {
+                    "description": "relevance（默认）或 date（只按创建时间倒序）",
+                    "enum": [
+                        "relevance",
+                        "date"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/repositories.SearchSort"
+                        }
+                    ]
+                },
+                "start_date": {
+                    "description": "YYYY-MM-DD",
+                    "type": "string",
+                    "example": "2024-01-01"
+                },
+                "tag_id": {
+                    "type": "string"
+                },
+                "tag_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tag_match": {
+                    "description": "all（默认）或 any",
+                    "type": "string",
+                    "enum": [
+                        "all",
+                        "any"
+                    ]
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
         "request.TagRequest": {
             "type": "object",
             "properties": {
@@ -1086,6 +3051,17 @@ const docTemplate = `{
                 }
             }
         },
+        "request.TransferConversationRequest": {
+            "type": "object",
+            "required": [
+                "target_user_id"
+            ],
+            "properties": {
+                "target_user_id": {
+                    "type": "string"
+                }
+            }
+        },
         "request.UpdateConversationTagsRequest": {
             "type": "object",
             "required": [
@@ -1111,6 +3087,161 @@ const docTemplate = `{
                 }
             }
         },
+        "response.AuditLogListResponse": {
+            "type": "object",
+            "properties": {
+                "audit_logs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.AuditLogResponse"
+                    }
+                }
+            }
+        },
+        "response.AuditLogResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "example": "conversation.delete"
+                },
+                "actor_user_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "object"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "resource_id": {
+                    "type": "string"
+                },
+                "resource_type": {
+                    "type": "string",
+                    "example": "conversation"
+                }
+            }
+        },
+        "response.BatchTagListResponse": {
+            "type": "object",
+            "properties": {
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.BatchTagResponse"
+                    }
+                }
+            }
+        },
+        "response.BatchTagResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "使用该标签的对话数，仅在按用户列出标签时返回",
+                    "type": "integer"
+                },
+                "created": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.BulkDeleteResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "deleted": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "id": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "User conversations deleted successfully"
+                }
+            }
+        },
+        "response.ConversationDetailResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_message": {
+                    "description": "最后一条消息预览，仅在 with_preview=true 时返回",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/response.MessagePreviewResponse"
+                        }
+                    ]
+                },
+                "last_message_at": {
+                    "description": "最后一条消息的时间，没有消息时省略",
+                    "type": "string"
+                },
+                "messages": {
+                    "description": "消息第一页，仅在 include=messages 时返回",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.MessageResponse"
+                    }
+                },
+                "messages_total": {
+                    "type": "integer"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "provider_model": {
+                    "description": "provider/model 组合，如 \"openai/gpt-4\"；model 为空时仅为 provider",
+                    "type": "string"
+                },
+                "source_id": {
+                    "type": "string"
+                },
+                "source_title": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.TagResponse"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
         "response.ConversationListResponse": {
             "type": "object",
             "properties": {
@@ -1131,15 +3262,34 @@ const docTemplate = `{
                 "id": {
                     "type": "string"
                 },
+                "last_message": {
+                    "description": "最后一条消息预览，仅在 with_preview=true 时返回",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/response.MessagePreviewResponse"
+                        }
+                    ]
+                },
+                "last_message_at": {
+                    "description": "最后一条消息的时间，没有消息时省略",
+                    "type": "string"
+                },
                 "model": {
                     "type": "string"
                 },
                 "provider": {
                     "type": "string"
                 },
+                "provider_model": {
+                    "description": "provider/model 组合，如 \"openai/gpt-4\"；model 为空时仅为 provider",
+                    "type": "string"
+                },
                 "source_id": {
                     "type": "string"
                 },
+                "source_title": {
+                    "type": "string"
+                },
                 "tags": {
                     "type": "array",
                     "items": {
@@ -1157,6 +3307,22 @@ const docTemplate = `{
                 }
             }
         },
+        "response.DeleteResponse": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "id": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Conversation deleted successfully"
+                }
+            }
+        },
         "response.ErrorInfo": {
             "type": "object",
             "properties": {
@@ -1168,6 +3334,77 @@ const docTemplate = `{
                 },
                 "message": {
                     "type": "string"
+                },
+                "request_id": {
+                    "description": "RequestID 与响应头 X-Request-ID 相同，便于客户端反馈问题时对应服务端日志",
+                    "type": "string"
+                }
+            }
+        },
+        "response.JobResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string",
+                    "example": "running"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "response.MessageAttachmentResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "url_or_ref": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.MessageContextResponse": {
+            "type": "object",
+            "properties": {
+                "after": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.MessageResponse"
+                    }
+                },
+                "before": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.MessageResponse"
+                    }
+                },
+                "message": {
+                    "$ref": "#/definitions/response.MessageResponse"
                 }
             }
         },
@@ -1182,9 +3419,29 @@ const docTemplate = `{
                 }
             }
         },
+        "response.MessagePreviewResponse": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                }
+            }
+        },
         "response.MessageResponse": {
             "type": "object",
             "properties": {
+                "attachments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.MessageAttachmentResponse"
+                    }
+                },
                 "content": {
                     "type": "string"
                 },
@@ -1200,6 +3457,9 @@ const docTemplate = `{
                 "role": {
                     "type": "string"
                 },
+                "sequence": {
+                    "type": "integer"
+                },
                 "updated_at": {
                     "type": "string"
                 }
@@ -1212,6 +3472,7 @@ const docTemplate = `{
                 "error": {
                     "$ref": "#/definitions/response.ErrorInfo"
                 },
+                "meta": {},
                 "pagination": {
                     "$ref": "#/definitions/response.PaginationInfo"
                 },
@@ -1223,12 +3484,28 @@ const docTemplate = `{
         "response.PaginationInfo": {
             "type": "object",
             "properties": {
+                "has_next": {
+                    "type": "boolean"
+                },
+                "has_prev": {
+                    "type": "boolean"
+                },
                 "limit": {
                     "type": "integer"
                 },
+                "next_cursor": {
+                    "description": "NextCursor is set by cursor-based endpoints instead of page numbers",
+                    "type": "string"
+                },
+                "next_page": {
+                    "type": "integer"
+                },
                 "page": {
                     "type": "integer"
                 },
+                "prev_page": {
+                    "type": "integer"
+                },
                 "total": {
                     "type": "integer"
                 },
@@ -1237,6 +3514,45 @@ const docTemplate = `{
                 }
             }
         },
+        "response.ProviderListResponse": {
+            "type": "object",
+            "properties": {
+                "providers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.ProviderResponse"
+                    }
+                }
+            }
+        },
+        "response.ProviderModelResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "model": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.ProviderResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "models": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.ProviderModelResponse"
+                    }
+                },
+                "provider": {
+                    "type": "string"
+                }
+            }
+        },
         "response.Response": {
             "type": "object",
             "properties": {
@@ -1249,12 +3565,52 @@ const docTemplate = `{
                 }
             }
         },
+        "response.SearchCacheStatsResponse": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "entries": {
+                    "type": "integer"
+                },
+                "evictions": {
+                    "type": "integer"
+                },
+                "hit_rate": {
+                    "description": "HitRate hits / (hits + misses)，还没有查询时为 0",
+                    "type": "number"
+                },
+                "hits": {
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "ttl_seconds": {
+                    "type": "number"
+                }
+            }
+        },
         "response.SearchConversationResponse": {
             "type": "object",
             "properties": {
                 "created_at": {
                     "type": "string"
                 },
+                "highlights": {
+                    "description": "对话字段的高亮片段，键为 title、source_title 或 tags.name；消息的高亮片段在各消息的 highlights 中",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                },
                 "id": {
                     "type": "string"
                 },
@@ -1278,6 +3634,10 @@ const docTemplate = `{
                 "provider": {
                     "type": "string"
                 },
+                "provider_model": {
+                    "description": "provider/model 组合，与 ConversationResponse 一致",
+                    "type": "string"
+                },
                 "source_id": {
                     "type": "string"
                 },
@@ -1291,6 +3651,7 @@ const docTemplate = `{
                     }
                 },
                 "title": {
+                    "description": "title 为规范化标题，source_title 为原始标题，两者分别返回，不互相替代",
                     "type": "string"
                 },
                 "updated_at": {
@@ -1305,6 +3666,7 @@ const docTemplate = `{
             "type": "object",
             "properties": {
                 "content": {
+                    "description": "启用 search.snippet_chars 时 content 和 source_content 不返回，完整内容通过 GET /messages/{id} 获取。\ncontent 为规范化内容，source_content 为原始内容，两者分别返回，不互相替代",
                     "type": "string"
                 },
                 "conversation_id": {
@@ -1313,9 +3675,23 @@ const docTemplate = `{
                 "created_at": {
                     "type": "string"
                 },
+                "highlights": {
+                    "description": "高亮片段，键为 content 或 source_content，关键词以 \u003cmark\u003e 标记",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                },
                 "id": {
                     "type": "string"
                 },
+                "is_context": {
+                    "description": "是否仅作为上下文返回（消息本身不匹配搜索关键词）",
+                    "type": "boolean"
+                },
                 "matched_fields": {
                     "description": "匹配信息，用于前端高亮",
                     "type": "array",
@@ -1326,6 +3702,10 @@ const docTemplate = `{
                 "role": {
                     "type": "string"
                 },
+                "snippet": {
+                    "description": "关键词第一次匹配位置前后的片段，截断处以 … 表示",
+                    "type": "string"
+                },
                 "source_content": {
                     "type": "string"
                 },
@@ -1376,6 +3756,17 @@ const docTemplate = `{
                 }
             }
         },
+        "response.SimilarConversationsResponse": {
+            "type": "object",
+            "properties": {
+                "conversations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.SearchConversationResponse"
+                    }
+                }
+            }
+        },
         "response.TagListResponse": {
             "type": "object",
             "properties": {
@@ -1390,6 +3781,10 @@ const docTemplate = `{
         "response.TagResponse": {
             "type": "object",
             "properties": {
+                "count": {
+                    "description": "使用该标签的对话数，仅在按用户列出标签时返回",
+                    "type": "integer"
+                },
                 "id": {
                     "type": "string"
                 },
@@ -1401,6 +3796,22 @@ const docTemplate = `{
                 }
             }
         },
+        "response.UpdateResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Conversation tags updated successfully"
+                },
+                "updated": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
         "response.UserResponse": {
             "type": "object",
             "properties": {
@@ -1420,6 +3831,73 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "services.ConversationExport": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_message_at": {
+                    "type": "string"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ExportedMessage"
+                    }
+                },
+                "metadata": {
+                    "$ref": "#/definitions/models.ConversationMetadata"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "source_id": {
+                    "type": "string"
+                },
+                "source_title": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.ExportedMessage": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
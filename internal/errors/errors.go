@@ -58,7 +58,11 @@ const (
 	ErrCodeUserNotFound = "USER_NOT_FOUND"
 
 	// Conversation errors
-	ErrCodeConversationNotFound = "CONVERSATION_NOT_FOUND"
+	ErrCodeConversationNotFound      = "CONVERSATION_NOT_FOUND"
+	ErrCodeTagPatchConflict          = "TAG_PATCH_CONFLICT"
+	ErrCodeInvalidMerge              = "INVALID_MERGE"
+	ErrCodeConversationOwnerMismatch = "CONVERSATION_OWNER_MISMATCH"
+	ErrCodeSourceIDConflict          = "SOURCE_ID_CONFLICT"
 
 	// Message errors
 	ErrCodeMessageNotFound = "MESSAGE_NOT_FOUND"
@@ -66,6 +70,7 @@ const (
 	// Tag errors
 	ErrCodeTagNotFound   = "TAG_NOT_FOUND"
 	ErrCodeTagNameExists = "TAG_NAME_EXISTS"
+	ErrCodeTagNameEmpty  = "TAG_NAME_EMPTY"
 
 	// Import errors
 	ErrCodeUnsupportedPlatform = "UNSUPPORTED_PLATFORM"
@@ -73,6 +78,15 @@ const (
 	ErrCodeImportFailed        = "IMPORT_FAILED"
 	ErrCodeValidationFailed    = "VALIDATION_FAILED"
 	ErrCodeImportJobNotFound   = "IMPORT_JOB_NOT_FOUND"
+
+	// Search errors
+	ErrCodeUserIDRequired = "USER_ID_REQUIRED"
+
+	// Admin errors
+	ErrCodeReindexInProgress     = "REINDEX_IN_PROGRESS"
+	ErrCodeReindexJobNotFound    = "REINDEX_JOB_NOT_FOUND"
+	ErrCodeInvalidMapping        = "INVALID_MAPPING"
+	ErrCodeMappingBreakingChange = "MAPPING_BREAKING_CHANGE"
 )
 
 // Predefined errors
@@ -92,12 +106,17 @@ var (
 
 	ErrUserNotFound = NewAppError(ErrCodeUserNotFound, "User not found", http.StatusNotFound)
 
-	ErrConversationNotFound = NewAppError(ErrCodeConversationNotFound, "Conversation not found", http.StatusNotFound)
-	ErrMessageNotFound      = NewAppError(ErrCodeMessageNotFound, "Message not found", http.StatusNotFound)
+	ErrConversationNotFound      = NewAppError(ErrCodeConversationNotFound, "Conversation not found", http.StatusNotFound)
+	ErrTagPatchConflict          = NewAppError(ErrCodeTagPatchConflict, "The same tag is both added and removed", http.StatusBadRequest)
+	ErrInvalidMerge              = NewAppError(ErrCodeInvalidMerge, "Merge needs at least one source conversation other than the target", http.StatusBadRequest)
+	ErrConversationOwnerMismatch = NewAppError(ErrCodeConversationOwnerMismatch, "Conversations belong to different users", http.StatusBadRequest)
+	ErrSourceIDConflict          = NewAppError(ErrCodeSourceIDConflict, "Target user already has a conversation with the same source_id", http.StatusConflict)
+	ErrMessageNotFound           = NewAppError(ErrCodeMessageNotFound, "Message not found", http.StatusNotFound)
 
 	// Tag errors
 	ErrTagNotFound   = NewAppError(ErrCodeTagNotFound, "Tag not found", http.StatusNotFound)
 	ErrTagNameExists = NewAppError(ErrCodeTagNameExists, "Tag name already exists", http.StatusConflict)
+	ErrTagNameEmpty  = NewAppError(ErrCodeTagNameEmpty, "Tag name is empty after normalization", http.StatusBadRequest)
 
 	// Import errors
 	ErrUnsupportedPlatform = NewAppError(ErrCodeUnsupportedPlatform, "Unsupported import platform", http.StatusBadRequest)
@@ -105,6 +124,13 @@ var (
 	ErrImportFailed        = NewAppError(ErrCodeImportFailed, "Import process failed", http.StatusInternalServerError)
 	ErrValidationFailed    = NewAppError(ErrCodeValidationFailed, "Data validation failed", http.StatusBadRequest)
 	ErrImportJobNotFound   = NewAppError(ErrCodeImportJobNotFound, "Import job not found", http.StatusNotFound)
+
+	// Search errors
+	ErrUserIDRequired = NewAppError(ErrCodeUserIDRequired, "User ID is required", http.StatusBadRequest)
+
+	// Admin errors
+	ErrReindexInProgress  = NewAppError(ErrCodeReindexInProgress, "A reindex is already in progress", http.StatusConflict)
+	ErrReindexJobNotFound = NewAppError(ErrCodeReindexJobNotFound, "Reindex job not found", http.StatusNotFound)
 )
 
 // Response represents a standard API response
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"net/http"
+
+	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/repositories"
+	"chat-assistant-backend/internal/response"
+	"chat-assistant-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AdminHandler handles admin HTTP requests
+type AdminHandler struct {
+	reindexService services.ReindexService
+	auditService   services.AuditService
+	mappingService services.MappingService
+	searchCache    *services.SearchCache
+}
+
+// NewAdminHandler creates a new admin handler; searchCache is nil when search caching is disabled
+func NewAdminHandler(reindexService services.ReindexService, auditService services.AuditService, mappingService services.MappingService, searchCache *services.SearchCache) *AdminHandler {
+	return &AdminHandler{
+		reindexService: reindexService,
+		auditService:   auditService,
+		mappingService: mappingService,
+		searchCache:    searchCache,
+	}
+}
+
+// StartReindex handles POST /api/v1/admin/reindex
+// @Summary Start Reindex
+// @Description Rebuild the search index from the database in the background
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin API token"
+// @Success 202 {object} response.Response{data=response.JobResponse} "Reindex job started"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Admin API disabled"
+// @Failure 409 {object} response.Response "Reindex already in progress"
+// @Router /api/v1/admin/reindex [post]
+func (h *AdminHandler) StartReindex(c *gin.Context) {
+	job, err := h.reindexService.StartReindex()
+	if err != nil {
+		if err == errors.ErrReindexInProgress {
+			response.Conflict(c, "REINDEX_IN_PROGRESS", "A reindex is already in progress", "Wait for the running reindex job to finish")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to start reindex")
+		return
+	}
+
+	response.Accepted(c, response.NewJobResponse(job))
+}
+
+// GetReindexJob handles GET /api/v1/admin/reindex/{jobId}
+// @Summary Get Reindex Job
+// @Description Retrieve the progress of a reindex job
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin API token"
+// @Param jobId path string true "Reindex job ID"
+// @Success 200 {object} response.Response{data=response.JobResponse} "Reindex job status"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Admin API disabled"
+// @Failure 404 {object} response.Response "Reindex job not found"
+// @Router /api/v1/admin/reindex/{jobId} [get]
+func (h *AdminHandler) GetReindexJob(c *gin.Context) {
+	job, err := h.reindexService.GetReindexJob(c.Param("jobId"))
+	if err != nil {
+		if err == errors.ErrReindexJobNotFound {
+			response.NotFound(c, "REINDEX_JOB_NOT_FOUND", "Reindex job not found", "No reindex job found with the specified ID")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve reindex job")
+		return
+	}
+
+	response.Success(c, response.NewJobResponse(job))
+}
+
+// GetAuditLogs handles GET /api/v1/admin/audit-logs
+// @Summary List Audit Logs
+// @Description Retrieve audit logs of destructive operations, newest first
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin API token"
+// @Param action query string false "Filter by action, e.g. conversation.delete"
+// @Param resource_type query string false "Filter by resource type" Enums(conversation, message, tag, user)
+// @Param resource_id query string false "Filter by resource ID" Format(uuid)
+// @Param start_date query string false "Only logs on or after this date" Format(date)
+// @Param end_date query string false "Only logs on or before this date" Format(date)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} response.PaginatedResponse{data=response.AuditLogListResponse} "Audit logs"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Admin API disabled"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/admin/audit-logs [get]
+func (h *AdminHandler) GetAuditLogs(c *gin.Context) {
+	filter := repositories.AuditLogFilter{
+		Action:       c.Query("action"),
+		ResourceType: c.Query("resource_type"),
+	}
+
+	if resourceIDStr := c.Query("resource_id"); resourceIDStr != "" {
+		resourceID, err := uuid.Parse(resourceIDStr)
+		if err != nil {
+			response.BadRequest(c, "INVALID_UUID", "Invalid resource ID format", "Resource ID must be a valid UUID")
+			return
+		}
+		filter.ResourceID = &resourceID
+	}
+
+	var err error
+	if filter.StartDate, err = parseSearchDate(c.Query("start_date"), false); err != nil {
+		response.BadRequest(c, "INVALID_DATE", "Invalid start date format", "Start date must be in YYYY-MM-DD format")
+		return
+	}
+	if filter.EndDate, err = parseSearchDate(c.Query("end_date"), true); err != nil {
+		response.BadRequest(c, "INVALID_DATE", "Invalid end date format", "End date must be in YYYY-MM-DD format")
+		return
+	}
+
+	// Parse pagination parameters
+	page, limit, ok := parsePagination(c, 20)
+	if !ok {
+		return
+	}
+
+	logs, total, err := h.auditService.List(filter, page, limit)
+	if err != nil {
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve audit logs")
+		return
+	}
+
+	pagination := &response.PaginationInfo{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: int((total + int64(limit) - 1) / int64(limit)),
+	}
+
+	response.SuccessPaginated(c, response.NewAuditLogListResponse(logs), pagination)
+}
+
+// GetIndexMapping handles GET /api/v1/admin/es/mapping
+// @Summary Get Index Mapping
+// @Description Retrieve the live mapping of the conversations index from Elasticsearch
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin API token"
+// @Success 200 {object} response.Response{data=object} "Index mapping"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Admin API disabled"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/admin/es/mapping [get]
+func (h *AdminHandler) GetIndexMapping(c *gin.Context) {
+	mapping, err := h.mappingService.GetMapping(c.Request.Context())
+	if err != nil {
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve index mapping")
+		return
+	}
+
+	response.Success(c, mapping)
+}
+
+// UpdateIndexMapping handles PUT /api/v1/admin/es/mapping
+// @Summary Update Index Mapping
+// @Description Add new fields to the mapping of the conversations index. The body uses the format of the Elasticsearch put mapping API, e.g. {"properties": {...}}. Changing existing fields is rejected; that requires a new index and a reindex
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin API token"
+// @Param mapping body object true "Mapping additions"
+// @Success 200 {object} response.Response{data=object} "Updated index mapping"
+// @Failure 400 {object} response.Response "Invalid mapping or breaking change"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Admin API disabled"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/admin/es/mapping [put]
+func (h *AdminHandler) UpdateIndexMapping(c *gin.Context) {
+	var mapping map[string]interface{}
+	if err := c.ShouldBindJSON(&mapping); err != nil {
+		response.BadRequest(c, "INVALID_REQUEST", "Invalid request data", err.Error())
+		return
+	}
+
+	updated, err := h.mappingService.UpdateMapping(c.Request.Context(), mapping)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Status == http.StatusBadRequest {
+			response.BadRequest(c, appErr.Code, appErr.Message, appErr.Details)
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to update index mapping")
+		return
+	}
+
+	response.Success(c, updated)
+}
+
+// GetSearchCacheStats handles GET /api/v1/admin/search/cache
+// @Summary Get Search Cache Stats
+// @Description Retrieve the hit/miss counters of the in-memory search result cache (search.cache_ttl)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin API token"
+// @Success 200 {object} response.Response{data=response.SearchCacheStatsResponse} "Search cache stats"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Admin API disabled"
+// @Router /api/v1/admin/search/cache [get]
+func (h *AdminHandler) GetSearchCacheStats(c *gin.Context) {
+	stats := h.searchCache.Stats()
+
+	resp := &response.SearchCacheStatsResponse{
+		Enabled:    stats.Enabled,
+		Size:       stats.Size,
+		TTLSeconds: stats.TTL.Seconds(),
+		Entries:    stats.Entries,
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		Evictions:  stats.Evictions,
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		resp.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+
+	response.Success(c, resp)
+}
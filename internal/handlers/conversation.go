@@ -1,10 +1,13 @@
 package handlers
 
 import (
-	"strconv"
+	"fmt"
+	"net/http"
+	"strings"
 
 	"chat-assistant-backend/internal/errors"
 	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/repositories"
 	"chat-assistant-backend/internal/request"
 	"chat-assistant-backend/internal/response"
 	"chat-assistant-backend/internal/services"
@@ -13,6 +16,9 @@ import (
 	"github.com/google/uuid"
 )
 
+// conversationDetailMessageLimit include=messages 时内联返回的消息数（第一页）
+const conversationDetailMessageLimit = 50
+
 // ConversationHandler handles conversation-related HTTP requests
 type ConversationHandler struct {
 	conversationService services.ConversationService
@@ -34,6 +40,10 @@ func NewConversationHandler(conversationService services.ConversationService) *C
 // @Param user_id query string true "User ID" Format(uuid)
 // @Param page query int false "Page number" default(1)
 // @Param limit query int false "Items per page" default(10)
+// @Param with_preview query bool false "Include last message preview for each conversation" default(false)
+// @Param order query string false "Sort by creation time or by last message time (most recently active first)" Enums(created, activity) default(created)
+// @Param start_date query string false "Only conversations created (last active with order=activity) on or after this date" Format(date)
+// @Param end_date query string false "Only conversations created (last active with order=activity) on or before this date" Format(date)
 // @Success 200 {object} response.PaginatedResponse{data=response.ConversationListResponse} "Conversations list"
 // @Failure 400 {object} response.Response "Bad request"
 // @Failure 500 {object} response.Response "Internal server error"
@@ -53,33 +63,56 @@ func (h *ConversationHandler) GetConversations(c *gin.Context) {
 	}
 
 	// Parse pagination parameters
-	page := 1
-	limit := 10
-
-	if pageStr := c.Query("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
+	page, limit, ok := parsePagination(c, 10)
+	if !ok {
+		return
 	}
 
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
-		}
+	withPreview := c.Query("with_preview") == "true"
+
+	order, err := repositories.ParseListOrder(c.Query("order"))
+	if err != nil {
+		response.BadRequest(c, "INVALID_ORDER", "Invalid order", err.Error())
+		return
 	}
 
-	// Get conversations from service
-	conversations, total, err := h.conversationService.GetConversationsByUserID(userID, page, limit)
+	// 日期范围与搜索接口的校验一致，end_date 包含当天
+	startDate, err := parseSearchDate(c.Query("start_date"), false)
 	if err != nil {
-		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve conversations")
+		response.BadRequest(c, "INVALID_DATE", "Invalid start date format", "Start date must be in YYYY-MM-DD format")
 		return
 	}
+	endDate, err := parseSearchDate(c.Query("end_date"), true)
+	if err != nil {
+		response.BadRequest(c, "INVALID_DATE", "Invalid end date format", "End date must be in YYYY-MM-DD format")
+		return
+	}
+
+	// Get conversations from service
+	var conversationResponse *response.ConversationListResponse
+	var total int64
+	if withPreview {
+		conversations, lastMessages, count, err := h.conversationService.GetConversationsByUserIDWithPreview(userID, startDate, endDate, page, limit, order.String())
+		if err != nil {
+			response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve conversations")
+			return
+		}
+		conversationResponse = response.NewConversationListResponseWithPreview(conversations, lastMessages)
+		total = count
+	} else {
+		conversations, count, err := h.conversationService.GetConversationsByUserID(userID, startDate, endDate, page, limit, order.String())
+		if err != nil {
+			response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve conversations")
+			return
+		}
+		conversationResponse = response.NewConversationListResponse(conversations)
+		total = count
+	}
 
 	// Calculate total pages
 	totalPages := int((total + int64(limit) - 1) / int64(limit))
 
 	// Return success response
-	conversationResponse := response.NewConversationListResponse(conversations)
 	pagination := &response.PaginationInfo{
 		Page:       page,
 		Limit:      limit,
@@ -90,6 +123,129 @@ func (h *ConversationHandler) GetConversations(c *gin.Context) {
 	response.SuccessPaginated(c, conversationResponse, pagination)
 }
 
+// GetRecentConversations handles GET /api/v1/users/{id}/recent
+// @Summary Get Recent Conversations
+// @Description Retrieve a user's most recently active conversations across all providers, ordered by last message time (update time for conversations without messages), with last message previews
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID" Format(uuid)
+// @Param limit query int false "Number of conversations" default(10)
+// @Success 200 {object} response.Response{data=response.ConversationListResponse} "Recent conversations"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/users/{id}/recent [get]
+func (h *ConversationHandler) GetRecentConversations(c *gin.Context) {
+	// Parse user ID from path parameter
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "INVALID_UUID", "Invalid user ID format", "User ID must be a valid UUID")
+		return
+	}
+
+	limit, ok := parseLimit(c, 10, maxPageLimit)
+	if !ok {
+		return
+	}
+
+	conversations, lastMessages, err := h.conversationService.GetRecentConversations(userID, limit)
+	if err != nil {
+		if err == errors.ErrUserNotFound {
+			response.NotFound(c, "USER_NOT_FOUND", "User not found", "No user found with the specified ID")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve recent conversations")
+		return
+	}
+
+	response.Success(c, response.NewConversationListResponseWithPreview(conversations, lastMessages))
+}
+
+// DeleteUserConversations handles DELETE /api/v1/users/{id}/conversations
+// @Summary Delete User Conversations
+// @Description Permanently delete all conversations and messages of a user from the database and the search index, e.g. for GDPR erasure. Requires the admin token; the user and their tags are kept
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin API token"
+// @Param id path string true "User ID" Format(uuid)
+// @Success 200 {object} response.Response{data=response.BulkDeleteResponse} "Number of deleted conversations"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Admin API disabled"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/users/{id}/conversations [delete]
+func (h *ConversationHandler) DeleteUserConversations(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "INVALID_UUID", "Invalid user ID format", "User ID must be a valid UUID")
+		return
+	}
+
+	deleted, err := h.conversationService.DeleteAllForUser(c.Request.Context(), userID)
+	if err != nil {
+		if err == errors.ErrUserNotFound {
+			response.NotFound(c, "USER_NOT_FOUND", "User not found", "No user found with the specified ID")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to delete user conversations")
+		return
+	}
+
+	response.Success(c, response.NewBulkDeleteResponse(userID, deleted, "User conversations deleted successfully"))
+}
+
+// GetConversationsByTag handles GET /api/v1/tags/{id}/conversations
+// @Summary Get Conversations By Tag
+// @Description Retrieve the conversations carrying a tag with pagination, newest first
+// @Tags Tags
+// @Accept json
+// @Produce json
+// @Param id path string true "Tag ID" Format(uuid)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} response.PaginatedResponse{data=response.ConversationListResponse} "Conversations list"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 404 {object} response.Response "Tag not found"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/tags/{id}/conversations [get]
+func (h *ConversationHandler) GetConversationsByTag(c *gin.Context) {
+	tagID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "INVALID_UUID", "Invalid tag ID format", "Tag ID must be a valid UUID")
+		return
+	}
+
+	page, limit, ok := parsePagination(c, 10)
+	if !ok {
+		return
+	}
+
+	conversations, total, err := h.conversationService.GetConversationsByTagID(tagID, page, limit)
+	if err != nil {
+		if err == errors.ErrTagNotFound {
+			response.NotFound(c, "TAG_NOT_FOUND", "Tag not found", "No tag found with the specified ID")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve conversations")
+		return
+	}
+
+	pagination := &response.PaginationInfo{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: int((total + int64(limit) - 1) / int64(limit)),
+	}
+
+	response.SuccessPaginated(c, response.NewConversationListResponse(conversations), pagination)
+}
+
 // GetConversation handles GET /api/v1/conversations/{id}
 // @Summary Get Conversation
 // @Description Retrieve a specific conversation by ID
@@ -97,7 +253,10 @@ func (h *ConversationHandler) GetConversations(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path string true "Conversation ID" Format(uuid)
-// @Success 200 {object} response.Response{data=response.ConversationResponse} "Conversation details"
+// @Param include query string false "Comma-separated related data to include: messages (first page), tags (always included)"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Success 200 {object} response.Response{data=response.ConversationDetailResponse} "Conversation details"
+// @Success 304 "Conversation not modified"
 // @Failure 400 {object} response.Response "Bad request"
 // @Failure 404 {object} response.Response "Conversation not found"
 // @Failure 500 {object} response.Response "Internal server error"
@@ -111,8 +270,30 @@ func (h *ConversationHandler) GetConversation(c *gin.Context) {
 		return
 	}
 
+	// Parse include option (optional)
+	includeMessages := false
+	if includeStr := c.Query("include"); includeStr != "" {
+		for _, include := range strings.Split(includeStr, ",") {
+			switch strings.TrimSpace(include) {
+			case "messages":
+				includeMessages = true
+			case "tags":
+				// 标签总是随对话返回
+			default:
+				response.BadRequest(c, "INVALID_INCLUDE", "Invalid include option", "include must be a comma-separated list of: messages, tags")
+				return
+			}
+		}
+	}
+
 	// Get conversation from service
-	conversation, err := h.conversationService.GetConversationByID(conversationID)
+	var conversation *models.Conversation
+	var messagesTotal int64
+	if includeMessages {
+		conversation, messagesTotal, err = h.conversationService.GetConversationWithMessages(conversationID, conversationDetailMessageLimit)
+	} else {
+		conversation, err = h.conversationService.GetConversationByID(conversationID)
+	}
 	if err != nil {
 		if err == errors.ErrConversationNotFound {
 			response.NotFound(c, "CONVERSATION_NOT_FOUND", "Conversation not found", "No conversation found with the specified ID")
@@ -123,11 +304,64 @@ func (h *ConversationHandler) GetConversation(c *gin.Context) {
 		return
 	}
 
+	// 内容未变化时返回 304，客户端可直接使用缓存
+	if response.WithETag(c, response.ConversationETag(conversation, includeMessages, messagesTotal)) {
+		return
+	}
+
 	// Return success response
-	conversationResponse := response.NewConversationResponse(conversation)
+	conversationResponse := response.NewConversationDetailResponse(conversation, includeMessages, messagesTotal)
 	response.Success(c, conversationResponse)
 }
 
+// GetConversationBySource handles GET /api/v1/conversations/by-source
+// @Summary Get Conversation By Source ID
+// @Description Retrieve a user's conversation by the provider and the conversation's original ID on that provider, e.g. for integrations that track external IDs
+// @Tags Conversations
+// @Accept json
+// @Produce json
+// @Param user_id query string true "User ID" Format(uuid)
+// @Param provider query string true "Provider the conversation was imported from, e.g. chatgpt"
+// @Param source_id query string true "Conversation ID on the provider"
+// @Success 200 {object} response.Response{data=response.ConversationDetailResponse} "Conversation details"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 404 {object} response.Response "Conversation not found"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/conversations/by-source [get]
+func (h *ConversationHandler) GetConversationBySource(c *gin.Context) {
+	userIDStr := c.Query("user_id")
+	if userIDStr == "" {
+		response.BadRequest(c, "MISSING_USER_ID", "User ID is required", "user_id query parameter is required")
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		response.BadRequest(c, "INVALID_UUID", "Invalid user ID format", "User ID must be a valid UUID")
+		return
+	}
+
+	provider := strings.TrimSpace(c.Query("provider"))
+	sourceID := strings.TrimSpace(c.Query("source_id"))
+	if provider == "" || sourceID == "" {
+		response.BadRequest(c, "INVALID_REQUEST", "Invalid request data", "provider and source_id query parameters are required")
+		return
+	}
+
+	conversation, err := h.conversationService.GetConversationBySourceID(userID, provider, sourceID)
+	if err != nil {
+		if err == errors.ErrConversationNotFound {
+			response.NotFound(c, "CONVERSATION_NOT_FOUND", "Conversation not found", "No conversation found with the specified provider and source_id")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve conversation")
+		return
+	}
+
+	response.Success(c, response.NewConversationDetailResponse(conversation, false, 0))
+}
+
 // DeleteConversation handles DELETE /api/v1/conversations/{id}
 // @Summary Delete Conversation
 // @Description Delete a specific conversation by ID
@@ -135,7 +369,7 @@ func (h *ConversationHandler) GetConversation(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path string true "Conversation ID" Format(uuid)
-// @Success 200 {object} response.Response "Conversation deleted successfully"
+// @Success 200 {object} response.Response{data=response.DeleteResponse} "Conversation deleted successfully"
 // @Failure 400 {object} response.Response "Bad request"
 // @Failure 404 {object} response.Response "Conversation not found"
 // @Failure 500 {object} response.Response "Internal server error"
@@ -150,7 +384,7 @@ func (h *ConversationHandler) DeleteConversation(c *gin.Context) {
 	}
 
 	// Delete conversation from service
-	err = h.conversationService.DeleteConversation(conversationID)
+	err = h.conversationService.DeleteConversation(c.Request.Context(), conversationID)
 	if err != nil {
 		if err == errors.ErrConversationNotFound {
 			response.NotFound(c, "CONVERSATION_NOT_FOUND", "Conversation not found", "No conversation found with the specified ID")
@@ -162,7 +396,7 @@ func (h *ConversationHandler) DeleteConversation(c *gin.Context) {
 	}
 
 	// Return success response
-	response.Success(c, gin.H{"message": "Conversation deleted successfully"})
+	response.Success(c, response.NewDeleteResponse(conversationID, "Conversation deleted successfully"))
 }
 
 // CreateConversation handles POST /api/v1/conversations
@@ -200,7 +434,7 @@ func (h *ConversationHandler) CreateConversation(c *gin.Context) {
 	}
 
 	// 创建对话和标签
-	createdConversation, err := h.conversationService.CreateConversationWithTags(conversation, tagNames)
+	createdConversation, err := h.conversationService.CreateConversationWithTags(c.Request.Context(), conversation, tagNames)
 	if err != nil {
 		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to create conversation")
 		return
@@ -219,7 +453,7 @@ func (h *ConversationHandler) CreateConversation(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Conversation ID" Format(uuid)
 // @Param tags body request.UpdateConversationTagsRequest true "Tags data"
-// @Success 200 {object} response.Response "Tags updated successfully"
+// @Success 200 {object} response.Response{data=response.UpdateResponse} "Tags updated successfully"
 // @Failure 400 {object} response.Response "Bad request"
 // @Failure 404 {object} response.Response "Conversation not found"
 // @Failure 500 {object} response.Response "Internal server error"
@@ -246,7 +480,7 @@ func (h *ConversationHandler) UpdateConversationTags(c *gin.Context) {
 	}
 
 	// 更新对话标签
-	err = h.conversationService.UpdateConversationTags(conversationID, tagNames)
+	err = h.conversationService.UpdateConversationTags(c.Request.Context(), conversationID, tagNames)
 	if err != nil {
 		if err == errors.ErrConversationNotFound {
 			response.NotFound(c, "CONVERSATION_NOT_FOUND", "Conversation not found", "No conversation found with the specified ID")
@@ -258,5 +492,261 @@ func (h *ConversationHandler) UpdateConversationTags(c *gin.Context) {
 	}
 
 	// Return success response
-	response.Success(c, gin.H{"message": "Conversation tags updated successfully"})
+	response.Success(c, response.NewUpdateResponse(conversationID, "Conversation tags updated successfully"))
+}
+
+// PatchConversation handles PATCH /api/v1/conversations/{id}
+// @Summary Patch Conversation
+// @Description Partially update a conversation. Only the fields present in the body are applied: title and model are
+// @Description set (an empty string is a value), add_tags are attached and remove_tags detached while other tags are kept
+// @Tags Conversations
+// @Accept json
+// @Produce json
+// @Param id path string true "Conversation ID" Format(uuid)
+// @Param patch body request.PatchConversationRequest true "Fields to update"
+// @Success 200 {object} response.Response{data=response.ConversationResponse} "Conversation updated successfully"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 404 {object} response.Response "Conversation not found"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/conversations/{id} [patch]
+func (h *ConversationHandler) PatchConversation(c *gin.Context) {
+	// Parse conversation ID from path parameter
+	conversationIDStr := c.Param("id")
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		response.BadRequest(c, "INVALID_UUID", "Invalid conversation ID format", "Conversation ID must be a valid UUID")
+		return
+	}
+
+	var req request.PatchConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "INVALID_REQUEST", "Invalid request data", err.Error())
+		return
+	}
+
+	if req.Empty() {
+		response.BadRequest(c, "INVALID_REQUEST", "Invalid request data", "At least one of title, model, add_tags, remove_tags must be provided")
+		return
+	}
+
+	conversation, err := h.conversationService.PatchConversation(c.Request.Context(), conversationID, services.ConversationPatch{
+		Title:      req.Title,
+		Model:      req.Model,
+		AddTags:    req.AddTags,
+		RemoveTags: req.RemoveTags,
+	})
+	if err != nil {
+		if err == errors.ErrConversationNotFound {
+			response.NotFound(c, "CONVERSATION_NOT_FOUND", "Conversation not found", "No conversation found with the specified ID")
+			return
+		}
+		if err == errors.ErrTagPatchConflict {
+			response.BadRequest(c, errors.ErrCodeTagPatchConflict, "Conflicting tag changes", "A tag cannot be in both add_tags and remove_tags")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to update conversation")
+		return
+	}
+
+	response.Success(c, response.NewConversationResponse(conversation))
+}
+
+// ExportConversation handles GET /api/v1/conversations/{id}/export
+// @Summary Export Conversation
+// @Description Download a conversation with all messages in chronological order, its tags, provider/model and metadata.
+// @Description format=markdown puts the conversation fields, tags and metadata in a YAML front matter block
+// @Tags Conversations
+// @Produce json
+// @Produce text/markdown
+// @Param id path string true "Conversation ID" Format(uuid)
+// @Param format query string false "Export format" Enums(json, markdown) default(json)
+// @Success 200 {object} services.ConversationExport "Conversation export"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 404 {object} response.Response "Conversation not found"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/conversations/{id}/export [get]
+func (h *ConversationHandler) ExportConversation(c *gin.Context) {
+	// Parse conversation ID from path parameter
+	conversationIDStr := c.Param("id")
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		response.BadRequest(c, "INVALID_UUID", "Invalid conversation ID format", "Conversation ID must be a valid UUID")
+		return
+	}
+
+	format := c.DefaultQuery("format", services.ExportFormatJSON)
+	contentType, extension := "application/json; charset=utf-8", "json"
+	switch format {
+	case services.ExportFormatJSON:
+	case services.ExportFormatMarkdown:
+		contentType, extension = "text/markdown; charset=utf-8", "md"
+	default:
+		response.BadRequest(c, "INVALID_FORMAT", "Invalid export format", "format must be one of: json, markdown")
+		return
+	}
+
+	data, err := h.conversationService.ExportConversation(conversationID, format)
+	if err != nil {
+		if err == errors.ErrConversationNotFound {
+			response.NotFound(c, "CONVERSATION_NOT_FOUND", "Conversation not found", "No conversation found with the specified ID")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to export conversation")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="conversation-%s.%s"`, conversationID, extension))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// TransferConversation handles POST /api/v1/conversations/{id}/transfer
+// @Summary Transfer Conversation
+// @Description Move a conversation to another user
+// @Tags Conversations
+// @Accept json
+// @Produce json
+// @Param id path string true "Conversation ID" Format(uuid)
+// @Param transfer body request.TransferConversationRequest true "Target user"
+// @Success 200 {object} response.Response{data=response.ConversationResponse} "Conversation transferred successfully"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 404 {object} response.Response "Conversation or user not found"
+// @Failure 409 {object} response.Response "Target user already has a conversation with the same source_id (SOURCE_ID_CONFLICT)"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/conversations/{id}/transfer [post]
+func (h *ConversationHandler) TransferConversation(c *gin.Context) {
+	// Parse conversation ID from path parameter
+	conversationIDStr := c.Param("id")
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		response.BadRequest(c, "INVALID_UUID", "Invalid conversation ID format", "Conversation ID must be a valid UUID")
+		return
+	}
+
+	var req request.TransferConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "INVALID_REQUEST", "Invalid request data", err.Error())
+		return
+	}
+
+	conversation, err := h.conversationService.Transfer(c.Request.Context(), conversationID, req.TargetUserID)
+	if err != nil {
+		if err == errors.ErrConversationNotFound {
+			response.NotFound(c, "CONVERSATION_NOT_FOUND", "Conversation not found", "No conversation found with the specified ID")
+			return
+		}
+		if err == errors.ErrUserNotFound {
+			response.NotFound(c, "USER_NOT_FOUND", "User not found", "No user found with the specified target_user_id")
+			return
+		}
+		if err == errors.ErrSourceIDConflict {
+			response.Conflict(c, "SOURCE_ID_CONFLICT", "Source ID conflict", "The target user already has a conversation (possibly deleted) with the same source_id")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to transfer conversation")
+		return
+	}
+
+	// Return success response
+	response.Success(c, response.NewConversationResponse(conversation))
+}
+
+// MergeConversations handles POST /api/v1/conversations/merge
+// @Summary Merge Conversations
+// @Description Move all messages and tags of the source conversations into the target conversation and delete the sources.
+// @Description Messages are renumbered in chronological order. All conversations must belong to the same user
+// @Tags Conversations
+// @Accept json
+// @Produce json
+// @Param merge body request.MergeConversationsRequest true "Target and source conversations"
+// @Success 200 {object} response.Response{data=response.ConversationResponse} "Conversations merged successfully"
+// @Failure 400 {object} response.Response "Bad request or conversations of different users"
+// @Failure 404 {object} response.Response "Conversation not found"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/conversations/merge [post]
+func (h *ConversationHandler) MergeConversations(c *gin.Context) {
+	var req request.MergeConversationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "INVALID_REQUEST", "Invalid request data", err.Error())
+		return
+	}
+
+	conversation, err := h.conversationService.Merge(c.Request.Context(), req.TargetID, req.SourceIDs)
+	if err != nil {
+		if err == errors.ErrConversationNotFound {
+			response.NotFound(c, "CONVERSATION_NOT_FOUND", "Conversation not found", "The target or a source conversation does not exist")
+			return
+		}
+		if err == errors.ErrInvalidMerge {
+			response.BadRequest(c, errors.ErrCodeInvalidMerge, "Invalid merge", "source_ids must not contain target_id")
+			return
+		}
+		if err == errors.ErrConversationOwnerMismatch {
+			response.BadRequest(c, errors.ErrCodeConversationOwnerMismatch, "Conversations belong to different users", "Only conversations of the same user can be merged")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to merge conversations")
+		return
+	}
+
+	// Return success response
+	response.Success(c, response.NewConversationResponse(conversation))
+}
+
+// CloneConversation handles POST /api/v1/conversations/{id}/clone
+// @Summary Clone Conversation
+// @Description Copy a conversation with its tags and all messages, optionally to another user
+// @Tags Conversations
+// @Accept json
+// @Produce json
+// @Param id path string true "Conversation ID" Format(uuid)
+// @Param clone body request.CloneConversationRequest false "Target user (defaults to the original owner)"
+// @Success 200 {object} response.Response{data=response.ConversationResponse} "Conversation cloned successfully"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 404 {object} response.Response "Conversation or user not found"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/conversations/{id}/clone [post]
+func (h *ConversationHandler) CloneConversation(c *gin.Context) {
+	// Parse conversation ID from path parameter
+	conversationIDStr := c.Param("id")
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		response.BadRequest(c, "INVALID_UUID", "Invalid conversation ID format", "Conversation ID must be a valid UUID")
+		return
+	}
+
+	// 请求体可选，为空时克隆给原用户
+	var req request.CloneConversationRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "INVALID_REQUEST", "Invalid request data", err.Error())
+			return
+		}
+	}
+
+	targetUserID := uuid.Nil
+	if req.TargetUserID != nil {
+		targetUserID = *req.TargetUserID
+	}
+
+	conversation, err := h.conversationService.Clone(c.Request.Context(), conversationID, targetUserID)
+	if err != nil {
+		if err == errors.ErrConversationNotFound {
+			response.NotFound(c, "CONVERSATION_NOT_FOUND", "Conversation not found", "No conversation found with the specified ID")
+			return
+		}
+		if err == errors.ErrUserNotFound {
+			response.NotFound(c, "USER_NOT_FOUND", "User not found", "No user found with the specified target_user_id")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to clone conversation")
+		return
+	}
+
+	// Return success response
+	response.Success(c, response.NewConversationResponse(conversation))
 }
@@ -4,6 +4,7 @@ import (
 	"strconv"
 
 	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/repositories"
 	"chat-assistant-backend/internal/response"
 	"chat-assistant-backend/internal/services"
 
@@ -11,6 +12,12 @@ import (
 	"github.com/google/uuid"
 )
 
+// 消息上下文前后各取的默认条数和上限
+const (
+	defaultMessageContext = 2
+	maxMessageContext     = 10
+)
+
 // MessageHandler handles message-related HTTP requests
 type MessageHandler struct {
 	messageService services.MessageService
@@ -37,19 +44,9 @@ func NewMessageHandler(messageService services.MessageService) *MessageHandler {
 // @Router /api/v1/messages [get]
 func (h *MessageHandler) GetMessages(c *gin.Context) {
 	// Parse pagination parameters
-	page := 1
-	limit := 10
-
-	if pageStr := c.Query("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
-	}
-
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
-		}
+	page, limit, ok := parsePagination(c, 10)
+	if !ok {
+		return
 	}
 
 	// Get messages from service
@@ -112,6 +109,64 @@ func (h *MessageHandler) GetMessage(c *gin.Context) {
 	response.Success(c, messageResponse)
 }
 
+// GetMessageContext handles GET /api/v1/messages/{id}/context
+// @Summary Get Message Context
+// @Description Retrieve a message together with its neighboring messages in the same conversation, ordered by creation time
+// @Tags Messages
+// @Accept json
+// @Produce json
+// @Param id path string true "Message ID" Format(uuid)
+// @Param before query int false "Number of messages before the target (max 10)" default(2)
+// @Param after query int false "Number of messages after the target (max 10)" default(2)
+// @Success 200 {object} response.Response{data=response.MessageContextResponse} "Message with context"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 404 {object} response.Response "Message not found"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/messages/{id}/context [get]
+func (h *MessageHandler) GetMessageContext(c *gin.Context) {
+	// Parse message ID from path parameter
+	messageIDStr := c.Param("id")
+	messageID, err := uuid.Parse(messageIDStr)
+	if err != nil {
+		response.BadRequest(c, "INVALID_UUID", "Invalid message ID format", "Message ID must be a valid UUID")
+		return
+	}
+
+	before := parseMessageContextSize(c.Query("before"))
+	after := parseMessageContextSize(c.Query("after"))
+
+	// Get message context from service
+	messageContext, err := h.messageService.GetMessageContext(messageID, before, after)
+	if err != nil {
+		if err == errors.ErrMessageNotFound {
+			response.NotFound(c, "MESSAGE_NOT_FOUND", "Message not found", "No message found with the specified ID")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve message context")
+		return
+	}
+
+	// Return success response
+	response.Success(c, response.NewMessageContextResponse(messageContext))
+}
+
+// parseMessageContextSize 解析 before/after 参数，无效时使用默认值，超过上限时截断
+func parseMessageContextSize(value string) int {
+	if value == "" {
+		return defaultMessageContext
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return defaultMessageContext
+	}
+	if n > maxMessageContext {
+		return maxMessageContext
+	}
+	return n
+}
+
 // DeleteMessage handles DELETE /api/v1/messages/{id}
 // @Summary Delete Message
 // @Description Delete a specific message by ID
@@ -119,7 +174,7 @@ func (h *MessageHandler) GetMessage(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path string true "Message ID" Format(uuid)
-// @Success 200 {object} response.Response "Message deleted successfully"
+// @Success 200 {object} response.Response{data=response.DeleteResponse} "Message deleted successfully"
 // @Failure 400 {object} response.Response "Bad request"
 // @Failure 404 {object} response.Response "Message not found"
 // @Failure 500 {object} response.Response "Internal server error"
@@ -134,7 +189,7 @@ func (h *MessageHandler) DeleteMessage(c *gin.Context) {
 	}
 
 	// Delete message from service
-	err = h.messageService.DeleteMessage(messageID)
+	err = h.messageService.DeleteMessage(c.Request.Context(), messageID)
 	if err != nil {
 		if err == errors.ErrMessageNotFound {
 			response.NotFound(c, "MESSAGE_NOT_FOUND", "Message not found", "No message found with the specified ID")
@@ -146,18 +201,21 @@ func (h *MessageHandler) DeleteMessage(c *gin.Context) {
 	}
 
 	// Return success response
-	response.Success(c, gin.H{"message": "Message deleted successfully"})
+	response.Success(c, response.NewDeleteResponse(messageID, "Message deleted successfully"))
 }
 
 // GetConversationMessages handles GET /api/v1/conversations/{id}/messages
 // @Summary Get Conversation Messages
-// @Description Retrieve all messages in a specific conversation with pagination
+// @Description Retrieve all messages in a specific conversation with pagination. order=desc returns the newest messages first
+// @Description and sets pagination.next_cursor; pass it back as cursor to load older messages without deep offsets
 // @Tags Conversations
 // @Accept json
 // @Produce json
 // @Param id path string true "Conversation ID" Format(uuid)
-// @Param page query int false "Page number" default(1)
+// @Param page query int false "Page number, ignored when cursor is set" default(1)
 // @Param limit query int false "Items per page" default(10)
+// @Param order query string false "Message order" Enums(asc, desc) default(asc)
+// @Param cursor query string false "Cursor from pagination.next_cursor, only with order=desc"
 // @Success 200 {object} response.PaginatedResponse{data=response.MessageListResponse} "Messages list"
 // @Failure 400 {object} response.Response "Bad request"
 // @Failure 500 {object} response.Response "Internal server error"
@@ -172,23 +230,33 @@ func (h *MessageHandler) GetConversationMessages(c *gin.Context) {
 	}
 
 	// Parse pagination parameters
-	page := 1
-	limit := 10
+	page, limit, ok := parsePagination(c, 10)
+	if !ok {
+		return
+	}
 
-	if pageStr := c.Query("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
+	order, err := repositories.ParseMessageOrder(c.Query("order"))
+	if err != nil {
+		response.BadRequest(c, "INVALID_ORDER", "Invalid order", err.Error())
+		return
 	}
 
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		if order != repositories.MessageOrderDesc {
+			response.BadRequest(c, "INVALID_CURSOR", "Invalid cursor", "cursor is only supported with order=desc")
+			return
+		}
+		cursor, err := repositories.ParseMessageCursor(cursorStr)
+		if err != nil {
+			response.BadRequest(c, "INVALID_CURSOR", "Invalid cursor", "cursor must be a next_cursor value returned by this endpoint")
+			return
 		}
+		h.getMessagesBefore(c, conversationID, cursor, limit)
+		return
 	}
 
 	// Get messages from service
-	messages, total, err := h.messageService.GetMessagesByConversationID(conversationID, page, limit)
+	messages, total, err := h.messageService.GetMessagesByConversationID(conversationID, page, limit, order.String())
 	if err != nil {
 		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve messages")
 		return
@@ -205,6 +273,30 @@ func (h *MessageHandler) GetConversationMessages(c *gin.Context) {
 		Total:      total,
 		TotalPages: totalPages,
 	}
+	// 倒序时返回游标，客户端可从第一页切换到游标分页继续加载更早的消息
+	if order == repositories.MessageOrderDesc && len(messages) > 0 && int64(page*limit) < total {
+		pagination.NextCursor = repositories.NewMessageCursor(messages[len(messages)-1]).Encode()
+	}
 
 	response.SuccessPaginated(c, messageResponse, pagination)
 }
+
+// getMessagesBefore responds with the page of messages older than cursor. 游标分页
+// 没有页码，page 和 total_pages 为 0，是否还有更早的消息由 next_cursor 表示
+func (h *MessageHandler) getMessagesBefore(c *gin.Context, conversationID uuid.UUID, cursor repositories.MessageCursor, limit int) {
+	messages, total, hasMore, err := h.messageService.GetMessagesBefore(conversationID, cursor, limit)
+	if err != nil {
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve messages")
+		return
+	}
+
+	pagination := &response.PaginationInfo{
+		Limit: limit,
+		Total: total,
+	}
+	if hasMore {
+		pagination.NextCursor = repositories.NewMessageCursor(messages[len(messages)-1]).Encode()
+	}
+
+	response.SuccessPaginated(c, response.NewMessageListResponse(messages), pagination)
+}
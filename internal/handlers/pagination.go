@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"chat-assistant-backend/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPageLimit 列表接口每页最多返回的条数
+const maxPageLimit = 100
+
+// parsePagination 解析列表接口的 page 和 limit 查询参数，缺省时分别使用 1 和 defaultLimit。
+// 参数存在但不是整数或超出范围时返回 400，ok 为 false，调用方应直接返回
+func parsePagination(c *gin.Context, defaultLimit int) (page, limit int, ok bool) {
+	page = 1
+	if pageStr := c.Query("page"); pageStr != "" {
+		p, err := strconv.Atoi(pageStr)
+		if err != nil || p < 1 || p > math.MaxInt32 {
+			response.BadRequest(c, "INVALID_PAGE", "Invalid page", fmt.Sprintf("page must be an integer between 1 and %d, got %q", math.MaxInt32, pageStr))
+			return 0, 0, false
+		}
+		page = p
+	}
+
+	limit, ok = parseLimit(c, defaultLimit, maxPageLimit)
+	return page, limit, ok
+}
+
+// parseLimit 解析 limit 查询参数，缺省时使用 defaultLimit，超出 1..maxLimit 时返回 400
+func parseLimit(c *gin.Context, defaultLimit, maxLimit int) (int, bool) {
+	limitStr := c.Query("limit")
+	if limitStr == "" {
+		return defaultLimit, true
+	}
+
+	l, err := strconv.Atoi(limitStr)
+	if err != nil || l < 1 || l > maxLimit {
+		response.BadRequest(c, "INVALID_LIMIT", "Invalid limit", fmt.Sprintf("limit must be an integer between 1 and %d, got %q", maxLimit, limitStr))
+		return 0, false
+	}
+	return l, true
+}
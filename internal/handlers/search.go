@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	stderrors "errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/middleware"
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/repositories"
+	"chat-assistant-backend/internal/request"
 	"chat-assistant-backend/internal/response"
 	"chat-assistant-backend/internal/services"
 
@@ -12,6 +19,19 @@ import (
 	"github.com/google/uuid"
 )
 
+// similarDefaultLimit、similarMaxLimit 相似对话默认和最多返回的条数
+const (
+	similarDefaultLimit = 5
+	similarMaxLimit     = 20
+)
+
+// validSearchRoles lists the message roles accepted by the role filter
+var validSearchRoles = map[string]bool{
+	"user":      true,
+	"assistant": true,
+	"system":    true,
+}
+
 // SearchHandler handles search-related HTTP requests
 type SearchHandler struct {
 	searchService services.SearchService
@@ -31,16 +51,27 @@ func NewSearchHandler(searchService services.SearchService) *SearchHandler {
 // @Accept json
 // @Produce json
 // @Param q query string false "Search query (optional, can be empty for filter-only queries)"
-// @Param user_id query string false "User ID" Format(uuid)
-// @Param provider_id query string false "Provider ID (e.g., openai, gemini, claude)"
+// @Param user_id query string false "User ID (required when search.require_user_id is enabled, unless an admin token is sent)" Format(uuid)
+// @Param X-Admin-Token header string false "Admin API token; allows searching across users when search.require_user_id is enabled"
+// @Param provider_id query string false "Provider ID; free-form, imported conversations use chatgpt, claude or gemini" example(chatgpt)
 // @Param tag_id query string false "Tag ID for filtering conversations" Format(uuid)
+// @Param tag_ids query []string false "Tag IDs for filtering conversations (repeated or comma-separated)" collectionFormat(multi)
+// @Param tag_match query string false "How tag_ids are matched: all tags or any tag" Enums(all, any) default(all)
 // @Param start_date query string false "Start date for filtering conversations" Format(date)
 // @Param end_date query string false "End date for filtering conversations" Format(date)
+// @Param role query string false "Only search messages with this role" Enums(user, assistant, system)
+// @Param meta.project query string false "Filter by conversation metadata; any meta.<key> is accepted for keys project, account, summary, tags_raw, import_policy, import_note"
+// @Param filter query string false "Advanced filter expression as JSON, e.g. {\"or\":[{\"provider\":\"openai\"},{\"tag\":\"work\"}]}; combined with other filters via AND"
+// @Param min_score query number false "Minimum relevance score for keyword hits (0 disables, defaults to search.min_score)"
+// @Param sort query string false "Sort by relevance (newest first among equal scores) or by date (creation time, newest first, no relevance re-ranking)" Enums(relevance, date) default(relevance)
+// @Param order query string false "Alias of sort that also accepts activity (last message time, most recently active first); relevance, activity or created (= sort=date). Must agree with sort when both are given" Enums(relevance, activity, created)
+// @Param fields query string false "Comma-separated field groups the keyword is matched against: title, messages, tags (default all)"
 // @Param page query int false "Page number" default(1)
 // @Param limit query int false "Items per page" default(10)
-// @Success 200 {object} response.PaginatedResponse{data=response.SearchResponse} "Search results"
+// @Success 200 {object} response.PaginatedResponse{data=response.SearchResponse,meta=models.SearchMeta} "Search results"
 // @Failure 400 {object} response.Response "Bad request"
 // @Failure 500 {object} response.Response "Internal server error"
+// @Failure 503 {object} response.Response "Filter or metadata search while Elasticsearch is unavailable"
 // @Router /api/v1/search [get]
 func (h *SearchHandler) Search(c *gin.Context) {
 	// Parse search query (optional)
@@ -74,61 +105,352 @@ func (h *SearchHandler) Search(c *gin.Context) {
 		}
 	}
 
+	// Parse multiple tag IDs (optional), e.g. tag_ids=a,b or tag_ids=a&tag_ids=b
+	var tagIDs []uuid.UUID
+	for _, value := range c.QueryArray("tag_ids") {
+		for _, idStr := range strings.Split(value, ",") {
+			idStr = strings.TrimSpace(idStr)
+			if idStr == "" {
+				continue
+			}
+			parsed, err := uuid.Parse(idStr)
+			if err != nil {
+				response.BadRequest(c, "INVALID_UUID", "Invalid tag ID format", fmt.Sprintf("Tag ID %q must be a valid UUID", idStr))
+				return
+			}
+			tagIDs = append(tagIDs, parsed)
+		}
+	}
+
+	tagMatch, err := repositories.ParseTagMatch(c.Query("tag_match"))
+	if err != nil {
+		response.BadRequest(c, "INVALID_TAG_MATCH", "Invalid tag_match", err.Error())
+		return
+	}
+
 	// Parse date range (optional)
-	var startDate, endDate *time.Time
-	if startDateStr := c.Query("start_date"); startDateStr != "" {
-		if parsed, err := time.Parse("2006-01-02", startDateStr); err == nil {
-			startDate = &parsed
-		} else {
-			response.BadRequest(c, "INVALID_DATE", "Invalid start date format", "Start date must be in YYYY-MM-DD format")
+	startDate, err := parseSearchDate(c.Query("start_date"), false)
+	if err != nil {
+		response.BadRequest(c, "INVALID_DATE", "Invalid start date format", "Start date must be in YYYY-MM-DD format")
+		return
+	}
+
+	endDate, err := parseSearchDate(c.Query("end_date"), true)
+	if err != nil {
+		response.BadRequest(c, "INVALID_DATE", "Invalid end date format", "End date must be in YYYY-MM-DD format")
+		return
+	}
+
+	// Parse message role (optional)
+	var role *string
+	if roleStr := c.Query("role"); roleStr != "" {
+		if !validSearchRoles[roleStr] {
+			response.BadRequest(c, "INVALID_ROLE", "Invalid message role", "Role must be one of: user, assistant, system")
 			return
 		}
+		role = &roleStr
 	}
 
-	if endDateStr := c.Query("end_date"); endDateStr != "" {
-		if parsed, err := time.Parse("2006-01-02", endDateStr); err == nil {
-			// 设置结束日期为当天的23:59:59
-			endOfDay := parsed.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-			endDate = &endOfDay
-		} else {
-			response.BadRequest(c, "INVALID_DATE", "Invalid end date format", "End date must be in YYYY-MM-DD format")
+	// Parse metadata filters (optional), e.g. meta.project=backend
+	var metadata map[string]string
+	for param, values := range c.Request.URL.Query() {
+		key, ok := strings.CutPrefix(param, "meta.")
+		if !ok || len(values) == 0 || values[0] == "" {
+			continue
+		}
+		if !models.IsConversationMetadataKey(key) {
+			response.BadRequest(c, "INVALID_METADATA_KEY", "Invalid metadata key", fmt.Sprintf("Metadata key must be one of: %s", strings.Join(models.ConversationMetadataKeys, ", ")))
+			return
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[key] = values[0]
+	}
+
+	// Parse advanced filter expression (optional)
+	var filter *repositories.FilterExpression
+	if filterStr := c.Query("filter"); filterStr != "" {
+		parsed, err := repositories.ParseFilterExpression(filterStr)
+		if err != nil {
+			response.BadRequest(c, "INVALID_FILTER", "Invalid filter expression", err.Error())
+			return
+		}
+		filter = parsed
+	}
+
+	// Parse minimum relevance score (optional)
+	var minScore *float64
+	if minScoreStr := c.Query("min_score"); minScoreStr != "" {
+		parsed, err := strconv.ParseFloat(minScoreStr, 64)
+		if err != nil || parsed < 0 {
+			response.BadRequest(c, "INVALID_MIN_SCORE", "Invalid min_score", "min_score must be a non-negative number")
 			return
 		}
+		minScore = &parsed
+	}
+
+	order, err := repositories.ResolveSearchOrder(c.Query("sort"), c.Query("order"))
+	if err != nil {
+		response.BadRequest(c, "INVALID_ORDER", "Invalid order", err.Error())
+		return
+	}
+
+	// Parse keyword fields (optional), e.g. fields=title,tags
+	var fields []string
+	if fieldsStr := c.Query("fields"); fieldsStr != "" {
+		fields = strings.Split(fieldsStr, ",")
+	}
+	fields, ok := parseSearchFields(fields)
+	if !ok {
+		response.BadRequest(c, "INVALID_FIELDS", "Invalid fields", searchFieldsDetails)
+		return
 	}
 
 	// Parse pagination parameters
-	page := 1
-	limit := 10
+	page, limit, ok := parsePagination(c, 10)
+	if !ok {
+		return
+	}
+
+	h.search(c, repositories.SearchParams{
+		Query:      query,
+		UserID:     userID,
+		ProviderID: providerID,
+		TagID:      tagID,
+		TagIDs:     tagIDs,
+		TagMatch:   tagMatch,
+		StartDate:  startDate,
+		EndDate:    endDate,
+		Role:       role,
+		MinScore:   minScore,
+		Metadata:   metadata,
+		Filter:     filter,
+		Order:      order,
+		Fields:     fields,
+		Page:       page,
+		Limit:      limit,
+	})
+}
+
+// SearchPost handles POST /api/v1/search
+// @Summary Search Conversations (JSON body)
+// @Description Search conversations with all options in a JSON body; supports arrays such as tag_ids and the advanced filter expression as a JSON object
+// @Tags Search
+// @Accept json
+// @Produce json
+// @Param request body request.SearchRequest true "Search options"
+// @Param X-Admin-Token header string false "Admin API token; allows searching across users when search.require_user_id is enabled"
+// @Success 200 {object} response.PaginatedResponse{data=response.SearchResponse,meta=models.SearchMeta} "Search results"
+// @Failure 400 {object} response.Response "user_id is required"
+// @Failure 422 {object} response.Response "Invalid search options"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Failure 503 {object} response.Response "Filter or metadata search while Elasticsearch is unavailable"
+// @Router /api/v1/search [post]
+func (h *SearchHandler) SearchPost(c *gin.Context) {
+	var req request.SearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.UnprocessableEntity(c, "VALIDATION_ERROR", "Invalid search request", err.Error())
+		return
+	}
+
+	params := repositories.SearchParams{
+		Query:    req.Query,
+		UserID:   req.UserID,
+		TagID:    req.TagID,
+		TagIDs:   req.TagIDs,
+		TagMatch: req.TagMatch,
+		MinScore: req.MinScore,
+		Page:     req.Page,
+		Limit:    req.Limit,
+	}
+
+	if req.ProviderID != "" {
+		params.ProviderID = &req.ProviderID
+	}
+
+	if req.Role != "" {
+		params.Role = &req.Role
+	}
+
+	var err error
+	if params.Order, err = repositories.ResolveSearchOrder(req.Sort.String(), req.Order.String()); err != nil {
+		response.UnprocessableEntity(c, "INVALID_ORDER", "Invalid sort", err.Error())
+		return
+	}
+
+	if params.StartDate, err = parseSearchDate(req.StartDate, false); err != nil {
+		response.UnprocessableEntity(c, "INVALID_DATE", "Invalid start date format", "Start date must be in YYYY-MM-DD format")
+		return
+	}
+
+	if params.EndDate, err = parseSearchDate(req.EndDate, true); err != nil {
+		response.UnprocessableEntity(c, "INVALID_DATE", "Invalid end date format", "End date must be in YYYY-MM-DD format")
+		return
+	}
 
-	if pageStr := c.Query("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
+	for key, value := range req.Metadata {
+		if !models.IsConversationMetadataKey(key) {
+			response.UnprocessableEntity(c, "INVALID_METADATA_KEY", "Invalid metadata key", fmt.Sprintf("Metadata key must be one of: %s", strings.Join(models.ConversationMetadataKeys, ", ")))
+			return
+		}
+		if value == "" {
+			continue
 		}
+		if params.Metadata == nil {
+			params.Metadata = make(map[string]string)
+		}
+		params.Metadata[key] = value
+	}
+
+	fields, ok := parseSearchFields(req.Fields)
+	if !ok {
+		response.UnprocessableEntity(c, "INVALID_FIELDS", "Invalid fields", searchFieldsDetails)
+		return
 	}
+	params.Fields = fields
 
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
+	if len(req.Filter) > 0 && string(req.Filter) != "null" {
+		filter, err := repositories.ParseFilterExpression(string(req.Filter))
+		if err != nil {
+			response.UnprocessableEntity(c, "INVALID_FILTER", "Invalid filter expression", err.Error())
+			return
 		}
+		params.Filter = filter
+	}
+
+	// 与 GET 保持一致的默认值
+	if params.TagMatch == "" {
+		params.TagMatch = repositories.TagMatchAll
+	}
+	if params.Order == "" {
+		params.Order = repositories.OrderRelevance
+	}
+	if params.Page == 0 {
+		params.Page = 1
+	}
+	if params.Limit == 0 {
+		params.Limit = 10
 	}
 
+	h.search(c, params)
+}
+
+// FindSimilar handles GET /api/v1/search/similar/{conversationId}
+// @Summary Find Similar Conversations
+// @Description Find conversations of the same user whose title and messages are similar to the given conversation; returns an empty list when the conversation has too little text
+// @Tags Search
+// @Accept json
+// @Produce json
+// @Param conversationId path string true "Conversation ID" Format(uuid)
+// @Param limit query int false "Maximum number of similar conversations" default(5) maximum(20)
+// @Success 200 {object} response.Response{data=response.SimilarConversationsResponse} "Similar conversations"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 404 {object} response.Response "Conversation not found"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Failure 503 {object} response.Response "Elasticsearch is unavailable"
+// @Router /api/v1/search/similar/{conversationId} [get]
+func (h *SearchHandler) FindSimilar(c *gin.Context) {
+	conversationID, err := uuid.Parse(c.Param("conversationId"))
+	if err != nil {
+		response.BadRequest(c, "INVALID_UUID", "Invalid conversation ID format", "Conversation ID must be a valid UUID")
+		return
+	}
+
+	limit, ok := parseLimit(c, similarDefaultLimit, similarMaxLimit)
+	if !ok {
+		return
+	}
+
+	similarResponse, err := h.searchService.FindSimilarConversations(conversationID, limit)
+	if err != nil {
+		if err == errors.ErrConversationNotFound {
+			response.NotFound(c, "CONVERSATION_NOT_FOUND", "Conversation not found", "No conversation found with the specified ID")
+			return
+		}
+		if stderrors.Is(err, repositories.ErrSearchFallbackUnsupported) {
+			response.ServiceUnavailable(c, "SEARCH_UNAVAILABLE", "Search is temporarily limited", err.Error())
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", fmt.Sprintf("Failed to find similar conversations: %v", err))
+		return
+	}
+
+	response.Success(c, similarResponse)
+}
+
+// search performs the search and writes the paginated response
+func (h *SearchHandler) search(c *gin.Context, params repositories.SearchParams) {
+	params.AllUsers = middleware.IsAdmin(c)
+
 	// Perform search with matched messages
-	searchResponse, total, err := h.searchService.SearchWithMatchedMessages(query, userID, providerID, tagID, startDate, endDate, page, limit)
+	searchResponse, total, meta, err := h.searchService.SearchWithMatchedMessages(c.Request.Context(), params)
 	if err != nil {
+		if err == errors.ErrUserIDRequired {
+			response.BadRequest(c, errors.ErrCodeUserIDRequired, "User ID is required", "Searches must be scoped to a user_id; only admin callers may search across users")
+			return
+		}
+		if stderrors.Is(err, repositories.ErrSearchFallbackUnsupported) {
+			response.ServiceUnavailable(c, "SEARCH_UNAVAILABLE", "Search is temporarily limited", err.Error())
+			return
+		}
+
 		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", fmt.Sprintf("Failed to perform search: %v", err))
 		return
 	}
 
 	// Calculate total pages
-	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	totalPages := int((total + int64(params.Limit) - 1) / int64(params.Limit))
 
 	// Return success response
 	pagination := &response.PaginationInfo{
-		Page:       page,
-		Limit:      limit,
+		Page:       params.Page,
+		Limit:      params.Limit,
 		Total:      total,
 		TotalPages: totalPages,
 	}
 
-	response.SuccessPaginated(c, searchResponse, pagination)
+	response.SuccessPaginatedWithMeta(c, searchResponse, pagination, meta)
+}
+
+// searchFieldsDetails 字段组无效时的错误说明
+var searchFieldsDetails = fmt.Sprintf("fields must be a comma-separated list of: %s", strings.Join(repositories.SearchFieldGroups, ", "))
+
+// parseSearchFields trims and validates keyword field groups, dropping empty entries
+// and duplicates. ok is false when a name is not one of repositories.SearchFieldGroups
+func parseSearchFields(names []string) ([]string, bool) {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if !repositories.IsSearchFieldGroup(name) {
+			return nil, false
+		}
+		seen[name] = true
+		fields = append(fields, name)
+	}
+	return fields, true
+}
+
+// parseSearchDate parses a YYYY-MM-DD date; endOfDay moves it to 23:59:59 of that day.
+// An empty value returns nil
+func parseSearchDate(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+
+	if endOfDay {
+		// 设置结束日期为当天的23:59:59
+		parsed = parsed.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+	}
+	return &parsed, nil
 }
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"fmt"
+
 	"chat-assistant-backend/internal/errors"
 	"chat-assistant-backend/internal/request"
 	"chat-assistant-backend/internal/response"
@@ -106,6 +108,10 @@ func (h *TagHandler) CreateTag(c *gin.Context) {
 			response.Conflict(c, "TAG_NAME_EXISTS", "Tag name already exists", "A tag with this name already exists")
 			return
 		}
+		if err == errors.ErrTagNameEmpty {
+			response.BadRequest(c, "TAG_NAME_EMPTY", "Invalid tag name", "Tag name must contain non-whitespace characters")
+			return
+		}
 
 		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to create tag")
 		return
@@ -116,6 +122,42 @@ func (h *TagHandler) CreateTag(c *gin.Context) {
 	response.Success(c, tagResponse)
 }
 
+// BatchCreateTags handles POST /api/v1/tags/batch
+// @Summary Batch Create Tags
+// @Description Create several tags at once; names are normalized and deduplicated, existing tags are returned with created=false
+// @Tags Tags
+// @Accept json
+// @Produce json
+// @Param tags body request.BatchCreateTagsRequest true "Tag names (at most 100)"
+// @Success 200 {object} response.Response{data=response.BatchTagListResponse} "Created and existing tags"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/tags/batch [post]
+func (h *TagHandler) BatchCreateTags(c *gin.Context) {
+	var req request.BatchCreateTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "INVALID_REQUEST", "Invalid request data", fmt.Sprintf("names must contain 1 to %d tag names: %v", request.MaxTagBatchSize, err))
+		return
+	}
+
+	results, err := h.tagService.BatchCreateTags(req.Names)
+	if err != nil {
+		if err == errors.ErrTagNameEmpty {
+			response.BadRequest(c, "TAG_NAME_EMPTY", "Invalid tag names", "At least one tag name must contain non-whitespace characters")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to create tags")
+		return
+	}
+
+	tags := make([]response.BatchTagResponse, len(results))
+	for i, result := range results {
+		tags[i] = response.BatchTagResponse{TagResponse: *response.NewTagResponse(result.Tag), Created: result.Created}
+	}
+	response.Success(c, &response.BatchTagListResponse{Tags: tags})
+}
+
 // UpdateTag handles PUT /api/v1/tags/{id}
 // @Summary Update Tag
 // @Description Update an existing tag
@@ -146,7 +188,7 @@ func (h *TagHandler) UpdateTag(c *gin.Context) {
 	}
 
 	// Update tag
-	tag, err := h.tagService.UpdateTag(tagID, req.Name)
+	tag, err := h.tagService.UpdateTag(c.Request.Context(), tagID, req.Name)
 	if err != nil {
 		if err == errors.ErrTagNotFound {
 			response.NotFound(c, "TAG_NOT_FOUND", "Tag not found", "No tag found with the specified ID")
@@ -156,6 +198,10 @@ func (h *TagHandler) UpdateTag(c *gin.Context) {
 			response.Conflict(c, "TAG_NAME_EXISTS", "Tag name already exists", "A tag with this name already exists")
 			return
 		}
+		if err == errors.ErrTagNameEmpty {
+			response.BadRequest(c, "TAG_NAME_EMPTY", "Invalid tag name", "Tag name must contain non-whitespace characters")
+			return
+		}
 
 		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to update tag")
 		return
@@ -173,7 +219,7 @@ func (h *TagHandler) UpdateTag(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path string true "Tag ID" Format(uuid)
-// @Success 200 {object} response.Response "Tag deleted successfully"
+// @Success 200 {object} response.Response{data=response.DeleteResponse} "Tag deleted successfully"
 // @Failure 400 {object} response.Response "Bad request"
 // @Failure 404 {object} response.Response "Tag not found"
 // @Failure 500 {object} response.Response "Internal server error"
@@ -188,7 +234,7 @@ func (h *TagHandler) DeleteTag(c *gin.Context) {
 	}
 
 	// Delete tag
-	err = h.tagService.DeleteTag(tagID)
+	err = h.tagService.DeleteTag(c.Request.Context(), tagID)
 	if err != nil {
 		if err == errors.ErrTagNotFound {
 			response.NotFound(c, "TAG_NOT_FOUND", "Tag not found", "No tag found with the specified ID")
@@ -200,5 +246,5 @@ func (h *TagHandler) DeleteTag(c *gin.Context) {
 	}
 
 	// Return success response
-	response.Success(c, gin.H{"message": "Tag deleted successfully"})
+	response.Success(c, response.NewDeleteResponse(tagID, "Tag deleted successfully"))
 }
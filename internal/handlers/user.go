@@ -58,3 +58,71 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	userResponse := response.NewUserResponse(user)
 	response.Success(c, userResponse)
 }
+
+// GetUserProviders handles GET /api/v1/users/{id}/providers
+// @Summary Get User Providers
+// @Description List the distinct providers and models in a user's conversations with conversation counts
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID" Format(uuid)
+// @Success 200 {object} response.Response{data=response.ProviderListResponse} "Providers with models"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/users/{id}/providers [get]
+func (h *UserHandler) GetUserProviders(c *gin.Context) {
+	// Parse user ID from path parameter
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "INVALID_UUID", "Invalid user ID format", "User ID must be a valid UUID")
+		return
+	}
+
+	counts, err := h.userService.GetUserProviders(userID)
+	if err != nil {
+		if err == errors.ErrUserNotFound {
+			response.NotFound(c, "USER_NOT_FOUND", "User not found", "No user found with the specified ID")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve providers")
+		return
+	}
+
+	response.Success(c, response.NewProviderListResponse(counts))
+}
+
+// GetUserTags handles GET /api/v1/users/{id}/tags
+// @Summary Get User Tags
+// @Description List the tags used by a user's conversations with the number of that user's conversations per tag, most used first
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID" Format(uuid)
+// @Success 200 {object} response.Response{data=response.TagListResponse} "Tags with usage counts"
+// @Failure 400 {object} response.Response "Bad request"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 500 {object} response.Response "Internal server error"
+// @Router /api/v1/users/{id}/tags [get]
+func (h *UserHandler) GetUserTags(c *gin.Context) {
+	// Parse user ID from path parameter
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "INVALID_UUID", "Invalid user ID format", "User ID must be a valid UUID")
+		return
+	}
+
+	counts, err := h.userService.GetUserTags(userID)
+	if err != nil {
+		if err == errors.ErrUserNotFound {
+			response.NotFound(c, "USER_NOT_FOUND", "User not found", "No user found with the specified ID")
+			return
+		}
+
+		response.InternalServerError(c, "INTERNAL_ERROR", "Internal server error", "Failed to retrieve tags")
+		return
+	}
+
+	response.Success(c, response.NewTagCountListResponse(counts))
+}
@@ -11,4 +11,5 @@ var HandlerSet = wire.NewSet(
 	NewMessageHandler,
 	NewTagHandler,
 	NewSearchHandler,
+	NewAdminHandler,
 )
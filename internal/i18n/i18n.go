@@ -0,0 +1,92 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContextKey is the gin context key holding the negotiated language
+const ContextKey = "language"
+
+// Supported language codes
+const (
+	LanguageEnglish = "en"
+	LanguageChinese = "zh"
+)
+
+// Translate returns the localized message for an error code in the given language
+func Translate(lang, code string) (string, bool) {
+	bundle, ok := bundles[lang]
+	if !ok {
+		return "", false
+	}
+	message, ok := bundle[code]
+	return message, ok
+}
+
+// Negotiate resolves an Accept-Language header to one of the supported languages,
+// falling back to defaultLang when nothing matches
+func Negotiate(acceptLanguage string, supported []string, defaultLang string) string {
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if tag == "*" {
+			return defaultLang
+		}
+		for _, lang := range supported {
+			// 同时匹配完整标签（zh-cn）和主语言（zh）
+			if strings.EqualFold(tag, lang) || strings.EqualFold(primarySubtag(tag), lang) {
+				return lang
+			}
+		}
+	}
+	return defaultLang
+}
+
+// parseAcceptLanguage returns language tags ordered by descending quality
+func parseAcceptLanguage(header string) []string {
+	type weightedTag struct {
+		tag     string
+		quality float64
+	}
+
+	var tags []weightedTag
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+				quality = q
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		tags = append(tags, weightedTag{tag: tag, quality: quality})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].quality > tags[j].quality
+	})
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
+
+func primarySubtag(tag string) string {
+	if idx := strings.IndexAny(tag, "-_"); idx > 0 {
+		return tag[:idx]
+	}
+	return tag
+}
@@ -0,0 +1,41 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// bundles maps language -> error code -> localized message, loaded from locales/<lang>.json.
+// Error messages are written in English, so there is no English bundle
+var bundles = mustLoadBundles()
+
+// mustLoadBundles 读取内嵌的语言文件，文件在编译时嵌入，格式错误时直接 panic
+func mustLoadBundles() map[string]map[string]string {
+	entries, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("i18n: failed to read locales: %v", err))
+	}
+
+	bundles := make(map[string]map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := localeFiles.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("i18n: failed to read %s: %v", entry.Name(), err))
+		}
+
+		var locale struct {
+			Errors map[string]string `json:"errors"`
+		}
+		if err := json.Unmarshal(data, &locale); err != nil {
+			panic(fmt.Sprintf("i18n: invalid locale file %s: %v", entry.Name(), err))
+		}
+		bundles[strings.TrimSuffix(entry.Name(), ".json")] = locale.Errors
+	}
+	return bundles
+}
@@ -0,0 +1,156 @@
+package importer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chat-assistant-backend/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// FileImportResult 单个文件的导入结果
+type FileImportResult struct {
+	File   string        `json:"file"`
+	Result *ImportResult `json:"result,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// BatchImportResult 多文件导入的汇总结果
+type BatchImportResult struct {
+	Platform          string             `json:"platform"`
+	FileCount         int                `json:"file_count"`
+	FailedFiles       int                `json:"failed_files"`
+	ConversationCount int                `json:"conversation_count"`
+	MessageCount      int                `json:"message_count"`
+	SuccessCount      int                `json:"success_count"`
+	ErrorCount        int                `json:"error_count"`
+	IndexedCount      int                `json:"indexed_count"`
+	Files             []FileImportResult `json:"files"`
+	Skipped           []string           `json:"skipped,omitempty"`
+	Duration          string             `json:"duration"`
+}
+
+// CollectImportFiles 收集待导入的 JSON 文件和 zip 导出包，source 可以是目录（递归遍历）或 glob 模式
+// 返回按路径排序的待导入文件列表和被跳过的其他文件
+func CollectImportFiles(source string) ([]string, []string, error) {
+	var candidates []string
+
+	info, err := os.Stat(source)
+	if err == nil && info.IsDir() {
+		err = filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				candidates = append(candidates, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to walk directory %s: %w", source, err)
+		}
+	} else {
+		matches, err := filepath.Glob(source)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid glob pattern %s: %w", source, err)
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() {
+				candidates = append(candidates, match)
+			}
+		}
+	}
+
+	var files, skipped []string
+	for _, path := range candidates {
+		if ext := filepath.Ext(path); strings.EqualFold(ext, ".json") || strings.EqualFold(ext, ".zip") {
+			files = append(files, path)
+		} else {
+			skipped = append(skipped, path)
+		}
+	}
+
+	sort.Strings(files)
+	sort.Strings(skipped)
+	return files, skipped, nil
+}
+
+// ImportFiles 导入多个文件，每个文件使用独立的事务
+// parallel <= 1 时按顺序处理，否则使用 parallel 个 worker 并发处理；
+// 配置了 max_conversations 时同一用户的上限检查和写入按顺序进行，见 lockProviderLimit
+func (i *Importer) ImportFiles(files []string, platform, userIDStr string, dryRun bool, parallel int) *BatchImportResult {
+	startTime := time.Now()
+	log := logger.GetLogger()
+
+	results := make([]FileImportResult, len(files))
+	importFile := func(idx int) {
+		result, err := i.Import(files[idx], platform, userIDStr, dryRun)
+		results[idx] = FileImportResult{File: files[idx], Result: result}
+		if err != nil {
+			results[idx].Error = err.Error()
+		}
+	}
+
+	if parallel <= 1 {
+		for idx := range files {
+			importFile(idx)
+		}
+	} else {
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < parallel; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for idx := range jobs {
+					importFile(idx)
+				}
+			}()
+		}
+		for idx := range files {
+			jobs <- idx
+		}
+		close(jobs)
+		wg.Wait()
+	}
+
+	// 汇总结果
+	batch := &BatchImportResult{
+		Platform:  platform,
+		FileCount: len(files),
+		Files:     results,
+	}
+	for _, fileResult := range results {
+		if fileResult.Error != "" {
+			batch.FailedFiles++
+		}
+		if fileResult.Result == nil {
+			continue
+		}
+		batch.ConversationCount += fileResult.Result.ConversationCount
+		batch.MessageCount += fileResult.Result.MessageCount
+		batch.ErrorCount += fileResult.Result.ErrorCount
+		batch.IndexedCount += fileResult.Result.IndexedCount
+		if fileResult.Error == "" {
+			batch.SuccessCount += fileResult.Result.SuccessCount
+		}
+	}
+	batch.Duration = time.Since(startTime).String()
+
+	log.Info("Batch import completed",
+		zap.String("platform", platform),
+		zap.Int("files", batch.FileCount),
+		zap.Int("failed_files", batch.FailedFiles),
+		zap.Int("conversations", batch.ConversationCount),
+		zap.String("duration", batch.Duration),
+	)
+
+	return batch
+}
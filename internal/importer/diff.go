@@ -0,0 +1,183 @@
+package importer
+
+import (
+	"fmt"
+	"strconv"
+
+	"chat-assistant-backend/internal/importer/parsers"
+	"chat-assistant-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Conversation statuses in ConversationDiff.Status
+const (
+	DiffStatusNew       = "new"       // 尚未导入过，导入时创建
+	DiffStatusChanged   = "changed"   // 已导入，标题或消息有变化
+	DiffStatusUnchanged = "unchanged" // 已导入，内容一致
+)
+
+// ImportDiff 重新导入前，导出文件与已存储数据的差异
+type ImportDiff struct {
+	Platform       string             `json:"platform"`
+	Conversations  []ConversationDiff `json:"conversations"`
+	NewCount       int                `json:"new_count"`
+	ChangedCount   int                `json:"changed_count"`
+	UnchangedCount int                `json:"unchanged_count"`
+}
+
+// ConversationDiff 单个对话的差异，对话和消息按导入时的业务唯一键（source_id）对应
+type ConversationDiff struct {
+	SourceID string `json:"source_id"`
+	Title    string `json:"title"`
+	Status   string `json:"status"`
+	// ConversationID 已存储对话的 ID，新对话为空
+	ConversationID *uuid.UUID `json:"conversation_id,omitempty"`
+	TitleChanged   bool       `json:"title_changed,omitempty"`
+
+	AddedMessages   []MessageDiff `json:"added_messages,omitempty"`
+	ChangedMessages []MessageDiff `json:"changed_messages,omitempty"`
+	// RemovedMessages 已存储但导出中不再包含的消息；导入只新增和更新，不会删除这些消息
+	RemovedMessages []MessageDiff `json:"removed_messages,omitempty"`
+}
+
+// MessageDiff 有变化的消息，Preview 为导出中（被删除的消息为已存储）原始内容的开头
+type MessageDiff struct {
+	SourceID string `json:"source_id"`
+	Role     string `json:"role"`
+	Preview  string `json:"preview"`
+}
+
+// diffPreviewChars MessageDiff.Preview 的最大字符数
+const diffPreviewChars = 80
+
+// DiffAgainstExisting compares an export (platform detected from its content) with the
+// user's stored conversations and reports, per conversation, the messages a re-import
+// would add or change and the stored messages missing from the export. Nothing is written
+func (i *Importer) DiffAgainstExisting(data []byte, userID uuid.UUID) (*ImportDiff, error) {
+	return i.diff(data, "", userID)
+}
+
+// diff platform 为空时根据内容识别平台
+func (i *Importer) diff(data []byte, platform string, userID uuid.UUID) (*ImportDiff, error) {
+	if i.conversationRepo == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	if platform == "" {
+		detected, err := parsers.Detect(data)
+		if err != nil {
+			return nil, err
+		}
+		platform = detected
+	}
+
+	conversations, messagesWithSource, err := i.transform(data, platform, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceIDs := make([]string, len(conversations))
+	for idx, conv := range conversations {
+		sourceIDs[idx] = conv.SourceID
+	}
+	stored, err := i.conversationRepo.FindBySourceIDs(userID, sourceIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load existing conversations: %w", err)
+	}
+	storedBySource := make(map[string]*models.Conversation, len(stored))
+	for _, conv := range stored {
+		storedBySource[conv.SourceID] = conv
+	}
+
+	// 按转换时生成的对话 ID 分组，保持导出中的顺序
+	messagesByConversation := make(map[uuid.UUID][]*models.Message)
+	for _, msg := range messagesWithSource {
+		messagesByConversation[msg.Message.ConversationID] = append(messagesByConversation[msg.Message.ConversationID], msg.Message)
+	}
+
+	result := &ImportDiff{Platform: platform, Conversations: make([]ConversationDiff, 0, len(conversations))}
+	for _, conv := range conversations {
+		messages := messagesByConversation[conv.ID]
+		convDiff := diffConversation(conv, messages, storedBySource[conv.SourceID])
+		switch convDiff.Status {
+		case DiffStatusNew:
+			result.NewCount++
+		case DiffStatusChanged:
+			result.ChangedCount++
+		default:
+			result.UnchangedCount++
+		}
+		result.Conversations = append(result.Conversations, convDiff)
+	}
+
+	return result, nil
+}
+
+// diffConversation 比较导出中的对话与已存储的对话（为 nil 表示新对话），消息通过内容哈希判断是否变化
+func diffConversation(conv *models.Conversation, messages []*models.Message, stored *models.Conversation) ConversationDiff {
+	convDiff := ConversationDiff{SourceID: conv.SourceID, Title: conv.SourceTitle}
+	if stored == nil {
+		convDiff.Status = DiffStatusNew
+		for _, msg := range messages {
+			convDiff.AddedMessages = append(convDiff.AddedMessages, newMessageDiff(msg))
+		}
+		return convDiff
+	}
+
+	convDiff.ConversationID = &stored.ID
+	convDiff.TitleChanged = stored.SourceTitle != conv.SourceTitle
+
+	storedByKey := make(map[string]*models.Message, len(stored.Messages))
+	for idx := range stored.Messages {
+		storedByKey[messageKey(&stored.Messages[idx], idx)] = &stored.Messages[idx]
+	}
+
+	seen := make(map[string]bool, len(messages))
+	for idx, msg := range messages {
+		key := messageKey(msg, idx)
+		seen[key] = true
+		existing, ok := storedByKey[key]
+		switch {
+		case !ok:
+			convDiff.AddedMessages = append(convDiff.AddedMessages, newMessageDiff(msg))
+		case messageContentHash(existing) != messageContentHash(msg):
+			convDiff.ChangedMessages = append(convDiff.ChangedMessages, newMessageDiff(msg))
+		}
+	}
+	for idx := range stored.Messages {
+		if !seen[messageKey(&stored.Messages[idx], idx)] {
+			convDiff.RemovedMessages = append(convDiff.RemovedMessages, newMessageDiff(&stored.Messages[idx]))
+		}
+	}
+
+	convDiff.Status = DiffStatusUnchanged
+	if convDiff.TitleChanged || len(convDiff.AddedMessages) > 0 || len(convDiff.ChangedMessages) > 0 || len(convDiff.RemovedMessages) > 0 {
+		convDiff.Status = DiffStatusChanged
+	}
+	return convDiff
+}
+
+// messageKey 消息的对应键：与 Loader 一样使用 source_id，没有 source_id 时使用在对话中的位置
+func messageKey(msg *models.Message, index int) string {
+	if msg.SourceID != "" {
+		return msg.SourceID
+	}
+	return "#" + strconv.Itoa(index)
+}
+
+// messageContentHash 消息导入内容（角色和原始内容）的哈希，不包含导入后生成的 Content
+func messageContentHash(msg *models.Message) string {
+	return hashFields(msg.Role, msg.SourceContent)
+}
+
+func newMessageDiff(msg *models.Message) MessageDiff {
+	preview := msg.SourceContent
+	if preview == "" {
+		preview = msg.Content
+	}
+	if runes := []rune(preview); len(runes) > diffPreviewChars {
+		preview = string(runes[:diffPreviewChars]) + "..."
+	}
+	return MessageDiff{SourceID: msg.SourceID, Role: msg.Role, Preview: preview}
+}
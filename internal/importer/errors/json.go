@@ -0,0 +1,126 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// snippetRadius 错误信息中出错位置前后各保留的字节数
+const snippetRadius = 20
+
+// JSONError 导出文件的 JSON 语法或类型错误，带出错位置，
+// 如 expected string, got number in field created_at at line 4021, column 17
+type JSONError struct {
+	Offset  int64  // 出错位置的字节偏移
+	Line    int    // 行号，从 1 开始
+	Column  int    // 列号（按字节计），从 1 开始
+	Message string // 错误描述，不含位置
+	Snippet string // 出错位置所在行的附近内容
+	Err     error  // encoding/json 返回的原始错误
+}
+
+// Error 实现error接口
+func (e *JSONError) Error() string {
+	message := fmt.Sprintf("%s at line %d, column %d", e.Message, e.Line, e.Column)
+	if e.Snippet != "" {
+		message += fmt.Sprintf(", near %q", e.Snippet)
+	}
+	return message
+}
+
+// Unwrap 返回原始错误，可以继续用 errors.As 取得 *json.SyntaxError 等
+func (e *JSONError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeJSON 用 json.Decoder 将 data 解码到 v，语法错误、类型错误和文件被截断时返回 *JSONError。
+// 与 json.Unmarshal 一致，顶层值之后只允许空白
+func DecodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(v); err != nil {
+		return NewJSONError(data, err)
+	}
+
+	offset := decoder.InputOffset()
+	if _, err := decoder.Token(); err != io.EOF {
+		// 跳过顶层值之后的空白，指向多余内容的第一个字节
+		for offset < int64(len(data)) && strings.IndexByte(" \t\r\n", data[offset]) >= 0 {
+			offset++
+		}
+		return newJSONError(data, offset, "invalid character after top-level value", err)
+	}
+	return nil
+}
+
+// NewJSONError 将 encoding/json 解码 data 时返回的错误转换为带位置的 *JSONError，
+// 其他错误原样返回
+func NewJSONError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// Offset 是读取出错字符之后的偏移
+		return newJSONError(data, syntaxErr.Offset-1, syntaxErr.Error(), err)
+	case errors.As(err, &typeErr):
+		message := fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value)
+		if typeErr.Field != "" {
+			message += " in field " + fieldPath(typeErr.Field)
+		}
+		// Offset 是读取该值之后的偏移，指向值的最后一个字节
+		return newJSONError(data, typeErr.Offset-1, message, err)
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		// json.Decoder 对被截断或空的输入返回 EOF 错误而不是 SyntaxError
+		return newJSONError(data, int64(len(data)), "unexpected end of JSON input", err)
+	default:
+		return err
+	}
+}
+
+// fieldPath 将 UnmarshalTypeError 的字段路径（如 0.chat_messages.2.sender）转换为
+// 与结构检查一致的写法（如 [0].chat_messages[2].sender）
+func fieldPath(field string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+func newJSONError(data []byte, offset int64, message string, err error) *JSONError {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	lineStart := bytes.LastIndexByte(data[:offset], '\n') + 1
+	lineEnd := len(data)
+	if i := bytes.IndexByte(data[offset:], '\n'); i >= 0 {
+		lineEnd = int(offset) + i
+	}
+
+	from := max(lineStart, int(offset)-snippetRadius)
+	to := min(lineEnd, int(offset)+snippetRadius)
+
+	return &JSONError{
+		Offset:  offset,
+		Line:    bytes.Count(data[:offset], []byte{'\n'}) + 1,
+		Column:  int(offset) - lineStart + 1,
+		Message: message,
+		Snippet: strings.ToValidUTF8(strings.TrimSpace(string(data[from:to])), ""),
+		Err:     err,
+	}
+}
@@ -0,0 +1,84 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"chat-assistant-backend/internal/importer/types"
+
+	"github.com/google/uuid"
+)
+
+// ImportNamespace 生成确定性 ID（UUIDv5）的命名空间，修改后已导入数据的 ID 不再一致
+var ImportNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("chat-assistant-backend/import"))
+
+// idGenerator 为导入的对话、消息和附件生成 ID。deterministic 为 false 时使用随机 UUID；
+// 为 true 时由用户、平台和原始 ID（没有原始 ID 时为内容哈希）派生 UUIDv5，
+// 同一份导出重复导入得到相同的 ID
+type idGenerator struct {
+	deterministic bool
+}
+
+// conversationID 对话 ID，按用户区分，同一份导出由不同用户导入时不会冲突
+func (g idGenerator) conversationID(userID uuid.UUID, platform string, stdConv *types.StandardConversation) uuid.UUID {
+	if !g.deterministic {
+		return uuid.New()
+	}
+	key := stdConv.ID
+	if key == "" {
+		key = "sha256:" + conversationHash(stdConv)
+	}
+	return g.derive("conversation", userID.String(), platform, key)
+}
+
+// partID 拆分后的对话第 part 部分（从 1 开始，第 1 部分沿用原 ID）的 ID
+func (g idGenerator) partID(conversationID uuid.UUID, part int) uuid.UUID {
+	if !g.deterministic {
+		return uuid.New()
+	}
+	return g.derive("part", conversationID.String(), strconv.Itoa(part))
+}
+
+// messageID 消息 ID，没有原始 ID 时由消息在对话中的位置和内容派生
+func (g idGenerator) messageID(conversationID uuid.UUID, index int, stdMsg *types.StandardMessage) uuid.UUID {
+	if !g.deterministic {
+		return uuid.New()
+	}
+	if stdMsg.ID != "" {
+		return g.derive("message", conversationID.String(), stdMsg.ID)
+	}
+	return g.derive("message", conversationID.String(), strconv.Itoa(index), "sha256:"+hashFields(stdMsg.Role, stdMsg.Content))
+}
+
+// attachmentID 附件 ID，按附件在消息中的位置派生
+func (g idGenerator) attachmentID(messageID uuid.UUID, index int) uuid.UUID {
+	if !g.deterministic {
+		return uuid.New()
+	}
+	return g.derive("attachment", messageID.String(), strconv.Itoa(index))
+}
+
+func (g idGenerator) derive(kind string, parts ...string) uuid.UUID {
+	return uuid.NewSHA1(ImportNamespace, []byte(kind+":"+strings.Join(parts, "/")))
+}
+
+// conversationHash 没有原始 ID 的对话按标题、创建时间和消息内容计算哈希
+func conversationHash(stdConv *types.StandardConversation) string {
+	fields := []string{stdConv.Title, strconv.FormatInt(stdConv.CreatedAt.UnixNano(), 10)}
+	for _, msg := range stdConv.Messages {
+		fields = append(fields, msg.Role, msg.Content)
+	}
+	return hashFields(fields...)
+}
+
+// hashFields 对字段计算 SHA-256，每个字段带长度前缀，避免拼接产生歧义
+func hashFields(fields ...string) string {
+	h := sha256.New()
+	for _, field := range fields {
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
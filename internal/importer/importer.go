@@ -2,27 +2,44 @@ package importer
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"sync"
 	"time"
 
 	"chat-assistant-backend/internal/config"
 	"chat-assistant-backend/internal/importer/parsers"
+	"chat-assistant-backend/internal/infra/database"
 	"chat-assistant-backend/internal/logger"
+	"chat-assistant-backend/internal/models"
 	"chat-assistant-backend/internal/repositories"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrProviderDisabled 平台在 import.providers 中被禁用
+	ErrProviderDisabled = errors.New("import is disabled for this provider")
+	// ErrProviderLimitExceeded 导入后用户在该平台下的对话数超过 max_conversations
+	ErrProviderLimitExceeded = errors.New("provider conversation limit exceeded")
+)
+
 // Importer 核心导入器
 type Importer struct {
 	config      *config.Config
 	loader      *Loader
 	validator   *Validator
 	transformer *Transformer
+
+	// 读取已有对话，连接数据库时默认设置
+	conversationRepo repositories.ConversationRepository
+	// 可选：设置后导入完成会将对话索引到 Elasticsearch
+	indexer repositories.ElasticsearchIndexer
+
+	// 每个用户一把 *sync.Mutex，见 lockProviderLimit
+	limitLocks sync.Map
 }
 
 // ImportResult 导入结果
@@ -32,15 +49,16 @@ type ImportResult struct {
 	MessageCount      int      `json:"message_count"`
 	SuccessCount      int      `json:"success_count"`
 	ErrorCount        int      `json:"error_count"`
+	IndexedCount      int      `json:"indexed_count"`
+	TruncatedCount    int      `json:"truncated_count,omitempty"` // 因超过 max_conversations 未导入的对话数
 	Errors            []string `json:"errors,omitempty"`
 	Duration          string   `json:"duration"`
 }
 
 // NewImporter 创建导入器
 func NewImporter(cfg *config.Config) *Importer {
-	// 初始化数据库连接
-	dsn := cfg.Database.GetDSN()
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// 初始化数据库连接，Postgres 未就绪时按 database.connect_retries 重试
+	db, err := database.Connect(&cfg.Database)
 	if err != nil {
 		// 如果数据库连接失败，返回一个没有数据库连接的导入器
 		// 这样在dry-run模式下仍然可以工作
@@ -48,7 +66,7 @@ func NewImporter(cfg *config.Config) *Importer {
 			config:      cfg,
 			loader:      NewLoader(cfg),
 			validator:   NewValidator(),
-			transformer: NewTransformer(),
+			transformer: newTransformer(cfg),
 		}
 	}
 
@@ -61,14 +79,67 @@ func NewImporter(cfg *config.Config) *Importer {
 	loader.SetDependencies(db, conversationRepo, messageRepo)
 
 	return &Importer{
-		config:      cfg,
-		loader:      loader,
-		validator:   NewValidator(),
-		transformer: NewTransformer(),
+		config:           cfg,
+		loader:           loader,
+		validator:        NewValidator(),
+		transformer:      newTransformer(cfg),
+		conversationRepo: conversationRepo,
 	}
 }
 
-// Import 执行导入
+// newTransformer 按导入配置创建转换器
+func newTransformer(cfg *config.Config) *Transformer {
+	return NewTransformerWithOptions(TransformerOptions{
+		MissingTimestampStep:       cfg.Import.MissingTimestampStep,
+		DefaultModels:              cfg.Import.DefaultModels(),
+		MaxMessagesPerConversation: cfg.Import.MaxMessagesPerConversation,
+		OversizedPolicy:            cfg.Import.OversizedPolicy,
+		DeterministicIDs:           cfg.Import.DeterministicIDs,
+	})
+}
+
+// SetIndexer 设置索引依赖，导入完成后会将导入的对话索引到 Elasticsearch
+func (i *Importer) SetIndexer(conversationRepo repositories.ConversationRepository, indexer repositories.ElasticsearchIndexer) {
+	i.conversationRepo = conversationRepo
+	i.indexer = indexer
+}
+
+// SetDatabase 使用已有的数据库连接写入和读取对话，替换 NewImporter 建立的连接
+func (i *Importer) SetDatabase(db *gorm.DB) {
+	conversationRepo := repositories.NewConversationRepository(db)
+	i.loader.SetDependencies(db, conversationRepo, repositories.NewMessageRepository(db))
+	i.conversationRepo = conversationRepo
+}
+
+// SetConversationRepository 设置读取已有对话的仓库（连接数据库时默认使用数据库），用于 DiffAgainstExisting 和索引
+func (i *Importer) SetConversationRepository(conversationRepo repositories.ConversationRepository) {
+	i.conversationRepo = conversationRepo
+}
+
+// IndexConversations 从数据库重新读取对话（包含消息和标签）并批量索引到 Elasticsearch
+func (i *Importer) IndexConversations(conversationIDs []uuid.UUID) (int, error) {
+	if i.conversationRepo == nil || i.indexer == nil {
+		return 0, fmt.Errorf("indexer not initialized")
+	}
+
+	conversations, err := i.conversationRepo.FindByIDs(conversationIDs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch imported conversations: %w", err)
+	}
+
+	docs := make([]*models.ConversationDocument, len(conversations))
+	for idx, conv := range conversations {
+		docs[idx] = conv.ToESDocument()
+	}
+
+	if err := i.indexer.BulkIndexConversations(docs); err != nil {
+		return 0, fmt.Errorf("failed to bulk index conversations: %w", err)
+	}
+
+	return len(docs), nil
+}
+
+// Import 执行导入，platform 为空时根据文件内容自动识别
 func (i *Importer) Import(filePath, platform, userIDStr string, dryRun bool) (*ImportResult, error) {
 	startTime := time.Now()
 	log := logger.GetLogger()
@@ -79,6 +150,24 @@ func (i *Importer) Import(filePath, platform, userIDStr string, dryRun bool) (*I
 		return nil, fmt.Errorf("invalid user ID: %w", err)
 	}
 
+	// 读取文件，zip 导出包会自动解压
+	data, err := i.readInput(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 未指定平台时根据文件内容自动识别
+	if platform == "" {
+		platform, err = parsers.Detect(data)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("Detected platform from file content",
+			zap.String("file", filePath),
+			zap.String("platform", platform),
+		)
+	}
+
 	log.Info("Starting import process",
 		zap.String("file", filePath),
 		zap.String("platform", platform),
@@ -86,33 +175,33 @@ func (i *Importer) Import(filePath, platform, userIDStr string, dryRun bool) (*I
 		zap.Bool("dry_run", dryRun),
 	)
 
-	// 获取解析器
-	parser, err := parsers.GetParser(platform)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get parser: %w", err)
+	// 检查平台是否允许导入
+	if providerCfg, ok := i.config.Import.Providers[platform]; ok && !providerCfg.Enabled {
+		return nil, fmt.Errorf("%w: %s", ErrProviderDisabled, platform)
 	}
 
-	// 读取文件
-	data, err := os.ReadFile(filePath)
+	conversations, messagesWithSource, err := i.transform(data, platform, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, err
 	}
 
-	// 解析数据
-	standardData, err := parser.Parse(data)
+	// 检查平台对话数上限，检查到写入数据库之间锁住该用户的导入
+	unlock := i.lockProviderLimit(platform, userID, dryRun)
+	conversations, messagesWithSource, truncated, err := i.enforceProviderLimit(platform, userID, conversations, messagesWithSource)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse data: %w", err)
+		unlock()
+		return nil, err
 	}
 
-	// 验证数据
-	if err := i.validator.Validate(standardData); err != nil {
-		return nil, fmt.Errorf("validation failed: %w", err)
-	}
-
-	// 转换数据
-	conversations, messagesWithSource, err := i.transformer.Transform(standardData, userID, platform)
-	if err != nil {
-		return nil, fmt.Errorf("transformation failed: %w", err)
+	// 超长消息在数据库中保存完整内容，索引时截断，在元信息中记录
+	for _, msg := range messagesWithSource {
+		if msg.Message.MarkIndexTruncation(i.config.Import.MaxMessageChars) {
+			log.Warn("Message exceeds max_message_chars, indexed content will be truncated",
+				zap.String("conversation_source_id", msg.ConversationSourceID),
+				zap.String("message_source_id", msg.Message.SourceID),
+				zap.Int("max_message_chars", i.config.Import.MaxMessageChars),
+			)
+		}
 	}
 
 	result := &ImportResult{
@@ -121,24 +210,162 @@ func (i *Importer) Import(filePath, platform, userIDStr string, dryRun bool) (*I
 		MessageCount:      len(messagesWithSource),
 		SuccessCount:      len(conversations),
 		ErrorCount:        0,
+		TruncatedCount:    truncated,
 		Duration:          time.Since(startTime).String(),
 	}
 
 	// 如果不是dry run，写入数据库
 	if !dryRun {
-		if err := i.loader.Load(context.Background(), conversations, messagesWithSource); err != nil {
+		err := i.loader.Load(context.Background(), conversations, messagesWithSource)
+		unlock()
+		if err != nil {
 			result.ErrorCount = 1
 			result.Errors = append(result.Errors, err.Error())
 			return result, fmt.Errorf("failed to load data: %w", err)
 		}
+
+		// 索引到 Elasticsearch（数据已写入数据库，索引失败只记录错误，可稍后运行 data-sync 补齐）
+		if i.indexer != nil {
+			conversationIDs := make([]uuid.UUID, len(conversations))
+			for idx, conv := range conversations {
+				conversationIDs[idx] = conv.ID
+			}
+
+			indexed, err := i.IndexConversations(conversationIDs)
+			if err != nil {
+				log.Error("Failed to index imported conversations", zap.Error(err))
+				result.Errors = append(result.Errors, err.Error())
+			}
+			result.IndexedCount = indexed
+		}
 	}
 
 	log.Info("Import completed",
 		zap.String("platform", platform),
 		zap.Int("conversations", len(conversations)),
 		zap.Int("messages", len(messagesWithSource)),
+		zap.Int("indexed", result.IndexedCount),
 		zap.String("duration", result.Duration),
 	)
 
 	return result, nil
 }
+
+// transform 用 platform 的解析器解析、验证导出数据并转换为数据库模型
+func (i *Importer) transform(data []byte, platform string, userID uuid.UUID) ([]*models.Conversation, []*MessageWithConversationSource, error) {
+	// 获取解析器
+	parser, err := parsers.GetParser(platform)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get parser: %w", err)
+	}
+
+	// 解析前检查文件结构，尽早发现选错平台或被截断的文件
+	if err := parser.ValidateRaw(data); err != nil {
+		return nil, nil, fmt.Errorf("invalid export file: %w", err)
+	}
+
+	// 解析数据
+	standardData, err := parser.Parse(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse data: %w", err)
+	}
+
+	// 验证数据
+	if err := i.validator.Validate(standardData); err != nil {
+		return nil, nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	// 转换数据
+	conversations, messagesWithSource, err := i.transformer.Transform(standardData, userID, platform)
+	if err != nil {
+		return nil, nil, fmt.Errorf("transformation failed: %w", err)
+	}
+	return conversations, messagesWithSource, nil
+}
+
+// lockProviderLimit 平台配置了 max_conversations 时锁住该用户在本进程内的导入，返回解锁函数。
+// 上限检查与写入数据库不在同一事务中，并行导入（ImportFiles 的多个 worker）如果都在对方写入前通过检查，
+// 合计会超过上限；持有锁期间同一用户的其他导入等待，不同用户互不影响。干运行不写入，不加锁
+func (i *Importer) lockProviderLimit(platform string, userID uuid.UUID, dryRun bool) func() {
+	providerCfg, ok := i.config.Import.Providers[platform]
+	if dryRun || !ok || providerCfg.MaxConversations <= 0 {
+		return func() {}
+	}
+
+	lock, _ := i.limitLocks.LoadOrStore(userID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// enforceProviderLimit 检查导入后用户在该平台下的对话数是否超过 max_conversations。
+// 重新导入已有对话只会更新，不计入新增；超出时默认拒绝导入，
+// 开启 truncate_over_limit 时只保留不超限的新对话并记录警告，返回被截断的对话数
+func (i *Importer) enforceProviderLimit(platform string, userID uuid.UUID, conversations []*models.Conversation, messagesWithSource []*MessageWithConversationSource) ([]*models.Conversation, []*MessageWithConversationSource, int, error) {
+	providerCfg, ok := i.config.Import.Providers[platform]
+	if !ok || providerCfg.MaxConversations <= 0 {
+		return conversations, messagesWithSource, 0, nil
+	}
+
+	sourceIDs := make([]string, len(conversations))
+	for idx, conv := range conversations {
+		sourceIDs[idx] = conv.SourceID
+	}
+
+	existingTotal, existing, err := i.loader.CountExistingConversations(userID, platform, sourceIDs)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	newCount := 0
+	for _, conv := range conversations {
+		if !existing[conv.SourceID] {
+			newCount++
+		}
+	}
+
+	allowed := providerCfg.MaxConversations - int(existingTotal)
+	if allowed < 0 {
+		allowed = 0
+	}
+	if newCount <= allowed {
+		return conversations, messagesWithSource, 0, nil
+	}
+
+	if !i.config.Import.TruncateOverLimit {
+		return nil, nil, 0, fmt.Errorf("%w: %s allows %d conversations per user, user has %d and import would add %d",
+			ErrProviderLimitExceeded, platform, providerCfg.MaxConversations, existingTotal, newCount)
+	}
+
+	// 截断：保留所有已存在的对话（只会更新），新对话按文件顺序保留到上限为止
+	kept := make([]*models.Conversation, 0, len(conversations))
+	keptSourceIDs := make(map[string]bool, len(conversations))
+	for _, conv := range conversations {
+		if !existing[conv.SourceID] {
+			if allowed == 0 {
+				continue
+			}
+			allowed--
+		}
+		kept = append(kept, conv)
+		keptSourceIDs[conv.SourceID] = true
+	}
+
+	keptMessages := make([]*MessageWithConversationSource, 0, len(messagesWithSource))
+	for _, msg := range messagesWithSource {
+		if keptSourceIDs[msg.ConversationSourceID] {
+			keptMessages = append(keptMessages, msg)
+		}
+	}
+
+	truncated := len(conversations) - len(kept)
+	logger.GetLogger().Warn("Import truncated by provider conversation limit",
+		zap.String("platform", platform),
+		zap.String("user_id", userID.String()),
+		zap.Int("max_conversations", providerCfg.MaxConversations),
+		zap.Int64("existing", existingTotal),
+		zap.Int("truncated", truncated),
+	)
+
+	return kept, keptMessages, truncated, nil
+}
@@ -0,0 +1,115 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrFileTooLarge 导入文件（zip 为解压后的大小）超过 import.max_file_size
+var ErrFileTooLarge = errors.New("import file exceeds max_file_size")
+
+// zipMagic zip 文件的本地文件头签名
+var zipMagic = []byte("PK\x03\x04")
+
+// exportFileName ChatGPT 等平台导出压缩包中的对话文件名
+const exportFileName = "conversations.json"
+
+// readInput 读取导入文件，支持原始 JSON 和 zip 导出包。
+// zip 中优先选择 conversations.json，否则选择最大的 JSON 文件；
+// import.max_file_size 按解压后的大小检查，避免 zip 炸弹
+func (i *Importer) readInput(filePath string) ([]byte, error) {
+	maxSize := i.config.Import.MaxFileSize
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, len(zipMagic))
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if n == len(zipMagic) && bytes.Equal(header, zipMagic) {
+		info, err := file.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		return readZipInput(file, info.Size(), maxSize)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return readLimited(file, maxSize)
+}
+
+// readZipInput 从 zip 导出包中选出对话 JSON 并解压读取
+func readZipInput(r io.ReaderAt, size, maxSize int64) ([]byte, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip archive: %w", err)
+	}
+
+	var selected *zip.File
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || !strings.EqualFold(path.Ext(f.Name), ".json") {
+			continue
+		}
+		// macOS 压缩时生成的 __MACOSX 元数据文件不是导出内容
+		if strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+		if strings.EqualFold(path.Base(f.Name), exportFileName) {
+			selected = f
+			break
+		}
+		if selected == nil || f.UncompressedSize64 > selected.UncompressedSize64 {
+			selected = f
+		}
+	}
+	if selected == nil {
+		return nil, errors.New("no JSON file found in zip archive")
+	}
+
+	// 声明的大小可以伪造，readLimited 还会按实际解压的字节数检查
+	if maxSize > 0 && selected.UncompressedSize64 > uint64(maxSize) {
+		return nil, fmt.Errorf("%w: %s is %d bytes uncompressed, limit is %d", ErrFileTooLarge, selected.Name, selected.UncompressedSize64, maxSize)
+	}
+
+	rc, err := selected.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s in zip archive: %w", selected.Name, err)
+	}
+	defer rc.Close()
+
+	return readLimited(rc, maxSize)
+}
+
+// readLimited 读取全部内容，超过 maxSize 时返回 ErrFileTooLarge，maxSize <= 0 表示不限制
+func readLimited(r io.Reader, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		return data, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: limit is %d bytes", ErrFileTooLarge, maxSize)
+	}
+	return data, nil
+}
@@ -34,6 +34,38 @@ func (l *Loader) SetDependencies(db *gorm.DB, conversationRepo repositories.Conv
 	l.messageRepo = messageRepo
 }
 
+// CountExistingConversations 统计用户在该 provider 下已有的对话数，并返回 sourceIDs 中已存在（重新导入时会被更新）的 source_id。
+// 未连接数据库时视为没有已有对话
+func (l *Loader) CountExistingConversations(userID uuid.UUID, provider string, sourceIDs []string) (int64, map[string]bool, error) {
+	existing := make(map[string]bool)
+	if l.db == nil {
+		return 0, existing, nil
+	}
+
+	var total int64
+	err := l.db.Model(&models.Conversation{}).
+		Where("user_id = ? AND provider = ?", userID, provider).
+		Count(&total).Error
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to count conversations: %w", err)
+	}
+
+	if len(sourceIDs) > 0 {
+		var matched []string
+		err = l.db.Model(&models.Conversation{}).
+			Where("user_id = ? AND source_id IN ?", userID, sourceIDs).
+			Pluck("source_id", &matched).Error
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to query existing conversations: %w", err)
+		}
+		for _, sourceID := range matched {
+			existing[sourceID] = true
+		}
+	}
+
+	return total, existing, nil
+}
+
 // Load 逐个处理数据到数据库，使用upsert确保幂等性
 func (l *Loader) Load(ctx context.Context, conversations []*models.Conversation, messagesWithSource []*MessageWithConversationSource) error {
 	if l.db == nil {
@@ -60,6 +92,10 @@ func (l *Loader) Load(ctx context.Context, conversations []*models.Conversation,
 
 		if err == gorm.ErrRecordNotFound {
 			// 记录不存在，创建新记录
+			if conv.ID, err = l.availableID(tx, &models.Conversation{}, conv.ID); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to check conversation id %s: %w", conv.SourceID, err)
+			}
 			if err := tx.Create(conv).Error; err != nil {
 				tx.Rollback()
 				return fmt.Errorf("failed to create conversation %s: %w", conv.SourceID, err)
@@ -97,7 +133,11 @@ func (l *Loader) Load(ctx context.Context, conversations []*models.Conversation,
 
 		if err == gorm.ErrRecordNotFound {
 			// 记录不存在，创建新记录
-			if err := tx.Create(msg).Error; err != nil {
+			if msg.ID, err = l.availableID(tx, &models.Message{}, msg.ID); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to check message id %s: %w", msg.SourceID, err)
+			}
+			if err := tx.Omit("Attachments").Create(msg).Error; err != nil {
 				tx.Rollback()
 				return fmt.Errorf("failed to create message %s: %w", msg.SourceID, err)
 			}
@@ -109,11 +149,27 @@ func (l *Loader) Load(ctx context.Context, conversations []*models.Conversation,
 			// 记录存在，更新现有记录
 			msg.ID = existingMsg.ID               // 保持原有ID
 			msg.CreatedAt = existingMsg.CreatedAt // 保持原有创建时间
-			if err := tx.Save(msg).Error; err != nil {
+			if err := tx.Omit("Attachments").Save(msg).Error; err != nil {
 				tx.Rollback()
 				return fmt.Errorf("failed to update message %s: %w", msg.SourceID, err)
 			}
 		}
+
+		// 重新导入时以最新数据为准，先清除旧的附件引用
+		if err := l.replaceAttachments(tx, msg); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to save attachments for message %s: %w", msg.SourceID, err)
+		}
+	}
+
+	// 对话记录被整体覆盖，按导入后的消息重新计算最后活跃时间
+	conversationIDs := make([]uuid.UUID, 0, len(conversationIDMap))
+	for _, id := range conversationIDMap {
+		conversationIDs = append(conversationIDs, id)
+	}
+	if err := repositories.UpdateLastMessageAt(tx, conversationIDs); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update last message time: %w", err)
 	}
 
 	// 提交事务
@@ -123,3 +179,41 @@ func (l *Loader) Load(ctx context.Context, conversations []*models.Conversation,
 
 	return nil
 }
+
+// replaceAttachments 用 msg.Attachments 替换消息已有的附件引用
+func (l *Loader) replaceAttachments(tx *gorm.DB, msg *models.Message) error {
+	if err := tx.Unscoped().Where("message_id = ?", msg.ID).Delete(&models.MessageAttachment{}).Error; err != nil {
+		return err
+	}
+
+	if len(msg.Attachments) == 0 {
+		return nil
+	}
+
+	for i := range msg.Attachments {
+		msg.Attachments[i].MessageID = msg.ID
+		id, err := l.availableID(tx, &models.MessageAttachment{}, msg.Attachments[i].ID)
+		if err != nil {
+			return err
+		}
+		msg.Attachments[i].ID = id
+	}
+	return tx.Create(&msg.Attachments).Error
+}
+
+// availableID 开启确定性 ID 时检查派生的主键是否已被占用（包括软删除的记录），
+// 被占用时改用随机 UUID。对话转移给其他用户或被合并后，原对话及其消息、附件仍占用
+// 派生出的 ID，按 (user_id, source_id) 或 (conversation_id, source_id) 查询不到
+func (l *Loader) availableID(tx *gorm.DB, model interface{}, id uuid.UUID) (uuid.UUID, error) {
+	if l.config == nil || !l.config.Import.DeterministicIDs || id == uuid.Nil {
+		return id, nil
+	}
+	var count int64
+	if err := tx.Unscoped().Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return uuid.Nil, err
+	}
+	if count > 0 {
+		return uuid.New(), nil
+	}
+	return id, nil
+}
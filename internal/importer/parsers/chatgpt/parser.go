@@ -1,18 +1,39 @@
 package chatgpt
 
 import (
-	"encoding/json"
 	"fmt"
+	"time"
 
+	importerrors "chat-assistant-backend/internal/importer/errors"
+	"chat-assistant-backend/internal/importer/parsers/schema"
+	"chat-assistant-backend/internal/importer/parsers/timestamp"
 	"chat-assistant-backend/internal/importer/types"
 )
 
+const (
+	// TimestampCreate 消息时间使用 create_time
+	TimestampCreate = "create"
+	// TimestampUpdate 消息时间使用 update_time（例如编辑过的消息）
+	TimestampUpdate = "update"
+)
+
 // Parser ChatGPT解析器
-type Parser struct{}
+type Parser struct {
+	timestampSource string
+}
 
-// NewParser 创建ChatGPT解析器
+// NewParser 创建ChatGPT解析器，消息时间优先使用 create_time
 func NewParser() *Parser {
-	return &Parser{}
+	return NewParserWithTimestampSource(TimestampCreate)
+}
+
+// NewParserWithTimestampSource 创建ChatGPT解析器，source 为 create 或 update，
+// 指定消息时间优先使用的字段，其他值按 create 处理
+func NewParserWithTimestampSource(source string) *Parser {
+	if source != TimestampUpdate {
+		source = TimestampCreate
+	}
+	return &Parser{timestampSource: source}
 }
 
 // Platform 返回平台名称
@@ -20,11 +41,60 @@ func (p *Parser) Platform() string {
 	return "chatgpt"
 }
 
-// Parse 解析ChatGPT导出数据
+// exportSchema ChatGPT 导出文件的结构：顶层对象包含 conversations 数组
+var exportSchema = &schema.Schema{
+	Type: schema.Object,
+	Required: map[string]*schema.Schema{
+		"conversations": {
+			Type: schema.Array,
+			Items: &schema.Schema{
+				Type: schema.Object,
+				Required: map[string]*schema.Schema{
+					"id": {Type: schema.String},
+					"messages": {
+						Type: schema.Array,
+						Items: &schema.Schema{
+							Type: schema.Object,
+							Required: map[string]*schema.Schema{
+								"role":    {Type: schema.String},
+								"content": {Type: schema.String},
+							},
+							Optional: map[string]*schema.Schema{
+								"create_time": {Type: schema.Number},
+								"update_time": {Type: schema.Number},
+								"attachments": {Type: schema.Array},
+							},
+						},
+					},
+				},
+				Optional: map[string]*schema.Schema{
+					"title":       {Type: schema.String},
+					"model":       {Type: schema.String},
+					"create_time": {Type: schema.Number},
+					"update_time": {Type: schema.Number},
+				},
+			},
+		},
+	},
+}
+
+// ValidateRaw 检查ChatGPT导出数据的结构，分享链接格式按 shareSchema 检查
+func (p *Parser) ValidateRaw(data []byte) error {
+	if isShareFormat(data) {
+		return schema.Validate(p.Platform(), data, shareSchema)
+	}
+	return schema.Validate(p.Platform(), data, exportSchema)
+}
+
+// Parse 解析ChatGPT导出数据，顶层包含 messages（而非 mapping）时按分享链接格式解析
 func (p *Parser) Parse(data []byte) (*types.StandardFormat, error) {
+	if isShareFormat(data) {
+		return parseShare(data)
+	}
+
 	// 简略实现 - 实际需要根据ChatGPT的真实导出格式调整
 	var chatgptData ChatGPTExportData
-	if err := json.Unmarshal(data, &chatgptData); err != nil {
+	if err := importerrors.DecodeJSON(data, &chatgptData); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal ChatGPT data: %w", err)
 	}
 
@@ -35,19 +105,33 @@ func (p *Parser) Parse(data []byte) (*types.StandardFormat, error) {
 
 	// 简略转换逻辑 - 实际需要根据真实格式调整
 	for _, conv := range chatgptData.Conversations {
+		// 对话时间缺失其中一个时用另一个补齐
+		createdAt := timestamp.FromUnix(firstNonZero(conv.CreateTime, conv.UpdateTime))
+		updatedAt := timestamp.FromUnix(firstNonZero(conv.UpdateTime, conv.CreateTime))
+
 		stdConv := &types.StandardConversation{
-			ID:       conv.ID,
-			Title:    conv.Title,
-			Provider: "chatgpt",
-			Model:    "gpt-4", // 默认模型，实际应该从数据中获取
-			Messages: make([]*types.StandardMessage, 0),
+			ID:        conv.ID,
+			Title:     conv.Title,
+			CreatedAt: createdAt,
+			UpdatedAt: updatedAt,
+			Provider:  "chatgpt",
+			Model:     conv.Model, // 为空时由转换器使用配置的默认模型
+			Messages:  make([]*types.StandardMessage, 0),
 		}
 
 		// 简略消息转换
 		for _, msg := range conv.Messages {
 			stdMsg := &types.StandardMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
+				Role:      msg.Role,
+				Content:   msg.Content,
+				CreatedAt: p.messageTime(msg),
+			}
+			for _, attachment := range msg.Attachments {
+				stdMsg.Attachments = append(stdMsg.Attachments, &types.StandardAttachment{
+					Type: attachment.MimeType,
+					Name: attachment.Name,
+					Ref:  attachment.ID,
+				})
 			}
 			stdConv.Messages = append(stdConv.Messages, stdMsg)
 		}
@@ -58,6 +142,26 @@ func (p *Parser) Parse(data []byte) (*types.StandardFormat, error) {
 	return standardData, nil
 }
 
+// messageTime 按配置优先使用 create_time 或 update_time，另一个作为备选，
+// 都没有时返回零值，由 Transformer 根据对话创建时间按顺序补齐
+func (p *Parser) messageTime(msg ChatGPTMessage) time.Time {
+	seconds := firstNonZero(msg.CreateTime, msg.UpdateTime)
+	if p.timestampSource == TimestampUpdate {
+		seconds = firstNonZero(msg.UpdateTime, msg.CreateTime)
+	}
+	return timestamp.FromUnix(seconds)
+}
+
+// firstNonZero 返回第一个非零（且非负）的时间戳
+func firstNonZero(values ...float64) float64 {
+	for _, v := range values {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
+
 // ChatGPTExportData ChatGPT导出数据结构（简略版本）
 type ChatGPTExportData struct {
 	Conversations []ChatGPTConversation `json:"conversations"`
@@ -65,13 +169,26 @@ type ChatGPTExportData struct {
 
 // ChatGPTConversation ChatGPT对话结构（简略版本）
 type ChatGPTConversation struct {
-	ID       string           `json:"id"`
-	Title    string           `json:"title"`
-	Messages []ChatGPTMessage `json:"messages"`
+	ID         string           `json:"id"`
+	Title      string           `json:"title"`
+	Model      string           `json:"model"`
+	CreateTime float64          `json:"create_time"`
+	UpdateTime float64          `json:"update_time"`
+	Messages   []ChatGPTMessage `json:"messages"`
 }
 
 // ChatGPTMessage ChatGPT消息结构（简略版本）
 type ChatGPTMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role        string              `json:"role"`
+	Content     string              `json:"content"`
+	CreateTime  float64             `json:"create_time"`
+	UpdateTime  float64             `json:"update_time"`
+	Attachments []ChatGPTAttachment `json:"attachments"`
+}
+
+// ChatGPTAttachment ChatGPT消息附件（上传的文件）
+type ChatGPTAttachment struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
 }
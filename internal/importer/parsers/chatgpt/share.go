@@ -0,0 +1,164 @@
+package chatgpt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	importerrors "chat-assistant-backend/internal/importer/errors"
+	"chat-assistant-backend/internal/importer/parsers/schema"
+	"chat-assistant-backend/internal/importer/parsers/timestamp"
+	"chat-assistant-backend/internal/importer/types"
+)
+
+// ShareData ChatGPT 分享链接导出的单个对话
+type ShareData struct {
+	ID             string         `json:"id"`
+	ConversationID string         `json:"conversation_id"`
+	Title          string         `json:"title"`
+	CreateTime     float64        `json:"create_time"`
+	UpdateTime     float64        `json:"update_time"`
+	Messages       []ShareMessage `json:"messages"`
+}
+
+// ShareMessage 分享格式中的消息
+type ShareMessage struct {
+	ID         string       `json:"id"`
+	Author     ShareAuthor  `json:"author"`
+	Content    ShareContent `json:"content"`
+	CreateTime float64      `json:"create_time"`
+}
+
+// ShareAuthor 消息作者
+type ShareAuthor struct {
+	Role string `json:"role"`
+}
+
+// ShareContent 消息内容，parts 中可能包含图片等非文本对象
+type ShareContent struct {
+	ContentType string        `json:"content_type"`
+	Parts       []interface{} `json:"parts"`
+}
+
+// shareRoles 导入的作者角色，tool 等中间消息不导入
+var shareRoles = map[string]bool{
+	"user":      true,
+	"assistant": true,
+	"system":    true,
+}
+
+// shareSchema 分享链接格式的结构，只检查消息列表
+var shareSchema = &schema.Schema{
+	Type: schema.Object,
+	Required: map[string]*schema.Schema{
+		"messages": {
+			Type: schema.Array,
+			Items: &schema.Schema{
+				Type: schema.Object,
+				Required: map[string]*schema.Schema{
+					"author": {
+						Type:     schema.Object,
+						Required: map[string]*schema.Schema{"role": {Type: schema.String}},
+					},
+					"content": {
+						Type:     schema.Object,
+						Optional: map[string]*schema.Schema{"parts": {Type: schema.Array}},
+					},
+				},
+				Optional: map[string]*schema.Schema{
+					"id":          {Type: schema.String},
+					"create_time": {Type: schema.Number},
+				},
+			},
+		},
+	},
+	Optional: map[string]*schema.Schema{
+		"title":           {Type: schema.String},
+		"conversation_id": {Type: schema.String},
+		"create_time":     {Type: schema.Number},
+		"update_time":     {Type: schema.Number},
+	},
+}
+
+// isShareFormat 判断是否为分享链接格式：顶层对象包含 messages 且不包含 mapping
+func isShareFormat(data []byte) bool {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	_, hasMessages := probe["messages"]
+	_, hasMapping := probe["mapping"]
+	return hasMessages && !hasMapping
+}
+
+// parseShare 解析分享链接格式
+func parseShare(data []byte) (*types.StandardFormat, error) {
+	var share ShareData
+	if err := importerrors.DecodeJSON(data, &share); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ChatGPT share data: %w", err)
+	}
+
+	id := share.ConversationID
+	if id == "" {
+		id = share.ID
+	}
+	if id == "" {
+		// 没有对话 ID 时按内容生成稳定的 ID，重复导入同一分享时可以去重
+		id = "share-" + shareHash(data)
+	}
+
+	// 分享数据中没有模型，Model 留空由转换器使用配置的默认模型
+	stdConv := &types.StandardConversation{
+		ID:        id,
+		Title:     share.Title,
+		CreatedAt: timestamp.FromUnix(share.CreateTime),
+		UpdatedAt: timestamp.FromUnix(share.UpdateTime),
+		Provider:  "chatgpt",
+		Messages:  make([]*types.StandardMessage, 0, len(share.Messages)),
+	}
+
+	for i, msg := range share.Messages {
+		if !shareRoles[msg.Author.Role] {
+			continue
+		}
+
+		content := joinParts(msg.Content.Parts)
+		if content == "" {
+			continue
+		}
+
+		msgID := msg.ID
+		if msgID == "" {
+			msgID = fmt.Sprintf("%s-%d", id, i)
+		}
+
+		stdConv.Messages = append(stdConv.Messages, &types.StandardMessage{
+			ID:        msgID,
+			Role:      msg.Author.Role,
+			Content:   content,
+			CreatedAt: timestamp.FromUnix(msg.CreateTime),
+		})
+	}
+
+	return &types.StandardFormat{
+		Conversations: []*types.StandardConversation{stdConv},
+	}, nil
+}
+
+// joinParts 拼接文本片段，忽略图片等非文本内容
+func joinParts(parts []interface{}) string {
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if text, ok := part.(string); ok && text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func shareHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
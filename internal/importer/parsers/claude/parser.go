@@ -1,11 +1,16 @@
 package claude
 
 import (
-	"encoding/json"
 	"fmt"
 	"time"
 
+	importerrors "chat-assistant-backend/internal/importer/errors"
+	"chat-assistant-backend/internal/importer/parsers/schema"
+	"chat-assistant-backend/internal/importer/parsers/timestamp"
 	"chat-assistant-backend/internal/importer/types"
+	"chat-assistant-backend/internal/logger"
+
+	"go.uber.org/zap"
 )
 
 // Parser Claude解析器
@@ -21,10 +26,49 @@ func (p *Parser) Platform() string {
 	return "claude"
 }
 
+// exportSchema Claude 导出文件的结构：顶层为对话数组
+var exportSchema = &schema.Schema{
+	Type: schema.Array,
+	Items: &schema.Schema{
+		Type: schema.Object,
+		Required: map[string]*schema.Schema{
+			"uuid": {Type: schema.String},
+			"chat_messages": {
+				Type: schema.Array,
+				Items: &schema.Schema{
+					Type: schema.Object,
+					Required: map[string]*schema.Schema{
+						"sender": {Type: schema.String},
+					},
+					Optional: map[string]*schema.Schema{
+						"uuid":        {Type: schema.String},
+						"text":        {Type: schema.String},
+						"content":     {Type: schema.Array},
+						"created_at":  {Type: schema.String},
+						"attachments": {Type: schema.Array},
+						"files":       {Type: schema.Array},
+					},
+				},
+			},
+		},
+		Optional: map[string]*schema.Schema{
+			"name":       {Type: schema.String},
+			"model":      {Type: schema.String},
+			"created_at": {Type: schema.String},
+			"updated_at": {Type: schema.String},
+		},
+	},
+}
+
+// ValidateRaw 检查Claude导出数据的结构
+func (p *Parser) ValidateRaw(data []byte) error {
+	return schema.Validate(p.Platform(), data, exportSchema)
+}
+
 // Parse 解析Claude导出数据
 func (p *Parser) Parse(data []byte) (*types.StandardFormat, error) {
 	var claudeData types.ClaudeExportData
-	if err := json.Unmarshal(data, &claudeData); err != nil {
+	if err := importerrors.DecodeJSON(data, &claudeData); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal Claude data: %w", err)
 	}
 
@@ -36,8 +80,8 @@ func (p *Parser) Parse(data []byte) (*types.StandardFormat, error) {
 	// 转换对话数据
 	for _, conv := range claudeData {
 		// 解析时间
-		createdAt, _ := time.Parse(time.RFC3339, conv.CreatedAt)
-		updatedAt, _ := time.Parse(time.RFC3339, conv.UpdatedAt)
+		createdAt := parseTime(conv.CreatedAt, "created_at", conv.UUID)
+		updatedAt := parseTime(conv.UpdatedAt, "updated_at", conv.UUID)
 
 		stdConv := &types.StandardConversation{
 			ID:        conv.UUID,
@@ -45,19 +89,19 @@ func (p *Parser) Parse(data []byte) (*types.StandardFormat, error) {
 			CreatedAt: createdAt,
 			UpdatedAt: updatedAt,
 			Provider:  "claude",
-			Model:     "claude-3", // 默认模型，实际应该从数据中获取
+			Model:     conv.Model, // 为空时由转换器使用配置的默认模型
 			Messages:  make([]*types.StandardMessage, 0),
 			Metadata: map[string]interface{}{
 				"summary": conv.Summary,
-				"account": conv.Account,
+				"account": conv.Account.UUID,
 			},
 		}
 
 		// 转换消息数据
 		for _, msg := range conv.ChatMessages {
 			// 解析消息时间
-			msgCreatedAt, _ := time.Parse(time.RFC3339, msg.CreatedAt)
-			msgUpdatedAt, _ := time.Parse(time.RFC3339, msg.UpdatedAt)
+			msgCreatedAt := parseTime(msg.CreatedAt, "chat_messages.created_at", msg.UUID)
+			msgUpdatedAt := parseTime(msg.UpdatedAt, "chat_messages.updated_at", msg.UUID)
 
 			// 确定角色
 			role := "user"
@@ -72,10 +116,11 @@ func (p *Parser) Parse(data []byte) (*types.StandardFormat, error) {
 			}
 
 			stdMsg := &types.StandardMessage{
-				ID:        msg.UUID,
-				Role:      role,
-				Content:   content,
-				CreatedAt: msgCreatedAt,
+				ID:          msg.UUID,
+				Role:        role,
+				Content:     content,
+				CreatedAt:   msgCreatedAt,
+				Attachments: attachments(msg),
 				Metadata: map[string]interface{}{
 					"updated_at":  msgUpdatedAt,
 					"attachments": msg.Attachments,
@@ -91,3 +136,52 @@ func (p *Parser) Parse(data []byte) (*types.StandardFormat, error) {
 
 	return standardData, nil
 }
+
+// parseTime 解析导出数据中的时间，无法解析时记录警告并返回零值，由转换器补齐
+func parseTime(value, field, id string) time.Time {
+	t, err := timestamp.ParseFlexibleTime(value)
+	if err != nil {
+		logger.GetLogger().Warn("Unparseable timestamp in Claude export",
+			zap.String("field", field),
+			zap.String("id", id),
+			zap.String("value", value),
+			zap.Error(err),
+		)
+	}
+	return t
+}
+
+// attachments 将消息的 attachments 和 files 转换为标准化附件引用
+func attachments(msg types.ClaudeMessage) []*types.StandardAttachment {
+	var result []*types.StandardAttachment
+
+	for _, attachment := range msg.Attachments {
+		attachmentType := attachment.FileType
+		if attachmentType == "" {
+			attachmentType = "attachment"
+		}
+		// attachments 的内容已内联在导出数据中，没有可引用的地址
+		result = append(result, &types.StandardAttachment{
+			Type: attachmentType,
+			Name: attachment.FileName,
+		})
+	}
+
+	for _, file := range msg.Files {
+		fileType := file.FileKind
+		if fileType == "" {
+			fileType = "file"
+		}
+		ref := file.PreviewURL
+		if ref == "" {
+			ref = file.FileUUID
+		}
+		result = append(result, &types.StandardAttachment{
+			Type: fileType,
+			Name: file.FileName,
+			Ref:  ref,
+		})
+	}
+
+	return result
+}
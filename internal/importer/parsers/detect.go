@@ -0,0 +1,79 @@
+package parsers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	importerrors "chat-assistant-backend/internal/importer/errors"
+)
+
+// platformSpecificFields 结构相同的平台之间用于区分的字段：ChatGPT 简略导出格式与 Gemini
+// 的结构一致（顶层 conversations，消息只有 role 和 content），只有 ChatGPT 带有时间和附件
+var platformSpecificFields = map[string][]string{
+	"chatgpt": {"create_time", "update_time", "attachments"},
+}
+
+// Detect 根据内容推断导出文件所属平台：依次用已注册解析器的 ValidateRaw 检查结构，只符合
+// 一个平台时返回该平台。同时符合多个平台时，只有一个平台的特有字段（platformSpecificFields）
+// 出现在数据中才能确定，否则返回 ambiguous 错误，需要显式指定平台
+func Detect(data []byte) (string, error) {
+	var raw interface{}
+	if err := importerrors.DecodeJSON(data, &raw); err != nil {
+		return "", fmt.Errorf("failed to detect platform: invalid JSON: %w", err)
+	}
+
+	var platforms []string
+	for platform, parser := range registry.parsers {
+		if parser.ValidateRaw(data) == nil {
+			platforms = append(platforms, platform)
+		}
+	}
+	sort.Strings(platforms)
+
+	if len(platforms) > 1 {
+		var specific []string
+		for _, platform := range platforms {
+			for _, field := range platformSpecificFields[platform] {
+				if hasField(raw, field) {
+					specific = append(specific, platform)
+					break
+				}
+			}
+		}
+		if len(specific) == 1 {
+			return specific[0], nil
+		}
+	}
+
+	switch len(platforms) {
+	case 0:
+		return "", fmt.Errorf("failed to detect platform: unrecognized export format, please specify the platform explicitly")
+	case 1:
+		return platforms[0], nil
+	default:
+		return "", fmt.Errorf("failed to detect platform: ambiguous export format (matches %s), please specify the platform explicitly", strings.Join(platforms, ", "))
+	}
+}
+
+// hasField 判断任意层级的对象中是否有名为 name 的字段
+func hasField(value interface{}, name string) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if _, ok := v[name]; ok {
+			return true
+		}
+		for _, field := range v {
+			if hasField(field, name) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if hasField(item, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
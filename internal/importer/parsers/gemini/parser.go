@@ -1,9 +1,10 @@
 package gemini
 
 import (
-	"encoding/json"
 	"fmt"
 
+	importerrors "chat-assistant-backend/internal/importer/errors"
+	"chat-assistant-backend/internal/importer/parsers/schema"
 	"chat-assistant-backend/internal/importer/types"
 )
 
@@ -20,11 +21,46 @@ func (p *Parser) Platform() string {
 	return "gemini"
 }
 
+// exportSchema Gemini 导出文件的结构：顶层对象包含 conversations 数组
+var exportSchema = &schema.Schema{
+	Type: schema.Object,
+	Required: map[string]*schema.Schema{
+		"conversations": {
+			Type: schema.Array,
+			Items: &schema.Schema{
+				Type: schema.Object,
+				Required: map[string]*schema.Schema{
+					"id": {Type: schema.String},
+					"messages": {
+						Type: schema.Array,
+						Items: &schema.Schema{
+							Type: schema.Object,
+							Required: map[string]*schema.Schema{
+								"role":    {Type: schema.String},
+								"content": {Type: schema.String},
+							},
+						},
+					},
+				},
+				Optional: map[string]*schema.Schema{
+					"title": {Type: schema.String},
+					"model": {Type: schema.String},
+				},
+			},
+		},
+	},
+}
+
+// ValidateRaw 检查Gemini导出数据的结构
+func (p *Parser) ValidateRaw(data []byte) error {
+	return schema.Validate(p.Platform(), data, exportSchema)
+}
+
 // Parse 解析Gemini导出数据
 func (p *Parser) Parse(data []byte) (*types.StandardFormat, error) {
 	// 简略实现 - 实际需要根据Gemini的真实导出格式调整
 	var geminiData GeminiExportData
-	if err := json.Unmarshal(data, &geminiData); err != nil {
+	if err := importerrors.DecodeJSON(data, &geminiData); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal Gemini data: %w", err)
 	}
 
@@ -39,7 +75,7 @@ func (p *Parser) Parse(data []byte) (*types.StandardFormat, error) {
 			ID:       conv.ID,
 			Title:    conv.Title,
 			Provider: "gemini",
-			Model:    "gemini-pro", // 默认模型，实际应该从数据中获取
+			Model:    conv.Model, // 为空时由转换器使用配置的默认模型
 			Messages: make([]*types.StandardMessage, 0),
 		}
 
@@ -67,6 +103,7 @@ type GeminiExportData struct {
 type GeminiConversation struct {
 	ID       string          `json:"id"`
 	Title    string          `json:"title"`
+	Model    string          `json:"model"`
 	Messages []GeminiMessage `json:"messages"`
 }
 
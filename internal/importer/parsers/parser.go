@@ -8,6 +8,8 @@ import (
 
 // Parser 解析器接口
 type Parser interface {
+	// ValidateRaw 在完整解析之前检查顶层结构和必填字段，返回具体的出错位置
+	ValidateRaw(data []byte) error
 	Parse(data []byte) (*types.StandardFormat, error)
 	Platform() string
 }
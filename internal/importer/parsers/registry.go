@@ -1,6 +1,7 @@
 package parsers
 
 import (
+	"chat-assistant-backend/internal/config"
 	chatgptParser "chat-assistant-backend/internal/importer/parsers/chatgpt"
 	claudeParser "chat-assistant-backend/internal/importer/parsers/claude"
 	geminiParser "chat-assistant-backend/internal/importer/parsers/gemini"
@@ -13,6 +14,14 @@ func RegisterAll() {
 	Register(geminiParser.NewParser())
 }
 
+// RegisterAllWithConfig 注册所有解析器，并应用导入配置中与解析相关的选项
+func RegisterAllWithConfig(cfg *config.ImportConfig) {
+	RegisterAll()
+	if providerCfg, ok := cfg.Providers["chatgpt"]; ok && providerCfg.TimestampSource != "" {
+		Register(chatgptParser.NewParserWithTimestampSource(providerCfg.TimestampSource))
+	}
+}
+
 // RegisterChatGPT 注册ChatGPT解析器
 func RegisterChatGPT() {
 	Register(chatgptParser.NewParser())
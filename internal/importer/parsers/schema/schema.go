@@ -0,0 +1,151 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+
+	importerrors "chat-assistant-backend/internal/importer/errors"
+)
+
+// Kind JSON 值类型
+type Kind string
+
+const (
+	Object  Kind = "object"
+	Array   Kind = "array"
+	String  Kind = "string"
+	Number  Kind = "number"
+	Boolean Kind = "boolean"
+	Null    Kind = "null"
+	Any     Kind = "any"
+)
+
+// Schema 描述导出文件中一个 JSON 值的结构，只检查类型和必填字段，
+// 用于在完整解析之前发现选错平台或被截断的文件
+type Schema struct {
+	Type Kind
+	// Required 必填字段，值为 null 视为类型错误
+	Required map[string]*Schema
+	// Optional 可选字段，出现且不为 null 时检查类型
+	Optional map[string]*Schema
+	// Items 数组元素的结构
+	Items *Schema
+}
+
+// ValidationError 导出文件结构不符合平台格式
+type ValidationError struct {
+	Platform string
+	Path     string // 出错位置，如 [0].chat_messages[2].sender，顶层为空
+	Message  string // 完整的错误描述，如 expected top-level array for claude, got object
+	Err      error  // JSON 本身不合法时为带行列号的 *importerrors.JSONError
+}
+
+// Error 实现error接口
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Unwrap 返回 JSON 解析错误
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// newError 创建错误，problem 与 detail 之间插入平台和出错位置
+func newError(platform, path, problem, detail string) *ValidationError {
+	location := "for " + platform
+	if path != "" {
+		location += " at " + path
+	}
+	message := problem + " " + location
+	if detail != "" {
+		message += ", " + detail
+	}
+	return &ValidationError{Platform: platform, Path: path, Message: message}
+}
+
+// Validate 检查 data 是否为合法 JSON 且符合 schema，返回第一个不符合的位置
+func Validate(platform string, data []byte, s *Schema) error {
+	var raw interface{}
+	if err := importerrors.DecodeJSON(data, &raw); err != nil {
+		validationErr := newError(platform, "", "invalid JSON", err.Error())
+		validationErr.Err = err
+		return validationErr
+	}
+
+	return validate(platform, "", raw, s)
+}
+
+func validate(platform, path string, value interface{}, s *Schema) error {
+	if s == nil || s.Type == Any {
+		return nil
+	}
+
+	if got := kindOf(value); got != s.Type {
+		expected := "expected " + string(s.Type)
+		if path == "" {
+			expected = "expected top-level " + string(s.Type)
+		}
+		return newError(platform, path, expected, "got "+string(got))
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		for i, item := range v {
+			if err := validate(platform, fmt.Sprintf("%s[%d]", path, i), item, s.Items); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		// 按字段名顺序检查，保证错误信息稳定
+		for _, name := range sortedKeys(s.Required) {
+			field, ok := v[name]
+			if !ok {
+				return newError(platform, path, fmt.Sprintf("missing required field %q", name), "")
+			}
+			if err := validate(platform, fieldPath(path, name), field, s.Required[name]); err != nil {
+				return err
+			}
+		}
+		for _, name := range sortedKeys(s.Optional) {
+			if field, ok := v[name]; ok && field != nil {
+				if err := validate(platform, fieldPath(path, name), field, s.Optional[name]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func kindOf(value interface{}) Kind {
+	switch value.(type) {
+	case map[string]interface{}:
+		return Object
+	case []interface{}:
+		return Array
+	case string:
+		return String
+	case float64:
+		return Number
+	case bool:
+		return Boolean
+	default:
+		return Null
+	}
+}
+
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func sortedKeys(fields map[string]*Schema) []string {
+	keys := make([]string, 0, len(fields))
+	for name := range fields {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,70 @@
+package timestamp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// layouts 导出文件中见过的时间格式，没有时区的按 UTC 处理
+var layouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// millisThreshold 超过该值的数字按毫秒处理（按秒计算已在 33000 年之后）
+const millisThreshold = 1e12
+
+// ParseFlexibleTime parses a timestamp in any of the formats used by chat exports:
+// RFC 3339 with or without fractional seconds, the same without a zone or with a
+// space instead of "T" (read as UTC), a plain date, or a numeric unix timestamp in
+// seconds (optionally fractional) or milliseconds. An empty string returns the zero
+// time and no error, so missing values can be backfilled by the transformer
+func ParseFlexibleTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if value, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return time.Time{}, fmt.Errorf("invalid unix timestamp %q", s)
+		}
+		if math.Abs(value) >= millisThreshold {
+			return FromUnixMillis(value), nil
+		}
+		return FromUnix(value), nil
+	}
+
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time format %q", s)
+}
+
+// FromUnix converts unix seconds (optionally fractional) to UTC time; 0 returns the zero
+// time. The fraction is rounded to microseconds to avoid float64 precision noise
+func FromUnix(seconds float64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e6))*int64(time.Microsecond)).UTC()
+}
+
+// FromUnixMillis converts unix milliseconds to UTC time; 0 returns the zero time
+func FromUnixMillis(millis float64) time.Time {
+	if millis == 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(int64(math.Round(millis * 1e3))).UTC()
+}
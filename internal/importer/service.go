@@ -1,8 +1,15 @@
 package importer
 
 import (
+	"fmt"
+
 	"chat-assistant-backend/internal/config"
 	"chat-assistant-backend/internal/importer/parsers"
+	"chat-assistant-backend/internal/logger"
+	"chat-assistant-backend/internal/repositories"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 // Service 导入服务
@@ -19,11 +26,51 @@ func NewService(cfg *config.Config) *Service {
 	}
 }
 
+// EnableIndexing 启用导入后自动索引到 Elasticsearch
+func (s *Service) EnableIndexing(indexer repositories.ElasticsearchIndexer) {
+	s.importer.SetIndexer(s.importer.loader.conversationRepo, indexer)
+}
+
 // Import 执行导入
 func (s *Service) Import(filePath, platform, userID string, dryRun bool) (*ImportResult, error) {
 	return s.importer.Import(filePath, platform, userID, dryRun)
 }
 
+// Diff 比较导出文件与用户已导入的数据，不写入数据库
+func (s *Service) Diff(filePath, platform, userID string) (*ImportDiff, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	// 读取文件，zip 导出包会自动解压
+	data, err := s.importer.readInput(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return s.importer.diff(data, platform, uid)
+}
+
+// ImportDir 导入目录（或 glob 模式）下的所有 JSON 文件，非 JSON 文件会被跳过
+func (s *Service) ImportDir(source, platform, userID string, dryRun bool, parallel int) (*BatchImportResult, error) {
+	files, skipped, err := CollectImportFiles(source)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range skipped {
+		logger.GetLogger().Warn("Skipping non-JSON file", zap.String("file", path))
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no JSON files found in %s", source)
+	}
+
+	result := s.importer.ImportFiles(files, platform, userID, dryRun, parallel)
+	result.Skipped = skipped
+	return result, nil
+}
+
 // GetSupportedPlatforms 获取支持的平台列表
 func (s *Service) GetSupportedPlatforms() []string {
 	return parsers.GetSupportedPlatforms()
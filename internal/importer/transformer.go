@@ -4,18 +4,69 @@ import (
 	"fmt"
 	"time"
 
+	"chat-assistant-backend/internal/config"
 	"chat-assistant-backend/internal/importer/types"
+	"chat-assistant-backend/internal/logger"
 	"chat-assistant-backend/internal/models"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
+// DefaultMissingTimestampStep 缺少时间的消息之间默认间隔的时长
+const DefaultMissingTimestampStep = time.Second
+
 // Transformer 数据转换器
-type Transformer struct{}
+type Transformer struct {
+	// missingTimestampStep 缺少时间的消息按顺序补齐时间的间隔，0 表示使用当前时间
+	missingTimestampStep time.Duration
+	// defaultModels 按平台的默认模型，导出数据中没有模型时使用
+	defaultModels map[string]string
+	// maxMessages 单个对话的最大消息数，0 表示不限制
+	maxMessages int
+	// oversizedPolicy 超出 maxMessages 时的处理方式，见 config.OversizedPolicySplit
+	oversizedPolicy string
+	// ids 生成对话、消息和附件的 ID
+	ids idGenerator
+}
+
+// TransformerOptions configures Transformer
+type TransformerOptions struct {
+	// MissingTimestampStep 缺少时间的消息按 step 间隔补齐，<= 0 时使用当前时间
+	MissingTimestampStep time.Duration
+	// DefaultModels 按平台（chatgpt、claude、gemini）的默认模型，见 config.ImportConfig.DefaultModels
+	DefaultModels map[string]string
+	// MaxMessagesPerConversation 单个对话的最大消息数，超出时按 OversizedPolicy 处理，<= 0 表示不限制
+	MaxMessagesPerConversation int
+	// OversizedPolicy config.OversizedPolicySplit（默认）或 config.OversizedPolicyTruncate
+	OversizedPolicy string
+	// DeterministicIDs 由用户、平台和原始 ID（或内容哈希）派生 UUIDv5，重复导入同一份导出得到相同的 ID
+	DeterministicIDs bool
+}
 
-// NewTransformer 创建转换器
+// NewTransformer 创建转换器，缺少时间的消息按 DefaultMissingTimestampStep 补齐
 func NewTransformer() *Transformer {
-	return &Transformer{}
+	return NewTransformerWithTimestampStep(DefaultMissingTimestampStep)
+}
+
+// NewTransformerWithTimestampStep 创建转换器，缺少时间的消息按 step 间隔补齐，step <= 0 时使用当前时间
+func NewTransformerWithTimestampStep(step time.Duration) *Transformer {
+	return NewTransformerWithOptions(TransformerOptions{MissingTimestampStep: step})
+}
+
+// NewTransformerWithOptions 创建使用指定选项的转换器
+func NewTransformerWithOptions(opts TransformerOptions) *Transformer {
+	policy := opts.OversizedPolicy
+	if policy == "" {
+		policy = config.OversizedPolicySplit
+	}
+	return &Transformer{
+		missingTimestampStep: max(opts.MissingTimestampStep, 0),
+		defaultModels:        opts.DefaultModels,
+		maxMessages:          max(opts.MaxMessagesPerConversation, 0),
+		oversizedPolicy:      policy,
+		ids:                  idGenerator{deterministic: opts.DeterministicIDs},
+	}
 }
 
 // MessageWithConversationSource 包含消息和其所属对话的source_id
@@ -35,19 +86,33 @@ func (t *Transformer) Transform(data *types.StandardFormat, userID uuid.UUID, pl
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to transform conversation %s: %w", stdConv.ID, err)
 		}
-		conversations = append(conversations, conv)
 
 		// 转换消息
-		for _, stdMsg := range stdConv.Messages {
-			msg, err := t.transformMessage(stdMsg, conv.ID)
+		var converted []*MessageWithConversationSource
+		var previous time.Time
+		for index, stdMsg := range stdConv.Messages {
+			msg, err := t.transformMessage(stdMsg, conv.ID, index)
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to transform message: %w", err)
 			}
-			messages = append(messages, &MessageWithConversationSource{
+			if stdMsg.CreatedAt.IsZero() && t.missingTimestampStep > 0 {
+				msg.CreatedAt = t.backfillTime(conv.CreatedAt, previous, index)
+			}
+			previous = msg.CreatedAt
+			converted = append(converted, &MessageWithConversationSource{
 				Message:              msg,
 				ConversationSourceID: stdConv.ID,
 			})
 		}
+
+		if t.maxMessages > 0 && len(converted) > t.maxMessages {
+			parts, kept := t.limitMessages(conv, converted)
+			conversations = append(conversations, parts...)
+			messages = append(messages, kept...)
+			continue
+		}
+		conversations = append(conversations, conv)
+		messages = append(messages, converted...)
 	}
 
 	return conversations, messages, nil
@@ -57,7 +122,7 @@ func (t *Transformer) Transform(data *types.StandardFormat, userID uuid.UUID, pl
 func (t *Transformer) transformConversation(stdConv *types.StandardConversation, userID uuid.UUID, platform string) (*models.Conversation, error) {
 	conv := &models.Conversation{
 		Base: models.Base{
-			ID: uuid.New(), // 手动生成UUID
+			ID: t.ids.conversationID(userID, platform, stdConv),
 		},
 		UserID:      userID,
 		Provider:    platform,
@@ -65,6 +130,12 @@ func (t *Transformer) transformConversation(stdConv *types.StandardConversation,
 		SourceID:    stdConv.ID, // 使用原始数据中的ID作为SourceID
 		SourceTitle: stdConv.Title,
 	}
+	if conv.Model == "" {
+		conv.Model = t.defaultModels[platform]
+	}
+
+	// 保存平台提供的元信息（summary、account 等）
+	conv.SetMetadata(conversationMetadata(stdConv.Metadata))
 
 	// 设置时间
 	if !stdConv.CreatedAt.IsZero() {
@@ -82,16 +153,100 @@ func (t *Transformer) transformConversation(stdConv *types.StandardConversation,
 	return conv, nil
 }
 
+// limitMessages 处理消息数超过上限的对话，在元信息中记录处理方式：
+// truncate 只保留最近的 maxMessages 条消息；split 按顺序每 maxMessages 条拆分为一个对话，
+// 第一部分沿用原 source_id，之后的部分为 "<source_id>#part<n>"，重复导入时仍能去重
+func (t *Transformer) limitMessages(conv *models.Conversation, messages []*MessageWithConversationSource) ([]*models.Conversation, []*MessageWithConversationSource) {
+	total := len(messages)
+	meta := conv.GetMetadata()
+	if meta == nil {
+		meta = &models.ConversationMetadata{}
+	}
+
+	if t.oversizedPolicy == config.OversizedPolicyTruncate {
+		meta.ImportPolicy = config.OversizedPolicyTruncate
+		meta.ImportNote = fmt.Sprintf("kept the most recent %d of %d messages", t.maxMessages, total)
+		conv.SetMetadata(meta)
+		logger.GetLogger().Warn("Conversation exceeds message limit, truncated",
+			zap.String("source_id", conv.SourceID),
+			zap.Int("messages", total),
+			zap.Int("max_messages", t.maxMessages))
+		return []*models.Conversation{conv}, messages[total-t.maxMessages:]
+	}
+
+	partCount := (total + t.maxMessages - 1) / t.maxMessages
+	original := *conv
+	conversations := make([]*models.Conversation, 0, partCount)
+	for part := 0; part < partCount; part++ {
+		start := part * t.maxMessages
+		end := min(start+t.maxMessages, total)
+
+		partConv := conv
+		if part > 0 {
+			copied := original
+			copied.ID = t.ids.partID(original.ID, part+1)
+			copied.SourceID = fmt.Sprintf("%s#part%d", original.SourceID, part+1)
+			// 后续部分从本部分第一条消息开始
+			copied.CreatedAt = messages[start].Message.CreatedAt
+			partConv = &copied
+		}
+		if original.SourceTitle != "" {
+			partConv.SourceTitle = fmt.Sprintf("%s (%d/%d)", original.SourceTitle, part+1, partCount)
+		}
+
+		partMeta := *meta
+		partMeta.ImportPolicy = config.OversizedPolicySplit
+		partMeta.ImportNote = fmt.Sprintf("part %d of %d, messages %d-%d of %d", part+1, partCount, start+1, end, total)
+		partConv.SetMetadata(&partMeta)
+
+		for _, msg := range messages[start:end] {
+			msg.Message.ConversationID = partConv.ID
+			msg.ConversationSourceID = partConv.SourceID
+		}
+		conversations = append(conversations, partConv)
+	}
+
+	logger.GetLogger().Warn("Conversation exceeds message limit, split",
+		zap.String("source_id", original.SourceID),
+		zap.Int("messages", total),
+		zap.Int("max_messages", t.maxMessages),
+		zap.Int("parts", partCount))
+	return conversations, messages
+}
+
+// backfillTime 为缺少时间的消息生成时间，保持消息顺序：
+// 紧跟在上一条消息之后 step，第一条消息为对话创建时间加 index*step。
+// 整个对话都没有时间时即为 created_at + index*step
+func (t *Transformer) backfillTime(conversationCreatedAt, previous time.Time, index int) time.Time {
+	if !previous.IsZero() {
+		return previous.Add(t.missingTimestampStep)
+	}
+	return conversationCreatedAt.Add(time.Duration(index) * t.missingTimestampStep)
+}
+
+// conversationMetadata 从标准化格式的元信息中提取已知的字符串字段
+func conversationMetadata(raw map[string]interface{}) *models.ConversationMetadata {
+	fields := make(map[string]string)
+	for _, key := range models.ConversationMetadataKeys {
+		if value, ok := raw[key].(string); ok && value != "" {
+			fields[key] = value
+		}
+	}
+	return models.NewConversationMetadata(fields)
+}
+
 // transformMessage 转换消息
-func (t *Transformer) transformMessage(stdMsg *types.StandardMessage, conversationID uuid.UUID) (*models.Message, error) {
+func (t *Transformer) transformMessage(stdMsg *types.StandardMessage, conversationID uuid.UUID, index int) (*models.Message, error) {
 	msg := &models.Message{
 		Base: models.Base{
-			ID: uuid.New(), // 手动生成UUID
+			ID: t.ids.messageID(conversationID, index, stdMsg),
 		},
 		ConversationID: conversationID,
 		Role:           stdMsg.Role,
 		SourceID:       stdMsg.ID,
 		SourceContent:  stdMsg.Content,
+		// 按导出中的位置编号，时间相同或缺失时仍保持原顺序；拆分和截断后保留原编号
+		Sequence: index + 1,
 	}
 
 	// 设置时间
@@ -103,5 +258,20 @@ func (t *Transformer) transformMessage(stdMsg *types.StandardMessage, conversati
 
 	msg.UpdatedAt = time.Now()
 
+	// 保留导入数据中的附件引用
+	for i, attachment := range stdMsg.Attachments {
+		attachmentType := attachment.Type
+		if attachmentType == "" {
+			attachmentType = "file"
+		}
+		msg.Attachments = append(msg.Attachments, models.MessageAttachment{
+			Base:      models.Base{ID: t.ids.attachmentID(msg.ID, i)},
+			MessageID: msg.ID,
+			Type:      attachmentType,
+			Name:      attachment.Name,
+			URLOrRef:  attachment.Ref,
+		})
+	}
+
 	return msg, nil
 }
@@ -7,6 +7,7 @@ type ClaudeExportData []ClaudeConversation
 type ClaudeConversation struct {
 	UUID         string                 `json:"uuid"`
 	Name         string                 `json:"name"`
+	Model        string                 `json:"model"`
 	Summary      string                 `json:"summary"`
 	CreatedAt    string                 `json:"created_at"`
 	UpdatedAt    string                 `json:"updated_at"`
@@ -28,11 +29,27 @@ type ClaudeMessage struct {
 	Sender      string                 `json:"sender"`
 	CreatedAt   string                 `json:"created_at"` // 2025-09-22T09:17:21.803710Z
 	UpdatedAt   string                 `json:"updated_at"`
-	Attachments []interface{}          `json:"attachments"`
-	Files       []interface{}          `json:"files"`
+	Attachments []ClaudeAttachment     `json:"attachments"`
+	Files       []ClaudeFile           `json:"files"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// ClaudeAttachment Claude消息附件（上传时已提取文本内容的文件）
+type ClaudeAttachment struct {
+	FileName         string `json:"file_name"`
+	FileSize         int64  `json:"file_size"`
+	FileType         string `json:"file_type"`
+	ExtractedContent string `json:"extracted_content"`
+}
+
+// ClaudeFile Claude消息文件（图片等以文件形式引用的内容）
+type ClaudeFile struct {
+	FileName   string `json:"file_name"`
+	FileKind   string `json:"file_kind"`
+	FileUUID   string `json:"file_uuid"`
+	PreviewURL string `json:"preview_url"`
+}
+
 // ClaudeContent Claude消息内容结构
 type ClaudeContent struct {
 	StartTimestamp string                 `json:"start_timestamp"` // 2025-09-22T09:17:21.803710Z
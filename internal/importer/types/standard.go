@@ -21,9 +21,17 @@ type StandardConversation struct {
 
 // StandardMessage 标准化消息
 type StandardMessage struct {
-	ID        string                 `json:"id"`
-	Role      string                 `json:"role"`
-	Content   string                 `json:"content"`
-	CreatedAt time.Time              `json:"created_at"`
-	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	ID          string                 `json:"id"`
+	Role        string                 `json:"role"`
+	Content     string                 `json:"content"`
+	CreatedAt   time.Time              `json:"created_at"`
+	Attachments []*StandardAttachment  `json:"attachments,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// StandardAttachment 标准化附件引用
+type StandardAttachment struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Ref  string `json:"ref"` // 原平台中的文件地址或引用ID
 }
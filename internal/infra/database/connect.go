@@ -0,0 +1,78 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// maxConnectRetryInterval 重试间隔每次翻倍，最长不超过该值
+const maxConnectRetryInterval = 30 * time.Second
+
+// ConnectFunc opens a database connection and checks that it is usable
+type ConnectFunc func() (*gorm.DB, error)
+
+// Connect opens the primary database and pings it, retrying with backoff while
+// Postgres is not ready yet (see ConnectWithRetry)
+func Connect(cfg *config.DatabaseConfig) (*gorm.DB, error) {
+	return ConnectWithRetry(cfg, func() (*gorm.DB, error) {
+		return openAndPing(cfg.GetDSN())
+	})
+}
+
+// ConnectWithRetry calls connect until it succeeds, retrying up to cfg.ConnectRetries
+// times. The first retry waits cfg.ConnectRetryInterval and each following one waits
+// twice as long, capped at 30s. Every failed attempt is logged; the last error is
+// returned once the retries are exhausted
+func ConnectWithRetry(cfg *config.DatabaseConfig, connect ConnectFunc) (*gorm.DB, error) {
+	log := logger.GetLogger()
+	attempts := max(cfg.ConnectRetries, 0) + 1
+	interval := cfg.ConnectRetryInterval
+
+	for attempt := 1; ; attempt++ {
+		db, err := connect()
+		if err == nil {
+			if attempt > 1 {
+				log.Info("Database connection established", zap.Int("attempt", attempt))
+			}
+			return db, nil
+		}
+
+		if attempt >= attempts {
+			return nil, fmt.Errorf("failed to connect to database after %d attempt(s): %w", attempt, err)
+		}
+
+		log.Warn("Database connection failed, retrying",
+			zap.Int("attempt", attempt),
+			zap.Int("max_attempts", attempts),
+			zap.Duration("retry_in", interval),
+			zap.Error(err),
+		)
+		time.Sleep(interval)
+		interval = min(interval*2, maxConnectRetryInterval)
+	}
+}
+
+// openAndPing 打开连接并 Ping，Ping 失败时关闭已打开的连接池
+func openAndPing(dsn string) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database instance: %w", err)
+	}
+	if err := sqlDB.Ping(); err != nil {
+		_ = sqlDB.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return db, nil
+}
@@ -6,19 +6,46 @@ import (
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
-// NewDatabase creates a new database connection
+// NewDatabase creates a new database connection. When read replicas are configured,
+// queries are routed to them and writes/transactions to the primary; use
+// db.Clauses(dbresolver.Write) for reads that must observe a preceding write.
+// The primary connection is retried while Postgres is starting (see Connect)
 func NewDatabase(cfg *config.Config) (*gorm.DB, error) {
-	dsn := cfg.Database.GetDSN()
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := Connect(&cfg.Database)
 	if err != nil {
 		return nil, err
 	}
 
+	if resolver := newReplicaResolver(&cfg.Database); resolver != nil {
+		if err := db.Use(resolver); err != nil {
+			return nil, err
+		}
+	}
+
 	return db, nil
 }
 
+// newReplicaResolver 根据只读副本配置创建 dbresolver 插件，未配置副本时返回 nil
+func newReplicaResolver(cfg *config.DatabaseConfig) *dbresolver.DBResolver {
+	dsns := cfg.GetReplicaDSNs()
+	if len(dsns) == 0 {
+		return nil
+	}
+
+	replicas := make([]gorm.Dialector, len(dsns))
+	for i, dsn := range dsns {
+		replicas[i] = postgres.Open(dsn)
+	}
+
+	return dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	})
+}
+
 // RunMigrations runs database migrations
 func RunMigrations(db *gorm.DB) error {
 	migrator, err := migrations.NewMigrator(db, nil)
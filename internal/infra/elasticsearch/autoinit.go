@@ -0,0 +1,91 @@
+package elasticsearch
+
+import (
+	"context"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// AutoInit creates the missing indices before the server accepts requests, so a fresh
+// deploy can search without running es-manager init first. It never deletes or changes
+// existing indices. It implements server.Component; failures are logged and never stop
+// the server
+type AutoInit struct {
+	client      *Client
+	initializer *Initializer
+
+	// cancel 停止等待 ES 恢复后执行的初始化
+	cancel context.CancelFunc
+}
+
+// NewAutoInit creates the index auto-initialization of client's configured indices
+func NewAutoInit(client *Client) *AutoInit {
+	return &AutoInit{
+		client:      client,
+		initializer: NewInitializer(client, nil),
+	}
+}
+
+// NewAutoInitFromConfig creates the index auto-initialization enabled by
+// elasticsearch.auto_init, or returns nil when it is disabled
+func NewAutoInitFromConfig(client *Client, cfg *config.Config) *AutoInit {
+	if !cfg.Elasticsearch.AutoInit {
+		return nil
+	}
+	return NewAutoInit(client)
+}
+
+// Start waits for Elasticsearch to become healthy and creates the indices that do not
+// exist, logging which ones were created. When the server started in degraded mode, the
+// indices are created in the background once the client reconnects. It always returns nil
+func (a *AutoInit) Start(ctx context.Context) error {
+	// 降级启动时 ES 不可用，等后台重连成功后再初始化，不阻塞启动
+	if !a.client.Available() {
+		logger.GetLogger().Warn("Elasticsearch is unavailable, index initialization will run once it is back")
+		initCtx, cancel := context.WithCancel(context.Background())
+		a.cancel = cancel
+		a.client.OnAvailable(func() {
+			if initCtx.Err() == nil {
+				a.ensureIndexes(initCtx)
+			}
+		})
+		return nil
+	}
+
+	a.ensureIndexes(ctx)
+	return nil
+}
+
+// ensureIndexes creates the missing indices and logs the outcome
+func (a *AutoInit) ensureIndexes(ctx context.Context) {
+	log := logger.GetLogger()
+
+	started := time.Now()
+	created, err := a.initializer.EnsureIndexes(ctx)
+	for _, index := range created {
+		log.Info("Created Elasticsearch index", zap.String("index", index))
+	}
+	if err != nil {
+		log.Error("Elasticsearch index initialization failed",
+			zap.Duration("duration", time.Since(started)),
+			zap.Error(err),
+		)
+		return
+	}
+
+	if len(created) == 0 {
+		log.Info("Elasticsearch indices already exist, nothing to initialize")
+	}
+}
+
+// Stop cancels an initialization still waiting for Elasticsearch to recover
+func (a *AutoInit) Stop(ctx context.Context) error {
+	if a.cancel != nil {
+		a.cancel()
+	}
+	return nil
+}
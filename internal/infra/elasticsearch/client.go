@@ -4,31 +4,171 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/logger"
 	"chat-assistant-backend/internal/repositories"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 启动时重试连接的退避区间
+const (
+	startupInitialBackoff = 100 * time.Millisecond
+	startupMaxBackoff     = 5 * time.Second
 )
 
 // Client wraps the Elasticsearch client with additional functionality
 type Client struct {
 	es  *elasticsearch.Client
 	cfg *Config
+
+	// available 表示最近一次连接检测是否成功；降级启动时为 false，直到后台重连成功
+	available atomic.Bool
+
+	// onAvailable 降级启动后恢复连接时执行的回调，由 mu 保护
+	mu          sync.Mutex
+	onAvailable []func()
 }
 
 // NewClient creates a new Elasticsearch client
 func NewClient(cfg *Config) (*Client, error) {
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Test connection
+	if err := client.Ping(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to connect to Elasticsearch: %w", err)
+	}
+	client.available.Store(true)
+
+	return client, nil
+}
+
+// ConnectWithRetry creates a client and pings Elasticsearch with exponential backoff
+// for up to startupTimeout. If it is still unreachable, the client is returned in
+// degraded mode instead of failing, and a background reconnector checks every
+// reconnectInterval until Elasticsearch responds.
+func ConnectWithRetry(cfg *Config, startupTimeout, reconnectInterval time.Duration) (*Client, error) {
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+	defer cancel()
+
+	backoff := startupInitialBackoff
+	for {
+		err = client.Ping(ctx)
+		if err == nil {
+			client.available.Store(true)
+			return client, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.GetLogger().Warn("!!! Elasticsearch is unavailable, starting in degraded mode: search and indexing will fail until it recovers",
+				zap.Strings("hosts", client.cfg.Hosts),
+				zap.Duration("startup_timeout", startupTimeout),
+				zap.Error(err),
+			)
+			go client.reconnect(reconnectInterval)
+			return client, nil
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > startupMaxBackoff {
+			backoff = startupMaxBackoff
+		}
+	}
+}
+
+// reconnect 后台定期检测 ES，连接成功后恢复为可用状态并退出
+func (c *Client) reconnect(interval time.Duration) {
+	if interval <= 0 {
+		interval = startupMaxBackoff
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := c.Ping(ctx)
+		cancel()
+
+		if err == nil {
+			c.mu.Lock()
+			c.available.Store(true)
+			hooks := c.onAvailable
+			c.onAvailable = nil
+			c.mu.Unlock()
+
+			logger.GetLogger().Info("Elasticsearch connection restored, leaving degraded mode",
+				zap.Strings("hosts", c.cfg.Hosts),
+			)
+			for _, fn := range hooks {
+				go fn()
+			}
+			return
+		}
+	}
+}
+
+// Available reports whether Elasticsearch was reachable at the last connection check
+func (c *Client) Available() bool {
+	return c.available.Load()
+}
+
+// OnAvailable registers fn to run in its own goroutine once the background reconnector
+// restores the connection after a degraded start. If Elasticsearch is already available,
+// fn runs right away
+func (c *Client) OnAvailable(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.available.Load() {
+		go fn()
+		return
+	}
+	c.onAvailable = append(c.onAvailable, fn)
+}
+
+// newClient 创建客户端但不检测连接
+func newClient(cfg *Config) (*Client, error) {
 	if cfg == nil {
 		cfg = DefaultConfig()
 	}
 
 	// Build client configuration
 	esConfig := elasticsearch.Config{
-		Addresses: cfg.Hosts,
+		Addresses:     cfg.Hosts,
+		MaxRetries:    cfg.MaxRetries,
+		DisableRetry:  cfg.MaxRetries == 0,
+		RetryOnStatus: cfg.RetryOnStatus,
+	}
+
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		esConfig.Transport = transport
 	}
 
 	// Add authentication if provided
@@ -43,17 +183,10 @@ func NewClient(cfg *Config) (*Client, error) {
 		return nil, fmt.Errorf("failed to create Elasticsearch client: %w", err)
 	}
 
-	client := &Client{
+	return &Client{
 		es:  es,
 		cfg: cfg,
-	}
-
-	// Test connection
-	if err := client.Ping(context.Background()); err != nil {
-		return nil, fmt.Errorf("failed to connect to Elasticsearch: %w", err)
-	}
-
-	return client, nil
+	}, nil
 }
 
 // GetClient returns the underlying Elasticsearch client
@@ -187,6 +320,174 @@ func (c *Client) IndexExists(ctx context.Context, indexName string) (bool, error
 	return res.StatusCode == 200, nil
 }
 
+// GetIndexSettings returns the effective value of the given settings (e.g. "index.refresh_interval"),
+// falling back to cluster defaults for settings that are not set on the index
+func (c *Client) GetIndexSettings(ctx context.Context, indexName string, names ...string) (map[string]string, error) {
+	flat, includeDefaults := true, true
+	req := esapi.IndicesGetSettingsRequest{
+		Index:           []string{indexName},
+		Name:            names,
+		FlatSettings:    &flat,
+		IncludeDefaults: &includeDefaults,
+	}
+
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return nil, fmt.Errorf("get index settings request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("get index settings failed with status: %s", res.Status())
+	}
+
+	var result map[string]struct {
+		Settings map[string]string `json:"settings"`
+		Defaults map[string]string `json:"defaults"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode index settings: %w", err)
+	}
+
+	index, ok := result[indexName]
+	if !ok {
+		return nil, fmt.Errorf("index %s not found in settings response", indexName)
+	}
+
+	settings := make(map[string]string, len(names))
+	for _, name := range names {
+		if value, ok := index.Settings[name]; ok {
+			settings[name] = value
+		} else if value, ok := index.Defaults[name]; ok {
+			settings[name] = value
+		}
+	}
+	return settings, nil
+}
+
+// UpdateIndexSettings updates dynamic settings of an index
+func (c *Client) UpdateIndexSettings(ctx context.Context, indexName string, settings map[string]interface{}) error {
+	body, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index settings: %w", err)
+	}
+
+	req := esapi.IndicesPutSettingsRequest{
+		Index: []string{indexName},
+		Body:  strings.NewReader(string(body)),
+	}
+
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return fmt.Errorf("update index settings request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("update index settings failed with status: %s", res.Status())
+	}
+
+	return nil
+}
+
+// GetMapping returns the mappings of an index (the "mappings" object of the _mapping API).
+// indexName may be an alias that points to a single index
+func (c *Client) GetMapping(ctx context.Context, indexName string) (map[string]interface{}, error) {
+	req := esapi.IndicesGetMappingRequest{
+		Index: []string{indexName},
+	}
+
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return nil, fmt.Errorf("get mapping request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("get mapping failed with status: %s", res.Status())
+	}
+
+	var result map[string]struct {
+		Mappings map[string]interface{} `json:"mappings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode mapping: %w", err)
+	}
+
+	// 响应以实际索引名为键，通过别名查询时与 indexName 不同
+	if len(result) != 1 {
+		return nil, fmt.Errorf("expected mapping of one index for %s, got %d", indexName, len(result))
+	}
+	for _, index := range result {
+		return index.Mappings, nil
+	}
+	return nil, nil
+}
+
+// PutMapping updates the mappings of an index. A mapping rejected by Elasticsearch (400,
+// e.g. an unknown field type or a conflicting change) is returned as an INVALID_MAPPING
+// AppError carrying the reason Elasticsearch gave
+func (c *Client) PutMapping(ctx context.Context, indexName string, mapping map[string]interface{}) error {
+	body, err := json.Marshal(mapping)
+	if err != nil {
+		return fmt.Errorf("failed to marshal mapping: %w", err)
+	}
+
+	req := esapi.IndicesPutMappingRequest{
+		Index: []string{indexName},
+		Body:  strings.NewReader(string(body)),
+	}
+
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return fmt.Errorf("put mapping request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		var result struct {
+			Error struct {
+				Reason string `json:"reason"`
+			} `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&result)
+		if res.StatusCode == http.StatusBadRequest {
+			return errors.NewAppError(errors.ErrCodeInvalidMapping, "Invalid mapping", http.StatusBadRequest).
+				WithDetails(result.Error.Reason)
+		}
+		return fmt.Errorf("put mapping failed with status: %s", res.Status())
+	}
+
+	return nil
+}
+
+// DeleteByQuery deletes the documents of index matching query, the JSON body of a
+// _delete_by_query request (e.g. {"query": {"term": {"user_id": "..."}}}), and returns how
+// many were deleted. Documents modified while the request runs are skipped instead of
+// failing it, and the index is refreshed so deleted documents no longer show up in searches
+func (c *Client) DeleteByQuery(ctx context.Context, index string, query []byte) (int64, error) {
+	return repositories.DeleteByQuery(ctx, c.es, index, query)
+}
+
+// RefreshIndex makes all operations performed on an index visible to search
+func (c *Client) RefreshIndex(ctx context.Context, indexName string) error {
+	req := esapi.IndicesRefreshRequest{
+		Index: []string{indexName},
+	}
+
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return fmt.Errorf("refresh index request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("refresh index failed with status: %s", res.Status())
+	}
+
+	return nil
+}
+
 // HealthChecker provides health check functionality for Elasticsearch
 type HealthChecker struct {
 	client *Client
@@ -267,6 +568,11 @@ func (h *HealthChecker) WaitForHealthy(ctx context.Context, timeout time.Duratio
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	// 先检查一次，已健康时不必等待第一个周期
+	if h.IsHealthy(ctx) {
+		return nil
+	}
+
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
@@ -284,23 +590,78 @@ func (h *HealthChecker) WaitForHealthy(ctx context.Context, timeout time.Duratio
 
 // NewElasticsearchClientFromConfig creates a new Elasticsearch client from config
 func NewElasticsearchClientFromConfig(cfg *config.Config) (*Client, error) {
-	esConfig := &Config{
+	return NewClient(clientConfig(cfg))
+}
+
+// NewElasticsearchClientWithRetry creates the server's Elasticsearch client; it retries
+// for cfg.Elasticsearch.StartupTimeout and then starts in degraded mode rather than failing
+func NewElasticsearchClientWithRetry(cfg *config.Config) (*Client, error) {
+	return ConnectWithRetry(clientConfig(cfg), cfg.Elasticsearch.StartupTimeout, cfg.Elasticsearch.ReconnectInterval)
+}
+
+// clientConfig 将应用配置转换为客户端配置
+func clientConfig(cfg *config.Config) *Config {
+	return &Config{
 		Hosts:    cfg.Elasticsearch.Hosts,
 		Username: cfg.Elasticsearch.Username,
 		Password: cfg.Elasticsearch.Password,
 		Timeout:  cfg.Elasticsearch.Timeout,
 		Index: IndexConfig{
-			Conversations: cfg.Elasticsearch.Index.Conversations,
-			Messages:      cfg.Elasticsearch.Index.Messages,
+			Conversations:  cfg.Elasticsearch.Index.Conversations,
+			Messages:       cfg.Elasticsearch.Index.Messages,
+			SourceAnalyzer: cfg.Elasticsearch.Index.SourceAnalyzer,
+			ExactAnalyzer:  cfg.Elasticsearch.Index.ExactAnalyzer,
 		},
+		CACertFile:         cfg.Elasticsearch.TLS.CACertFile,
+		InsecureSkipVerify: cfg.Elasticsearch.TLS.InsecureSkipVerify,
+		MaxRetries:         cfg.Elasticsearch.Retry.MaxRetries,
+		RetryOnStatus:      cfg.Elasticsearch.Retry.RetryOnStatus,
 	}
-
-	return NewClient(esConfig)
 }
 
 // NewElasticsearchIndexerFromClient creates a new Elasticsearch indexer from client
 func NewElasticsearchIndexerFromClient(esClient *Client, cfg *config.Config) repositories.ElasticsearchIndexer {
-	return repositories.NewElasticsearchIndexer(esClient.GetClient(), cfg.Elasticsearch.Index.Conversations)
+	return repositories.NewElasticsearchIndexerWithOptions(esClient.GetClient(), cfg.Elasticsearch.Index.Conversations, repositories.IndexerOptions{
+		Refresh: repositories.RefreshPolicy{
+			Write: cfg.Elasticsearch.Refresh.Write,
+			Bulk:  cfg.Elasticsearch.Refresh.Bulk,
+		},
+		MaxMessageChars: cfg.Import.MaxMessageChars,
+		MaxMessages:     cfg.Import.MaxIndexedMessages,
+		Deleter:         esClient,
+		MessageIndex:    messageIndex(cfg),
+	})
+}
+
+// messageIndex 返回需要写入的独立消息索引，search.message_index_mode 为 nested 时为空
+func messageIndex(cfg *config.Config) string {
+	if !cfg.Search.WritesMessageIndex() {
+		return ""
+	}
+	return cfg.Elasticsearch.Index.Messages
+}
+
+// NewSearchRepositoryFromClient creates a new Elasticsearch search repository from client;
+// search.message_index_mode 为 flat 时在独立消息索引中匹配消息内容，search.fallback 开启时
+// ES 不可用期间改用 Postgres 搜索
+func NewSearchRepositoryFromClient(esClient *Client, cfg *config.Config, db *gorm.DB) repositories.SearchRepository {
+	opts := repositories.SearchRepositoryOptions{
+		SlowSearchThreshold:    cfg.Search.SlowSearchThreshold,
+		PostProcessConcurrency: cfg.Search.PostProcessConcurrency,
+		PostProcessMaxMessages: cfg.Search.PostProcessMaxMessages,
+	}
+	var searchRepo repositories.SearchRepository
+	if cfg.Search.MessageIndexMode == config.MessageIndexModeFlat {
+		searchRepo = repositories.NewFlatMessageSearchRepository(esClient.GetClient(), cfg.Elasticsearch.Index.Conversations, cfg.Elasticsearch.Index.Messages, opts)
+	} else {
+		searchRepo = repositories.NewElasticsearchRepositoryWithOptions(esClient.GetClient(), cfg.Elasticsearch.Index.Conversations, opts)
+	}
+
+	// 降级启动（ES 不可用）期间改用数据库搜索，重连成功后切回 ES
+	if cfg.Search.Fallback {
+		return repositories.NewFallbackSearchRepository(searchRepo, repositories.NewPostgresSearchRepository(db), esClient.Available)
+	}
+	return searchRepo
 }
 
 // NewElasticsearchClient extracts the underlying Elasticsearch client
@@ -1,6 +1,12 @@
 package elasticsearch
 
-import "time"
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+)
 
 // Config holds Elasticsearch configuration
 type Config struct {
@@ -9,12 +15,25 @@ type Config struct {
 	Password string        `mapstructure:"password"`
 	Timeout  time.Duration `mapstructure:"timeout"`
 	Index    IndexConfig   `mapstructure:"index"`
+
+	// CACertFile 是 PEM 格式的 CA 证书路径，为空时使用系统证书
+	CACertFile string `mapstructure:"ca_cert_file"`
+	// InsecureSkipVerify 跳过证书校验，仅用于本地开发
+	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify"`
+	// MaxRetries 失败请求的最大重试次数，0 表示不重试
+	MaxRetries int `mapstructure:"max_retries"`
+	// RetryOnStatus 需要重试的 HTTP 状态码，为空时使用客户端默认值（502、503、504）
+	RetryOnStatus []int `mapstructure:"retry_on_status"`
 }
 
 // IndexConfig holds index-specific configuration
 type IndexConfig struct {
 	Conversations string `mapstructure:"conversations"`
 	Messages      string `mapstructure:"messages"`
+	// SourceAnalyzer 原文字段（source_title、source_content）的分析器，为空时使用 standard
+	SourceAnalyzer string `mapstructure:"source_analyzer"`
+	// ExactAnalyzer exact 子字段的分析器，为空时使用 DefaultExactAnalyzer
+	ExactAnalyzer string `mapstructure:"exact_analyzer"`
 }
 
 // DefaultConfig returns default Elasticsearch configuration
@@ -28,5 +47,27 @@ func DefaultConfig() *Config {
 			Conversations: "conversations",
 			Messages:      "messages",
 		},
+		MaxRetries: 3,
+	}
+}
+
+// tlsConfig 根据 CA 证书和校验设置构建 TLS 配置，未设置时返回 nil 使用默认传输层
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	if c.CACertFile == "" && !c.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CACertFile != "" {
+		cert, err := os.ReadFile(c.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cert) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", c.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
 	}
+	return tlsConfig, nil
 }
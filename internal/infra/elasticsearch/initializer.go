@@ -5,13 +5,28 @@ import (
 	"fmt"
 	"time"
 
+	"chat-assistant-backend/internal/models"
 	"chat-assistant-backend/internal/repositories"
 )
 
+// reindexBatchSize 全量重建索引时每批写入的 conversation 数量
+const reindexBatchSize = 100
+
+// bulkSettings 批量写入期间临时调整的索引设置。refresh_interval 使用较长的有限值而不是 -1，
+// 同步期间 API 服务的 wait_for 写入最多等待一个刷新周期，不会阻塞到同步结束
+var bulkSettings = map[string]interface{}{
+	"index.refresh_interval":   "30s",
+	"index.number_of_replicas": 0,
+}
+
 // Initializer 负责初始化 Elasticsearch 索引
 type Initializer struct {
-	client  *Client
-	indexer repositories.ElasticsearchIndexer
+	client           *Client
+	indexer          repositories.ElasticsearchIndexer
+	conversationRepo repositories.ConversationRepository
+
+	// savedSettings 保存 PrepareForBulk 之前的索引设置，供 FinishBulk 恢复
+	savedSettings map[string]interface{}
 }
 
 // NewInitializer 创建新的初始化器
@@ -22,71 +37,83 @@ func NewInitializer(client *Client, indexer repositories.ElasticsearchIndexer) *
 	}
 }
 
+// NewInitializerWithRepository 创建可从数据库重建索引的初始化器
+func NewInitializerWithRepository(client *Client, indexer repositories.ElasticsearchIndexer, conversationRepo repositories.ConversationRepository) *Initializer {
+	return &Initializer{
+		client:           client,
+		indexer:          indexer,
+		conversationRepo: conversationRepo,
+	}
+}
+
+// initHealthTimeout 初始化索引前等待 ES 健康的最长时间
+const initHealthTimeout = 60 * time.Second
+
 // Initialize 初始化所有必要的索引
 func (i *Initializer) Initialize(ctx context.Context) error {
+	_, err := i.EnsureIndexes(ctx)
+	return err
+}
+
+// EnsureIndexes waits for Elasticsearch to become healthy and creates the conversation
+// and message indices that do not exist yet, returning the names of the created ones.
+// Existing indices are left untouched
+func (i *Initializer) EnsureIndexes(ctx context.Context) ([]string, error) {
 	cfg := i.client.GetConfig()
 
 	// 等待 Elasticsearch 可用
 	healthChecker := NewHealthChecker(i.client)
-	if err := healthChecker.WaitForHealthy(ctx, 60*time.Second); err != nil {
-		return fmt.Errorf("elasticsearch is not healthy: %w", err)
+	if err := healthChecker.WaitForHealthy(ctx, initHealthTimeout); err != nil {
+		return nil, fmt.Errorf("elasticsearch is not healthy: %w", err)
 	}
 
+	var created []string
+
 	// 创建 conversation 索引
-	if err := i.createConversationIndex(ctx, cfg.Index.Conversations); err != nil {
-		return fmt.Errorf("failed to create conversation index: %w", err)
+	ok, err := i.createIndex(ctx, cfg.Index.Conversations, ConversationMappingWithOptions(i.mappingOptions()))
+	if err != nil {
+		return created, fmt.Errorf("failed to create conversation index: %w", err)
 	}
-
-	// 创建 message 索引（如果需要独立索引）
-	if err := i.createMessageIndex(ctx, cfg.Index.Messages); err != nil {
-		return fmt.Errorf("failed to create message index: %w", err)
+	if ok {
+		created = append(created, cfg.Index.Conversations)
 	}
 
-	return nil
-}
-
-// createConversationIndex 创建 conversation 索引
-func (i *Initializer) createConversationIndex(ctx context.Context, indexName string) error {
-	// 检查索引是否已存在
-	exists, err := i.client.IndexExists(ctx, indexName)
+	// 创建 message 索引（如果需要独立索引）
+	ok, err = i.createIndex(ctx, cfg.Index.Messages, MessageMappingWithOptions(i.mappingOptions()))
 	if err != nil {
-		return fmt.Errorf("failed to check if conversation index exists: %w", err)
-	}
-
-	if exists {
-		// 索引已存在，可以选择更新映射或跳过
-		return nil
+		return created, fmt.Errorf("failed to create message index: %w", err)
 	}
-
-	// 创建索引
-	mapping := ConversationMapping()
-	if err := i.client.CreateIndex(ctx, indexName, mapping); err != nil {
-		return fmt.Errorf("failed to create conversation index: %w", err)
+	if ok {
+		created = append(created, cfg.Index.Messages)
 	}
 
-	return nil
+	return created, nil
 }
 
-// createMessageIndex 创建 message 索引
-func (i *Initializer) createMessageIndex(ctx context.Context, indexName string) error {
+// createIndex 索引不存在时使用 mapping 创建，返回是否创建了索引
+func (i *Initializer) createIndex(ctx context.Context, indexName, mapping string) (bool, error) {
 	// 检查索引是否已存在
 	exists, err := i.client.IndexExists(ctx, indexName)
 	if err != nil {
-		return fmt.Errorf("failed to check if message index exists: %w", err)
+		return false, fmt.Errorf("failed to check if index exists: %w", err)
 	}
 
 	if exists {
-		// 索引已存在，可以选择更新映射或跳过
-		return nil
+		// 索引已存在，不修改映射
+		return false, nil
 	}
 
-	// 创建索引
-	mapping := MessageMapping()
 	if err := i.client.CreateIndex(ctx, indexName, mapping); err != nil {
-		return fmt.Errorf("failed to create message index: %w", err)
+		return false, err
 	}
 
-	return nil
+	return true, nil
+}
+
+// mappingOptions 根据客户端配置生成索引映射选项
+func (i *Initializer) mappingOptions() MappingOptions {
+	index := i.client.GetConfig().Index
+	return MappingOptions{SourceAnalyzer: index.SourceAnalyzer, ExactAnalyzer: index.ExactAnalyzer}
 }
 
 // RecreateIndexes 重新创建所有索引（会删除现有数据）
@@ -106,6 +133,116 @@ func (i *Initializer) RecreateIndexes(ctx context.Context) error {
 	return i.Initialize(ctx)
 }
 
+// Reindex 从数据库全量重建 conversation 索引，按批写入并回调进度
+func (i *Initializer) Reindex(ctx context.Context, progress func(processed, total int)) error {
+	if i.conversationRepo == nil {
+		return fmt.Errorf("reindex requires a conversation repository")
+	}
+
+	// 确保索引存在
+	if err := i.Initialize(ctx); err != nil {
+		return err
+	}
+
+	conversations, err := i.conversationRepo.FindAll()
+	if err != nil {
+		return fmt.Errorf("failed to get conversations: %w", err)
+	}
+
+	total := len(conversations)
+	if progress != nil {
+		progress(0, total)
+	}
+
+	for start := 0; start < total; start += reindexBatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		end := start + reindexBatchSize
+		if end > total {
+			end = total
+		}
+
+		docs := make([]*models.ConversationDocument, 0, end-start)
+		for _, conv := range conversations[start:end] {
+			docs = append(docs, conv.ToESDocument())
+		}
+
+		if err := i.indexer.BulkIndexConversations(docs); err != nil {
+			return fmt.Errorf("failed to bulk index conversations: %w", err)
+		}
+
+		if progress != nil {
+			progress(end, total)
+		}
+	}
+
+	return nil
+}
+
+// PrepareForBulk 在全量写入前延长 conversation 索引的刷新间隔、关闭副本，并记录原设置。
+// 期间 wait_for 写入最多等待一个刷新间隔（见 bulkSettings），API 服务可以继续运行
+func (i *Initializer) PrepareForBulk(ctx context.Context) error {
+	indexName := i.client.GetConfig().Index.Conversations
+
+	names := make([]string, 0, len(bulkSettings))
+	for name := range bulkSettings {
+		names = append(names, name)
+	}
+	current, err := i.client.GetIndexSettings(ctx, indexName, names...)
+	if err != nil {
+		return fmt.Errorf("failed to read index settings: %w", err)
+	}
+
+	saved := make(map[string]interface{}, len(current))
+	for name, value := range current {
+		saved[name] = value
+	}
+
+	if err := i.client.UpdateIndexSettings(ctx, indexName, bulkSettings); err != nil {
+		return fmt.Errorf("failed to apply bulk settings: %w", err)
+	}
+
+	i.savedSettings = saved
+	return nil
+}
+
+// FinishBulk 恢复 PrepareForBulk 记录的索引设置，并强制刷新使写入的数据可搜索
+func (i *Initializer) FinishBulk(ctx context.Context) error {
+	indexName := i.client.GetConfig().Index.Conversations
+
+	if len(i.savedSettings) > 0 {
+		if err := i.client.UpdateIndexSettings(ctx, indexName, i.savedSettings); err != nil {
+			return fmt.Errorf("failed to restore index settings: %w", err)
+		}
+		i.savedSettings = nil
+	}
+
+	if err := i.client.RefreshIndex(ctx, indexName); err != nil {
+		return fmt.Errorf("failed to refresh index: %w", err)
+	}
+
+	return nil
+}
+
+// WithBulkSettings 在 PrepareForBulk 和 FinishBulk 之间执行 fn，fn 失败时也会恢复索引设置
+func (i *Initializer) WithBulkSettings(ctx context.Context, fn func() error) error {
+	if err := i.PrepareForBulk(ctx); err != nil {
+		return err
+	}
+
+	err := fn()
+	if finishErr := i.FinishBulk(ctx); finishErr != nil {
+		if err != nil {
+			return fmt.Errorf("%w (also failed to finish bulk: %v)", err, finishErr)
+		}
+		return finishErr
+	}
+
+	return err
+}
+
 // GetIndexStatus 获取索引状态信息
 func (i *Initializer) GetIndexStatus(ctx context.Context) (map[string]interface{}, error) {
 	cfg := i.client.GetConfig()
@@ -135,9 +272,45 @@ func (i *Initializer) GetIndexStatus(ctx context.Context) (map[string]interface{
 	return status, nil
 }
 
-// ConversationMapping 返回 conversation 索引的映射定义
+// MappingOptions 控制生成的索引映射
+type MappingOptions struct {
+	// SourceAnalyzer 是 source_title、source_content 等原文字段使用的分析器，为空时与
+	// 规范化字段相同（standard）。原文多为中文、日文时可使用 cjk 等语言相关的分析器
+	SourceAnalyzer string
+	// ExactAnalyzer 是 exact 子字段使用的分析器，为空时使用 DefaultExactAnalyzer。
+	// exact 子字段只用于 phrase 查询，分析器需要保留词序
+	ExactAnalyzer string
+}
+
+// DefaultExactAnalyzer exact 子字段的默认分析器：standard 分词加小写，不去停用词，
+// 关键词作为短语出现在字段中任意位置即可命中，中日韩文按单字切分后要求连续出现
+const DefaultExactAnalyzer = "exact_phrase"
+
+// sourceAnalyzer 返回原文字段的分析器名称
+func (o MappingOptions) sourceAnalyzer() string {
+	if o.SourceAnalyzer == "" {
+		return "standard"
+	}
+	return o.SourceAnalyzer
+}
+
+// exactAnalyzer 返回 exact 子字段的分析器名称
+func (o MappingOptions) exactAnalyzer() string {
+	if o.ExactAnalyzer == "" {
+		return DefaultExactAnalyzer
+	}
+	return o.ExactAnalyzer
+}
+
+// ConversationMapping 返回 conversation 索引的默认映射定义
 func ConversationMapping() string {
-	return `{
+	return ConversationMappingWithOptions(MappingOptions{})
+}
+
+// ConversationMappingWithOptions 返回 conversation 索引的映射定义，原文字段使用 opts.SourceAnalyzer，
+// exact 子字段使用 opts.ExactAnalyzer
+func ConversationMappingWithOptions(opts MappingOptions) string {
+	return fmt.Sprintf(`{
 		"mappings": {
 			"properties": {
 				"id": {
@@ -155,7 +328,7 @@ func ConversationMapping() string {
 						},
 						"exact": {
 							"type": "text",
-							"analyzer": "keyword"
+							"analyzer": %[2]q
 						}
 					}
 				},
@@ -170,11 +343,11 @@ func ConversationMapping() string {
 				},
 				"source_title": {
 					"type": "text",
-					"analyzer": "standard",
+					"analyzer": %[1]q,
 					"fields": {
 						"exact": {
 							"type": "text",
-							"analyzer": "keyword"
+							"analyzer": %[2]q
 						}
 					}
 				},
@@ -184,6 +357,22 @@ func ConversationMapping() string {
 				"updated_at": {
 					"type": "date"
 				},
+				"last_message_at": {
+					"type": "date"
+				},
+				"message_count": {
+					"type": "integer"
+				},
+				"content_hash": {
+					"type": "keyword",
+					"index": false
+				},
+				"metadata": {
+					"type": "flattened"
+				},
+				"messages_truncated": {
+					"type": "boolean"
+				},
 				"messages": {
 					"type": "nested",
 					"properties": {
@@ -202,7 +391,7 @@ func ConversationMapping() string {
 							"fields": {
 								"exact": {
 									"type": "text",
-									"analyzer": "keyword"
+									"analyzer": %[2]q
 								}
 							}
 						},
@@ -211,14 +400,20 @@ func ConversationMapping() string {
 						},
 						"source_content": {
 							"type": "text",
-							"analyzer": "standard",
+							"analyzer": %[1]q,
 							"fields": {
 								"exact": {
 									"type": "text",
-									"analyzer": "keyword"
+									"analyzer": %[2]q
 								}
 							}
 						},
+						"content_truncated": {
+							"type": "boolean"
+						},
+						"sequence": {
+							"type": "integer"
+						},
 						"created_at": {
 							"type": "date"
 						},
@@ -242,7 +437,7 @@ func ConversationMapping() string {
 								},
 								"exact": {
 									"type": "text",
-									"analyzer": "keyword"
+									"analyzer": %[2]q
 								}
 							}
 						},
@@ -264,16 +459,27 @@ func ConversationMapping() string {
 					"standard": {
 						"type": "standard",
 						"stopwords": "_english_"
+					},
+					%[3]q: {
+						"type": "custom",
+						"tokenizer": "standard",
+						"filter": ["lowercase"]
 					}
 				}
 			}
 		}
-	}`
+	}`, opts.sourceAnalyzer(), opts.exactAnalyzer(), DefaultExactAnalyzer)
 }
 
-// MessageMapping 返回 message 索引的映射定义（独立索引方案）
+// MessageMapping 返回 message 索引的默认映射定义（独立索引方案），每条消息一个文档
 func MessageMapping() string {
-	return `{
+	return MessageMappingWithOptions(MappingOptions{})
+}
+
+// MessageMappingWithOptions 返回 message 索引的映射定义，原文字段使用 opts.SourceAnalyzer，
+// exact 子字段使用 opts.ExactAnalyzer。search.message_index_mode 为 dual 或 flat 时写入该索引
+func MessageMappingWithOptions(opts MappingOptions) string {
+	return fmt.Sprintf(`{
 		"mappings": {
 			"properties": {
 				"id": {
@@ -282,19 +488,52 @@ func MessageMapping() string {
 				"conversation_id": {
 					"type": "keyword"
 				},
+				"user_id": {
+					"type": "keyword"
+				},
+				"provider": {
+					"type": "keyword"
+				},
+				"conversation_created_at": {
+					"type": "date"
+				},
+				"tag_ids": {
+					"type": "keyword"
+				},
 				"role": {
 					"type": "keyword"
 				},
 				"content": {
 					"type": "text",
-					"analyzer": "standard"
+					"analyzer": "standard",
+					"fields": {
+						"exact": {
+							"type": "text",
+							"analyzer": %[2]q
+						}
+					}
 				},
 				"source_id": {
 					"type": "keyword"
 				},
 				"source_content": {
 					"type": "text",
-					"analyzer": "standard"
+					"analyzer": %[1]q,
+					"fields": {
+						"exact": {
+							"type": "text",
+							"analyzer": %[2]q
+						}
+					}
+				},
+				"content_truncated": {
+					"type": "boolean"
+				},
+				"sequence": {
+					"type": "integer"
+				},
+				"index_generation": {
+					"type": "long"
 				},
 				"created_at": {
 					"type": "date"
@@ -312,9 +551,14 @@ func MessageMapping() string {
 					"standard": {
 						"type": "standard",
 						"stopwords": "_english_"
+					},
+					%[3]q: {
+						"type": "custom",
+						"tokenizer": "standard",
+						"filter": ["lowercase"]
 					}
 				}
 			}
 		}
-	}`
+	}`, opts.sourceAnalyzer(), opts.exactAnalyzer(), DefaultExactAnalyzer)
 }
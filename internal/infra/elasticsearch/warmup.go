@@ -0,0 +1,107 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/logger"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"go.uber.org/zap"
+)
+
+// warmUpQuery 不返回文档的轻量查询，只用于建立连接和加载索引缓存
+const warmUpQuery = `{"size":0,"query":{"match_all":{}}}`
+
+// WarmUp primes the HTTP connections and the caches of the conversation index before
+// the server accepts requests, so the first searches after a deploy are not slow.
+// It implements server.Component; failures are logged and never stop the server
+type WarmUp struct {
+	client    *Client
+	indexName string
+	timeout   time.Duration
+}
+
+// NewWarmUp creates a warm-up of indexName bounded by timeout (0 means no bound)
+func NewWarmUp(client *Client, indexName string, timeout time.Duration) *WarmUp {
+	return &WarmUp{
+		client:    client,
+		indexName: indexName,
+		timeout:   timeout,
+	}
+}
+
+// NewWarmUpFromConfig creates the warm-up of the conversation index configured by
+// elasticsearch.warm_up, or returns nil when it is disabled
+func NewWarmUpFromConfig(client *Client, cfg *config.Config) *WarmUp {
+	if !cfg.Elasticsearch.WarmUp.Enabled {
+		return nil
+	}
+	return NewWarmUp(client, cfg.Elasticsearch.Index.Conversations, cfg.Elasticsearch.WarmUp.Timeout)
+}
+
+// Start pings Elasticsearch and runs a match_all search with size 0 on the index,
+// logging how long it took. It always returns nil
+func (w *WarmUp) Start(ctx context.Context) error {
+	log := logger.GetLogger()
+
+	// 降级启动时 ES 不可用，预热只会等到超时
+	if !w.client.Available() {
+		log.Warn("Skipping Elasticsearch warm-up, Elasticsearch is unavailable")
+		return nil
+	}
+
+	if w.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.timeout)
+		defer cancel()
+	}
+
+	started := time.Now()
+	if err := w.run(ctx); err != nil {
+		log.Warn("Elasticsearch warm-up failed",
+			zap.String("index", w.indexName),
+			zap.Duration("duration", time.Since(started)),
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	log.Info("Elasticsearch warm-up completed",
+		zap.String("index", w.indexName),
+		zap.Duration("duration", time.Since(started)),
+	)
+	return nil
+}
+
+// Stop implements server.Component; there is nothing to release
+func (w *WarmUp) Stop(ctx context.Context) error {
+	return nil
+}
+
+func (w *WarmUp) run(ctx context.Context) error {
+	if err := w.client.Ping(ctx); err != nil {
+		return err
+	}
+
+	requestCache := true
+	req := esapi.SearchRequest{
+		Index:        []string{w.indexName},
+		Body:         strings.NewReader(warmUpQuery),
+		RequestCache: &requestCache,
+	}
+
+	res, err := req.Do(ctx, w.client.es)
+	if err != nil {
+		return fmt.Errorf("warm-up search failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("warm-up search failed with status: %s", res.Status())
+	}
+	return nil
+}
@@ -6,8 +6,10 @@ import (
 
 // ElasticsearchSet provides all Elasticsearch-related dependencies
 var ElasticsearchSet = wire.NewSet(
-	NewElasticsearchClientFromConfig,
+	NewElasticsearchClientWithRetry,
 	NewElasticsearchIndexerFromClient,
+	NewSearchRepositoryFromClient,
 	NewElasticsearchClient,
 	NewElasticsearchIndexName,
+	NewInitializerWithRepository,
 )
@@ -0,0 +1,235 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/logger"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// State represents the lifecycle state of a job
+type State string
+
+const (
+	StatePending State = "pending"
+	StateRunning State = "running"
+	StateDone    State = "done"
+	StateFailed  State = "failed"
+)
+
+// Func is the work executed by a job; it may report progress through the job
+type Func func(ctx context.Context, job *Job) error
+
+// Job tracks the state and progress of a background task
+type Job struct {
+	mu sync.RWMutex
+
+	ID         string
+	State      State
+	Processed  int
+	Total      int
+	Error      string
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// SetProgress updates the processed/total counters
+func (j *Job) SetProgress(processed, total int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.Processed = processed
+	j.Total = total
+}
+
+// Finished reports whether the job has reached a terminal state
+func (j *Job) Finished() bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	return j.State == StateDone || j.State == StateFailed
+}
+
+// snapshot returns a copy of the job that is safe to read without locking
+func (j *Job) snapshot() *Job {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	return &Job{
+		ID:         j.ID,
+		State:      j.State,
+		Processed:  j.Processed,
+		Total:      j.Total,
+		Error:      j.Error,
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+	}
+}
+
+func (j *Job) setState(state State, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now().UTC()
+	j.State = state
+	switch state {
+	case StateRunning:
+		j.StartedAt = &now
+	case StateDone, StateFailed:
+		j.FinishedAt = &now
+	}
+	if err != nil {
+		j.Error = err.Error()
+	}
+}
+
+// Manager runs jobs in the background and keeps their status in memory.
+// Finished jobs are expired after ttl, and at most maxHistory finished jobs are kept.
+type Manager struct {
+	mu         sync.Mutex
+	jobs       map[string]*Job
+	maxHistory int
+	ttl        time.Duration
+	logger     *zap.Logger
+
+	// running 跟踪执行中的任务，ctx 在 Stop 超时后取消，通知任务尽快退出
+	running sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewManager creates a new job manager
+func NewManager(maxHistory int, ttl time.Duration) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		jobs:       make(map[string]*Job),
+		maxHistory: maxHistory,
+		ttl:        ttl,
+		logger:     logger.GetLogger(),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// NewManagerFromConfig creates a job manager from application configuration
+func NewManagerFromConfig(cfg *config.Config) *Manager {
+	return NewManager(cfg.Jobs.MaxHistory, cfg.Jobs.TTL)
+}
+
+// Submit schedules fn to run in the background and returns the job ID
+func (m *Manager) Submit(fn Func) string {
+	job := &Job{
+		ID:        uuid.New().String(),
+		State:     StatePending,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	m.mu.Lock()
+	m.pruneLocked()
+	m.jobs[job.ID] = job
+	m.mu.Unlock()
+
+	m.running.Add(1)
+	go m.run(job, fn)
+
+	return job.ID
+}
+
+// Start implements server.Component; jobs are started on Submit so there is nothing to do
+func (m *Manager) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop waits for running jobs to finish. If ctx is done first, the jobs' context is
+// cancelled and ctx.Err() is returned
+func (m *Manager) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		m.cancel()
+		return fmt.Errorf("jobs still running at shutdown: %w", ctx.Err())
+	}
+}
+
+// Get returns a snapshot of the job with the given ID
+func (m *Manager) Get(jobID string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pruneLocked()
+	job, ok := m.jobs[jobID]
+	if !ok {
+		return nil, false
+	}
+	return job.snapshot(), true
+}
+
+// run executes fn and records the outcome, converting panics into failures
+func (m *Manager) run(job *Job, fn Func) {
+	defer m.running.Done()
+	job.setState(StateRunning, nil)
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		return fn(m.ctx, job)
+	}()
+
+	if err != nil {
+		job.setState(StateFailed, err)
+		m.logger.Error("Background job failed", zap.String("job_id", job.ID), zap.Error(err))
+		return
+	}
+
+	job.setState(StateDone, nil)
+}
+
+// pruneLocked drops expired finished jobs and trims history to maxHistory.
+// 调用方需持有 m.mu
+func (m *Manager) pruneLocked() {
+	now := time.Now().UTC()
+	var finished []*Job
+
+	for id, job := range m.jobs {
+		snap := job.snapshot()
+		if snap.FinishedAt == nil {
+			continue
+		}
+		if m.ttl > 0 && now.Sub(*snap.FinishedAt) > m.ttl {
+			delete(m.jobs, id)
+			continue
+		}
+		finished = append(finished, snap)
+	}
+
+	if m.maxHistory <= 0 || len(finished) <= m.maxHistory {
+		return
+	}
+
+	// 优先淘汰最早结束的任务
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.Before(*finished[j].FinishedAt)
+	})
+	for _, job := range finished[:len(finished)-m.maxHistory] {
+		delete(m.jobs, job.ID)
+	}
+}
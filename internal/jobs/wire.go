@@ -0,0 +1,10 @@
+package jobs
+
+import (
+	"github.com/google/wire"
+)
+
+// JobsSet provides the background job manager
+var JobsSet = wire.NewSet(
+	NewManagerFromConfig,
+)
@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"context"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -61,5 +63,31 @@ func Sync() {
 
 // WithRequestID creates a logger with request ID
 func WithRequestID(requestID string) *zap.Logger {
-	return Logger.With(zap.String("request_id", requestID))
+	return GetLogger().With(zap.String("request_id", requestID))
+}
+
+// requestIDKey is the context key holding the request ID
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying requestID, read by FromContext
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or "" when there is none
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// FromContext returns the global logger with the request_id field attached when ctx
+// carries a request ID, so service and repository logs can be matched to a request
+func FromContext(ctx context.Context) *zap.Logger {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		return WithRequestID(requestID)
+	}
+	return GetLogger()
 }
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"chat-assistant-backend/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenHeader is the header carrying the admin API token
+const AdminTokenHeader = "X-Admin-Token"
+
+// adminContextKey marks requests carrying a valid admin token, see AdminIdentityMiddleware
+const adminContextKey = "is_admin"
+
+// AdminAuthMiddleware restricts access to requests carrying the configured admin token.
+// Admin routes are disabled entirely when no token is configured.
+func AdminAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			response.Forbidden(c, "ADMIN_DISABLED", "Admin API is disabled", "Set admin.token to enable admin endpoints")
+			c.Abort()
+			return
+		}
+
+		if !validAdminToken(c, token) {
+			response.Unauthorized(c, "UNAUTHORIZED", "Unauthorized", "A valid "+AdminTokenHeader+" header is required")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// AdminIdentityMiddleware marks requests carrying a valid admin token without rejecting
+// the others, for routes that are public but grant admins more (see IsAdmin)
+func AdminIdentityMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token != "" && validAdminToken(c, token) {
+			c.Set(adminContextKey, true)
+		}
+		c.Next()
+	}
+}
+
+// IsAdmin reports whether AdminIdentityMiddleware accepted the request's admin token
+func IsAdmin(c *gin.Context) bool {
+	return c.GetBool(adminContextKey)
+}
+
+func validAdminToken(c *gin.Context, token string) bool {
+	provided := c.GetHeader(AdminTokenHeader)
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"chat-assistant-backend/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLimitMiddleware limits the request body to maxBytes and responds with
+// 413 Request Entity Too Large when the limit is exceeded
+func BodyLimitMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		// 声明的长度已超出限制，直接拒绝
+		if c.Request.ContentLength > maxBytes {
+			abortBodyTooLarge(c, maxBytes)
+			return
+		}
+
+		// 未声明长度（如 chunked）时按限制读取，确保超限请求在进入 handler 前被拒绝
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				abortBodyTooLarge(c, maxBytes)
+				return
+			}
+			response.BadRequest(c, "INVALID_REQUEST", "Failed to read request body", err.Error())
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
+
+func abortBodyTooLarge(c *gin.Context, maxBytes int64) {
+	response.RequestEntityTooLarge(c, "REQUEST_BODY_TOO_LARGE", "Request body too large",
+		fmt.Sprintf("Request body must not exceed %d bytes", maxBytes))
+	c.Abort()
+}
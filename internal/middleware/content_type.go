@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+
+	"chat-assistant-backend/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireJSON rejects POST, PUT and PATCH requests whose Content-Type is not
+// application/json (parameters such as charset are allowed) with 415 Unsupported
+// Media Type. Other methods and requests without a body pass through, so endpoints
+// with an optional body keep working when it is omitted
+func RequireJSON() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength == 0 {
+			c.Next()
+			return
+		}
+
+		contentType := c.GetHeader("Content-Type")
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || mediaType != "application/json" {
+			response.UnsupportedMediaType(c, "UNSUPPORTED_MEDIA_TYPE", "Unsupported media type",
+				"Content-Type must be application/json, got "+quoteContentType(contentType))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func quoteContentType(contentType string) string {
+	if contentType == "" {
+		return "none"
+	}
+	return `"` + contentType + `"`
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// I18nMiddleware negotiates the response language from Accept-Language
+func I18nMiddleware(cfg config.I18nConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := i18n.Negotiate(c.GetHeader("Accept-Language"), cfg.SupportedLanguages, cfg.DefaultLanguage)
+
+		c.Header("Content-Language", lang)
+		c.Set(i18n.ContextKey, lang)
+		c.Next()
+	}
+}
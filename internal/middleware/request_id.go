@@ -1,11 +1,14 @@
 package middleware
 
 import (
+	"chat-assistant-backend/internal/logger"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-// RequestIDMiddleware adds request ID to each request
+// RequestIDMiddleware adds request ID to each request. The ID is also stored in the
+// request context so services can log it via logger.FromContext
 func RequestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestID := c.GetHeader("X-Request-ID")
@@ -15,6 +18,7 @@ func RequestIDMiddleware() gin.HandlerFunc {
 
 		c.Header("X-Request-ID", requestID)
 		c.Set("request_id", requestID)
+		c.Request = c.Request.WithContext(logger.ContextWithRequestID(c.Request.Context(), requestID))
 		c.Next()
 	}
 }
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Create message_attachments table for file references imported with messages
+CREATE TABLE message_attachments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    message_id UUID NOT NULL,
+    type VARCHAR(100) NOT NULL,
+    name VARCHAR(500),
+    url_or_ref TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+);
+-- Create indexes for better query performance
+CREATE INDEX idx_message_attachments_message_id ON message_attachments(message_id);
+CREATE INDEX idx_message_attachments_deleted_at ON message_attachments(deleted_at);
+-- Create trigger for automatic timestamp updates
+CREATE TRIGGER update_message_attachments_updated_at BEFORE
+UPDATE ON message_attachments FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- Add table and column comments
+COMMENT ON TABLE message_attachments IS '消息附件引用表，保存导入数据中的附件和文件信息';
+COMMENT ON COLUMN message_attachments.message_id IS '消息ID';
+COMMENT ON COLUMN message_attachments.type IS '附件类型，如 pdf、image/png';
+COMMENT ON COLUMN message_attachments.name IS '文件名';
+COMMENT ON COLUMN message_attachments.url_or_ref IS '原平台中的文件地址或引用ID';
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+-- Drop message_attachments table
+DROP TABLE IF EXISTS message_attachments CASCADE;
+-- +goose StatementEnd
\ No newline at end of file
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add denormalized last activity time to conversations table
+ALTER TABLE conversations
+ADD COLUMN last_message_at TIMESTAMP WITH TIME ZONE;
+-- Backfill from existing messages; the updated_at trigger is disabled so the
+-- backfill does not mark every conversation as recently modified
+ALTER TABLE conversations DISABLE TRIGGER update_conversations_updated_at;
+UPDATE conversations c
+SET last_message_at = (
+        SELECT max(m.created_at)
+        FROM messages m
+        WHERE m.conversation_id = c.id
+            AND m.deleted_at IS NULL
+    );
+ALTER TABLE conversations ENABLE TRIGGER update_conversations_updated_at;
+-- Create index for sorting by recent activity
+CREATE INDEX idx_conversations_user_id_last_message_at ON conversations(user_id, last_message_at DESC NULLS LAST);
+-- Add column comment
+COMMENT ON COLUMN conversations.last_message_at IS '最后一条消息的创建时间，用于按最近活跃排序';
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+-- Remove last_message_at field from conversations table
+DROP INDEX IF EXISTS idx_conversations_user_id_last_message_at;
+ALTER TABLE conversations DROP COLUMN IF EXISTS last_message_at;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Create audit_logs table recording destructive operations
+CREATE TABLE audit_logs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor_user_id UUID,
+    action VARCHAR(100) NOT NULL,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id UUID NOT NULL,
+    details TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+-- Create indexes for filtering audit logs
+CREATE INDEX idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX idx_audit_logs_resource ON audit_logs(resource_type, resource_id);
+CREATE INDEX idx_audit_logs_created_at ON audit_logs(created_at);
+-- Add table and column comments
+COMMENT ON TABLE audit_logs IS '审计日志表，记录删除等破坏性操作，只追加不修改';
+COMMENT ON COLUMN audit_logs.actor_user_id IS '操作者用户ID，未知时为空';
+COMMENT ON COLUMN audit_logs.action IS '操作，如 conversation.delete';
+COMMENT ON COLUMN audit_logs.resource_type IS '资源类型，如 conversation、message、tag';
+COMMENT ON COLUMN audit_logs.resource_id IS '资源ID';
+COMMENT ON COLUMN audit_logs.details IS '操作详情，JSON 格式';
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+-- Drop audit_logs table
+DROP TABLE IF EXISTS audit_logs CASCADE;
+-- +goose StatementEnd
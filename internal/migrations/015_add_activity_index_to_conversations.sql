@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Create index for listing a user's recently active conversations; the expression
+-- matches the ORDER BY of ConversationRepository.GetRecentByUserID
+CREATE INDEX idx_conversations_user_id_activity ON conversations(
+    user_id,
+    (COALESCE(last_message_at, updated_at)) DESC,
+    id DESC
+)
+WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+-- Drop recent activity index
+DROP INDEX IF EXISTS idx_conversations_user_id_activity;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add position of a message within its conversation; messages with equal
+-- timestamps (common in imports without timestamps) are ordered by it
+ALTER TABLE messages
+ADD COLUMN sequence INTEGER NOT NULL DEFAULT 0;
+-- Backfill by the previous ordering (created_at, id); the updated_at trigger is
+-- disabled so the backfill does not mark every message as modified
+ALTER TABLE messages DISABLE TRIGGER update_messages_updated_at;
+UPDATE messages m
+SET sequence = ordered.rn
+FROM (
+        SELECT id,
+            ROW_NUMBER() OVER (
+                PARTITION BY conversation_id
+                ORDER BY created_at ASC, id ASC
+            ) AS rn
+        FROM messages
+    ) ordered
+WHERE m.id = ordered.id;
+ALTER TABLE messages ENABLE TRIGGER update_messages_updated_at;
+-- Create index matching the ORDER BY of MessageRepository.GetByConversationID
+CREATE INDEX idx_messages_conversation_id_sequence ON messages(conversation_id, sequence, created_at, id)
+WHERE deleted_at IS NULL;
+-- Add column comment
+COMMENT ON COLUMN messages.sequence IS '消息在对话中的位置，从 1 开始，时间相同的消息按它排序';
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+-- Remove sequence field from messages table
+DROP INDEX IF EXISTS idx_messages_conversation_id_sequence;
+ALTER TABLE messages DROP COLUMN IF EXISTS sequence;
+-- +goose StatementEnd
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	AuditActionConversationDelete = "conversation.delete"
+	AuditActionMessageDelete      = "message.delete"
+	AuditActionTagDelete          = "tag.delete"
+	// AuditActionUserConversationsDelete 删除用户的全部对话，resource_id 为用户 ID
+	AuditActionUserConversationsDelete = "user.conversations.delete"
+	// AuditActionConversationMerge 合并对话，resource_id 为目标对话 ID，来源对话被删除
+	AuditActionConversationMerge = "conversation.merge"
+)
+
+// Audit resource types
+const (
+	AuditResourceConversation = "conversation"
+	AuditResourceMessage      = "message"
+	AuditResourceTag          = "tag"
+	AuditResourceUser         = "user"
+)
+
+// AuditLog records a destructive operation. Audit logs are append-only,
+// so unlike other models they have no updated_at or soft delete
+type AuditLog struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ActorUserID  *uuid.UUID `gorm:"type:uuid" json:"actor_user_id"`                 // 操作者，接口暂无认证时为空
+	Action       string     `gorm:"type:varchar(100);not null;index" json:"action"` // 如 conversation.delete
+	ResourceType string     `gorm:"type:varchar(50);not null" json:"resource_type"`
+	ResourceID   uuid.UUID  `gorm:"type:uuid;not null" json:"resource_id"`
+	Details      string     `gorm:"type:text" json:"details"` // 操作详情，JSON 序列化
+	CreatedAt    time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// BeforeCreate is a GORM hook that generates UUID before creating
+func (a *AuditLog) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for the AuditLog model
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
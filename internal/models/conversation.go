@@ -1,6 +1,12 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // Conversation represents a chat conversation
 type Conversation struct {
@@ -11,9 +17,97 @@ type Conversation struct {
 	Model       string    `gorm:"type:varchar(50)" json:"model"`                     // gpt-4, gemini-pro, llama-3 等
 	SourceID    string    `gorm:"type:varchar(255);not null;index" json:"source_id"` // 原始数据中的ID，用于关联导入内容
 	SourceTitle string    `gorm:"type:varchar(500);not null" json:"source_title"`
-	Metadata    string    `gorm:"type:text" json:"metadata"` // 可选元信息
-	Messages    []Message `gorm:"foreignKey:ConversationID" json:"messages,omitempty"`
-	Tags        []Tag     `gorm:"many2many:conversation_tags;" json:"tags,omitempty"`
+	Metadata    string    `gorm:"type:text" json:"metadata"` // 可选元信息，ConversationMetadata 的 JSON 序列化
+	// LastMessageAt 最后一条消息的创建时间（冗余字段），在消息导入、删除时维护，用于按最近活跃排序
+	LastMessageAt *time.Time `gorm:"index" json:"last_message_at"`
+	Messages      []Message  `gorm:"foreignKey:ConversationID" json:"messages,omitempty"`
+	Tags          []Tag      `gorm:"many2many:conversation_tags;" json:"tags,omitempty"`
+}
+
+// ProviderModelCount is the number of conversations for a provider/model pair
+type ProviderModelCount struct {
+	Provider string
+	Model    string
+	Count    int64
+}
+
+// ConversationMetadata 是对话级别的结构化元信息
+type ConversationMetadata struct {
+	Project string `json:"project,omitempty"`
+	Account string `json:"account,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	TagsRaw string `json:"tags_raw,omitempty"`
+	// ImportPolicy 导入时对话消息数超过上限所采用的处理方式（truncate 或 split），未超限时为空
+	ImportPolicy string `json:"import_policy,omitempty"`
+	// ImportNote 超限处理的说明，如保留了哪些消息、属于拆分后的第几部分
+	ImportNote string `json:"import_note,omitempty"`
+}
+
+// ConversationMetadataKeys 元信息字段名，可通过 meta.<key> 搜索过滤
+var ConversationMetadataKeys = []string{"project", "account", "summary", "tags_raw", "import_policy", "import_note"}
+
+// IsConversationMetadataKey reports whether key is a known metadata field
+func IsConversationMetadataKey(key string) bool {
+	for _, k := range ConversationMetadataKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Fields returns the non-empty metadata fields keyed by name
+func (m *ConversationMetadata) Fields() map[string]string {
+	fields := make(map[string]string)
+	for key, value := range map[string]string{
+		"project":       m.Project,
+		"account":       m.Account,
+		"summary":       m.Summary,
+		"tags_raw":      m.TagsRaw,
+		"import_policy": m.ImportPolicy,
+		"import_note":   m.ImportNote,
+	} {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// NewConversationMetadata builds metadata from a field map, ignoring unknown keys
+func NewConversationMetadata(fields map[string]string) *ConversationMetadata {
+	return &ConversationMetadata{
+		Project:      fields["project"],
+		Account:      fields["account"],
+		Summary:      fields["summary"],
+		TagsRaw:      fields["tags_raw"],
+		ImportPolicy: fields["import_policy"],
+		ImportNote:   fields["import_note"],
+	}
+}
+
+// GetMetadata parses the stored metadata; returns nil when empty or not valid JSON
+func (c *Conversation) GetMetadata() *ConversationMetadata {
+	if c.Metadata == "" {
+		return nil
+	}
+
+	var meta ConversationMetadata
+	if err := json.Unmarshal([]byte(c.Metadata), &meta); err != nil {
+		return nil
+	}
+	return &meta
+}
+
+// SetMetadata serializes metadata into the Metadata column; empty metadata clears it
+func (c *Conversation) SetMetadata(meta *ConversationMetadata) {
+	if meta == nil || len(meta.Fields()) == 0 {
+		c.Metadata = ""
+		return
+	}
+
+	data, _ := json.Marshal(meta)
+	c.Metadata = string(data)
 }
 
 // TableName returns the table name for the Conversation model
@@ -21,26 +115,55 @@ func (Conversation) TableName() string {
 	return "conversations"
 }
 
+// ContentHash returns the content hash of the conversation's ES document, see
+// ConversationDocument.ComputeContentHash. Messages and tags must be preloaded
+func (c *Conversation) ContentHash() string {
+	return c.ToESDocument().ComputeContentHash()
+}
+
 // ToESDocument converts Conversation to ConversationDocument for Elasticsearch
 func (c *Conversation) ToESDocument() *ConversationDocument {
 	doc := &ConversationDocument{
-		ID:          c.ID,
-		UserID:      c.UserID,
-		Title:       c.Title,
-		Provider:    c.Provider,
-		Model:       c.Model,
-		SourceID:    c.SourceID,
-		SourceTitle: c.SourceTitle,
-		CreatedAt:   c.CreatedAt,
-		UpdatedAt:   c.UpdatedAt,
-		Messages:    []MessageDocument{},
-		Tags:        []TagDocument{},
-	}
-
-	// 如果有预加载的 Messages，转换它们
+		ID:            c.ID,
+		UserID:        c.UserID,
+		Title:         c.Title,
+		Provider:      c.Provider,
+		Model:         c.Model,
+		SourceID:      c.SourceID,
+		SourceTitle:   c.SourceTitle,
+		CreatedAt:     c.CreatedAt,
+		UpdatedAt:     c.UpdatedAt,
+		LastMessageAt: c.LastMessageAt,
+		Messages:      []MessageDocument{},
+		Tags:          []TagDocument{},
+	}
+
+	// 元信息以扁平的 key/value 形式索引
+	if meta := c.GetMetadata(); meta != nil {
+		if fields := meta.Fields(); len(fields) > 0 {
+			doc.Metadata = fields
+		}
+	}
+
+	// 如果有预加载的 Messages，转换它们。按对话内顺序排序，索引上限截断时保留的是最近的消息
 	if c.Messages != nil {
-		doc.Messages = make([]MessageDocument, len(c.Messages))
-		for i, msg := range c.Messages {
+		messages := make([]*Message, len(c.Messages))
+		for i := range c.Messages {
+			messages[i] = &c.Messages[i]
+		}
+		// sequence 和时间都相同时保持加载顺序（数据库查询已按 id 排序）
+		sort.SliceStable(messages, func(i, j int) bool {
+			a, b := messages[i], messages[j]
+			if a.Sequence != b.Sequence {
+				return a.Sequence < b.Sequence
+			}
+			return a.CreatedAt.Before(b.CreatedAt)
+		})
+
+		count := len(messages)
+		doc.MessageCount = &count
+		doc.Messages = make([]MessageDocument, len(messages))
+		for i, msg := range messages {
 			doc.Messages[i] = msg.ToESDocument()
 		}
 	}
@@ -1,7 +1,12 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sort"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
@@ -20,9 +25,78 @@ type ConversationDocument struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 
+	// 最后一条消息的创建时间，用于按最近活跃排序；没有消息时为空
+	LastMessageAt *time.Time `json:"last_message_at,omitempty"`
+
+	// MessageCount 数据库中的消息数（不受索引消息数上限影响）；为空表示未加载消息，
+	// 部分更新时保留索引中的原值
+	MessageCount *int `json:"message_count,omitempty"`
+
+	// 对话元信息（flattened 字段），见 ConversationMetadata
+	Metadata map[string]string `json:"metadata,omitempty"`
+
 	// 嵌套的 Messages 和 Tags
 	Messages []MessageDocument `json:"messages,omitempty"`
 	Tags     []TagDocument     `json:"tags,omitempty"`
+
+	// MessagesTruncated 表示消息数超过索引上限，只索引了最近的消息，完整消息以数据库为准
+	MessagesTruncated bool `json:"messages_truncated,omitempty"`
+
+	// ContentHash 索引时的内容哈希（见 ComputeContentHash），用于同步时判断文档是否需要更新
+	ContentHash string `json:"content_hash,omitempty"`
+}
+
+// ComputeContentHash returns a SHA-256 hex digest of the fields a sync needs to keep
+// in step with the database: title, provider, model, timestamps, metadata, tags and the
+// ordered messages. Timestamps are normalized to UTC at second precision, so the value
+// does not depend on the time zone or on the precision Elasticsearch and Postgres keep.
+// Must be computed before messages are truncated
+func (d *ConversationDocument) ComputeContentHash() string {
+	h := sha256.New()
+	// 每个字段后写入分隔符，避免相邻字段拼接后产生相同的输入
+	write := func(fields ...string) {
+		for _, field := range fields {
+			io.WriteString(h, field)
+			h.Write([]byte{0})
+		}
+	}
+
+	write(d.UserID.String(), d.Title, d.Provider, d.Model, d.SourceID, d.SourceTitle)
+	write(hashTime(d.CreatedAt), hashTime(d.UpdatedAt))
+	if d.LastMessageAt != nil {
+		write("last_message_at", hashTime(*d.LastMessageAt))
+	}
+
+	keys := make([]string, 0, len(d.Metadata))
+	for key := range d.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		write("metadata", key, d.Metadata[key])
+	}
+
+	// 标签顺序不影响内容，消息顺序影响
+	tags := make([]string, len(d.Tags))
+	for i, tag := range d.Tags {
+		tags[i] = tag.ID.String() + ":" + tag.Name
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		write("tag", tag)
+	}
+
+	for _, msg := range d.Messages {
+		write("message", msg.ID.String(), msg.Role, msg.SourceID, msg.Content, msg.SourceContent,
+			hashTime(msg.CreatedAt), hashTime(msg.UpdatedAt))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashTime 内容哈希中的时间，统一为 UTC 并截断到秒
+func hashTime(t time.Time) string {
+	return t.UTC().Truncate(time.Second).Format(time.RFC3339)
 }
 
 // MessageDocument 是 ES 中的消息文档
@@ -33,8 +107,127 @@ type MessageDocument struct {
 	Content        string    `json:"content"`
 	SourceID       string    `json:"source_id"`
 	SourceContent  string    `json:"source_content"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	// Sequence 消息在对话中的位置（见 Message.Sequence），0 表示未知
+	Sequence  int       `json:"sequence,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// ContentTruncated 表示 content/source_content 超过索引上限被截断，完整内容以数据库为准
+	ContentTruncated bool `json:"content_truncated,omitempty"`
+}
+
+// FlatMessageDocument 是独立消息索引中的消息文档，带上所属对话的 user_id 和可过滤的字段，
+// 按用户、provider、创建时间或标签搜索时不需要先关联对话
+type FlatMessageDocument struct {
+	MessageDocument
+	UserID uuid.UUID `json:"user_id"`
+	// Provider、ConversationCreatedAt、TagIDs 复制自所属对话，对话的标签变化时由索引器同步
+	Provider              string      `json:"provider"`
+	ConversationCreatedAt time.Time   `json:"conversation_created_at"`
+	TagIDs                []uuid.UUID `json:"tag_ids"`
+	// IndexGeneration 写入整个对话的消息时的批次标记（写入时间的纳秒数），写入后删除同一对话中
+	// 批次不同的旧消息；单条写入的消息为 0。使用数值类型，旧索引动态映射为 long 时查询仍然有效
+	IndexGeneration int64 `json:"index_generation,omitempty"`
+}
+
+// FlatMessage 返回 message 在独立消息索引中的文档，带上对话的 user_id 和可过滤的字段。
+// TagIDs 按字符串排序且不为 nil，便于与索引中的值比较
+func (d *ConversationDocument) FlatMessage(message MessageDocument) FlatMessageDocument {
+	return FlatMessageDocument{
+		MessageDocument:       message,
+		UserID:                d.UserID,
+		Provider:              d.Provider,
+		ConversationCreatedAt: d.CreatedAt,
+		TagIDs:                d.TagIDs(),
+	}
+}
+
+// TagIDs 对话标签的 ID，按字符串排序，没有标签时为空切片
+func (d *ConversationDocument) TagIDs() []uuid.UUID {
+	ids := make([]uuid.UUID, len(d.Tags))
+	for i, tag := range d.Tags {
+		ids[i] = tag.ID
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	return ids
+}
+
+// MessageDocumentLess reports whether a comes before b in a conversation, in the same
+// order as MessageLess: by sequence, then by creation time and id
+func MessageDocumentLess(a, b *MessageDocument) bool {
+	if a.Sequence != b.Sequence {
+		return a.Sequence < b.Sequence
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID.String() < b.ID.String()
+}
+
+// Truncate cuts content and source content to at most maxChars characters;
+// maxChars <= 0 disables the limit. Returns whether anything was cut
+func (d *MessageDocument) Truncate(maxChars int) bool {
+	if maxChars <= 0 {
+		return false
+	}
+
+	var contentCut, sourceCut bool
+	d.Content, contentCut = truncateChars(d.Content, maxChars)
+	d.SourceContent, sourceCut = truncateChars(d.SourceContent, maxChars)
+	if contentCut || sourceCut {
+		d.ContentTruncated = true
+	}
+	return d.ContentTruncated
+}
+
+// WithTruncatedMessages returns the document with every message truncated to maxChars
+// characters. The receiver is returned as is when nothing needs truncating, otherwise
+// a copy is made so the caller's document is left untouched
+func (d *ConversationDocument) WithTruncatedMessages(maxChars int) *ConversationDocument {
+	if maxChars <= 0 {
+		return d
+	}
+
+	var messages []MessageDocument
+	for i, msg := range d.Messages {
+		if !msg.Truncate(maxChars) {
+			continue
+		}
+		if messages == nil {
+			messages = append([]MessageDocument(nil), d.Messages...)
+		}
+		messages[i] = msg
+	}
+	if messages == nil {
+		return d
+	}
+
+	truncated := *d
+	truncated.Messages = messages
+	return &truncated
+}
+
+// WithRecentMessages returns the document keeping only its last maxMessages messages
+// (messages are in chronological order, see Conversation.ToESDocument) and flagged with
+// MessagesTruncated. maxMessages <= 0 disables the limit. The receiver is returned as
+// is when nothing needs dropping, otherwise a copy is made
+func (d *ConversationDocument) WithRecentMessages(maxMessages int) *ConversationDocument {
+	if maxMessages <= 0 || len(d.Messages) <= maxMessages {
+		return d
+	}
+
+	truncated := *d
+	truncated.Messages = append([]MessageDocument(nil), d.Messages[len(d.Messages)-maxMessages:]...)
+	truncated.MessagesTruncated = true
+	return &truncated
+}
+
+// truncateChars 按字符（而非字节）截断，避免切断多字节字符
+func truncateChars(s string, maxChars int) (string, bool) {
+	if utf8.RuneCountInString(s) <= maxChars {
+		return s, false
+	}
+	return string([]rune(s)[:maxChars]), true
 }
 
 // TagDocument 是 ES 中的标签文档
@@ -45,21 +238,40 @@ type TagDocument struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// SearchMeta 是 ES 搜索执行信息，用于性能排查
+type SearchMeta struct {
+	TookMs           int64 `json:"took_ms"`           // ES 自身耗时
+	TimedOut         bool  `json:"timed_out"`         // ES 是否超时
+	ShardsTotal      int   `json:"shards_total"`      // 分片总数
+	ShardsSuccessful int   `json:"shards_successful"` // 成功的分片数
+	ShardsSkipped    int   `json:"shards_skipped"`    // 跳过的分片数
+	ShardsFailed     int   `json:"shards_failed"`     // 失败的分片数（>0 时结果可能不完整）
+	PostProcessMs    int64 `json:"post_process_ms"`   // 服务端过滤、排序等后处理耗时
+	Cached           bool  `json:"cached,omitempty"`  // 结果来自搜索缓存，其余字段为首次查询时的值
+	// Fallback ES 不可用时由 Postgres 搜索返回的结果（search.fallback），没有相关性排序和高亮
+	Fallback bool `json:"fallback,omitempty"`
+}
+
 // 转换方法：从 ES 文档提取 Conversation 模型
 func (d *ConversationDocument) ToConversation() *Conversation {
-	return &Conversation{
+	conversation := &Conversation{
 		Base: Base{
 			ID:        d.ID,
 			CreatedAt: d.CreatedAt,
 			UpdatedAt: d.UpdatedAt,
 		},
-		UserID:      d.UserID,
-		Title:       d.Title,
-		Provider:    d.Provider,
-		Model:       d.Model,
-		SourceID:    d.SourceID,
-		SourceTitle: d.SourceTitle,
+		UserID:        d.UserID,
+		Title:         d.Title,
+		Provider:      d.Provider,
+		Model:         d.Model,
+		SourceID:      d.SourceID,
+		SourceTitle:   d.SourceTitle,
+		LastMessageAt: d.LastMessageAt,
+	}
+	if len(d.Metadata) > 0 {
+		conversation.SetMetadata(NewConversationMetadata(d.Metadata))
 	}
+	return conversation
 }
 
 // 转换方法：从 ES 文档提取 Messages 模型
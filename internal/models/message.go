@@ -1,6 +1,11 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
 
 // Message represents a message in a conversation
 type Message struct {
@@ -10,7 +15,10 @@ type Message struct {
 	Content        string    `gorm:"type:text" json:"content"`
 	SourceID       string    `gorm:"type:varchar(255);not null;index" json:"source_id"` // 原始数据中的ID，用于关联导入内容
 	SourceContent  string    `gorm:"type:text;not null" json:"source_content"`          // 原始数据中的内容，用于对比和调试
-	Metadata       string    `gorm:"type:text" json:"metadata"`                         // 可选元信息
+	Metadata       string    `gorm:"type:text" json:"metadata"`                         // 可选元信息，MessageMetadata 的 JSON 序列化
+	Sequence       int       `gorm:"not null;default:0" json:"sequence"`                // 消息在对话中的位置，从 1 开始；时间相同的消息按它排序
+
+	Attachments []MessageAttachment `gorm:"foreignKey:MessageID" json:"attachments,omitempty"`
 }
 
 // TableName returns the table name for the Message model
@@ -18,6 +26,90 @@ func (Message) TableName() string {
 	return "messages"
 }
 
+// MessageLess reports whether a comes before b in a conversation: by sequence, then by
+// creation time and id when the sequences are equal (e.g. both 0). 与数据库查询的
+// ORDER BY sequence, created_at, id 一致
+func MessageLess(a, b *Message) bool {
+	if a.Sequence != b.Sequence {
+		return a.Sequence < b.Sequence
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID.String() < b.ID.String()
+}
+
+// MessageMetadata 是消息级别的元信息
+type MessageMetadata struct {
+	// IndexTruncated 表示 ES 中只索引了截断后的内容，完整内容仍保存在数据库中
+	IndexTruncated bool `json:"index_truncated,omitempty"`
+	// ContentChars 截断前的内容字符数
+	ContentChars int `json:"content_chars,omitempty"`
+}
+
+// GetMetadata parses the stored metadata; returns nil when empty or not valid JSON
+func (m *Message) GetMetadata() *MessageMetadata {
+	if m.Metadata == "" {
+		return nil
+	}
+
+	var meta MessageMetadata
+	if err := json.Unmarshal([]byte(m.Metadata), &meta); err != nil {
+		return nil
+	}
+	return &meta
+}
+
+// SetMetadata serializes metadata into the Metadata column; empty metadata clears it
+func (m *Message) SetMetadata(meta *MessageMetadata) {
+	if meta == nil || *meta == (MessageMetadata{}) {
+		m.Metadata = ""
+		return
+	}
+
+	data, _ := json.Marshal(meta)
+	m.Metadata = string(data)
+}
+
+// MarkIndexTruncation records in the metadata whether the content exceeds maxChars and
+// will be truncated when indexed; maxChars <= 0 disables the limit
+func (m *Message) MarkIndexTruncation(maxChars int) bool {
+	chars := max(utf8.RuneCountInString(m.Content), utf8.RuneCountInString(m.SourceContent))
+	if maxChars <= 0 || chars <= maxChars {
+		return false
+	}
+
+	meta := m.GetMetadata()
+	if meta == nil {
+		meta = &MessageMetadata{}
+	}
+	meta.IndexTruncated = true
+	meta.ContentChars = chars
+	m.SetMetadata(meta)
+	return true
+}
+
+// MessageContext is a message with its neighbors in the same conversation, ordered by created_at
+type MessageContext struct {
+	Message *Message
+	Before  []*Message
+	After   []*Message
+}
+
+// MessageAttachment represents a file reference attached to a message
+type MessageAttachment struct {
+	Base
+	MessageID uuid.UUID `gorm:"type:uuid;not null;index" json:"message_id"`
+	Type      string    `gorm:"type:varchar(100);not null" json:"type"` // 附件类型，如 pdf、image/png
+	Name      string    `gorm:"type:varchar(500)" json:"name"`
+	URLOrRef  string    `gorm:"column:url_or_ref;type:text" json:"url_or_ref"` // 原平台中的文件地址或引用ID
+}
+
+// TableName returns the table name for the MessageAttachment model
+func (MessageAttachment) TableName() string {
+	return "message_attachments"
+}
+
 // ToESDocument converts Message to MessageDocument for Elasticsearch
 func (m *Message) ToESDocument() MessageDocument {
 	return MessageDocument{
@@ -27,6 +119,7 @@ func (m *Message) ToESDocument() MessageDocument {
 		Content:        m.Content,
 		SourceID:       m.SourceID,
 		SourceContent:  m.SourceContent,
+		Sequence:       m.Sequence,
 		CreatedAt:      m.CreatedAt,
 		UpdatedAt:      m.UpdatedAt,
 	}
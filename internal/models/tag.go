@@ -5,6 +5,12 @@ type Tag struct {
 	Name string `gorm:"type:varchar(500);not null" json:"name"`
 }
 
+// TagCount is a tag with the number of conversations it is attached to
+type TagCount struct {
+	Tag
+	Count int64
+}
+
 // TableName returns the table name for the Tag model
 func (Tag) TableName() string {
 	return "tags"
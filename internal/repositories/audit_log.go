@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"time"
+
+	"chat-assistant-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditLogFilter narrows down the audit logs returned by List; zero fields are ignored
+type AuditLogFilter struct {
+	Action       string
+	ResourceType string
+	ResourceID   *uuid.UUID
+	StartDate    *time.Time
+	EndDate      *time.Time
+}
+
+// AuditLogRepository defines the interface for audit log repository
+type AuditLogRepository interface {
+	Create(log *models.AuditLog) error
+	List(filter AuditLogFilter, page, limit int) ([]*models.AuditLog, int64, error)
+}
+
+// AuditLogRepositoryImpl handles audit log data access
+type AuditLogRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewAuditLogRepository creates a new audit log repository
+func NewAuditLogRepository(db *gorm.DB) AuditLogRepository {
+	return &AuditLogRepositoryImpl{
+		db: db,
+	}
+}
+
+// Create inserts an audit log
+func (r *AuditLogRepositoryImpl) Create(log *models.AuditLog) error {
+	return r.db.Create(log).Error
+}
+
+// List retrieves audit logs matching filter with pagination, newest first
+func (r *AuditLogRepositoryImpl) List(filter AuditLogFilter, page, limit int) ([]*models.AuditLog, int64, error) {
+	query := r.db.Model(&models.AuditLog{})
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != nil {
+		query = query.Where("resource_id = ?", *filter.ResourceID)
+	}
+	if filter.StartDate != nil {
+		query = query.Where("created_at >= ?", *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		query = query.Where("created_at <= ?", *filter.EndDate)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []*models.AuditLog
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&logs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
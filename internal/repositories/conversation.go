@@ -1,25 +1,57 @@
 package repositories
 
 import (
+	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"chat-assistant-backend/internal/models"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
 )
 
 // ConversationRepository defines the interface for conversation repository
 type ConversationRepository interface {
 	GetByID(id uuid.UUID) (*models.Conversation, error)
-	GetByUserID(userID uuid.UUID, page, limit int) ([]*models.Conversation, int64, error)
+	GetByIDWithMessages(id uuid.UUID, messageLimit int) (*models.Conversation, int64, error)
+	GetBySourceID(userID uuid.UUID, provider, sourceID string) (*models.Conversation, error)
+	GetByUserID(userID uuid.UUID, page, limit int, order string) ([]*models.Conversation, int64, error)
+	GetByUserIDWithPreview(userID uuid.UUID, page, limit int, order string) ([]*models.Conversation, map[uuid.UUID]*models.Message, int64, error)
+	GetByUserIDInRange(userID uuid.UUID, startDate, endDate *time.Time, page, limit int, order string) ([]*models.Conversation, int64, error)
+	GetByUserIDInRangeWithPreview(userID uuid.UUID, startDate, endDate *time.Time, page, limit int, order string) ([]*models.Conversation, map[uuid.UUID]*models.Message, int64, error)
+	GetRecentByUserID(userID uuid.UUID, limit int) ([]*models.Conversation, map[uuid.UUID]*models.Message, error)
+	GetByTagID(tagID uuid.UUID, page, limit int) ([]*models.Conversation, int64, error)
 	Create(conversation *models.Conversation) error
 	Update(conversation *models.Conversation) error
+	UpdateUserID(id uuid.UUID, userID uuid.UUID) (bool, error)
+	SourceIDExists(userID uuid.UUID, sourceID string, excludeID uuid.UUID) (bool, error)
+	Touch(id uuid.UUID, at time.Time) error
+	RefreshLastMessageAt(id uuid.UUID) (*time.Time, error)
+	Clone(id uuid.UUID, userID uuid.UUID) (*models.Conversation, error)
+	Merge(targetID uuid.UUID, sourceIDs []uuid.UUID, userID uuid.UUID) (*models.Conversation, error)
 	Delete(id uuid.UUID) error
+	DeleteByUserID(userID uuid.UUID, batchSize int) (int64, error)
 	FindAll() ([]*models.Conversation, error)
+	FindAllStream(batchSize int, fn func([]*models.Conversation) error) error
+	FindByUserIDStream(userID uuid.UUID, batchSize int, fn func([]*models.Conversation) error) error
+	FindByIDs(ids []uuid.UUID) ([]*models.Conversation, error)
+	FindBySourceIDs(userID uuid.UUID, sourceIDs []string) ([]*models.Conversation, error)
 	ReplaceTags(conversationID uuid.UUID, tagIDs []string) error
+	Patch(id uuid.UUID, updates map[string]interface{}, addTagIDs, removeTagIDs []string) error
+	CountByProviderModel(userID uuid.UUID) ([]*models.ProviderModelCount, error)
+	CountByTag(userID uuid.UUID) ([]*models.TagCount, error)
 }
 
+// Conversation list orders
+const (
+	OrderCreated  = "created"  // 按创建时间倒序（默认）
+	OrderActivity = "activity" // 按最后一条消息时间倒序，没有消息的对话排在最后
+)
+
 // ConversationRepositoryImpl handles conversation data access
 type ConversationRepositoryImpl struct {
 	db *gorm.DB
@@ -45,21 +77,79 @@ func (r *ConversationRepositoryImpl) GetByID(id uuid.UUID) (*models.Conversation
 	return &conversation, nil
 }
 
-// GetByUserID retrieves conversations by user ID with pagination
-func (r *ConversationRepositoryImpl) GetByUserID(userID uuid.UUID, page, limit int) ([]*models.Conversation, int64, error) {
+// GetBySourceID retrieves the user's conversation imported from provider with the given
+// source ID (the import key, see importer.Loader)
+func (r *ConversationRepositoryImpl) GetBySourceID(userID uuid.UUID, provider, sourceID string) (*models.Conversation, error) {
+	var conversation models.Conversation
+	err := r.db.Preload("Tags").
+		Where("user_id = ? AND provider = ? AND source_id = ?", userID, provider, sourceID).
+		First(&conversation).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// GetByIDWithMessages retrieves a conversation with its tags and the first messageLimit messages,
+// returning the total number of messages in the conversation
+func (r *ConversationRepositoryImpl) GetByIDWithMessages(id uuid.UUID, messageLimit int) (*models.Conversation, int64, error) {
+	var conversation models.Conversation
+	err := r.db.Preload("Tags").
+		Preload("Messages", func(db *gorm.DB) *gorm.DB {
+			return db.Order(messageOrder(MessageOrderAsc)).Limit(messageLimit)
+		}).
+		Preload("Messages.Attachments").
+		Where("id = ?", id).
+		First(&conversation).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := r.db.Model(&models.Message{}).Where("conversation_id = ?", id).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return &conversation, total, nil
+}
+
+// GetByUserID retrieves conversations by user ID with pagination, sorted by order
+func (r *ConversationRepositoryImpl) GetByUserID(userID uuid.UUID, page, limit int, order string) ([]*models.Conversation, int64, error) {
+	return r.GetByUserIDInRange(userID, nil, nil, page, limit, order)
+}
+
+// GetByUserIDInRange retrieves conversations by user ID with pagination, sorted by order.
+// startDate and endDate (both inclusive, nil for no bound) apply to the time the list is
+// ordered by: created_at, or the last message time (created_at without messages) for OrderActivity
+func (r *ConversationRepositoryImpl) GetByUserIDInRange(userID uuid.UUID, startDate, endDate *time.Time, page, limit int, order string) ([]*models.Conversation, int64, error) {
 	var conversations []*models.Conversation
 	var total int64
 
+	query := r.db.Model(&models.Conversation{}).Where("user_id = ?", userID)
+	column := rangeColumn(order)
+	if startDate != nil {
+		query = query.Where(column+" >= ?", *startDate)
+	}
+	if endDate != nil {
+		query = query.Where(column+" <= ?", *endDate)
+	}
+
 	// Count total conversations for this user
-	err := r.db.Model(&models.Conversation{}).Where("user_id = ?", userID).Count(&total).Error
+	err := query.Session(&gorm.Session{}).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get paginated conversations
 	offset := (page - 1) * limit
-	err = r.db.Preload("Tags").Where("user_id = ?", userID).
-		Order("created_at DESC").
+	err = query.Preload("Tags").
+		Order(listOrder(order)).
 		Offset(offset).
 		Limit(limit).
 		Find(&conversations).Error
@@ -70,6 +160,121 @@ func (r *ConversationRepositoryImpl) GetByUserID(userID uuid.UUID, page, limit i
 	return conversations, total, nil
 }
 
+// GetByTagID retrieves the conversations carrying a tag with pagination, newest first
+func (r *ConversationRepositoryImpl) GetByTagID(tagID uuid.UUID, page, limit int) ([]*models.Conversation, int64, error) {
+	var conversations []*models.Conversation
+	var total int64
+
+	// 通过 conversation_tags 关联表筛选带有该标签的对话
+	tagged := r.db.Table("conversation_tags").Select("conversation_id").Where("tag_id = ?", tagID)
+
+	err := r.db.Model(&models.Conversation{}).Where("id IN (?)", tagged).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err = r.db.Preload("Tags").Where("id IN (?)", tagged).
+		Order(listOrder(OrderCreated)).
+		Offset(offset).
+		Limit(limit).
+		Find(&conversations).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return conversations, total, nil
+}
+
+// GetByUserIDWithPreview retrieves conversations by user ID with pagination,
+// together with the latest message of each conversation
+func (r *ConversationRepositoryImpl) GetByUserIDWithPreview(userID uuid.UUID, page, limit int, order string) ([]*models.Conversation, map[uuid.UUID]*models.Message, int64, error) {
+	return r.GetByUserIDInRangeWithPreview(userID, nil, nil, page, limit, order)
+}
+
+// GetByUserIDInRangeWithPreview is GetByUserIDInRange together with the latest message
+// of each conversation
+func (r *ConversationRepositoryImpl) GetByUserIDInRangeWithPreview(userID uuid.UUID, startDate, endDate *time.Time, page, limit int, order string) ([]*models.Conversation, map[uuid.UUID]*models.Message, int64, error) {
+	conversations, total, err := r.GetByUserIDInRange(userID, startDate, endDate, page, limit, order)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	lastMessages, err := r.lastMessages(conversations)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return conversations, lastMessages, total, nil
+}
+
+// GetRecentByUserID retrieves a user's most recently active conversations across all
+// providers, ordered by last_message_at (updated_at for conversations without messages),
+// together with the latest message of each conversation
+func (r *ConversationRepositoryImpl) GetRecentByUserID(userID uuid.UUID, limit int) ([]*models.Conversation, map[uuid.UUID]*models.Message, error) {
+	var conversations []*models.Conversation
+	// 排序表达式与 idx_conversations_user_id_activity 一致，可以直接按索引顺序读取前 limit 条
+	err := r.db.Preload("Tags").Where("user_id = ?", userID).
+		Order("COALESCE(last_message_at, updated_at) DESC, id DESC").
+		Limit(limit).
+		Find(&conversations).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lastMessages, err := r.lastMessages(conversations)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return conversations, lastMessages, nil
+}
+
+// lastMessages 一次查询获取每个对话的最后一条消息，避免 N+1
+func (r *ConversationRepositoryImpl) lastMessages(conversations []*models.Conversation) (map[uuid.UUID]*models.Message, error) {
+	lastMessages := make(map[uuid.UUID]*models.Message)
+	if len(conversations) == 0 {
+		return lastMessages, nil
+	}
+
+	conversationIDs := make([]uuid.UUID, len(conversations))
+	for i, conversation := range conversations {
+		conversationIDs[i] = conversation.ID
+	}
+
+	var messages []*models.Message
+	err := r.db.Raw(`SELECT DISTINCT ON (conversation_id) *
+		FROM messages
+		WHERE conversation_id IN ? AND deleted_at IS NULL
+		ORDER BY conversation_id, sequence DESC, created_at DESC, id DESC`, conversationIDs).
+		Scan(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, message := range messages {
+		lastMessages[message.ConversationID] = message
+	}
+
+	return lastMessages, nil
+}
+
+// rangeColumn 返回按时间范围过滤列表时使用的列，与 listOrder 的排序依据一致
+func rangeColumn(order string) string {
+	if order == OrderActivity {
+		return "COALESCE(last_message_at, created_at)"
+	}
+	return "created_at"
+}
+
+// listOrder 返回列表排序子句，id 作为并列时的次序保证分页稳定
+func listOrder(order string) string {
+	if order == OrderActivity {
+		return "last_message_at DESC NULLS LAST, created_at DESC, id DESC"
+	}
+	return "created_at DESC, id DESC"
+}
+
 // Create creates a new conversation
 func (r *ConversationRepositoryImpl) Create(conversation *models.Conversation) error {
 	return r.db.Create(conversation).Error
@@ -80,9 +285,345 @@ func (r *ConversationRepositoryImpl) Update(conversation *models.Conversation) e
 	return r.db.Save(conversation).Error
 }
 
-// Delete soft deletes a conversation by ID
+// UpdateUserID moves a conversation to another user; returns false when the conversation
+// no longer exists
+func (r *ConversationRepositoryImpl) UpdateUserID(id uuid.UUID, userID uuid.UUID) (bool, error) {
+	result := r.db.Model(&models.Conversation{}).Where("id = ?", id).Update("user_id", userID)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// SourceIDExists reports whether the user has another conversation (excludeID excluded)
+// with the given source_id. Soft-deleted conversations are included because they still
+// hold uk_conversations_user_source
+func (r *ConversationRepositoryImpl) SourceIDExists(userID uuid.UUID, sourceID string, excludeID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&models.Conversation{}).
+		Where("user_id = ? AND source_id = ? AND id <> ?", userID, sourceID, excludeID).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Touch sets a conversation's updated_at, e.g. when one of its messages changes
+func (r *ConversationRepositoryImpl) Touch(id uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.Conversation{}).Where("id = ?", id).Update("updated_at", at).Error
+}
+
+// RefreshLastMessageAt recomputes a conversation's last_message_at from its messages
+// and returns the new value (nil when it has no messages left)
+func (r *ConversationRepositoryImpl) RefreshLastMessageAt(id uuid.UUID) (*time.Time, error) {
+	if err := UpdateLastMessageAt(r.db, []uuid.UUID{id}); err != nil {
+		return nil, err
+	}
+
+	// 刚写入的值需要从主库读取
+	var conversation models.Conversation
+	err := r.db.Clauses(dbresolver.Write).Select("last_message_at").Where("id = ?", id).First(&conversation).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return conversation.LastMessageAt, nil
+}
+
+// UpdateLastMessageAt sets last_message_at of the given conversations to the creation
+// time of their latest non-deleted message. db may be a transaction
+func UpdateLastMessageAt(db *gorm.DB, conversationIDs []uuid.UUID) error {
+	if len(conversationIDs) == 0 {
+		return nil
+	}
+	return db.Exec(`UPDATE conversations c
+		SET last_message_at = (
+			SELECT max(m.created_at) FROM messages m
+			WHERE m.conversation_id = c.id AND m.deleted_at IS NULL
+		)
+		WHERE c.id IN ?`, conversationIDs).Error
+}
+
+// Clone copies a conversation with its tags, messages and attachments to userID in one
+// transaction; every copied row gets a new ID. Returns nil if the conversation does not exist
+func (r *ConversationRepositoryImpl) Clone(id uuid.UUID, userID uuid.UUID) (*models.Conversation, error) {
+	var clone *models.Conversation
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var source models.Conversation
+		err := tx.Preload("Messages", func(db *gorm.DB) *gorm.DB {
+			return db.Order(messageOrder(MessageOrderAsc))
+		}).Preload("Messages.Attachments").Preload("Tags").Where("id = ?", id).First(&source).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil
+			}
+			return err
+		}
+
+		// 使用独立的 source_id，避免与原对话在导入 upsert 时冲突
+		cloneID := uuid.New()
+		clone = &models.Conversation{
+			Base:        models.Base{ID: cloneID},
+			UserID:      userID,
+			Title:       source.Title,
+			Provider:    source.Provider,
+			Model:       source.Model,
+			SourceID:    "clone:" + cloneID.String(),
+			SourceTitle: source.SourceTitle,
+			Metadata:    source.Metadata,
+			// 消息保留原创建时间，最后活跃时间与原对话一致
+			LastMessageAt: source.LastMessageAt,
+		}
+		if err := tx.Create(clone).Error; err != nil {
+			return err
+		}
+
+		if len(source.Tags) > 0 {
+			if err := tx.Model(clone).Association("Tags").Append(source.Tags); err != nil {
+				return err
+			}
+		}
+
+		// 保留原消息的创建时间，保证克隆后的消息顺序一致
+		for _, msg := range source.Messages {
+			copied := models.Message{
+				Base:           models.Base{ID: uuid.New(), CreatedAt: msg.CreatedAt},
+				ConversationID: cloneID,
+				Role:           msg.Role,
+				Content:        msg.Content,
+				SourceID:       msg.SourceID,
+				SourceContent:  msg.SourceContent,
+				Metadata:       msg.Metadata,
+				Sequence:       msg.Sequence,
+			}
+			for _, attachment := range msg.Attachments {
+				copied.Attachments = append(copied.Attachments, models.MessageAttachment{
+					Base:      models.Base{ID: uuid.New()},
+					MessageID: copied.ID,
+					Type:      attachment.Type,
+					Name:      attachment.Name,
+					URLOrRef:  attachment.URLOrRef,
+				})
+			}
+			if err := tx.Create(&copied).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return clone, nil
+}
+
+// Merge moves the messages and tags of the source conversations into the target in one
+// transaction and permanently deletes the emptied sources, so their source_ids can be
+// imported again. Messages are renumbered in
+// chronological order; messages with equal times keep the order of their conversations
+// (target first, then sourceIDs in order) and their original sequence. Returns the target
+// with messages and tags preloaded, or nil if any of the conversations no longer exists
+// or is not owned by userID
+func (r *ConversationRepositoryImpl) Merge(targetID uuid.UUID, sourceIDs []uuid.UUID, userID uuid.UUID) (*models.Conversation, error) {
+	var merged *models.Conversation
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		ids := append([]uuid.UUID{targetID}, sourceIDs...)
+
+		// 锁定参与合并的对话，避免与并发的删除、转移或另一次合并交错
+		var locked []uuid.UUID
+		if err := tx.Model(&models.Conversation{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND user_id = ?", ids, userID).Pluck("id", &locked).Error; err != nil {
+			return err
+		}
+		if len(locked) != len(ids) {
+			return nil
+		}
+
+		rank := make(map[uuid.UUID]int, len(ids))
+		for i, id := range ids {
+			rank[id] = i
+		}
+
+		var messages []*models.Message
+		if err := tx.Select("id, conversation_id, source_id, sequence, created_at").
+			Where("conversation_id IN ?", ids).Find(&messages).Error; err != nil {
+			return err
+		}
+
+		// (conversation_id, source_id) 唯一，与目标对话或先合并的对话重复的 source_id
+		// 加上来源对话 ID 作为前缀，消息全部保留
+		// 目标对话中已软删除的消息仍占用唯一索引，需要一并计入
+		var targetSourceIDs []string
+		if err := tx.Unscoped().Model(&models.Message{}).Where("conversation_id = ?", targetID).
+			Pluck("source_id", &targetSourceIDs).Error; err != nil {
+			return err
+		}
+		sourceIDsInTarget := make(map[string]bool, len(messages)+len(targetSourceIDs))
+		for _, sourceID := range targetSourceIDs {
+			sourceIDsInTarget[sourceID] = true
+		}
+		renamed := make(map[uuid.UUID]string)
+		for _, id := range sourceIDs {
+			for _, msg := range messages {
+				if msg.ConversationID != id {
+					continue
+				}
+				if sourceIDsInTarget[msg.SourceID] {
+					renamed[msg.ID] = "merged:" + id.String() + ":" + msg.SourceID
+				}
+				sourceIDsInTarget[msg.SourceID] = true
+			}
+		}
+
+		sort.SliceStable(messages, func(i, j int) bool {
+			a, b := messages[i], messages[j]
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+			if rank[a.ConversationID] != rank[b.ConversationID] {
+				return rank[a.ConversationID] < rank[b.ConversationID]
+			}
+			return models.MessageLess(a, b)
+		})
+
+		for i, msg := range messages {
+			if msg.ConversationID == targetID && msg.Sequence == i+1 {
+				continue
+			}
+			updates := map[string]interface{}{
+				"conversation_id": targetID,
+				"sequence":        i + 1,
+			}
+			if sourceID, ok := renamed[msg.ID]; ok {
+				updates["source_id"] = sourceID
+			}
+			if err := tx.Model(&models.Message{}).Where("id = ?", msg.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		// 合并标签，目标对话已有的标签保持不变
+		if err := tx.Exec(`INSERT INTO conversation_tags (conversation_id, tag_id)
+			SELECT DISTINCT ?::uuid, tag_id FROM conversation_tags WHERE conversation_id IN ?
+			ON CONFLICT DO NOTHING`, targetID, sourceIDs).Error; err != nil {
+			return err
+		}
+
+		// 来源对话的消息已全部移走，直接物理删除，否则重新导入拆分前的导出文件时
+		// 会与 uk_conversations_user_source 冲突；残留的软删除消息、附件和标签关联由外键级联删除
+		if err := tx.Unscoped().Where("id IN ?", sourceIDs).Delete(&models.Conversation{}).Error; err != nil {
+			return err
+		}
+
+		if err := UpdateLastMessageAt(tx, []uuid.UUID{targetID}); err != nil {
+			return err
+		}
+
+		var target models.Conversation
+		if err := tx.Preload("Messages", func(db *gorm.DB) *gorm.DB {
+			return db.Order(messageOrder(MessageOrderAsc))
+		}).Preload("Messages.Attachments").Preload("Tags").Where("id = ?", targetID).First(&target).Error; err != nil {
+			return err
+		}
+		merged = &target
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+// CountByProviderModel counts a user's conversations grouped by provider and model,
+// ordered by provider and model
+func (r *ConversationRepositoryImpl) CountByProviderModel(userID uuid.UUID) ([]*models.ProviderModelCount, error) {
+	var counts []*models.ProviderModelCount
+	err := r.db.Model(&models.Conversation{}).
+		Select("provider, COALESCE(model, '') AS model, COUNT(*) AS count").
+		Where("user_id = ?", userID).
+		Group("provider, COALESCE(model, '')").
+		Order("provider ASC, model ASC").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// CountByTag returns the tags on a user's conversations with the number of that user's
+// conversations carrying each tag, most used first and then by name
+func (r *ConversationRepositoryImpl) CountByTag(userID uuid.UUID) ([]*models.TagCount, error) {
+	var counts []*models.TagCount
+	err := r.db.Model(&models.Tag{}).
+		Select("tags.id, tags.name, tags.created_at, tags.updated_at, COUNT(*) AS count").
+		Joins("JOIN conversation_tags ON conversation_tags.tag_id = tags.id").
+		Joins("JOIN conversations ON conversations.id = conversation_tags.conversation_id AND conversations.deleted_at IS NULL").
+		Where("conversations.user_id = ?", userID).
+		Group("tags.id, tags.name, tags.created_at, tags.updated_at").
+		Order("count DESC, tags.name ASC").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// Delete soft deletes a conversation and its messages by ID in one transaction
 func (r *ConversationRepositoryImpl) Delete(id uuid.UUID) error {
-	return r.db.Delete(&models.Conversation{}, id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// 软删除不会触发外键级联，需要显式删除消息，否则消息列表仍会返回这些消息
+		if err := tx.Where("conversation_id = ?", id).Delete(&models.Message{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Conversation{}, id).Error
+	})
+}
+
+// DeleteByUserID permanently deletes all conversations of a user, including soft-deleted
+// ones, together with their messages, in transactions of batchSize conversations, and
+// returns how many conversations were deleted. Attachments and tag associations are
+// removed by the foreign key cascades; the tags themselves are kept
+func (r *ConversationRepositoryImpl) DeleteByUserID(userID uuid.UUID, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+
+	var deleted int64
+	for {
+		var batch int64
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			var ids []uuid.UUID
+			if err := tx.Unscoped().Model(&models.Conversation{}).Where("user_id = ?", userID).
+				Limit(batchSize).Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				return nil
+			}
+
+			if err := tx.Unscoped().Where("conversation_id IN ?", ids).Delete(&models.Message{}).Error; err != nil {
+				return err
+			}
+			result := tx.Unscoped().Where("id IN ?", ids).Delete(&models.Conversation{})
+			batch = result.RowsAffected
+			return result.Error
+		})
+		if err != nil {
+			return deleted, err
+		}
+		if batch == 0 {
+			return deleted, nil
+		}
+		deleted += batch
+	}
 }
 
 // ReplaceTags replaces all tags for a conversation
@@ -119,12 +660,50 @@ func (r *ConversationRepositoryImpl) ReplaceTags(conversationID uuid.UUID, tagID
 	})
 }
 
+// Patch applies a partial update in one transaction: updates sets the given columns
+// (title, model), addTagIDs are attached and removeTagIDs detached without touching
+// the conversation's other tags
+func (r *ConversationRepositoryImpl) Patch(id uuid.UUID, updates map[string]interface{}, addTagIDs, removeTagIDs []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&models.Conversation{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(addTagIDs) > 0 {
+			values := make([]string, len(addTagIDs))
+			args := make([]interface{}, len(addTagIDs)*2)
+			for i, tagID := range addTagIDs {
+				values[i] = "(?, ?)"
+				args[i*2] = id
+				args[i*2+1] = tagID
+			}
+
+			query := "INSERT INTO conversation_tags (conversation_id, tag_id) VALUES " +
+				strings.Join(values, ", ") + " ON CONFLICT DO NOTHING"
+			if err := tx.Exec(query, args...).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(removeTagIDs) > 0 {
+			err := tx.Exec("DELETE FROM conversation_tags WHERE conversation_id = ? AND tag_id IN ?", id, removeTagIDs).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
 func (r *ConversationRepositoryImpl) FindAll() ([]*models.Conversation, error) {
 	var conversations []*models.Conversation
 
 	// 预加载 messages 和 tags，按创建时间排序
 	err := r.db.Preload("Messages", func(db *gorm.DB) *gorm.DB {
-		return db.Order("created_at ASC")
+		return db.Order(messageOrder(MessageOrderAsc))
 	}).Preload("Tags").Order("created_at ASC").Find(&conversations).Error
 	if err != nil {
 		return nil, err
@@ -132,3 +711,65 @@ func (r *ConversationRepositoryImpl) FindAll() ([]*models.Conversation, error) {
 
 	return conversations, nil
 }
+
+// FindAllStream 分批读取所有对话（预加载 messages 和 tags），每批最多 batchSize 个并调用一次 fn，
+// 内存占用只与批大小有关。批次按主键游标（id > 上一批最后一个 id）读取而不是 OFFSET，
+// 读取期间有写入也不会重复或跳过已有对话。fn 返回错误时停止读取并返回该错误
+func (r *ConversationRepositoryImpl) FindAllStream(batchSize int, fn func([]*models.Conversation) error) error {
+	return findStream(r.db, batchSize, fn)
+}
+
+// FindByUserIDStream 与 FindAllStream 相同，只读取指定用户的对话
+func (r *ConversationRepositoryImpl) FindByUserIDStream(userID uuid.UUID, batchSize int, fn func([]*models.Conversation) error) error {
+	return findStream(r.db.Where("user_id = ?", userID), batchSize, fn)
+}
+
+// findStream 按主键游标分批读取 query 匹配的对话，预加载 messages 和 tags
+func findStream(query *gorm.DB, batchSize int, fn func([]*models.Conversation) error) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+
+	var batch []*models.Conversation
+	return query.Preload("Messages", func(db *gorm.DB) *gorm.DB {
+		return db.Order(messageOrder(MessageOrderAsc))
+	}).Preload("Tags").FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// FindByIDs retrieves conversations by IDs with messages and tags preloaded
+func (r *ConversationRepositoryImpl) FindByIDs(ids []uuid.UUID) ([]*models.Conversation, error) {
+	if len(ids) == 0 {
+		return []*models.Conversation{}, nil
+	}
+
+	// 导入完成后立即读取用于索引，需读主库避免副本延迟
+	var conversations []*models.Conversation
+	err := r.db.Clauses(dbresolver.Write).Preload("Messages", func(db *gorm.DB) *gorm.DB {
+		return db.Order(messageOrder(MessageOrderAsc))
+	}).Preload("Tags").Where("id IN ?", ids).Order("created_at ASC").Find(&conversations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return conversations, nil
+}
+
+// FindBySourceIDs retrieves the user's conversations with the given source IDs (the import
+// key, see importer.Loader) with their messages in chronological order
+func (r *ConversationRepositoryImpl) FindBySourceIDs(userID uuid.UUID, sourceIDs []string) ([]*models.Conversation, error) {
+	if len(sourceIDs) == 0 {
+		return []*models.Conversation{}, nil
+	}
+
+	var conversations []*models.Conversation
+	err := r.db.Preload("Messages", func(db *gorm.DB) *gorm.DB {
+		return db.Order(messageOrder(MessageOrderAsc))
+	}).Where("user_id = ? AND source_id IN ?", userID, sourceIDs).Find(&conversations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return conversations, nil
+}
@@ -5,89 +5,383 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
+	"chat-assistant-backend/internal/logger"
 	"chat-assistant-backend/internal/models"
 
 	es "github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
+// SearchParams holds the search keyword, filters and pagination
+type SearchParams struct {
+	Query      string
+	UserID     *uuid.UUID
+	ProviderID *string
+	TagID      *uuid.UUID
+	TagIDs     []uuid.UUID // 按多个标签过滤，匹配方式由 TagMatch 决定
+	TagMatch   TagMatch    // TagMatchAll（默认，需包含全部标签）或 TagMatchAny（包含任一标签）
+	StartDate  *time.Time
+	EndDate    *time.Time
+	Role       *string           // 只搜索指定角色的消息: user, assistant, system
+	MinScore   *float64          // 相关性得分下限，低于该值的命中在 ES 端丢弃（仅在有搜索关键词时生效）
+	Metadata   map[string]string // 按对话元信息精确过滤，key 见 models.ConversationMetadataKeys
+	Filter     *FilterExpression // 高级过滤表达式，与其他过滤条件为 AND 关系
+	Order      SearchOrder       // OrderRelevance（默认）、OrderActivity（按最后一条消息时间倒序）或 OrderCreated（按创建时间倒序）
+	Fields     []string          // 关键词匹配的字段组（SearchFieldTitle 等），为空时匹配全部字段
+	Page       int
+	Limit      int
+
+	// 标题或标签匹配但没有消息匹配时，是否返回前几条消息作为上下文
+	IncludeContextMessages bool
+	// SkipPostFilter 跳过 Go 端的精确匹配过滤和相关性重排，直接按 ES 的排序返回
+	SkipPostFilter bool
+	// AllUsers 调用方为管理员，search.require_user_id 开启时仍允许不指定 UserID 跨用户搜索
+	AllUsers bool
+
+	// messageMatches 由 FlatMessageSearchRepository 设置：关键词在独立消息索引中匹配到的消息，
+	// 对话查询用它代替嵌套消息子句
+	messageMatches *flatMessageMatches
+}
+
+// Tag match modes for SearchParams.TagIDs
+const (
+	TagMatchAll = "all"
+	TagMatchAny = "any"
+)
+
+// Field groups for SearchParams.Fields
+const (
+	SearchFieldTitle    = "title"    // title、source_title
+	SearchFieldMessages = "messages" // messages.content、messages.source_content
+	SearchFieldTags     = "tags"     // tags.name
+)
+
+// SearchFieldGroups 可以选择的关键词匹配字段组
+var SearchFieldGroups = []string{SearchFieldTitle, SearchFieldMessages, SearchFieldTags}
+
+// IsSearchFieldGroup reports whether name is one of SearchFieldGroups
+func IsSearchFieldGroup(name string) bool {
+	for _, group := range SearchFieldGroups {
+		if group == name {
+			return true
+		}
+	}
+	return false
+}
+
+// searchesField reports whether the keyword is matched against a field group;
+// no selected fields means all groups
+func searchesField(fields []string, group string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, field := range fields {
+		if field == group {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightFieldGroup 返回高亮字段所属的字段组
+func highlightFieldGroup(field string) string {
+	switch {
+	case strings.HasPrefix(field, "messages."):
+		return SearchFieldMessages
+	case strings.HasPrefix(field, "tags."):
+		return SearchFieldTags
+	default:
+		return SearchFieldTitle
+	}
+}
+
+// searchClauseGroup 返回关键词查询子句所属的字段组：嵌套查询按 path 区分，其余为标题
+func searchClauseGroup(clause map[string]interface{}) string {
+	if nested, ok := clause["nested"].(map[string]interface{}); ok {
+		switch nested["path"] {
+		case "messages":
+			return SearchFieldMessages
+		case "tags":
+			return SearchFieldTags
+		}
+	}
+	return SearchFieldTitle
+}
+
+// OrderRelevance sorts search results by relevance score, newest first among equal
+// scores and when there is no keyword. OrderActivity and OrderCreated (shared with the
+// conversation list) sort by last_message_at and created_at, still dropping non-matches
+const OrderRelevance = "relevance"
+
+// maxMatchedMessages 每个对话最多返回的消息数
+const maxMatchedMessages = 3
+
+// postFilterWindow 后置过滤时从 ES 读取的候选命中数，过滤和相关性排序只在该窗口内进行
+const postFilterWindow = 500
+
+// innerHitMessages 后置过滤时每个嵌套消息子句通过 inner_hits 返回的匹配消息数，
+// 窗口内的命中不读取全部嵌套消息，只用这些消息做精确匹配和评分
+const innerHitMessages = 20
+
+// innerHitMessageFields 匹配消息 inner_hits 中读取的字段
+var innerHitMessageFields = []string{
+	"messages.id", "messages.conversation_id", "messages.role", "messages.content", "messages.source_content",
+	"messages.sequence", "messages.created_at", "messages.updated_at",
+}
+
+// Names of the inner_hits returned by the nested message clauses, see messagesFromInnerHits
+const (
+	innerHitsExactMessages = "exact_messages"
+	innerHitsAnyMessages   = "any_messages"
+)
+
+// highlightFields 参与高亮并用于判断 matched_fields 的字段
+var highlightFields = []string{"title", "source_title", "messages.content", "messages.source_content", "tags.name"}
+
+// Message fields reported in SearchResult.MessageMatchedFields and MessageHighlights
+const (
+	MessageFieldContent       = "content"
+	MessageFieldSourceContent = "source_content"
+)
+
+// SearchResult holds the conversations found by a search with their matched messages and fields
+type SearchResult struct {
+	Documents       []*models.ConversationDocument
+	MatchedMessages map[uuid.UUID][]*models.MessageDocument
+	MatchedFields   map[uuid.UUID][]string
+	// Highlights 按对话 ID 保存 ES 返回的高亮片段，键为 highlightFields 中的字段名
+	Highlights      map[uuid.UUID]map[string][]string
+	ContextMessages map[uuid.UUID]bool // 按消息 ID 标记仅作为上下文返回、本身不匹配的消息
+	// MessageMatchedFields 按消息 ID 保存包含关键词的字段（content、source_content），
+	// 区分匹配来自规范化内容还是原始内容
+	MessageMatchedFields map[uuid.UUID][]string
+	// MessageHighlights 按消息 ID 保存属于该消息的高亮片段，键为 content 或 source_content
+	MessageHighlights map[uuid.UUID]map[string][]string
+	Total             int64
+	Meta              *models.SearchMeta
+}
+
+// SearchStats holds cumulative search counters since startup
+type SearchStats struct {
+	Searches       int64 `json:"searches"`
+	SlowSearches   int64 `json:"slow_searches"`
+	FailedSearches int64 `json:"failed_searches"` // ES 请求失败的搜索，即需要 Postgres 兜底的搜索
+	// FallbackSearches ES 不可用期间由 Postgres 执行的搜索（见 FallbackSearchRepository）
+	FallbackSearches int64 `json:"fallback_searches"`
+}
+
 // SearchRepository defines the interface for search repository
 type SearchRepository interface {
-	SearchConversationsWithMatchedMessages(query string, userID *uuid.UUID, providerID *string, tagID *uuid.UUID, startDate, endDate *time.Time, page, limit int) ([]*models.ConversationDocument, map[uuid.UUID][]*models.MessageDocument, map[uuid.UUID][]string, int64, error)
+	SearchConversationsWithMatchedMessages(ctx context.Context, params SearchParams) (*SearchResult, error)
+	FindSimilar(conversationID uuid.UUID, userID *uuid.UUID, limit int) ([]*models.ConversationDocument, error)
+	Stats() SearchStats
 }
 
+// slowSearchLogBurst 每秒最多输出的慢查询日志条数，超出部分只计数不输出
+const slowSearchLogBurst = 10
+
 // ElasticsearchRepositoryImpl handles Elasticsearch search operations
 type ElasticsearchRepositoryImpl struct {
 	esClient  *es.Client
 	indexName string
+
+	// 耗时超过该值的搜索记录慢查询日志，0 表示不记录
+	slowSearchThreshold time.Duration
+
+	searches       atomic.Int64
+	slowSearches   atomic.Int64
+	failedSearches atomic.Int64
+
+	slowLogMu     sync.Mutex
+	slowLogWindow time.Time
+	slowLogCount  int
+
+	// postProcessSlots 所有请求共享的后置过滤并发槽位，限制精确匹配和评分占用的 CPU
+	postProcessSlots chan struct{}
+	// postProcessMaxMessages 后置过滤时每个对话最多参与评分的消息数，不影响精确匹配，0 表示不限制
+	postProcessMaxMessages int
+}
+
+// SearchRepositoryOptions configures ElasticsearchRepositoryImpl
+type SearchRepositoryOptions struct {
+	// SlowSearchThreshold 耗时超过该值的搜索记录慢查询日志，0 表示不记录
+	SlowSearchThreshold time.Duration
+	// PostProcessConcurrency 同时进行精确匹配和评分的对话数上限（所有请求共享），<= 0 时使用 GOMAXPROCS
+	PostProcessConcurrency int
+	// PostProcessMaxMessages 后置过滤时每个对话最多参与相关性评分的消息数，限制重排的计算量；
+	// 精确匹配总是检查全部消息，0 表示不限制
+	PostProcessMaxMessages int
+}
+
+// NewElasticsearchRepository creates a new Elasticsearch repository.
+// Searches taking longer than slowSearchThreshold are logged; 0 disables slow-search logging
+func NewElasticsearchRepository(esClient *es.Client, indexName string, slowSearchThreshold time.Duration) SearchRepository {
+	return NewElasticsearchRepositoryWithOptions(esClient, indexName, SearchRepositoryOptions{SlowSearchThreshold: slowSearchThreshold})
 }
 
-// NewElasticsearchRepository creates a new Elasticsearch repository
-func NewElasticsearchRepository(esClient *es.Client, indexName string) SearchRepository {
+// NewElasticsearchRepositoryWithOptions creates a new Elasticsearch repository with the given options
+func NewElasticsearchRepositoryWithOptions(esClient *es.Client, indexName string, opts SearchRepositoryOptions) SearchRepository {
+	return newElasticsearchRepository(esClient, indexName, opts)
+}
+
+func newElasticsearchRepository(esClient *es.Client, indexName string, opts SearchRepositoryOptions) *ElasticsearchRepositoryImpl {
+	concurrency := opts.PostProcessConcurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
 	return &ElasticsearchRepositoryImpl{
-		esClient:  esClient,
-		indexName: indexName,
+		esClient:               esClient,
+		indexName:              indexName,
+		slowSearchThreshold:    opts.SlowSearchThreshold,
+		postProcessSlots:       make(chan struct{}, concurrency),
+		postProcessMaxMessages: max(opts.PostProcessMaxMessages, 0),
 	}
 }
 
-// SearchConversationsWithMatchedMessages searches conversations and returns matched messages
-func (r *ElasticsearchRepositoryImpl) SearchConversationsWithMatchedMessages(query string, userID *uuid.UUID, providerID *string, tagID *uuid.UUID, startDate, endDate *time.Time, page, limit int) ([]*models.ConversationDocument, map[uuid.UUID][]*models.MessageDocument, map[uuid.UUID][]string, int64, error) {
+// Stats returns the cumulative search counters
+func (r *ElasticsearchRepositoryImpl) Stats() SearchStats {
+	return SearchStats{
+		Searches:       r.searches.Load(),
+		SlowSearches:   r.slowSearches.Load(),
+		FailedSearches: r.failedSearches.Load(),
+	}
+}
+
+// SearchConversationsWithMatchedMessages searches conversations and returns matched messages.
+// ctx cancels the Elasticsearch requests and carries the request ID for logging
+func (r *ElasticsearchRepositoryImpl) SearchConversationsWithMatchedMessages(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	query := params.Query
+	start := time.Now()
+	r.searches.Add(1)
+
+	// 有关键词且开启后置过滤时，从 ES 读取固定窗口内的候选命中，过滤、排序后再分页，
+	// 保证每页条数和 total 一致，且各页之间顺序稳定
+	postFilter := query != "" && !params.SkipPostFilter
+	esParams := params
+	if postFilter {
+		esParams.Page = 1
+		esParams.Limit = postFilterWindow
+	}
+
 	// 1. 在 ES 中搜索
-	esDocs, highlights, total, err := r.searchConversationDocumentsWithHighlights(query, userID, providerID, tagID, startDate, endDate, page, limit)
+	esDocs, highlights, total, meta, err := r.searchConversationDocumentsWithHighlights(ctx, esParams)
 	if err != nil {
-		return nil, nil, nil, 0, err
+		r.failedSearches.Add(1)
+		return nil, err
+	}
+	if params.messageMatches != nil {
+		params.messageMatches.apply(esDocs, highlights)
 	}
+	postProcessStart := time.Now()
 
 	// 2. 使用精确匹配过滤结果，确保关键词精确匹配（只在有搜索关键词时进行）
-	filteredDocs := make([]*models.ConversationDocument, 0, len(esDocs))
-	filteredHighlights := make([]map[string]interface{}, 0, len(highlights))
+	uniqueDocs := make([]*models.ConversationDocument, 0, len(esDocs))
+	uniqueHighlights := make([]map[string]interface{}, 0, len(highlights))
+	seen := make(map[uuid.UUID]bool, len(esDocs))
 
 	for i, doc := range esDocs {
-		// 如果没有搜索关键词，直接使用 ES 返回的结果
-		if query == "" {
-			filteredDocs = append(filteredDocs, doc)
-			filteredHighlights = append(filteredHighlights, highlights[i])
-		} else {
-			// 有搜索关键词时，检查是否真正包含关键词
-			if r.hasExactMatch(doc, query) {
+		// 同一对话只保留第一次出现（得分最高）的命中，避免在同一页重复出现
+		if seen[doc.ID] {
+			total--
+			continue
+		}
+		seen[doc.ID] = true
+		uniqueDocs = append(uniqueDocs, doc)
+		uniqueHighlights = append(uniqueHighlights, highlights[i])
+	}
+
+	// 如果没有搜索关键词或关闭了后置过滤，直接使用 ES 返回的结果
+	filteredDocs, filteredHighlights := uniqueDocs, uniqueHighlights
+	var scores []float64
+	if postFilter {
+		// 按时间排序时保持 ES 返回的顺序，只过滤不评分
+		scoreDocs := params.Order != OrderActivity && params.Order != OrderCreated
+		matched, docScores := r.evaluateCandidates(uniqueDocs, query, params.Role, params.Fields, scoreDocs)
+
+		filteredDocs = make([]*models.ConversationDocument, 0, len(uniqueDocs))
+		filteredHighlights = make([]map[string]interface{}, 0, len(uniqueHighlights))
+		for i, doc := range uniqueDocs {
+			if matched[i] {
 				filteredDocs = append(filteredDocs, doc)
-				filteredHighlights = append(filteredHighlights, highlights[i])
+				filteredHighlights = append(filteredHighlights, uniqueHighlights[i])
+				if scoreDocs {
+					scores = append(scores, docScores[i])
+				}
 			}
 		}
 	}
 
-	// 3. 按相关性评分排序（只在有搜索关键词时进行）
-	if query != "" {
-		r.sortByRelevance(filteredDocs, query)
+	// 3. 按相关性评分排序并截取当前页（只在后置过滤时进行）
+	if postFilter {
+		if scores != nil {
+			sortByScores(filteredDocs, filteredHighlights, scores)
+		}
+
+		// 窗口之外的命中无法翻页到，total 只统计窗口内通过过滤的对话
+		total = int64(len(filteredDocs))
+		start, end := pageBounds(params.Page, params.Limit, len(filteredDocs))
+		filteredDocs = filteredDocs[start:end]
+		filteredHighlights = filteredHighlights[start:end]
+	}
+
+	// 4. 搜索不返回完整的嵌套消息，只为当前页需要展示消息的对话补充读取。
+	// inner_hits 只包含部分匹配消息，不作为对话的消息返回
+	var needMessages []*models.ConversationDocument
+	for i, doc := range filteredDocs {
+		if messagesFromInnerHits(params) {
+			doc.Messages = nil
+		}
+		_, hasContent := filteredHighlights[i]["messages.content"]
+		_, hasSourceContent := filteredHighlights[i]["messages.source_content"]
+		if params.messageMatches != nil && len(params.messageMatches.messages[doc.ID]) >= maxMatchedMessages {
+			continue // 独立消息索引中匹配的消息已足够展示，不需要补充上下文
+		}
+		if hasContent || hasSourceContent || (params.IncludeContextMessages && hasHighlightedField(filteredHighlights[i])) {
+			needMessages = append(needMessages, doc)
+		}
+	}
+	if err := r.loadMessages(ctx, needMessages); err != nil {
+		r.failedSearches.Add(1)
+		return nil, err
+	}
+	if params.messageMatches != nil {
+		for _, doc := range needMessages {
+			params.messageMatches.merge(doc)
+		}
 	}
 
-	// 4. 提取匹配的消息和字段信息
+	// 5. 提取匹配的消息和字段信息
 	matchedMessagesMap := make(map[uuid.UUID][]*models.MessageDocument)
 	matchedFieldsMap := make(map[uuid.UUID][]string)
+	highlightsMap := make(map[uuid.UUID]map[string][]string)
+	contextMessages := make(map[uuid.UUID]bool)
+	messageMatchedFields := make(map[uuid.UUID][]string)
+	messageHighlights := make(map[uuid.UUID]map[string][]string)
 
 	for i, doc := range filteredDocs {
 		conversationID := doc.ID
-		var matchedFields []string
 
 		// 检查哪些字段有高亮（即匹配）
-		if _, exists := filteredHighlights[i]["title"]; exists {
-			matchedFields = append(matchedFields, "title")
-		}
-		if _, exists := filteredHighlights[i]["source_title"]; exists {
-			matchedFields = append(matchedFields, "source_title")
-		}
-		if _, exists := filteredHighlights[i]["messages.content"]; exists {
-			matchedFields = append(matchedFields, "messages.content")
-		}
-		if _, exists := filteredHighlights[i]["messages.source_content"]; exists {
-			matchedFields = append(matchedFields, "messages.source_content")
+		var matchedFields []string
+		fragments := highlightFragments(filteredHighlights[i])
+		for _, field := range highlightFields {
+			if _, exists := filteredHighlights[i][field]; exists {
+				matchedFields = append(matchedFields, field)
+			}
 		}
-		if _, exists := filteredHighlights[i]["tags.name"]; exists {
-			matchedFields = append(matchedFields, "tags.name")
+		if len(fragments) > 0 {
+			highlightsMap[conversationID] = fragments
 		}
 
 		// 提取匹配的消息
@@ -95,65 +389,211 @@ func (r *ElasticsearchRepositoryImpl) SearchConversationsWithMatchedMessages(que
 		_, hasSourceContent := filteredHighlights[i]["messages.source_content"]
 		if hasContent || hasSourceContent {
 			// 如果 ES 返回了消息字段的高亮，说明有消息匹配
-			// 最多返回 3 条消息，优先选择包含匹配关键词的消息
-			const maxMessages = 3
-			matchedMessages := make([]*models.MessageDocument, 0, maxMessages)
-
-			// 首先尝试找到真正包含匹配关键词的消息
-			for _, msgDoc := range doc.Messages {
-				if len(matchedMessages) >= maxMessages {
-					break
+			// 优先选择包含匹配关键词的消息，不足时补充前几条消息作为上下文
+			matched, context := collectMessages(doc, query, params.Role, maxMatchedMessages)
+			matchedMessagesMap[conversationID] = append(matched, context...)
+			for _, msg := range context {
+				contextMessages[msg.ID] = true
+			}
+			for _, msg := range matched {
+				if fields := messageMatchedFieldNames(msg, query); len(fields) > 0 {
+					messageMatchedFields[msg.ID] = fields
 				}
-
-				// 检查消息是否包含匹配的关键词（通过精确匹配判断）
-				content := msgDoc.Content
-				if content == "" {
-					content = msgDoc.SourceContent
+				if msgFragments := messageFragments(msg, fragments); len(msgFragments) > 0 {
+					messageHighlights[msg.ID] = msgFragments
 				}
+			}
+		} else if params.IncludeContextMessages && len(matchedFields) > 0 {
+			// 只有标题或标签匹配时，返回前几条消息作为上下文
+			_, context := collectMessages(doc, "", params.Role, maxMatchedMessages)
+			matchedMessagesMap[conversationID] = context
+			for _, msg := range context {
+				contextMessages[msg.ID] = true
+			}
+		}
 
-				if countKeywordMatches(content, query) > 0 {
-					matchedMessages = append(matchedMessages, &msgDoc)
-				}
+		// 总是设置 matched_fields，即使为空
+		matchedFieldsMap[conversationID] = matchedFields
+	}
+
+	meta.PostProcessMs = time.Since(postProcessStart).Milliseconds()
+
+	r.recordSlowSearch(ctx, params, time.Since(start), len(filteredDocs), meta)
+
+	return &SearchResult{
+		Documents:            filteredDocs,
+		MatchedMessages:      matchedMessagesMap,
+		MatchedFields:        matchedFieldsMap,
+		Highlights:           highlightsMap,
+		ContextMessages:      contextMessages,
+		MessageMatchedFields: messageMatchedFields,
+		MessageHighlights:    messageHighlights,
+		Total:                total,
+		Meta:                 meta,
+	}, nil
+}
+
+// highlightFragments 提取 highlightFields 中各字段的高亮片段
+func highlightFragments(highlight map[string]interface{}) map[string][]string {
+	fragments := make(map[string][]string)
+	for _, field := range highlightFields {
+		values, ok := highlight[field].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, value := range values {
+			if fragment, ok := value.(string); ok {
+				fragments[field] = append(fragments[field], fragment)
 			}
+		}
+	}
+	return fragments
+}
 
-			// 如果没有找到匹配的消息，或者匹配的消息少于3条，则补充前几条消息
-			if len(matchedMessages) < maxMessages {
-				for _, msgDoc := range doc.Messages {
-					if len(matchedMessages) >= maxMessages {
-						break
-					}
-
-					// 检查是否已经包含这条消息
-					alreadyIncluded := false
-					for _, existing := range matchedMessages {
-						if existing.ID == msgDoc.ID {
-							alreadyIncluded = true
-							break
-						}
-					}
-
-					if !alreadyIncluded {
-						matchedMessages = append(matchedMessages, &msgDoc)
-					}
-				}
+// messageMatchedFieldNames 返回消息中包含关键词的字段，content 和 source_content 分别判断
+func messageMatchedFieldNames(msg *models.MessageDocument, query string) []string {
+	var fields []string
+	if countKeywordMatches(msg.Content, query) > 0 {
+		fields = append(fields, MessageFieldContent)
+	}
+	if countKeywordMatches(msg.SourceContent, query) > 0 {
+		fields = append(fields, MessageFieldSourceContent)
+	}
+	return fields
+}
+
+// messageFragments 从对话级的高亮片段中找出属于该消息的片段。
+// ES 对嵌套字段的高亮不区分消息，去掉高亮标签后是消息原文子串的片段才归属该消息
+func messageFragments(msg *models.MessageDocument, fragments map[string][]string) map[string][]string {
+	result := make(map[string][]string)
+	sources := []struct {
+		field string
+		text  string
+	}{
+		{MessageFieldContent, msg.Content},
+		{MessageFieldSourceContent, msg.SourceContent},
+	}
+	for _, source := range sources {
+		if source.text == "" {
+			continue
+		}
+		for _, fragment := range fragments["messages."+source.field] {
+			if strings.Contains(source.text, removeHighlightTags(fragment)) {
+				result[source.field] = append(result[source.field], fragment)
 			}
+		}
+	}
+	return result
+}
 
-			matchedMessagesMap[conversationID] = matchedMessages
+// hasHighlightedField 是否有参与 matched_fields 判断的字段被高亮
+func hasHighlightedField(highlight map[string]interface{}) bool {
+	for _, field := range highlightFields {
+		if _, exists := highlight[field]; exists {
+			return true
 		}
+	}
+	return false
+}
 
-		// 总是设置 matched_fields，即使为空
-		matchedFieldsMap[conversationID] = matchedFields
+// pageBounds 返回第 page 页（从 1 开始）在长度为 n 的列表中的起止下标
+func pageBounds(page, limit, n int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	start := (page - 1) * limit
+	if limit <= 0 || start > n {
+		return n, n
 	}
+	return start, min(start+limit, n)
+}
 
-	return filteredDocs, matchedMessagesMap, matchedFieldsMap, total, nil
+// recordSlowSearch 记录耗时超过阈值的搜索，用于调整权重和分词器。
+// 日志按秒限流，避免慢查询集中出现时刷屏
+func (r *ElasticsearchRepositoryImpl) recordSlowSearch(ctx context.Context, params SearchParams, duration time.Duration, hits int, meta *models.SearchMeta) {
+	if r.slowSearchThreshold <= 0 || duration < r.slowSearchThreshold {
+		return
+	}
+	r.slowSearches.Add(1)
+
+	r.slowLogMu.Lock()
+	now := time.Now()
+	if now.Sub(r.slowLogWindow) >= time.Second {
+		r.slowLogWindow = now
+		r.slowLogCount = 0
+	}
+	r.slowLogCount++
+	sampled := r.slowLogCount <= slowSearchLogBurst
+	r.slowLogMu.Unlock()
+
+	if !sampled {
+		return
+	}
+
+	logger.FromContext(ctx).Warn("Slow Elasticsearch search",
+		zap.String("index", r.indexName),
+		zap.String("query", params.Query),
+		zap.Any("filters", searchFilterFields(params)),
+		zap.Int("hits", hits),
+		zap.Int64("took_ms", meta.TookMs),
+		zap.Duration("duration", duration),
+		zap.Duration("threshold", r.slowSearchThreshold),
+	)
+}
+
+// searchFilterFields 汇总搜索中生效的过滤条件，用于日志输出
+func searchFilterFields(params SearchParams) map[string]interface{} {
+	filters := make(map[string]interface{})
+	if params.UserID != nil {
+		filters["user_id"] = params.UserID.String()
+	}
+	if params.ProviderID != nil {
+		filters["provider_id"] = *params.ProviderID
+	}
+	if params.TagID != nil {
+		filters["tag_id"] = params.TagID.String()
+	}
+	if len(params.TagIDs) > 0 {
+		tagIDs := make([]string, len(params.TagIDs))
+		for i, id := range params.TagIDs {
+			tagIDs[i] = id.String()
+		}
+		filters["tag_ids"] = tagIDs
+		filters["tag_match"] = params.TagMatch.String()
+	}
+	if params.StartDate != nil {
+		filters["start_date"] = params.StartDate.Format(time.RFC3339)
+	}
+	if params.EndDate != nil {
+		filters["end_date"] = params.EndDate.Format(time.RFC3339)
+	}
+	if params.Role != nil {
+		filters["role"] = *params.Role
+	}
+	if params.MinScore != nil {
+		filters["min_score"] = *params.MinScore
+	}
+	if len(params.Metadata) > 0 {
+		filters["metadata"] = params.Metadata
+	}
+	if params.Filter != nil {
+		filters["filter"] = buildFilterClause(params.Filter)
+	}
+	if len(params.Fields) > 0 {
+		filters["fields"] = params.Fields
+	}
+	return filters
 }
 
 // buildSearchQuery 构建 ES 搜索查询
-func (r *ElasticsearchRepositoryImpl) buildSearchQuery(query string, userID *uuid.UUID, providerID *string, tagID *uuid.UUID, startDate, endDate *time.Time, page, limit int) []byte {
+func (r *ElasticsearchRepositoryImpl) buildSearchQuery(params SearchParams) []byte {
 	// 预处理查询词，确保精确匹配
-	query = strings.TrimSpace(query)
+	query := strings.TrimSpace(params.Query)
+	userID, providerID, tagID := params.UserID, params.ProviderID, params.TagID
+	startDate, endDate := params.StartDate, params.EndDate
+	limit := params.Limit
 	// 计算偏移量
-	offset := (page - 1) * limit
+	offset := (params.Page - 1) * limit
 
 	// 构建查询条件
 	var mustQueries []map[string]interface{}
@@ -190,6 +630,31 @@ func (r *ElasticsearchRepositoryImpl) buildSearchQuery(query string, userID *uui
 		})
 	}
 
+	// 多个 Tag ID 过滤 - 每个标签一个嵌套查询
+	if len(params.TagIDs) > 0 {
+		tagQueries := make([]map[string]interface{}, len(params.TagIDs))
+		for i, id := range params.TagIDs {
+			tagQueries[i] = nestedTerm("tags", "tags.id", id.String())
+		}
+
+		if params.TagMatch == TagMatchAny {
+			// 包含任一标签即可
+			mustQueries = append(mustQueries, map[string]interface{}{
+				"bool": map[string]interface{}{
+					"should":               tagQueries,
+					"minimum_should_match": 1,
+				},
+			})
+		} else {
+			// 需包含全部标签
+			mustQueries = append(mustQueries, map[string]interface{}{
+				"bool": map[string]interface{}{
+					"must": tagQueries,
+				},
+			})
+		}
+	}
+
 	// 日期范围过滤
 	if startDate != nil || endDate != nil {
 		dateRange := map[string]interface{}{}
@@ -206,13 +671,43 @@ func (r *ElasticsearchRepositoryImpl) buildSearchQuery(query string, userID *uui
 		})
 	}
 
+	// 消息角色过滤 - 对话中至少包含一条该角色的消息
+	if params.Role != nil {
+		mustQueries = append(mustQueries, map[string]interface{}{
+			"nested": map[string]interface{}{
+				"path":  "messages",
+				"query": messageRoleFilter(*params.Role),
+			},
+		})
+	}
+
+	// 元信息过滤 - 按 key 排序保证查询稳定
+	metadataKeys := make([]string, 0, len(params.Metadata))
+	for key := range params.Metadata {
+		metadataKeys = append(metadataKeys, key)
+	}
+	sort.Strings(metadataKeys)
+	for _, key := range metadataKeys {
+		mustQueries = append(mustQueries, map[string]interface{}{
+			"term": map[string]interface{}{
+				"metadata." + key: params.Metadata[key],
+			},
+		})
+	}
+
+	// 高级过滤表达式
+	if params.Filter != nil {
+		mustQueries = append(mustQueries, buildFilterClause(params.Filter))
+	}
+
 	// 构建搜索查询
 	var searchQueries []map[string]interface{}
 
 	// 如果有搜索关键词，添加文本搜索查询
 	if query != "" {
 		// 搜索查询 - 平衡精确匹配和相关性
-		searchQueries = []map[string]interface{}{
+		messageQueries := messageMatchQueries(query, "messages.")
+		clauses := []map[string]interface{}{
 			// 1. 完全精确匹配 - 最高优先级 (权重: 10)
 			{
 				"multi_match": map[string]interface{}{
@@ -222,19 +717,7 @@ func (r *ElasticsearchRepositoryImpl) buildSearchQuery(query string, userID *uui
 					"slop":   0,
 				},
 			},
-			{
-				"nested": map[string]interface{}{
-					"path": "messages",
-					"query": map[string]interface{}{
-						"multi_match": map[string]interface{}{
-							"query":  query,
-							"fields": []string{"messages.content.exact^10", "messages.source_content.exact^8"},
-							"type":   "phrase",
-							"slop":   0,
-						},
-					},
-				},
-			},
+			nestedMessages(params, messageQueries[0], innerHitsExactMessages),
 			{
 				"nested": map[string]interface{}{
 					"path": "tags",
@@ -257,19 +740,7 @@ func (r *ElasticsearchRepositoryImpl) buildSearchQuery(query string, userID *uui
 					"fuzziness": "AUTO",
 				},
 			},
-			{
-				"nested": map[string]interface{}{
-					"path": "messages",
-					"query": map[string]interface{}{
-						"multi_match": map[string]interface{}{
-							"query":     query,
-							"fields":    []string{"messages.content^8", "messages.source_content^6"},
-							"type":      "best_fields",
-							"fuzziness": "AUTO",
-						},
-					},
-				},
-			},
+			nestedMessages(params, messageQueries[1], ""),
 			{
 				"nested": map[string]interface{}{
 					"path": "tags",
@@ -292,19 +763,7 @@ func (r *ElasticsearchRepositoryImpl) buildSearchQuery(query string, userID *uui
 					"operator": "and", // 所有词都必须匹配
 				},
 			},
-			{
-				"nested": map[string]interface{}{
-					"path": "messages",
-					"query": map[string]interface{}{
-						"multi_match": map[string]interface{}{
-							"query":    query,
-							"fields":   []string{"messages.content^5", "messages.source_content^4"},
-							"type":     "cross_fields",
-							"operator": "and", // 所有词都必须匹配
-						},
-					},
-				},
-			},
+			nestedMessages(params, messageQueries[2], ""),
 			{
 				"nested": map[string]interface{}{
 					"path": "tags",
@@ -327,19 +786,7 @@ func (r *ElasticsearchRepositoryImpl) buildSearchQuery(query string, userID *uui
 					"operator": "or", // 任意词匹配即可
 				},
 			},
-			{
-				"nested": map[string]interface{}{
-					"path": "messages",
-					"query": map[string]interface{}{
-						"multi_match": map[string]interface{}{
-							"query":    query,
-							"fields":   []string{"messages.content^2", "messages.source_content^1"},
-							"type":     "best_fields",
-							"operator": "or", // 任意词匹配即可
-						},
-					},
-				},
-			},
+			nestedMessages(params, messageQueries[3], innerHitsAnyMessages),
 			{
 				"nested": map[string]interface{}{
 					"path": "tags",
@@ -354,6 +801,21 @@ func (r *ElasticsearchRepositoryImpl) buildSearchQuery(query string, userID *uui
 				},
 			},
 		}
+
+		// 只保留选中字段组的子句
+		for _, clause := range clauses {
+			group := searchClauseGroup(clause)
+			// 消息已在独立消息索引中匹配，用匹配结果代替嵌套消息子句
+			if group == SearchFieldMessages && params.messageMatches != nil {
+				continue
+			}
+			if searchesField(params.Fields, group) {
+				searchQueries = append(searchQueries, clause)
+			}
+		}
+		if params.messageMatches != nil {
+			searchQueries = append(searchQueries, params.messageMatches.conversationClauses()...)
+		}
 	}
 
 	// 构建完整的查询
@@ -361,9 +823,10 @@ func (r *ElasticsearchRepositoryImpl) buildSearchQuery(query string, userID *uui
 
 	if len(searchQueries) > 0 {
 		// 有搜索关键词时，使用 bool 查询组合过滤条件和搜索查询
+		// 过滤条件放在 filter 中不参与评分，使 min_score 只衡量关键词相关性
 		queryClause = map[string]interface{}{
 			"bool": map[string]interface{}{
-				"must":                 mustQueries,
+				"filter":               mustQueries,
 				"should":               searchQueries,
 				"minimum_should_match": 1,
 			},
@@ -386,7 +849,23 @@ func (r *ElasticsearchRepositoryImpl) buildSearchQuery(query string, userID *uui
 
 	// 构建排序条件
 	var sortConditions []map[string]interface{}
-	if len(searchQueries) > 0 {
+	if params.Order == OrderActivity {
+		// 按最近活跃排序，没有消息的对话排在最后
+		sortConditions = []map[string]interface{}{
+			{
+				"last_message_at": map[string]interface{}{
+					"order":         "desc",
+					"missing":       "_last",
+					"unmapped_type": "date",
+				},
+			},
+			{
+				"created_at": map[string]interface{}{
+					"order": "desc",
+				},
+			},
+		}
+	} else if len(searchQueries) > 0 && params.Order != OrderCreated {
 		// 有搜索关键词时，按相关性评分排序
 		sortConditions = []map[string]interface{}{
 			{
@@ -401,7 +880,7 @@ func (r *ElasticsearchRepositoryImpl) buildSearchQuery(query string, userID *uui
 			},
 		}
 	} else {
-		// 没有搜索关键词时，只按创建时间排序
+		// 没有搜索关键词或指定按创建时间排序时，只按创建时间排序
 		sortConditions = []map[string]interface{}{
 			{
 				"created_at": map[string]interface{}{
@@ -414,14 +893,19 @@ func (r *ElasticsearchRepositoryImpl) buildSearchQuery(query string, userID *uui
 	// 构建高亮配置（只在有搜索关键词时使用）
 	var highlightConfig map[string]interface{}
 	if len(searchQueries) > 0 {
+		// 只高亮选中的字段组，matched_fields 随之只包含这些字段
+		fields := map[string]interface{}{}
+		for _, field := range highlightFields {
+			group := highlightFieldGroup(field)
+			if group == SearchFieldMessages && params.messageMatches != nil {
+				continue // 消息的高亮来自独立消息索引
+			}
+			if searchesField(params.Fields, group) {
+				fields[field] = map[string]interface{}{}
+			}
+		}
 		highlightConfig = map[string]interface{}{
-			"fields": map[string]interface{}{
-				"title":                   map[string]interface{}{},
-				"source_title":            map[string]interface{}{},
-				"messages.content":        map[string]interface{}{},
-				"messages.source_content": map[string]interface{}{},
-				"tags.name":               map[string]interface{}{},
-			},
+			"fields":              fields,
 			"pre_tags":            []string{"<mark>"},
 			"post_tags":           []string{"</mark>"},
 			"fragment_size":       150, // 限制高亮片段长度
@@ -436,16 +920,183 @@ func (r *ElasticsearchRepositoryImpl) buildSearchQuery(query string, userID *uui
 		"sort":  sortConditions,
 	}
 
+	// 不读取嵌套消息，减少长对话的传输和解析开销；后置过滤需要的匹配消息由 inner_hits 返回
+	searchBody["_source"] = map[string]interface{}{
+		"excludes": []string{"messages"},
+	}
+
 	// 只在有搜索关键词时添加高亮配置
 	if highlightConfig != nil {
 		searchBody["highlight"] = highlightConfig
 	}
 
+	// 丢弃模糊匹配、任意词匹配产生的弱相关命中，减少返回后再过滤的文档数
+	if len(searchQueries) > 0 && params.MinScore != nil && *params.MinScore > 0 {
+		searchBody["min_score"] = *params.MinScore
+	}
+
 	// 序列化查询
 	queryBytes, _ := json.Marshal(searchBody)
 	return queryBytes
 }
 
+// collectMessages 从对话中选取最多 limit 条消息：先选包含关键词的消息，
+// 不足时按顺序补充其他消息作为上下文。query 为空时全部消息都作为上下文
+func collectMessages(doc *models.ConversationDocument, query string, role *string, limit int) ([]*models.MessageDocument, []*models.MessageDocument) {
+	matched := make([]*models.MessageDocument, 0, limit)
+	var context []*models.MessageDocument
+	included := make(map[uuid.UUID]bool)
+
+	// 首先找到真正包含匹配关键词的消息
+	if query != "" {
+		for i := range doc.Messages {
+			if len(matched) >= limit {
+				break
+			}
+
+			msg := &doc.Messages[i]
+			if !matchesRole(msg, role) {
+				continue
+			}
+
+			// 规范化内容和原始内容任一包含关键词即为匹配
+			if countKeywordMatches(msg.Content, query) > 0 || countKeywordMatches(msg.SourceContent, query) > 0 {
+				matched = append(matched, msg)
+				included[msg.ID] = true
+			}
+		}
+	}
+
+	// 不足 limit 条时补充前几条消息
+	for i := range doc.Messages {
+		if len(matched)+len(context) >= limit {
+			break
+		}
+
+		msg := &doc.Messages[i]
+		if !matchesRole(msg, role) || included[msg.ID] {
+			continue
+		}
+
+		context = append(context, msg)
+	}
+
+	return matched, context
+}
+
+// messageRoleFilter 构建消息角色的 term 过滤条件
+func messageRoleFilter(role string) map[string]interface{} {
+	return map[string]interface{}{
+		"term": map[string]interface{}{
+			"messages.role": role,
+		},
+	}
+}
+
+// messageMatchQueries 返回消息内容的关键词查询，按优先级依次为精确短语、标准匹配、词级别匹配和部分匹配，
+// 嵌套消息使用 prefix "messages."，独立消息索引使用空前缀
+func messageMatchQueries(query, prefix string) [4]map[string]interface{} {
+	content, sourceContent := prefix+"content", prefix+"source_content"
+	return [4]map[string]interface{}{
+		{
+			"multi_match": map[string]interface{}{
+				"query":  query,
+				"fields": []string{content + ".exact^10", sourceContent + ".exact^8"},
+				"type":   "phrase",
+				"slop":   0,
+			},
+		},
+		{
+			"multi_match": map[string]interface{}{
+				"query":     query,
+				"fields":    []string{content + "^8", sourceContent + "^6"},
+				"type":      "best_fields",
+				"fuzziness": "AUTO",
+			},
+		},
+		{
+			"multi_match": map[string]interface{}{
+				"query":    query,
+				"fields":   []string{content + "^5", sourceContent + "^4"},
+				"type":     "cross_fields",
+				"operator": "and", // 所有词都必须匹配
+			},
+		},
+		{
+			"multi_match": map[string]interface{}{
+				"query":    query,
+				"fields":   []string{content + "^2", sourceContent + "^1"},
+				"type":     "best_fields",
+				"operator": "or", // 任意词匹配即可
+			},
+		},
+	}
+}
+
+// nestedMessages 构建嵌套消息子句；innerHits 非空且需要后置过滤时，通过同名 inner_hits 返回
+// 最多 innerHitMessages 条匹配的消息
+func nestedMessages(params SearchParams, query map[string]interface{}, innerHits string) map[string]interface{} {
+	nested := map[string]interface{}{
+		"path":  "messages",
+		"query": withMessageRole(params.Role, query),
+	}
+	if innerHits != "" && messagesFromInnerHits(params) {
+		nested["inner_hits"] = map[string]interface{}{
+			"name":    innerHits,
+			"size":    innerHitMessages,
+			"_source": innerHitMessageFields,
+		}
+	}
+	return map[string]interface{}{"nested": nested}
+}
+
+// parseInnerHitMessages 合并各嵌套消息子句 inner_hits 中的消息，按 ID 去重并按对话内顺序排序
+func (r *ElasticsearchRepositoryImpl) parseInnerHitMessages(innerHits map[string]interface{}) []models.MessageDocument {
+	var messages []models.MessageDocument
+	seen := make(map[uuid.UUID]bool)
+	for _, name := range []string{innerHitsExactMessages, innerHitsAnyMessages} {
+		result, _ := innerHits[name].(map[string]interface{})
+		hits, _ := result["hits"].(map[string]interface{})
+		items, _ := hits["hits"].([]interface{})
+		for _, item := range items {
+			hit, _ := item.(map[string]interface{})
+			source, ok := hit["_source"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			var message models.MessageDocument
+			if err := r.parseMessageDocument(source, &message); err != nil || seen[message.ID] {
+				continue
+			}
+			seen[message.ID] = true
+			messages = append(messages, message)
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return models.MessageDocumentLess(&messages[i], &messages[j])
+	})
+	return messages
+}
+
+// withMessageRole 为嵌套消息查询添加角色过滤，只让指定角色的消息参与评分
+func withMessageRole(role *string, query map[string]interface{}) map[string]interface{} {
+	if role == nil {
+		return query
+	}
+
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must":   query,
+			"filter": messageRoleFilter(*role),
+		},
+	}
+}
+
+// matchesRole 检查消息是否符合角色过滤条件
+func matchesRole(msg *models.MessageDocument, role *string) bool {
+	return role == nil || msg.Role == *role
+}
+
 // parseSearchResponse 解析 ES 搜索响应
 func (r *ElasticsearchRepositoryImpl) parseSearchResponse(response map[string]interface{}) ([]*models.ConversationDocument, int64, error) {
 	// 提取总数
@@ -499,11 +1150,9 @@ func (r *ElasticsearchRepositoryImpl) parseSearchResponse(response map[string]in
 }
 
 // searchConversationDocumentsWithHighlights 在 ES 中搜索 conversation 文档并返回高亮信息
-func (r *ElasticsearchRepositoryImpl) searchConversationDocumentsWithHighlights(query string, userID *uuid.UUID, providerID *string, tagID *uuid.UUID, startDate, endDate *time.Time, page, limit int) ([]*models.ConversationDocument, []map[string]interface{}, int64, error) {
-	ctx := context.Background()
-
+func (r *ElasticsearchRepositoryImpl) searchConversationDocumentsWithHighlights(ctx context.Context, params SearchParams) ([]*models.ConversationDocument, []map[string]interface{}, int64, *models.SearchMeta, error) {
 	// 构建 ES 查询
-	searchQuery := r.buildSearchQuery(query, userID, providerID, tagID, startDate, endDate, page, limit)
+	searchQuery := r.buildSearchQuery(params)
 
 	// 执行搜索
 	req := esapi.SearchRequest{
@@ -513,7 +1162,7 @@ func (r *ElasticsearchRepositoryImpl) searchConversationDocumentsWithHighlights(
 
 	res, err := req.Do(ctx, r.esClient)
 	if err != nil {
-		return nil, nil, 0, fmt.Errorf("failed to execute search: %w", err)
+		return nil, nil, 0, nil, fmt.Errorf("failed to execute search: %w", err)
 	}
 	defer res.Body.Close()
 
@@ -521,19 +1170,65 @@ func (r *ElasticsearchRepositoryImpl) searchConversationDocumentsWithHighlights(
 		// 读取错误响应体以获取更详细的错误信息
 		var errorResponse map[string]interface{}
 		if err := json.NewDecoder(res.Body).Decode(&errorResponse); err == nil {
-			return nil, nil, 0, fmt.Errorf("search request failed with status: %s, error: %v", res.Status(), errorResponse)
+			return nil, nil, 0, nil, fmt.Errorf("search request failed with status: %s, error: %v", res.Status(), errorResponse)
 		}
-		return nil, nil, 0, fmt.Errorf("search request failed with status: %s", res.Status())
+		return nil, nil, 0, nil, fmt.Errorf("search request failed with status: %s", res.Status())
 	}
 
 	// 解析响应
 	var searchResponse map[string]interface{}
 	if err := json.NewDecoder(res.Body).Decode(&searchResponse); err != nil {
-		return nil, nil, 0, fmt.Errorf("failed to decode search response: %w", err)
+		return nil, nil, 0, nil, fmt.Errorf("failed to decode search response: %w", err)
+	}
+
+	// 提取 ES 执行信息
+	meta := parseSearchMeta(searchResponse)
+	if meta.ShardsFailed > 0 || meta.TimedOut {
+		logger.FromContext(ctx).Warn("Elasticsearch search returned partial results",
+			zap.String("index", r.indexName),
+			zap.Int("shards_total", meta.ShardsTotal),
+			zap.Int("shards_failed", meta.ShardsFailed),
+			zap.Bool("timed_out", meta.TimedOut),
+		)
 	}
 
 	// 提取结果和高亮信息
-	return r.parseSearchResponseWithHighlights(searchResponse)
+	docs, highlights, total, err := r.parseSearchResponseWithHighlights(searchResponse)
+	if err != nil {
+		return nil, nil, 0, nil, err
+	}
+
+	return docs, highlights, total, meta, nil
+}
+
+// parseSearchMeta 提取 ES 响应中的 took 和 _shards 信息
+func parseSearchMeta(response map[string]interface{}) *models.SearchMeta {
+	meta := &models.SearchMeta{}
+
+	if took, ok := response["took"].(float64); ok {
+		meta.TookMs = int64(took)
+	}
+
+	if timedOut, ok := response["timed_out"].(bool); ok {
+		meta.TimedOut = timedOut
+	}
+
+	if shards, ok := response["_shards"].(map[string]interface{}); ok {
+		if v, ok := shards["total"].(float64); ok {
+			meta.ShardsTotal = int(v)
+		}
+		if v, ok := shards["successful"].(float64); ok {
+			meta.ShardsSuccessful = int(v)
+		}
+		if v, ok := shards["skipped"].(float64); ok {
+			meta.ShardsSkipped = int(v)
+		}
+		if v, ok := shards["failed"].(float64); ok {
+			meta.ShardsFailed = int(v)
+		}
+	}
+
+	return meta
 }
 
 // parseDocument 解析单个文档
@@ -584,6 +1279,26 @@ func (r *ElasticsearchRepositoryImpl) parseDocument(source map[string]interface{
 		}
 	}
 
+	if lastMessageAt, ok := source["last_message_at"].(string); ok {
+		if parsed, err := time.Parse(time.RFC3339Nano, lastMessageAt); err == nil {
+			doc.LastMessageAt = &parsed
+		}
+	}
+
+	if contentHash, ok := source["content_hash"].(string); ok {
+		doc.ContentHash = contentHash
+	}
+
+	// 解析元信息
+	if metadata, ok := source["metadata"].(map[string]interface{}); ok {
+		doc.Metadata = make(map[string]string, len(metadata))
+		for key, value := range metadata {
+			if str, ok := value.(string); ok {
+				doc.Metadata[key] = str
+			}
+		}
+	}
+
 	// 解析嵌套的 messages
 	if messages, ok := source["messages"].([]interface{}); ok {
 		doc.Messages = make([]models.MessageDocument, 0, len(messages))
@@ -644,6 +1359,10 @@ func (r *ElasticsearchRepositoryImpl) parseMessageDocument(source map[string]int
 		doc.SourceContent = sourceContent
 	}
 
+	if sequence, ok := source["sequence"].(float64); ok {
+		doc.Sequence = int(sequence)
+	}
+
 	// 解析时间字段
 	if createdAt, ok := source["created_at"].(string); ok {
 		if parsed, err := json.Marshal(createdAt); err == nil {
@@ -737,6 +1456,11 @@ func (r *ElasticsearchRepositoryImpl) parseSearchResponseWithHighlights(response
 			continue // 跳过解析失败的文档
 		}
 
+		// 后置过滤用的匹配消息
+		if innerHits, ok := hitMap["inner_hits"].(map[string]interface{}); ok {
+			doc.Messages = r.parseInnerHitMessages(innerHits)
+		}
+
 		// 提取高亮信息
 		highlight := make(map[string]interface{})
 		if highlightData, exists := hitMap["highlight"]; exists {
@@ -786,46 +1510,68 @@ func countKeywordMatches(text, keyword string) int {
 	lowerText := strings.ToLower(text)
 	lowerKeyword := strings.ToLower(keyword)
 
-	// 使用词边界匹配，确保精确匹配
-	// 例如："英语" 不会匹配 "英无语"
 	count := 0
 	start := 0
-
 	for {
-		pos := strings.Index(lowerText[start:], lowerKeyword)
+		pos := nextKeywordMatch(lowerText, lowerKeyword, start)
 		if pos == -1 {
 			break
 		}
+		count++
+		start = pos + len(lowerKeyword)
+	}
+
+	return count
+}
+
+// nextKeywordMatch 从 start 开始查找满足词边界的下一个匹配位置（字节偏移），没有时返回 -1。
+// 例如："英语" 不会匹配 "英无语"
+func nextKeywordMatch(lowerText, lowerKeyword string, start int) int {
+	for start <= len(lowerText) {
+		pos := strings.Index(lowerText[start:], lowerKeyword)
+		if pos == -1 {
+			return -1
+		}
 
 		actualPos := start + pos
 
-		// 检查词边界
+		// 检查前一个字符和后一个字符是否为词边界
 		isWordBoundary := true
-
-		// 检查前一个字符
-		if actualPos > 0 {
-			prevChar := lowerText[actualPos-1]
-			if isWordChar(prevChar) {
-				isWordBoundary = false
-			}
+		if actualPos > 0 && isWordChar(lowerText[actualPos-1]) {
+			isWordBoundary = false
 		}
-
-		// 检查后一个字符
-		if actualPos+len(lowerKeyword) < len(lowerText) {
-			nextChar := lowerText[actualPos+len(lowerKeyword)]
-			if isWordChar(nextChar) {
-				isWordBoundary = false
-			}
+		if end := actualPos + len(lowerKeyword); end < len(lowerText) && isWordChar(lowerText[end]) {
+			isWordBoundary = false
 		}
 
 		if isWordBoundary {
-			count++
+			return actualPos
 		}
 
 		start = actualPos + len(lowerKeyword)
 	}
+	return -1
+}
 
-	return count
+// FindKeywordOffset 返回关键词在文本中第一次出现的位置（按字符计），忽略大小写。
+// 优先返回满足词边界的精确匹配，否则返回第一个包含匹配，都没有时返回 -1
+func FindKeywordOffset(text, keyword string) int {
+	if text == "" || keyword == "" {
+		return -1
+	}
+
+	// strings.ToLower 按字符一一映射，小写文本中的字符位置与原文一致
+	lowerText := strings.ToLower(text)
+	lowerKeyword := strings.ToLower(keyword)
+
+	pos := nextKeywordMatch(lowerText, lowerKeyword, 0)
+	if pos == -1 {
+		pos = strings.Index(lowerText, lowerKeyword)
+	}
+	if pos == -1 {
+		return -1
+	}
+	return utf8.RuneCountInString(lowerText[:pos])
 }
 
 // isWordChar 检查字符是否为单词字符
@@ -834,36 +1580,33 @@ func isWordChar(c byte) bool {
 		(c >= '0' && c <= '9') || c == '_' || c >= 128 // 包含中文字符
 }
 
-// calculateRelevanceScore 计算相关性评分
-func calculateRelevanceScore(conversationDoc *models.ConversationDocument, keyword string) float64 {
+// calculateRelevanceScore 计算相关性评分，maxMessages > 0 时只统计前 maxMessages 条消息
+func calculateRelevanceScore(conversationDoc *models.ConversationDocument, keyword string, maxMessages int, fields []string) float64 {
 	score := 0.0
 
-	// 计算标题匹配
-	title := conversationDoc.Title
-	if title == "" {
-		title = conversationDoc.SourceTitle
+	// 计算标题匹配，原始标题与规范化标题通常相同，取较多的一方避免重复计分
+	if searchesField(fields, SearchFieldTitle) {
+		titleMatches := max(countRelevanceMatches(conversationDoc.Title, keyword), countRelevanceMatches(conversationDoc.SourceTitle, keyword))
+		score += float64(titleMatches) * 10.0 // 标题匹配权重最高
 	}
 
-	titleMatches := countRelevanceMatches(title, keyword)
-	score += float64(titleMatches) * 10.0 // 标题匹配权重最高
-
 	// 计算消息内容匹配
-	messageMatches := 0
-	for _, msg := range conversationDoc.Messages {
-		content := msg.Content
-		if content == "" {
-			content = msg.SourceContent
+	if searchesField(fields, SearchFieldMessages) {
+		messageMatches := 0
+		for _, msg := range limitMessages(conversationDoc.Messages, maxMessages) {
+			messageMatches += max(countRelevanceMatches(msg.Content, keyword), countRelevanceMatches(msg.SourceContent, keyword))
 		}
-		messageMatches += countRelevanceMatches(content, keyword)
+		score += float64(messageMatches) * 5.0 // 消息匹配权重中等
 	}
-	score += float64(messageMatches) * 5.0 // 消息匹配权重中等
 
 	// 计算标签匹配
-	tagMatches := 0
-	for _, tag := range conversationDoc.Tags {
-		tagMatches += countRelevanceMatches(tag.Name, keyword)
+	if searchesField(fields, SearchFieldTags) {
+		tagMatches := 0
+		for _, tag := range conversationDoc.Tags {
+			tagMatches += countRelevanceMatches(tag.Name, keyword)
+		}
+		score += float64(tagMatches) * 8.0 // 标签匹配权重较高
 	}
-	score += float64(tagMatches) * 8.0 // 标签匹配权重较高
 
 	return score
 }
@@ -902,39 +1645,85 @@ func countRelevanceMatches(text, keyword string) int {
 	return 0
 }
 
-// hasExactMatch 检查对话是否包含相关匹配的关键词
-func (r *ElasticsearchRepositoryImpl) hasExactMatch(doc *models.ConversationDocument, keyword string) bool {
-	// 检查标题
-	title := doc.Title
-	if title == "" {
-		title = doc.SourceTitle
-	}
-
-	if r.containsKeyword(title, keyword) {
+// hasExactMatch 检查对话的选中字段组中是否包含相关匹配的关键词
+func (r *ElasticsearchRepositoryImpl) hasExactMatch(doc *models.ConversationDocument, keyword string, role *string, fields []string) bool {
+	// 检查标题，title 和 source_title 分别判断
+	if searchesField(fields, SearchFieldTitle) && (r.containsKeyword(doc.Title, keyword) || r.containsKeyword(doc.SourceTitle, keyword)) {
 		return true
 	}
 
-	// 检查消息内容
-	for _, msg := range doc.Messages {
-		content := msg.Content
-		if content == "" {
-			content = msg.SourceContent
-		}
-		if r.containsKeyword(content, keyword) {
-			return true
+	// 检查消息内容，content 和 source_content 分别判断；检查全部消息，post_process_max_messages 只限制评分，
+	// 否则关键词只出现在靠后消息中的对话会被丢弃
+	if searchesField(fields, SearchFieldMessages) {
+		for _, msg := range doc.Messages {
+			if !matchesRole(&msg, role) {
+				continue
+			}
+
+			if r.containsKeyword(msg.Content, keyword) || r.containsKeyword(msg.SourceContent, keyword) {
+				return true
+			}
 		}
 	}
 
 	// 检查标签
-	for _, tag := range doc.Tags {
-		if r.containsKeyword(tag.Name, keyword) {
-			return true
+	if searchesField(fields, SearchFieldTags) {
+		for _, tag := range doc.Tags {
+			if r.containsKeyword(tag.Name, keyword) {
+				return true
+			}
 		}
 	}
 
 	return false
 }
 
+// evaluateCandidates 检查每个候选命中是否精确匹配关键词，需要时同时计算相关性评分。
+// 每个对话在共享的并发槽位内处理，槽位数限制了所有请求同时占用的 CPU
+func (r *ElasticsearchRepositoryImpl) evaluateCandidates(docs []*models.ConversationDocument, keyword string, role *string, fields []string, score bool) ([]bool, []float64) {
+	matched := make([]bool, len(docs))
+	scores := make([]float64, len(docs))
+
+	var wg sync.WaitGroup
+	for i, doc := range docs {
+		r.postProcessSlots <- struct{}{}
+		wg.Add(1)
+		go func(i int, doc *models.ConversationDocument) {
+			defer wg.Done()
+			defer func() { <-r.postProcessSlots }()
+
+			matched[i] = r.hasExactMatch(doc, keyword, role, fields)
+			if matched[i] && score {
+				scores[i] = calculateRelevanceScore(doc, keyword, r.postProcessMaxMessages, fields)
+			}
+		}(i, doc)
+	}
+	wg.Wait()
+
+	return matched, scores
+}
+
+// limitMessages 返回对话中前 maxMessages 条消息，maxMessages <= 0 时不限制
+func limitMessages(messages []models.MessageDocument, maxMessages int) []models.MessageDocument {
+	if maxMessages <= 0 {
+		return messages
+	}
+
+	// inner_hits 只返回匹配的消息，按消息在对话中的位置限制；位置未知时按顺序截取
+	for i, msg := range messages {
+		if msg.Sequence == 0 {
+			if len(messages) > maxMessages {
+				return messages[:maxMessages]
+			}
+			return messages
+		}
+		if msg.Sequence > maxMessages {
+			return messages[:i]
+		}
+	}
+	return messages
+}
+
 // containsKeyword 检查文本是否包含关键词（更宽松的匹配）
 func (r *ElasticsearchRepositoryImpl) containsKeyword(text, keyword string) bool {
 	if text == "" || keyword == "" {
@@ -958,19 +1747,27 @@ func (r *ElasticsearchRepositoryImpl) containsKeyword(text, keyword string) bool
 	return false
 }
 
-// sortByRelevance 按相关性评分排序对话
-func (r *ElasticsearchRepositoryImpl) sortByRelevance(docs []*models.ConversationDocument, keyword string) {
-	// 使用简单的冒泡排序，按相关性评分降序排列
-	n := len(docs)
-	for i := 0; i < n-1; i++ {
-		for j := 0; j < n-i-1; j++ {
-			score1 := calculateRelevanceScore(docs[j], keyword)
-			score2 := calculateRelevanceScore(docs[j+1], keyword)
+// sortByScores 按预先计算的相关性评分降序排列对话，highlights 与 docs 一一对应并随之调整顺序。
+// 评分相同时按创建时间倒序，时间也相同时保持 ES 的原始顺序
+func sortByScores(docs []*models.ConversationDocument, highlights []map[string]interface{}, scores []float64) {
+	indexes := make([]int, len(docs))
+	for i := range docs {
+		indexes[i] = i
+	}
 
-			if score1 < score2 {
-				// 交换位置
-				docs[j], docs[j+1] = docs[j+1], docs[j]
-			}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		if scores[indexes[a]] != scores[indexes[b]] {
+			return scores[indexes[a]] > scores[indexes[b]]
 		}
+		return docs[indexes[a]].CreatedAt.After(docs[indexes[b]].CreatedAt)
+	})
+
+	sortedDocs := make([]*models.ConversationDocument, len(docs))
+	sortedHighlights := make([]map[string]interface{}, len(highlights))
+	for i, idx := range indexes {
+		sortedDocs[i] = docs[idx]
+		sortedHighlights[i] = highlights[idx]
 	}
+	copy(docs, sortedDocs)
+	copy(highlights, sortedHighlights)
 }
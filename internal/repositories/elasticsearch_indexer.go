@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
 
 	"chat-assistant-backend/internal/models"
@@ -28,39 +29,144 @@ type ElasticsearchIndexer interface {
 	// 从 conversation 中删除 message
 	RemoveMessageFromConversation(conversationID uuid.UUID, messageID uuid.UUID) error
 
+	// 从 conversation 中批量删除 messages
+	RemoveMessagesFromConversation(conversationID uuid.UUID, messageIDs []uuid.UUID) error
+
 	// 删除整个 conversation
 	DeleteConversation(conversationID uuid.UUID) error
 
+	// 删除该用户除 keep 以外的所有 conversation，返回删除的文档数
+	DeleteUserConversationsExcept(userID uuid.UUID, keep []uuid.UUID) (int64, error)
+
 	// 批量索引 conversations
 	BulkIndexConversations(docs []*models.ConversationDocument) error
 
 	// 更新 conversation 基本信息（不包含 messages）
 	UpdateConversation(doc *models.ConversationDocument) error
 
+	// 更新所有包含该标签的 conversation 中的标签名称
+	UpdateTagInConversations(tagID uuid.UUID, name string) error
+
+	// 从所有包含该标签的 conversation 中移除该标签
+	RemoveTagFromConversations(tagID uuid.UUID) error
+
 	// 检查 conversation 是否存在
 	ConversationExists(conversationID uuid.UUID) (bool, error)
+
+	// 列出索引中所有 conversation 的内容哈希，未记录哈希的文档对应空字符串
+	ContentHashes() (map[uuid.UUID]string, error)
+}
+
+// Refresh values accepted by Elasticsearch write APIs
+const (
+	RefreshTrue    = "true"     // 立即刷新，写入后马上可搜索，开销最大
+	RefreshFalse   = "false"    // 不刷新，依赖索引的 refresh_interval
+	RefreshWaitFor = "wait_for" // 等待下一次定时刷新后返回，不额外触发刷新
+)
+
+// RefreshPolicy controls the refresh parameter sent with indexer writes
+type RefreshPolicy struct {
+	Write string // 单文档写入：索引、更新、删除对话或消息
+	Bulk  string // 批量写入：BulkIndexConversations 以及按标签的 _update_by_query
+}
+
+// DefaultRefreshPolicy waits for the next refresh on single-document writes and does not
+// refresh on bulk writes
+func DefaultRefreshPolicy() RefreshPolicy {
+	return RefreshPolicy{Write: RefreshWaitFor, Bulk: RefreshFalse}
+}
+
+// IndexerOptions configures ElasticsearchIndexerImpl
+type IndexerOptions struct {
+	Refresh RefreshPolicy
+	// MaxMessageChars 索引时消息内容的最大字符数，超出部分截断（数据库保留完整内容），0 表示不限制
+	MaxMessageChars int
+	// MaxMessages 每个对话文档最多索引的消息数，只保留最近的消息（数据库保留全部消息），0 表示不限制
+	MaxMessages int
+	// Deleter 执行 _delete_by_query，为空时直接通过 esClient 发送请求
+	Deleter QueryDeleter
+	// MessageIndex 独立消息索引，非空时每条消息还会作为单独的文档写入该索引（不受 MaxMessages 限制），
+	// 供 FlatMessageSearchRepository 搜索；为空时只写入对话文档中的嵌套消息
+	MessageIndex string
+}
+
+// QueryDeleter deletes the documents of an index matching a query on the server side,
+// see elasticsearch.Client.DeleteByQuery
+type QueryDeleter interface {
+	DeleteByQuery(ctx context.Context, index string, query []byte) (int64, error)
+}
+
+// esQueryDeleter 直接通过 go-elasticsearch 客户端执行 DeleteByQuery
+type esQueryDeleter struct {
+	esClient *es.Client
+}
+
+func (d esQueryDeleter) DeleteByQuery(ctx context.Context, index string, query []byte) (int64, error) {
+	return DeleteByQuery(ctx, d.esClient, index, query)
 }
 
 // ElasticsearchIndexerImpl 默认的索引器实现
 type ElasticsearchIndexerImpl struct {
-	esClient  *es.Client
-	indexName string
+	esClient        *es.Client
+	indexName       string
+	refresh         RefreshPolicy
+	maxMessageChars int
+	maxMessages     int
+	deleter         QueryDeleter
+	messageIndex    string
 }
 
-// NewElasticsearchIndexer 创建新的索引器
+// NewElasticsearchIndexer 创建新的索引器，使用默认刷新策略，不限制消息长度和消息数
 func NewElasticsearchIndexer(esClient *es.Client, indexName string) ElasticsearchIndexer {
+	return NewElasticsearchIndexerWithOptions(esClient, indexName, IndexerOptions{Refresh: DefaultRefreshPolicy()})
+}
+
+// NewElasticsearchIndexerWithOptions 创建使用指定选项的索引器，刷新策略为空值或无效值时使用默认策略
+func NewElasticsearchIndexerWithOptions(esClient *es.Client, indexName string, opts IndexerOptions) ElasticsearchIndexer {
+	refresh := opts.Refresh
+	defaults := DefaultRefreshPolicy()
+	if !isValidRefresh(refresh.Write) {
+		refresh.Write = defaults.Write
+	}
+	if !isValidRefresh(refresh.Bulk) {
+		refresh.Bulk = defaults.Bulk
+	}
+
+	deleter := opts.Deleter
+	if deleter == nil {
+		deleter = esQueryDeleter{esClient: esClient}
+	}
+
 	return &ElasticsearchIndexerImpl{
-		esClient:  esClient,
-		indexName: indexName,
+		esClient:        esClient,
+		indexName:       indexName,
+		refresh:         refresh,
+		maxMessageChars: opts.MaxMessageChars,
+		maxMessages:     opts.MaxMessages,
+		deleter:         deleter,
+		messageIndex:    opts.MessageIndex,
 	}
 }
 
+// indexedDocument returns the document as written to the index: the content hash is
+// computed from the full messages, then only the last maxMessages messages are kept and
+// each is truncated to maxMessageChars. The caller's document is not modified
+func (i *ElasticsearchIndexerImpl) indexedDocument(doc *models.ConversationDocument) *models.ConversationDocument {
+	indexed := *doc
+	indexed.ContentHash = doc.ComputeContentHash()
+	return indexed.WithRecentMessages(i.maxMessages).WithTruncatedMessages(i.maxMessageChars)
+}
+
+func isValidRefresh(value string) bool {
+	return value == RefreshTrue || value == RefreshFalse || value == RefreshWaitFor
+}
+
 // IndexConversation 索引 conversation 文档
 func (i *ElasticsearchIndexerImpl) IndexConversation(doc *models.ConversationDocument) error {
 	ctx := context.Background()
 
 	// 序列化文档
-	docBytes, err := json.Marshal(doc)
+	docBytes, err := json.Marshal(i.indexedDocument(doc))
 	if err != nil {
 		return fmt.Errorf("failed to marshal conversation document: %w", err)
 	}
@@ -70,7 +176,7 @@ func (i *ElasticsearchIndexerImpl) IndexConversation(doc *models.ConversationDoc
 		Index:      i.indexName,
 		DocumentID: doc.ID.String(),
 		Body:       bytes.NewReader(docBytes),
-		Refresh:    "true",
+		Refresh:    i.refresh.Write,
 	}
 
 	// 执行请求
@@ -84,19 +190,30 @@ func (i *ElasticsearchIndexerImpl) IndexConversation(doc *models.ConversationDoc
 		return fmt.Errorf("index request failed with status: %s", res.Status())
 	}
 
-	return nil
+	// 新建的对话在独立消息索引中没有旧消息
+	var result struct {
+		Result string `json:"result"`
+	}
+	created := map[uuid.UUID]bool{doc.ID: json.NewDecoder(res.Body).Decode(&result) == nil && result.Result == "created"}
+	return i.replaceFlatMessages(ctx, []*models.ConversationDocument{doc}, created, i.refresh.Write)
 }
 
 // AddMessageToConversation 向 conversation 添加 message
 func (i *ElasticsearchIndexerImpl) AddMessageToConversation(conversationID uuid.UUID, message models.MessageDocument) error {
 	ctx := context.Background()
+	message.Truncate(i.maxMessageChars)
 
-	// 构建脚本，向 messages 数组添加新消息
+	// 构建脚本，向 messages 数组添加新消息。消息按时间顺序排列，
+	// 超过 maxMessages（大于 0 时）从头部移除最早的消息并标记截断
 	script := `
 		if (ctx._source.messages == null) {
 			ctx._source.messages = []
 		}
 		ctx._source.messages.add(params.message)
+		if (params.maxMessages > 0 && ctx._source.messages.size() > params.maxMessages) {
+			ctx._source.messages.subList(0, ctx._source.messages.size() - params.maxMessages).clear()
+			ctx._source.messages_truncated = true
+		}
 	`
 
 	// 序列化消息
@@ -115,7 +232,8 @@ func (i *ElasticsearchIndexerImpl) AddMessageToConversation(conversationID uuid.
 		"script": map[string]interface{}{
 			"source": script,
 			"params": map[string]interface{}{
-				"message": messageData,
+				"message":     messageData,
+				"maxMessages": i.maxMessages,
 			},
 		},
 	}
@@ -129,7 +247,7 @@ func (i *ElasticsearchIndexerImpl) AddMessageToConversation(conversationID uuid.
 		Index:      i.indexName,
 		DocumentID: conversationID.String(),
 		Body:       bytes.NewReader(updateBytes),
-		Refresh:    "true",
+		Refresh:    i.refresh.Write,
 	}
 
 	res, err := req.Do(ctx, i.esClient)
@@ -142,12 +260,13 @@ func (i *ElasticsearchIndexerImpl) AddMessageToConversation(conversationID uuid.
 		return fmt.Errorf("update request failed with status: %s", res.Status())
 	}
 
-	return nil
+	return i.indexFlatMessage(ctx, conversationID, message)
 }
 
 // UpdateMessageInConversation 更新 conversation 中的 message
 func (i *ElasticsearchIndexerImpl) UpdateMessageInConversation(conversationID uuid.UUID, message models.MessageDocument) error {
 	ctx := context.Background()
+	message.Truncate(i.maxMessageChars)
 
 	// 构建脚本，更新 messages 数组中的特定消息
 	script := `
@@ -192,7 +311,7 @@ func (i *ElasticsearchIndexerImpl) UpdateMessageInConversation(conversationID uu
 		Index:      i.indexName,
 		DocumentID: conversationID.String(),
 		Body:       bytes.NewReader(updateBytes),
-		Refresh:    "true",
+		Refresh:    i.refresh.Write,
 	}
 
 	res, err := req.Do(ctx, i.esClient)
@@ -205,7 +324,7 @@ func (i *ElasticsearchIndexerImpl) UpdateMessageInConversation(conversationID uu
 		return fmt.Errorf("update request failed with status: %s", res.Status())
 	}
 
-	return nil
+	return i.indexFlatMessage(ctx, conversationID, message)
 }
 
 // RemoveMessageFromConversation 从 conversation 中删除 message
@@ -238,7 +357,7 @@ func (i *ElasticsearchIndexerImpl) RemoveMessageFromConversation(conversationID
 		Index:      i.indexName,
 		DocumentID: conversationID.String(),
 		Body:       bytes.NewReader(updateBytes),
-		Refresh:    "true",
+		Refresh:    i.refresh.Write,
 	}
 
 	res, err := req.Do(ctx, i.esClient)
@@ -251,7 +370,62 @@ func (i *ElasticsearchIndexerImpl) RemoveMessageFromConversation(conversationID
 		return fmt.Errorf("update request failed with status: %s", res.Status())
 	}
 
-	return nil
+	return i.deleteFlatMessages(ctx, []uuid.UUID{messageID})
+}
+
+// RemoveMessagesFromConversation 在一次请求中从 conversation 删除多条 message
+func (i *ElasticsearchIndexerImpl) RemoveMessagesFromConversation(conversationID uuid.UUID, messageIDs []uuid.UUID) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	// 构建脚本，按 ID 列表从 messages 数组中删除消息
+	script := `
+		if (ctx._source.messages != null) {
+			ctx._source.messages.removeIf(m -> params.ids.contains(m.id))
+		}
+	`
+
+	ids := make([]string, len(messageIDs))
+	for idx, id := range messageIDs {
+		ids[idx] = id.String()
+	}
+
+	// 构建更新请求
+	updateBody := map[string]interface{}{
+		"script": map[string]interface{}{
+			"source": script,
+			"params": map[string]interface{}{
+				"ids": ids,
+			},
+		},
+	}
+
+	updateBytes, err := json.Marshal(updateBody)
+	if err != nil {
+		return fmt.Errorf("failed to marshal update body: %w", err)
+	}
+
+	req := esapi.UpdateRequest{
+		Index:      i.indexName,
+		DocumentID: conversationID.String(),
+		Body:       bytes.NewReader(updateBytes),
+		Refresh:    i.refresh.Write,
+	}
+
+	res, err := req.Do(ctx, i.esClient)
+	if err != nil {
+		return fmt.Errorf("failed to remove messages from conversation: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("update request failed with status: %s", res.Status())
+	}
+
+	return i.deleteFlatMessages(ctx, messageIDs)
 }
 
 // DeleteConversation 删除整个 conversation
@@ -261,7 +435,7 @@ func (i *ElasticsearchIndexerImpl) DeleteConversation(conversationID uuid.UUID)
 	req := esapi.DeleteRequest{
 		Index:      i.indexName,
 		DocumentID: conversationID.String(),
-		Refresh:    "true",
+		Refresh:    i.refresh.Write,
 	}
 
 	res, err := req.Do(ctx, i.esClient)
@@ -274,9 +448,192 @@ func (i *ElasticsearchIndexerImpl) DeleteConversation(conversationID uuid.UUID)
 		return fmt.Errorf("delete request failed with status: %s", res.Status())
 	}
 
+	return i.deleteFlatConversationMessages(ctx, []uuid.UUID{conversationID}, 0, i.refresh.Write)
+}
+
+// deleteBatchSize 按用户清理时每次列出的对话数（聚合分页大小和单个 _delete_by_query 中的 ID 数），
+// 远小于 index.max_terms_count（默认 65536）
+const deleteBatchSize = 1000
+
+// DeleteUserConversationsExcept 删除该用户不在 keep 中的 conversation 及其独立索引中的消息，
+// 用于按用户重新同步时清理数据库中已不存在（或已转移给其他用户）的文档；keep 为空时删除该用户的全部文档。
+// 需要删除的对话 ID 通过 composite 聚合分页读取，再分批执行 _delete_by_query，查询中不会列出 keep，
+// 对话数超过 index.max_terms_count 时也不会失败
+func (i *ElasticsearchIndexerImpl) DeleteUserConversationsExcept(userID uuid.UUID, keep []uuid.UUID) (int64, error) {
+	ctx := context.Background()
+
+	if len(keep) == 0 {
+		deleted, err := i.deleteUserDocuments(ctx, i.indexName, userID, nil)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete user conversations: %w", err)
+		}
+		if i.messageIndex != "" {
+			if _, err := i.deleteUserDocuments(ctx, i.messageIndex, userID, nil); err != nil {
+				return 0, fmt.Errorf("failed to delete user messages: %w", err)
+			}
+		}
+		return deleted, nil
+	}
+
+	kept := make(map[string]bool, len(keep))
+	for _, id := range keep {
+		kept[id.String()] = true
+	}
+
+	var deleted int64
+	err := i.forEachUserConversationBatch(ctx, i.indexName, "id", userID, kept, func(ids []string) error {
+		n, err := i.deleteUserDocuments(ctx, i.indexName, userID, map[string]interface{}{
+			"ids": map[string]interface{}{"values": ids},
+		})
+		deleted += n
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete user conversations: %w", err)
+	}
+
+	// 独立消息索引中的消息带有 user_id 和 conversation_id，按同样的方式分批删除，不计入返回的对话数
+	if i.messageIndex != "" {
+		err := i.forEachUserConversationBatch(ctx, i.messageIndex, "conversation_id", userID, kept, func(ids []string) error {
+			_, err := i.deleteUserDocuments(ctx, i.messageIndex, userID, map[string]interface{}{
+				"terms": map[string]interface{}{"conversation_id": ids},
+			})
+			return err
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete user messages: %w", err)
+		}
+	}
+	return deleted, nil
+}
+
+// forEachUserConversationBatch 通过 composite 聚合按 field 分页读取 index 中该用户的对话 ID，
+// 跳过 kept 中的 ID，每页剩余的 ID 交给 fn。先读取完所有页再删除，删除不影响分页位置
+func (i *ElasticsearchIndexerImpl) forEachUserConversationBatch(ctx context.Context, index, field string, userID uuid.UUID, kept map[string]bool, fn func(ids []string) error) error {
+	var stale [][]string
+	var after map[string]interface{}
+	for {
+		composite := map[string]interface{}{
+			"size":    deleteBatchSize,
+			"sources": []map[string]interface{}{{"id": map[string]interface{}{"terms": map[string]interface{}{"field": field}}}},
+		}
+		if after != nil {
+			composite["after"] = after
+		}
+		body, err := json.Marshal(map[string]interface{}{
+			"size":  0,
+			"query": map[string]interface{}{"term": map[string]interface{}{"user_id": userID.String()}},
+			"aggs":  map[string]interface{}{"ids": map[string]interface{}{"composite": composite}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal aggregation body: %w", err)
+		}
+
+		req := esapi.SearchRequest{Index: []string{index}, Body: bytes.NewReader(body)}
+		res, err := req.Do(ctx, i.esClient)
+		if err != nil {
+			return fmt.Errorf("failed to list user conversations: %w", err)
+		}
+
+		var result struct {
+			Aggregations struct {
+				IDs struct {
+					AfterKey map[string]interface{} `json:"after_key"`
+					Buckets  []struct {
+						Key struct {
+							ID string `json:"id"`
+						} `json:"key"`
+					} `json:"buckets"`
+				} `json:"ids"`
+			} `json:"aggregations"`
+		}
+		if res.IsError() {
+			res.Body.Close()
+			return fmt.Errorf("list user conversations request failed with status: %s", res.Status())
+		}
+		err = json.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode aggregation response: %w", err)
+		}
+
+		var ids []string
+		for _, bucket := range result.Aggregations.IDs.Buckets {
+			if !kept[bucket.Key.ID] {
+				ids = append(ids, bucket.Key.ID)
+			}
+		}
+		if len(ids) > 0 {
+			stale = append(stale, ids)
+		}
+		if len(result.Aggregations.IDs.Buckets) < deleteBatchSize || result.Aggregations.IDs.AfterKey == nil {
+			break
+		}
+		after = result.Aggregations.IDs.AfterKey
+	}
+
+	for _, ids := range stale {
+		if err := fn(ids); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// deleteUserDocuments 删除 index 中该用户匹配 clause 的文档，clause 为 nil 时删除该用户的全部文档
+func (i *ElasticsearchIndexerImpl) deleteUserDocuments(ctx context.Context, index string, userID uuid.UUID, clause map[string]interface{}) (int64, error) {
+	filter := []map[string]interface{}{
+		{"term": map[string]interface{}{"user_id": userID.String()}},
+	}
+	if clause != nil {
+		filter = append(filter, clause)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": filter}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal delete by query body: %w", err)
+	}
+	return i.deleter.DeleteByQuery(ctx, index, body)
+}
+
+// DeleteByQuery 通过 _delete_by_query 删除 index 中匹配的文档，query 为完整的请求体
+// （如 {"query": {"term": {"user_id": "..."}}}），返回删除的文档数。
+// 删除期间被修改的文档跳过而不是让请求失败（conflicts=proceed）；删除后立即刷新，
+// 被删除的数据不会再出现在搜索结果中（_delete_by_query 不支持 wait_for）
+func DeleteByQuery(ctx context.Context, esClient *es.Client, index string, query []byte) (int64, error) {
+	return deleteByQuery(ctx, esClient, index, query, true)
+}
+
+// deleteByQuery 执行 _delete_by_query，refresh 为 false 时依赖索引的 refresh_interval
+func deleteByQuery(ctx context.Context, esClient *es.Client, index string, query []byte, refresh bool) (int64, error) {
+	req := esapi.DeleteByQueryRequest{
+		Index:     []string{index},
+		Body:      bytes.NewReader(query),
+		Conflicts: "proceed",
+		Refresh:   &refresh,
+	}
+
+	res, err := req.Do(ctx, esClient)
+	if err != nil {
+		return 0, fmt.Errorf("delete by query request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, fmt.Errorf("delete by query request failed with status: %s", res.Status())
+	}
+
+	var result struct {
+		Deleted int64 `json:"deleted"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode delete by query response: %w", err)
+	}
+	return result.Deleted, nil
+}
+
 // BulkIndexConversations 批量索引 conversations
 func (i *ElasticsearchIndexerImpl) BulkIndexConversations(docs []*models.ConversationDocument) error {
 	if len(docs) == 0 {
@@ -300,7 +657,7 @@ func (i *ElasticsearchIndexerImpl) BulkIndexConversations(docs []*models.Convers
 		bulkBody.WriteString("\n")
 
 		// 添加文档数据
-		docBytes, err := json.Marshal(doc)
+		docBytes, err := json.Marshal(i.indexedDocument(doc))
 		if err != nil {
 			return fmt.Errorf("failed to marshal conversation document: %w", err)
 		}
@@ -311,7 +668,7 @@ func (i *ElasticsearchIndexerImpl) BulkIndexConversations(docs []*models.Convers
 	// 执行批量请求
 	req := esapi.BulkRequest{
 		Body:    strings.NewReader(bulkBody.String()),
-		Refresh: "true",
+		Refresh: i.refresh.Bulk,
 	}
 
 	res, err := req.Do(ctx, i.esClient)
@@ -324,7 +681,30 @@ func (i *ElasticsearchIndexerImpl) BulkIndexConversations(docs []*models.Convers
 		return fmt.Errorf("bulk request failed with status: %s", res.Status())
 	}
 
-	return nil
+	return i.replaceFlatMessages(ctx, docs, bulkCreatedIDs(res.Body), i.refresh.Bulk)
+}
+
+// bulkCreatedIDs 返回批量写入响应中新建（result 为 created）的文档 ID，
+// 响应无法解析时返回空集合，即视为全部已存在
+func bulkCreatedIDs(body io.Reader) map[uuid.UUID]bool {
+	var response struct {
+		Items []map[string]struct {
+			ID     string `json:"_id"`
+			Result string `json:"result"`
+		} `json:"items"`
+	}
+	created := make(map[uuid.UUID]bool)
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return created
+	}
+	for _, item := range response.Items {
+		for _, result := range item {
+			if id, err := uuid.Parse(result.ID); err == nil && result.Result == "created" {
+				created[id] = true
+			}
+		}
+	}
+	return created
 }
 
 // UpdateConversation 更新 conversation 基本信息（不包含 messages）
@@ -342,18 +722,24 @@ func (i *ElasticsearchIndexerImpl) UpdateConversation(doc *models.ConversationDo
 		return i.IndexConversation(doc)
 	}
 
-	// 构建更新文档，包含 tags 字段但不包含 messages 字段
+	// 构建更新文档，包含 tags 字段但不包含 messages 字段。last_message_at 为空时写入 null，
+	// 清除删除最后一条消息前的值；message_count 只在调用方给出时更新
 	updateDoc := map[string]interface{}{
-		"id":           doc.ID,
-		"user_id":      doc.UserID,
-		"title":        doc.Title,
-		"provider":     doc.Provider,
-		"model":        doc.Model,
-		"source_id":    doc.SourceID,
-		"source_title": doc.SourceTitle,
-		"created_at":   doc.CreatedAt,
-		"updated_at":   doc.UpdatedAt,
-		"tags":         doc.Tags,
+		"id":              doc.ID,
+		"user_id":         doc.UserID,
+		"title":           doc.Title,
+		"provider":        doc.Provider,
+		"model":           doc.Model,
+		"source_id":       doc.SourceID,
+		"source_title":    doc.SourceTitle,
+		"created_at":      doc.CreatedAt,
+		"updated_at":      doc.UpdatedAt,
+		"last_message_at": doc.LastMessageAt,
+		"metadata":        doc.Metadata,
+		"tags":            doc.Tags,
+	}
+	if doc.MessageCount != nil {
+		updateDoc["message_count"] = *doc.MessageCount
 	}
 
 	// ES 更新请求需要使用 doc 包装器
@@ -370,7 +756,7 @@ func (i *ElasticsearchIndexerImpl) UpdateConversation(doc *models.ConversationDo
 		Index:      i.indexName,
 		DocumentID: doc.ID.String(),
 		Body:       bytes.NewReader(updateBytes),
-		Refresh:    "true",
+		Refresh:    i.refresh.Write,
 	}
 
 	res, err := req.Do(ctx, i.esClient)
@@ -383,6 +769,97 @@ func (i *ElasticsearchIndexerImpl) UpdateConversation(doc *models.ConversationDo
 		return fmt.Errorf("update request failed with status: %s", res.Status())
 	}
 
+	// 对话转移给其他用户或标签变化时同步独立消息索引中复制的字段
+	return i.updateFlatMessagesConversation(ctx, doc)
+}
+
+// UpdateTagInConversations 更新所有包含该标签的 conversation 中的标签名称
+func (i *ElasticsearchIndexerImpl) UpdateTagInConversations(tagID uuid.UUID, name string) error {
+	// 构建脚本，按 ID 更新 tags 数组中的标签名称
+	script := `
+		if (ctx._source.tags != null) {
+			for (tag in ctx._source.tags) {
+				if (tag.id == params.tagId) {
+					tag.name = params.name
+				}
+			}
+		}
+	`
+
+	return i.updateConversationsByTag(tagID, script, map[string]interface{}{
+		"name": name,
+	})
+}
+
+// RemoveTagFromConversations 从所有包含该标签的 conversation 中移除该标签
+func (i *ElasticsearchIndexerImpl) RemoveTagFromConversations(tagID uuid.UUID) error {
+	// 构建脚本，从 tags 数组中删除特定标签
+	script := `
+		if (ctx._source.tags != null) {
+			ctx._source.tags.removeIf(tag -> tag.id == params.tagId)
+		}
+	`
+
+	if err := i.updateConversationsByTag(tagID, script, nil); err != nil {
+		return err
+	}
+	return i.removeFlatMessagesTag(context.Background(), tagID)
+}
+
+// updateConversationsByTag 对包含指定标签的 conversation 执行脚本更新，
+// 脚本参数中会自动带上 tagId
+func (i *ElasticsearchIndexerImpl) updateConversationsByTag(tagID uuid.UUID, script string, params map[string]interface{}) error {
+	ctx := context.Background()
+
+	scriptParams := map[string]interface{}{
+		"tagId": tagID.String(),
+	}
+	for key, value := range params {
+		scriptParams[key] = value
+	}
+
+	// 只更新包含该标签的文档
+	updateBody := map[string]interface{}{
+		"query": map[string]interface{}{
+			"nested": map[string]interface{}{
+				"path": "tags",
+				"query": map[string]interface{}{
+					"term": map[string]interface{}{
+						"tags.id": tagID.String(),
+					},
+				},
+			},
+		},
+		"script": map[string]interface{}{
+			"source": script,
+			"params": scriptParams,
+		},
+	}
+
+	updateBytes, err := json.Marshal(updateBody)
+	if err != nil {
+		return fmt.Errorf("failed to marshal update by query body: %w", err)
+	}
+
+	// _update_by_query 只支持 true/false，wait_for 视为 true
+	refresh := i.refresh.Bulk != RefreshFalse
+	req := esapi.UpdateByQueryRequest{
+		Index:     []string{i.indexName},
+		Body:      bytes.NewReader(updateBytes),
+		Conflicts: "proceed",
+		Refresh:   &refresh,
+	}
+
+	res, err := req.Do(ctx, i.esClient)
+	if err != nil {
+		return fmt.Errorf("failed to update conversations by tag: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("update by query request failed with status: %s", res.Status())
+	}
+
 	return nil
 }
 
@@ -403,3 +880,73 @@ func (i *ElasticsearchIndexerImpl) ConversationExists(conversationID uuid.UUID)
 
 	return res.StatusCode == 200, nil
 }
+
+// contentHashPageSize ContentHashes 每页读取的文档数
+const contentHashPageSize = 1000
+
+// ContentHashes 按 id 排序用 search_after 分页读取所有文档的 content_hash，不返回消息
+func (i *ElasticsearchIndexerImpl) ContentHashes() (map[uuid.UUID]string, error) {
+	ctx := context.Background()
+	hashes := make(map[uuid.UUID]string)
+
+	var searchAfter []interface{}
+	for {
+		query := map[string]interface{}{
+			"size":    contentHashPageSize,
+			"query":   map[string]interface{}{"match_all": map[string]interface{}{}},
+			"sort":    []map[string]interface{}{{"id": "asc"}},
+			"_source": []string{"id", "content_hash"},
+		}
+		if searchAfter != nil {
+			query["search_after"] = searchAfter
+		}
+		queryBytes, err := json.Marshal(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal content hash query: %w", err)
+		}
+
+		req := esapi.SearchRequest{
+			Index: []string{i.indexName},
+			Body:  bytes.NewReader(queryBytes),
+		}
+		res, err := req.Do(ctx, i.esClient)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list content hashes: %w", err)
+		}
+
+		var response struct {
+			Hits struct {
+				Hits []struct {
+					ID     string        `json:"_id"`
+					Sort   []interface{} `json:"sort"`
+					Source struct {
+						ContentHash string `json:"content_hash"`
+					} `json:"_source"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		if res.IsError() {
+			res.Body.Close()
+			return nil, fmt.Errorf("content hash search failed with status: %s", res.Status())
+		}
+		err = json.NewDecoder(res.Body).Decode(&response)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode content hash response: %w", err)
+		}
+
+		hits := response.Hits.Hits
+		for _, hit := range hits {
+			id, err := uuid.Parse(hit.ID)
+			if err != nil {
+				continue
+			}
+			hashes[id] = hit.Source.ContentHash
+		}
+
+		if len(hits) < contentHashPageSize {
+			return hashes, nil
+		}
+		searchAfter = hits[len(hits)-1].Sort
+	}
+}
@@ -0,0 +1,298 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"chat-assistant-backend/internal/models"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/google/uuid"
+)
+
+// 独立消息索引的写入：每条消息一个 FlatMessageDocument，文档 ID 为消息 ID。
+// 只在 IndexerOptions.MessageIndex 非空时写入，对话文档写入成功后再写消息索引
+
+// replaceFlatMessages 将 docs 的全部消息写入独立消息索引，写入的消息带有本次的批次标记，
+// 再删除已存在的对话（不在 created 中）里批次不同、即已不存在的旧消息。新建的对话没有旧消息，
+// 不执行删除；删除使用与写入相同的刷新策略
+func (i *ElasticsearchIndexerImpl) replaceFlatMessages(ctx context.Context, docs []*models.ConversationDocument, created map[uuid.UUID]bool, refresh string) error {
+	if i.messageIndex == "" || len(docs) == 0 {
+		return nil
+	}
+
+	generation := time.Now().UnixNano()
+	var existing []uuid.UUID
+	var bulkBody strings.Builder
+	for _, doc := range docs {
+		if !created[doc.ID] {
+			existing = append(existing, doc.ID)
+		}
+		for _, message := range doc.Messages {
+			flat := doc.FlatMessage(message)
+			flat.IndexGeneration = generation
+			flat.Truncate(i.maxMessageChars)
+
+			meta, _ := json.Marshal(map[string]interface{}{
+				"index": map[string]interface{}{"_index": i.messageIndex, "_id": message.ID.String()},
+			})
+			source, err := json.Marshal(flat)
+			if err != nil {
+				return fmt.Errorf("failed to marshal message document: %w", err)
+			}
+			bulkBody.Write(meta)
+			bulkBody.WriteString("\n")
+			bulkBody.Write(source)
+			bulkBody.WriteString("\n")
+		}
+	}
+
+	if bulkBody.Len() > 0 {
+		req := esapi.BulkRequest{
+			Body:    strings.NewReader(bulkBody.String()),
+			Refresh: refresh,
+		}
+		res, err := req.Do(ctx, i.esClient)
+		if err != nil {
+			return fmt.Errorf("failed to bulk index messages: %w", err)
+		}
+		defer res.Body.Close()
+
+		if res.IsError() {
+			return fmt.Errorf("message bulk request failed with status: %s", res.Status())
+		}
+	}
+
+	return i.deleteFlatConversationMessages(ctx, existing, generation, refresh)
+}
+
+// deleteFlatConversationMessages 删除独立消息索引中属于 conversationIDs 的消息，
+// 保留该批次写入的消息（generation 为 0 时全部删除）。conversationIDs 最多为一个批次的对话数，查询大小与消息数无关
+func (i *ElasticsearchIndexerImpl) deleteFlatConversationMessages(ctx context.Context, conversationIDs []uuid.UUID, generation int64, refresh string) error {
+	if i.messageIndex == "" || len(conversationIDs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(conversationIDs))
+	for idx, id := range conversationIDs {
+		ids[idx] = id.String()
+	}
+
+	query := map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": []map[string]interface{}{
+				{"terms": map[string]interface{}{"conversation_id": ids}},
+			},
+		},
+	}
+	if generation != 0 {
+		query["bool"].(map[string]interface{})["must_not"] = []map[string]interface{}{
+			{"term": map[string]interface{}{"index_generation": generation}},
+		}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"query": query})
+	if err != nil {
+		return fmt.Errorf("failed to marshal delete by query body: %w", err)
+	}
+	// _delete_by_query 只支持 true/false，wait_for 视为 true
+	if _, err := deleteByQuery(ctx, i.esClient, i.messageIndex, body, refresh != RefreshFalse); err != nil {
+		return fmt.Errorf("failed to delete conversation messages: %w", err)
+	}
+	return nil
+}
+
+// indexFlatMessage 写入单条消息，user_id 和可过滤的对话字段从对话文档中读取
+func (i *ElasticsearchIndexerImpl) indexFlatMessage(ctx context.Context, conversationID uuid.UUID, message models.MessageDocument) error {
+	if i.messageIndex == "" {
+		return nil
+	}
+
+	conversation, err := i.flatConversationFields(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+
+	source, err := json.Marshal(conversation.FlatMessage(message))
+	if err != nil {
+		return fmt.Errorf("failed to marshal message document: %w", err)
+	}
+
+	req := esapi.IndexRequest{
+		Index:      i.messageIndex,
+		DocumentID: message.ID.String(),
+		Body:       bytes.NewReader(source),
+		Refresh:    i.refresh.Write,
+	}
+	res, err := req.Do(ctx, i.esClient)
+	if err != nil {
+		return fmt.Errorf("failed to index message: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("message index request failed with status: %s", res.Status())
+	}
+	return nil
+}
+
+// flatConversationFields 读取对话文档中复制到独立消息索引的字段（user_id、provider、created_at、tags）
+func (i *ElasticsearchIndexerImpl) flatConversationFields(ctx context.Context, conversationID uuid.UUID) (*models.ConversationDocument, error) {
+	req := esapi.GetRequest{
+		Index:          i.indexName,
+		DocumentID:     conversationID.String(),
+		SourceIncludes: []string{"user_id", "provider", "created_at", "tags"},
+	}
+	res, err := req.Do(ctx, i.esClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("get conversation request failed with status: %s", res.Status())
+	}
+
+	var result struct {
+		Source models.ConversationDocument `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode conversation: %w", err)
+	}
+	return &result.Source, nil
+}
+
+// deleteFlatMessages 按消息 ID 删除，不存在的消息忽略
+func (i *ElasticsearchIndexerImpl) deleteFlatMessages(ctx context.Context, messageIDs []uuid.UUID) error {
+	if i.messageIndex == "" || len(messageIDs) == 0 {
+		return nil
+	}
+
+	var bulkBody strings.Builder
+	for _, id := range messageIDs {
+		meta, _ := json.Marshal(map[string]interface{}{
+			"delete": map[string]interface{}{"_index": i.messageIndex, "_id": id.String()},
+		})
+		bulkBody.Write(meta)
+		bulkBody.WriteString("\n")
+	}
+
+	req := esapi.BulkRequest{
+		Body:    strings.NewReader(bulkBody.String()),
+		Refresh: i.refresh.Write,
+	}
+	res, err := req.Do(ctx, i.esClient)
+	if err != nil {
+		return fmt.Errorf("failed to delete messages: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("message bulk request failed with status: %s", res.Status())
+	}
+	return nil
+}
+
+// updateFlatMessagesConversation 将对话消息中复制自对话的字段更新为 doc 的值（转移用户、修改标签），
+// 已一致的消息由脚本跳过（noop），不会被重写
+func (i *ElasticsearchIndexerImpl) updateFlatMessagesConversation(ctx context.Context, doc *models.ConversationDocument) error {
+	if i.messageIndex == "" {
+		return nil
+	}
+
+	script := `
+		if (ctx._source.user_id == params.userId && ctx._source.provider == params.provider &&
+				ctx._source.tag_ids == params.tagIds) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.user_id = params.userId;
+			ctx._source.provider = params.provider;
+			ctx._source.conversation_created_at = params.createdAt;
+			ctx._source.tag_ids = params.tagIds;
+		}
+	`
+	tagIDs := make([]string, 0, len(doc.Tags))
+	for _, id := range doc.TagIDs() {
+		tagIDs = append(tagIDs, id.String())
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"term": map[string]interface{}{"conversation_id": doc.ID.String()},
+		},
+		"script": map[string]interface{}{
+			"source": script,
+			"params": map[string]interface{}{
+				"userId":    doc.UserID.String(),
+				"provider":  doc.Provider,
+				"createdAt": doc.CreatedAt,
+				"tagIds":    tagIDs,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal update by query body: %w", err)
+	}
+
+	// _update_by_query 只支持 true/false，wait_for 视为 true
+	refresh := i.refresh.Write != RefreshFalse
+	req := esapi.UpdateByQueryRequest{
+		Index:     []string{i.messageIndex},
+		Body:      bytes.NewReader(body),
+		Conflicts: "proceed",
+		Refresh:   &refresh,
+	}
+	res, err := req.Do(ctx, i.esClient)
+	if err != nil {
+		return fmt.Errorf("failed to update message conversation fields: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("update by query request failed with status: %s", res.Status())
+	}
+	return nil
+}
+
+// removeFlatMessagesTag 从独立消息索引中带有该标签的消息中移除标签 ID
+func (i *ElasticsearchIndexerImpl) removeFlatMessagesTag(ctx context.Context, tagID uuid.UUID) error {
+	if i.messageIndex == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"term": map[string]interface{}{"tag_ids": tagID.String()},
+		},
+		"script": map[string]interface{}{
+			"source": "ctx._source.tag_ids.removeIf(id -> id == params.tagId)",
+			"params": map[string]interface{}{"tagId": tagID.String()},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal update by query body: %w", err)
+	}
+
+	// _update_by_query 只支持 true/false，wait_for 视为 true
+	refresh := i.refresh.Bulk != RefreshFalse
+	req := esapi.UpdateByQueryRequest{
+		Index:     []string{i.messageIndex},
+		Body:      bytes.NewReader(body),
+		Conflicts: "proceed",
+		Refresh:   &refresh,
+	}
+	res, err := req.Do(ctx, i.esClient)
+	if err != nil {
+		return fmt.Errorf("failed to remove tag from messages: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("update by query request failed with status: %s", res.Status())
+	}
+	return nil
+}
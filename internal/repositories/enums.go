@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SearchOrder is the order of search results: OrderRelevance, OrderActivity or OrderCreated
+type SearchOrder string
+
+// SearchOrders 搜索结果的全部排序方式，与 Swagger 注释中的 Enums(...) 一致
+var SearchOrders = []SearchOrder{OrderRelevance, OrderActivity, OrderCreated}
+
+// SearchSort is the sort parameter of a search: SortRelevance or SortDate, the documented
+// form of SearchOrder (order is kept as an alias and also accepts OrderActivity)
+type SearchSort string
+
+// Search sorts
+const (
+	SortRelevance SearchSort = "relevance" // 相关性，得分相同时按创建时间倒序，即 OrderRelevance
+	SortDate      SearchSort = "date"      // 只按创建时间倒序，不按相关性重排，即 OrderCreated
+)
+
+// SearchSorts 搜索结果的全部 sort 取值
+var SearchSorts = []SearchSort{SortRelevance, SortDate}
+
+// ListOrder is the order of the conversation list: OrderCreated or OrderActivity
+type ListOrder string
+
+// ListOrders 对话列表的全部排序方式
+var ListOrders = []ListOrder{OrderCreated, OrderActivity}
+
+// MessageOrder is the order of a conversation's messages: MessageOrderAsc or MessageOrderDesc
+type MessageOrder string
+
+// MessageOrders 消息列表的全部排序方式
+var MessageOrders = []MessageOrder{MessageOrderAsc, MessageOrderDesc}
+
+// TagMatch is how SearchParams.TagIDs are matched: TagMatchAll or TagMatchAny
+type TagMatch string
+
+// TagMatches 标签的全部匹配方式
+var TagMatches = []TagMatch{TagMatchAll, TagMatchAny}
+
+// String returns the query parameter value
+func (o SearchOrder) String() string { return string(o) }
+
+// String returns the query parameter value
+func (s SearchSort) String() string { return string(s) }
+
+// String returns the query parameter value
+func (o ListOrder) String() string { return string(o) }
+
+// String returns the query parameter value
+func (o MessageOrder) String() string { return string(o) }
+
+// String returns the query parameter value
+func (m TagMatch) String() string { return string(m) }
+
+// ParseSearchOrder parses the order parameter of a search; empty means OrderRelevance
+func ParseSearchOrder(s string) (SearchOrder, error) {
+	return parseEnum("order", s, SearchOrders, OrderRelevance)
+}
+
+// ParseSearchSort parses the sort parameter of a search into the equivalent SearchOrder;
+// empty means OrderRelevance
+func ParseSearchSort(s string) (SearchOrder, error) {
+	sort, err := parseEnum("sort", s, SearchSorts, SortRelevance)
+	if err != nil {
+		return "", err
+	}
+	if sort == SortDate {
+		return OrderCreated, nil
+	}
+	return OrderRelevance, nil
+}
+
+// ResolveSearchOrder returns the order of a search given its sort and order parameters:
+// sort wins when set, order is its alias; both set to different orders is an error
+func ResolveSearchOrder(sort, order string) (SearchOrder, error) {
+	parsedOrder, err := ParseSearchOrder(order)
+	if err != nil || sort == "" {
+		return parsedOrder, err
+	}
+	parsedSort, err := ParseSearchSort(sort)
+	if err != nil {
+		return "", err
+	}
+	if order != "" && parsedOrder != parsedSort {
+		return "", fmt.Errorf("sort=%s conflicts with order=%s, use only one of them", sort, order)
+	}
+	return parsedSort, nil
+}
+
+// ParseListOrder parses the order parameter of the conversation list; empty means OrderCreated
+func ParseListOrder(s string) (ListOrder, error) {
+	return parseEnum("order", s, ListOrders, OrderCreated)
+}
+
+// ParseMessageOrder parses the order parameter of the message list; empty means MessageOrderAsc
+func ParseMessageOrder(s string) (MessageOrder, error) {
+	return parseEnum("order", s, MessageOrders, MessageOrderAsc)
+}
+
+// ParseTagMatch parses the tag_match parameter of a search; empty means TagMatchAll
+func ParseTagMatch(s string) (TagMatch, error) {
+	return parseEnum("tag_match", s, TagMatches, TagMatchAll)
+}
+
+// parseEnum 返回 values 中与 s 相同的值，s 为空时返回 defaultValue，区分大小写
+func parseEnum[T ~string](name, s string, values []T, defaultValue T) (T, error) {
+	if s == "" {
+		return defaultValue, nil
+	}
+	for _, value := range values {
+		if string(value) == s {
+			return value, nil
+		}
+	}
+
+	names := make([]string, len(values))
+	for i, value := range values {
+		names[i] = string(value)
+	}
+	return "", fmt.Errorf("%s must be one of: %s", name, strings.Join(names, ", "))
+}
@@ -1,6 +1,12 @@
 package repositories
 
 import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"chat-assistant-backend/internal/models"
 
 	"github.com/google/uuid"
@@ -10,11 +16,66 @@ import (
 // MessageRepository defines the interface for message repository
 type MessageRepository interface {
 	GetByID(id uuid.UUID) (*models.Message, error)
-	GetByConversationID(conversationID uuid.UUID, page, limit int) ([]*models.Message, int64, error)
+	GetByConversationID(conversationID uuid.UUID, page, limit int, order string) ([]*models.Message, int64, error)
+	GetByConversationIDBefore(conversationID uuid.UUID, cursor MessageCursor, limit int) ([]*models.Message, int64, error)
 	GetAll(page, limit int) ([]*models.Message, int64, error)
+	GetContext(id uuid.UUID, before, after int) (*models.MessageContext, error)
+	CountByConversationID(conversationID uuid.UUID) (int64, error)
 	Delete(id uuid.UUID) error
 }
 
+// Conversation message orders
+const (
+	MessageOrderAsc  = "asc"  // 按对话内顺序（sequence，相同时按创建时间）正序（默认）
+	MessageOrderDesc = "desc" // 倒序，用于从最新消息开始向前加载
+)
+
+// MessageCursor 倒序分页的游标，指向上一页最后（最早）一条消息；
+// 与排序一致按 (sequence, created_at, id) 比较，保证翻页不重复也不遗漏
+type MessageCursor struct {
+	Sequence  int
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// NewMessageCursor returns the cursor positioned at message
+func NewMessageCursor(message *models.Message) MessageCursor {
+	return MessageCursor{Sequence: message.Sequence, CreatedAt: message.CreatedAt, ID: message.ID}
+}
+
+// Encode returns the opaque cursor string passed to clients
+func (c MessageCursor) Encode() string {
+	raw := strconv.Itoa(c.Sequence) + "|" + c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseMessageCursor decodes a cursor string produced by MessageCursor.Encode
+func ParseMessageCursor(s string) (MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return MessageCursor{}, fmt.Errorf("invalid cursor encoding: %w", err)
+	}
+
+	// 不含 sequence 的旧游标无法定位，客户端需要从第一页重新加载
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return MessageCursor{}, fmt.Errorf("invalid cursor format")
+	}
+	sequence, createdAt, id := parts[0], parts[1], parts[2]
+
+	var cursor MessageCursor
+	if cursor.Sequence, err = strconv.Atoi(sequence); err != nil {
+		return MessageCursor{}, fmt.Errorf("invalid cursor sequence: %w", err)
+	}
+	if cursor.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return MessageCursor{}, fmt.Errorf("invalid cursor time: %w", err)
+	}
+	if cursor.ID, err = uuid.Parse(id); err != nil {
+		return MessageCursor{}, fmt.Errorf("invalid cursor id: %w", err)
+	}
+	return cursor, nil
+}
+
 // MessageRepositoryImpl handles message data access
 type MessageRepositoryImpl struct {
 	db *gorm.DB
@@ -30,7 +91,7 @@ func NewMessageRepository(db *gorm.DB) MessageRepository {
 // GetByID retrieves a message by ID
 func (r *MessageRepositoryImpl) GetByID(id uuid.UUID) (*models.Message, error) {
 	var message models.Message
-	err := r.db.Where("id = ?", id).First(&message).Error
+	err := r.db.Preload("Attachments").Where("id = ?", id).First(&message).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil // Return nil message and nil error for not found
@@ -40,21 +101,21 @@ func (r *MessageRepositoryImpl) GetByID(id uuid.UUID) (*models.Message, error) {
 	return &message, nil
 }
 
-// GetByConversationID retrieves messages by conversation ID with pagination
-func (r *MessageRepositoryImpl) GetByConversationID(conversationID uuid.UUID, page, limit int) ([]*models.Message, int64, error) {
+// GetByConversationID retrieves messages by conversation ID with pagination,
+// ordered by MessageOrderAsc (default) or MessageOrderDesc
+func (r *MessageRepositoryImpl) GetByConversationID(conversationID uuid.UUID, page, limit int, order string) ([]*models.Message, int64, error) {
 	var messages []*models.Message
-	var total int64
 
-	// Count total messages for this conversation
-	err := r.db.Model(&models.Message{}).Where("conversation_id = ?", conversationID).Count(&total).Error
+	total, err := r.CountByConversationID(conversationID)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get paginated messages
 	offset := (page - 1) * limit
-	err = r.db.Where("conversation_id = ?", conversationID).
-		Order("created_at ASC").
+	err = r.db.Preload("Attachments").
+		Where("conversation_id = ?", conversationID).
+		Order(messageOrder(order)).
 		Offset(offset).
 		Limit(limit).
 		Find(&messages).Error
@@ -65,6 +126,46 @@ func (r *MessageRepositoryImpl) GetByConversationID(conversationID uuid.UUID, pa
 	return messages, total, nil
 }
 
+// GetByConversationIDBefore retrieves up to limit messages created before cursor,
+// newest first, together with the total message count of the conversation.
+// 使用 (sequence, created_at, id) 键集分页，加载更早的消息不需要深度 offset
+func (r *MessageRepositoryImpl) GetByConversationIDBefore(conversationID uuid.UUID, cursor MessageCursor, limit int) ([]*models.Message, int64, error) {
+	var messages []*models.Message
+
+	total, err := r.CountByConversationID(conversationID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = r.db.Preload("Attachments").
+		Where("conversation_id = ?", conversationID).
+		Where("(sequence, created_at, id) < (?, ?, ?)", cursor.Sequence, cursor.CreatedAt, cursor.ID).
+		Order(messageOrder(MessageOrderDesc)).
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return messages, total, nil
+}
+
+// CountByConversationID counts messages of a conversation
+func (r *MessageRepositoryImpl) CountByConversationID(conversationID uuid.UUID) (int64, error) {
+	var total int64
+	err := r.db.Model(&models.Message{}).Where("conversation_id = ?", conversationID).Count(&total).Error
+	return total, err
+}
+
+// messageOrder 返回对话消息排序子句：按 sequence，sequence 相同（如旧数据都为 0）时
+// 按 created_at，id 作为最后的并列次序保证分页稳定。与 models.MessageLess 一致
+func messageOrder(order string) string {
+	if order == MessageOrderDesc {
+		return "sequence DESC, created_at DESC, id DESC"
+	}
+	return "sequence ASC, created_at ASC, id ASC"
+}
+
 // GetAll retrieves all messages with pagination
 func (r *MessageRepositoryImpl) GetAll(page, limit int) ([]*models.Message, int64, error) {
 	var messages []*models.Message
@@ -78,7 +179,8 @@ func (r *MessageRepositoryImpl) GetAll(page, limit int) ([]*models.Message, int6
 
 	// Get paginated messages
 	offset := (page - 1) * limit
-	err = r.db.Order("created_at DESC").
+	err = r.db.Preload("Attachments").
+		Order("created_at DESC").
 		Offset(offset).
 		Limit(limit).
 		Find(&messages).Error
@@ -89,6 +191,57 @@ func (r *MessageRepositoryImpl) GetAll(page, limit int) ([]*models.Message, int6
 	return messages, total, nil
 }
 
+// messageContextQuery 按对话内顺序为消息编号，取目标消息前后指定数量的消息 ID
+const messageContextQuery = `
+WITH ordered AS (
+	SELECT id, ROW_NUMBER() OVER (ORDER BY sequence ASC, created_at ASC, id ASC) AS rn
+	FROM messages
+	WHERE conversation_id = ? AND deleted_at IS NULL
+), target AS (
+	SELECT rn FROM ordered WHERE id = ?
+)
+SELECT ordered.id
+FROM ordered, target
+WHERE ordered.rn BETWEEN target.rn - ? AND target.rn + ?
+ORDER BY ordered.rn`
+
+// GetContext retrieves a message with up to before/after neighbors in the same conversation
+func (r *MessageRepositoryImpl) GetContext(id uuid.UUID, before, after int) (*models.MessageContext, error) {
+	message, err := r.GetByID(id)
+	if err != nil || message == nil {
+		return nil, err
+	}
+
+	var ids []uuid.UUID
+	if err := r.db.Raw(messageContextQuery, message.ConversationID, id, before, after).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+
+	var messages []*models.Message
+	err = r.db.Preload("Attachments").
+		Where("id IN ?", ids).
+		Order(messageOrder(MessageOrderAsc)).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	messageContext := &models.MessageContext{Message: message}
+	target := -1
+	for i, m := range messages {
+		if m.ID == id {
+			target = i
+			break
+		}
+	}
+	if target >= 0 {
+		messageContext.Before = messages[:target]
+		messageContext.After = messages[target+1:]
+	}
+
+	return messageContext, nil
+}
+
 // Delete soft deletes a message by ID
 func (r *MessageRepositoryImpl) Delete(id uuid.UUID) error {
 	return r.db.Delete(&models.Message{}, id).Error
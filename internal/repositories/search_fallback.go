@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+	"sync/atomic"
+
+	"chat-assistant-backend/internal/logger"
+	"chat-assistant-backend/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// FallbackSearchRepository 根据 Elasticsearch 的可用状态选择搜索实现：available 返回 false
+// （降级启动后尚未重连成功）时使用 fallback，恢复后自动切回 primary
+type FallbackSearchRepository struct {
+	primary   SearchRepository
+	fallback  SearchRepository
+	available func() bool
+
+	fallbackSearches atomic.Int64
+}
+
+// NewFallbackSearchRepository routes searches to fallback while available reports false
+func NewFallbackSearchRepository(primary, fallback SearchRepository, available func() bool) SearchRepository {
+	return &FallbackSearchRepository{primary: primary, fallback: fallback, available: available}
+}
+
+// SearchConversationsWithMatchedMessages searches Elasticsearch, or the fallback while it is unavailable
+func (r *FallbackSearchRepository) SearchConversationsWithMatchedMessages(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	if r.available() {
+		return r.primary.SearchConversationsWithMatchedMessages(ctx, params)
+	}
+
+	r.fallbackSearches.Add(1)
+	logger.FromContext(ctx).Debug("Elasticsearch unavailable, searching with the Postgres fallback",
+		zap.String("query", params.Query),
+	)
+	return r.fallback.SearchConversationsWithMatchedMessages(ctx, params)
+}
+
+// FindSimilar finds similar conversations, or uses the fallback while Elasticsearch is unavailable
+func (r *FallbackSearchRepository) FindSimilar(conversationID uuid.UUID, userID *uuid.UUID, limit int) ([]*models.ConversationDocument, error) {
+	if r.available() {
+		return r.primary.FindSimilar(conversationID, userID, limit)
+	}
+	return r.fallback.FindSimilar(conversationID, userID, limit)
+}
+
+// Stats returns the primary's counters plus the number of fallback searches
+func (r *FallbackSearchRepository) Stats() SearchStats {
+	stats := r.primary.Stats()
+	stats.FallbackSearches = r.fallbackSearches.Load()
+	return stats
+}
@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"chat-assistant-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// maxFilterDepth 过滤表达式最大嵌套层数，防止构造过深的查询
+const maxFilterDepth = 5
+
+// filterFields lists the leaf fields accepted by the filter expression,
+// in addition to meta.<key> for conversation metadata
+var filterFields = []string{"provider", "model", "tag", "tag_id", "role"}
+
+// filterRoles lists the message roles accepted by the role field
+var filterRoles = map[string]bool{
+	"user":      true,
+	"assistant": true,
+	"system":    true,
+}
+
+// FilterExpression is a boolean filter tree for advanced search.
+// Exactly one of And, Or, Not or Field is set; leaves compare Field with Value.
+//
+// JSON form: {"or":[{"provider":"openai"},{"tag":"work"}]}, {"not":{"role":"system"}},
+// {"and":[...]} or a single {"<field>":"<value>"}
+type FilterExpression struct {
+	And   []*FilterExpression
+	Or    []*FilterExpression
+	Not   *FilterExpression
+	Field string
+	Value string
+}
+
+// ParseFilterExpression parses and validates a JSON filter expression
+func ParseFilterExpression(data string) (*FilterExpression, error) {
+	var raw json.RawMessage
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, fmt.Errorf("filter must be valid JSON: %w", err)
+	}
+	return parseFilterNode(raw, 1)
+}
+
+func parseFilterNode(raw json.RawMessage, depth int) (*FilterExpression, error) {
+	if depth > maxFilterDepth {
+		return nil, fmt.Errorf("filter nesting exceeds %d levels", maxFilterDepth)
+	}
+
+	var node map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &node); err != nil {
+		return nil, fmt.Errorf("filter node must be a JSON object")
+	}
+	if len(node) != 1 {
+		return nil, fmt.Errorf("filter node must have exactly one key, got %d", len(node))
+	}
+
+	for key, value := range node {
+		switch key {
+		case "and", "or":
+			var children []json.RawMessage
+			if err := json.Unmarshal(value, &children); err != nil || len(children) == 0 {
+				return nil, fmt.Errorf("%q must be a non-empty array", key)
+			}
+
+			exprs := make([]*FilterExpression, len(children))
+			for i, child := range children {
+				expr, err := parseFilterNode(child, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				exprs[i] = expr
+			}
+
+			if key == "and" {
+				return &FilterExpression{And: exprs}, nil
+			}
+			return &FilterExpression{Or: exprs}, nil
+
+		case "not":
+			expr, err := parseFilterNode(value, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			return &FilterExpression{Not: expr}, nil
+
+		default:
+			var str string
+			if err := json.Unmarshal(value, &str); err != nil {
+				return nil, fmt.Errorf("value of %q must be a string", key)
+			}
+			if err := validateFilterField(key, str); err != nil {
+				return nil, err
+			}
+			return &FilterExpression{Field: key, Value: str}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("filter node must have exactly one key")
+}
+
+// validateFilterField 校验叶子节点的字段名和值
+func validateFilterField(field, value string) error {
+	if key, ok := strings.CutPrefix(field, "meta."); ok {
+		if !models.IsConversationMetadataKey(key) {
+			return fmt.Errorf("unknown metadata key %q, must be one of: %s", key, strings.Join(models.ConversationMetadataKeys, ", "))
+		}
+		return nil
+	}
+
+	switch field {
+	case "provider", "model", "tag":
+		if value == "" {
+			return fmt.Errorf("value of %q must not be empty", field)
+		}
+	case "tag_id":
+		if _, err := uuid.Parse(value); err != nil {
+			return fmt.Errorf("value of \"tag_id\" must be a valid UUID")
+		}
+	case "role":
+		if !filterRoles[value] {
+			return fmt.Errorf("value of \"role\" must be one of: user, assistant, system")
+		}
+	default:
+		return fmt.Errorf("unknown filter operator or field %q, must be and, or, not, %s or meta.<key>", field, strings.Join(filterFields, ", "))
+	}
+	return nil
+}
+
+// buildFilterClause 将过滤表达式转换为 ES bool 查询子句
+func buildFilterClause(expr *FilterExpression) map[string]interface{} {
+	switch {
+	case len(expr.And) > 0:
+		return map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": buildFilterClauses(expr.And),
+			},
+		}
+	case len(expr.Or) > 0:
+		return map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should":               buildFilterClauses(expr.Or),
+				"minimum_should_match": 1,
+			},
+		}
+	case expr.Not != nil:
+		return map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must_not": []map[string]interface{}{buildFilterClause(expr.Not)},
+			},
+		}
+	}
+
+	switch expr.Field {
+	case "tag":
+		return nestedTerm("tags", "tags.name.keyword", expr.Value)
+	case "tag_id":
+		return nestedTerm("tags", "tags.id", expr.Value)
+	case "role":
+		return map[string]interface{}{
+			"nested": map[string]interface{}{
+				"path":  "messages",
+				"query": messageRoleFilter(expr.Value),
+			},
+		}
+	}
+
+	// provider、model 以及 meta.<key> 都是 keyword 字段
+	field := expr.Field
+	if key, ok := strings.CutPrefix(field, "meta."); ok {
+		field = "metadata." + key
+	}
+	return map[string]interface{}{
+		"term": map[string]interface{}{
+			field: expr.Value,
+		},
+	}
+}
+
+func buildFilterClauses(exprs []*FilterExpression) []map[string]interface{} {
+	clauses := make([]map[string]interface{}, len(exprs))
+	for i, expr := range exprs {
+		clauses[i] = buildFilterClause(expr)
+	}
+	return clauses
+}
+
+// nestedTerm 构建嵌套字段的 term 查询
+func nestedTerm(path, field, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"nested": map[string]interface{}{
+			"path": path,
+			"query": map[string]interface{}{
+				"term": map[string]interface{}{
+					field: value,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,280 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"chat-assistant-backend/internal/models"
+
+	es "github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/google/uuid"
+)
+
+// FlatMessageSearchRepository 在独立消息索引（每条消息一个文档）中匹配关键词，再关联回对话。
+// 超长对话的嵌套文档很大，且可能因 MaxMessages 只索引了最近的消息；独立索引保存全部消息，
+// 搜索时也不需要读取整个嵌套文档。标题、标签的匹配、过滤条件、后置过滤和分页与
+// ElasticsearchRepositoryImpl 相同，没有关键词或不匹配消息时直接使用嵌套文档搜索
+type FlatMessageSearchRepository struct {
+	*ElasticsearchRepositoryImpl
+	messageIndex string
+}
+
+// NewFlatMessageSearchRepository creates a search repository that matches message content in
+// messageIndex, which the indexer fills when IndexerOptions.MessageIndex is set
+func NewFlatMessageSearchRepository(esClient *es.Client, indexName, messageIndex string, opts SearchRepositoryOptions) SearchRepository {
+	return &FlatMessageSearchRepository{
+		ElasticsearchRepositoryImpl: newElasticsearchRepository(esClient, indexName, opts),
+		messageIndex:                messageIndex,
+	}
+}
+
+// flatMessageMatches 独立消息索引中匹配关键词的消息，按对话分组
+type flatMessageMatches struct {
+	scores     map[uuid.UUID]float64                  // 对话中得分最高的消息的得分
+	messages   map[uuid.UUID][]models.MessageDocument // 按对话内顺序排序
+	highlights map[uuid.UUID]map[string][]interface{} // 键为 messages.content、messages.source_content
+	tookMs     int64
+}
+
+// SearchConversationsWithMatchedMessages searches conversations, matching the keyword against
+// the flat message index instead of the nested messages
+func (r *FlatMessageSearchRepository) SearchConversationsWithMatchedMessages(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	if strings.TrimSpace(params.Query) == "" || !searchesField(params.Fields, SearchFieldMessages) {
+		return r.ElasticsearchRepositoryImpl.SearchConversationsWithMatchedMessages(ctx, params)
+	}
+
+	matches, err := r.searchMessages(ctx, params)
+	if err != nil {
+		r.searches.Add(1)
+		r.failedSearches.Add(1)
+		return nil, err
+	}
+
+	// 只匹配消息且没有消息命中时，对话查询没有关键词子句，会变成只按过滤条件返回全部对话
+	if len(matches.scores) == 0 && !searchesField(params.Fields, SearchFieldTitle) && !searchesField(params.Fields, SearchFieldTags) {
+		r.searches.Add(1)
+		return &SearchResult{
+			Documents:            []*models.ConversationDocument{},
+			MatchedMessages:      map[uuid.UUID][]*models.MessageDocument{},
+			MatchedFields:        map[uuid.UUID][]string{},
+			Highlights:           map[uuid.UUID]map[string][]string{},
+			ContextMessages:      map[uuid.UUID]bool{},
+			MessageMatchedFields: map[uuid.UUID][]string{},
+			MessageHighlights:    map[uuid.UUID]map[string][]string{},
+			Meta:                 &models.SearchMeta{TookMs: matches.tookMs},
+		}, nil
+	}
+
+	params.messageMatches = matches
+	result, err := r.ElasticsearchRepositoryImpl.SearchConversationsWithMatchedMessages(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	result.Meta.TookMs += matches.tookMs
+	return result, nil
+}
+
+// searchMessages 在独立消息索引中搜索关键词，读取得分最高的 postFilterWindow 条消息
+func (r *FlatMessageSearchRepository) searchMessages(ctx context.Context, params SearchParams) (*flatMessageMatches, error) {
+	query := strings.TrimSpace(params.Query)
+
+	messageQueries := messageMatchQueries(query, "")
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter":               flatMessageFilters(params),
+				"should":               messageQueries[:],
+				"minimum_should_match": 1,
+			},
+		},
+		"size": postFilterWindow,
+		"sort": []map[string]interface{}{
+			{"_score": map[string]interface{}{"order": "desc"}},
+			{"created_at": map[string]interface{}{"order": "desc"}},
+		},
+		"highlight": map[string]interface{}{
+			"fields": map[string]interface{}{
+				MessageFieldContent:       map[string]interface{}{},
+				MessageFieldSourceContent: map[string]interface{}{},
+			},
+			"pre_tags":            []string{"<mark>"},
+			"post_tags":           []string{"</mark>"},
+			"fragment_size":       150,
+			"number_of_fragments": 3,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message search query: %w", err)
+	}
+
+	req := esapi.SearchRequest{
+		Index: []string{r.messageIndex},
+		Body:  bytes.NewReader(body),
+	}
+	res, err := req.Do(ctx, r.esClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute message search: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("message search request failed with status: %s", res.Status())
+	}
+
+	var response struct {
+		Took int64 `json:"took"`
+		Hits struct {
+			Hits []struct {
+				Score     float64                `json:"_score"`
+				Source    map[string]interface{} `json:"_source"`
+				Highlight map[string][]string    `json:"highlight"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode message search response: %w", err)
+	}
+
+	matches := &flatMessageMatches{
+		scores:     make(map[uuid.UUID]float64),
+		messages:   make(map[uuid.UUID][]models.MessageDocument),
+		highlights: make(map[uuid.UUID]map[string][]interface{}),
+		tookMs:     response.Took,
+	}
+	for _, hit := range response.Hits.Hits {
+		var message models.MessageDocument
+		if err := r.parseMessageDocument(hit.Source, &message); err != nil || message.ConversationID == uuid.Nil {
+			continue // 跳过解析失败的文档
+		}
+
+		conversationID := message.ConversationID
+		matches.scores[conversationID] = max(matches.scores[conversationID], hit.Score)
+		matches.messages[conversationID] = append(matches.messages[conversationID], message)
+		for _, field := range []string{MessageFieldContent, MessageFieldSourceContent} {
+			for _, fragment := range hit.Highlight[field] {
+				if matches.highlights[conversationID] == nil {
+					matches.highlights[conversationID] = make(map[string][]interface{})
+				}
+				key := "messages." + field
+				matches.highlights[conversationID][key] = append(matches.highlights[conversationID][key], fragment)
+			}
+		}
+	}
+
+	for _, messages := range matches.messages {
+		sort.SliceStable(messages, func(i, j int) bool {
+			return models.MessageDocumentLess(&messages[i], &messages[j])
+		})
+	}
+	return matches, nil
+}
+
+// flatMessageFilters 在截取得分最高的消息之前应用的过滤条件：用户、角色，以及复制到消息文档中的
+// provider、对话创建时间和标签，与 buildSearchQuery 中对应的对话过滤条件一致。元信息和高级过滤表达式
+// 只在对话查询中应用
+func flatMessageFilters(params SearchParams) []map[string]interface{} {
+	var filters []map[string]interface{}
+	term := func(field, value string) {
+		filters = append(filters, map[string]interface{}{"term": map[string]interface{}{field: value}})
+	}
+
+	if params.UserID != nil {
+		term("user_id", params.UserID.String())
+	}
+	if params.Role != nil {
+		term("role", *params.Role)
+	}
+	if params.ProviderID != nil {
+		term("provider", *params.ProviderID)
+	}
+	if params.TagID != nil {
+		term("tag_ids", params.TagID.String())
+	}
+	if len(params.TagIDs) > 0 {
+		tagIDs := make([]string, len(params.TagIDs))
+		for i, id := range params.TagIDs {
+			tagIDs[i] = id.String()
+		}
+		if params.TagMatch == TagMatchAny {
+			filters = append(filters, map[string]interface{}{"terms": map[string]interface{}{"tag_ids": tagIDs}})
+		} else {
+			for _, id := range tagIDs {
+				term("tag_ids", id)
+			}
+		}
+	}
+	if params.StartDate != nil || params.EndDate != nil {
+		dateRange := map[string]interface{}{}
+		if params.StartDate != nil {
+			dateRange["gte"] = params.StartDate.Format(time.RFC3339)
+		}
+		if params.EndDate != nil {
+			dateRange["lte"] = params.EndDate.Format(time.RFC3339)
+		}
+		filters = append(filters, map[string]interface{}{"range": map[string]interface{}{"conversation_created_at": dateRange}})
+	}
+	return filters
+}
+
+// conversationClauses 为每个有消息命中的对话生成一个得分固定的子句，得分为其最高的消息得分，
+// 与标题、标签子句的得分相加后参与排序和 min_score 过滤
+func (m *flatMessageMatches) conversationClauses() []map[string]interface{} {
+	ids := make([]uuid.UUID, 0, len(m.scores))
+	for id := range m.scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	clauses := make([]map[string]interface{}, len(ids))
+	for i, id := range ids {
+		clauses[i] = map[string]interface{}{
+			"constant_score": map[string]interface{}{
+				"filter": map[string]interface{}{"term": map[string]interface{}{"id": id.String()}},
+				"boost":  m.scores[id],
+			},
+		}
+	}
+	return clauses
+}
+
+// apply 将匹配的消息合并到对话文档中，并补充消息字段的高亮
+func (m *flatMessageMatches) apply(docs []*models.ConversationDocument, highlights []map[string]interface{}) {
+	for i, doc := range docs {
+		m.merge(doc)
+		for field, fragments := range m.highlights[doc.ID] {
+			highlights[i][field] = fragments
+		}
+	}
+}
+
+// merge 将匹配的消息合并到对话文档的消息中（按 ID 去重，按 sequence 和创建时间排序）。
+// 嵌套文档可能因 MaxMessages 不包含较早的匹配消息，补充读取嵌套消息后需要再次合并
+func (m *flatMessageMatches) merge(doc *models.ConversationDocument) {
+	matched, ok := m.messages[doc.ID]
+	if !ok {
+		return
+	}
+
+	seen := make(map[uuid.UUID]bool, len(doc.Messages))
+	for _, message := range doc.Messages {
+		seen[message.ID] = true
+	}
+	added := false
+	for _, message := range matched {
+		if !seen[message.ID] {
+			doc.Messages = append(doc.Messages, message)
+			added = true
+		}
+	}
+	if added {
+		sort.SliceStable(doc.Messages, func(i, j int) bool {
+			return models.MessageDocumentLess(&doc.Messages[i], &doc.Messages[j])
+		})
+	}
+}
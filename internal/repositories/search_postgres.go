@@ -0,0 +1,220 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"chat-assistant-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrSearchFallbackUnsupported 降级到 Postgres 搜索时不支持的查询（高级过滤、元信息过滤、相似对话）
+var ErrSearchFallbackUnsupported = errors.New("not supported while Elasticsearch is unavailable")
+
+// likeEscaper 转义 ILIKE 模式中的通配符，关键词按原文匹配
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// PostgresSearchRepository 在 Elasticsearch 不可用时直接在数据库中搜索（search.fallback）：
+// 关键词按 ILIKE 子串匹配标题、消息和标签，支持用户、平台、标签、日期、角色和字段组过滤，
+// 不计算相关性，结果按创建时间（OrderActivity 时按最后一条消息时间）倒序
+type PostgresSearchRepository struct {
+	db *gorm.DB
+}
+
+// NewPostgresSearchRepository creates the Postgres search used as the Elasticsearch fallback
+func NewPostgresSearchRepository(db *gorm.DB) SearchRepository {
+	return &PostgresSearchRepository{db: db}
+}
+
+// SearchConversationsWithMatchedMessages searches conversations with ILIKE and returns
+// up to maxMatchedMessages matching messages per conversation
+func (r *PostgresSearchRepository) SearchConversationsWithMatchedMessages(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	start := time.Now()
+	if params.Filter != nil || len(params.Metadata) > 0 {
+		return nil, fmt.Errorf("filter and metadata search are %w", ErrSearchFallbackUnsupported)
+	}
+
+	db := r.db.WithContext(ctx)
+	query := strings.TrimSpace(params.Query)
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	groups := searchFieldSet(params.Fields)
+
+	conversations := db.Model(&models.Conversation{})
+	if params.UserID != nil {
+		conversations = conversations.Where("user_id = ?", *params.UserID)
+	}
+	if params.ProviderID != nil {
+		conversations = conversations.Where("provider = ?", *params.ProviderID)
+	}
+	if params.TagID != nil {
+		conversations = conversations.Where("id IN (?)", db.Table("conversation_tags").Select("conversation_id").Where("tag_id = ?", *params.TagID))
+	}
+	if len(params.TagIDs) > 0 {
+		if params.TagMatch == TagMatchAny {
+			conversations = conversations.Where("id IN (?)", db.Table("conversation_tags").Select("conversation_id").Where("tag_id IN ?", params.TagIDs))
+		} else {
+			for _, tagID := range params.TagIDs {
+				conversations = conversations.Where("id IN (?)", db.Table("conversation_tags").Select("conversation_id").Where("tag_id = ?", tagID))
+			}
+		}
+	}
+	if params.StartDate != nil {
+		conversations = conversations.Where("created_at >= ?", *params.StartDate)
+	}
+	if params.EndDate != nil {
+		conversations = conversations.Where("created_at <= ?", *params.EndDate)
+	}
+	if params.Role != nil {
+		conversations = conversations.Where("id IN (?)", db.Model(&models.Message{}).Select("conversation_id").Where("role = ?", *params.Role))
+	}
+
+	if query != "" {
+		var conditions []string
+		var args []interface{}
+		if groups[SearchFieldTitle] {
+			conditions = append(conditions, "title ILIKE ? OR source_title ILIKE ?")
+			args = append(args, pattern, pattern)
+		}
+		if groups[SearchFieldMessages] {
+			conditions = append(conditions, "id IN (?)")
+			args = append(args, r.matchingMessages(db, pattern, params.Role).Select("conversation_id"))
+		}
+		if groups[SearchFieldTags] {
+			conditions = append(conditions, "id IN (?)")
+			args = append(args, db.Table("conversation_tags").
+				Select("conversation_tags.conversation_id").
+				Joins("JOIN tags ON tags.id = conversation_tags.tag_id AND tags.deleted_at IS NULL").
+				Where("tags.name ILIKE ?", pattern))
+		}
+		conversations = conversations.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+
+	var total int64
+	if err := conversations.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count conversations: %w", err)
+	}
+
+	order := OrderCreated
+	if params.Order == OrderActivity {
+		order = OrderActivity
+	}
+	var found []*models.Conversation
+	err := conversations.Preload("Tags").
+		Order(listOrder(order)).
+		Offset((params.Page - 1) * params.Limit).
+		Limit(params.Limit).
+		Find(&found).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to search conversations: %w", err)
+	}
+
+	result := &SearchResult{
+		Documents:            make([]*models.ConversationDocument, len(found)),
+		MatchedMessages:      make(map[uuid.UUID][]*models.MessageDocument),
+		MatchedFields:        make(map[uuid.UUID][]string),
+		Highlights:           make(map[uuid.UUID]map[string][]string),
+		ContextMessages:      make(map[uuid.UUID]bool),
+		MessageMatchedFields: make(map[uuid.UUID][]string),
+		MessageHighlights:    make(map[uuid.UUID]map[string][]string),
+		Total:                total,
+	}
+	for i, conv := range found {
+		doc := conv.ToESDocument()
+		result.Documents[i] = doc
+
+		var matchedFields []string
+		if query != "" && groups[SearchFieldTitle] {
+			if countKeywordMatches(conv.Title, query) > 0 {
+				matchedFields = append(matchedFields, "title")
+			}
+			if countKeywordMatches(conv.SourceTitle, query) > 0 {
+				matchedFields = append(matchedFields, "source_title")
+			}
+		}
+
+		if query != "" && groups[SearchFieldMessages] {
+			var messages []*models.Message
+			err := r.matchingMessages(db, pattern, params.Role).
+				Where("conversation_id = ?", conv.ID).
+				Order("sequence ASC, created_at ASC, id ASC").
+				Limit(maxMatchedMessages).
+				Find(&messages).Error
+			if err != nil {
+				return nil, fmt.Errorf("failed to load matched messages: %w", err)
+			}
+			for _, msg := range messages {
+				msgDoc := msg.ToESDocument()
+				result.MatchedMessages[conv.ID] = append(result.MatchedMessages[conv.ID], &msgDoc)
+				if fields := messageMatchedFieldNames(&msgDoc, query); len(fields) > 0 {
+					result.MessageMatchedFields[msg.ID] = fields
+				}
+				for _, field := range result.MessageMatchedFields[msg.ID] {
+					matchedFields = appendUnique(matchedFields, "messages."+field)
+				}
+			}
+		}
+
+		if query != "" && groups[SearchFieldTags] {
+			for _, tag := range conv.Tags {
+				if countKeywordMatches(tag.Name, query) > 0 {
+					matchedFields = append(matchedFields, "tags.name")
+					break
+				}
+			}
+		}
+
+		// 总是设置 matched_fields，即使为空
+		result.MatchedFields[conv.ID] = matchedFields
+	}
+
+	result.Meta = &models.SearchMeta{
+		PostProcessMs: time.Since(start).Milliseconds(),
+		Fallback:      true,
+	}
+	return result, nil
+}
+
+// matchingMessages 内容或原始内容包含关键词的消息，role 不为空时只匹配该角色
+func (r *PostgresSearchRepository) matchingMessages(db *gorm.DB, pattern string, role *string) *gorm.DB {
+	messages := db.Model(&models.Message{}).Where("content ILIKE ? OR source_content ILIKE ?", pattern, pattern)
+	if role != nil {
+		messages = messages.Where("role = ?", *role)
+	}
+	return messages
+}
+
+// FindSimilar needs Elasticsearch's more_like_this and is not available in the fallback
+func (r *PostgresSearchRepository) FindSimilar(conversationID uuid.UUID, userID *uuid.UUID, limit int) ([]*models.ConversationDocument, error) {
+	return nil, fmt.Errorf("similar conversations are %w", ErrSearchFallbackUnsupported)
+}
+
+// Stats returns no counters; fallback searches are counted by FallbackSearchRepository
+func (r *PostgresSearchRepository) Stats() SearchStats {
+	return SearchStats{}
+}
+
+// searchFieldSet 关键词匹配的字段组，为空时匹配全部字段组
+func searchFieldSet(fields []string) map[string]bool {
+	set := make(map[string]bool, len(SearchFieldGroups))
+	if len(fields) == 0 {
+		fields = SearchFieldGroups
+	}
+	for _, field := range fields {
+		set[field] = true
+	}
+	return set
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
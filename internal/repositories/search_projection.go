@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"chat-assistant-backend/internal/models"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/google/uuid"
+)
+
+// messagesFromInnerHits 后置过滤是否通过嵌套消息子句的 inner_hits 读取匹配的消息。
+// 搜索从不在 _source 中返回完整的嵌套消息：后置过滤只对候选窗口内每个对话的匹配消息
+// （见 innerHitMessages）做精确匹配和评分，当前页需要展示消息的对话再通过 loadMessages 补充。
+// 关键词在独立消息索引中匹配时，匹配的消息随消息搜索读取，不需要 inner_hits
+func messagesFromInnerHits(params SearchParams) bool {
+	return params.Query != "" && !params.SkipPostFilter && params.messageMatches == nil
+}
+
+// loadMessages 通过 mget 读取指定对话的嵌套消息并填充到 docs 中
+func (r *ElasticsearchRepositoryImpl) loadMessages(ctx context.Context, docs []*models.ConversationDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(docs))
+	byID := make(map[uuid.UUID]*models.ConversationDocument, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID.String()
+		byID[doc.ID] = doc
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"ids": ids})
+	req := esapi.MgetRequest{
+		Index:          r.indexName,
+		Body:           bytes.NewReader(body),
+		SourceIncludes: []string{"id", "messages"},
+	}
+
+	res, err := req.Do(ctx, r.esClient)
+	if err != nil {
+		return fmt.Errorf("failed to load conversation messages: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("mget request failed with status: %s", res.Status())
+	}
+
+	var mgetResponse struct {
+		Docs []struct {
+			ID     string                 `json:"_id"`
+			Found  bool                   `json:"found"`
+			Source map[string]interface{} `json:"_source"`
+		} `json:"docs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&mgetResponse); err != nil {
+		return fmt.Errorf("failed to decode mget response: %w", err)
+	}
+
+	for _, item := range mgetResponse.Docs {
+		if !item.Found {
+			continue
+		}
+		id, err := uuid.Parse(item.ID)
+		if err != nil {
+			continue
+		}
+		doc, ok := byID[id]
+		if !ok {
+			continue
+		}
+
+		loaded := &models.ConversationDocument{}
+		if err := r.parseDocument(item.Source, loaded); err != nil {
+			continue // 跳过解析失败的文档
+		}
+		doc.Messages = loaded.Messages
+	}
+	return nil
+}
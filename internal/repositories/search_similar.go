@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"chat-assistant-backend/internal/models"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/google/uuid"
+)
+
+const (
+	// similarMinChars 源对话文本少于该字符数时不做相似推荐，避免按少量常见词匹配出无关结果
+	similarMinChars = 20
+	// similarMaxChars more_like_this 的 like 文本上限，长对话只取前面部分
+	similarMaxChars = 10000
+)
+
+// FindSimilar finds up to limit conversations similar to conversationID using ES
+// more_like_this on titles and message content. Results are restricted to userID,
+// or to the source conversation's user when userID is nil, and never include the
+// source itself. Returns nil when the source is not indexed or has too little text
+func (r *ElasticsearchRepositoryImpl) FindSimilar(conversationID uuid.UUID, userID *uuid.UUID, limit int) ([]*models.ConversationDocument, error) {
+	ctx := context.Background()
+
+	source, err := r.getConversationDocument(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, nil
+	}
+
+	likeText := similarLikeText(source)
+	if utf8.RuneCountInString(likeText) < similarMinChars {
+		return nil, nil
+	}
+
+	if userID == nil {
+		userID = &source.UserID
+	}
+
+	searchQuery, _ := json.Marshal(buildSimilarQuery(conversationID, *userID, likeText, limit))
+	req := esapi.SearchRequest{
+		Index: []string{r.indexName},
+		Body:  bytes.NewReader(searchQuery),
+	}
+
+	res, err := req.Do(ctx, r.esClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute similar search: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("similar search request failed with status: %s", res.Status())
+	}
+
+	var searchResponse map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&searchResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode similar search response: %w", err)
+	}
+
+	docs, _, err := r.parseSearchResponse(searchResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	// must_not 已排除源对话，这里再过滤一次以防万一
+	similar := make([]*models.ConversationDocument, 0, len(docs))
+	for _, doc := range docs {
+		if doc.ID != conversationID {
+			similar = append(similar, doc)
+		}
+	}
+	return similar, nil
+}
+
+// getConversationDocument 按 ID 读取 ES 中的对话文档，不存在时返回 nil
+func (r *ElasticsearchRepositoryImpl) getConversationDocument(ctx context.Context, id uuid.UUID) (*models.ConversationDocument, error) {
+	req := esapi.GetRequest{
+		Index:      r.indexName,
+		DocumentID: id.String(),
+	}
+
+	res, err := req.Do(ctx, r.esClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation document: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("get request failed with status: %s", res.Status())
+	}
+
+	var getResponse struct {
+		Found  bool                   `json:"found"`
+		Source map[string]interface{} `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&getResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode get response: %w", err)
+	}
+	if !getResponse.Found {
+		return nil, nil
+	}
+
+	doc := &models.ConversationDocument{}
+	if err := r.parseDocument(getResponse.Source, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// similarLikeText 拼接标题和消息内容作为 more_like_this 的 like 文本
+func similarLikeText(doc *models.ConversationDocument) string {
+	title := doc.Title
+	if title == "" {
+		title = doc.SourceTitle
+	}
+
+	parts := []string{title}
+	for _, msg := range doc.Messages {
+		content := msg.Content
+		if content == "" {
+			content = msg.SourceContent
+		}
+		parts = append(parts, content)
+	}
+
+	text := strings.TrimSpace(strings.Join(parts, "\n"))
+	if utf8.RuneCountInString(text) > similarMaxChars {
+		text = string([]rune(text)[:similarMaxChars])
+	}
+	return text
+}
+
+// buildSimilarQuery 构建相似对话查询：标题和嵌套的消息内容各用一个 more_like_this，
+// 只在同一用户的对话中查找并排除源对话
+func buildSimilarQuery(conversationID, userID uuid.UUID, likeText string, limit int) map[string]interface{} {
+	moreLikeThis := func(fields ...string) map[string]interface{} {
+		return map[string]interface{}{
+			"more_like_this": map[string]interface{}{
+				"fields":          fields,
+				"like":            likeText,
+				"min_term_freq":   1,
+				"min_doc_freq":    1,
+				"max_query_terms": 25,
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"size": limit,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []map[string]interface{}{
+					moreLikeThis("title", "source_title"),
+					{
+						"nested": map[string]interface{}{
+							"path":       "messages",
+							"query":      moreLikeThis("messages.content", "messages.source_content"),
+							"score_mode": "max",
+						},
+					},
+				},
+				"minimum_should_match": 1,
+				"filter": []map[string]interface{}{
+					{"term": map[string]interface{}{"user_id": userID.String()}},
+				},
+				"must_not": []map[string]interface{}{
+					{"ids": map[string]interface{}{"values": []string{conversationID.String()}}},
+				},
+			},
+		},
+		// 推荐列表只展示对话信息，不返回消息
+		"_source": map[string]interface{}{
+			"excludes": []string{"messages"},
+		},
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
 // TagRepository defines the interface for tag repository
@@ -17,6 +18,7 @@ type TagRepository interface {
 	Delete(id uuid.UUID) error
 	FindAll() ([]*models.Tag, error)
 	CreateOrGetTags(names []string) ([]*models.Tag, error)
+	MergeTags(targetID uuid.UUID, name string, duplicateIDs []uuid.UUID) error
 }
 
 // TagRepositoryImpl handles tag data access
@@ -47,7 +49,8 @@ func (r *TagRepositoryImpl) GetByID(id uuid.UUID) (*models.Tag, error) {
 // GetByName retrieves a tag by name
 func (r *TagRepositoryImpl) GetByName(name string) (*models.Tag, error) {
 	var tag models.Tag
-	err := r.db.Where("name = ?", name).First(&tag).Error
+	// 创建前查重依赖该结果，需读主库避免副本延迟
+	err := r.db.Clauses(dbresolver.Write).Where("name = ?", name).First(&tag).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil // Return nil tag and nil error for not found
@@ -64,7 +67,8 @@ func (r *TagRepositoryImpl) GetByNames(names []string) ([]*models.Tag, error) {
 	}
 
 	var tags []*models.Tag
-	err := r.db.Where("name IN ?", names).Find(&tags).Error
+	// CreateOrGetTags 据此决定需要创建的标签，需读主库避免副本延迟
+	err := r.db.Clauses(dbresolver.Write).Where("name IN ?", names).Find(&tags).Error
 	if err != nil {
 		return nil, err
 	}
@@ -149,3 +153,32 @@ func (r *TagRepositoryImpl) CreateOrGetTags(names []string) ([]*models.Tag, erro
 
 	return result, nil
 }
+
+// MergeTags renames the target tag and merges duplicate tags into it
+func (r *TagRepositoryImpl) MergeTags(targetID uuid.UUID, name string, duplicateIDs []uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(duplicateIDs) > 0 {
+			// 将重复标签的对话关系迁移到目标标签
+			err := tx.Exec(`INSERT INTO conversation_tags (conversation_id, tag_id)
+				SELECT conversation_id, ? FROM conversation_tags WHERE tag_id IN ?
+				ON CONFLICT DO NOTHING`, targetID, duplicateIDs).Error
+			if err != nil {
+				return err
+			}
+
+			err = tx.Exec("DELETE FROM conversation_tags WHERE tag_id IN ?", duplicateIDs).Error
+			if err != nil {
+				return err
+			}
+
+			// 删除重复标签
+			err = tx.Delete(&models.Tag{}, duplicateIDs).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// 更新目标标签名称
+		return tx.Model(&models.Tag{}).Where("id = ?", targetID).Update("name", name).Error
+	})
+}
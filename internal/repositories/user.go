@@ -10,6 +10,7 @@ import (
 // UserRepository defines the interface for user repository
 type UserRepository interface {
 	GetByID(id uuid.UUID) (*models.User, error)
+	Create(user *models.User) error
 }
 
 // UserRepositoryImpl handles user data access
@@ -36,3 +37,8 @@ func (r *UserRepositoryImpl) GetByID(id uuid.UUID) (*models.User, error) {
 	}
 	return &user, nil
 }
+
+// Create creates a new user
+func (r *UserRepositoryImpl) Create(user *models.User) error {
+	return r.db.Create(user).Error
+}
@@ -10,5 +10,5 @@ var RepositorySet = wire.NewSet(
 	NewConversationRepository,
 	NewMessageRepository,
 	NewTagRepository,
-	NewElasticsearchRepository,
+	NewAuditLogRepository,
 )
@@ -21,3 +21,34 @@ type UpdateConversationRequest struct {
 	SourceTitle string       `json:"source_title"`
 	Tags        []TagRequest `json:"tags,omitempty"`
 }
+
+// PatchConversationRequest represents a partial conversation update. Omitted fields are
+// left unchanged; an empty string is a value (e.g. "title": "" clears the title)
+type PatchConversationRequest struct {
+	Title      *string  `json:"title"`
+	Model      *string  `json:"model"`
+	AddTags    []string `json:"add_tags" binding:"omitempty,max=100"`
+	RemoveTags []string `json:"remove_tags" binding:"omitempty,max=100"`
+}
+
+// Empty reports whether the request changes nothing
+func (r *PatchConversationRequest) Empty() bool {
+	return r.Title == nil && r.Model == nil && len(r.AddTags) == 0 && len(r.RemoveTags) == 0
+}
+
+// TransferConversationRequest represents a request to move a conversation to another user
+type TransferConversationRequest struct {
+	TargetUserID uuid.UUID `json:"target_user_id" binding:"required"`
+}
+
+// MergeConversationsRequest represents a request to merge conversations into a target
+type MergeConversationsRequest struct {
+	TargetID  uuid.UUID   `json:"target_id" binding:"required"`
+	SourceIDs []uuid.UUID `json:"source_ids" binding:"required,min=1,max=100"`
+}
+
+// CloneConversationRequest represents a request to copy a conversation; the copy keeps the
+// original owner when TargetUserID is omitted
+type CloneConversationRequest struct {
+	TargetUserID *uuid.UUID `json:"target_user_id"`
+}
@@ -0,0 +1,30 @@
+package request
+
+import (
+	"encoding/json"
+
+	"chat-assistant-backend/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// SearchRequest represents a search request body for POST /api/v1/search
+type SearchRequest struct {
+	Query      string                   `json:"q" example:"kubernetes"`
+	UserID     *uuid.UUID               `json:"user_id"`
+	ProviderID string                   `json:"provider_id" example:"chatgpt"`
+	TagID      *uuid.UUID               `json:"tag_id"`
+	TagIDs     []uuid.UUID              `json:"tag_ids"`
+	TagMatch   repositories.TagMatch    `json:"tag_match" binding:"omitempty,oneof=all any" enums:"all,any"` // all（默认）或 any
+	StartDate  string                   `json:"start_date" example:"2024-01-01"`                             // YYYY-MM-DD
+	EndDate    string                   `json:"end_date" example:"2024-12-31"`                               // YYYY-MM-DD
+	Role       string                   `json:"role" binding:"omitempty,oneof=user assistant system" enums:"user,assistant,system"`
+	Metadata   map[string]string        `json:"meta"`
+	Filter     json.RawMessage          `json:"filter" swaggertype:"object"`
+	MinScore   *float64                 `json:"min_score" binding:"omitempty,min=0"`
+	Sort       repositories.SearchSort  `json:"sort" binding:"omitempty,oneof=relevance date" enums:"relevance,date"`                          // relevance（默认）或 date（只按创建时间倒序）
+	Order      repositories.SearchOrder `json:"order" binding:"omitempty,oneof=relevance activity created" enums:"relevance,activity,created"` // sort 的别名，另支持 activity；与 sort 同时给出时必须一致
+	Fields     []string                 `json:"fields"`                                                                                        // 关键词匹配的字段组：title、messages、tags，为空时匹配全部
+	Page       int                      `json:"page" binding:"omitempty,min=1"`
+	Limit      int                      `json:"limit" binding:"omitempty,min=1,max=100"`
+}
@@ -11,6 +11,14 @@ type CreateTagRequest struct {
 	Name string `json:"name" binding:"required"`
 }
 
+// MaxTagBatchSize 批量创建标签时单次请求最多的名称数
+const MaxTagBatchSize = 100
+
+// BatchCreateTagsRequest represents a request to create several tags at once
+type BatchCreateTagsRequest struct {
+	Names []string `json:"names" binding:"required,min=1,max=100"`
+}
+
 // UpdateTagRequest represents a request to update a tag
 type UpdateTagRequest struct {
 	Name string `json:"name" binding:"required"`
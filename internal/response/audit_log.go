@@ -0,0 +1,48 @@
+package response
+
+import (
+	"encoding/json"
+
+	"chat-assistant-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// AuditLogResponse represents an audit log in API response
+type AuditLogResponse struct {
+	ID           uuid.UUID       `json:"id"`
+	ActorUserID  *uuid.UUID      `json:"actor_user_id"`
+	Action       string          `json:"action" example:"conversation.delete"`
+	ResourceType string          `json:"resource_type" example:"conversation"`
+	ResourceID   uuid.UUID       `json:"resource_id"`
+	Details      json.RawMessage `json:"details,omitempty" swaggertype:"object"`
+	CreatedAt    string          `json:"created_at"`
+}
+
+// AuditLogListResponse represents a list of audit logs in API response
+type AuditLogListResponse struct {
+	AuditLogs []AuditLogResponse `json:"audit_logs"`
+}
+
+// NewAuditLogListResponse creates an AuditLogListResponse from audit logs
+func NewAuditLogListResponse(logs []*models.AuditLog) *AuditLogListResponse {
+	auditLogs := make([]AuditLogResponse, len(logs))
+	for i, log := range logs {
+		auditLogs[i] = AuditLogResponse{
+			ID:           log.ID,
+			ActorUserID:  log.ActorUserID,
+			Action:       log.Action,
+			ResourceType: log.ResourceType,
+			ResourceID:   log.ResourceID,
+			CreatedAt:    log.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		}
+		// 详情按 JSON 对象原样返回
+		if log.Details != "" && json.Valid([]byte(log.Details)) {
+			auditLogs[i].Details = json.RawMessage(log.Details)
+		}
+	}
+
+	return &AuditLogListResponse{
+		AuditLogs: auditLogs,
+	}
+}
@@ -1,6 +1,12 @@
 package response
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
 	"chat-assistant-backend/internal/models"
 
 	"github.com/google/uuid"
@@ -8,17 +14,34 @@ import (
 
 // ConversationResponse represents a conversation in API response
 type ConversationResponse struct {
-	ID        uuid.UUID     `json:"id"`
-	UserID    uuid.UUID     `json:"user_id"`
-	Title     string        `json:"title"`
-	Provider  string        `json:"provider"`
-	Model     string        `json:"model"`
-	SourceID  string        `json:"source_id"`
-	Tags      []TagResponse `json:"tags"`
-	CreatedAt string        `json:"created_at"`
-	UpdatedAt string        `json:"updated_at"`
+	ID       uuid.UUID `json:"id"`
+	UserID   uuid.UUID `json:"user_id"`
+	Title    string    `json:"title"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	// provider/model 组合，如 "openai/gpt-4"；model 为空时仅为 provider
+	ProviderModel string        `json:"provider_model"`
+	SourceID      string        `json:"source_id,omitempty"`
+	SourceTitle   string        `json:"source_title,omitempty"`
+	Tags          []TagResponse `json:"tags"`
+	CreatedAt     string        `json:"created_at"`
+	UpdatedAt     string        `json:"updated_at"`
+	// 最后一条消息的时间，没有消息时省略
+	LastMessageAt string `json:"last_message_at,omitempty"`
+	// 最后一条消息预览，仅在 with_preview=true 时返回
+	LastMessage *MessagePreviewResponse `json:"last_message,omitempty"`
+}
+
+// MessagePreviewResponse represents a truncated message preview in API response
+type MessagePreviewResponse struct {
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
 }
 
+// messagePreviewMaxRunes 消息预览的最大字符数
+const messagePreviewMaxRunes = 100
+
 // ConversationListResponse represents a list of conversations in API response
 type ConversationListResponse struct {
 	Conversations []ConversationResponse `json:"conversations"`
@@ -41,16 +64,61 @@ func NewConversationResponse(conversation *models.Conversation) *ConversationRes
 	}
 
 	return &ConversationResponse{
-		ID:        conversation.Base.ID,
-		UserID:    conversation.UserID,
-		Title:     title,
-		Provider:  conversation.Provider,
-		Model:     conversation.Model,
-		SourceID:  conversation.SourceID,
-		Tags:      tags,
-		CreatedAt: conversation.Base.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		UpdatedAt: conversation.Base.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		ID:            conversation.Base.ID,
+		UserID:        conversation.UserID,
+		Title:         title,
+		Provider:      conversation.Provider,
+		Model:         conversation.Model,
+		ProviderModel: providerModel(conversation.Provider, conversation.Model),
+		SourceID:      conversation.SourceID,
+		SourceTitle:   conversation.SourceTitle,
+		Tags:          tags,
+		CreatedAt:     conversation.Base.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:     conversation.Base.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		LastMessageAt: formatOptionalTime(conversation.LastMessageAt),
+	}
+}
+
+// formatOptionalTime 格式化可能为空的时间，nil 返回空字符串
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02T15:04:05Z07:00")
+}
+
+// providerModel 拼接 provider 与 model，供对话和搜索结果共用
+func providerModel(provider, model string) string {
+	if model == "" {
+		return provider
 	}
+	return provider + "/" + model
+}
+
+// ConversationDetailResponse represents a conversation with inline messages in API response
+type ConversationDetailResponse struct {
+	ConversationResponse
+	// 消息第一页，仅在 include=messages 时返回
+	Messages      []MessageResponse `json:"messages,omitempty"`
+	MessagesTotal *int64            `json:"messages_total,omitempty"`
+}
+
+// NewConversationDetailResponse creates a ConversationDetailResponse; messages are only
+// included when includeMessages is true
+func NewConversationDetailResponse(conversation *models.Conversation, includeMessages bool, messagesTotal int64) *ConversationDetailResponse {
+	detail := &ConversationDetailResponse{
+		ConversationResponse: *NewConversationResponse(conversation),
+	}
+
+	if includeMessages {
+		detail.Messages = make([]MessageResponse, len(conversation.Messages))
+		for i := range conversation.Messages {
+			detail.Messages[i] = *NewMessageResponse(&conversation.Messages[i])
+		}
+		detail.MessagesTotal = &messagesTotal
+	}
+
+	return detail
 }
 
 // NewConversationListResponse creates a ConversationListResponse from a slice of models.Conversation
@@ -64,3 +132,53 @@ func NewConversationListResponse(conversations []*models.Conversation) *Conversa
 		Conversations: conversationResponses,
 	}
 }
+
+// NewMessagePreviewResponse creates a truncated MessagePreviewResponse from models.Message
+func NewMessagePreviewResponse(message *models.Message) *MessagePreviewResponse {
+	content := message.Content
+	if content == "" {
+		content = message.SourceContent
+	}
+
+	if runes := []rune(content); len(runes) > messagePreviewMaxRunes {
+		content = string(runes[:messagePreviewMaxRunes]) + "..."
+	}
+
+	return &MessagePreviewResponse{
+		Role:      message.Role,
+		Content:   content,
+		CreatedAt: message.Base.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// NewConversationListResponseWithPreview creates a ConversationListResponse including last message previews
+func NewConversationListResponseWithPreview(conversations []*models.Conversation, lastMessages map[uuid.UUID]*models.Message) *ConversationListResponse {
+	listResponse := NewConversationListResponse(conversations)
+	for i := range listResponse.Conversations {
+		if message, exists := lastMessages[listResponse.Conversations[i].ID]; exists {
+			listResponse.Conversations[i].LastMessage = NewMessagePreviewResponse(message)
+		}
+	}
+
+	return listResponse
+}
+
+// ConversationETag computes the ETag of a conversation detail response from updated_at,
+// title, tags and the message count. Tag changes do not touch updated_at, so tags are hashed directly;
+// includeMessages distinguishes the two representations of the same conversation
+func ConversationETag(conversation *models.Conversation, includeMessages bool, messagesTotal int64) string {
+	tags := make([]string, len(conversation.Tags))
+	for i, tag := range conversation.Tags {
+		tags[i] = tag.ID.String() + ":" + tag.Name
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s|%t|%d",
+		conversation.ID,
+		conversation.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		conversation.Title,
+		strings.Join(tags, ","),
+		includeMessages,
+		messagesTotal,
+	)))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
@@ -2,8 +2,10 @@ package response
 
 import (
 	"net/http"
+	"strings"
 
 	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/i18n"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +18,30 @@ func Success(c *gin.Context, data interface{}) {
 	})
 }
 
+// WithETag sets the ETag header and, when the request's If-None-Match matches it,
+// responds 304 Not Modified. Returns true if the response has been written
+func WithETag(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+
+	for _, candidate := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			c.Status(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			return true
+		}
+	}
+	return false
+}
+
+// Accepted sends a 202 response for work that continues in the background
+func Accepted(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusAccepted, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
 // SuccessWithMeta sends a success response with metadata
 func SuccessWithMeta(c *gin.Context, data interface{}, meta *MetaInfo) {
 	c.JSON(http.StatusOK, MetaResponse{
@@ -29,6 +55,10 @@ func SuccessWithMeta(c *gin.Context, data interface{}, meta *MetaInfo) {
 
 // SuccessPaginated sends a paginated success response
 func SuccessPaginated(c *gin.Context, data interface{}, pagination *PaginationInfo) {
+	if pagination != nil {
+		pagination.SetNavigation()
+	}
+
 	c.JSON(http.StatusOK, PaginatedResponse{
 		Response: Response{
 			Success: true,
@@ -38,14 +68,45 @@ func SuccessPaginated(c *gin.Context, data interface{}, pagination *PaginationIn
 	})
 }
 
+// SuccessPaginatedWithMeta sends a paginated success response with metadata
+func SuccessPaginatedWithMeta(c *gin.Context, data interface{}, pagination *PaginationInfo, meta interface{}) {
+	if pagination != nil {
+		pagination.SetNavigation()
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Response: Response{
+			Success: true,
+			Data:    data,
+		},
+		Pagination: pagination,
+		Meta:       meta,
+	})
+}
+
+// localizeMessage returns the message for code in the negotiated language,
+// keeping the given message when no translation is available. Messages are written
+// in English, so English keeps the handler's specific message instead of the generic one
+func localizeMessage(c *gin.Context, code, message string) string {
+	lang := c.GetString(i18n.ContextKey)
+	if lang == "" || lang == i18n.LanguageEnglish {
+		return message
+	}
+	if localized, ok := i18n.Translate(lang, code); ok {
+		return localized
+	}
+	return message
+}
+
 // Error sends an error response
 func Error(c *gin.Context, statusCode int, code, message, details string) {
 	c.JSON(statusCode, Response{
 		Success: false,
 		Error: &ErrorInfo{
-			Code:    code,
-			Message: message,
-			Details: details,
+			Code:      code,
+			Message:   localizeMessage(c, code, message),
+			Details:   details,
+			RequestID: c.GetString("request_id"),
 		},
 	})
 }
@@ -55,9 +116,10 @@ func AppError(c *gin.Context, err *errors.AppError) {
 	c.JSON(err.Status, Response{
 		Success: false,
 		Error: &ErrorInfo{
-			Code:    err.Code,
-			Message: err.Message,
-			Details: err.Details,
+			Code:      err.Code,
+			Message:   localizeMessage(c, err.Code, err.Message),
+			Details:   err.Details,
+			RequestID: c.GetString("request_id"),
 		},
 	})
 }
@@ -87,6 +149,21 @@ func Conflict(c *gin.Context, code, message, details string) {
 	Error(c, http.StatusConflict, code, message, details)
 }
 
+// UnprocessableEntity sends an unprocessable entity response
+func UnprocessableEntity(c *gin.Context, code, message, details string) {
+	Error(c, http.StatusUnprocessableEntity, code, message, details)
+}
+
+// RequestEntityTooLarge sends a request entity too large response
+func RequestEntityTooLarge(c *gin.Context, code, message, details string) {
+	Error(c, http.StatusRequestEntityTooLarge, code, message, details)
+}
+
+// UnsupportedMediaType sends an unsupported media type response
+func UnsupportedMediaType(c *gin.Context, code, message, details string) {
+	Error(c, http.StatusUnsupportedMediaType, code, message, details)
+}
+
 // InternalServerError sends an internal server error response
 func InternalServerError(c *gin.Context, code, message, details string) {
 	Error(c, http.StatusInternalServerError, code, message, details)
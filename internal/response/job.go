@@ -0,0 +1,40 @@
+package response
+
+import (
+	"chat-assistant-backend/internal/jobs"
+)
+
+// JobResponse represents a background job in API response
+type JobResponse struct {
+	JobID      string  `json:"job_id"`
+	State      string  `json:"state" example:"running"`
+	Processed  int     `json:"processed"`
+	Total      int     `json:"total"`
+	Error      string  `json:"error,omitempty"`
+	CreatedAt  string  `json:"created_at"`
+	StartedAt  *string `json:"started_at,omitempty"`
+	FinishedAt *string `json:"finished_at,omitempty"`
+}
+
+// NewJobResponse creates a JobResponse from jobs.Job
+func NewJobResponse(job *jobs.Job) *JobResponse {
+	resp := &JobResponse{
+		JobID:     job.ID,
+		State:     string(job.State),
+		Processed: job.Processed,
+		Total:     job.Total,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	if job.StartedAt != nil {
+		startedAt := job.StartedAt.Format("2006-01-02T15:04:05Z07:00")
+		resp.StartedAt = &startedAt
+	}
+	if job.FinishedAt != nil {
+		finishedAt := job.FinishedAt.Format("2006-01-02T15:04:05Z07:00")
+		resp.FinishedAt = &finishedAt
+	}
+
+	return resp
+}
@@ -12,8 +12,19 @@ type MessageResponse struct {
 	ConversationID uuid.UUID `json:"conversation_id"`
 	Role           string    `json:"role"`
 	Content        string    `json:"content"`
+	Sequence       int       `json:"sequence"`
 	CreatedAt      string    `json:"created_at"`
 	UpdatedAt      string    `json:"updated_at"`
+
+	Attachments []MessageAttachmentResponse `json:"attachments,omitempty"`
+}
+
+// MessageAttachmentResponse represents a file reference attached to a message
+type MessageAttachmentResponse struct {
+	ID       uuid.UUID `json:"id"`
+	Type     string    `json:"type"`
+	Name     string    `json:"name"`
+	URLOrRef string    `json:"url_or_ref,omitempty"`
 }
 
 // MessageListResponse represents a list of messages in API response
@@ -27,14 +38,29 @@ func NewMessageResponse(message *models.Message) *MessageResponse {
 	if content == "" {
 		content = message.SourceContent
 	}
-	return &MessageResponse{
+	messageResponse := &MessageResponse{
 		ID:             message.Base.ID,
 		ConversationID: message.ConversationID,
 		Role:           message.Role,
 		Content:        content,
+		Sequence:       message.Sequence,
 		CreatedAt:      message.Base.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt:      message.Base.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
+
+	if len(message.Attachments) > 0 {
+		messageResponse.Attachments = make([]MessageAttachmentResponse, len(message.Attachments))
+		for i, attachment := range message.Attachments {
+			messageResponse.Attachments[i] = MessageAttachmentResponse{
+				ID:       attachment.ID,
+				Type:     attachment.Type,
+				Name:     attachment.Name,
+				URLOrRef: attachment.URLOrRef,
+			}
+		}
+	}
+
+	return messageResponse
 }
 
 // NewMessageListResponse creates a MessageListResponse from a slice of models.Message
@@ -48,3 +74,19 @@ func NewMessageListResponse(messages []*models.Message) *MessageListResponse {
 		Messages: messageResponses,
 	}
 }
+
+// MessageContextResponse represents a message with its surrounding messages in API response
+type MessageContextResponse struct {
+	Message MessageResponse   `json:"message"`
+	Before  []MessageResponse `json:"before"`
+	After   []MessageResponse `json:"after"`
+}
+
+// NewMessageContextResponse creates a MessageContextResponse from models.MessageContext
+func NewMessageContextResponse(messageContext *models.MessageContext) *MessageContextResponse {
+	return &MessageContextResponse{
+		Message: *NewMessageResponse(messageContext.Message),
+		Before:  NewMessageListResponse(messageContext.Before).Messages,
+		After:   NewMessageListResponse(messageContext.After).Messages,
+	}
+}
@@ -0,0 +1,57 @@
+package response
+
+import (
+	"github.com/google/uuid"
+)
+
+// DeleteResponse represents the result of a delete operation in API response
+type DeleteResponse struct {
+	ID      uuid.UUID `json:"id"`
+	Deleted bool      `json:"deleted" example:"true"`
+	Message string    `json:"message" example:"Conversation deleted successfully"`
+}
+
+// NewDeleteResponse creates a DeleteResponse for the deleted resource
+func NewDeleteResponse(id uuid.UUID, message string) *DeleteResponse {
+	return &DeleteResponse{
+		ID:      id,
+		Deleted: true,
+		Message: message,
+	}
+}
+
+// BulkDeleteResponse represents the result of deleting every resource of an owner, such
+// as all conversations of a user; ID is the owner and Count the number of deleted resources
+type BulkDeleteResponse struct {
+	ID      uuid.UUID `json:"id"`
+	Deleted bool      `json:"deleted" example:"true"`
+	Count   int64     `json:"count" example:"42"`
+	Message string    `json:"message" example:"User conversations deleted successfully"`
+}
+
+// NewBulkDeleteResponse creates a BulkDeleteResponse for count resources deleted from owner id
+func NewBulkDeleteResponse(id uuid.UUID, count int64, message string) *BulkDeleteResponse {
+	return &BulkDeleteResponse{
+		ID:      id,
+		Deleted: true,
+		Count:   count,
+		Message: message,
+	}
+}
+
+// UpdateResponse represents the result of an update that does not return the
+// updated resource, such as replacing the tags of a conversation
+type UpdateResponse struct {
+	ID      uuid.UUID `json:"id"`
+	Updated bool      `json:"updated" example:"true"`
+	Message string    `json:"message" example:"Conversation tags updated successfully"`
+}
+
+// NewUpdateResponse creates an UpdateResponse for the updated resource
+func NewUpdateResponse(id uuid.UUID, message string) *UpdateResponse {
+	return &UpdateResponse{
+		ID:      id,
+		Updated: true,
+		Message: message,
+	}
+}
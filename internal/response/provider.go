@@ -0,0 +1,49 @@
+package response
+
+import "chat-assistant-backend/internal/models"
+
+// ProviderListResponse represents the providers present in a user's conversations
+type ProviderListResponse struct {
+	Providers []ProviderResponse `json:"providers"`
+}
+
+// ProviderResponse represents a provider with its models and conversation counts
+type ProviderResponse struct {
+	Provider string                  `json:"provider"`
+	Count    int64                   `json:"count"`
+	Models   []ProviderModelResponse `json:"models"`
+}
+
+// ProviderModelResponse represents a model under a provider with its conversation count
+type ProviderModelResponse struct {
+	Model string `json:"model"`
+	Count int64  `json:"count"`
+}
+
+// NewProviderListResponse groups provider/model counts by provider, keeping the input order
+func NewProviderListResponse(counts []*models.ProviderModelCount) *ProviderListResponse {
+	providers := make([]ProviderResponse, 0)
+	index := make(map[string]int)
+
+	for _, count := range counts {
+		i, exists := index[count.Provider]
+		if !exists {
+			i = len(providers)
+			index[count.Provider] = i
+			providers = append(providers, ProviderResponse{
+				Provider: count.Provider,
+				Models:   make([]ProviderModelResponse, 0),
+			})
+		}
+
+		providers[i].Count += count.Count
+		providers[i].Models = append(providers[i].Models, ProviderModelResponse{
+			Model: count.Model,
+			Count: count.Count,
+		})
+	}
+
+	return &ProviderListResponse{
+		Providers: providers,
+	}
+}
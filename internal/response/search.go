@@ -1,6 +1,8 @@
 package response
 
 import (
+	"strings"
+
 	"chat-assistant-backend/internal/models"
 
 	"github.com/google/uuid"
@@ -11,13 +13,21 @@ type SearchMessageResponse struct {
 	ID             uuid.UUID `json:"id"`
 	ConversationID uuid.UUID `json:"conversation_id"`
 	Role           string    `json:"role"`
-	Content        string    `json:"content"`
-	SourceID       string    `json:"source_id,omitempty"`
-	SourceContent  string    `json:"source_content,omitempty"`
-	CreatedAt      string    `json:"created_at"`
-	UpdatedAt      string    `json:"updated_at"`
+	// 启用 search.snippet_chars 时 content 和 source_content 不返回，完整内容通过 GET /messages/{id} 获取。
+	// content 为规范化内容，source_content 为原始内容，两者分别返回，不互相替代
+	Content       string `json:"content,omitempty"`
+	SourceID      string `json:"source_id,omitempty"`
+	SourceContent string `json:"source_content,omitempty"`
+	// 关键词第一次匹配位置前后的片段，截断处以 … 表示
+	Snippet   string `json:"snippet,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
 	// 匹配信息，用于前端高亮
-	MatchedFields []string `json:"matched_fields,omitempty"` // 匹配的字段名
+	MatchedFields []string `json:"matched_fields,omitempty"` // 匹配的字段名：content、source_content
+	// 高亮片段，键为 content 或 source_content，关键词以 <mark> 标记
+	Highlights map[string][]string `json:"highlights,omitempty"`
+	// 是否仅作为上下文返回（消息本身不匹配搜索关键词）
+	IsContext bool `json:"is_context,omitempty"`
 }
 
 // SearchTagResponse represents a tag in search results with highlighting
@@ -32,20 +42,25 @@ type SearchTagResponse struct {
 
 // SearchConversationResponse represents a conversation in search results with matched messages
 type SearchConversationResponse struct {
-	ID          uuid.UUID           `json:"id"`
-	UserID      uuid.UUID           `json:"user_id"`
-	Title       string              `json:"title"`
-	Provider    string              `json:"provider"`
-	Model       string              `json:"model"`
-	SourceID    string              `json:"source_id,omitempty"`
-	SourceTitle string              `json:"source_title,omitempty"`
-	Tags        []SearchTagResponse `json:"tags"`
-	CreatedAt   string              `json:"created_at"`
-	UpdatedAt   string              `json:"updated_at"`
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+	// title 为规范化标题，source_title 为原始标题，两者分别返回，不互相替代
+	Title    string `json:"title"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// provider/model 组合，与 ConversationResponse 一致
+	ProviderModel string              `json:"provider_model"`
+	SourceID      string              `json:"source_id,omitempty"`
+	SourceTitle   string              `json:"source_title,omitempty"`
+	Tags          []SearchTagResponse `json:"tags"`
+	CreatedAt     string              `json:"created_at"`
+	UpdatedAt     string              `json:"updated_at"`
 	// 匹配的消息列表（如果 conversation 匹配但消息不匹配，则为空；最多返回3条消息）
 	Messages []SearchMessageResponse `json:"messages"`
 	// 匹配信息，用于前端高亮
 	MatchedFields []string `json:"matched_fields,omitempty"` // 匹配的字段名，如 ["title", "messages.content"]
+	// 对话字段的高亮片段，键为 title、source_title 或 tags.name；消息的高亮片段在各消息的 highlights 中
+	Highlights map[string][]string `json:"highlights,omitempty"`
 }
 
 // SearchResponse represents the search results
@@ -54,18 +69,29 @@ type SearchResponse struct {
 	Conversations []SearchConversationResponse `json:"conversations"`
 }
 
+// SimilarConversationsResponse represents conversations similar to a given conversation
+type SimilarConversationsResponse struct {
+	Conversations []SearchConversationResponse `json:"conversations"`
+}
+
+// SearchMatches holds the match information of a search: maps keyed by conversation ID
+// (Messages, Fields, Highlights) or by message ID (the rest)
+type SearchMatches struct {
+	Messages          map[uuid.UUID][]*models.MessageDocument
+	Fields            map[uuid.UUID][]string
+	Highlights        map[uuid.UUID]map[string][]string
+	ContextMessages   map[uuid.UUID]bool
+	MessageFields     map[uuid.UUID][]string
+	MessageHighlights map[uuid.UUID]map[string][]string
+}
+
 // NewSearchMessageResponse creates a SearchMessageResponse from models.MessageDocument
 func NewSearchMessageResponse(messageDoc *models.MessageDocument, matchedFields []string) *SearchMessageResponse {
-	content := messageDoc.Content
-	if content == "" {
-		content = messageDoc.SourceContent
-	}
-
 	return &SearchMessageResponse{
 		ID:             messageDoc.ID,
 		ConversationID: messageDoc.ConversationID,
 		Role:           messageDoc.Role,
-		Content:        content,
+		Content:        messageDoc.Content,
 		SourceID:       messageDoc.SourceID,
 		SourceContent:  messageDoc.SourceContent,
 		CreatedAt:      messageDoc.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
@@ -85,24 +111,44 @@ func NewSearchTagResponse(tagDoc *models.TagDocument, matchedFields []string) *S
 	}
 }
 
-// NewSearchConversationResponse creates a SearchConversationResponse from models.ConversationDocument
-func NewSearchConversationResponse(conversationDoc *models.ConversationDocument, matchedMessages []*models.MessageDocument, matchedFields []string) *SearchConversationResponse {
-	title := conversationDoc.Title
-	if title == "" {
-		title = conversationDoc.SourceTitle
+// NewSearchConversationResponse creates a SearchConversationResponse from models.ConversationDocument.
+// matches may be nil when the conversation was not found by keyword
+func NewSearchConversationResponse(conversationDoc *models.ConversationDocument, matches *SearchMatches) *SearchConversationResponse {
+	if matches == nil {
+		matches = &SearchMatches{}
 	}
+	matchedMessages := matches.Messages[conversationDoc.ID]
+	matchedFields := matches.Fields[conversationDoc.ID]
 
 	// 转换匹配的消息
 	messageResponses := make([]SearchMessageResponse, len(matchedMessages))
 	for i, msgDoc := range matchedMessages {
-		// 为消息添加匹配字段信息
-		messageMatchedFields := []string{}
-		for _, field := range matchedFields {
-			if field == "messages.content" || field == "messages.source_content" {
-				messageMatchedFields = append(messageMatchedFields, "content")
-			}
+		// 上下文消息不匹配关键词，不标记匹配字段
+		if matches.ContextMessages[msgDoc.ID] {
+			messageResponses[i] = *NewSearchMessageResponse(msgDoc, []string{})
+			messageResponses[i].IsContext = true
+			continue
+		}
+
+		// 为消息添加匹配字段信息，content 和 source_content 分别标记
+		messageMatchedFields := matches.MessageFields[msgDoc.ID]
+		if messageMatchedFields == nil {
+			messageMatchedFields = []string{}
 		}
 		messageResponses[i] = *NewSearchMessageResponse(msgDoc, messageMatchedFields)
+		messageResponses[i].Highlights = matches.MessageHighlights[msgDoc.ID]
+	}
+
+	// 对话字段的高亮片段，消息字段的片段已分配到各消息
+	var highlights map[string][]string
+	for field, fragments := range matches.Highlights[conversationDoc.ID] {
+		if strings.HasPrefix(field, "messages.") {
+			continue
+		}
+		if highlights == nil {
+			highlights = make(map[string][]string)
+		}
+		highlights[field] = fragments
 	}
 
 	// 转换 Tags
@@ -124,9 +170,10 @@ func NewSearchConversationResponse(conversationDoc *models.ConversationDocument,
 	return &SearchConversationResponse{
 		ID:            conversationDoc.ID,
 		UserID:        conversationDoc.UserID,
-		Title:         title,
+		Title:         conversationDoc.Title,
 		Provider:      conversationDoc.Provider,
 		Model:         conversationDoc.Model,
+		ProviderModel: providerModel(conversationDoc.Provider, conversationDoc.Model),
 		SourceID:      conversationDoc.SourceID,
 		SourceTitle:   conversationDoc.SourceTitle,
 		Tags:          tags,
@@ -134,26 +181,16 @@ func NewSearchConversationResponse(conversationDoc *models.ConversationDocument,
 		UpdatedAt:     conversationDoc.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		Messages:      messageResponses,
 		MatchedFields: matchedFields,
+		Highlights:    highlights,
 	}
 }
 
 // NewSearchResponse creates a SearchResponse from a slice of conversation documents
-func NewSearchResponse(query string, conversationDocs []*models.ConversationDocument, matchedMessagesMap map[uuid.UUID][]*models.MessageDocument, matchedFieldsMap map[uuid.UUID][]string) *SearchResponse {
+func NewSearchResponse(query string, conversationDocs []*models.ConversationDocument, matches *SearchMatches) *SearchResponse {
 	conversationResponses := make([]SearchConversationResponse, len(conversationDocs))
 
 	for i, conversationDoc := range conversationDocs {
-		var matchedMessages []*models.MessageDocument
-		var matchedFields []string
-
-		if messages, exists := matchedMessagesMap[conversationDoc.ID]; exists {
-			matchedMessages = messages
-		}
-
-		if fields, exists := matchedFieldsMap[conversationDoc.ID]; exists {
-			matchedFields = fields
-		}
-
-		conversationResponses[i] = *NewSearchConversationResponse(conversationDoc, matchedMessages, matchedFields)
+		conversationResponses[i] = *NewSearchConversationResponse(conversationDoc, matches)
 	}
 
 	return &SearchResponse{
@@ -161,3 +198,28 @@ func NewSearchResponse(query string, conversationDocs []*models.ConversationDocu
 		Conversations: conversationResponses,
 	}
 }
+
+// NewSimilarConversationsResponse creates a SimilarConversationsResponse from conversation documents
+func NewSimilarConversationsResponse(conversationDocs []*models.ConversationDocument) *SimilarConversationsResponse {
+	conversationResponses := make([]SearchConversationResponse, len(conversationDocs))
+	for i, conversationDoc := range conversationDocs {
+		conversationResponses[i] = *NewSearchConversationResponse(conversationDoc, nil)
+	}
+
+	return &SimilarConversationsResponse{
+		Conversations: conversationResponses,
+	}
+}
+
+// SearchCacheStatsResponse represents the hit/miss counters of the search result cache
+type SearchCacheStatsResponse struct {
+	Enabled    bool    `json:"enabled"`
+	Size       int     `json:"size"`
+	TTLSeconds float64 `json:"ttl_seconds"`
+	Entries    int     `json:"entries"`
+	Hits       int64   `json:"hits"`
+	Misses     int64   `json:"misses"`
+	Evictions  int64   `json:"evictions"`
+	// HitRate hits / (hits + misses)，还没有查询时为 0
+	HitRate float64 `json:"hit_rate"`
+}
@@ -10,6 +10,8 @@ type TagResponse struct {
 	ID        uuid.UUID `json:"id"`
 	Name      string    `json:"name"`
 	UpdatedAt string    `json:"updated_at"`
+	// 使用该标签的对话数，仅在按用户列出标签时返回
+	Count int64 `json:"count,omitempty"`
 }
 
 // NewTagResponse creates a TagResponse from models.Tag
@@ -21,6 +23,18 @@ func NewTagResponse(tag *models.Tag) *TagResponse {
 	}
 }
 
+// BatchTagResponse is a tag returned by the batch create endpoint; Created is false
+// when a tag with the normalized name already existed
+type BatchTagResponse struct {
+	TagResponse
+	Created bool `json:"created"`
+}
+
+// BatchTagListResponse represents the result of a batch tag creation, in request order
+type BatchTagListResponse struct {
+	Tags []BatchTagResponse `json:"tags"`
+}
+
 // TagListResponse represents a list of tags in API response
 type TagListResponse struct {
 	Tags []TagResponse `json:"tags"`
@@ -37,3 +51,16 @@ func NewTagListResponse(tags []*models.Tag) *TagListResponse {
 		Tags: tagResponses,
 	}
 }
+
+// NewTagCountListResponse creates a TagListResponse with usage counts, keeping the input order
+func NewTagCountListResponse(counts []*models.TagCount) *TagListResponse {
+	tagResponses := make([]TagResponse, len(counts))
+	for i, count := range counts {
+		tagResponses[i] = *NewTagResponse(&count.Tag)
+		tagResponses[i].Count = count.Count
+	}
+
+	return &TagListResponse{
+		Tags: tagResponses,
+	}
+}
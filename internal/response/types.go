@@ -16,6 +16,8 @@ type ErrorInfo struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
 	Details string `json:"details,omitempty"`
+	// RequestID 与响应头 X-Request-ID 相同，便于客户端反馈问题时对应服务端日志
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // PaginationInfo represents pagination information
@@ -24,12 +26,39 @@ type PaginationInfo struct {
 	Limit      int   `json:"limit"`
 	Total      int64 `json:"total"`
 	TotalPages int   `json:"total_pages"`
+	HasNext    bool  `json:"has_next"`
+	HasPrev    bool  `json:"has_prev"`
+	NextPage   *int  `json:"next_page,omitempty"`
+	PrevPage   *int  `json:"prev_page,omitempty"`
+	// NextCursor is set by cursor-based endpoints instead of page numbers
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// SetNavigation populates the next/prev page fields from page and total pages
+func (p *PaginationInfo) SetNavigation() {
+	p.HasPrev = p.Page > 1
+	p.HasNext = p.Page < p.TotalPages || p.NextCursor != ""
+	p.PrevPage = nil
+	p.NextPage = nil
+
+	if p.HasPrev {
+		prev := p.Page - 1
+		if prev > p.TotalPages && p.TotalPages > 0 {
+			prev = p.TotalPages
+		}
+		p.PrevPage = &prev
+	}
+	if p.Page < p.TotalPages {
+		next := p.Page + 1
+		p.NextPage = &next
+	}
 }
 
 // PaginatedResponse represents a paginated response
 type PaginatedResponse struct {
 	Response
 	Pagination *PaginationInfo `json:"pagination,omitempty"`
+	Meta       interface{}     `json:"meta,omitempty"`
 }
 
 // MetaInfo represents metadata information
@@ -0,0 +1,194 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"chat-assistant-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Options 控制生成的数据量；相同的 Options 总是生成相同的数据（包括 ID 和时间）
+type Options struct {
+	Users                   int
+	ConversationsPerUser    int
+	MessagesPerConversation int
+	Seed                    int64
+}
+
+// Dataset 是生成的开发数据。对话的 Tags 只填充了名称，写入数据库前需要换成已创建的标签
+type Dataset struct {
+	Users         []*models.User
+	Conversations []*models.Conversation
+	TagNames      []string
+}
+
+// epoch 对话时间从这里开始分布在一年内，不依赖当前时间以保证可复现
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// providerModels 与导入器支持的平台一致
+var providerModels = []struct {
+	provider string
+	models   []string
+}{
+	{"chatgpt", []string{"gpt-4o", "gpt-4", "gpt-3.5-turbo", "o1"}},
+	{"claude", []string{"claude-3-5-sonnet", "claude-3-opus", "claude-3-haiku"}},
+	{"gemini", []string{"gemini-1.5-pro", "gemini-1.5-flash"}},
+}
+
+var tagPool = []string{"go", "rust", "python", "database", "elasticsearch", "devops", "frontend", "writing", "学习", "工作", "旅行", "健康"}
+
+var projects = []string{"backend", "mobile-app", "research", "blog"}
+
+// topic 决定对话标题和消息内容的用词，使生成的数据可以按关键词搜索
+type topic struct {
+	title     string
+	questions []string
+	answers   []string
+}
+
+var topics = []topic{
+	{
+		title:     "Go concurrency patterns",
+		questions: []string{"How do I cancel a goroutine with context?", "When should I use a buffered channel?", "Is sync.Map faster than a mutex?"},
+		answers:   []string{"Pass a context.Context and select on ctx.Done() inside the goroutine.", "Buffered channels decouple producers and consumers when bursts are expected.", "sync.Map helps for append-only caches; a plain map with a mutex is usually simpler."},
+	},
+	{
+		title:     "PostgreSQL index tuning",
+		questions: []string{"Why is my query not using the index?", "Should I add a composite index on user_id and created_at?", "How do I find slow queries?"},
+		answers:   []string{"Run EXPLAIN ANALYZE; a type mismatch or low selectivity often prevents index usage.", "A composite index on (user_id, created_at DESC) serves both the filter and the sort.", "Enable pg_stat_statements and sort by total_exec_time."},
+	},
+	{
+		title:     "Elasticsearch relevance",
+		questions: []string{"How do nested queries affect scoring?", "What does min_score do?", "How can I highlight matched messages?"},
+		answers:   []string{"Nested queries score each inner document and combine them with score_mode.", "min_score drops hits whose score is below the threshold before paging.", "Use inner_hits with a highlight block on the nested messages field."},
+	},
+	{
+		title:     "Rust ownership",
+		questions: []string{"Why does the borrow checker reject this loop?", "When should I use Rc versus Arc?", "How do lifetimes work in structs?"},
+		answers:   []string{"The loop holds a mutable borrow while you read from the same vector.", "Use Arc when the value is shared across threads, Rc otherwise.", "A struct holding a reference must declare the lifetime of that reference."},
+	},
+	{
+		title:     "周末旅行计划",
+		questions: []string{"杭州两天怎么安排比较好？", "去西湖需要提前预约吗？", "有什么适合带老人去的景点？"},
+		answers:   []string{"第一天游览西湖和灵隐寺，第二天去西溪湿地，行程比较轻松。", "西湖景区免费开放，部分展馆需要在小程序上预约。", "可以选择乘船游湖，步行少，老人也不会太累。"},
+	},
+	{
+		title:     "学习英语的方法",
+		questions: []string{"每天背单词效果不好怎么办？", "怎样提高听力？", "写作有什么练习方法？"},
+		answers:   []string{"把单词放在句子里记忆，并按间隔重复复习。", "从慢速材料开始精听，逐句听写再对照原文。", "每天写一小段日记，再请别人或工具帮忙修改。"},
+	},
+}
+
+// Generate 生成开发用的用户、对话和消息。所有随机性都来自 opts.Seed
+func Generate(opts Options) *Dataset {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	dataset := &Dataset{}
+	usedTags := make(map[string]bool)
+
+	for u := 0; u < opts.Users; u++ {
+		user := &models.User{
+			Base:     models.Base{ID: newUUID(rng), CreatedAt: epoch},
+			Username: fmt.Sprintf("seed%d_user%d", opts.Seed, u+1),
+		}
+		user.UpdatedAt = user.CreatedAt
+		dataset.Users = append(dataset.Users, user)
+
+		for c := 0; c < opts.ConversationsPerUser; c++ {
+			conversation := newConversation(rng, user.ID, opts, u, c)
+			for _, tag := range conversation.Tags {
+				usedTags[tag.Name] = true
+			}
+			dataset.Conversations = append(dataset.Conversations, conversation)
+		}
+	}
+
+	// 按 tagPool 的顺序输出，保证结果稳定
+	for _, name := range tagPool {
+		if usedTags[name] {
+			dataset.TagNames = append(dataset.TagNames, name)
+		}
+	}
+	return dataset
+}
+
+func newConversation(rng *rand.Rand, userID uuid.UUID, opts Options, userIndex, conversationIndex int) *models.Conversation {
+	pm := providerModels[rng.Intn(len(providerModels))]
+	t := topics[rng.Intn(len(topics))]
+	createdAt := epoch.Add(time.Duration(rng.Int63n(int64(365 * 24 * time.Hour)))).Truncate(time.Second)
+
+	conversation := &models.Conversation{
+		Base:        models.Base{ID: newUUID(rng), CreatedAt: createdAt, UpdatedAt: createdAt},
+		UserID:      userID,
+		Title:       t.title,
+		Provider:    pm.provider,
+		Model:       pm.models[rng.Intn(len(pm.models))],
+		SourceID:    fmt.Sprintf("seed-%d-%d-%d", opts.Seed, userIndex+1, conversationIndex+1),
+		SourceTitle: t.title,
+	}
+
+	// 约一半的对话带有元信息，Claude 对话带摘要
+	if rng.Intn(2) == 0 {
+		meta := &models.ConversationMetadata{Project: projects[rng.Intn(len(projects))]}
+		if pm.provider == "claude" {
+			meta.Summary = "Discussion about " + t.title
+		}
+		conversation.SetMetadata(meta)
+	}
+
+	for _, i := range rng.Perm(len(tagPool))[:rng.Intn(4)] {
+		conversation.Tags = append(conversation.Tags, models.Tag{Name: tagPool[i]})
+	}
+
+	at := createdAt
+	for m := 0; m < opts.MessagesPerConversation; m++ {
+		at = at.Add(time.Duration(1+rng.Intn(10)) * time.Minute)
+		role, content := "user", t.questions[rng.Intn(len(t.questions))]
+		if m%2 == 1 {
+			role, content = "assistant", t.answers[rng.Intn(len(t.answers))]
+		}
+		conversation.Messages = append(conversation.Messages, models.Message{
+			Base:          models.Base{ID: newUUID(rng), CreatedAt: at, UpdatedAt: at},
+			Role:          role,
+			Content:       content,
+			SourceID:      fmt.Sprintf("%s-%d", conversation.SourceID, m+1),
+			SourceContent: content,
+			Sequence:      m + 1,
+		})
+	}
+
+	if len(conversation.Messages) > 0 {
+		last := at
+		conversation.LastMessageAt = &last
+		conversation.UpdatedAt = at
+	}
+	return conversation
+}
+
+// newUUID 从 rng 生成 v4 UUID，使 ID 也可以复现
+func newUUID(rng *rand.Rand) uuid.UUID {
+	var id uuid.UUID
+	rng.Read(id[:])
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+	return id
+}
+
+// IsLocalHost reports whether a database host is the local machine: localhost, a loopback
+// address, a unix socket directory, or the postgres service of docker-compose.yaml
+func IsLocalHost(host string) bool {
+	host = strings.TrimSpace(strings.ToLower(host))
+	switch host {
+	case "localhost", "postgres", "host.docker.internal":
+		return true
+	}
+	if strings.HasPrefix(host, "/") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
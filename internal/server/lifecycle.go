@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Component is a background worker whose lifecycle follows the server,
+// e.g. the job manager. Start is called before the server accepts requests;
+// Stop is called after in-flight requests are drained and should return once
+// pending work is flushed or ctx is done
+type Component interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Register adds a component to the server lifecycle. Components are started in
+// registration order and stopped in reverse order. Must be called before Start
+func (s *Server) Register(component Component) {
+	s.components = append(s.components, component)
+}
+
+// startComponents starts all registered components, stopping the already started
+// ones if any of them fails
+func (s *Server) startComponents(ctx context.Context) error {
+	for i, component := range s.components {
+		if err := component.Start(ctx); err != nil {
+			s.stopComponents(ctx, s.components[:i])
+			return fmt.Errorf("failed to start component %T: %w", component, err)
+		}
+	}
+	s.mu.Lock()
+	s.started = s.components
+	s.mu.Unlock()
+	return nil
+}
+
+// stopComponents stops components in reverse order. Every component is stopped
+// even if an earlier one fails or ctx expires; the errors are joined
+func (s *Server) stopComponents(ctx context.Context, components []Component) error {
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		component := components[i]
+		if err := component.Stop(ctx); err != nil {
+			s.logger.Error("Failed to stop component",
+				zap.String("component", fmt.Sprintf("%T", component)),
+				zap.Error(err),
+			)
+			errs = append(errs, fmt.Errorf("failed to stop component %T: %w", component, err))
+		}
+	}
+	return errors.Join(errs...)
+}
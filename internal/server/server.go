@@ -2,8 +2,10 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -25,10 +27,19 @@ type Server struct {
 	router *gin.Engine
 	server *http.Server
 	logger *zap.Logger
+
+	// components 随服务器启动和停止的后台组件，started 为已成功启动的部分
+	components []Component
+	mu         sync.Mutex
+	started    []Component
 }
 
-// Start starts the HTTP server
+// Start starts the registered components and then the HTTP server
 func (s *Server) Start() error {
+	if err := s.startComponents(context.Background()); err != nil {
+		return err
+	}
+
 	s.logger.Info("Starting HTTP server",
 		zap.String("addr", s.server.Addr),
 		zap.String("mode", gin.Mode()),
@@ -37,12 +48,30 @@ func (s *Server) Start() error {
 	return s.server.ListenAndServe()
 }
 
-// Stop gracefully stops the HTTP server
+// Stop gracefully stops the HTTP server, waiting for in-flight requests, and then
+// stops the registered components. When ctx has no deadline, shutdown.timeout is used
 func (s *Server) Stop(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); !ok && s.config.Shutdown.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Shutdown.Timeout)
+		defer cancel()
+	}
+
 	s.logger.Info("Stopping HTTP server...")
 
-	if err := s.server.Shutdown(ctx); err != nil {
-		s.logger.Error("Failed to shutdown server", zap.Error(err))
+	// 先停止接收新请求并等待处理中的请求结束，再停止后台组件，避免请求产生的任务丢失
+	shutdownErr := s.server.Shutdown(ctx)
+	if shutdownErr != nil {
+		s.logger.Error("Failed to shutdown server", zap.Error(shutdownErr))
+	}
+
+	s.mu.Lock()
+	started := s.started
+	s.started = nil
+	s.mu.Unlock()
+	componentsErr := s.stopComponents(ctx, started)
+
+	if err := errors.Join(shutdownErr, componentsErr); err != nil {
 		return err
 	}
 
@@ -55,8 +84,21 @@ func (s *Server) GetRouter() *gin.Engine {
 	return s.router
 }
 
+// ConfigureTrustedProxies sets the proxies whose forwarding headers are honored
+// when resolving the client IP. An empty list trusts no proxy
+func ConfigureTrustedProxies(router *gin.Engine, trustedProxies []string) error {
+	// 依次检查 X-Forwarded-For 和 X-Real-IP，仅当直连地址是受信任代理时生效
+	router.ForwardedByClientIP = true
+	router.RemoteIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+
+	if len(trustedProxies) == 0 {
+		return router.SetTrustedProxies(nil)
+	}
+	return router.SetTrustedProxies(trustedProxies)
+}
+
 // New creates a new server instance with pre-initialized dependencies
-func New(cfg *config.Config, db *gorm.DB, userHandler *handlers.UserHandler, conversationHandler *handlers.ConversationHandler, messageHandler *handlers.MessageHandler, tagHandler *handlers.TagHandler, searchHandler *handlers.SearchHandler) *Server {
+func New(cfg *config.Config, db *gorm.DB, userHandler *handlers.UserHandler, conversationHandler *handlers.ConversationHandler, messageHandler *handlers.MessageHandler, tagHandler *handlers.TagHandler, searchHandler *handlers.SearchHandler, adminHandler *handlers.AdminHandler) *Server {
 	// Set Gin mode
 	if cfg.Logging.Level == "debug" {
 		gin.SetMode(gin.DebugMode)
@@ -66,11 +108,21 @@ func New(cfg *config.Config, db *gorm.DB, userHandler *handlers.UserHandler, con
 
 	router := gin.New()
 
+	// 配置受信任代理，保证 c.ClientIP() 在负载均衡后返回真实客户端 IP
+	if err := ConfigureTrustedProxies(router, cfg.Server.TrustedProxies); err != nil {
+		logger.GetLogger().Error("Invalid trusted proxies, trusting no proxies",
+			zap.Strings("trusted_proxies", cfg.Server.TrustedProxies),
+			zap.Error(err),
+		)
+		_ = ConfigureTrustedProxies(router, nil)
+	}
+
 	// Add middlewares
 	router.Use(gin.Recovery())
 	router.Use(middleware.RequestIDMiddleware())
 	router.Use(middleware.LoggingMiddleware())
 	router.Use(middleware.CORSMiddleware(cfg.CORS))
+	router.Use(middleware.I18nMiddleware(cfg.I18n))
 
 	// Add health check endpoint
 	router.GET("/health", func(c *gin.Context) {
@@ -85,33 +137,65 @@ func New(cfg *config.Config, db *gorm.DB, userHandler *handlers.UserHandler, con
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// Add API routes
-	api := router.Group("/api/v1")
+	api := router.Group("/api/v1", middleware.BodyLimitMiddleware(cfg.Server.BodyLimit.Default), middleware.RequireJSON())
+	// 创建类接口的请求体很小，使用更低的上限
+	createLimit := middleware.BodyLimitMiddleware(cfg.Server.BodyLimit.Create)
 	{
 		// User routes
 		api.GET("/users/:id", userHandler.GetUser)
+		api.GET("/users/:id/providers", userHandler.GetUserProviders)
+		api.GET("/users/:id/tags", userHandler.GetUserTags)
+		api.GET("/users/:id/recent", conversationHandler.GetRecentConversations)
+		// 没有用户认证，无法校验“本人”，删除全部对话只允许管理员调用
+		api.DELETE("/users/:id/conversations", middleware.AdminAuthMiddleware(cfg.Admin.Token), conversationHandler.DeleteUserConversations)
 
 		// Tag routes
 		api.GET("/tags", tagHandler.GetTags)
 		api.GET("/tags/:id", tagHandler.GetTag)
-		api.POST("/tags", tagHandler.CreateTag)
+		api.GET("/tags/:id/conversations", conversationHandler.GetConversationsByTag)
+		api.POST("/tags", createLimit, tagHandler.CreateTag)
+		api.POST("/tags/batch", createLimit, tagHandler.BatchCreateTags)
 		api.PUT("/tags/:id", tagHandler.UpdateTag)
 		api.DELETE("/tags/:id", tagHandler.DeleteTag)
 
 		// Conversation routes
 		api.GET("/conversations", conversationHandler.GetConversations)
-		api.POST("/conversations", conversationHandler.CreateConversation)
+		api.POST("/conversations", createLimit, conversationHandler.CreateConversation)
+		api.POST("/conversations/merge", conversationHandler.MergeConversations)
+		api.GET("/conversations/by-source", conversationHandler.GetConversationBySource)
 		api.GET("/conversations/:id", conversationHandler.GetConversation)
+		api.PATCH("/conversations/:id", conversationHandler.PatchConversation)
 		api.PUT("/conversations/:id/tags", conversationHandler.UpdateConversationTags)
+		api.GET("/conversations/:id/export", conversationHandler.ExportConversation)
+		api.POST("/conversations/:id/transfer", conversationHandler.TransferConversation)
+		api.POST("/conversations/:id/clone", conversationHandler.CloneConversation)
 		api.DELETE("/conversations/:id", conversationHandler.DeleteConversation)
 		api.GET("/conversations/:id/messages", messageHandler.GetConversationMessages)
 
 		// Message routes
 		api.GET("/messages", messageHandler.GetMessages)
 		api.GET("/messages/:id", messageHandler.GetMessage)
+		api.GET("/messages/:id/context", messageHandler.GetMessageContext)
 		api.DELETE("/messages/:id", messageHandler.DeleteMessage)
 
 		// Search routes
-		api.GET("/search", searchHandler.Search)
+		search := api.Group("/search", middleware.AdminIdentityMiddleware(cfg.Admin.Token))
+		{
+			search.GET("", searchHandler.Search)
+			search.POST("", searchHandler.SearchPost)
+			search.GET("/similar/:conversationId", searchHandler.FindSimilar)
+		}
+
+		// Admin routes
+		admin := api.Group("/admin", middleware.AdminAuthMiddleware(cfg.Admin.Token))
+		{
+			admin.POST("/reindex", adminHandler.StartReindex)
+			admin.GET("/reindex/:jobId", adminHandler.GetReindexJob)
+			admin.GET("/audit-logs", adminHandler.GetAuditLogs)
+			admin.GET("/es/mapping", adminHandler.GetIndexMapping)
+			admin.PUT("/es/mapping", adminHandler.UpdateIndexMapping)
+			admin.GET("/search/cache", adminHandler.GetSearchCacheStats)
+		}
 	}
 
 	server := &http.Server{
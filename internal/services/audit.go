@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"chat-assistant-backend/internal/logger"
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/repositories"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// auditQueueSize 待写入审计日志的缓冲数量，队列满时丢弃新记录而不是阻塞请求
+const auditQueueSize = 1024
+
+// AuditService records destructive operations. Record is asynchronous and never
+// blocks the caller; Start/Stop let the server flush pending records on shutdown
+type AuditService interface {
+	Record(actorUserID *uuid.UUID, action, resourceType string, resourceID uuid.UUID, details map[string]interface{})
+	List(filter repositories.AuditLogFilter, page, limit int) ([]*models.AuditLog, int64, error)
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// AuditServiceImpl writes audit logs from a background worker
+type AuditServiceImpl struct {
+	auditRepo repositories.AuditLogRepository
+	logger    *zap.Logger
+
+	// queue 由 worker 消费，Stop 时关闭；closed 之后的记录直接丢弃
+	queue  chan *models.AuditLog
+	done   chan struct{}
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAuditService creates a new audit service and starts its writer
+func NewAuditService(auditRepo repositories.AuditLogRepository) AuditService {
+	s := &AuditServiceImpl{
+		auditRepo: auditRepo,
+		logger:    logger.GetLogger(),
+		queue:     make(chan *models.AuditLog, auditQueueSize),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Record queues an audit log; details are stored as JSON
+func (s *AuditServiceImpl) Record(actorUserID *uuid.UUID, action, resourceType string, resourceID uuid.UUID, details map[string]interface{}) {
+	log := &models.AuditLog{
+		ActorUserID:  actorUserID,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+	}
+	if len(details) > 0 {
+		data, _ := json.Marshal(details)
+		log.Details = string(data)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		s.dropped(log, "audit service stopped")
+		return
+	}
+
+	select {
+	case s.queue <- log:
+	default:
+		s.dropped(log, "audit queue full")
+	}
+}
+
+// List retrieves audit logs matching filter, newest first
+func (s *AuditServiceImpl) List(filter repositories.AuditLogFilter, page, limit int) ([]*models.AuditLog, int64, error) {
+	return s.auditRepo.List(filter, page, limit)
+}
+
+// Start implements server.Component; the writer is started by NewAuditService
+func (s *AuditServiceImpl) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops accepting records and waits until queued ones are written or ctx is done
+func (s *AuditServiceImpl) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("audit logs still pending at shutdown: %w", ctx.Err())
+	}
+}
+
+// run writes queued audit logs until the queue is closed
+func (s *AuditServiceImpl) run() {
+	defer close(s.done)
+
+	for log := range s.queue {
+		if err := s.auditRepo.Create(log); err != nil {
+			s.logger.Error("Failed to write audit log",
+				zap.String("action", log.Action),
+				zap.String("resource_id", log.ResourceID.String()),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+func (s *AuditServiceImpl) dropped(log *models.AuditLog, reason string) {
+	s.logger.Warn("Audit log dropped",
+		zap.String("reason", reason),
+		zap.String("action", log.Action),
+		zap.String("resource_id", log.ResourceID.String()),
+	)
+}
+
+// recordAudit records an audit log when auditService is configured; command line
+// tools construct services without one
+func recordAudit(auditService AuditService, action, resourceType string, resourceID uuid.UUID, details map[string]interface{}) {
+	if auditService == nil {
+		return
+	}
+	// 接口暂无认证，操作者未知
+	auditService.Record(nil, action, resourceType, resourceID, details)
+}
@@ -1,10 +1,13 @@
 package services
 
 import (
+	"chat-assistant-backend/internal/config"
 	"chat-assistant-backend/internal/errors"
 	"chat-assistant-backend/internal/logger"
 	"chat-assistant-backend/internal/models"
 	"chat-assistant-backend/internal/repositories"
+	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -13,25 +16,51 @@ import (
 // ConversationService defines the interface for conversation service
 type ConversationService interface {
 	GetConversationByID(id uuid.UUID) (*models.Conversation, error)
-	GetConversationsByUserID(userID uuid.UUID, page, limit int) ([]*models.Conversation, int64, error)
-	DeleteConversation(id uuid.UUID) error
-	CreateConversationWithTags(conversation *models.Conversation, tagNames []string) (*models.Conversation, error)
-	UpdateConversationTags(conversationID uuid.UUID, tagNames []string) error
+	GetConversationWithMessages(id uuid.UUID, messageLimit int) (*models.Conversation, int64, error)
+	GetConversationBySourceID(userID uuid.UUID, provider, sourceID string) (*models.Conversation, error)
+	GetConversationsByUserID(userID uuid.UUID, startDate, endDate *time.Time, page, limit int, order string) ([]*models.Conversation, int64, error)
+	GetConversationsByUserIDWithPreview(userID uuid.UUID, startDate, endDate *time.Time, page, limit int, order string) ([]*models.Conversation, map[uuid.UUID]*models.Message, int64, error)
+	GetConversationsByTagID(tagID uuid.UUID, page, limit int) ([]*models.Conversation, int64, error)
+	GetRecentConversations(userID uuid.UUID, limit int) ([]*models.Conversation, map[uuid.UUID]*models.Message, error)
+	DeleteConversation(ctx context.Context, id uuid.UUID) error
+	DeleteAllForUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	CreateConversationWithTags(ctx context.Context, conversation *models.Conversation, tagNames []string) (*models.Conversation, error)
+	UpdateConversationTags(ctx context.Context, conversationID uuid.UUID, tagNames []string) error
+	PatchConversation(ctx context.Context, id uuid.UUID, patch ConversationPatch) (*models.Conversation, error)
+	Transfer(ctx context.Context, id uuid.UUID, targetUserID uuid.UUID) (*models.Conversation, error)
+	Clone(ctx context.Context, id uuid.UUID, targetUserID uuid.UUID) (*models.Conversation, error)
+	Merge(ctx context.Context, targetID uuid.UUID, sourceIDs []uuid.UUID) (*models.Conversation, error)
+	ExportConversation(id uuid.UUID, format string) ([]byte, error)
+}
+
+// ConversationPatch is a partial conversation update; nil fields are left unchanged and
+// tags not listed in AddTags or RemoveTags are kept
+type ConversationPatch struct {
+	Title      *string
+	Model      *string
+	AddTags    []string
+	RemoveTags []string
 }
 
 // ConversationServiceImpl handles conversation business logic
 type ConversationServiceImpl struct {
 	conversationRepo repositories.ConversationRepository
 	tagRepo          repositories.TagRepository
+	userRepo         repositories.UserRepository
 	indexer          repositories.ElasticsearchIndexer
+	auditService     AuditService
+	caseInsensitive  bool
 }
 
 // NewConversationService creates a new conversation service
-func NewConversationService(conversationRepo repositories.ConversationRepository, tagRepo repositories.TagRepository, indexer repositories.ElasticsearchIndexer) ConversationService {
+func NewConversationService(conversationRepo repositories.ConversationRepository, tagRepo repositories.TagRepository, userRepo repositories.UserRepository, indexer repositories.ElasticsearchIndexer, auditService AuditService, cfg *config.Config) ConversationService {
 	return &ConversationServiceImpl{
 		conversationRepo: conversationRepo,
 		tagRepo:          tagRepo,
+		userRepo:         userRepo,
 		indexer:          indexer,
+		auditService:     auditService,
+		caseInsensitive:  cfg.Tags.CaseInsensitive,
 	}
 }
 
@@ -49,9 +78,38 @@ func (s *ConversationServiceImpl) GetConversationByID(id uuid.UUID) (*models.Con
 	return conversation, nil
 }
 
-// GetConversationsByUserID retrieves conversations by user ID with pagination
-func (s *ConversationServiceImpl) GetConversationsByUserID(userID uuid.UUID, page, limit int) ([]*models.Conversation, int64, error) {
-	conversations, total, err := s.conversationRepo.GetByUserID(userID, page, limit)
+// GetConversationBySourceID retrieves a user's conversation by the provider and its ID on that provider
+func (s *ConversationServiceImpl) GetConversationBySourceID(userID uuid.UUID, provider, sourceID string) (*models.Conversation, error) {
+	conversation, err := s.conversationRepo.GetBySourceID(userID, provider, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation == nil {
+		return nil, errors.ErrConversationNotFound
+	}
+
+	return conversation, nil
+}
+
+// GetConversationWithMessages retrieves a conversation with its first messages and the total message count
+func (s *ConversationServiceImpl) GetConversationWithMessages(id uuid.UUID, messageLimit int) (*models.Conversation, int64, error) {
+	conversation, total, err := s.conversationRepo.GetByIDWithMessages(id, messageLimit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if conversation == nil {
+		return nil, 0, errors.ErrConversationNotFound
+	}
+
+	return conversation, total, nil
+}
+
+// GetConversationsByUserID retrieves conversations by user ID with pagination, optionally
+// limited to a date range (inclusive, nil for no bound)
+func (s *ConversationServiceImpl) GetConversationsByUserID(userID uuid.UUID, startDate, endDate *time.Time, page, limit int, order string) ([]*models.Conversation, int64, error) {
+	conversations, total, err := s.conversationRepo.GetByUserIDInRange(userID, startDate, endDate, page, limit, order)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -59,8 +117,42 @@ func (s *ConversationServiceImpl) GetConversationsByUserID(userID uuid.UUID, pag
 	return conversations, total, nil
 }
 
+// GetConversationsByUserIDWithPreview retrieves conversations by user ID with pagination and last message previews
+func (s *ConversationServiceImpl) GetConversationsByUserIDWithPreview(userID uuid.UUID, startDate, endDate *time.Time, page, limit int, order string) ([]*models.Conversation, map[uuid.UUID]*models.Message, int64, error) {
+	return s.conversationRepo.GetByUserIDInRangeWithPreview(userID, startDate, endDate, page, limit, order)
+}
+
+// GetRecentConversations retrieves a user's most recently active conversations with last
+// message previews
+func (s *ConversationServiceImpl) GetRecentConversations(userID uuid.UUID, limit int) ([]*models.Conversation, map[uuid.UUID]*models.Message, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if user == nil {
+		return nil, nil, errors.ErrUserNotFound
+	}
+
+	return s.conversationRepo.GetRecentByUserID(userID, limit)
+}
+
+// GetConversationsByTagID retrieves the conversations carrying a tag with pagination
+func (s *ConversationServiceImpl) GetConversationsByTagID(tagID uuid.UUID, page, limit int) ([]*models.Conversation, int64, error) {
+	tag, err := s.tagRepo.GetByID(tagID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if tag == nil {
+		return nil, 0, errors.ErrTagNotFound
+	}
+
+	return s.conversationRepo.GetByTagID(tagID, page, limit)
+}
+
 // DeleteConversation deletes a conversation by ID
-func (s *ConversationServiceImpl) DeleteConversation(id uuid.UUID) error {
+func (s *ConversationServiceImpl) DeleteConversation(ctx context.Context, id uuid.UUID) error {
 	// First check if conversation exists
 	conversation, err := s.conversationRepo.GetByID(id)
 	if err != nil {
@@ -80,17 +172,65 @@ func (s *ConversationServiceImpl) DeleteConversation(id uuid.UUID) error {
 	if err := s.indexer.DeleteConversation(id); err != nil {
 		// Log the error but don't fail the operation
 		// ES is used for search, so we can tolerate temporary inconsistency
-		logger.GetLogger().Error("Failed to delete conversation from Elasticsearch",
+		logger.FromContext(ctx).Error("Failed to delete conversation from Elasticsearch",
 			zap.String("conversation_id", id.String()),
 			zap.Error(err),
 		)
 	}
 
+	recordAudit(s.auditService, models.AuditActionConversationDelete, models.AuditResourceConversation, id, map[string]interface{}{
+		"user_id": conversation.UserID.String(),
+		"title":   conversation.Title,
+	})
+
 	return nil
 }
 
+// deleteAllForUserBatchSize 删除用户全部对话时每个事务删除的对话数
+const deleteAllForUserBatchSize = 500
+
+// DeleteAllForUser permanently deletes all conversations and messages of a user from
+// PostgreSQL and Elasticsearch and returns how many conversations were deleted
+func (s *ConversationServiceImpl) DeleteAllForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if user == nil {
+		return 0, errors.ErrUserNotFound
+	}
+
+	deleted, err := s.conversationRepo.DeleteByUserID(userID, deleteAllForUserBatchSize)
+	if err != nil {
+		return deleted, err
+	}
+
+	// 按 user_id 删除，包括数据库中已不存在但仍留在索引中的文档
+	indexed, err := s.indexer.DeleteUserConversationsExcept(userID, nil)
+	if err != nil {
+		// 与删除单个对话一致，索引残留可以通过 data-sync -user-id 清理
+		logger.FromContext(ctx).Error("Failed to delete user conversations from Elasticsearch",
+			zap.String("user_id", userID.String()),
+			zap.Error(err),
+		)
+	}
+
+	logger.FromContext(ctx).Info("Deleted all conversations of user",
+		zap.String("user_id", userID.String()),
+		zap.Int64("conversations", deleted),
+		zap.Int64("documents", indexed),
+	)
+	recordAudit(s.auditService, models.AuditActionUserConversationsDelete, models.AuditResourceUser, userID, map[string]interface{}{
+		"username":      user.Username,
+		"conversations": deleted,
+	})
+
+	return deleted, nil
+}
+
 // CreateConversationWithTags creates a new conversation with tags
-func (s *ConversationServiceImpl) CreateConversationWithTags(conversation *models.Conversation, tagNames []string) (*models.Conversation, error) {
+func (s *ConversationServiceImpl) CreateConversationWithTags(ctx context.Context, conversation *models.Conversation, tagNames []string) (*models.Conversation, error) {
 	// 创建对话
 	err := s.conversationRepo.Create(conversation)
 	if err != nil {
@@ -98,6 +238,7 @@ func (s *ConversationServiceImpl) CreateConversationWithTags(conversation *model
 	}
 
 	// 处理标签
+	tagNames = NormalizeTagNames(tagNames, s.caseInsensitive)
 	if len(tagNames) > 0 {
 		tags, err := s.tagRepo.CreateOrGetTags(tagNames)
 		if err != nil {
@@ -126,7 +267,7 @@ func (s *ConversationServiceImpl) CreateConversationWithTags(conversation *model
 	if err := s.indexer.IndexConversation(createdConversation.ToESDocument()); err != nil {
 		// Log the error but don't fail the operation
 		// ES is used for search, so we can tolerate temporary inconsistency
-		logger.GetLogger().Error("Failed to index conversation to Elasticsearch",
+		logger.FromContext(ctx).Error("Failed to index conversation to Elasticsearch",
 			zap.String("conversation_id", conversation.ID.String()),
 			zap.Error(err),
 		)
@@ -136,7 +277,7 @@ func (s *ConversationServiceImpl) CreateConversationWithTags(conversation *model
 }
 
 // UpdateConversationTags updates tags for a conversation
-func (s *ConversationServiceImpl) UpdateConversationTags(conversationID uuid.UUID, tagNames []string) error {
+func (s *ConversationServiceImpl) UpdateConversationTags(ctx context.Context, conversationID uuid.UUID, tagNames []string) error {
 	// 检查对话是否存在
 	conversation, err := s.conversationRepo.GetByID(conversationID)
 	if err != nil {
@@ -149,6 +290,7 @@ func (s *ConversationServiceImpl) UpdateConversationTags(conversationID uuid.UUI
 
 	// 处理标签
 	var tagIDs []string
+	tagNames = NormalizeTagNames(tagNames, s.caseInsensitive)
 	if len(tagNames) > 0 {
 		tags, err := s.tagRepo.CreateOrGetTags(tagNames)
 		if err != nil {
@@ -176,7 +318,7 @@ func (s *ConversationServiceImpl) UpdateConversationTags(conversationID uuid.UUI
 	if err := s.indexer.UpdateConversation(updatedConversation.ToESDocument()); err != nil {
 		// Log the error but don't fail the operation
 		// ES is used for search, so we can tolerate temporary inconsistency
-		logger.GetLogger().Error("Failed to update conversation in Elasticsearch",
+		logger.FromContext(ctx).Error("Failed to update conversation in Elasticsearch",
 			zap.String("conversation_id", conversationID.String()),
 			zap.Error(err),
 		)
@@ -184,3 +326,291 @@ func (s *ConversationServiceImpl) UpdateConversationTags(conversationID uuid.UUI
 
 	return nil
 }
+
+// PatchConversation applies a partial update and re-indexes the conversation once.
+// 增删单个标签不需要先读出全部标签再整体替换，避免并发修改时互相覆盖
+func (s *ConversationServiceImpl) PatchConversation(ctx context.Context, id uuid.UUID, patch ConversationPatch) (*models.Conversation, error) {
+	conversation, err := s.conversationRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation == nil {
+		return nil, errors.ErrConversationNotFound
+	}
+
+	addNames := NormalizeTagNames(patch.AddTags, s.caseInsensitive)
+	removeNames := NormalizeTagNames(patch.RemoveTags, s.caseInsensitive)
+	adding := make(map[string]bool, len(addNames))
+	for _, name := range addNames {
+		adding[name] = true
+	}
+	for _, name := range removeNames {
+		if adding[name] {
+			return nil, errors.ErrTagPatchConflict
+		}
+	}
+
+	updates := make(map[string]interface{})
+	if patch.Title != nil {
+		updates["title"] = *patch.Title
+	}
+	if patch.Model != nil {
+		updates["model"] = *patch.Model
+	}
+
+	var addTagIDs []string
+	if len(addNames) > 0 {
+		tags, err := s.tagRepo.CreateOrGetTags(addNames)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			addTagIDs = append(addTagIDs, tag.ID.String())
+		}
+	}
+
+	// 不存在的标签本来就不在对话上，忽略即可
+	var removeTagIDs []string
+	if len(removeNames) > 0 {
+		tags, err := s.tagRepo.GetByNames(removeNames)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			removeTagIDs = append(removeTagIDs, tag.ID.String())
+		}
+	}
+
+	if err := s.conversationRepo.Patch(id, updates, addTagIDs, removeTagIDs); err != nil {
+		return nil, err
+	}
+
+	updatedConversation, err := s.conversationRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 在 GetByID 与更新之间被删除
+	if updatedConversation == nil {
+		return nil, errors.ErrConversationNotFound
+	}
+
+	// 更新 Elasticsearch 中的对话文档
+	if err := s.indexer.UpdateConversation(updatedConversation.ToESDocument()); err != nil {
+		// Log the error but don't fail the operation
+		// ES is used for search, so we can tolerate temporary inconsistency
+		logger.FromContext(ctx).Error("Failed to update conversation in Elasticsearch",
+			zap.String("conversation_id", id.String()),
+			zap.Error(err),
+		)
+	}
+
+	return updatedConversation, nil
+}
+
+// Transfer moves a conversation to another user and re-indexes it so search ownership follows
+func (s *ConversationServiceImpl) Transfer(ctx context.Context, id uuid.UUID, targetUserID uuid.UUID) (*models.Conversation, error) {
+	conversation, err := s.conversationRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation == nil {
+		return nil, errors.ErrConversationNotFound
+	}
+
+	// 检查目标用户是否存在
+	targetUser, err := s.userRepo.GetByID(targetUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetUser == nil {
+		return nil, errors.ErrUserNotFound
+	}
+
+	// 目标用户已有相同 source_id 的对话（包括软删除的）时会违反 uk_conversations_user_source
+	conflict, err := s.conversationRepo.SourceIDExists(targetUserID, conversation.SourceID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if conflict {
+		return nil, errors.ErrSourceIDConflict
+	}
+
+	// messages 通过 conversation_id 关联，只需更新 conversation 的 user_id
+	updated, err := s.conversationRepo.UpdateUserID(id, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 在 GetByID 与更新之间被删除
+	if !updated {
+		return nil, errors.ErrConversationNotFound
+	}
+
+	updatedConversation, err := s.conversationRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if updatedConversation == nil {
+		return nil, errors.ErrConversationNotFound
+	}
+
+	// 更新 Elasticsearch 中的 user_id，保证搜索结果归属正确
+	if err := s.indexer.UpdateConversation(updatedConversation.ToESDocument()); err != nil {
+		// Log the error but don't fail the operation
+		// ES is used for search, so we can tolerate temporary inconsistency
+		logger.FromContext(ctx).Error("Failed to update conversation in Elasticsearch",
+			zap.String("conversation_id", id.String()),
+			zap.Error(err),
+		)
+	}
+
+	return updatedConversation, nil
+}
+
+// Clone copies a conversation with all its messages to targetUserID (uuid.Nil keeps the
+// original owner) and indexes the copy
+func (s *ConversationServiceImpl) Clone(ctx context.Context, id uuid.UUID, targetUserID uuid.UUID) (*models.Conversation, error) {
+	conversation, err := s.conversationRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation == nil {
+		return nil, errors.ErrConversationNotFound
+	}
+
+	if targetUserID == uuid.Nil {
+		targetUserID = conversation.UserID
+	} else {
+		// 检查目标用户是否存在
+		targetUser, err := s.userRepo.GetByID(targetUserID)
+		if err != nil {
+			return nil, err
+		}
+
+		if targetUser == nil {
+			return nil, errors.ErrUserNotFound
+		}
+	}
+
+	clone, err := s.conversationRepo.Clone(id, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 在 GetByID 与事务之间被删除
+	if clone == nil {
+		return nil, errors.ErrConversationNotFound
+	}
+
+	// 重新获取克隆的对话（包含消息和标签）用于索引
+	clones, err := s.conversationRepo.FindByIDs([]uuid.UUID{clone.ID})
+	if err != nil {
+		return nil, err
+	}
+	if len(clones) == 0 {
+		return nil, errors.ErrConversationNotFound
+	}
+	clone = clones[0]
+
+	// 索引到 Elasticsearch
+	if err := s.indexer.IndexConversation(clone.ToESDocument()); err != nil {
+		// Log the error but don't fail the operation
+		// ES is used for search, so we can tolerate temporary inconsistency
+		logger.FromContext(ctx).Error("Failed to index conversation to Elasticsearch",
+			zap.String("conversation_id", clone.ID.String()),
+			zap.Error(err),
+		)
+	}
+
+	return clone, nil
+}
+
+// Merge moves all messages and tags of the source conversations into the target, deletes
+// the sources and re-indexes the target. All conversations must belong to the same user
+func (s *ConversationServiceImpl) Merge(ctx context.Context, targetID uuid.UUID, sourceIDs []uuid.UUID) (*models.Conversation, error) {
+	// 去重并保持顺序，消息时间相同时按该顺序排列
+	seen := map[uuid.UUID]bool{targetID: true}
+	var sources []uuid.UUID
+	for _, id := range sourceIDs {
+		if id == targetID {
+			return nil, errors.ErrInvalidMerge
+		}
+		if !seen[id] {
+			seen[id] = true
+			sources = append(sources, id)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, errors.ErrInvalidMerge
+	}
+
+	target, err := s.conversationRepo.GetByID(targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if target == nil {
+		return nil, errors.ErrConversationNotFound
+	}
+
+	for _, id := range sources {
+		source, err := s.conversationRepo.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if source == nil {
+			return nil, errors.ErrConversationNotFound
+		}
+
+		if source.UserID != target.UserID {
+			return nil, errors.ErrConversationOwnerMismatch
+		}
+	}
+
+	merged, err := s.conversationRepo.Merge(targetID, sources, target.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 在检查与事务之间被删除或转移
+	if merged == nil {
+		return nil, errors.ErrConversationNotFound
+	}
+
+	// 目标文档整体重建，消息顺序与数据库一致
+	if err := s.indexer.IndexConversation(merged.ToESDocument()); err != nil {
+		// Log the error but don't fail the operation
+		// ES is used for search, so we can tolerate temporary inconsistency
+		logger.FromContext(ctx).Error("Failed to index conversation to Elasticsearch",
+			zap.String("conversation_id", targetID.String()),
+			zap.Error(err),
+		)
+	}
+
+	sourceIDStrings := make([]string, len(sources))
+	for i, id := range sources {
+		sourceIDStrings[i] = id.String()
+		if err := s.indexer.DeleteConversation(id); err != nil {
+			logger.FromContext(ctx).Error("Failed to delete conversation from Elasticsearch",
+				zap.String("conversation_id", id.String()),
+				zap.Error(err),
+			)
+		}
+	}
+
+	recordAudit(s.auditService, models.AuditActionConversationMerge, models.AuditResourceConversation, targetID, map[string]interface{}{
+		"user_id":    target.UserID.String(),
+		"source_ids": sourceIDStrings,
+		"messages":   len(merged.Messages),
+	})
+
+	return merged, nil
+}
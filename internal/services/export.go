@@ -0,0 +1,170 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Conversation export formats
+const (
+	ExportFormatJSON     = "json"
+	ExportFormatMarkdown = "markdown"
+)
+
+// ConversationExport is the JSON export of a conversation: everything needed to archive
+// it, including tags and parsed metadata, with messages in chronological order
+type ConversationExport struct {
+	ID            uuid.UUID                    `json:"id"`
+	UserID        uuid.UUID                    `json:"user_id"`
+	Title         string                       `json:"title"`
+	Provider      string                       `json:"provider"`
+	Model         string                       `json:"model"`
+	SourceID      string                       `json:"source_id"`
+	SourceTitle   string                       `json:"source_title"`
+	CreatedAt     time.Time                    `json:"created_at"`
+	UpdatedAt     time.Time                    `json:"updated_at"`
+	LastMessageAt *time.Time                   `json:"last_message_at,omitempty"`
+	Tags          []string                     `json:"tags"`
+	Metadata      *models.ConversationMetadata `json:"metadata,omitempty"`
+	Messages      []ExportedMessage            `json:"messages"`
+}
+
+// ExportedMessage is a message in a conversation export
+type ExportedMessage struct {
+	ID        uuid.UUID `json:"id"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportConversation renders a conversation with all its messages, tags and metadata
+// in format (ExportFormatJSON or ExportFormatMarkdown)
+func (s *ConversationServiceImpl) ExportConversation(id uuid.UUID, format string) ([]byte, error) {
+	// FindByIDs 预加载全部消息和标签
+	conversations, err := s.conversationRepo.FindByIDs([]uuid.UUID{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return nil, errors.ErrConversationNotFound
+	}
+
+	export := NewConversationExport(conversations[0])
+	switch format {
+	case ExportFormatJSON:
+		return json.MarshalIndent(export, "", "  ")
+	case ExportFormatMarkdown:
+		return export.Markdown(), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// NewConversationExport builds the export of a conversation with preloaded messages and tags
+func NewConversationExport(conversation *models.Conversation) *ConversationExport {
+	export := &ConversationExport{
+		ID:            conversation.ID,
+		UserID:        conversation.UserID,
+		Title:         conversation.Title,
+		Provider:      conversation.Provider,
+		Model:         conversation.Model,
+		SourceID:      conversation.SourceID,
+		SourceTitle:   conversation.SourceTitle,
+		CreatedAt:     conversation.CreatedAt,
+		UpdatedAt:     conversation.UpdatedAt,
+		LastMessageAt: conversation.LastMessageAt,
+		Tags:          make([]string, 0, len(conversation.Tags)),
+		Metadata:      conversation.GetMetadata(),
+		Messages:      make([]ExportedMessage, 0, len(conversation.Messages)),
+	}
+
+	for _, tag := range conversation.Tags {
+		export.Tags = append(export.Tags, tag.Name)
+	}
+	sort.Strings(export.Tags)
+
+	// 不依赖调用方的加载顺序
+	messages := make([]*models.Message, len(conversation.Messages))
+	for i := range conversation.Messages {
+		messages[i] = &conversation.Messages[i]
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return models.MessageLess(messages[i], messages[j])
+	})
+
+	for _, message := range messages {
+		content := message.Content
+		if content == "" {
+			content = message.SourceContent
+		}
+		export.Messages = append(export.Messages, ExportedMessage{
+			ID:        message.ID,
+			Role:      message.Role,
+			Content:   content,
+			CreatedAt: message.CreatedAt,
+		})
+	}
+
+	return export
+}
+
+// Markdown renders the export as Markdown with a YAML front matter block holding the
+// conversation's fields, tags and metadata
+func (e *ConversationExport) Markdown() []byte {
+	var b strings.Builder
+
+	title := e.Title
+	if title == "" {
+		title = e.SourceTitle
+	}
+
+	// 字符串值用 JSON 编码，JSON 字符串和数组同时是合法的 YAML
+	quote := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "id: %s\n", e.ID)
+	fmt.Fprintf(&b, "title: %s\n", quote(title))
+	fmt.Fprintf(&b, "provider: %s\n", quote(e.Provider))
+	fmt.Fprintf(&b, "model: %s\n", quote(e.Model))
+	fmt.Fprintf(&b, "created_at: %s\n", e.CreatedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "updated_at: %s\n", e.UpdatedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "tags: %s\n", quote(e.Tags))
+	if e.Metadata != nil {
+		fields := e.Metadata.Fields()
+		for _, key := range models.ConversationMetadataKeys {
+			if value, ok := fields[key]; ok {
+				fmt.Fprintf(&b, "%s: %s\n", key, quote(value))
+			}
+		}
+	}
+	b.WriteString("---\n\n")
+
+	fmt.Fprintf(&b, "# %s\n", title)
+	for _, message := range e.Messages {
+		fmt.Fprintf(&b, "\n## %s\n\n", roleHeading(message.Role))
+		fmt.Fprintf(&b, "_%s_\n\n", message.CreatedAt.UTC().Format(time.RFC3339))
+		b.WriteString(strings.TrimRight(message.Content, "\n"))
+		b.WriteString("\n")
+	}
+
+	return []byte(b.String())
+}
+
+// roleHeading 消息角色的标题，如 user -> User
+func roleHeading(role string) string {
+	if role == "" {
+		return "Unknown"
+	}
+	return strings.ToUpper(role[:1]) + role[1:]
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"chat-assistant-backend/internal/errors"
+)
+
+// MappingClient reads and updates index mappings in the search engine
+type MappingClient interface {
+	GetMapping(ctx context.Context, indexName string) (map[string]interface{}, error)
+	PutMapping(ctx context.Context, indexName string, mapping map[string]interface{}) error
+}
+
+// MappingService defines the interface for inspecting and extending the mapping of
+// the conversations index
+type MappingService interface {
+	GetMapping(ctx context.Context) (map[string]interface{}, error)
+	UpdateMapping(ctx context.Context, mapping map[string]interface{}) (map[string]interface{}, error)
+}
+
+// MappingServiceImpl 只允许向映射中添加新字段，修改已有字段需要重建索引
+type MappingServiceImpl struct {
+	client    MappingClient
+	indexName string
+}
+
+// NewMappingService creates a new mapping service for the given index
+func NewMappingService(client MappingClient, indexName string) MappingService {
+	return &MappingServiceImpl{
+		client:    client,
+		indexName: indexName,
+	}
+}
+
+// GetMapping returns the live mapping of the index
+func (s *MappingServiceImpl) GetMapping(ctx context.Context) (map[string]interface{}, error) {
+	return s.client.GetMapping(ctx, s.indexName)
+}
+
+// UpdateMapping applies mapping, in the body format of the Elasticsearch put mapping API,
+// and returns the resulting mapping. Changes to existing fields are rejected with
+// ErrCodeMappingBreakingChange before anything is sent to Elasticsearch
+func (s *MappingServiceImpl) UpdateMapping(ctx context.Context, mapping map[string]interface{}) (map[string]interface{}, error) {
+	if len(mapping) == 0 {
+		return nil, errors.NewAppError(errors.ErrCodeInvalidMapping, "Invalid mapping", http.StatusBadRequest).
+			WithDetails("Mapping must not be empty")
+	}
+
+	current, err := s.client.GetMapping(ctx, s.indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	if changed := BreakingMappingChanges(current, mapping); len(changed) > 0 {
+		return nil, errors.NewAppError(errors.ErrCodeMappingBreakingChange, "Mapping change requires a reindex", http.StatusBadRequest).
+			WithDetails("Existing fields cannot be changed in place: " + strings.Join(changed, ", ") +
+				". Create a new index with the changed mapping and reindex into it")
+	}
+
+	if err := s.client.PutMapping(ctx, s.indexName, mapping); err != nil {
+		return nil, err
+	}
+	return s.client.GetMapping(ctx, s.indexName)
+}
+
+// BreakingMappingChanges returns the sorted paths (e.g. "messages.content.analyzer") of the
+// settings in proposed that differ from current. New entries under "properties" (fields)
+// and "fields" (multi-fields) are additions; any other new or different value changes an
+// existing field and can only be applied by reindexing
+func BreakingMappingChanges(current, proposed map[string]interface{}) []string {
+	var changed []string
+	compareMapping("", current, proposed, false, &changed)
+	sort.Strings(changed)
+	return changed
+}
+
+// compareMapping 递归比较映射，additive 表示当前层级是字段列表（properties 或 fields），可以添加新键
+func compareMapping(path string, current, proposed map[string]interface{}, additive bool, changed *[]string) {
+	for key, proposedValue := range proposed {
+		// properties 和 fields 只是字段列表的容器，不出现在路径中
+		keyPath := path
+		if additive || (key != "properties" && key != "fields") {
+			keyPath = joinMappingPath(path, key)
+		}
+
+		currentValue, exists := current[key]
+		// 已有字段还没有子字段时，可以新增 properties 或 fields
+		if !exists && !additive && (key == "properties" || key == "fields") {
+			currentValue, exists = map[string]interface{}{}, true
+		}
+		if !exists {
+			if !additive {
+				*changed = append(*changed, keyPath)
+			}
+			continue
+		}
+
+		currentMap, currentIsMap := currentValue.(map[string]interface{})
+		proposedMap, proposedIsMap := proposedValue.(map[string]interface{})
+		if currentIsMap && proposedIsMap {
+			compareMapping(keyPath, currentMap, proposedMap, !additive && (key == "properties" || key == "fields"), changed)
+			continue
+		}
+
+		if !reflect.DeepEqual(currentValue, proposedValue) {
+			*changed = append(*changed, keyPath)
+		}
+	}
+}
+
+func joinMappingPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
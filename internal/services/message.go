@@ -1,30 +1,43 @@
 package services
 
 import (
+	"context"
+	"time"
+
 	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/logger"
 	"chat-assistant-backend/internal/models"
 	"chat-assistant-backend/internal/repositories"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 // MessageService defines the interface for message service
 type MessageService interface {
 	GetMessageByID(id uuid.UUID) (*models.Message, error)
-	GetMessagesByConversationID(conversationID uuid.UUID, page, limit int) ([]*models.Message, int64, error)
+	GetMessagesByConversationID(conversationID uuid.UUID, page, limit int, order string) ([]*models.Message, int64, error)
+	GetMessagesBefore(conversationID uuid.UUID, cursor repositories.MessageCursor, limit int) ([]*models.Message, int64, bool, error)
 	GetAllMessages(page, limit int) ([]*models.Message, int64, error)
-	DeleteMessage(id uuid.UUID) error
+	GetMessageContext(id uuid.UUID, before, after int) (*models.MessageContext, error)
+	DeleteMessage(ctx context.Context, id uuid.UUID) error
 }
 
 // MessageServiceImpl handles message business logic
 type MessageServiceImpl struct {
-	messageRepo repositories.MessageRepository
+	messageRepo      repositories.MessageRepository
+	conversationRepo repositories.ConversationRepository
+	indexer          repositories.ElasticsearchIndexer
+	auditService     AuditService
 }
 
 // NewMessageService creates a new message service
-func NewMessageService(messageRepo repositories.MessageRepository) MessageService {
+func NewMessageService(messageRepo repositories.MessageRepository, conversationRepo repositories.ConversationRepository, indexer repositories.ElasticsearchIndexer, auditService AuditService) MessageService {
 	return &MessageServiceImpl{
-		messageRepo: messageRepo,
+		messageRepo:      messageRepo,
+		conversationRepo: conversationRepo,
+		indexer:          indexer,
+		auditService:     auditService,
 	}
 }
 
@@ -43,8 +56,8 @@ func (s *MessageServiceImpl) GetMessageByID(id uuid.UUID) (*models.Message, erro
 }
 
 // GetMessagesByConversationID retrieves messages by conversation ID with pagination
-func (s *MessageServiceImpl) GetMessagesByConversationID(conversationID uuid.UUID, page, limit int) ([]*models.Message, int64, error) {
-	messages, total, err := s.messageRepo.GetByConversationID(conversationID, page, limit)
+func (s *MessageServiceImpl) GetMessagesByConversationID(conversationID uuid.UUID, page, limit int, order string) ([]*models.Message, int64, error) {
+	messages, total, err := s.messageRepo.GetByConversationID(conversationID, page, limit, order)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -52,6 +65,22 @@ func (s *MessageServiceImpl) GetMessagesByConversationID(conversationID uuid.UUI
 	return messages, total, nil
 }
 
+// GetMessagesBefore retrieves up to limit messages older than cursor, newest first.
+// hasMore reports whether even older messages exist
+func (s *MessageServiceImpl) GetMessagesBefore(conversationID uuid.UUID, cursor repositories.MessageCursor, limit int) ([]*models.Message, int64, bool, error) {
+	// 多取一条用于判断是否还有更早的消息
+	messages, total, err := s.messageRepo.GetByConversationIDBefore(conversationID, cursor, limit+1)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	return messages, total, hasMore, nil
+}
+
 // GetAllMessages retrieves all messages with pagination
 func (s *MessageServiceImpl) GetAllMessages(page, limit int) ([]*models.Message, int64, error) {
 	messages, total, err := s.messageRepo.GetAll(page, limit)
@@ -62,8 +91,22 @@ func (s *MessageServiceImpl) GetAllMessages(page, limit int) ([]*models.Message,
 	return messages, total, nil
 }
 
+// GetMessageContext retrieves a message with its surrounding messages
+func (s *MessageServiceImpl) GetMessageContext(id uuid.UUID, before, after int) (*models.MessageContext, error) {
+	messageContext, err := s.messageRepo.GetContext(id, before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	if messageContext == nil {
+		return nil, errors.ErrMessageNotFound
+	}
+
+	return messageContext, nil
+}
+
 // DeleteMessage deletes a message by ID
-func (s *MessageServiceImpl) DeleteMessage(id uuid.UUID) error {
+func (s *MessageServiceImpl) DeleteMessage(ctx context.Context, id uuid.UUID) error {
 	// First check if message exists
 	message, err := s.messageRepo.GetByID(id)
 	if err != nil {
@@ -75,5 +118,79 @@ func (s *MessageServiceImpl) DeleteMessage(id uuid.UUID) error {
 	}
 
 	// Delete the message
-	return s.messageRepo.Delete(id)
+	if err := s.messageRepo.Delete(id); err != nil {
+		return err
+	}
+
+	if err := s.indexer.RemoveMessageFromConversation(message.ConversationID, id); err != nil {
+		// Log the error but don't fail the operation
+		// ES is used for search, so we can tolerate temporary inconsistency
+		logger.FromContext(ctx).Error("Failed to remove message from Elasticsearch",
+			zap.String("conversation_id", message.ConversationID.String()),
+			zap.String("message_id", id.String()),
+			zap.Error(err),
+		)
+	}
+
+	recordAudit(s.auditService, models.AuditActionMessageDelete, models.AuditResourceMessage, id, map[string]interface{}{
+		"conversation_id": message.ConversationID.String(),
+		"role":            message.Role,
+	})
+
+	// 消息已经删除，刷新对话的时间和消息数失败只记录日志，不能让调用方以为删除失败而重试
+	if err := s.touchConversation(ctx, message.ConversationID); err != nil {
+		logger.FromContext(ctx).Error("Failed to touch conversation after deleting message",
+			zap.String("conversation_id", message.ConversationID.String()),
+			zap.String("message_id", id.String()),
+			zap.Error(err),
+		)
+	}
+
+	return nil
+}
+
+// touchConversation bumps the conversation's updated_at and recomputes its last_message_at
+// and indexed message_count after one of its messages changed, so recently-active sorting and updated_at based
+// incremental sync pick it up
+func (s *MessageServiceImpl) touchConversation(ctx context.Context, conversationID uuid.UUID) error {
+	conversation, err := s.conversationRepo.GetByID(conversationID)
+	if err != nil {
+		return err
+	}
+
+	if conversation == nil {
+		return nil
+	}
+
+	lastMessageAt, err := s.conversationRepo.RefreshLastMessageAt(conversationID)
+	if err != nil {
+		return err
+	}
+	conversation.LastMessageAt = lastMessageAt
+
+	// 使用同一个时间更新数据库和索引，避免从只读副本读回旧值
+	now := time.Now()
+	if err := s.conversationRepo.Touch(conversationID, now); err != nil {
+		return err
+	}
+	conversation.UpdatedAt = now
+
+	count, err := s.messageRepo.CountByConversationID(conversationID)
+	if err != nil {
+		return err
+	}
+	doc := conversation.ToESDocument()
+	messageCount := int(count)
+	doc.MessageCount = &messageCount
+
+	if err := s.indexer.UpdateConversation(doc); err != nil {
+		// Log the error but don't fail the operation
+		// ES is used for search, so we can tolerate temporary inconsistency
+		logger.FromContext(ctx).Error("Failed to update conversation in Elasticsearch",
+			zap.String("conversation_id", conversationID.String()),
+			zap.Error(err),
+		)
+	}
+
+	return nil
 }
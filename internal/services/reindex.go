@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/jobs"
+)
+
+// Reindexer rebuilds the search index from the database
+type Reindexer interface {
+	Reindex(ctx context.Context, progress func(processed, total int)) error
+}
+
+// ReindexService defines the interface for reindex service
+type ReindexService interface {
+	StartReindex() (*jobs.Job, error)
+	GetReindexJob(jobID string) (*jobs.Job, error)
+}
+
+// ReindexServiceImpl 通过后台任务执行全量重建索引
+type ReindexServiceImpl struct {
+	reindexer Reindexer
+	jobs      *jobs.Manager
+
+	mu          sync.Mutex
+	activeJobID string
+}
+
+// NewReindexService creates a new reindex service
+func NewReindexService(reindexer Reindexer, jobManager *jobs.Manager) ReindexService {
+	return &ReindexServiceImpl{
+		reindexer: reindexer,
+		jobs:      jobManager,
+	}
+}
+
+// StartReindex launches a reindex in the background and returns the new job
+func (s *ReindexServiceImpl) StartReindex() (*jobs.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 同一时间只允许一个重建任务
+	if s.activeJobID != "" {
+		if job, ok := s.jobs.Get(s.activeJobID); ok && !job.Finished() {
+			return nil, errors.ErrReindexInProgress
+		}
+	}
+
+	jobID := s.jobs.Submit(func(ctx context.Context, job *jobs.Job) error {
+		return s.reindexer.Reindex(ctx, job.SetProgress)
+	})
+	s.activeJobID = jobID
+
+	job, _ := s.jobs.Get(jobID)
+	return job, nil
+}
+
+// GetReindexJob returns the reindex job with the given ID
+func (s *ReindexServiceImpl) GetReindexJob(jobID string) (*jobs.Job, error) {
+	job, ok := s.jobs.Get(jobID)
+	if !ok {
+		return nil, errors.ErrReindexJobNotFound
+	}
+	return job, nil
+}
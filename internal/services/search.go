@@ -1,9 +1,13 @@
 package services
 
 import (
+	"context"
 	"strings"
-	"time"
+	"unicode/utf8"
 
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/models"
 	"chat-assistant-backend/internal/repositories"
 	"chat-assistant-backend/internal/response"
 
@@ -12,32 +16,149 @@ import (
 
 // SearchService defines the interface for search service
 type SearchService interface {
-	SearchWithMatchedMessages(query string, userID *uuid.UUID, providerID *string, tagID *uuid.UUID, startDate, endDate *time.Time, page, limit int) (*response.SearchResponse, int64, error)
+	SearchWithMatchedMessages(ctx context.Context, params repositories.SearchParams) (*response.SearchResponse, int64, *models.SearchMeta, error)
+	FindSimilarConversations(conversationID uuid.UUID, limit int) (*response.SimilarConversationsResponse, error)
 }
 
 // SearchServiceImpl handles search business logic
 type SearchServiceImpl struct {
-	searchRepo repositories.SearchRepository
+	searchRepo       repositories.SearchRepository
+	conversationRepo repositories.ConversationRepository
+	config           *config.Config
+	// cache 搜索结果缓存，为 nil 时不缓存
+	cache *SearchCache
 }
 
-// NewSearchService creates a new search service
-func NewSearchService(searchRepo repositories.SearchRepository) SearchService {
+// NewSearchService creates a new search service; cache may be nil to disable result caching
+func NewSearchService(searchRepo repositories.SearchRepository, conversationRepo repositories.ConversationRepository, cfg *config.Config, cache *SearchCache) SearchService {
 	return &SearchServiceImpl{
-		searchRepo: searchRepo,
+		searchRepo:       searchRepo,
+		conversationRepo: conversationRepo,
+		config:           cfg,
+		cache:            cache,
 	}
 }
 
 // SearchWithMatchedMessages performs a search and returns conversations with matched messages
-func (s *SearchServiceImpl) SearchWithMatchedMessages(query string, userID *uuid.UUID, providerID *string, tagID *uuid.UUID, startDate, endDate *time.Time, page, limit int) (*response.SearchResponse, int64, error) {
+func (s *SearchServiceImpl) SearchWithMatchedMessages(ctx context.Context, params repositories.SearchParams) (*response.SearchResponse, int64, *models.SearchMeta, error) {
+	// 多用户部署中不允许普通调用方跨用户搜索
+	if s.config.Search.RequireUserID && params.UserID == nil && !params.AllUsers {
+		return nil, 0, nil, errors.ErrUserIDRequired
+	}
+
 	// Validate and clean query
-	query = strings.TrimSpace(query)
+	params.Query = strings.TrimSpace(params.Query)
+	params.IncludeContextMessages = s.config.Search.IncludeContextMessages
+	params.SkipPostFilter = !s.config.Search.PostFilter
+	if params.MinScore == nil {
+		minScore := s.config.Search.MinScore
+		params.MinScore = &minScore
+	}
+
+	// 缓存键包含规范化后的全部参数，不同用户、过滤条件和分页互不影响
+	var cacheKey string
+	if s.cache != nil {
+		if key, ok := searchCacheKey(params); ok {
+			cacheKey = key
+			if cached, total, meta, hit := s.cache.Get(cacheKey); hit {
+				return cached, total, cachedMeta(meta), nil
+			}
+		}
+	}
 
 	// Search conversations with matched messages and field information
-	conversationDocs, matchedMessagesMap, matchedFieldsMap, total, err := s.searchRepo.SearchConversationsWithMatchedMessages(query, userID, providerID, tagID, startDate, endDate, page, limit)
+	result, err := s.searchRepo.SearchConversationsWithMatchedMessages(ctx, params)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
 	// Convert to new search response format
-	return response.NewSearchResponse(query, conversationDocs, matchedMessagesMap, matchedFieldsMap), total, nil
+	searchResponse := response.NewSearchResponse(params.Query, result.Documents, &response.SearchMatches{
+		Messages:          result.MatchedMessages,
+		Fields:            result.MatchedFields,
+		Highlights:        result.Highlights,
+		ContextMessages:   result.ContextMessages,
+		MessageFields:     result.MessageMatchedFields,
+		MessageHighlights: result.MessageHighlights,
+	})
+	if window := s.config.Search.SnippetChars; window > 0 {
+		applySnippets(searchResponse, params.Query, window)
+	}
+	// 降级搜索的结果不缓存，ES 恢复后立即返回完整结果
+	if cacheKey != "" && (result.Meta == nil || !result.Meta.Fallback) {
+		s.cache.Set(cacheKey, searchResponse, result.Total, result.Meta)
+	}
+	return searchResponse, result.Total, result.Meta, nil
+}
+
+// cachedMeta 复制缓存中的元信息并标记为缓存命中，不修改缓存的条目
+func cachedMeta(meta *models.SearchMeta) *models.SearchMeta {
+	copied := models.SearchMeta{}
+	if meta != nil {
+		copied = *meta
+	}
+	copied.Cached = true
+	return &copied
+}
+
+// applySnippets 用关键词附近的片段代替消息全文，减小搜索响应体积，完整内容通过消息详情接口获取
+func applySnippets(searchResponse *response.SearchResponse, query string, window int) {
+	for i := range searchResponse.Conversations {
+		messages := searchResponse.Conversations[i].Messages
+		for j := range messages {
+			content := messages[j].Content
+			offset := repositories.FindKeywordOffset(content, query)
+			if offset == -1 && messages[j].SourceContent != "" {
+				// 匹配可能来自原始内容
+				if sourceOffset := repositories.FindKeywordOffset(messages[j].SourceContent, query); sourceOffset != -1 {
+					content, offset = messages[j].SourceContent, sourceOffset
+				}
+			}
+
+			messages[j].Snippet = buildSnippet(content, offset, utf8.RuneCountInString(query), window)
+			messages[j].Content = ""
+			messages[j].SourceContent = ""
+		}
+	}
+}
+
+// buildSnippet 截取匹配位置前后各 window 个字符，被截断的一侧加省略号；
+// offset 为 -1（上下文消息或没有精确匹配）时从开头截取
+func buildSnippet(content string, offset, keywordChars, window int) string {
+	runes := []rune(content)
+	start, end := 0, 2*window
+	if offset >= 0 {
+		start = max(offset-window, 0)
+		end = offset + keywordChars + window
+	}
+	end = min(end, len(runes))
+
+	snippet := string(runes[start:end])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// FindSimilarConversations returns up to limit conversations of the same user that are
+// similar to the given conversation
+func (s *SearchServiceImpl) FindSimilarConversations(conversationID uuid.UUID, limit int) (*response.SimilarConversationsResponse, error) {
+	conversation, err := s.conversationRepo.GetByID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation == nil {
+		return nil, errors.ErrConversationNotFound
+	}
+
+	docs, err := s.searchRepo.FindSimilar(conversationID, &conversation.UserID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.NewSimilarConversationsResponse(docs), nil
 }
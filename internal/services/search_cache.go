@@ -0,0 +1,174 @@
+package services
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/repositories"
+	"chat-assistant-backend/internal/response"
+)
+
+// DefaultSearchCacheSize 未配置 search.cache_size 时缓存的搜索结果数
+const DefaultSearchCacheSize = 1000
+
+// SearchCache 是搜索结果的内存 LRU 缓存，以完整的查询参数（关键词、过滤条件、分页）为键。
+// 写入不会主动失效缓存，结果最多延迟 TTL 才反映新的数据，因此 TTL 应设置得较短
+type SearchCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]*list.Element
+	lru     *list.List // 队首为最近使用的条目
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+// SearchCacheOptions configures SearchCache
+type SearchCacheOptions struct {
+	// Size 最多缓存的结果数，<= 0 时使用 DefaultSearchCacheSize
+	Size int
+	// TTL 结果的有效期
+	TTL time.Duration
+	// Now 返回当前时间，为空时使用 time.Now
+	Now func() time.Time
+}
+
+// SearchCacheStats 缓存命中统计
+type SearchCacheStats struct {
+	Enabled   bool
+	Size      int
+	TTL       time.Duration
+	Entries   int
+	Hits      int64
+	Misses    int64
+	Evictions int64
+}
+
+// searchCacheEntry 缓存的一次搜索结果
+type searchCacheEntry struct {
+	key       string
+	expiresAt time.Time
+	response  *response.SearchResponse
+	total     int64
+	meta      *models.SearchMeta
+}
+
+// NewSearchCache creates a search result cache holding up to size results for ttl
+func NewSearchCache(size int, ttl time.Duration) *SearchCache {
+	return NewSearchCacheWithOptions(SearchCacheOptions{Size: size, TTL: ttl})
+}
+
+// NewSearchCacheWithOptions creates a search result cache with the given options
+func NewSearchCacheWithOptions(opts SearchCacheOptions) *SearchCache {
+	if opts.Size <= 0 {
+		opts.Size = DefaultSearchCacheSize
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &SearchCache{
+		size:    opts.Size,
+		ttl:     opts.TTL,
+		now:     opts.Now,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// NewSearchCacheFromConfig creates the search cache configured by search.cache_ttl and
+// search.cache_size; returns nil (caching disabled) when cache_ttl is 0
+func NewSearchCacheFromConfig(cfg *config.Config) *SearchCache {
+	if cfg.Search.CacheTTL <= 0 {
+		return nil
+	}
+	return NewSearchCache(cfg.Search.CacheSize, cfg.Search.CacheTTL)
+}
+
+// searchCacheKey 由规范化后的查询参数生成缓存键，指针字段按值序列化
+func searchCacheKey(params repositories.SearchParams) (string, bool) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Get returns the cached result for key; expired results count as misses and are removed
+func (c *SearchCache) Get(key string) (*response.SearchResponse, int64, *models.SearchMeta, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, 0, nil, false
+	}
+
+	entry := element.Value.(*searchCacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		c.misses.Add(1)
+		return nil, 0, nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	c.hits.Add(1)
+	return entry.response, entry.total, entry.meta, true
+}
+
+// Set stores a result, evicting the least recently used result when the cache is full
+func (c *SearchCache) Set(key string, searchResponse *response.SearchResponse, total int64, meta *models.SearchMeta) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &searchCacheEntry{
+		key:       key,
+		expiresAt: c.now().Add(c.ttl),
+		response:  searchResponse,
+		total:     total,
+		meta:      meta,
+	}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*searchCacheEntry).key)
+		c.evictions.Add(1)
+	}
+}
+
+// Stats returns the hit/miss counters; a nil cache reports Enabled false
+func (c *SearchCache) Stats() SearchCacheStats {
+	if c == nil {
+		return SearchCacheStats{}
+	}
+
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+
+	return SearchCacheStats{
+		Enabled:   true,
+		Size:      c.size,
+		TTL:       c.ttl,
+		Entries:   entries,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
@@ -2,49 +2,212 @@ package services
 
 import (
 	"fmt"
+	"sort"
 
+	"chat-assistant-backend/internal/logger"
 	"chat-assistant-backend/internal/models"
 	"chat-assistant-backend/internal/repositories"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 // SyncService defines the interface for sync service
 type SyncService interface {
 	SyncAll() error
+	SyncUser(userID uuid.UUID) error
+	Plan() (*SyncPlan, error)
+}
+
+// SyncPlan 比较数据库与 ES 的状态，描述一次全量同步会做的变更
+type SyncPlan struct {
+	ToIndex   []uuid.UUID // ES 中不存在，需要新建的对话
+	ToUpdate  []uuid.UUID // ES 中存在但内容哈希不一致（或未记录哈希），需要重新索引的对话
+	Stale     []uuid.UUID // ES 中存在但数据库中已不存在的文档；SyncAll 不会删除，见 SyncUser
+	Unchanged int         // 内容哈希一致，无需变更的对话数
+}
+
+// defaultSyncBatchSize 每批从数据库读取并写入 ES 的对话数
+const defaultSyncBatchSize = 500
+
+// SyncOptions configures SyncServiceImpl
+type SyncOptions struct {
+	// Force 重新索引所有对话，不跳过内容哈希一致的对话。调整 import.max_indexed_messages
+	// 等只影响索引内容、不影响哈希的配置后使用
+	Force bool
+	// BatchSize 每批读取和索引的对话数，<= 0 时使用 500
+	BatchSize int
 }
 
 // SyncServiceImpl 处理数据同步业务逻辑
 type SyncServiceImpl struct {
 	conversationRepo repositories.ConversationRepository
 	indexer          repositories.ElasticsearchIndexer
+	force            bool
+	batchSize        int
+	logger           *zap.Logger
 }
 
-// NewSyncService 创建同步服务
+// NewSyncService 创建同步服务，跳过内容未变化的对话
 func NewSyncService(conversationRepo repositories.ConversationRepository, indexer repositories.ElasticsearchIndexer) SyncService {
+	return NewSyncServiceWithOptions(conversationRepo, indexer, SyncOptions{})
+}
+
+// NewSyncServiceWithOptions 创建使用指定选项的同步服务
+func NewSyncServiceWithOptions(conversationRepo repositories.ConversationRepository, indexer repositories.ElasticsearchIndexer, opts SyncOptions) SyncService {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultSyncBatchSize
+	}
+
 	return &SyncServiceImpl{
 		conversationRepo: conversationRepo,
 		indexer:          indexer,
+		force:            opts.Force,
+		batchSize:        batchSize,
+		logger:           logger.GetLogger(),
 	}
 }
 
-// SyncAll 同步所有数据到 Elasticsearch
+// SyncAll 同步所有数据到 Elasticsearch。对话按批从数据库读取并逐批写入，内存占用只与批大小有关。
+// 索引中内容哈希与数据库一致的对话会被跳过，读取索引中的哈希失败时（例如索引尚未创建）
+// 退化为重新索引所有对话
 func (s *SyncServiceImpl) SyncAll() error {
-	// 1. 从数据库读取所有 conversations 和 messages
-	conversations, err := s.conversationRepo.FindAll()
+	// 1. 读取索引中已有的内容哈希，用于跳过内容未变化的对话
+	var indexed map[uuid.UUID]string
+	if !s.force {
+		hashes, err := s.indexer.ContentHashes()
+		if err != nil {
+			s.logger.Warn("Failed to get indexed content hashes, reindexing all conversations", zap.Error(err))
+		} else {
+			indexed = hashes
+		}
+	}
+
+	// 2. 分批读取对话，转换为 ES 文档后批量索引
+	var total, indexedCount int
+	err := s.conversationRepo.FindAllStream(s.batchSize, func(conversations []*models.Conversation) error {
+		total += len(conversations)
+
+		docs := s.convertToESDocuments(conversations)
+		if indexed != nil {
+			docs = changedDocuments(docs, indexed)
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+
+		if err := s.indexer.BulkIndexConversations(docs); err != nil {
+			return fmt.Errorf("failed to bulk index conversations: %w", err)
+		}
+		indexedCount += len(docs)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get conversations: %w", err)
+		return fmt.Errorf("failed to sync conversations: %w", err)
 	}
 
-	// 2. 转换为 ES 文档
-	docs := s.convertToESDocuments(conversations)
+	s.logger.Info("Conversations synced",
+		zap.Int("indexed", indexedCount),
+		zap.Int("skipped", total-indexedCount),
+	)
 
-	// 3. 批量索引到 ES
-	if err := s.indexer.BulkIndexConversations(docs); err != nil {
-		return fmt.Errorf("failed to bulk index conversations: %w", err)
+	return nil
+}
+
+// SyncUser 重新索引指定用户的所有对话（不跳过内容哈希一致的对话），然后删除索引中属于该用户
+// 但数据库中已不存在或已不属于该用户的文档。用于修复单个用户的数据后，无需全量同步
+func (s *SyncServiceImpl) SyncUser(userID uuid.UUID) error {
+	var keep []uuid.UUID
+	err := s.conversationRepo.FindByUserIDStream(userID, s.batchSize, func(conversations []*models.Conversation) error {
+		if len(conversations) == 0 {
+			return nil
+		}
+
+		docs := s.convertToESDocuments(conversations)
+		if err := s.indexer.BulkIndexConversations(docs); err != nil {
+			return fmt.Errorf("failed to bulk index conversations: %w", err)
+		}
+		for _, doc := range docs {
+			keep = append(keep, doc.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sync conversations of user %s: %w", userID, err)
 	}
 
+	deleted, err := s.indexer.DeleteUserConversationsExcept(userID, keep)
+	if err != nil {
+		return fmt.Errorf("failed to delete stale conversations of user %s: %w", userID, err)
+	}
+
+	s.logger.Info("User conversations synced",
+		zap.String("user_id", userID.String()),
+		zap.Int("indexed", len(keep)),
+		zap.Int64("deleted", deleted),
+	)
+
 	return nil
 }
 
+// Plan compares the database with the index without writing anything. Conversations
+// missing from the index are new, those whose stored content hash differs (or was
+// never recorded) are updates, and indexed documents with no conversation in the
+// database are stale (reported only, SyncAll leaves them in place)
+func (s *SyncServiceImpl) Plan() (*SyncPlan, error) {
+	indexed, err := s.indexer.ContentHashes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get indexed content hashes: %w", err)
+	}
+
+	plan := &SyncPlan{}
+	seen := make(map[uuid.UUID]bool)
+	err = s.conversationRepo.FindAllStream(s.batchSize, func(conversations []*models.Conversation) error {
+		for _, doc := range s.convertToESDocuments(conversations) {
+			seen[doc.ID] = true
+
+			hash, ok := indexed[doc.ID]
+			switch {
+			case !ok:
+				plan.ToIndex = append(plan.ToIndex, doc.ID)
+			case !s.force && hash == doc.ComputeContentHash():
+				plan.Unchanged++
+			default:
+				plan.ToUpdate = append(plan.ToUpdate, doc.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversations: %w", err)
+	}
+
+	for id := range indexed {
+		if !seen[id] {
+			plan.Stale = append(plan.Stale, id)
+		}
+	}
+	// map 遍历顺序不固定，排序后输出稳定
+	sort.Slice(plan.Stale, func(i, j int) bool {
+		return plan.Stale[i].String() < plan.Stale[j].String()
+	})
+
+	return plan, nil
+}
+
+// changedDocuments 过滤掉索引中已存在且内容哈希一致的文档；未记录哈希的旧文档视为已变化
+func changedDocuments(docs []*models.ConversationDocument, indexed map[uuid.UUID]string) []*models.ConversationDocument {
+	changed := make([]*models.ConversationDocument, 0, len(docs))
+	for _, doc := range docs {
+		if hash, ok := indexed[doc.ID]; ok && hash != "" && hash == doc.ComputeContentHash() {
+			continue
+		}
+		changed = append(changed, doc)
+	}
+	return changed
+}
+
 // convertToESDocuments 转换 conversations 为 ES 文档
 func (s *SyncServiceImpl) convertToESDocuments(conversations []*models.Conversation) []*models.ConversationDocument {
 	docs := make([]*models.ConversationDocument, len(conversations))
@@ -1,11 +1,20 @@
 package services
 
 import (
+	"context"
+	"sort"
+	"strings"
+
+	"chat-assistant-backend/internal/config"
 	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/logger"
 	"chat-assistant-backend/internal/models"
 	"chat-assistant-backend/internal/repositories"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
 )
 
 // TagService defines the interface for tag service
@@ -14,21 +23,74 @@ type TagService interface {
 	GetTagByName(name string) (*models.Tag, error)
 	GetAllTags() ([]*models.Tag, error)
 	CreateTag(name string) (*models.Tag, error)
-	UpdateTag(id uuid.UUID, name string) (*models.Tag, error)
-	DeleteTag(id uuid.UUID) error
+	UpdateTag(ctx context.Context, id uuid.UUID, name string) (*models.Tag, error)
+	DeleteTag(ctx context.Context, id uuid.UUID) error
 	CreateOrGetTags(names []string) ([]*models.Tag, error)
+	BatchCreateTags(names []string) ([]TagBatchResult, error)
+	NormalizeExistingTags(dryRun bool) ([]TagNormalizationChange, error)
+}
+
+// TagBatchResult is a tag returned by BatchCreateTags; Created is false when a tag
+// with the normalized name already existed
+type TagBatchResult struct {
+	Tag     *models.Tag
+	Created bool
+}
+
+// TagNormalizationChange describes how a group of existing tags is normalized
+type TagNormalizationChange struct {
+	TargetID     uuid.UUID   `json:"target_id"`
+	OldNames     []string    `json:"old_names"`
+	NewName      string      `json:"new_name"`
+	DuplicateIDs []uuid.UUID `json:"duplicate_ids"`
 }
 
 // TagServiceImpl handles tag business logic
 type TagServiceImpl struct {
-	tagRepo repositories.TagRepository
+	tagRepo         repositories.TagRepository
+	indexer         repositories.ElasticsearchIndexer
+	auditService    AuditService
+	caseInsensitive bool
 }
 
-// NewTagService creates a new tag service
-func NewTagService(tagRepo repositories.TagRepository) TagService {
+// NewTagService creates a new tag service.
+// indexer may be nil, in which case tag changes are not propagated to Elasticsearch
+func NewTagService(tagRepo repositories.TagRepository, indexer repositories.ElasticsearchIndexer, auditService AuditService, cfg *config.Config) TagService {
 	return &TagServiceImpl{
-		tagRepo: tagRepo,
+		tagRepo:         tagRepo,
+		indexer:         indexer,
+		auditService:    auditService,
+		caseInsensitive: cfg.Tags.CaseInsensitive,
+	}
+}
+
+// NormalizeTagName normalizes a tag name: Unicode NFC, trims surrounding
+// whitespace, collapses inner whitespace and optionally folds case. Case folding
+// uses Unicode full case folding, so names that only differ in case such as
+// "Straße"/"STRASSE" or "ΣΑΣ"/"σας" normalize to the same name
+func NormalizeTagName(name string, caseInsensitive bool) string {
+	name = norm.NFC.String(name)
+	name = strings.Join(strings.Fields(name), " ")
+	if caseInsensitive {
+		// Caser 有状态，不能在 goroutine 之间共享，每次新建
+		name = norm.NFC.String(cases.Fold().String(name))
 	}
+	return name
+}
+
+// NormalizeTagNames normalizes tag names, dropping empty names and duplicates
+func NormalizeTagNames(names []string, caseInsensitive bool) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		normalized := NormalizeTagName(name, caseInsensitive)
+		if normalized == "" || seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		result = append(result, normalized)
+	}
+	return result
 }
 
 // GetTagByID retrieves a tag by ID
@@ -47,7 +109,7 @@ func (s *TagServiceImpl) GetTagByID(id uuid.UUID) (*models.Tag, error) {
 
 // GetTagByName retrieves a tag by name
 func (s *TagServiceImpl) GetTagByName(name string) (*models.Tag, error) {
-	tag, err := s.tagRepo.GetByName(name)
+	tag, err := s.tagRepo.GetByName(NormalizeTagName(name, s.caseInsensitive))
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +128,11 @@ func (s *TagServiceImpl) GetAllTags() ([]*models.Tag, error) {
 
 // CreateTag creates a new tag
 func (s *TagServiceImpl) CreateTag(name string) (*models.Tag, error) {
+	name = NormalizeTagName(name, s.caseInsensitive)
+	if name == "" {
+		return nil, errors.ErrTagNameEmpty
+	}
+
 	// 检查标签是否已存在
 	existingTag, err := s.tagRepo.GetByName(name)
 	if err != nil {
@@ -90,7 +157,12 @@ func (s *TagServiceImpl) CreateTag(name string) (*models.Tag, error) {
 }
 
 // UpdateTag updates an existing tag
-func (s *TagServiceImpl) UpdateTag(id uuid.UUID, name string) (*models.Tag, error) {
+func (s *TagServiceImpl) UpdateTag(ctx context.Context, id uuid.UUID, name string) (*models.Tag, error) {
+	name = NormalizeTagName(name, s.caseInsensitive)
+	if name == "" {
+		return nil, errors.ErrTagNameEmpty
+	}
+
 	// 检查标签是否存在
 	tag, err := s.tagRepo.GetByID(id)
 	if err != nil {
@@ -112,17 +184,36 @@ func (s *TagServiceImpl) UpdateTag(id uuid.UUID, name string) (*models.Tag, erro
 	}
 
 	// 更新标签
+	renamed := tag.Name != name
 	tag.Name = name
 	err = s.tagRepo.Update(tag)
 	if err != nil {
 		return nil, err
 	}
 
+	// ES 文档中冗余存储了标签名称，异步同步新名称。请求结束后 ctx 会被取消，
+	// 只把带 request_id 的 logger 传给后台协程
+	if renamed && s.indexer != nil {
+		go s.propagateTagRename(logger.FromContext(ctx), tag.ID, name)
+	}
+
 	return tag, nil
 }
 
+// propagateTagRename updates the tag name embedded in indexed conversations
+func (s *TagServiceImpl) propagateTagRename(log *zap.Logger, id uuid.UUID, name string) {
+	if err := s.indexer.UpdateTagInConversations(id, name); err != nil {
+		// Log the error but don't fail the operation
+		// ES is used for search, so we can tolerate temporary inconsistency
+		log.Error("Failed to update tag in Elasticsearch",
+			zap.String("tag_id", id.String()),
+			zap.Error(err),
+		)
+	}
+}
+
 // DeleteTag deletes a tag by ID
-func (s *TagServiceImpl) DeleteTag(id uuid.UUID) error {
+func (s *TagServiceImpl) DeleteTag(ctx context.Context, id uuid.UUID) error {
 	// 检查标签是否存在
 	tag, err := s.tagRepo.GetByID(id)
 	if err != nil {
@@ -134,14 +225,132 @@ func (s *TagServiceImpl) DeleteTag(id uuid.UUID) error {
 	}
 
 	// 删除标签
-	return s.tagRepo.Delete(id)
+	if err := s.tagRepo.Delete(id); err != nil {
+		return err
+	}
+
+	// 异步从 ES 文档中移除该标签
+	if s.indexer != nil {
+		go s.propagateTagDeletion(logger.FromContext(ctx), id)
+	}
+
+	recordAudit(s.auditService, models.AuditActionTagDelete, models.AuditResourceTag, id, map[string]interface{}{
+		"name": tag.Name,
+	})
+
+	return nil
+}
+
+// propagateTagDeletion removes the tag from indexed conversations
+func (s *TagServiceImpl) propagateTagDeletion(log *zap.Logger, id uuid.UUID) {
+	if err := s.indexer.RemoveTagFromConversations(id); err != nil {
+		// Log the error but don't fail the operation
+		// ES is used for search, so we can tolerate temporary inconsistency
+		log.Error("Failed to remove tag from Elasticsearch",
+			zap.String("tag_id", id.String()),
+			zap.Error(err),
+		)
+	}
 }
 
 // CreateOrGetTags creates new tags or returns existing ones by names
 func (s *TagServiceImpl) CreateOrGetTags(names []string) ([]*models.Tag, error) {
+	names = NormalizeTagNames(names, s.caseInsensitive)
 	if len(names) == 0 {
 		return []*models.Tag{}, nil
 	}
 
 	return s.tagRepo.CreateOrGetTags(names)
 }
+
+// BatchCreateTags normalizes and dedupes names like CreateOrGetTags, creates the missing
+// tags and returns one result per normalized name in request order. Calling it again
+// with the same names creates nothing
+func (s *TagServiceImpl) BatchCreateTags(names []string) ([]TagBatchResult, error) {
+	names = NormalizeTagNames(names, s.caseInsensitive)
+	if len(names) == 0 {
+		return nil, errors.ErrTagNameEmpty
+	}
+
+	// 先查出已存在的标签，用于区分新建和已有
+	existing, err := s.tagRepo.GetByNames(names)
+	if err != nil {
+		return nil, err
+	}
+	existed := make(map[string]bool, len(existing))
+	for _, tag := range existing {
+		existed[tag.Name] = true
+	}
+
+	tags, err := s.CreateOrGetTags(names)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*models.Tag, len(tags))
+	for _, tag := range tags {
+		byName[tag.Name] = tag
+	}
+
+	results := make([]TagBatchResult, 0, len(names))
+	for _, name := range names {
+		if tag, ok := byName[name]; ok {
+			results = append(results, TagBatchResult{Tag: tag, Created: !existed[name]})
+		}
+	}
+	return results, nil
+}
+
+// NormalizeExistingTags normalizes stored tag names and merges tags that
+// collapse to the same normalized name into the oldest one
+func (s *TagServiceImpl) NormalizeExistingTags(dryRun bool) ([]TagNormalizationChange, error) {
+	tags, err := s.tagRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// 按规范化后的名称分组
+	groups := make(map[string][]*models.Tag)
+	var order []string
+	for _, tag := range tags {
+		normalized := NormalizeTagName(tag.Name, s.caseInsensitive)
+		if _, exists := groups[normalized]; !exists {
+			order = append(order, normalized)
+		}
+		groups[normalized] = append(groups[normalized], tag)
+	}
+
+	var changes []TagNormalizationChange
+	for _, name := range order {
+		group := groups[name]
+		if name == "" || (len(group) == 1 && group[0].Name == name) {
+			continue
+		}
+
+		// 保留最早创建的标签
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].CreatedAt.Before(group[j].CreatedAt)
+		})
+
+		change := TagNormalizationChange{
+			TargetID: group[0].ID,
+			NewName:  name,
+		}
+		for i, tag := range group {
+			change.OldNames = append(change.OldNames, tag.Name)
+			if i > 0 {
+				change.DuplicateIDs = append(change.DuplicateIDs, tag.ID)
+			}
+		}
+		changes = append(changes, change)
+
+		if dryRun {
+			continue
+		}
+
+		if err := s.tagRepo.MergeTags(change.TargetID, change.NewName, change.DuplicateIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
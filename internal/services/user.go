@@ -11,17 +11,21 @@ import (
 // UserService defines the interface for user service
 type UserService interface {
 	GetUserByID(id uuid.UUID) (*models.User, error)
+	GetUserProviders(id uuid.UUID) ([]*models.ProviderModelCount, error)
+	GetUserTags(id uuid.UUID) ([]*models.TagCount, error)
 }
 
 // UserServiceImpl handles user business logic
 type UserServiceImpl struct {
-	userRepo repositories.UserRepository
+	userRepo         repositories.UserRepository
+	conversationRepo repositories.ConversationRepository
 }
 
 // NewUserService creates a new user service
-func NewUserService(userRepo repositories.UserRepository) UserService {
+func NewUserService(userRepo repositories.UserRepository, conversationRepo repositories.ConversationRepository) UserService {
 	return &UserServiceImpl{
-		userRepo: userRepo,
+		userRepo:         userRepo,
+		conversationRepo: conversationRepo,
 	}
 }
 
@@ -38,3 +42,21 @@ func (s *UserServiceImpl) GetUserByID(id uuid.UUID) (*models.User, error) {
 
 	return user, nil
 }
+
+// GetUserProviders returns the providers and models present in a user's conversations with counts
+func (s *UserServiceImpl) GetUserProviders(id uuid.UUID) ([]*models.ProviderModelCount, error) {
+	if _, err := s.GetUserByID(id); err != nil {
+		return nil, err
+	}
+
+	return s.conversationRepo.CountByProviderModel(id)
+}
+
+// GetUserTags returns the tags used by a user's conversations with per-user usage counts
+func (s *UserServiceImpl) GetUserTags(id uuid.UUID) ([]*models.TagCount, error) {
+	if _, err := s.GetUserByID(id); err != nil {
+		return nil, err
+	}
+
+	return s.conversationRepo.CountByTag(id)
+}
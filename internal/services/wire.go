@@ -11,5 +11,9 @@ var ServiceSet = wire.NewSet(
 	NewMessageService,
 	NewTagService,
 	NewSearchService,
+	NewSearchCacheFromConfig,
 	NewSyncService,
+	NewReindexService,
+	NewAuditService,
+	NewMappingService,
 )
@@ -0,0 +1,306 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/handlers"
+	"chat-assistant-backend/internal/infra/elasticsearch"
+	"chat-assistant-backend/internal/jobs"
+	"chat-assistant-backend/internal/middleware"
+	"chat-assistant-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReindexer reports progress and blocks until released
+type fakeReindexer struct {
+	release chan struct{}
+	err     error
+}
+
+func (f *fakeReindexer) Reindex(ctx context.Context, progress func(processed, total int)) error {
+	progress(0, 2)
+	<-f.release
+	progress(2, 2)
+	return f.err
+}
+
+func waitForReindexJob(t *testing.T, service services.ReindexService, id string) *jobs.Job {
+	t.Helper()
+
+	var job *jobs.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = service.GetReindexJob(id)
+		require.NoError(t, err)
+		return job.Finished()
+	}, time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestReindexService(t *testing.T) {
+	t.Run("Completes and reports progress", func(t *testing.T) {
+		reindexer := &fakeReindexer{release: make(chan struct{})}
+		service := services.NewReindexService(reindexer, jobs.NewManager(10, time.Hour))
+
+		job, err := service.StartReindex()
+		require.NoError(t, err)
+		assert.NotEqual(t, jobs.StateFailed, job.State)
+
+		// 运行期间拒绝并发重建
+		_, err = service.StartReindex()
+		assert.Equal(t, errors.ErrReindexInProgress, err)
+
+		close(reindexer.release)
+		finished := waitForReindexJob(t, service, job.ID)
+
+		assert.Equal(t, jobs.StateDone, finished.State)
+		assert.Equal(t, 2, finished.Processed)
+		assert.Equal(t, 2, finished.Total)
+		assert.NotNil(t, finished.FinishedAt)
+
+		// 完成后可以再次启动
+		reindexer.release = make(chan struct{})
+		close(reindexer.release)
+		next, err := service.StartReindex()
+		require.NoError(t, err)
+		waitForReindexJob(t, service, next.ID)
+	})
+
+	t.Run("Records errors in job status", func(t *testing.T) {
+		reindexer := &fakeReindexer{release: make(chan struct{}), err: fmt.Errorf("bulk index failed")}
+		close(reindexer.release)
+		service := services.NewReindexService(reindexer, jobs.NewManager(10, time.Hour))
+
+		job, err := service.StartReindex()
+		require.NoError(t, err)
+		finished := waitForReindexJob(t, service, job.ID)
+
+		assert.Equal(t, jobs.StateFailed, finished.State)
+		assert.Equal(t, "bulk index failed", finished.Error)
+	})
+
+	t.Run("Unknown job", func(t *testing.T) {
+		service := services.NewReindexService(&fakeReindexer{}, jobs.NewManager(10, time.Hour))
+
+		_, err := service.GetReindexJob("missing")
+		assert.Equal(t, errors.ErrReindexJobNotFound, err)
+	})
+}
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(token string) *gin.Engine {
+		router := gin.New()
+		router.GET("/admin", middleware.AdminAuthMiddleware(token), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		return router
+	}
+
+	testCases := []struct {
+		name           string
+		configured     string
+		provided       string
+		expectedStatus int
+	}{
+		{"Disabled without configured token", "", "anything", http.StatusForbidden},
+		{"Missing token", "secret", "", http.StatusUnauthorized},
+		{"Wrong token", "secret", "wrong", http.StatusUnauthorized},
+		{"Valid token", "secret", "secret", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tc.provided != "" {
+				req.Header.Set(middleware.AdminTokenHeader, tc.provided)
+			}
+			newRouter(tc.configured).ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+		})
+	}
+}
+
+// newMappingES starts an Elasticsearch stand-in for the _mapping API of one index, reached
+// through an alias like in production. PUT merges new top-level properties; a field of
+// type "txt" is rejected the way Elasticsearch rejects unknown types
+func newMappingES(t *testing.T, alias string, properties map[string]interface{}) (*elasticsearch.Client, *[]map[string]interface{}) {
+	var puts []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/" {
+			// 创建客户端时的 ping
+			return
+		}
+		require.Equal(t, "/"+alias+"/_mapping", r.URL.Path)
+
+		if r.Method == http.MethodPut {
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			for name, field := range body["properties"].(map[string]interface{}) {
+				if field.(map[string]interface{})["type"] == "txt" {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"error":{"type":"mapper_parsing_exception","reason":"No handler for type [txt] declared on field [` + name + `]"},"status":400}`))
+					return
+				}
+				properties[name] = field
+			}
+			puts = append(puts, body)
+			w.Write([]byte(`{"acknowledged":true}`))
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			alias + "_v2": map[string]interface{}{
+				"mappings": map[string]interface{}{"properties": properties},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := elasticsearch.NewClient(&elasticsearch.Config{Hosts: []string{server.URL}})
+	require.NoError(t, err)
+	return client, &puts
+}
+
+func TestAdminIndexMapping(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func() (*gin.Engine, *[]map[string]interface{}) {
+		client, puts := newMappingES(t, "conversations", map[string]interface{}{
+			"title": map[string]interface{}{"type": "text", "analyzer": "standard"},
+			"messages": map[string]interface{}{
+				"type": "nested",
+				"properties": map[string]interface{}{
+					"content": map[string]interface{}{"type": "text", "analyzer": "standard"},
+				},
+			},
+		})
+		handler := handlers.NewAdminHandler(nil, nil, services.NewMappingService(client, "conversations"), nil)
+
+		router := gin.New()
+		admin := router.Group("/admin", middleware.AdminAuthMiddleware("secret"))
+		admin.GET("/es/mapping", handler.GetIndexMapping)
+		admin.PUT("/es/mapping", handler.UpdateIndexMapping)
+		return router, puts
+	}
+	do := func(router *gin.Engine, method, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/admin/es/mapping", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.AdminTokenHeader, "secret")
+		router.ServeHTTP(w, req)
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w, resp
+	}
+
+	t.Run("Get live mapping", func(t *testing.T) {
+		router, _ := newRouter()
+		w, resp := do(router, http.MethodGet, "")
+
+		require.Equal(t, http.StatusOK, w.Code)
+		properties := resp["data"].(map[string]interface{})["properties"].(map[string]interface{})
+		assert.Equal(t, "standard", properties["title"].(map[string]interface{})["analyzer"])
+		assert.Contains(t, properties, "messages")
+	})
+
+	t.Run("Requires admin token", func(t *testing.T) {
+		router, _ := newRouter()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/es/mapping", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Add new fields", func(t *testing.T) {
+		router, puts := newRouter()
+		w, resp := do(router, http.MethodPut, `{"properties": {
+			"summary": {"type": "text"},
+			"title": {"type": "text", "analyzer": "standard", "fields": {"exact": {"type": "keyword"}}},
+			"messages": {"properties": {"lang": {"type": "keyword"}}}
+		}}`)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.Len(t, *puts, 1)
+		properties := resp["data"].(map[string]interface{})["properties"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"type": "text"}, properties["summary"])
+	})
+
+	t.Run("Reject changes to existing fields", func(t *testing.T) {
+		router, puts := newRouter()
+		w, resp := do(router, http.MethodPut, `{"properties": {
+			"title": {"type": "text", "analyzer": "ik_smart"},
+			"messages": {"properties": {"content": {"type": "keyword"}}}
+		}}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, *puts, "breaking changes must not reach Elasticsearch")
+		errBody := resp["error"].(map[string]interface{})
+		assert.Equal(t, errors.ErrCodeMappingBreakingChange, errBody["code"])
+		assert.Contains(t, errBody["details"], "messages.content.type, title.analyzer")
+		assert.Contains(t, errBody["details"], "reindex")
+	})
+
+	t.Run("Mapping rejected by Elasticsearch", func(t *testing.T) {
+		router, _ := newRouter()
+		w, resp := do(router, http.MethodPut, `{"properties": {"summary": {"type": "txt"}}}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		errBody := resp["error"].(map[string]interface{})
+		assert.Equal(t, errors.ErrCodeInvalidMapping, errBody["code"])
+		assert.Contains(t, errBody["details"], "No handler for type [txt]")
+	})
+}
+
+func TestBreakingMappingChanges(t *testing.T) {
+	current := map[string]interface{}{
+		"dynamic": "strict",
+		"properties": map[string]interface{}{
+			"title": map[string]interface{}{
+				"type":   "text",
+				"fields": map[string]interface{}{"exact": map[string]interface{}{"type": "text", "analyzer": "keyword"}},
+			},
+			"content_hash": map[string]interface{}{"type": "keyword", "index": false},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		proposed map[string]interface{}
+		expected []string
+	}{
+		{"Identical", current, nil},
+		{"New field", map[string]interface{}{"properties": map[string]interface{}{"summary": map[string]interface{}{"type": "text"}}}, nil},
+		{"New multi-field", map[string]interface{}{"properties": map[string]interface{}{"title": map[string]interface{}{
+			"fields": map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword"}},
+		}}}, nil},
+		{"Changed type", map[string]interface{}{"properties": map[string]interface{}{"title": map[string]interface{}{"type": "keyword"}}}, []string{"title.type"}},
+		{"New parameter on existing field", map[string]interface{}{"properties": map[string]interface{}{"title": map[string]interface{}{"analyzer": "ik_smart"}}}, []string{"title.analyzer"}},
+		{"Changed multi-field", map[string]interface{}{"properties": map[string]interface{}{"title": map[string]interface{}{
+			"fields": map[string]interface{}{"exact": map[string]interface{}{"analyzer": "standard"}},
+		}}}, []string{"title.exact.analyzer"}},
+		{"Changed boolean parameter", map[string]interface{}{"properties": map[string]interface{}{"content_hash": map[string]interface{}{"index": true}}}, []string{"content_hash.index"}},
+		{"Changed root parameter", map[string]interface{}{"dynamic": true}, []string{"dynamic"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, services.BreakingMappingChanges(current, tc.proposed))
+		})
+	}
+}
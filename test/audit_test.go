@@ -0,0 +1,165 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/handlers"
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/repositories"
+	"chat-assistant-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAuditLogRepository is a mock implementation of repositories.AuditLogRepository
+type MockAuditLogRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditLogRepository) Create(log *models.AuditLog) error {
+	return m.Called(log).Error(0)
+}
+
+func (m *MockAuditLogRepository) List(filter repositories.AuditLogFilter, page, limit int) ([]*models.AuditLog, int64, error) {
+	args := m.Called(filter, page, limit)
+	return args.Get(0).([]*models.AuditLog), args.Get(1).(int64), args.Error(2)
+}
+
+func TestConversationService_DeleteRecordsAudit(t *testing.T) {
+	conversation := &models.Conversation{
+		Base:   models.Base{ID: uuid.New()},
+		UserID: uuid.New(),
+		Title:  "Obsolete",
+	}
+
+	convRepo := new(MockConversationRepository)
+	convRepo.On("GetByID", conversation.ID).Return(conversation, nil)
+	convRepo.On("Delete", conversation.ID).Return(nil)
+
+	indexer := new(MockIndexer)
+	indexer.On("DeleteConversation", conversation.ID).Return(nil)
+
+	var recorded *models.AuditLog
+	auditRepo := new(MockAuditLogRepository)
+	auditRepo.On("Create", mock.Anything).Run(func(args mock.Arguments) {
+		recorded = args.Get(0).(*models.AuditLog)
+	}).Return(nil)
+
+	auditService := services.NewAuditService(auditRepo)
+	service := services.NewConversationService(convRepo, new(MockTagRepository), new(MockUserRepository), indexer, auditService, &config.Config{})
+
+	require.NoError(t, service.DeleteConversation(context.Background(), conversation.ID))
+
+	// Stop 等待队列中的记录写入完成
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, auditService.Stop(ctx))
+
+	require.NotNil(t, recorded)
+	assert.Equal(t, models.AuditActionConversationDelete, recorded.Action)
+	assert.Equal(t, models.AuditResourceConversation, recorded.ResourceType)
+	assert.Equal(t, conversation.ID, recorded.ResourceID)
+	assert.Nil(t, recorded.ActorUserID)
+
+	var details map[string]string
+	require.NoError(t, json.Unmarshal([]byte(recorded.Details), &details))
+	assert.Equal(t, conversation.UserID.String(), details["user_id"])
+
+	t.Run("Not found is not audited", func(t *testing.T) {
+		missing := uuid.New()
+		convRepo.On("GetByID", missing).Return(nil, nil)
+
+		assert.Error(t, service.DeleteConversation(context.Background(), missing))
+		auditRepo.AssertNumberOfCalls(t, "Create", 1)
+	})
+}
+
+func TestAuditService_Record(t *testing.T) {
+	t.Run("Does not block on a slow store", func(t *testing.T) {
+		release := make(chan struct{})
+		auditRepo := new(MockAuditLogRepository)
+		auditRepo.On("Create", mock.Anything).Run(func(mock.Arguments) { <-release }).Return(nil)
+		auditService := services.NewAuditService(auditRepo)
+
+		start := time.Now()
+		for i := 0; i < 10; i++ {
+			auditService.Record(nil, models.AuditActionTagDelete, models.AuditResourceTag, uuid.New(), nil)
+		}
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+		close(release)
+		require.NoError(t, auditService.Stop(context.Background()))
+		auditRepo.AssertNumberOfCalls(t, "Create", 10)
+	})
+
+	t.Run("Stop times out with pending records", func(t *testing.T) {
+		auditRepo := new(MockAuditLogRepository)
+		auditRepo.On("Create", mock.Anything).Run(func(mock.Arguments) { time.Sleep(200 * time.Millisecond) }).Return(nil)
+		auditService := services.NewAuditService(auditRepo)
+		auditService.Record(nil, models.AuditActionTagDelete, models.AuditResourceTag, uuid.New(), nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, auditService.Stop(ctx), context.DeadlineExceeded)
+
+		// 停止后的记录被丢弃，不会 panic
+		auditService.Record(nil, models.AuditActionTagDelete, models.AuditResourceTag, uuid.New(), nil)
+	})
+}
+
+func TestGetAuditLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resourceID := uuid.New()
+
+	auditRepo := new(MockAuditLogRepository)
+	auditRepo.On("List", mock.MatchedBy(func(filter repositories.AuditLogFilter) bool {
+		return filter.Action == models.AuditActionMessageDelete && filter.ResourceID != nil && *filter.ResourceID == resourceID &&
+			filter.StartDate != nil && filter.EndDate != nil && filter.EndDate.Hour() == 23
+	}), 1, 20).Return([]*models.AuditLog{{
+		ID:           uuid.New(),
+		Action:       models.AuditActionMessageDelete,
+		ResourceType: models.AuditResourceMessage,
+		ResourceID:   resourceID,
+		Details:      `{"role":"user"}`,
+		CreatedAt:    time.Now(),
+	}}, int64(1), nil)
+
+	router := gin.New()
+	router.GET("/admin/audit-logs", handlers.NewAdminHandler(nil, services.NewAuditService(auditRepo), nil, nil).GetAuditLogs)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/audit-logs"+query, nil))
+		return w
+	}
+
+	t.Run("Filters by action, resource and date", func(t *testing.T) {
+		w := get("?action=message.delete&resource_id=" + resourceID.String() + "&start_date=2026-01-01&end_date=2026-01-31")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var body struct {
+			Data struct {
+				AuditLogs []map[string]interface{} `json:"audit_logs"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		require.Len(t, body.Data.AuditLogs, 1)
+		assert.Equal(t, resourceID.String(), body.Data.AuditLogs[0]["resource_id"])
+		assert.Equal(t, map[string]interface{}{"role": "user"}, body.Data.AuditLogs[0]["details"])
+		auditRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid resource ID", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("?resource_id=nope").Code)
+	})
+}
@@ -0,0 +1,173 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_ElasticsearchDefaults(t *testing.T) {
+	// 测试目录下没有 config.yaml，只使用默认值
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	es := cfg.Elasticsearch
+	assert.Equal(t, []string{"http://localhost:9200"}, es.Hosts)
+	assert.Equal(t, 30*time.Second, es.Timeout)
+	assert.Equal(t, "conversations", es.Index.Conversations)
+	assert.Equal(t, "messages", es.Index.Messages)
+	assert.Equal(t, "standard", es.Index.SourceAnalyzer)
+	assert.Equal(t, "exact_phrase", es.Index.ExactAnalyzer)
+	assert.False(t, es.AutoInit)
+	assert.False(t, es.WarmUp.Enabled)
+	assert.Equal(t, 5*time.Second, es.WarmUp.Timeout)
+	assert.Empty(t, es.TLS.CACertFile)
+	assert.False(t, es.TLS.InsecureSkipVerify)
+	assert.Equal(t, 3, es.Retry.MaxRetries)
+	assert.Equal(t, []int{502, 503, 504}, es.Retry.RetryOnStatus)
+	assert.Equal(t, config.MessageIndexModeNested, cfg.Search.MessageIndexMode)
+	assert.False(t, cfg.Search.WritesMessageIndex())
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestLoad_DatabaseConnectRetryDefaults(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, cfg.Database.ConnectRetries)
+	assert.Equal(t, time.Second, cfg.Database.ConnectRetryInterval)
+}
+
+func TestSearchConfig_MessageIndexMode(t *testing.T) {
+	for mode, writes := range map[string]bool{
+		"":                            false,
+		config.MessageIndexModeNested: false,
+		config.MessageIndexModeDual:   true,
+		config.MessageIndexModeFlat:   true,
+	} {
+		cfg := config.SearchConfig{MessageIndexMode: mode}
+		assert.NoError(t, cfg.Validate(), mode)
+		assert.Equal(t, writes, cfg.WritesMessageIndex(), mode)
+	}
+
+	err := (&config.Config{
+		Elasticsearch: config.ElasticsearchConfig{
+			Hosts:   []string{"http://localhost:9200"},
+			Timeout: time.Second,
+			Index:   config.IndexConfig{Conversations: "conversations", Messages: "messages"},
+		},
+		Search: config.SearchConfig{MessageIndexMode: "separate"},
+	}).Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "search: message_index_mode")
+}
+
+func TestLoad_ImportDefaultModels(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"chatgpt": "gpt-4o",
+		"claude":  "claude-3-5-sonnet",
+		"gemini":  "gemini-1.5-pro",
+	}, cfg.Import.DefaultModels())
+}
+
+func TestImportConfig_Validate(t *testing.T) {
+	cfg := config.ImportConfig{Providers: map[string]config.ProviderConfig{
+		"chatgpt": {Enabled: true, DefaultModel: "gpt-4o"},
+		"gemini":  {Enabled: false},
+	}}
+	// 未启用的平台不要求默认模型
+	assert.NoError(t, cfg.Validate())
+
+	cfg.OversizedPolicy = "drop"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "oversized_policy")
+	cfg.OversizedPolicy = config.OversizedPolicyTruncate
+
+	cfg.Providers["claude"] = config.ProviderConfig{Enabled: true, DefaultModel: " "}
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "providers.claude.default_model")
+
+	err = (&config.Config{
+		Elasticsearch: config.ElasticsearchConfig{
+			Hosts:   []string{"http://localhost:9200"},
+			Timeout: time.Second,
+			Index:   config.IndexConfig{Conversations: "conversations", Messages: "messages"},
+		},
+		Import: cfg,
+	}).Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "import: ")
+}
+
+func TestElasticsearchConfig_Validate(t *testing.T) {
+	valid := func() config.ElasticsearchConfig {
+		return config.ElasticsearchConfig{
+			Hosts:   []string{"http://localhost:9200", "https://es.example.com:9243"},
+			Timeout: 30 * time.Second,
+			Index:   config.IndexConfig{Conversations: "conversations", Messages: "messages"},
+			Refresh: config.RefreshConfig{Write: "wait_for", Bulk: "false"},
+			Retry:   config.ElasticsearchRetryConfig{MaxRetries: 3, RetryOnStatus: []int{502, 503, 504}},
+		}
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		cfg := valid()
+		assert.NoError(t, cfg.Validate())
+
+		// 0 表示不重试
+		cfg.Retry.MaxRetries = 0
+		assert.NoError(t, cfg.Validate())
+
+		cfg.Index.SourceAnalyzer = "cjk"
+		cfg.Index.ExactAnalyzer = "cjk"
+		assert.NoError(t, cfg.Validate())
+	})
+
+	tests := []struct {
+		name   string
+		modify func(*config.ElasticsearchConfig)
+		errMsg string
+	}{
+		{"Empty hosts", func(c *config.ElasticsearchConfig) { c.Hosts = nil }, "hosts must not be empty"},
+		{"Blank host", func(c *config.ElasticsearchConfig) { c.Hosts = []string{" "} }, "http(s) URL"},
+		{"Host without scheme", func(c *config.ElasticsearchConfig) { c.Hosts = []string{"localhost:9200"} }, "http(s) URL"},
+		{"Empty conversations index", func(c *config.ElasticsearchConfig) { c.Index.Conversations = "" }, "index.conversations"},
+		{"Empty messages index", func(c *config.ElasticsearchConfig) { c.Index.Messages = " " }, "index.messages"},
+		{"Invalid source analyzer", func(c *config.ElasticsearchConfig) { c.Index.SourceAnalyzer = `cjk", "x` }, "index.source_analyzer"},
+		{"Invalid exact analyzer", func(c *config.ElasticsearchConfig) { c.Index.ExactAnalyzer = "exact phrase" }, "index.exact_analyzer"},
+		{"Zero timeout", func(c *config.ElasticsearchConfig) { c.Timeout = 0 }, "timeout must be positive"},
+		{"Negative startup timeout", func(c *config.ElasticsearchConfig) { c.StartupTimeout = -time.Second }, "startup_timeout"},
+		{"Negative warm-up timeout", func(c *config.ElasticsearchConfig) { c.WarmUp.Timeout = -time.Second }, "warm_up.timeout"},
+		{"Invalid refresh policy", func(c *config.ElasticsearchConfig) { c.Refresh.Bulk = "sometimes" }, "refresh.bulk"},
+		{"Conflicting TLS settings", func(c *config.ElasticsearchConfig) {
+			c.TLS = config.ElasticsearchTLSConfig{CACertFile: "/etc/ssl/es-ca.pem", InsecureSkipVerify: true}
+		}, "cannot both be set"},
+		{"Negative retries", func(c *config.ElasticsearchConfig) { c.Retry.MaxRetries = -1 }, "retry.max_retries"},
+		{"Invalid retry status", func(c *config.ElasticsearchConfig) { c.Retry.RetryOnStatus = []int{503, 42} }, "invalid HTTP status 42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+
+			// Config.Validate 报告出错的配置段
+			err = (&config.Config{Elasticsearch: cfg}).Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "elasticsearch: ")
+		})
+	}
+}
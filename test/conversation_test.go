@@ -0,0 +1,1905 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/handlers"
+	"chat-assistant-backend/internal/infra/database"
+	"chat-assistant-backend/internal/middleware"
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/repositories"
+	"chat-assistant-backend/internal/response"
+	"chat-assistant-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// openTestDB connects to the database given by TEST_DATABASE_DSN and runs
+// migrations; tests needing a real PostgreSQL are skipped when it is unset
+func openTestDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set, skipping database test")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.RunMigrations(db))
+	return db
+}
+
+// createTestConversation creates a user and a conversation owned by that user
+func createTestConversation(t *testing.T, db *gorm.DB) (*models.User, *models.Conversation) {
+	user := &models.User{Username: "test-" + uuid.NewString()[:8]}
+	require.NoError(t, db.Create(user).Error)
+
+	conversation := &models.Conversation{
+		UserID:      user.ID,
+		Title:       "Test conversation",
+		Provider:    "openai",
+		SourceID:    uuid.NewString(),
+		SourceTitle: "Test conversation",
+	}
+	require.NoError(t, db.Create(conversation).Error)
+
+	t.Cleanup(func() {
+		db.Unscoped().Where("conversation_id = ?", conversation.ID).Delete(&models.Message{})
+		db.Unscoped().Delete(conversation)
+		db.Unscoped().Delete(user)
+	})
+	return user, conversation
+}
+
+func TestConversationRepository_GetByUserIDWithPreview(t *testing.T) {
+	db := openTestDB(t)
+	user, conversation := createTestConversation(t, db)
+
+	base := time.Now().Add(-time.Hour)
+	// 按非时间顺序插入，确保预览取的是时间上最后一条
+	for i, offset := range []time.Duration{2 * time.Minute, 10 * time.Minute, 5 * time.Minute} {
+		message := &models.Message{
+			Base:           models.Base{CreatedAt: base.Add(offset)},
+			ConversationID: conversation.ID,
+			Role:           []string{"user", "assistant", "user"}[i],
+			Content:        offset.String(),
+			SourceID:       uuid.NewString(),
+			SourceContent:  offset.String(),
+		}
+		require.NoError(t, db.Create(message).Error)
+	}
+
+	repo := repositories.NewConversationRepository(db)
+	conversations, lastMessages, total, err := repo.GetByUserIDWithPreview(user.ID, 1, 10, repositories.OrderCreated)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, conversations, 1)
+	require.Contains(t, lastMessages, conversation.ID)
+	assert.Equal(t, "assistant", lastMessages[conversation.ID].Role)
+	assert.Equal(t, (10 * time.Minute).String(), lastMessages[conversation.ID].Content)
+}
+
+func TestConversation_LastMessageAt(t *testing.T) {
+	db := openTestDB(t)
+	user, active := createTestConversation(t, db)
+
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	newMessage := func(conversationID uuid.UUID, offset time.Duration) *models.Message {
+		message := &models.Message{
+			Base:           models.Base{CreatedAt: base.Add(offset)},
+			ConversationID: conversationID,
+			Role:           "user",
+			Content:        offset.String(),
+			SourceID:       uuid.NewString(),
+		}
+		require.NoError(t, db.Create(message).Error)
+		return message
+	}
+
+	// active 创建得早但最近有消息；idle 创建得晚但消息较旧；empty 没有消息
+	newMessage(active.ID, 5*time.Minute)
+	newMessage(active.ID, 30*time.Minute)
+	deleted := newMessage(active.ID, 50*time.Minute)
+	require.NoError(t, db.Delete(deleted).Error)
+
+	idle := &models.Conversation{UserID: user.ID, Title: "Idle", Provider: "openai", SourceID: uuid.NewString(), SourceTitle: "Idle"}
+	require.NoError(t, db.Create(idle).Error)
+	newMessage(idle.ID, 10*time.Minute)
+
+	empty := &models.Conversation{UserID: user.ID, Title: "Empty", Provider: "openai", SourceID: uuid.NewString(), SourceTitle: "Empty"}
+	require.NoError(t, db.Create(empty).Error)
+
+	t.Cleanup(func() {
+		db.Unscoped().Where("conversation_id = ?", idle.ID).Delete(&models.Message{})
+		db.Unscoped().Delete(idle)
+		db.Unscoped().Delete(empty)
+	})
+
+	lastMessageAt := func(id uuid.UUID) *time.Time {
+		var conversation models.Conversation
+		require.NoError(t, db.First(&conversation, "id = ?", id).Error)
+		return conversation.LastMessageAt
+	}
+
+	t.Run("Migration backfills from messages", func(t *testing.T) {
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+
+		// 回退到加列之前再重新迁移，执行回填
+		goose.SetTableName("goose_db_version")
+		require.NoError(t, goose.DownTo(sqlDB, "../internal/migrations", 12))
+		require.NoError(t, goose.Up(sqlDB, "../internal/migrations"))
+
+		// 软删除的消息不计入
+		require.NotNil(t, lastMessageAt(active.ID))
+		assert.True(t, lastMessageAt(active.ID).Equal(base.Add(30*time.Minute)))
+		require.NotNil(t, lastMessageAt(idle.ID))
+		assert.True(t, lastMessageAt(idle.ID).Equal(base.Add(10*time.Minute)))
+		assert.Nil(t, lastMessageAt(empty.ID))
+	})
+
+	t.Run("Activity order", func(t *testing.T) {
+		require.NoError(t, repositories.UpdateLastMessageAt(db, []uuid.UUID{active.ID, idle.ID, empty.ID}))
+
+		repo := repositories.NewConversationRepository(db)
+		ids := func(order string) []uuid.UUID {
+			conversations, total, err := repo.GetByUserID(user.ID, 1, 10, order)
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), total)
+
+			result := make([]uuid.UUID, len(conversations))
+			for i, conversation := range conversations {
+				result[i] = conversation.ID
+			}
+			return result
+		}
+
+		assert.Equal(t, []uuid.UUID{empty.ID, idle.ID, active.ID}, ids(repositories.OrderCreated))
+		assert.Equal(t, []uuid.UUID{active.ID, idle.ID, empty.ID}, ids(repositories.OrderActivity))
+	})
+}
+
+func TestGetConversations_InvalidOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/conversations", handlers.NewConversationHandler(new(MockConversationService)).GetConversations)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/conversations?user_id="+uuid.NewString()+"&order=title", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "INVALID_ORDER")
+}
+
+func TestNewConversationListResponseWithPreview(t *testing.T) {
+	withMessage := &models.Conversation{Base: models.Base{ID: uuid.New()}}
+	empty := &models.Conversation{Base: models.Base{ID: uuid.New()}}
+	lastMessages := map[uuid.UUID]*models.Message{
+		withMessage.ID: {Role: "assistant", Content: strings.Repeat("长", 150)},
+	}
+
+	listResponse := response.NewConversationListResponseWithPreview([]*models.Conversation{withMessage, empty}, lastMessages)
+
+	require.NotNil(t, listResponse.Conversations[0].LastMessage)
+	assert.Equal(t, "assistant", listResponse.Conversations[0].LastMessage.Role)
+	assert.Equal(t, strings.Repeat("长", 100)+"...", listResponse.Conversations[0].LastMessage.Content)
+	assert.Nil(t, listResponse.Conversations[1].LastMessage)
+}
+
+func TestConversationService_Transfer(t *testing.T) {
+	conversationID := uuid.New()
+	sourceUserID := uuid.New()
+	targetUserID := uuid.New()
+	conversation := &models.Conversation{
+		Base:   models.Base{ID: conversationID},
+		UserID: sourceUserID,
+		Title:  "Misplaced",
+	}
+
+	newService := func(convRepo *MockConversationRepository, userRepo *MockUserRepository, indexer *MockIndexer) services.ConversationService {
+		return services.NewConversationService(convRepo, new(MockTagRepository), userRepo, indexer, nil, &config.Config{})
+	}
+
+	t.Run("Updates owner and ES user_id", func(t *testing.T) {
+		transferred := *conversation
+		transferred.UserID = targetUserID
+
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", conversationID).Return(conversation, nil).Once()
+		convRepo.On("SourceIDExists", targetUserID, conversation.SourceID, conversationID).Return(false, nil)
+		convRepo.On("UpdateUserID", conversationID, targetUserID).Return(true, nil)
+		convRepo.On("GetByID", conversationID).Return(&transferred, nil).Once()
+
+		userRepo := new(MockUserRepository)
+		userRepo.On("GetByID", targetUserID).Return(&models.User{Base: models.Base{ID: targetUserID}}, nil)
+
+		indexer := new(MockIndexer)
+		indexer.On("UpdateConversation", mock.MatchedBy(func(doc *models.ConversationDocument) bool {
+			return doc.ID == conversationID && doc.UserID == targetUserID
+		})).Return(nil)
+
+		result, err := newService(convRepo, userRepo, indexer).Transfer(context.Background(), conversationID, targetUserID)
+		require.NoError(t, err)
+
+		assert.Equal(t, targetUserID, result.UserID)
+		convRepo.AssertExpectations(t)
+		indexer.AssertExpectations(t)
+	})
+
+	t.Run("Conversation not found", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", conversationID).Return(nil, nil)
+
+		_, err := newService(convRepo, new(MockUserRepository), new(MockIndexer)).Transfer(context.Background(), conversationID, targetUserID)
+		assert.Equal(t, errors.ErrConversationNotFound, err)
+	})
+
+	t.Run("Target user not found", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", conversationID).Return(conversation, nil)
+
+		userRepo := new(MockUserRepository)
+		userRepo.On("GetByID", targetUserID).Return(nil, nil)
+
+		indexer := new(MockIndexer)
+		_, err := newService(convRepo, userRepo, indexer).Transfer(context.Background(), conversationID, targetUserID)
+
+		assert.Equal(t, errors.ErrUserNotFound, err)
+		convRepo.AssertNotCalled(t, "UpdateUserID", mock.Anything, mock.Anything)
+		indexer.AssertNotCalled(t, "UpdateConversation", mock.Anything)
+	})
+
+	t.Run("Target user already has the source_id", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", conversationID).Return(conversation, nil)
+		convRepo.On("SourceIDExists", targetUserID, conversation.SourceID, conversationID).Return(true, nil)
+
+		userRepo := new(MockUserRepository)
+		userRepo.On("GetByID", targetUserID).Return(&models.User{Base: models.Base{ID: targetUserID}}, nil)
+
+		_, err := newService(convRepo, userRepo, new(MockIndexer)).Transfer(context.Background(), conversationID, targetUserID)
+
+		assert.Equal(t, errors.ErrSourceIDConflict, err)
+		convRepo.AssertNotCalled(t, "UpdateUserID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Deleted concurrently", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("GetByID", targetUserID).Return(&models.User{Base: models.Base{ID: targetUserID}}, nil)
+
+		// 更新时已不存在
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", conversationID).Return(conversation, nil)
+		convRepo.On("SourceIDExists", targetUserID, conversation.SourceID, conversationID).Return(false, nil)
+		convRepo.On("UpdateUserID", conversationID, targetUserID).Return(false, nil)
+
+		indexer := new(MockIndexer)
+		_, err := newService(convRepo, userRepo, indexer).Transfer(context.Background(), conversationID, targetUserID)
+		assert.Equal(t, errors.ErrConversationNotFound, err)
+
+		// 更新后、重新读取前被删除
+		convRepo = new(MockConversationRepository)
+		convRepo.On("GetByID", conversationID).Return(conversation, nil).Once()
+		convRepo.On("SourceIDExists", targetUserID, conversation.SourceID, conversationID).Return(false, nil)
+		convRepo.On("UpdateUserID", conversationID, targetUserID).Return(true, nil)
+		convRepo.On("GetByID", conversationID).Return(nil, nil).Once()
+
+		_, err = newService(convRepo, userRepo, indexer).Transfer(context.Background(), conversationID, targetUserID)
+		assert.Equal(t, errors.ErrConversationNotFound, err)
+		indexer.AssertNotCalled(t, "UpdateConversation", mock.Anything)
+	})
+
+	t.Run("Soft-deleted conversation of the target user blocks the transfer", func(t *testing.T) {
+		db := openTestDB(t)
+		target, existing := createTestConversation(t, db)
+		_, moved := createTestConversation(t, db)
+		require.NoError(t, db.Model(moved).Update("source_id", existing.SourceID).Error)
+		require.NoError(t, db.Delete(existing).Error)
+
+		taken, err := repositories.NewConversationRepository(db).SourceIDExists(target.ID, existing.SourceID, moved.ID)
+		require.NoError(t, err)
+		assert.True(t, taken)
+	})
+}
+
+func TestConversationService_DeleteCascadesToMessages(t *testing.T) {
+	db := openTestDB(t)
+	_, conversation := createTestConversation(t, db)
+
+	var messageIDs []uuid.UUID
+	for _, content := range []string{"question", "answer"} {
+		msg := &models.Message{ConversationID: conversation.ID, Role: "user", Content: content, SourceID: uuid.NewString()}
+		require.NoError(t, db.Create(msg).Error)
+		messageIDs = append(messageIDs, msg.ID)
+	}
+
+	convRepo := repositories.NewConversationRepository(db)
+	messageService := services.NewMessageService(repositories.NewMessageRepository(db), convRepo, nil, nil)
+	listed := func() map[uuid.UUID]bool {
+		messages, _, err := messageService.GetAllMessages(1, 100)
+		require.NoError(t, err)
+		ids := make(map[uuid.UUID]bool)
+		for _, message := range messages {
+			ids[message.ID] = true
+		}
+		return ids
+	}
+	for _, id := range messageIDs {
+		require.True(t, listed()[id])
+	}
+
+	indexer := new(MockIndexer)
+	indexer.On("DeleteConversation", conversation.ID).Return(nil)
+	service := services.NewConversationService(convRepo, new(MockTagRepository), new(MockUserRepository), indexer, nil, &config.Config{})
+	require.NoError(t, service.DeleteConversation(context.Background(), conversation.ID))
+	indexer.AssertExpectations(t)
+
+	ids := listed()
+	for _, id := range messageIDs {
+		assert.False(t, ids[id], "message %s is still listed", id)
+
+		// 消息是软删除，不是物理删除
+		var message models.Message
+		require.NoError(t, db.Unscoped().First(&message, "id = ?", id).Error)
+		assert.True(t, message.DeletedAt.Valid)
+	}
+}
+
+func TestConversationRepository_Clone(t *testing.T) {
+	db := openTestDB(t)
+	user, conversation := createTestConversation(t, db)
+
+	tag := &models.Tag{Name: "clone-" + uuid.NewString()[:8]}
+	require.NoError(t, db.Create(tag).Error)
+	require.NoError(t, db.Model(conversation).Association("Tags").Append(tag))
+	t.Cleanup(func() { db.Unscoped().Delete(tag) })
+
+	base := time.Now().UTC().Add(-time.Hour)
+	var original []*models.Message
+	for i, content := range []string{"first", "second", "third"} {
+		msg := &models.Message{
+			Base:           models.Base{CreatedAt: base.Add(time.Duration(i) * time.Minute)},
+			ConversationID: conversation.ID,
+			Role:           "user",
+			Content:        content,
+			SourceID:       uuid.NewString(),
+		}
+		require.NoError(t, db.Create(msg).Error)
+		original = append(original, msg)
+	}
+
+	repo := repositories.NewConversationRepository(db)
+	clone, err := repo.Clone(conversation.ID, user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, clone)
+	t.Cleanup(func() {
+		db.Unscoped().Where("conversation_id = ?", clone.ID).Delete(&models.Message{})
+		db.Exec("DELETE FROM conversation_tags WHERE conversation_id = ?", clone.ID)
+		db.Unscoped().Delete(clone)
+	})
+
+	assert.NotEqual(t, conversation.ID, clone.ID)
+	assert.NotEqual(t, conversation.SourceID, clone.SourceID)
+	assert.Equal(t, conversation.Title, clone.Title)
+
+	clones, err := repo.FindByIDs([]uuid.UUID{clone.ID})
+	require.NoError(t, err)
+	require.Len(t, clones, 1)
+	require.Len(t, clones[0].Messages, len(original))
+	require.Len(t, clones[0].Tags, 1)
+	assert.Equal(t, tag.ID, clones[0].Tags[0].ID)
+
+	for i, msg := range clones[0].Messages {
+		assert.NotEqual(t, original[i].ID, msg.ID)
+		assert.Equal(t, original[i].Content, msg.Content)
+		assert.Equal(t, clone.ID, msg.ConversationID)
+	}
+
+	// 原对话的消息保持不变
+	var count int64
+	require.NoError(t, db.Model(&models.Message{}).Where("conversation_id = ?", conversation.ID).Count(&count).Error)
+	assert.Equal(t, int64(len(original)), count)
+
+	t.Run("Not found", func(t *testing.T) {
+		missing, err := repo.Clone(uuid.New(), user.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+}
+
+func TestConversationService_Clone(t *testing.T) {
+	conversationID := uuid.New()
+	ownerID := uuid.New()
+	conversation := &models.Conversation{Base: models.Base{ID: conversationID}, UserID: ownerID, Title: "Fork me"}
+	clone := &models.Conversation{
+		Base:     models.Base{ID: uuid.New()},
+		UserID:   ownerID,
+		Title:    "Fork me",
+		Messages: []models.Message{{Base: models.Base{ID: uuid.New()}, Role: "user", Content: "hi"}},
+	}
+
+	newService := func(convRepo *MockConversationRepository, userRepo *MockUserRepository, indexer *MockIndexer) services.ConversationService {
+		return services.NewConversationService(convRepo, new(MockTagRepository), userRepo, indexer, nil, &config.Config{})
+	}
+
+	t.Run("Defaults to the original owner and indexes the copy", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", conversationID).Return(conversation, nil)
+		convRepo.On("Clone", conversationID, ownerID).Return(clone, nil)
+		convRepo.On("FindByIDs", []uuid.UUID{clone.ID}).Return([]*models.Conversation{clone}, nil)
+
+		indexer := new(MockIndexer)
+		indexer.On("IndexConversation", mock.MatchedBy(func(doc *models.ConversationDocument) bool {
+			return doc.ID == clone.ID && len(doc.Messages) == 1
+		})).Return(nil)
+
+		userRepo := new(MockUserRepository)
+		result, err := newService(convRepo, userRepo, indexer).Clone(context.Background(), conversationID, uuid.Nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, clone.ID, result.ID)
+		userRepo.AssertNotCalled(t, "GetByID", mock.Anything)
+		indexer.AssertExpectations(t)
+	})
+
+	t.Run("Target user not found", func(t *testing.T) {
+		targetUserID := uuid.New()
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", conversationID).Return(conversation, nil)
+
+		userRepo := new(MockUserRepository)
+		userRepo.On("GetByID", targetUserID).Return(nil, nil)
+
+		_, err := newService(convRepo, userRepo, new(MockIndexer)).Clone(context.Background(), conversationID, targetUserID)
+
+		assert.Equal(t, errors.ErrUserNotFound, err)
+		convRepo.AssertNotCalled(t, "Clone", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Conversation not found", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", conversationID).Return(nil, nil)
+
+		_, err := newService(convRepo, new(MockUserRepository), new(MockIndexer)).Clone(context.Background(), conversationID, uuid.Nil)
+		assert.Equal(t, errors.ErrConversationNotFound, err)
+	})
+}
+
+// MockConversationService is a mock implementation of services.ConversationService
+type MockConversationService struct {
+	services.ConversationService
+	mock.Mock
+}
+
+func (m *MockConversationService) GetConversationByID(id uuid.UUID) (*models.Conversation, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Conversation), args.Error(1)
+}
+
+func (m *MockConversationService) DeleteConversation(ctx context.Context, id uuid.UUID) error {
+	return m.Called(id).Error(0)
+}
+
+func (m *MockConversationService) UpdateConversationTags(ctx context.Context, conversationID uuid.UUID, tagNames []string) error {
+	return m.Called(conversationID, tagNames).Error(0)
+}
+
+func (m *MockConversationService) GetConversationWithMessages(id uuid.UUID, messageLimit int) (*models.Conversation, int64, error) {
+	args := m.Called(id, messageLimit)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).(*models.Conversation), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockConversationService) PatchConversation(ctx context.Context, id uuid.UUID, patch services.ConversationPatch) (*models.Conversation, error) {
+	args := m.Called(id, patch)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Conversation), args.Error(1)
+}
+
+func TestConversationService_PatchConversation(t *testing.T) {
+	conversationID := uuid.New()
+	conversation := &models.Conversation{Base: models.Base{ID: conversationID}, Title: "Before", Model: "gpt-4"}
+	goTag := &models.Tag{Base: models.Base{ID: uuid.New()}, Name: "go"}
+	rustTag := &models.Tag{Base: models.Base{ID: uuid.New()}, Name: "rust"}
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name         string
+		patch        services.ConversationPatch
+		updates      map[string]interface{}
+		addTagIDs    []string
+		removeTagIDs []string
+	}{
+		{
+			name:    "Title only",
+			patch:   services.ConversationPatch{Title: str("After")},
+			updates: map[string]interface{}{"title": "After"},
+		},
+		{
+			name:    "Empty title clears it",
+			patch:   services.ConversationPatch{Title: str("")},
+			updates: map[string]interface{}{"title": ""},
+		},
+		{
+			name:    "Model only",
+			patch:   services.ConversationPatch{Model: str("gpt-4o")},
+			updates: map[string]interface{}{"model": "gpt-4o"},
+		},
+		{
+			name:      "Add tags only",
+			patch:     services.ConversationPatch{AddTags: []string{" go ", "go"}},
+			updates:   map[string]interface{}{},
+			addTagIDs: []string{goTag.ID.String()},
+		},
+		{
+			name:         "Remove tags only",
+			patch:        services.ConversationPatch{RemoveTags: []string{"rust", "unknown"}},
+			updates:      map[string]interface{}{},
+			removeTagIDs: []string{rustTag.ID.String()},
+		},
+		{
+			name:         "All fields",
+			patch:        services.ConversationPatch{Title: str("After"), Model: str("o1"), AddTags: []string{"go"}, RemoveTags: []string{"rust"}},
+			updates:      map[string]interface{}{"title": "After", "model": "o1"},
+			addTagIDs:    []string{goTag.ID.String()},
+			removeTagIDs: []string{rustTag.ID.String()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched := *conversation
+			convRepo := new(MockConversationRepository)
+			convRepo.On("GetByID", conversationID).Return(conversation, nil).Once()
+			convRepo.On("Patch", conversationID, tt.updates, tt.addTagIDs, tt.removeTagIDs).Return(nil)
+			convRepo.On("GetByID", conversationID).Return(&patched, nil).Once()
+
+			tagRepo := new(MockTagRepository)
+			tagRepo.On("CreateOrGetTags", []string{"go"}).Return([]*models.Tag{goTag}, nil)
+			tagRepo.On("GetByNames", []string{"rust", "unknown"}).Return([]*models.Tag{rustTag}, nil)
+			tagRepo.On("GetByNames", []string{"rust"}).Return([]*models.Tag{rustTag}, nil)
+
+			// 无论修改了几个字段都只更新一次索引
+			indexer := new(MockIndexer)
+			indexer.On("UpdateConversation", mock.MatchedBy(func(doc *models.ConversationDocument) bool {
+				return doc.ID == conversationID
+			})).Return(nil).Once()
+
+			service := services.NewConversationService(convRepo, tagRepo, new(MockUserRepository), indexer, nil, &config.Config{})
+			result, err := service.PatchConversation(context.Background(), conversationID, tt.patch)
+
+			require.NoError(t, err)
+			assert.Same(t, &patched, result)
+			convRepo.AssertExpectations(t)
+			indexer.AssertExpectations(t)
+			if tt.addTagIDs == nil {
+				tagRepo.AssertNotCalled(t, "CreateOrGetTags", mock.Anything)
+			}
+			if tt.removeTagIDs == nil {
+				tagRepo.AssertNotCalled(t, "GetByNames", mock.Anything)
+			}
+		})
+	}
+
+	t.Run("Same tag added and removed", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", conversationID).Return(conversation, nil)
+
+		service := services.NewConversationService(convRepo, new(MockTagRepository), new(MockUserRepository), new(MockIndexer), nil, newTagConfig(true))
+		_, err := service.PatchConversation(context.Background(), conversationID, services.ConversationPatch{
+			AddTags:    []string{"Go"},
+			RemoveTags: []string{"go"},
+		})
+
+		assert.Equal(t, errors.ErrTagPatchConflict, err)
+		convRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Conversation not found", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", conversationID).Return(nil, nil)
+
+		service := services.NewConversationService(convRepo, new(MockTagRepository), new(MockUserRepository), new(MockIndexer), nil, &config.Config{})
+		_, err := service.PatchConversation(context.Background(), conversationID, services.ConversationPatch{Title: str("After")})
+
+		assert.Equal(t, errors.ErrConversationNotFound, err)
+	})
+}
+
+func TestConversationRepository_Patch(t *testing.T) {
+	db := openTestDB(t)
+	_, conversation := createTestConversation(t, db)
+	repo := repositories.NewConversationRepository(db)
+	tagRepo := repositories.NewTagRepository(db)
+
+	tags, err := tagRepo.CreateOrGetTags([]string{"patch-keep-" + uuid.NewString(), "patch-drop-" + uuid.NewString(), "patch-add-" + uuid.NewString()})
+	require.NoError(t, err)
+	keep, drop, add := tags[0], tags[1], tags[2]
+	require.NoError(t, repo.ReplaceTags(conversation.ID, []string{keep.ID.String(), drop.ID.String()}))
+
+	tagNames := func(c *models.Conversation) []string {
+		names := make([]string, len(c.Tags))
+		for i, tag := range c.Tags {
+			names[i] = tag.Name
+		}
+		return names
+	}
+
+	t.Run("Tags only keeps title and other tags", func(t *testing.T) {
+		require.NoError(t, repo.Patch(conversation.ID, nil, []string{add.ID.String(), keep.ID.String()}, []string{drop.ID.String()}))
+
+		reloaded, err := repo.GetByID(conversation.ID)
+		require.NoError(t, err)
+		assert.Equal(t, conversation.Title, reloaded.Title)
+		assert.ElementsMatch(t, []string{keep.Name, add.Name}, tagNames(reloaded))
+	})
+
+	t.Run("Fields only keeps tags", func(t *testing.T) {
+		require.NoError(t, repo.Patch(conversation.ID, map[string]interface{}{"title": "", "model": "o1"}, nil, nil))
+
+		reloaded, err := repo.GetByID(conversation.ID)
+		require.NoError(t, err)
+		assert.Empty(t, reloaded.Title)
+		assert.Equal(t, "o1", reloaded.Model)
+		assert.ElementsMatch(t, []string{keep.Name, add.Name}, tagNames(reloaded))
+	})
+}
+
+func TestPatchConversation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	conversationID := uuid.New()
+	str := func(s string) *string { return &s }
+
+	patch := func(service *MockConversationService, body string) (int, map[string]interface{}) {
+		router := gin.New()
+		router.PATCH("/conversations/:id", handlers.NewConversationHandler(service).PatchConversation)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPatch, "/conversations/"+conversationID.String(), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+
+	t.Run("Absent fields stay nil", func(t *testing.T) {
+		service := new(MockConversationService)
+		service.On("PatchConversation", conversationID, services.ConversationPatch{AddTags: []string{"go"}}).
+			Return(&models.Conversation{Base: models.Base{ID: conversationID}, Title: "Kept"}, nil)
+
+		code, resp := patch(service, `{"add_tags": ["go"]}`)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "Kept", resp["data"].(map[string]interface{})["title"])
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Empty string is a value", func(t *testing.T) {
+		service := new(MockConversationService)
+		service.On("PatchConversation", conversationID, services.ConversationPatch{Title: str(""), Model: str("o1")}).
+			Return(&models.Conversation{Base: models.Base{ID: conversationID}}, nil)
+
+		code, _ := patch(service, `{"title": "", "model": "o1"}`)
+
+		assert.Equal(t, http.StatusOK, code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Empty patch", func(t *testing.T) {
+		service := new(MockConversationService)
+
+		code, _ := patch(service, `{}`)
+
+		assert.Equal(t, http.StatusBadRequest, code)
+		service.AssertNotCalled(t, "PatchConversation", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Conflicting tags", func(t *testing.T) {
+		service := new(MockConversationService)
+		service.On("PatchConversation", conversationID, mock.Anything).Return(nil, errors.ErrTagPatchConflict)
+
+		code, resp := patch(service, `{"add_tags": ["go"], "remove_tags": ["go"]}`)
+
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "TAG_PATCH_CONFLICT", resp["error"].(map[string]interface{})["code"])
+	})
+
+	t.Run("Conversation not found", func(t *testing.T) {
+		service := new(MockConversationService)
+		service.On("PatchConversation", conversationID, mock.Anything).Return(nil, errors.ErrConversationNotFound)
+
+		code, _ := patch(service, `{"title": "x"}`)
+
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+}
+
+func (m *MockConversationService) ExportConversation(id uuid.UUID, format string) ([]byte, error) {
+	args := m.Called(id, format)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func TestConversationService_ExportConversation(t *testing.T) {
+	conversationID := uuid.New()
+	base := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	conversation := &models.Conversation{
+		Base:     models.Base{ID: conversationID, CreatedAt: base, UpdatedAt: base.Add(time.Hour)},
+		Title:    "Trip planning",
+		Provider: "claude",
+		Model:    "claude-3-opus",
+		Tags:     []models.Tag{{Name: "travel"}, {Name: "旅行"}},
+		// 加载顺序与时间顺序不同
+		Messages: []models.Message{
+			{Base: models.Base{ID: uuid.New(), CreatedAt: base.Add(2 * time.Minute)}, Role: "assistant", Content: "Go to Hangzhou"},
+			{Base: models.Base{ID: uuid.New(), CreatedAt: base.Add(time.Minute)}, Role: "user", Content: "Where should I go?"},
+		},
+	}
+	conversation.SetMetadata(&models.ConversationMetadata{Account: "me@example.com", Summary: "Weekend \"trip\" ideas"})
+
+	newService := func() services.ConversationService {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("FindByIDs", []uuid.UUID{conversationID}).Return([]*models.Conversation{conversation}, nil)
+		convRepo.On("FindByIDs", mock.Anything).Return([]*models.Conversation{}, nil)
+		return services.NewConversationService(convRepo, new(MockTagRepository), new(MockUserRepository), new(MockIndexer), nil, &config.Config{})
+	}
+
+	t.Run("JSON includes tags, metadata and ordered messages", func(t *testing.T) {
+		data, err := newService().ExportConversation(conversationID, services.ExportFormatJSON)
+		require.NoError(t, err)
+
+		var export services.ConversationExport
+		require.NoError(t, json.Unmarshal(data, &export))
+		assert.Equal(t, "claude", export.Provider)
+		assert.Equal(t, "claude-3-opus", export.Model)
+		assert.Equal(t, []string{"travel", "旅行"}, export.Tags)
+		require.NotNil(t, export.Metadata)
+		assert.Equal(t, "me@example.com", export.Metadata.Account)
+		assert.Equal(t, `Weekend "trip" ideas`, export.Metadata.Summary)
+		require.Len(t, export.Messages, 2)
+		assert.Equal(t, "Where should I go?", export.Messages[0].Content)
+		assert.Equal(t, "Go to Hangzhou", export.Messages[1].Content)
+	})
+
+	t.Run("Markdown front matter", func(t *testing.T) {
+		data, err := newService().ExportConversation(conversationID, services.ExportFormatMarkdown)
+		require.NoError(t, err)
+		markdown := string(data)
+
+		require.True(t, strings.HasPrefix(markdown, "---\n"))
+		frontMatter := markdown[:strings.Index(markdown[4:], "---\n")+4]
+		assert.Contains(t, frontMatter, `title: "Trip planning"`)
+		assert.Contains(t, frontMatter, `provider: "claude"`)
+		assert.Contains(t, frontMatter, `model: "claude-3-opus"`)
+		assert.Contains(t, frontMatter, `tags: ["travel","旅行"]`)
+		assert.Contains(t, frontMatter, `account: "me@example.com"`)
+		assert.Contains(t, frontMatter, `summary: "Weekend \"trip\" ideas"`)
+		assert.NotContains(t, frontMatter, "project:")
+
+		assert.Contains(t, markdown, "# Trip planning")
+		question := strings.Index(markdown, "## User\n")
+		answer := strings.Index(markdown, "## Assistant\n")
+		require.NotEqual(t, -1, question)
+		assert.Greater(t, answer, question)
+	})
+
+	t.Run("Conversation not found", func(t *testing.T) {
+		_, err := newService().ExportConversation(uuid.New(), services.ExportFormatJSON)
+
+		assert.Equal(t, errors.ErrConversationNotFound, err)
+	})
+}
+
+func TestExportConversation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	conversationID := uuid.New()
+
+	export := func(service *MockConversationService, query string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.GET("/conversations/:id/export", handlers.NewConversationHandler(service).ExportConversation)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/conversations/"+conversationID.String()+"/export"+query, nil))
+		return w
+	}
+
+	t.Run("Defaults to JSON attachment", func(t *testing.T) {
+		service := new(MockConversationService)
+		service.On("ExportConversation", conversationID, services.ExportFormatJSON).Return([]byte(`{"id":"x"}`), nil)
+
+		w := export(service, "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="conversation-`+conversationID.String()+`.json"`, w.Header().Get("Content-Disposition"))
+		assert.Equal(t, `{"id":"x"}`, w.Body.String())
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		service := new(MockConversationService)
+		service.On("ExportConversation", conversationID, services.ExportFormatMarkdown).Return([]byte("---\n"), nil)
+
+		w := export(service, "?format=markdown")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/markdown; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), ".md")
+	})
+
+	t.Run("Invalid format", func(t *testing.T) {
+		w := export(new(MockConversationService), "?format=pdf")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Conversation not found", func(t *testing.T) {
+		service := new(MockConversationService)
+		service.On("ExportConversation", conversationID, services.ExportFormatJSON).Return(nil, errors.ErrConversationNotFound)
+
+		assert.Equal(t, http.StatusNotFound, export(service, "").Code)
+	})
+}
+
+func TestGetConversation_IncludeMessages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	conversationID := uuid.New()
+	conversation := &models.Conversation{
+		Base:  models.Base{ID: conversationID},
+		Title: "Detail",
+		Tags:  []models.Tag{{Base: models.Base{ID: uuid.New()}, Name: "go"}},
+		Messages: []models.Message{
+			{Base: models.Base{ID: uuid.New()}, ConversationID: conversationID, Role: "user", Content: "hello"},
+			{Base: models.Base{ID: uuid.New()}, ConversationID: conversationID, Role: "assistant", Content: "hi"},
+		},
+	}
+
+	service := new(MockConversationService)
+	service.On("GetConversationByID", conversationID).Return(conversation, nil)
+	service.On("GetConversationWithMessages", conversationID, mock.Anything).Return(conversation, int64(12), nil)
+
+	router := gin.New()
+	router.GET("/conversations/:id", handlers.NewConversationHandler(service).GetConversation)
+
+	get := func(query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/conversations/"+conversationID.String()+query, nil))
+
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body.Data
+	}
+
+	t.Run("Default response has no messages", func(t *testing.T) {
+		code, data := get("")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.NotContains(t, data, "messages")
+		assert.NotContains(t, data, "messages_total")
+		assert.Len(t, data["tags"], 1)
+	})
+
+	t.Run("include=messages hydrates messages", func(t *testing.T) {
+		code, data := get("?include=messages,tags")
+
+		assert.Equal(t, http.StatusOK, code)
+		require.Len(t, data["messages"], 2)
+		assert.Equal(t, "hello", data["messages"].([]interface{})[0].(map[string]interface{})["content"])
+		assert.Equal(t, float64(12), data["messages_total"])
+		assert.Len(t, data["tags"], 1)
+	})
+
+	t.Run("Unknown include option", func(t *testing.T) {
+		code, _ := get("?include=attachments")
+
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestGetConversation_ETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	conversationID := uuid.New()
+	conversation := &models.Conversation{
+		Base:  models.Base{ID: conversationID, UpdatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Title: "Cached",
+		Tags:  []models.Tag{{Base: models.Base{ID: uuid.New()}, Name: "go"}},
+	}
+
+	service := new(MockConversationService)
+	service.On("GetConversationByID", conversationID).Return(conversation, nil)
+
+	router := gin.New()
+	router.GET("/conversations/:id", handlers.NewConversationHandler(service).GetConversation)
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/conversations/"+conversationID.String(), nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("")
+	require.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	t.Run("Unchanged conversation returns 304", func(t *testing.T) {
+		w := get(etag)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+	})
+
+	t.Run("Stale ETag returns 200", func(t *testing.T) {
+		w := get(`"stale"`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, w.Body.String())
+	})
+
+	t.Run("ETag changes with title and tags", func(t *testing.T) {
+		renamed := *conversation
+		renamed.Title = "Renamed"
+		retagged := *conversation
+		retagged.Tags = []models.Tag{{Base: models.Base{ID: conversation.Tags[0].ID}, Name: "golang"}}
+
+		assert.NotEqual(t, etag, response.ConversationETag(&renamed, false, 0))
+		assert.NotEqual(t, etag, response.ConversationETag(&retagged, false, 0))
+		assert.NotEqual(t, etag, response.ConversationETag(conversation, true, 3))
+		assert.Equal(t, etag, response.ConversationETag(conversation, false, 0))
+	})
+}
+
+func TestConversationRepository_CountByProviderModel(t *testing.T) {
+	db := openTestDB(t)
+	user, conversation := createTestConversation(t, db)
+
+	// createTestConversation 创建了一个 openai 对话（无模型）
+	seed := []struct{ provider, model string }{
+		{"openai", "gpt-4"},
+		{"openai", "gpt-4"},
+		{"claude", "claude-3"},
+	}
+	for _, s := range seed {
+		c := &models.Conversation{UserID: user.ID, Provider: s.provider, Model: s.model, SourceID: uuid.NewString()}
+		require.NoError(t, db.Create(c).Error)
+		t.Cleanup(func() { db.Unscoped().Delete(c) })
+	}
+
+	// 已删除的对话不计入
+	deleted := &models.Conversation{UserID: user.ID, Provider: "gemini", Model: "gemini-pro", SourceID: uuid.NewString()}
+	require.NoError(t, db.Create(deleted).Error)
+	require.NoError(t, db.Delete(deleted).Error)
+	t.Cleanup(func() { db.Unscoped().Delete(deleted) })
+
+	counts, err := repositories.NewConversationRepository(db).CountByProviderModel(user.ID)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*models.ProviderModelCount{
+		{Provider: "claude", Model: "claude-3", Count: 1},
+		{Provider: "openai", Model: "", Count: 1},
+		{Provider: "openai", Model: "gpt-4", Count: 2},
+	}, counts)
+	assert.Equal(t, "openai", conversation.Provider)
+}
+
+func TestConversationRepository_CountByTag(t *testing.T) {
+	db := openTestDB(t)
+	alice, aliceConversation := createTestConversation(t, db)
+	bob, bobConversation := createTestConversation(t, db)
+
+	newTag := func(prefix string) *models.Tag {
+		tag := &models.Tag{Name: prefix + "-" + uuid.NewString()[:8]}
+		require.NoError(t, db.Create(tag).Error)
+		t.Cleanup(func() { db.Unscoped().Delete(tag) })
+		return tag
+	}
+	tagConversation := func(conversation *models.Conversation, tags ...*models.Tag) {
+		require.NoError(t, db.Model(conversation).Association("Tags").Append(tags))
+		t.Cleanup(func() { db.Exec("DELETE FROM conversation_tags WHERE conversation_id = ?", conversation.ID) })
+	}
+
+	golang, rust, cooking := newTag("go"), newTag("rust"), newTag("cooking")
+	second := &models.Conversation{UserID: alice.ID, Provider: "openai", SourceID: uuid.NewString()}
+	require.NoError(t, db.Create(second).Error)
+	t.Cleanup(func() { db.Unscoped().Delete(second) })
+	// 已删除的对话不计入
+	deleted := &models.Conversation{UserID: alice.ID, Provider: "openai", SourceID: uuid.NewString()}
+	require.NoError(t, db.Create(deleted).Error)
+	t.Cleanup(func() { db.Unscoped().Delete(deleted) })
+
+	tagConversation(aliceConversation, golang, rust)
+	tagConversation(second, golang)
+	tagConversation(deleted, rust)
+	require.NoError(t, db.Delete(deleted).Error)
+	tagConversation(bobConversation, cooking)
+
+	repo := repositories.NewConversationRepository(db)
+	counts, err := repo.CountByTag(alice.ID)
+	require.NoError(t, err)
+	require.Len(t, counts, 2)
+	assert.Equal(t, golang.ID, counts[0].ID)
+	assert.Equal(t, golang.Name, counts[0].Name)
+	assert.Equal(t, int64(2), counts[0].Count)
+	assert.Equal(t, rust.ID, counts[1].ID)
+	assert.Equal(t, int64(1), counts[1].Count)
+
+	counts, err = repo.CountByTag(bob.ID)
+	require.NoError(t, err)
+	require.Len(t, counts, 1)
+	assert.Equal(t, cooking.ID, counts[0].ID)
+	assert.Equal(t, int64(1), counts[0].Count)
+}
+
+func (m *MockConversationService) GetConversationsByTagID(tagID uuid.UUID, page, limit int) ([]*models.Conversation, int64, error) {
+	args := m.Called(tagID, page, limit)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*models.Conversation), args.Get(1).(int64), args.Error(2)
+}
+
+func TestConversationService_GetConversationsByTagID(t *testing.T) {
+	tagID := uuid.New()
+	newService := func(convRepo *MockConversationRepository, tagRepo *MockTagRepository) services.ConversationService {
+		return services.NewConversationService(convRepo, tagRepo, new(MockUserRepository), new(MockIndexer), nil, &config.Config{})
+	}
+
+	t.Run("Returns tagged conversations", func(t *testing.T) {
+		tagged := []*models.Conversation{{Base: models.Base{ID: uuid.New()}}, {Base: models.Base{ID: uuid.New()}}}
+		tagRepo := new(MockTagRepository)
+		tagRepo.On("GetByID", tagID).Return(&models.Tag{Base: models.Base{ID: tagID}, Name: "go"}, nil)
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByTagID", tagID, 2, 5).Return(tagged, int64(7), nil)
+
+		conversations, total, err := newService(convRepo, tagRepo).GetConversationsByTagID(tagID, 2, 5)
+
+		require.NoError(t, err)
+		assert.Equal(t, tagged, conversations)
+		assert.Equal(t, int64(7), total)
+	})
+
+	t.Run("Tag not found", func(t *testing.T) {
+		tagRepo := new(MockTagRepository)
+		tagRepo.On("GetByID", tagID).Return(nil, nil)
+		convRepo := new(MockConversationRepository)
+
+		_, _, err := newService(convRepo, tagRepo).GetConversationsByTagID(tagID, 1, 10)
+
+		assert.Equal(t, errors.ErrTagNotFound, err)
+		convRepo.AssertNotCalled(t, "GetByTagID", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetConversationsByTag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tagID := uuid.New()
+
+	get := func(service *MockConversationService, path string) (int, map[string]interface{}) {
+		router := gin.New()
+		router.GET("/tags/:id/conversations", handlers.NewConversationHandler(service).GetConversationsByTag)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+
+	t.Run("Lists tagged conversations with pagination", func(t *testing.T) {
+		tag := models.Tag{Base: models.Base{ID: tagID}, Name: "go"}
+		var tagged []*models.Conversation
+		for _, title := range []string{"First", "Second", "Third"} {
+			tagged = append(tagged, &models.Conversation{Base: models.Base{ID: uuid.New()}, Title: title, Tags: []models.Tag{tag}})
+		}
+		service := new(MockConversationService)
+		service.On("GetConversationsByTagID", tagID, 1, 3).Return(tagged, int64(5), nil)
+
+		code, resp := get(service, "/tags/"+tagID.String()+"/conversations?limit=3")
+
+		assert.Equal(t, http.StatusOK, code)
+		conversations := resp["data"].(map[string]interface{})["conversations"].([]interface{})
+		require.Len(t, conversations, 3)
+		assert.Equal(t, "First", conversations[0].(map[string]interface{})["title"])
+		pagination := resp["pagination"].(map[string]interface{})
+		assert.Equal(t, float64(5), pagination["total"])
+		assert.Equal(t, float64(2), pagination["total_pages"])
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Tag not found", func(t *testing.T) {
+		service := new(MockConversationService)
+		service.On("GetConversationsByTagID", tagID, 1, 10).Return(nil, int64(0), errors.ErrTagNotFound)
+
+		code, resp := get(service, "/tags/"+tagID.String()+"/conversations")
+
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Equal(t, "TAG_NOT_FOUND", resp["error"].(map[string]interface{})["code"])
+	})
+
+	t.Run("Invalid tag ID", func(t *testing.T) {
+		code, _ := get(new(MockConversationService), "/tags/nope/conversations")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestConversationRepository_GetByTagID(t *testing.T) {
+	db := openTestDB(t)
+	repo := repositories.NewConversationRepository(db)
+
+	tag := &models.Tag{Name: "by-tag-" + uuid.NewString()[:8]}
+	require.NoError(t, db.Create(tag).Error)
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM conversation_tags WHERE tag_id = ?", tag.ID)
+		db.Unscoped().Delete(tag)
+	})
+
+	var tagged []uuid.UUID
+	for i := 0; i < 3; i++ {
+		_, conversation := createTestConversation(t, db)
+		require.NoError(t, repo.ReplaceTags(conversation.ID, []string{tag.ID.String()}))
+		tagged = append(tagged, conversation.ID)
+	}
+	// 不带该标签的对话不返回
+	createTestConversation(t, db)
+
+	conversations, total, err := repo.GetByTagID(tag.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	ids := make([]uuid.UUID, len(conversations))
+	for i, conversation := range conversations {
+		ids[i] = conversation.ID
+		require.Len(t, conversation.Tags, 1)
+		assert.Equal(t, tag.ID, conversation.Tags[0].ID)
+	}
+	assert.ElementsMatch(t, tagged, ids)
+
+	conversations, total, err = repo.GetByTagID(tag.ID, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Len(t, conversations, 1)
+}
+
+func TestConversationRepository_GetRecentByUserID(t *testing.T) {
+	db := openTestDB(t)
+	user, _ := createTestConversation(t, db)
+	db.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Conversation{})
+
+	base := time.Now().UTC().Truncate(time.Second).Add(-24 * time.Hour)
+	at := func(hours int) *time.Time {
+		t := base.Add(time.Duration(hours) * time.Hour)
+		return &t
+	}
+	newConversation := func(title string, created int, lastMessage *time.Time, provider string) *models.Conversation {
+		c := &models.Conversation{
+			Base:          models.Base{CreatedAt: *at(created), UpdatedAt: *at(created)},
+			UserID:        user.ID,
+			Title:         title,
+			Provider:      provider,
+			SourceID:      uuid.NewString(),
+			LastMessageAt: lastMessage,
+		}
+		require.NoError(t, db.Create(c).Error)
+		t.Cleanup(func() {
+			db.Unscoped().Where("conversation_id = ?", c.ID).Delete(&models.Message{})
+			db.Unscoped().Delete(c)
+		})
+		return c
+	}
+
+	// 创建顺序与活跃顺序相反：最早创建的对话最近有新消息
+	oldest := newConversation("oldest", 1, at(20), "claude")
+	middle := newConversation("middle", 2, at(10), "openai")
+	newest := newConversation("newest", 3, nil, "gemini") // 没有消息，按 updated_at
+	message := &models.Message{
+		Base:           models.Base{CreatedAt: *at(20)},
+		ConversationID: oldest.ID,
+		Role:           "user",
+		Content:        "latest question",
+		SourceID:       uuid.NewString(),
+	}
+	require.NoError(t, db.Create(message).Error)
+
+	repo := repositories.NewConversationRepository(db)
+	conversations, lastMessages, err := repo.GetRecentByUserID(user.ID, 10)
+	require.NoError(t, err)
+
+	var titles []string
+	for _, conversation := range conversations {
+		titles = append(titles, conversation.Title)
+	}
+	assert.Equal(t, []string{oldest.Title, middle.Title, newest.Title}, titles)
+	require.Contains(t, lastMessages, oldest.ID)
+	assert.Equal(t, "latest question", lastMessages[oldest.ID].Content)
+	assert.NotContains(t, lastMessages, newest.ID)
+
+	conversations, _, err = repo.GetRecentByUserID(user.ID, 1)
+	require.NoError(t, err)
+	require.Len(t, conversations, 1)
+	assert.Equal(t, oldest.ID, conversations[0].ID)
+}
+
+func (m *MockConversationService) GetRecentConversations(userID uuid.UUID, limit int) ([]*models.Conversation, map[uuid.UUID]*models.Message, error) {
+	args := m.Called(userID, limit)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).([]*models.Conversation), args.Get(1).(map[uuid.UUID]*models.Message), args.Error(2)
+}
+
+func TestConversationHandler_GetRecentConversations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := uuid.New()
+
+	get := func(service services.ConversationService, path string) (int, *response.ConversationListResponse) {
+		router := gin.New()
+		router.GET("/users/:id/recent", handlers.NewConversationHandler(service).GetRecentConversations)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		var resp struct {
+			Data response.ConversationListResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, &resp.Data
+	}
+
+	t.Run("Keeps the activity order with previews", func(t *testing.T) {
+		active := &models.Conversation{Base: models.Base{ID: uuid.New()}, Title: "active", Provider: "claude"}
+		idle := &models.Conversation{Base: models.Base{ID: uuid.New()}, Title: "idle", Provider: "openai"}
+		lastMessages := map[uuid.UUID]*models.Message{
+			active.ID: {Role: "user", Content: "latest question"},
+		}
+
+		userRepo := new(MockUserRepository)
+		userRepo.On("GetByID", userID).Return(&models.User{Base: models.Base{ID: userID}}, nil)
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetRecentByUserID", userID, 5).Return([]*models.Conversation{active, idle}, lastMessages, nil)
+		service := services.NewConversationService(convRepo, new(MockTagRepository), userRepo, new(MockIndexer), nil, &config.Config{})
+
+		code, data := get(service, "/users/"+userID.String()+"/recent?limit=5")
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, data.Conversations, 2)
+		assert.Equal(t, "active", data.Conversations[0].Title)
+		require.NotNil(t, data.Conversations[0].LastMessage)
+		assert.Equal(t, "latest question", data.Conversations[0].LastMessage.Content)
+		assert.Equal(t, "idle", data.Conversations[1].Title)
+		assert.Nil(t, data.Conversations[1].LastMessage)
+		convRepo.AssertExpectations(t)
+	})
+
+	t.Run("Default limit", func(t *testing.T) {
+		service := new(MockConversationService)
+		service.On("GetRecentConversations", userID, 10).Return([]*models.Conversation{}, map[uuid.UUID]*models.Message{}, nil)
+
+		code, data := get(service, "/users/"+userID.String()+"/recent")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Empty(t, data.Conversations)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("User not found", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("GetByID", userID).Return(nil, nil)
+		convRepo := new(MockConversationRepository)
+		service := services.NewConversationService(convRepo, new(MockTagRepository), userRepo, new(MockIndexer), nil, &config.Config{})
+
+		code, _ := get(service, "/users/"+userID.String()+"/recent")
+		assert.Equal(t, http.StatusNotFound, code)
+		convRepo.AssertNotCalled(t, "GetRecentByUserID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		code, _ := get(new(MockConversationService), "/users/nope/recent")
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = get(new(MockConversationService), "/users/"+userID.String()+"/recent?limit=101")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestConversationRepository_GetBySourceID(t *testing.T) {
+	db := openTestDB(t)
+	repo := repositories.NewConversationRepository(db)
+
+	user, conversation := createTestConversation(t, db)
+
+	found, err := repo.GetBySourceID(user.ID, conversation.Provider, conversation.SourceID)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, conversation.ID, found.ID)
+
+	// 复合键的每一部分都参与匹配
+	for _, lookup := range []struct {
+		userID             uuid.UUID
+		provider, sourceID string
+	}{
+		{uuid.New(), conversation.Provider, conversation.SourceID},
+		{user.ID, "other-provider", conversation.SourceID},
+		{user.ID, conversation.Provider, "missing-" + uuid.NewString()},
+	} {
+		found, err := repo.GetBySourceID(lookup.userID, lookup.provider, lookup.sourceID)
+		require.NoError(t, err)
+		assert.Nil(t, found)
+	}
+}
+
+func TestConversationHandler_GetConversationBySource(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := uuid.New()
+
+	get := func(service services.ConversationService, query string) (int, map[string]interface{}) {
+		router := gin.New()
+		router.GET("/conversations/by-source", handlers.NewConversationHandler(service).GetConversationBySource)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/conversations/by-source"+query, nil))
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+	newService := func(convRepo *MockConversationRepository) services.ConversationService {
+		return services.NewConversationService(convRepo, new(MockTagRepository), new(MockUserRepository), new(MockIndexer), nil, &config.Config{})
+	}
+
+	t.Run("Looks up by user, provider and source ID", func(t *testing.T) {
+		conversation := &models.Conversation{Base: models.Base{ID: uuid.New()}, UserID: userID, Title: "Imported", Provider: "chatgpt", SourceID: "abc-123"}
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetBySourceID", userID, "chatgpt", "abc-123").Return(conversation, nil)
+
+		code, resp := get(newService(convRepo), "?user_id="+userID.String()+"&provider=chatgpt&source_id=abc-123")
+
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, conversation.ID.String(), resp["data"].(map[string]interface{})["id"])
+		convRepo.AssertExpectations(t)
+	})
+
+	t.Run("Unknown source ID", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetBySourceID", userID, "chatgpt", "missing").Return(nil, nil)
+
+		code, resp := get(newService(convRepo), "?user_id="+userID.String()+"&provider=chatgpt&source_id=missing")
+
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Equal(t, "CONVERSATION_NOT_FOUND", resp["error"].(map[string]interface{})["code"])
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		for _, query := range []string{
+			"?provider=chatgpt&source_id=abc",
+			"?user_id=nope&provider=chatgpt&source_id=abc",
+			"?user_id=" + userID.String() + "&source_id=abc",
+			"?user_id=" + userID.String() + "&provider=chatgpt",
+		} {
+			service := new(MockConversationService)
+			code, _ := get(service, query)
+			assert.Equal(t, http.StatusBadRequest, code, query)
+		}
+	})
+}
+
+func TestConversationRepository_GetByUserIDInRange(t *testing.T) {
+	db := openTestDB(t)
+	user, first := createTestConversation(t, db)
+
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	require.NoError(t, db.Model(first).Update("created_at", day(1)).Error)
+
+	second := &models.Conversation{UserID: user.ID, Title: "second", Provider: "claude", SourceID: uuid.NewString(), Base: models.Base{CreatedAt: day(2).Add(23*time.Hour + 59*time.Minute + 59*time.Second)}}
+	third := &models.Conversation{UserID: user.ID, Title: "third", Provider: "claude", SourceID: uuid.NewString(), Base: models.Base{CreatedAt: day(3)}}
+	require.NoError(t, db.Create(second).Error)
+	require.NoError(t, db.Create(third).Error)
+	t.Cleanup(func() {
+		db.Unscoped().Delete(second)
+		db.Unscoped().Delete(third)
+	})
+
+	repo := repositories.NewConversationRepository(db)
+	titles := func(start, end *time.Time) ([]string, int64) {
+		conversations, total, err := repo.GetByUserIDInRange(user.ID, start, end, 1, 10, repositories.OrderCreated)
+		require.NoError(t, err)
+		result := make([]string, len(conversations))
+		for i, conversation := range conversations {
+			result[i] = conversation.Title
+		}
+		return result, total
+	}
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	t.Run("Inclusive boundaries", func(t *testing.T) {
+		// end_date 按接口的方式取当天 23:59:59
+		got, total := titles(ptr(day(2)), ptr(day(2).Add(23*time.Hour+59*time.Minute+59*time.Second)))
+		assert.Equal(t, []string{"second"}, got)
+		assert.Equal(t, int64(1), total)
+
+		got, total = titles(ptr(day(1)), ptr(day(3)))
+		assert.Len(t, got, 3)
+		assert.Equal(t, int64(3), total)
+	})
+
+	t.Run("Open ended", func(t *testing.T) {
+		got, _ := titles(ptr(day(3)), nil)
+		assert.Equal(t, []string{"third"}, got)
+
+		got, _ = titles(nil, nil)
+		assert.Len(t, got, 3)
+	})
+
+	t.Run("Empty range", func(t *testing.T) {
+		got, total := titles(ptr(day(10)), ptr(day(11)))
+		assert.Empty(t, got)
+		assert.Zero(t, total)
+
+		// 开始日期晚于结束日期时没有结果
+		got, total = titles(ptr(day(3)), ptr(day(1)))
+		assert.Empty(t, got)
+		assert.Zero(t, total)
+	})
+}
+
+func (m *MockConversationService) GetConversationsByUserID(userID uuid.UUID, startDate, endDate *time.Time, page, limit int, order string) ([]*models.Conversation, int64, error) {
+	args := m.Called(userID, startDate, endDate, page, limit, order)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*models.Conversation), args.Get(1).(int64), args.Error(2)
+}
+
+func TestGetConversations_DateRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := uuid.New()
+
+	get := func(service services.ConversationService, query string) int {
+		router := gin.New()
+		router.GET("/conversations", handlers.NewConversationHandler(service).GetConversations)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/conversations?user_id="+userID.String()+query, nil))
+		return w.Code
+	}
+
+	t.Run("Passes inclusive bounds", func(t *testing.T) {
+		start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+		service := new(MockConversationService)
+		service.On("GetConversationsByUserID", userID, &start, &end, 1, 10, repositories.OrderCreated).
+			Return([]*models.Conversation{}, int64(0), nil)
+
+		assert.Equal(t, http.StatusOK, get(service, "&start_date=2024-03-01&end_date=2024-03-31"))
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Without range", func(t *testing.T) {
+		service := new(MockConversationService)
+		service.On("GetConversationsByUserID", userID, (*time.Time)(nil), (*time.Time)(nil), 1, 10, repositories.OrderCreated).
+			Return([]*models.Conversation{}, int64(0), nil)
+
+		assert.Equal(t, http.StatusOK, get(service, ""))
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Invalid date", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get(new(MockConversationService), "&start_date=03/01/2024"))
+		assert.Equal(t, http.StatusBadRequest, get(new(MockConversationService), "&end_date=2024-13-01"))
+	})
+}
+
+func (m *MockConversationRepository) DeleteByUserID(userID uuid.UUID, batchSize int) (int64, error) {
+	args := m.Called(userID, batchSize)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestConversationService_DeleteAllForUser(t *testing.T) {
+	target := &models.User{Base: models.Base{ID: uuid.New()}, Username: "target"}
+	other := &models.User{Base: models.Base{ID: uuid.New()}, Username: "other"}
+
+	// 两个用户各有已索引的对话
+	indexed := func() *memoryIndexer {
+		indexer := &memoryIndexer{hashes: map[uuid.UUID]string{}, owners: map[uuid.UUID]uuid.UUID{}}
+		for _, owner := range []uuid.UUID{target.ID, target.ID, other.ID} {
+			id := uuid.New()
+			indexer.hashes[id] = "hash"
+			indexer.owners[id] = owner
+		}
+		return indexer
+	}
+	remainingOwners := func(indexer *memoryIndexer) []uuid.UUID {
+		var owners []uuid.UUID
+		for _, owner := range indexer.owners {
+			owners = append(owners, owner)
+		}
+		return owners
+	}
+
+	t.Run("Clears the user's database rows and documents only", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("GetByID", target.ID).Return(target, nil)
+		convRepo := new(MockConversationRepository)
+		convRepo.On("DeleteByUserID", target.ID, mock.Anything).Return(int64(2), nil)
+		indexer := indexed()
+
+		service := services.NewConversationService(convRepo, new(MockTagRepository), userRepo, indexer, nil, &config.Config{})
+		deleted, err := service.DeleteAllForUser(context.Background(), target.ID)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(2), deleted)
+		assert.Equal(t, []uuid.UUID{other.ID}, remainingOwners(indexer))
+		convRepo.AssertExpectations(t)
+	})
+
+	t.Run("User not found", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("GetByID", target.ID).Return(nil, nil)
+		convRepo := new(MockConversationRepository)
+		indexer := indexed()
+
+		service := services.NewConversationService(convRepo, new(MockTagRepository), userRepo, indexer, nil, &config.Config{})
+		_, err := service.DeleteAllForUser(context.Background(), target.ID)
+		assert.Equal(t, errors.ErrUserNotFound, err)
+		assert.Len(t, indexer.owners, 3)
+		convRepo.AssertNotCalled(t, "DeleteByUserID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Database", func(t *testing.T) {
+		db := openTestDB(t)
+		targetUser, first := createTestConversation(t, db)
+		otherUser, kept := createTestConversation(t, db)
+
+		second := &models.Conversation{UserID: targetUser.ID, Title: "second", Provider: "claude", SourceID: uuid.NewString()}
+		require.NoError(t, db.Create(second).Error)
+		for _, conversation := range []*models.Conversation{first, second, kept} {
+			require.NoError(t, db.Create(&models.Message{ConversationID: conversation.ID, Role: "user", Content: "hello"}).Error)
+		}
+		t.Cleanup(func() { db.Unscoped().Delete(second) })
+		// 软删除的对话同样被永久删除
+		require.NoError(t, db.Delete(second).Error)
+
+		indexer := &memoryIndexer{hashes: map[uuid.UUID]string{}, owners: map[uuid.UUID]uuid.UUID{}}
+		for _, conversation := range []*models.Conversation{first, second, kept} {
+			indexer.hashes[conversation.ID] = "hash"
+			indexer.owners[conversation.ID] = conversation.UserID
+		}
+
+		service := services.NewConversationService(repositories.NewConversationRepository(db), repositories.NewTagRepository(db),
+			repositories.NewUserRepository(db), indexer, nil, &config.Config{})
+		deleted, err := service.DeleteAllForUser(context.Background(), targetUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), deleted)
+
+		count := func(model interface{}, query string, args ...interface{}) int64 {
+			var n int64
+			require.NoError(t, db.Unscoped().Model(model).Where(query, args...).Count(&n).Error)
+			return n
+		}
+		assert.Zero(t, count(&models.Conversation{}, "user_id = ?", targetUser.ID))
+		assert.Zero(t, count(&models.Message{}, "conversation_id IN ?", []uuid.UUID{first.ID, second.ID}))
+		assert.Equal(t, int64(1), count(&models.Conversation{}, "user_id = ?", otherUser.ID))
+		assert.Equal(t, int64(1), count(&models.Message{}, "conversation_id = ?", kept.ID))
+		assert.Equal(t, []uuid.UUID{otherUser.ID}, remainingOwners(indexer))
+	})
+}
+
+func TestConversationHandler_DeleteUserConversations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := uuid.New()
+
+	do := func(service services.ConversationService, id, token string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.DELETE("/users/:id/conversations", middleware.AdminAuthMiddleware("secret"), handlers.NewConversationHandler(service).DeleteUserConversations)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodDelete, "/users/"+id+"/conversations", nil)
+		if token != "" {
+			req.Header.Set(middleware.AdminTokenHeader, token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Reports the deleted count", func(t *testing.T) {
+		service := new(MockConversationService)
+		service.On("DeleteAllForUser", mock.Anything, userID).Return(int64(7), nil)
+
+		w := do(service, userID.String(), "secret")
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data response.BulkDeleteResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, userID, resp.Data.ID)
+		assert.True(t, resp.Data.Deleted)
+		assert.Equal(t, int64(7), resp.Data.Count)
+	})
+
+	t.Run("Requires the admin token", func(t *testing.T) {
+		service := new(MockConversationService)
+		assert.Equal(t, http.StatusUnauthorized, do(service, userID.String(), "").Code)
+		service.AssertNotCalled(t, "DeleteAllForUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		service := new(MockConversationService)
+		service.On("DeleteAllForUser", mock.Anything, userID).Return(int64(0), errors.ErrUserNotFound)
+		assert.Equal(t, http.StatusNotFound, do(service, userID.String(), "secret").Code)
+		assert.Equal(t, http.StatusBadRequest, do(service, "nope", "secret").Code)
+	})
+}
+
+func (m *MockConversationService) DeleteAllForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestConversationRepository_Merge(t *testing.T) {
+	db := openTestDB(t)
+	user, target := createTestConversation(t, db)
+	source := &models.Conversation{UserID: user.ID, Title: "Continued", Provider: "openai", SourceID: uuid.NewString()}
+	require.NoError(t, db.Create(source).Error)
+	t.Cleanup(func() {
+		db.Unscoped().Where("conversation_id IN ?", []uuid.UUID{target.ID, source.ID}).Delete(&models.Message{})
+		db.Unscoped().Delete(source)
+	})
+
+	shared := &models.Tag{Name: "merge-shared-" + uuid.NewString()[:8]}
+	extra := &models.Tag{Name: "merge-extra-" + uuid.NewString()[:8]}
+	require.NoError(t, db.Create(shared).Error)
+	require.NoError(t, db.Create(extra).Error)
+	require.NoError(t, db.Model(target).Association("Tags").Append(shared))
+	require.NoError(t, db.Model(source).Association("Tags").Append([]*models.Tag{shared, extra}))
+	t.Cleanup(func() { db.Unscoped().Delete([]*models.Tag{shared, extra}) })
+
+	// 来源对话的消息与目标对话交错，"b" 与 "c" 时间相同，目标对话的消息排在前面
+	base := time.Now().UTC().Truncate(time.Microsecond).Add(-time.Hour)
+	for _, m := range []struct {
+		conversation *models.Conversation
+		content      string
+		minute       int
+		sequence     int
+	}{
+		{target, "a", 0, 1},
+		{target, "b", 2, 2},
+		{target, "e", 5, 3},
+		{source, "c", 2, 1},
+		{source, "d", 3, 2},
+	} {
+		require.NoError(t, db.Create(&models.Message{
+			Base:           models.Base{CreatedAt: base.Add(time.Duration(m.minute) * time.Minute)},
+			ConversationID: m.conversation.ID,
+			Role:           "user",
+			Content:        m.content,
+			SourceID:       uuid.NewString(),
+			Sequence:       m.sequence,
+		}).Error)
+	}
+
+	// 目标对话中已删除的消息仍占用 source_id，来源对话中相同 source_id 的消息需要改名
+	duplicated := uuid.NewString()
+	deleted := &models.Message{ConversationID: target.ID, Role: "user", Content: "deleted", SourceID: duplicated, Sequence: 4}
+	require.NoError(t, db.Create(deleted).Error)
+	require.NoError(t, db.Delete(deleted).Error)
+	require.NoError(t, db.Model(&models.Message{}).Where("conversation_id = ? AND content = ?", source.ID, "d").
+		Update("source_id", duplicated).Error)
+
+	repo := repositories.NewConversationRepository(db)
+
+	t.Run("Other users' conversations are not merged", func(t *testing.T) {
+		_, other := createTestConversation(t, db)
+		merged, err := repo.Merge(target.ID, []uuid.UUID{other.ID}, user.ID)
+		require.NoError(t, err)
+		assert.Nil(t, merged)
+
+		var count int64
+		require.NoError(t, db.Model(&models.Message{}).Where("conversation_id = ?", target.ID).Count(&count).Error)
+		assert.Equal(t, int64(3), count)
+	})
+
+	merged, err := repo.Merge(target.ID, []uuid.UUID{source.ID}, user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, merged)
+
+	var contents []string
+	for i, m := range merged.Messages {
+		contents = append(contents, m.Content)
+		assert.Equal(t, i+1, m.Sequence)
+		assert.Equal(t, target.ID, m.ConversationID)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, contents)
+	assert.Equal(t, "merged:"+source.ID.String()+":"+duplicated, merged.Messages[3].SourceID)
+
+	var tagIDs []uuid.UUID
+	for _, tag := range merged.Tags {
+		tagIDs = append(tagIDs, tag.ID)
+	}
+	assert.ElementsMatch(t, []uuid.UUID{shared.ID, extra.ID}, tagIDs)
+	require.NotNil(t, merged.LastMessageAt)
+	assert.True(t, merged.LastMessageAt.Equal(base.Add(5*time.Minute)))
+
+	// 来源对话被物理删除，不再有消息，source_id 可以重新导入
+	gone, err := repo.GetByID(source.ID)
+	require.NoError(t, err)
+	assert.Nil(t, gone)
+	exists, err := repo.SourceIDExists(user.ID, source.SourceID, uuid.Nil)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	var remaining int64
+	require.NoError(t, db.Unscoped().Model(&models.Message{}).Where("conversation_id = ?", source.ID).Count(&remaining).Error)
+	assert.Zero(t, remaining)
+}
+
+func TestConversationService_Merge(t *testing.T) {
+	userID := uuid.New()
+	target := &models.Conversation{Base: models.Base{ID: uuid.New()}, UserID: userID, Title: "Part 1"}
+	source := &models.Conversation{Base: models.Base{ID: uuid.New()}, UserID: userID, Title: "Part 2"}
+	merged := &models.Conversation{
+		Base:   target.Base,
+		UserID: userID,
+		Title:  "Part 1",
+		Messages: []models.Message{
+			{Base: models.Base{ID: uuid.New()}, Role: "user", Content: "one", Sequence: 1},
+			{Base: models.Base{ID: uuid.New()}, Role: "user", Content: "two", Sequence: 2},
+		},
+	}
+
+	newService := func(convRepo *MockConversationRepository, indexer *MockIndexer) services.ConversationService {
+		return services.NewConversationService(convRepo, new(MockTagRepository), new(MockUserRepository), indexer, nil, &config.Config{})
+	}
+
+	t.Run("Merges, re-indexes the target and removes the sources from ES", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", target.ID).Return(target, nil)
+		convRepo.On("GetByID", source.ID).Return(source, nil)
+		convRepo.On("Merge", target.ID, []uuid.UUID{source.ID}, userID).Return(merged, nil)
+
+		indexer := new(MockIndexer)
+		indexer.On("IndexConversation", mock.MatchedBy(func(doc *models.ConversationDocument) bool {
+			return doc.ID == target.ID && len(doc.Messages) == 2
+		})).Return(nil)
+		indexer.On("DeleteConversation", source.ID).Return(nil)
+
+		// 重复的来源只合并一次
+		result, err := newService(convRepo, indexer).Merge(context.Background(), target.ID, []uuid.UUID{source.ID, source.ID})
+		require.NoError(t, err)
+
+		assert.Equal(t, target.ID, result.ID)
+		convRepo.AssertExpectations(t)
+		indexer.AssertExpectations(t)
+	})
+
+	t.Run("Different users", func(t *testing.T) {
+		foreign := &models.Conversation{Base: models.Base{ID: uuid.New()}, UserID: uuid.New()}
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", target.ID).Return(target, nil)
+		convRepo.On("GetByID", foreign.ID).Return(foreign, nil)
+
+		_, err := newService(convRepo, new(MockIndexer)).Merge(context.Background(), target.ID, []uuid.UUID{foreign.ID})
+
+		assert.Equal(t, errors.ErrConversationOwnerMismatch, err)
+		convRepo.AssertNotCalled(t, "Merge", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Target among the sources", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		_, err := newService(convRepo, new(MockIndexer)).Merge(context.Background(), target.ID, []uuid.UUID{source.ID, target.ID})
+
+		assert.Equal(t, errors.ErrInvalidMerge, err)
+		convRepo.AssertNotCalled(t, "GetByID", mock.Anything)
+	})
+
+	t.Run("Source not found", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", target.ID).Return(target, nil)
+		convRepo.On("GetByID", source.ID).Return(nil, nil)
+
+		_, err := newService(convRepo, new(MockIndexer)).Merge(context.Background(), target.ID, []uuid.UUID{source.ID})
+		assert.Equal(t, errors.ErrConversationNotFound, err)
+	})
+
+	t.Run("Removed before the transaction", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", target.ID).Return(target, nil)
+		convRepo.On("GetByID", source.ID).Return(source, nil)
+		convRepo.On("Merge", target.ID, []uuid.UUID{source.ID}, userID).Return(nil, nil)
+
+		indexer := new(MockIndexer)
+		_, err := newService(convRepo, indexer).Merge(context.Background(), target.ID, []uuid.UUID{source.ID})
+
+		assert.Equal(t, errors.ErrConversationNotFound, err)
+		indexer.AssertNotCalled(t, "IndexConversation", mock.Anything)
+	})
+}
+
+func TestConversationHandler_MergeConversations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	targetID := uuid.New()
+	sourceID := uuid.New()
+
+	do := func(service services.ConversationService, body string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.POST("/conversations/merge", handlers.NewConversationHandler(service).MergeConversations)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/conversations/merge", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	body := fmt.Sprintf(`{"target_id":%q,"source_ids":[%q]}`, targetID, sourceID)
+
+	t.Run("Returns the merged conversation", func(t *testing.T) {
+		service := new(MockConversationService)
+		service.On("Merge", mock.Anything, targetID, []uuid.UUID{sourceID}).
+			Return(&models.Conversation{Base: models.Base{ID: targetID}, Title: "Merged"}, nil)
+
+		w := do(service, body)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data response.ConversationResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, targetID, resp.Data.ID)
+	})
+
+	t.Run("Invalid requests", func(t *testing.T) {
+		service := new(MockConversationService)
+		for _, invalid := range []string{
+			`{"target_id":"` + targetID.String() + `"}`,
+			`{"target_id":"` + targetID.String() + `","source_ids":[]}`,
+			`{"source_ids":["` + sourceID.String() + `"]}`,
+			`{"target_id":"nope","source_ids":["` + sourceID.String() + `"]}`,
+		} {
+			assert.Equal(t, http.StatusBadRequest, do(service, invalid).Code, invalid)
+		}
+		service.AssertNotCalled(t, "Merge", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for err, code := range map[error]int{
+			errors.ErrConversationOwnerMismatch: http.StatusBadRequest,
+			errors.ErrInvalidMerge:              http.StatusBadRequest,
+			errors.ErrConversationNotFound:      http.StatusNotFound,
+		} {
+			service := new(MockConversationService)
+			service.On("Merge", mock.Anything, targetID, []uuid.UUID{sourceID}).Return(nil, err)
+			assert.Equal(t, code, do(service, body).Code, err.Error())
+		}
+	})
+}
+
+func (m *MockConversationService) Merge(ctx context.Context, targetID uuid.UUID, sourceIDs []uuid.UUID) (*models.Conversation, error) {
+	args := m.Called(ctx, targetID, sourceIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Conversation), args.Error(1)
+}
@@ -0,0 +1,94 @@
+package test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/infra/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestDatabaseConfig_GetReplicaDSNs(t *testing.T) {
+	base := config.DatabaseConfig{
+		Host:     "primary",
+		Port:     5432,
+		User:     "app",
+		Password: "secret",
+		DBName:   "chat_assistant",
+		SSLMode:  "disable",
+		Timezone: "UTC",
+	}
+
+	t.Run("No replicas routes everything to the primary", func(t *testing.T) {
+		assert.Nil(t, base.GetReplicaDSNs())
+	})
+
+	t.Run("Replicas share primary credentials", func(t *testing.T) {
+		cfg := base
+		cfg.ReadReplicas = []string{"replica-1", "replica-2:6432"}
+
+		assert.Equal(t, []string{
+			"host=replica-1 port=5432 user=app password=secret dbname=chat_assistant sslmode=disable TimeZone=UTC",
+			"host=replica-2 port=6432 user=app password=secret dbname=chat_assistant sslmode=disable TimeZone=UTC",
+		}, cfg.GetReplicaDSNs())
+		// 主库 DSN 不受影响
+		assert.Contains(t, cfg.GetDSN(), "host=primary port=5432")
+	})
+
+	t.Run("ForcePrimary ignores replicas", func(t *testing.T) {
+		cfg := base
+		cfg.ReadReplicas = []string{"replica-1"}
+		cfg.ForcePrimary = true
+
+		assert.Nil(t, cfg.GetReplicaDSNs())
+	})
+}
+
+func TestDatabaseConnectWithRetry(t *testing.T) {
+	cfg := &config.DatabaseConfig{ConnectRetries: 3, ConnectRetryInterval: time.Millisecond}
+	refused := errors.New("connection refused")
+
+	t.Run("Retries until the database is ready", func(t *testing.T) {
+		want := &gorm.DB{}
+		calls := 0
+		db, err := database.ConnectWithRetry(cfg, func() (*gorm.DB, error) {
+			calls++
+			if calls < 3 {
+				return nil, refused
+			}
+			return want, nil
+		})
+
+		require.NoError(t, err)
+		assert.Same(t, want, db)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Gives up after the configured retries", func(t *testing.T) {
+		calls := 0
+		db, err := database.ConnectWithRetry(cfg, func() (*gorm.DB, error) {
+			calls++
+			return nil, refused
+		})
+
+		assert.Nil(t, db)
+		assert.ErrorIs(t, err, refused)
+		assert.Equal(t, 4, calls) // 首次连接 + 3 次重试
+	})
+
+	t.Run("Zero retries fails on the first error", func(t *testing.T) {
+		calls := 0
+		_, err := database.ConnectWithRetry(&config.DatabaseConfig{}, func() (*gorm.DB, error) {
+			calls++
+			return nil, refused
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,530 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/infra/elasticsearch"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFlakyES starts a server that answers 503 until healthy is set
+func newFlakyES(t *testing.T) (*httptest.Server, *atomic.Bool) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, &healthy
+}
+
+func TestConnectWithRetry(t *testing.T) {
+	t.Run("Available at boot", func(t *testing.T) {
+		server, healthy := newFlakyES(t)
+		healthy.Store(true)
+
+		client, err := elasticsearch.ConnectWithRetry(&elasticsearch.Config{Hosts: []string{server.URL}}, time.Second, time.Second)
+
+		require.NoError(t, err)
+		assert.True(t, client.Available())
+	})
+
+	t.Run("Unavailable at boot then recovers", func(t *testing.T) {
+		server, healthy := newFlakyES(t)
+
+		start := time.Now()
+		client, err := elasticsearch.ConnectWithRetry(&elasticsearch.Config{Hosts: []string{server.URL}}, 300*time.Millisecond, 20*time.Millisecond)
+
+		// 不报错，以降级模式启动
+		require.NoError(t, err)
+		require.NotNil(t, client)
+		assert.False(t, client.Available())
+		assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+
+		healthy.Store(true)
+		assert.Eventually(t, client.Available, 2*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Recovers during startup retries", func(t *testing.T) {
+		server, healthy := newFlakyES(t)
+		time.AfterFunc(150*time.Millisecond, func() { healthy.Store(true) })
+
+		client, err := elasticsearch.ConnectWithRetry(&elasticsearch.Config{Hosts: []string{server.URL}}, 5*time.Second, time.Second)
+
+		require.NoError(t, err)
+		assert.True(t, client.Available())
+	})
+}
+
+// settingsES is a minimal Elasticsearch stand-in that keeps the settings of a single index
+type settingsES struct {
+	settings  map[string]string
+	refreshes int
+}
+
+func newSettingsES(t *testing.T, index string, settings map[string]string) (*settingsES, *elasticsearch.Client) {
+	stub := &settingsES{settings: settings}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/"+index+"/_settings"):
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				index: map[string]interface{}{
+					"settings": stub.settings,
+					"defaults": map[string]string{"index.refresh_interval": "1s"},
+				},
+			})
+		case r.Method == http.MethodPut && r.URL.Path == "/"+index+"/_settings":
+			var update map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&update))
+			for name, value := range update {
+				stub.settings[name] = fmt.Sprint(value)
+			}
+			w.Write([]byte(`{"acknowledged":true}`))
+		case r.URL.Path == "/"+index+"/_refresh":
+			stub.refreshes++
+			w.Write([]byte(`{}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := elasticsearch.NewClient(&elasticsearch.Config{
+		Hosts: []string{server.URL},
+		Index: elasticsearch.IndexConfig{Conversations: index},
+	})
+	require.NoError(t, err)
+	return stub, client
+}
+
+func TestInitializer_WithBulkSettings(t *testing.T) {
+	t.Run("Settings toggled around sync", func(t *testing.T) {
+		// refresh_interval 未显式设置，读取集群默认值
+		stub, client := newSettingsES(t, "conversations", map[string]string{"index.number_of_replicas": "1"})
+		initializer := elasticsearch.NewInitializer(client, nil)
+
+		synced := false
+		err := initializer.WithBulkSettings(context.Background(), func() error {
+			synced = true
+			// 有限的刷新间隔，API 的 wait_for 写入不会阻塞到同步结束
+			assert.Equal(t, "30s", stub.settings["index.refresh_interval"])
+			assert.Equal(t, "0", stub.settings["index.number_of_replicas"])
+			assert.Zero(t, stub.refreshes)
+			return nil
+		})
+
+		require.NoError(t, err)
+		assert.True(t, synced)
+		assert.Equal(t, "1s", stub.settings["index.refresh_interval"])
+		assert.Equal(t, "1", stub.settings["index.number_of_replicas"])
+		assert.Equal(t, 1, stub.refreshes)
+	})
+
+	t.Run("Settings restored when sync fails", func(t *testing.T) {
+		stub, client := newSettingsES(t, "conversations", map[string]string{
+			"index.refresh_interval":   "5s",
+			"index.number_of_replicas": "2",
+		})
+		initializer := elasticsearch.NewInitializer(client, nil)
+		syncErr := errors.New("bulk failed")
+
+		err := initializer.WithBulkSettings(context.Background(), func() error {
+			return syncErr
+		})
+
+		assert.ErrorIs(t, err, syncErr)
+		assert.Equal(t, "5s", stub.settings["index.refresh_interval"])
+		assert.Equal(t, "2", stub.settings["index.number_of_replicas"])
+		assert.Equal(t, 1, stub.refreshes)
+	})
+}
+
+func TestNewClient_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Run("Untrusted certificate is rejected", func(t *testing.T) {
+		_, err := elasticsearch.NewClient(&elasticsearch.Config{Hosts: []string{server.URL}})
+		assert.Error(t, err)
+	})
+
+	t.Run("CA certificate file", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		require.NoError(t, os.WriteFile(caFile, pemBytes, 0o600))
+
+		_, err := elasticsearch.NewClient(&elasticsearch.Config{Hosts: []string{server.URL}, CACertFile: caFile})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Insecure skip verify", func(t *testing.T) {
+		_, err := elasticsearch.NewClient(&elasticsearch.Config{Hosts: []string{server.URL}, InsecureSkipVerify: true})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Missing CA certificate file", func(t *testing.T) {
+		_, err := elasticsearch.NewClient(&elasticsearch.Config{Hosts: []string{server.URL}, CACertFile: filepath.Join(t.TempDir(), "missing.pem")})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read CA certificate")
+	})
+}
+
+func TestConversationMapping_SourceAnalyzer(t *testing.T) {
+	analyzers := func(mapping string) map[string]interface{} {
+		var parsed struct {
+			Mappings struct {
+				Properties map[string]struct {
+					Analyzer   string `json:"analyzer"`
+					Properties map[string]struct {
+						Analyzer string `json:"analyzer"`
+					} `json:"properties"`
+				} `json:"properties"`
+			} `json:"mappings"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(mapping), &parsed))
+		properties := parsed.Mappings.Properties
+		return map[string]interface{}{
+			"title":                   properties["title"].Analyzer,
+			"source_title":            properties["source_title"].Analyzer,
+			"messages.content":        properties["messages"].Properties["content"].Analyzer,
+			"messages.source_content": properties["messages"].Properties["source_content"].Analyzer,
+		}
+	}
+
+	t.Run("Defaults to the normalized analyzer", func(t *testing.T) {
+		assert.Equal(t, map[string]interface{}{
+			"title":                   "standard",
+			"source_title":            "standard",
+			"messages.content":        "standard",
+			"messages.source_content": "standard",
+		}, analyzers(elasticsearch.ConversationMapping()))
+	})
+
+	t.Run("Distinct analyzer for source fields", func(t *testing.T) {
+		mapping := elasticsearch.ConversationMappingWithOptions(elasticsearch.MappingOptions{SourceAnalyzer: "cjk"})
+		assert.Equal(t, map[string]interface{}{
+			"title":                   "standard",
+			"source_title":            "cjk",
+			"messages.content":        "standard",
+			"messages.source_content": "cjk",
+		}, analyzers(mapping))
+	})
+
+	t.Run("Message index", func(t *testing.T) {
+		var parsed struct {
+			Mappings struct {
+				Properties map[string]struct {
+					Type     string `json:"type"`
+					Analyzer string `json:"analyzer"`
+					Fields   struct {
+						Exact struct {
+							Analyzer string `json:"analyzer"`
+						} `json:"exact"`
+					} `json:"fields"`
+				} `json:"properties"`
+			} `json:"mappings"`
+		}
+		mapping := elasticsearch.MessageMappingWithOptions(elasticsearch.MappingOptions{SourceAnalyzer: "cjk"})
+		require.NoError(t, json.Unmarshal([]byte(mapping), &parsed))
+		assert.Equal(t, "standard", parsed.Mappings.Properties["content"].Analyzer)
+		assert.Equal(t, "cjk", parsed.Mappings.Properties["source_content"].Analyzer)
+
+		// 独立消息索引按 user_id 过滤，与对话索引一样带 exact 子字段
+		assert.Equal(t, "keyword", parsed.Mappings.Properties["user_id"].Type)
+		assert.Equal(t, elasticsearch.DefaultExactAnalyzer, parsed.Mappings.Properties["content"].Fields.Exact.Analyzer)
+		assert.Equal(t, elasticsearch.DefaultExactAnalyzer, parsed.Mappings.Properties["source_content"].Fields.Exact.Analyzer)
+	})
+}
+
+func TestConversationMapping_ExactAnalyzer(t *testing.T) {
+	var parsed struct {
+		Mappings struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"mappings"`
+		Settings struct {
+			Analysis struct {
+				Analyzer map[string]map[string]interface{} `json:"analyzer"`
+			} `json:"analysis"`
+		} `json:"settings"`
+	}
+	type field struct {
+		Analyzer string `json:"analyzer"`
+		Fields   struct {
+			Exact struct {
+				Analyzer string `json:"analyzer"`
+			} `json:"exact"`
+		} `json:"fields"`
+		Properties map[string]*field `json:"properties"`
+	}
+	exactAnalyzers := func(mapping string) map[string]string {
+		require.NoError(t, json.Unmarshal([]byte(mapping), &parsed))
+		properties := make(map[string]*field)
+		for name, raw := range parsed.Mappings.Properties {
+			var f field
+			require.NoError(t, json.Unmarshal(raw, &f))
+			properties[name] = &f
+		}
+		return map[string]string{
+			"title":                   properties["title"].Fields.Exact.Analyzer,
+			"source_title":            properties["source_title"].Fields.Exact.Analyzer,
+			"messages.content":        properties["messages"].Properties["content"].Fields.Exact.Analyzer,
+			"messages.source_content": properties["messages"].Properties["source_content"].Fields.Exact.Analyzer,
+			"tags.name":               properties["tags"].Properties["name"].Fields.Exact.Analyzer,
+		}
+	}
+
+	t.Run("Defaults to the phrase analyzer", func(t *testing.T) {
+		for path, analyzer := range exactAnalyzers(elasticsearch.ConversationMapping()) {
+			assert.Equal(t, elasticsearch.DefaultExactAnalyzer, analyzer, path)
+		}
+		// 保留词序的 standard 分词加小写，不再用 keyword 把整个字段作为一个词
+		assert.Equal(t, map[string]interface{}{
+			"type":      "custom",
+			"tokenizer": "standard",
+			"filter":    []interface{}{"lowercase"},
+		}, parsed.Settings.Analysis.Analyzer[elasticsearch.DefaultExactAnalyzer])
+	})
+
+	t.Run("Configured analyzer", func(t *testing.T) {
+		mapping := elasticsearch.ConversationMappingWithOptions(elasticsearch.MappingOptions{ExactAnalyzer: "cjk"})
+		for path, analyzer := range exactAnalyzers(mapping) {
+			assert.Equal(t, "cjk", analyzer, path)
+		}
+	})
+}
+
+func TestWarmUp(t *testing.T) {
+	type request struct {
+		method, path, query string
+		body                map[string]interface{}
+	}
+	newES := func(t *testing.T, searchStatus int) (*elasticsearch.Client, *[]request) {
+		var requests []request
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Elastic-Product", "Elasticsearch")
+			w.Header().Set("Content-Type", "application/json")
+			req := request{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery}
+			if r.ContentLength > 0 {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&req.body))
+			}
+			requests = append(requests, req)
+
+			if strings.HasSuffix(r.URL.Path, "/_search") {
+				w.WriteHeader(searchStatus)
+				w.Write([]byte(`{"hits":{"total":{"value":0},"hits":[]}}`))
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		client, err := elasticsearch.NewClient(&elasticsearch.Config{Hosts: []string{server.URL}})
+		require.NoError(t, err)
+		// 忽略创建客户端时的 ping
+		requests = nil
+		return client, &requests
+	}
+	newConfig := func(enabled bool) *config.Config {
+		cfg := &config.Config{}
+		cfg.Elasticsearch.Index.Conversations = "conversations"
+		cfg.Elasticsearch.WarmUp = config.ElasticsearchWarmUpConfig{Enabled: enabled, Timeout: time.Second}
+		return cfg
+	}
+
+	t.Run("Issues a ping and a match_all search when enabled", func(t *testing.T) {
+		client, requests := newES(t, http.StatusOK)
+		warmUp := elasticsearch.NewWarmUpFromConfig(client, newConfig(true))
+		require.NotNil(t, warmUp)
+
+		require.NoError(t, warmUp.Start(context.Background()))
+		require.Len(t, *requests, 2)
+		assert.Equal(t, "/", (*requests)[0].path)
+
+		search := (*requests)[1]
+		assert.Equal(t, "/conversations/_search", search.path)
+		assert.Contains(t, search.query, "request_cache=true")
+		assert.Equal(t, float64(0), search.body["size"])
+		assert.Equal(t, map[string]interface{}{"match_all": map[string]interface{}{}}, search.body["query"])
+		assert.NoError(t, warmUp.Stop(context.Background()))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		client, _ := newES(t, http.StatusOK)
+		assert.Nil(t, elasticsearch.NewWarmUpFromConfig(client, newConfig(false)))
+	})
+
+	t.Run("Failures do not stop startup", func(t *testing.T) {
+		client, requests := newES(t, http.StatusInternalServerError)
+		assert.NoError(t, elasticsearch.NewWarmUp(client, "conversations", time.Second).Start(context.Background()))
+		assert.NotEmpty(t, *requests)
+	})
+}
+
+func TestAutoInit(t *testing.T) {
+	// newES 模拟已有 existing 索引的集群，记录创建索引的请求
+	newES := func(t *testing.T, existing ...string) (*elasticsearch.Client, *[]string) {
+		indices := map[string]bool{}
+		for _, index := range existing {
+			indices[index] = true
+		}
+		var created []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Elastic-Product", "Elasticsearch")
+			w.Header().Set("Content-Type", "application/json")
+			index := strings.Trim(r.URL.Path, "/")
+			switch {
+			case r.URL.Path == "/_cluster/health":
+				w.Write([]byte(`{"status":"green"}`))
+			case index == "":
+				w.Write([]byte(`{}`))
+			case r.Method == http.MethodHead && !indices[index]:
+				w.WriteHeader(http.StatusNotFound)
+			case r.Method == http.MethodPut:
+				var mapping map[string]interface{}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&mapping))
+				indices[index] = true
+				created = append(created, index)
+				w.Write([]byte(`{"acknowledged":true}`))
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		client, err := elasticsearch.NewClient(&elasticsearch.Config{
+			Hosts: []string{server.URL},
+			Index: elasticsearch.IndexConfig{Conversations: "conversations", Messages: "messages"},
+		})
+		require.NoError(t, err)
+		return client, &created
+	}
+
+	t.Run("Creates missing indices", func(t *testing.T) {
+		client, created := newES(t)
+		require.NoError(t, elasticsearch.NewAutoInit(client).Start(context.Background()))
+		assert.Equal(t, []string{"conversations", "messages"}, *created)
+	})
+
+	t.Run("Leaves existing indices alone", func(t *testing.T) {
+		client, created := newES(t, "conversations")
+		initializer := elasticsearch.NewInitializer(client, nil)
+
+		indices, err := initializer.EnsureIndexes(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"messages"}, indices)
+
+		// 再次启动时全部已存在，不再创建
+		require.NoError(t, elasticsearch.NewAutoInit(client).Start(context.Background()))
+		assert.Equal(t, []string{"messages"}, *created)
+	})
+
+	t.Run("Initializes once Elasticsearch is back", func(t *testing.T) {
+		var healthy atomic.Bool
+		var mu sync.Mutex
+		var created []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Elastic-Product", "Elasticsearch")
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case !healthy.Load():
+				w.WriteHeader(http.StatusServiceUnavailable)
+			case r.URL.Path == "/_cluster/health":
+				w.Write([]byte(`{"status":"green"}`))
+			case r.Method == http.MethodHead && r.URL.Path != "/":
+				w.WriteHeader(http.StatusNotFound)
+			case r.Method == http.MethodPut:
+				mu.Lock()
+				created = append(created, strings.Trim(r.URL.Path, "/"))
+				mu.Unlock()
+				w.Write([]byte(`{"acknowledged":true}`))
+			default:
+				w.Write([]byte(`{}`))
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		client, err := elasticsearch.ConnectWithRetry(&elasticsearch.Config{
+			Hosts: []string{server.URL},
+			Index: elasticsearch.IndexConfig{Conversations: "conversations", Messages: "messages"},
+		}, 50*time.Millisecond, 20*time.Millisecond)
+		require.NoError(t, err)
+		require.False(t, client.Available())
+
+		autoInit := elasticsearch.NewAutoInit(client)
+		t.Cleanup(func() { autoInit.Stop(context.Background()) })
+		require.NoError(t, autoInit.Start(context.Background()))
+
+		healthy.Store(true)
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(created) == 2
+		}, 2*time.Second, 10*time.Millisecond)
+		mu.Lock()
+		assert.ElementsMatch(t, []string{"conversations", "messages"}, created)
+		mu.Unlock()
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		client, _ := newES(t)
+		assert.Nil(t, elasticsearch.NewAutoInitFromConfig(client, &config.Config{}))
+
+		cfg := &config.Config{}
+		cfg.Elasticsearch.AutoInit = true
+		assert.NotNil(t, elasticsearch.NewAutoInitFromConfig(client, cfg))
+	})
+}
+
+func TestClient_DeleteByQuery(t *testing.T) {
+	var path, query string
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/_delete_by_query") {
+			path, query = r.URL.Path, r.URL.RawQuery
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			w.Write([]byte(`{"deleted":3,"version_conflicts":0}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := elasticsearch.NewClient(&elasticsearch.Config{Hosts: []string{server.URL}})
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	deleted, err := client.DeleteByQuery(context.Background(), "conversations",
+		[]byte(`{"query":{"term":{"user_id":"`+userID.String()+`"}}}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(3), deleted)
+	assert.Equal(t, "/conversations/_delete_by_query", path)
+	assert.Contains(t, query, "conflicts=proceed")
+	assert.Contains(t, query, "refresh=true")
+	assert.Equal(t, userID.String(), body["query"].(map[string]interface{})["term"].(map[string]interface{})["user_id"])
+}
@@ -0,0 +1,142 @@
+package test
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/i18n"
+	"chat-assistant-backend/internal/middleware"
+	"chat-assistant-backend/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newI18nRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.I18nMiddleware(config.I18nConfig{
+		DefaultLanguage:    "en",
+		SupportedLanguages: []string{"en", "zh"},
+	}))
+	router.GET("/conversations/missing", func(c *gin.Context) {
+		response.NotFound(c, "CONVERSATION_NOT_FOUND", "Conversation not found", "No conversation found with the specified ID")
+	})
+	router.GET("/conversations/invalid", func(c *gin.Context) {
+		response.BadRequest(c, "INVALID_UUID", "Invalid conversation ID format", "")
+	})
+	return router
+}
+
+func TestI18nMiddleware_LocalizesErrorMessages(t *testing.T) {
+	router := newI18nRouter()
+
+	testCases := []struct {
+		name            string
+		acceptLanguage  string
+		expectedLang    string
+		expectedMessage string
+	}{
+		{"Chinese request", "zh-CN,zh;q=0.9,en;q=0.8", "zh", "对话不存在"},
+		{"English request", "en-US", "en", "Conversation not found"},
+		{"Unsupported language falls back to default", "fr-FR", "en", "Conversation not found"},
+		{"Missing header falls back to default", "", "en", "Conversation not found"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/conversations/missing", nil)
+			if tc.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tc.acceptLanguage)
+			}
+			router.ServeHTTP(w, req)
+
+			var resp response.Response
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.NotNil(t, resp.Error)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, tc.expectedLang, w.Header().Get("Content-Language"))
+			assert.Equal(t, "CONVERSATION_NOT_FOUND", resp.Error.Code)
+			assert.Equal(t, tc.expectedMessage, resp.Error.Message)
+		})
+	}
+}
+
+func TestI18nMiddleware_KeepsEnglishMessage(t *testing.T) {
+	router := newI18nRouter()
+
+	testCases := []struct {
+		acceptLanguage  string
+		expectedMessage string
+	}{
+		// 英文保留接口给出的具体信息，不替换为通用翻译 "Invalid ID format"
+		{"en-US", "Invalid conversation ID format"},
+		{"zh-CN", "ID 格式无效"},
+	}
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/conversations/invalid", nil)
+		req.Header.Set("Accept-Language", tc.acceptLanguage)
+		router.ServeHTTP(w, req)
+
+		var resp response.Response
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, tc.expectedMessage, resp.Error.Message, tc.acceptLanguage)
+	}
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	supported := []string{"en", "zh"}
+
+	assert.Equal(t, "zh", i18n.Negotiate("fr;q=0.9, zh-TW;q=0.8", supported, "en"))
+	assert.Equal(t, "en", i18n.Negotiate("zh;q=0.5, en;q=0.7", supported, "zh"))
+	assert.Equal(t, "en", i18n.Negotiate("zh;q=0, *", supported, "en"))
+}
+
+// TestI18n_ChineseCoversErrorCodes checks that every ErrCode* constant and every code the
+// handlers pass as a literal has a Chinese translation
+func TestI18n_ChineseCoversErrorCodes(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "../internal/errors/errors.go", nil, 0)
+	require.NoError(t, err)
+
+	var codes []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		for i, name := range spec.Names {
+			if !strings.HasPrefix(name.Name, "ErrCode") || i >= len(spec.Values) {
+				continue
+			}
+			if lit, ok := spec.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				code, err := strconv.Unquote(lit.Value)
+				require.NoError(t, err)
+				codes = append(codes, code)
+			}
+		}
+		return true
+	})
+	require.NotEmpty(t, codes)
+
+	// 只在 handler 中以字面量出现的错误码
+	codes = append(codes, "INVALID_CURSOR", "INVALID_FORMAT", "SEARCH_UNAVAILABLE")
+
+	for _, code := range codes {
+		_, ok := i18n.Translate(i18n.LanguageChinese, code)
+		assert.True(t, ok, "missing zh translation for %s", code)
+	}
+}
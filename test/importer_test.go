@@ -0,0 +1,1410 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/importer"
+	importerrors "chat-assistant-backend/internal/importer/errors"
+	"chat-assistant-backend/internal/importer/parsers"
+	chatgptParser "chat-assistant-backend/internal/importer/parsers/chatgpt"
+	claudeParser "chat-assistant-backend/internal/importer/parsers/claude"
+	geminiParser "chat-assistant-backend/internal/importer/parsers/gemini"
+	"chat-assistant-backend/internal/importer/parsers/schema"
+	"chat-assistant-backend/internal/importer/parsers/timestamp"
+	"chat-assistant-backend/internal/importer/types"
+	"chat-assistant-backend/internal/logger"
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/repositories"
+	"chat-assistant-backend/internal/response"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/gorm"
+)
+
+// MockConversationRepository is a mock implementation of repositories.ConversationRepository
+type MockConversationRepository struct {
+	repositories.ConversationRepository
+	mock.Mock
+}
+
+func (m *MockConversationRepository) GetByID(id uuid.UUID) (*models.Conversation, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Conversation), args.Error(1)
+}
+
+func (m *MockConversationRepository) GetByTagID(tagID uuid.UUID, page, limit int) ([]*models.Conversation, int64, error) {
+	args := m.Called(tagID, page, limit)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*models.Conversation), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockConversationRepository) FindAll() ([]*models.Conversation, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Conversation), args.Error(1)
+}
+
+func (m *MockConversationRepository) FindAllStream(batchSize int, fn func([]*models.Conversation) error) error {
+	args := m.Called(batchSize)
+	if conversations, ok := args.Get(0).([]*models.Conversation); ok {
+		for start := 0; start < len(conversations); start += batchSize {
+			if err := fn(conversations[start:min(start+batchSize, len(conversations))]); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockConversationRepository) FindByUserIDStream(userID uuid.UUID, batchSize int, fn func([]*models.Conversation) error) error {
+	args := m.Called(userID, batchSize)
+	if conversations, ok := args.Get(0).([]*models.Conversation); ok {
+		for start := 0; start < len(conversations); start += batchSize {
+			if err := fn(conversations[start:min(start+batchSize, len(conversations))]); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockConversationRepository) FindByIDs(ids []uuid.UUID) ([]*models.Conversation, error) {
+	args := m.Called(ids)
+	return args.Get(0).([]*models.Conversation), args.Error(1)
+}
+
+func (m *MockConversationRepository) GetBySourceID(userID uuid.UUID, provider, sourceID string) (*models.Conversation, error) {
+	args := m.Called(userID, provider, sourceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Conversation), args.Error(1)
+}
+
+func (m *MockConversationRepository) FindBySourceIDs(userID uuid.UUID, sourceIDs []string) ([]*models.Conversation, error) {
+	args := m.Called(userID, sourceIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Conversation), args.Error(1)
+}
+
+func (m *MockConversationRepository) CountByProviderModel(userID uuid.UUID) ([]*models.ProviderModelCount, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.ProviderModelCount), args.Error(1)
+}
+
+func (m *MockConversationRepository) GetRecentByUserID(userID uuid.UUID, limit int) ([]*models.Conversation, map[uuid.UUID]*models.Message, error) {
+	args := m.Called(userID, limit)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).([]*models.Conversation), args.Get(1).(map[uuid.UUID]*models.Message), args.Error(2)
+}
+
+func (m *MockConversationRepository) CountByTag(userID uuid.UUID) ([]*models.TagCount, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.TagCount), args.Error(1)
+}
+
+func (m *MockConversationRepository) UpdateUserID(id uuid.UUID, userID uuid.UUID) (bool, error) {
+	args := m.Called(id, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockConversationRepository) SourceIDExists(userID uuid.UUID, sourceID string, excludeID uuid.UUID) (bool, error) {
+	args := m.Called(userID, sourceID, excludeID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockConversationRepository) Clone(id uuid.UUID, userID uuid.UUID) (*models.Conversation, error) {
+	args := m.Called(id, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Conversation), args.Error(1)
+}
+
+func (m *MockConversationRepository) Merge(targetID uuid.UUID, sourceIDs []uuid.UUID, userID uuid.UUID) (*models.Conversation, error) {
+	args := m.Called(targetID, sourceIDs, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Conversation), args.Error(1)
+}
+
+func (m *MockConversationRepository) Touch(id uuid.UUID, at time.Time) error {
+	return m.Called(id, at).Error(0)
+}
+
+func (m *MockConversationRepository) Patch(id uuid.UUID, updates map[string]interface{}, addTagIDs, removeTagIDs []string) error {
+	return m.Called(id, updates, addTagIDs, removeTagIDs).Error(0)
+}
+
+func (m *MockConversationRepository) ReplaceTags(conversationID uuid.UUID, tagIDs []string) error {
+	return m.Called(conversationID, tagIDs).Error(0)
+}
+
+func (m *MockConversationRepository) Delete(id uuid.UUID) error {
+	return m.Called(id).Error(0)
+}
+
+func (m *MockConversationRepository) RefreshLastMessageAt(id uuid.UUID) (*time.Time, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*time.Time), args.Error(1)
+}
+
+// MockIndexer is a mock implementation of repositories.ElasticsearchIndexer
+type MockIndexer struct {
+	repositories.ElasticsearchIndexer
+	mock.Mock
+}
+
+func (m *MockIndexer) IndexConversation(doc *models.ConversationDocument) error {
+	return m.Called(doc).Error(0)
+}
+
+func (m *MockIndexer) BulkIndexConversations(docs []*models.ConversationDocument) error {
+	return m.Called(docs).Error(0)
+}
+
+func (m *MockIndexer) UpdateConversation(doc *models.ConversationDocument) error {
+	return m.Called(doc).Error(0)
+}
+
+func (m *MockIndexer) UpdateTagInConversations(tagID uuid.UUID, name string) error {
+	return m.Called(tagID, name).Error(0)
+}
+
+func (m *MockIndexer) RemoveTagFromConversations(tagID uuid.UUID) error {
+	return m.Called(tagID).Error(0)
+}
+
+func (m *MockIndexer) DeleteConversation(conversationID uuid.UUID) error {
+	return m.Called(conversationID).Error(0)
+}
+
+func (m *MockIndexer) DeleteUserConversationsExcept(userID uuid.UUID, keep []uuid.UUID) (int64, error) {
+	args := m.Called(userID, keep)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockIndexer) RemoveMessageFromConversation(conversationID uuid.UUID, messageID uuid.UUID) error {
+	return m.Called(conversationID, messageID).Error(0)
+}
+
+func (m *MockIndexer) ContentHashes() (map[uuid.UUID]string, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uuid.UUID]string), args.Error(1)
+}
+
+// newOfflineImporterConfig returns a config whose database is unreachable,
+// so the importer is created without a database connection
+func newOfflineImporterConfig() *config.Config {
+	return &config.Config{
+		Database: config.DatabaseConfig{Host: "127.0.0.1", Port: 1, SSLMode: "disable", Timezone: "UTC"},
+	}
+}
+
+func TestImporter_IndexConversations(t *testing.T) {
+	conversationID := uuid.New()
+	conversation := &models.Conversation{
+		Base:     models.Base{ID: conversationID},
+		Title:    "Imported",
+		Provider: "chatgpt",
+		Messages: []models.Message{{Base: models.Base{ID: uuid.New()}, ConversationID: conversationID, Role: "user", Content: "hi"}},
+		Tags:     []models.Tag{{Base: models.Base{ID: uuid.New()}, Name: "existing"}},
+	}
+
+	repo := new(MockConversationRepository)
+	repo.On("FindByIDs", []uuid.UUID{conversationID}).Return([]*models.Conversation{conversation}, nil)
+
+	indexer := new(MockIndexer)
+	indexer.On("BulkIndexConversations", mock.MatchedBy(func(docs []*models.ConversationDocument) bool {
+		return len(docs) == 1 && docs[0].ID == conversationID &&
+			len(docs[0].Messages) == 1 && len(docs[0].Tags) == 1
+	})).Return(nil)
+
+	imp := importer.NewImporter(newOfflineImporterConfig())
+	imp.SetIndexer(repo, indexer)
+
+	indexed, err := imp.IndexConversations([]uuid.UUID{conversationID})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, indexed)
+	repo.AssertExpectations(t)
+	indexer.AssertExpectations(t)
+}
+
+func TestImporter_IndexConversationsWithoutIndexer(t *testing.T) {
+	imp := importer.NewImporter(newOfflineImporterConfig())
+
+	indexed, err := imp.IndexConversations([]uuid.UUID{uuid.New()})
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, indexed)
+}
+
+// writeChatGPTFixture writes a minimal ChatGPT export with the given conversation IDs
+func writeChatGPTFixture(t *testing.T, path string, ids ...string) {
+	content := `{"conversations":[`
+	for i, id := range ids {
+		if i > 0 {
+			content += ","
+		}
+		content += `{"id":"` + id + `","title":"` + id + `","messages":[{"role":"user","content":"hello"},{"role":"assistant","content":"hi"}]}`
+	}
+	content += `]}`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestImporterService_ImportDir(t *testing.T) {
+	parsers.RegisterAll()
+
+	dir := t.TempDir()
+	writeChatGPTFixture(t, filepath.Join(dir, "2024-01.json"), "conv-1", "conv-2")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested"), 0o755))
+	writeChatGPTFixture(t, filepath.Join(dir, "nested", "2024-02.json"), "conv-3")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not json"), 0o644))
+
+	service := importer.NewService(newOfflineImporterConfig())
+	userID := uuid.New().String()
+
+	for _, parallel := range []int{1, 2} {
+		result, err := service.ImportDir(dir, "chatgpt", userID, true, parallel)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.FileCount)
+		assert.Equal(t, 0, result.FailedFiles)
+		assert.Equal(t, 3, result.ConversationCount)
+		assert.Equal(t, 6, result.MessageCount)
+		assert.Equal(t, []string{filepath.Join(dir, "notes.txt")}, result.Skipped)
+
+		// 每个文件单独统计，顺序与文件路径一致
+		require.Len(t, result.Files, 2)
+		assert.Equal(t, filepath.Join(dir, "2024-01.json"), result.Files[0].File)
+		assert.Equal(t, 2, result.Files[0].Result.ConversationCount)
+		assert.Equal(t, 1, result.Files[1].Result.ConversationCount)
+	}
+}
+
+func TestImporter_ProviderLimits(t *testing.T) {
+	parsers.RegisterAll()
+
+	path := filepath.Join(t.TempDir(), "chatgpt.json")
+	writeChatGPTFixture(t, path, "conv-1", "conv-2", "conv-3")
+	userID := uuid.New().String()
+
+	newConfig := func(provider config.ProviderConfig, truncate bool) *config.Config {
+		cfg := newOfflineImporterConfig()
+		cfg.Import.Providers = map[string]config.ProviderConfig{"chatgpt": provider}
+		cfg.Import.TruncateOverLimit = truncate
+		return cfg
+	}
+
+	t.Run("Rejects import over the cap", func(t *testing.T) {
+		service := importer.NewService(newConfig(config.ProviderConfig{Enabled: true, MaxConversations: 2}, false))
+
+		_, err := service.Import(path, "chatgpt", userID, true)
+
+		assert.ErrorIs(t, err, importer.ErrProviderLimitExceeded)
+	})
+
+	t.Run("Truncates over the cap when configured", func(t *testing.T) {
+		service := importer.NewService(newConfig(config.ProviderConfig{Enabled: true, MaxConversations: 2}, true))
+
+		result, err := service.Import(path, "chatgpt", userID, true)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.ConversationCount)
+		assert.Equal(t, 4, result.MessageCount)
+		assert.Equal(t, 1, result.TruncatedCount)
+	})
+
+	t.Run("Within the cap or unlimited", func(t *testing.T) {
+		for _, max := range []int{3, 0} {
+			service := importer.NewService(newConfig(config.ProviderConfig{Enabled: true, MaxConversations: max}, false))
+
+			result, err := service.Import(path, "chatgpt", userID, true)
+
+			require.NoError(t, err)
+			assert.Equal(t, 3, result.ConversationCount)
+			assert.Zero(t, result.TruncatedCount)
+		}
+	})
+
+	t.Run("Disabled provider", func(t *testing.T) {
+		service := importer.NewService(newConfig(config.ProviderConfig{Enabled: false, MaxConversations: 10}, false))
+
+		_, err := service.Import(path, "chatgpt", userID, true)
+
+		assert.ErrorIs(t, err, importer.ErrProviderDisabled)
+		assert.Contains(t, err.Error(), "chatgpt")
+	})
+}
+
+// TestImporter_ParallelImportRespectsProviderLimit imports files concurrently for one user;
+// the limit check and the write are serialized so the cap holds across workers
+func TestImporter_ParallelImportRespectsProviderLimit(t *testing.T) {
+	db := openTestDB(t)
+	parsers.RegisterAll()
+
+	user := &models.User{Username: "test-" + uuid.NewString()[:8]}
+	require.NoError(t, db.Create(user).Error)
+	t.Cleanup(func() {
+		conversations := db.Model(&models.Conversation{}).Select("id").Where("user_id = ?", user.ID)
+		db.Unscoped().Where("conversation_id IN (?)", conversations).Delete(&models.Message{})
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Conversation{})
+		db.Unscoped().Delete(user)
+	})
+
+	dir := t.TempDir()
+	var files []string
+	for f := 0; f < 4; f++ {
+		path := filepath.Join(dir, fmt.Sprintf("%d.json", f))
+		writeChatGPTFixture(t, path, fmt.Sprintf("conv-%d", f))
+		files = append(files, path)
+	}
+
+	cfg := newOfflineImporterConfig()
+	cfg.Import.Providers = map[string]config.ProviderConfig{"chatgpt": {Enabled: true, MaxConversations: 2}}
+	imp := importer.NewImporter(cfg)
+	imp.SetDatabase(db)
+
+	result := imp.ImportFiles(files, "chatgpt", user.ID.String(), false, 4)
+
+	assert.Equal(t, 2, result.FailedFiles)
+	for _, file := range result.Files {
+		if file.Error != "" {
+			assert.Contains(t, file.Error, importer.ErrProviderLimitExceeded.Error())
+		}
+	}
+	var count int64
+	require.NoError(t, db.Model(&models.Conversation{}).Where("user_id = ? AND provider = ?", user.ID, "chatgpt").Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestCollectImportFiles_Glob(t *testing.T) {
+	dir := t.TempDir()
+	writeChatGPTFixture(t, filepath.Join(dir, "a.json"), "a")
+	writeChatGPTFixture(t, filepath.Join(dir, "b.json"), "b")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.txt"), []byte("x"), 0o644))
+
+	files, skipped, err := importer.CollectImportFiles(filepath.Join(dir, "*.json"))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")}, files)
+	assert.Empty(t, skipped)
+}
+
+// writeZipFixture writes a zip archive containing the given files
+func writeZipFixture(t *testing.T, path string, files map[string][]byte) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+}
+
+func TestImporter_ImportZip(t *testing.T) {
+	parsers.RegisterAll()
+
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "export.json")
+	writeChatGPTFixture(t, jsonPath, "conv-1", "conv-2")
+	export, err := os.ReadFile(jsonPath)
+	require.NoError(t, err)
+
+	userID := uuid.New().String()
+	cfg := newOfflineImporterConfig()
+	cfg.Import.MaxFileSize = int64(len(export))
+
+	t.Run("Uses conversations.json", func(t *testing.T) {
+		path := filepath.Join(dir, "chatgpt.zip")
+		writeZipFixture(t, path, map[string][]byte{
+			"export/conversations.json": export,
+			"export/user.json":          []byte(`{"id":"user"}`),
+			"export/chat.html":          []byte("<html></html>"),
+		})
+
+		result, err := importer.NewImporter(cfg).Import(path, "chatgpt", userID, true)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.ConversationCount)
+	})
+
+	t.Run("Falls back to largest JSON", func(t *testing.T) {
+		path := filepath.Join(dir, "other.zip")
+		writeZipFixture(t, path, map[string][]byte{
+			"meta.json":   []byte(`{}`),
+			"export.json": export,
+		})
+
+		result, err := importer.NewImporter(cfg).Import(path, "chatgpt", userID, true)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.ConversationCount)
+	})
+
+	t.Run("Rejects decompressed size over max_file_size", func(t *testing.T) {
+		// 高度可压缩的内容，压缩包本身远小于限制
+		path := filepath.Join(dir, "bomb.zip")
+		writeZipFixture(t, path, map[string][]byte{
+			"conversations.json": bytes.Repeat([]byte(" "), len(export)+1),
+		})
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Less(t, info.Size(), int64(len(export)))
+
+		_, err = importer.NewImporter(cfg).Import(path, "chatgpt", userID, true)
+
+		assert.ErrorIs(t, err, importer.ErrFileTooLarge)
+	})
+
+	t.Run("Raw JSON still supported", func(t *testing.T) {
+		result, err := importer.NewImporter(cfg).Import(jsonPath, "chatgpt", userID, true)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.ConversationCount)
+	})
+
+	t.Run("No JSON in archive", func(t *testing.T) {
+		path := filepath.Join(dir, "empty.zip")
+		writeZipFixture(t, path, map[string][]byte{"chat.html": []byte("<html></html>")})
+
+		_, err := importer.NewImporter(cfg).Import(path, "chatgpt", userID, true)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestDetectPlatform(t *testing.T) {
+	parsers.RegisterAll()
+
+	testCases := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{"Claude export", `[{"uuid":"c1","name":"Hello","chat_messages":[{"uuid":"m1","sender":"human","text":"hi"}]}]`, "claude"},
+		{"ChatGPT export", `{"conversations":[{"id":"c1","title":"Hello","create_time":1704067200,` +
+			`"messages":[{"role":"user","content":"hi","create_time":1704067200}]}]}`, "chatgpt"},
+		{"ChatGPT export with attachments", `{"conversations":[{"id":"c1","messages":[` +
+			`{"role":"user","content":"see file","attachments":[{"id":"f1","name":"a.txt"}]}]}]}`, "chatgpt"},
+		{"ChatGPT shared link", chatgptShareExport, "chatgpt"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			platform, err := parsers.Detect([]byte(tc.data))
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, platform)
+
+			// 识别结果必须能被该平台的解析器解析
+			parser, err := parsers.GetParser(platform)
+			require.NoError(t, err)
+			require.NoError(t, parser.ValidateRaw([]byte(tc.data)))
+			_, err = parser.Parse([]byte(tc.data))
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("Ambiguous export", func(t *testing.T) {
+		// 没有时间和附件时 ChatGPT 与 Gemini 的格式完全相同
+		_, err := parsers.Detect([]byte(`{"conversations":[{"id":"g1","title":"Hello","messages":[{"role":"user","content":"hi"}]}]}`))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ambiguous")
+		assert.Contains(t, err.Error(), "chatgpt, gemini")
+	})
+
+	t.Run("Unrecognized export", func(t *testing.T) {
+		for _, data := range []string{
+			`{"items":[]}`,
+			// 解析器不支持 mapping 格式
+			`[{"title":"Hello","mapping":{"node-1":{"id":"node-1","message":null}}}]`,
+		} {
+			_, err := parsers.Detect([]byte(data))
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unrecognized")
+		}
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		_, err := parsers.Detect([]byte(`not json`))
+
+		assert.Error(t, err)
+	})
+}
+
+// chatgptShareExport is a ChatGPT shared-link export with a tool message and a multi-part reply
+const chatgptShareExport = `{"title":"Shared chat","conversation_id":"share-1","create_time":1704067200.5,"update_time":1704067260,` +
+	`"messages":[` +
+	`{"id":"m1","author":{"role":"user"},"content":{"content_type":"text","parts":["What is Go?"]},"create_time":1704067200.5},` +
+	`{"id":"m2","author":{"role":"tool"},"content":{"content_type":"text","parts":["search results"]},"create_time":1704067201},` +
+	`{"id":"m3","author":{"role":"assistant"},"content":{"content_type":"multimodal_text",` +
+	`"parts":["Go is a language.",{"content_type":"image_asset_pointer"},"It is compiled."]},"create_time":1704067202}]}`
+
+func TestChatGPTParser_ShareFormat(t *testing.T) {
+	parsers.RegisterAll()
+	parser, err := parsers.GetParser("chatgpt")
+	require.NoError(t, err)
+
+	standardData, err := parser.Parse([]byte(chatgptShareExport))
+	require.NoError(t, err)
+	require.NoError(t, importer.NewValidator().Validate(standardData))
+	require.Len(t, standardData.Conversations, 1)
+
+	conversation := standardData.Conversations[0]
+	assert.Equal(t, "share-1", conversation.ID)
+	assert.Equal(t, "Shared chat", conversation.Title)
+	assert.Equal(t, "chatgpt", conversation.Provider)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 500000000, time.UTC), conversation.CreatedAt)
+
+	// tool 消息不导入，parts 中的非文本内容被忽略
+	require.Len(t, conversation.Messages, 2)
+	assert.Equal(t, "user", conversation.Messages[0].Role)
+	assert.Equal(t, "What is Go?", conversation.Messages[0].Content)
+	assert.Equal(t, "m3", conversation.Messages[1].ID)
+	assert.Equal(t, "assistant", conversation.Messages[1].Role)
+	assert.Equal(t, "Go is a language.\nIt is compiled.", conversation.Messages[1].Content)
+
+	t.Run("Stable ID without conversation_id", func(t *testing.T) {
+		data := `{"title":"No id","messages":[{"author":{"role":"user"},"content":{"parts":["hi"]}}]}`
+
+		first, err := parser.Parse([]byte(data))
+		require.NoError(t, err)
+		second, err := parser.Parse([]byte(data))
+		require.NoError(t, err)
+
+		assert.True(t, strings.HasPrefix(first.Conversations[0].ID, "share-"))
+		assert.Equal(t, first.Conversations[0].ID, second.Conversations[0].ID)
+		assert.NotEmpty(t, first.Conversations[0].Messages[0].ID)
+	})
+}
+
+// chatgptTimedExport is a ChatGPT export with conversation and message timestamps;
+// the second message was edited and the third has no timestamps
+const chatgptTimedExport = `{"conversations":[{"id":"c1","title":"Timed","create_time":1704067200,"update_time":1704153600,"messages":[` +
+	`{"role":"user","content":"first","create_time":1704067210.25,"update_time":1704067210.25},` +
+	`{"role":"assistant","content":"edited","create_time":1704067220,"update_time":1704070800},` +
+	`{"role":"user","content":"untimed"}]}]}`
+
+func TestChatGPTParser_Timestamps(t *testing.T) {
+	conversationCreated := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Prefers create_time", func(t *testing.T) {
+		standardData, err := chatgptParser.NewParser().Parse([]byte(chatgptTimedExport))
+		require.NoError(t, err)
+
+		conversation := standardData.Conversations[0]
+		assert.Equal(t, conversationCreated, conversation.CreatedAt)
+		assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), conversation.UpdatedAt)
+
+		require.Len(t, conversation.Messages, 3)
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 10, 250000000, time.UTC), conversation.Messages[0].CreatedAt)
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 20, 0, time.UTC), conversation.Messages[1].CreatedAt)
+		// 缺少时间的消息留给 Transformer 补齐
+		assert.True(t, conversation.Messages[2].CreatedAt.IsZero())
+	})
+
+	t.Run("Prefers update_time", func(t *testing.T) {
+		standardData, err := chatgptParser.NewParserWithTimestampSource(chatgptParser.TimestampUpdate).Parse([]byte(chatgptTimedExport))
+		require.NoError(t, err)
+
+		messages := standardData.Conversations[0].Messages
+		assert.Equal(t, time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC), messages[1].CreatedAt)
+		assert.True(t, messages[2].CreatedAt.IsZero())
+	})
+
+	t.Run("Missing conversation times", func(t *testing.T) {
+		data := `{"conversations":[{"id":"c2","title":"Partial","update_time":1704067200,` +
+			`"messages":[{"role":"user","content":"hi"}]}]}`
+
+		standardData, err := chatgptParser.NewParser().Parse([]byte(data))
+		require.NoError(t, err)
+
+		conversation := standardData.Conversations[0]
+		assert.Equal(t, conversationCreated, conversation.CreatedAt)
+		assert.Equal(t, conversationCreated, conversation.UpdatedAt)
+		assert.True(t, conversation.Messages[0].CreatedAt.IsZero())
+	})
+
+	t.Run("Configured through import config", func(t *testing.T) {
+		t.Cleanup(parsers.RegisterAll)
+		parsers.RegisterAllWithConfig(&config.ImportConfig{Providers: map[string]config.ProviderConfig{
+			"chatgpt": {Enabled: true, TimestampSource: "update"},
+		}})
+		parser, err := parsers.GetParser("chatgpt")
+		require.NoError(t, err)
+
+		standardData, err := parser.Parse([]byte(chatgptTimedExport))
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC), standardData.Conversations[0].Messages[1].CreatedAt)
+	})
+}
+
+func TestParsers_ValidateRaw(t *testing.T) {
+	parsers.RegisterAll()
+
+	testCases := []struct {
+		name     string
+		platform string
+		data     string
+		expected string
+	}{
+		{"Claude object instead of array", "claude", `{"conversations":[]}`,
+			"expected top-level array for claude, got object"},
+		{"Claude missing messages", "claude", `[{"uuid":"c1","name":"Hello"}]`,
+			`missing required field "chat_messages" for claude at [0]`},
+		{"Claude wrong sender type", "claude", `[{"uuid":"c1","chat_messages":[{"sender":"human"},{"sender":1}]}]`,
+			"expected string for claude at [0].chat_messages[1].sender, got number"},
+		{"Claude truncated file", "claude", `[{"uuid":"c1","chat_messages":[{"sender":"hu`,
+			"invalid JSON for claude, unexpected end of JSON input"},
+		{"ChatGPT array instead of object", "chatgpt", `[{"uuid":"c1","chat_messages":[]}]`,
+			"expected top-level object for chatgpt, got array"},
+		{"ChatGPT message without content", "chatgpt", `{"conversations":[{"id":"c1","messages":[{"role":"user"}]}]}`,
+			`missing required field "content" for chatgpt at conversations[0].messages[0]`},
+		{"ChatGPT share without author", "chatgpt", `{"title":"Shared","messages":[{"content":{"parts":["hi"]}}]}`,
+			`missing required field "author" for chatgpt at messages[0]`},
+		{"Gemini null messages", "gemini", `{"conversations":[{"id":"g1","messages":null}]}`,
+			"expected array for gemini at conversations[0].messages, got null"},
+		{"Gemini wrong title type", "gemini", `{"conversations":[{"id":"g1","title":42,"messages":[]}]}`,
+			"expected string for gemini at conversations[0].title, got number"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parser, err := parsers.GetParser(tc.platform)
+			require.NoError(t, err)
+
+			err = parser.ValidateRaw([]byte(tc.data))
+
+			var validationErr *schema.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Contains(t, err.Error(), tc.expected)
+		})
+	}
+
+	t.Run("Valid exports pass", func(t *testing.T) {
+		for platform, data := range map[string]string{
+			"claude":  claudeExportWithAttachments,
+			"chatgpt": chatgptTimedExport,
+			"gemini":  `{"conversations":[{"id":"g1","title":"Hello","messages":[{"role":"user","content":"hi"}]}]}`,
+		} {
+			parser, err := parsers.GetParser(platform)
+			require.NoError(t, err)
+			assert.NoError(t, parser.ValidateRaw([]byte(data)), platform)
+		}
+		parser, err := parsers.GetParser("chatgpt")
+		require.NoError(t, err)
+		assert.NoError(t, parser.ValidateRaw([]byte(chatgptShareExport)))
+	})
+
+	t.Run("Import rejects wrong platform before parsing", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "claude.json")
+		require.NoError(t, os.WriteFile(path, []byte(claudeExportWithAttachments), 0o644))
+
+		_, err := importer.NewService(newOfflineImporterConfig()).Import(path, "gemini", uuid.New().String(), true)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "expected top-level object for gemini, got array")
+	})
+}
+
+func TestParsers_JSONErrorLocation(t *testing.T) {
+	parsers.RegisterAll()
+
+	t.Run("Syntax error", func(t *testing.T) {
+		data := "[\n  {\n    \"uuid\": \"c1\",\n    \"name\": \"Hello\" \"chat_messages\": []\n  }\n]"
+
+		var raw interface{}
+		err := importerrors.DecodeJSON([]byte(data), &raw)
+
+		var jsonErr *importerrors.JSONError
+		require.ErrorAs(t, err, &jsonErr)
+		assert.Equal(t, 4, jsonErr.Line)
+		assert.Equal(t, 21, jsonErr.Column)
+		assert.Contains(t, jsonErr.Message, "invalid character '\"' after object key:value pair")
+		assert.Contains(t, jsonErr.Snippet, `"chat_messages"`)
+		assert.Contains(t, err.Error(), "at line 4, column 21")
+	})
+
+	t.Run("Type error names the field", func(t *testing.T) {
+		data := "[\n  {\n    \"uuid\": \"c1\",\n    \"created_at\": 1727000000,\n    \"chat_messages\": []\n  }\n]"
+
+		parser, err := parsers.GetParser("claude")
+		require.NoError(t, err)
+		_, err = parser.Parse([]byte(data))
+
+		var jsonErr *importerrors.JSONError
+		require.ErrorAs(t, err, &jsonErr)
+		assert.Equal(t, 4, jsonErr.Line)
+		assert.Contains(t, err.Error(), "expected string, got number in field")
+		assert.Contains(t, err.Error(), "created_at at line 4, column 28")
+		assert.Contains(t, jsonErr.Snippet, "1727000000")
+	})
+
+	t.Run("Truncated file", func(t *testing.T) {
+		data := "[\n  {\"uuid\": \"c1\", \"chat_messages\": [{\"sender\": \"hu"
+
+		var raw interface{}
+		err := importerrors.DecodeJSON([]byte(data), &raw)
+
+		var jsonErr *importerrors.JSONError
+		require.ErrorAs(t, err, &jsonErr)
+		assert.Equal(t, 2, jsonErr.Line)
+		assert.Equal(t, int64(len(data)), jsonErr.Offset)
+		assert.Contains(t, err.Error(), "unexpected end of JSON input at line 2")
+	})
+
+	t.Run("Trailing data", func(t *testing.T) {
+		var raw interface{}
+		err := importerrors.DecodeJSON([]byte("[]\n\n{}"), &raw)
+
+		var jsonErr *importerrors.JSONError
+		require.ErrorAs(t, err, &jsonErr)
+		assert.Equal(t, 3, jsonErr.Line)
+		assert.Equal(t, 1, jsonErr.Column)
+	})
+
+	t.Run("Import reports the location", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "broken.json")
+		require.NoError(t, os.WriteFile(path, []byte("[\n  {\"uuid\": \"c1\",,}\n]"), 0o644))
+
+		_, err := importer.NewService(newOfflineImporterConfig()).Import(path, "claude", uuid.New().String(), true)
+
+		var jsonErr *importerrors.JSONError
+		require.ErrorAs(t, err, &jsonErr)
+		assert.Equal(t, 2, jsonErr.Line)
+		assert.Contains(t, err.Error(), "invalid JSON for claude")
+		assert.Contains(t, err.Error(), "at line 2, column 17")
+	})
+}
+
+func TestTransformer_BackfillsMissingTimestamps(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timed := created.Add(time.Hour)
+	standardData := &types.StandardFormat{Conversations: []*types.StandardConversation{{
+		ID:        "c1",
+		CreatedAt: created,
+		Messages: []*types.StandardMessage{
+			{Role: "user", Content: "one"},
+			{Role: "assistant", Content: "two"},
+			{Role: "user", Content: "three", CreatedAt: timed},
+			{Role: "assistant", Content: "four"},
+		},
+	}}}
+
+	t.Run("Ordered by index", func(t *testing.T) {
+		_, messages, err := importer.NewTransformer().Transform(standardData, uuid.New(), "gemini")
+		require.NoError(t, err)
+		require.Len(t, messages, 4)
+
+		assert.Equal(t, created, messages[0].Message.CreatedAt)
+		assert.Equal(t, created.Add(time.Second), messages[1].Message.CreatedAt)
+		// 带时间的消息保持原值，之后的消息接在它后面
+		assert.Equal(t, timed, messages[2].Message.CreatedAt)
+		assert.Equal(t, timed.Add(time.Second), messages[3].Message.CreatedAt)
+	})
+
+	t.Run("Configurable step", func(t *testing.T) {
+		_, messages, err := importer.NewTransformerWithTimestampStep(time.Minute).Transform(standardData, uuid.New(), "gemini")
+		require.NoError(t, err)
+
+		assert.Equal(t, created.Add(time.Minute), messages[1].Message.CreatedAt)
+	})
+
+	t.Run("Disabled uses import time", func(t *testing.T) {
+		before := time.Now()
+		_, messages, err := importer.NewTransformerWithTimestampStep(0).Transform(standardData, uuid.New(), "gemini")
+		require.NoError(t, err)
+
+		assert.False(t, messages[0].Message.CreatedAt.Before(before))
+		assert.Equal(t, timed, messages[2].Message.CreatedAt)
+	})
+
+	t.Run("Sequence keeps export order when times collide", func(t *testing.T) {
+		_, messages, err := importer.NewTransformerWithTimestampStep(0).Transform(&types.StandardFormat{
+			Conversations: []*types.StandardConversation{{ID: "c1", CreatedAt: created, Messages: []*types.StandardMessage{
+				{Role: "user", Content: "one", CreatedAt: timed},
+				{Role: "assistant", Content: "two", CreatedAt: timed},
+				{Role: "user", Content: "three", CreatedAt: timed},
+			}}},
+		}, uuid.New(), "gemini")
+		require.NoError(t, err)
+
+		for i, msg := range messages {
+			assert.Equal(t, i+1, msg.Message.Sequence)
+		}
+	})
+}
+
+func TestTransformer_DefaultModel(t *testing.T) {
+	cfg := &config.ImportConfig{Providers: map[string]config.ProviderConfig{
+		"gemini": {Enabled: true, DefaultModel: "gemini-1.5-pro"},
+		"claude": {Enabled: true, DefaultModel: "claude-3-5-sonnet"},
+	}}
+	transformer := importer.NewTransformerWithOptions(importer.TransformerOptions{DefaultModels: cfg.DefaultModels()})
+
+	standardData, err := geminiParser.NewParser().Parse([]byte(`{"conversations": [
+		{"id": "g1", "title": "No model", "messages": [{"role": "user", "content": "hi"}]},
+		{"id": "g2", "title": "With model", "model": "gemini-2.0-flash", "messages": [{"role": "user", "content": "hi"}]}
+	]}`))
+	require.NoError(t, err)
+
+	conversations, _, err := transformer.Transform(standardData, uuid.New(), "gemini")
+	require.NoError(t, err)
+	require.Len(t, conversations, 2)
+
+	// 导出数据中没有模型时使用配置的默认模型，有模型时保持原值
+	assert.Equal(t, "gemini-1.5-pro", conversations[0].Model)
+	assert.Equal(t, "gemini-2.0-flash", conversations[1].Model)
+
+	t.Run("Provider without configured default", func(t *testing.T) {
+		conversations, _, err := transformer.Transform(standardData, uuid.New(), "chatgpt")
+		require.NoError(t, err)
+		assert.Empty(t, conversations[0].Model)
+	})
+}
+
+func TestTransformer_MaxMessagesPerConversation(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newData := func() *types.StandardFormat {
+		var messages []*types.StandardMessage
+		for i := 1; i <= 5; i++ {
+			messages = append(messages, &types.StandardMessage{
+				ID:        fmt.Sprintf("m%d", i),
+				Role:      "user",
+				Content:   fmt.Sprintf("message %d", i),
+				CreatedAt: created.Add(time.Duration(i) * time.Minute),
+			})
+		}
+		return &types.StandardFormat{Conversations: []*types.StandardConversation{
+			{ID: "big", Title: "Big", CreatedAt: created, Messages: messages, Metadata: map[string]interface{}{"account": "me@example.com"}},
+			{ID: "small", Title: "Small", CreatedAt: created, Messages: messages[:2]},
+		}}
+	}
+
+	t.Run("Truncate keeps most recent", func(t *testing.T) {
+		transformer := importer.NewTransformerWithOptions(importer.TransformerOptions{
+			MaxMessagesPerConversation: 2,
+			OversizedPolicy:            config.OversizedPolicyTruncate,
+		})
+		conversations, messages, err := transformer.Transform(newData(), uuid.New(), "gemini")
+		require.NoError(t, err)
+		require.Len(t, conversations, 2)
+		require.Len(t, messages, 4)
+
+		assert.Equal(t, "m4", messages[0].Message.SourceID)
+		assert.Equal(t, "m5", messages[1].Message.SourceID)
+		assert.Equal(t, "big", messages[1].ConversationSourceID)
+
+		meta := conversations[0].GetMetadata()
+		require.NotNil(t, meta)
+		assert.Equal(t, "truncate", meta.ImportPolicy)
+		assert.Equal(t, "kept the most recent 2 of 5 messages", meta.ImportNote)
+		// 原有元信息保留
+		assert.Equal(t, "me@example.com", meta.Account)
+
+		// 未超限的对话不记录处理方式
+		assert.Nil(t, conversations[1].GetMetadata())
+	})
+
+	t.Run("Split into parts", func(t *testing.T) {
+		transformer := importer.NewTransformerWithOptions(importer.TransformerOptions{
+			MaxMessagesPerConversation: 2,
+			OversizedPolicy:            config.OversizedPolicySplit,
+		})
+		conversations, messages, err := transformer.Transform(newData(), uuid.New(), "gemini")
+		require.NoError(t, err)
+		require.Len(t, conversations, 4)
+		require.Len(t, messages, 7)
+
+		assert.Equal(t, []string{"big", "big#part2", "big#part3", "small"}, []string{
+			conversations[0].SourceID, conversations[1].SourceID, conversations[2].SourceID, conversations[3].SourceID,
+		})
+		assert.Equal(t, "Big (2/3)", conversations[1].SourceTitle)
+		assert.Equal(t, created.Add(3*time.Minute), conversations[1].CreatedAt)
+
+		for i, part := range conversations[:3] {
+			meta := part.GetMetadata()
+			require.NotNil(t, meta)
+			assert.Equal(t, "split", meta.ImportPolicy)
+			assert.Equal(t, "me@example.com", meta.Account)
+			assert.Contains(t, meta.ImportNote, fmt.Sprintf("part %d of 3", i+1))
+		}
+		assert.Equal(t, "part 3 of 3, messages 5-5 of 5", conversations[2].GetMetadata().ImportNote)
+
+		// 消息按顺序分配到各部分
+		perPart := make(map[string][]string)
+		for _, msg := range messages {
+			perPart[msg.ConversationSourceID] = append(perPart[msg.ConversationSourceID], msg.Message.SourceID)
+		}
+		assert.Equal(t, []string{"m1", "m2"}, perPart["big"])
+		assert.Equal(t, []string{"m3", "m4"}, perPart["big#part2"])
+		assert.Equal(t, []string{"m5"}, perPart["big#part3"])
+		assert.Equal(t, conversations[2].ID, messages[4].Message.ConversationID)
+	})
+
+	t.Run("Unlimited", func(t *testing.T) {
+		conversations, messages, err := importer.NewTransformer().Transform(newData(), uuid.New(), "gemini")
+		require.NoError(t, err)
+		assert.Len(t, conversations, 2)
+		assert.Len(t, messages, 7)
+	})
+}
+
+func TestTransformer_DeterministicIDs(t *testing.T) {
+	userID := uuid.New()
+	claudeExport := []byte(`[{"uuid":"c1","name":"Hello","created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-01T00:00:00Z",` +
+		`"chat_messages":[{"uuid":"m1","sender":"human","text":"hi","created_at":"2024-01-01T00:00:00Z"},` +
+		`{"uuid":"m2","sender":"assistant","text":"hello","created_at":"2024-01-01T00:00:01Z"}]}]`)
+	// Gemini 导出的消息没有 ID，由位置和内容派生
+	geminiExport := []byte(`{"conversations": [{"id": "g1", "title": "No IDs", "messages": [
+		{"role": "user", "content": "same"}, {"role": "user", "content": "same"}, {"role": "model", "content": "reply"}]}]}`)
+
+	type result struct {
+		conversations []uuid.UUID
+		messages      []uuid.UUID
+	}
+	run := func(opts importer.TransformerOptions, platform string, data []byte, user uuid.UUID) result {
+		var standardData *types.StandardFormat
+		var err error
+		if platform == "claude" {
+			standardData, err = claudeParser.NewParser().Parse(data)
+		} else {
+			standardData, err = geminiParser.NewParser().Parse(data)
+		}
+		require.NoError(t, err)
+
+		conversations, messages, err := importer.NewTransformerWithOptions(opts).Transform(standardData, user, platform)
+		require.NoError(t, err)
+		var r result
+		for _, conv := range conversations {
+			r.conversations = append(r.conversations, conv.ID)
+		}
+		for _, msg := range messages {
+			r.messages = append(r.messages, msg.Message.ID)
+			assert.Contains(t, r.conversations, msg.Message.ConversationID)
+		}
+		return r
+	}
+	deterministic := importer.TransformerOptions{DeterministicIDs: true}
+
+	t.Run("Same export yields identical IDs", func(t *testing.T) {
+		for _, tc := range []struct {
+			platform string
+			data     []byte
+		}{{"claude", claudeExport}, {"gemini", geminiExport}} {
+			first := run(deterministic, tc.platform, tc.data, userID)
+			second := run(deterministic, tc.platform, tc.data, userID)
+			assert.Equal(t, first, second, tc.platform)
+			assert.Equal(t, uuid.Version(5), first.conversations[0].Version())
+		}
+	})
+
+	t.Run("Messages without IDs are distinct", func(t *testing.T) {
+		r := run(deterministic, "gemini", geminiExport, userID)
+		require.Len(t, r.messages, 3)
+		assert.NotEqual(t, r.messages[0], r.messages[1])
+	})
+
+	t.Run("Scoped to the user", func(t *testing.T) {
+		assert.NotEqual(t, run(deterministic, "claude", claudeExport, userID).conversations,
+			run(deterministic, "claude", claudeExport, uuid.New()).conversations)
+	})
+
+	t.Run("Split parts are stable", func(t *testing.T) {
+		opts := importer.TransformerOptions{DeterministicIDs: true, MaxMessagesPerConversation: 1}
+		first := run(opts, "claude", claudeExport, userID)
+		require.Len(t, first.conversations, 2)
+		assert.Equal(t, first, run(opts, "claude", claudeExport, userID))
+	})
+
+	t.Run("Random by default", func(t *testing.T) {
+		assert.NotEqual(t, run(importer.TransformerOptions{}, "claude", claudeExport, userID),
+			run(importer.TransformerOptions{}, "claude", claudeExport, userID))
+	})
+}
+
+func TestImporterService_ImportAutoDetectsPlatform(t *testing.T) {
+	parsers.RegisterAll()
+
+	path := filepath.Join(t.TempDir(), "claude.json")
+	content := `[{"uuid":"c1","name":"Hello","created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-01T00:00:00Z",` +
+		`"chat_messages":[{"uuid":"m1","sender":"human","text":"hi","created_at":"2024-01-01T00:00:00Z"},` +
+		`{"uuid":"m2","sender":"assistant","text":"hello","created_at":"2024-01-01T00:00:01Z"}]}]`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	service := importer.NewService(newOfflineImporterConfig())
+	result, err := service.Import(path, "", uuid.New().String(), true)
+
+	require.NoError(t, err)
+	assert.Equal(t, "claude", result.Platform)
+	assert.Equal(t, 1, result.ConversationCount)
+	assert.Equal(t, 2, result.MessageCount)
+}
+
+func TestImporter_DiffAgainstExisting(t *testing.T) {
+	parsers.RegisterAll()
+
+	// 新的导出修改了 c1 的 m2、新增了 m3，不再包含 m0；c2 尚未导入；c3 没有变化
+	export := `[` +
+		`{"uuid":"c1","name":"Hello","created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-02T00:00:00Z","chat_messages":[` +
+		`{"uuid":"m1","sender":"human","text":"hi","created_at":"2024-01-01T00:00:00Z"},` +
+		`{"uuid":"m2","sender":"assistant","text":"hello, edited","created_at":"2024-01-01T00:00:01Z"},` +
+		`{"uuid":"m3","sender":"human","text":"one more question","created_at":"2024-01-02T00:00:00Z"}]},` +
+		`{"uuid":"c2","name":"New","created_at":"2024-01-03T00:00:00Z","updated_at":"2024-01-03T00:00:00Z","chat_messages":[` +
+		`{"uuid":"m4","sender":"human","text":"brand new","created_at":"2024-01-03T00:00:00Z"}]},` +
+		`{"uuid":"c3","name":"Same","created_at":"2024-01-04T00:00:00Z","updated_at":"2024-01-04T00:00:00Z","chat_messages":[` +
+		`{"uuid":"m5","sender":"human","text":"unchanged","created_at":"2024-01-04T00:00:00Z"}]}]`
+
+	userID := uuid.New()
+	c1 := &models.Conversation{
+		Base:        models.Base{ID: uuid.New()},
+		SourceID:    "c1",
+		SourceTitle: "Hello",
+		Messages: []models.Message{
+			{Role: "user", SourceID: "m0", SourceContent: "removed from export"},
+			{Role: "user", SourceID: "m1", SourceContent: "hi", Content: "hi (processed)"},
+			{Role: "assistant", SourceID: "m2", SourceContent: "hello"},
+		},
+	}
+	c3 := &models.Conversation{
+		Base:        models.Base{ID: uuid.New()},
+		SourceID:    "c3",
+		SourceTitle: "Same",
+		Messages:    []models.Message{{Role: "user", SourceID: "m5", SourceContent: "unchanged"}},
+	}
+
+	repo := &MockConversationRepository{}
+	repo.On("FindBySourceIDs", userID, []string{"c1", "c2", "c3"}).Return([]*models.Conversation{c1, c3}, nil)
+
+	imp := importer.NewImporter(newOfflineImporterConfig())
+	imp.SetConversationRepository(repo)
+
+	diff, err := imp.DiffAgainstExisting([]byte(export), userID)
+
+	require.NoError(t, err)
+	repo.AssertExpectations(t)
+	assert.Equal(t, "claude", diff.Platform)
+	assert.Equal(t, 1, diff.NewCount)
+	assert.Equal(t, 1, diff.ChangedCount)
+	assert.Equal(t, 1, diff.UnchangedCount)
+	require.Len(t, diff.Conversations, 3)
+
+	changed := diff.Conversations[0]
+	assert.Equal(t, importer.DiffStatusChanged, changed.Status)
+	require.NotNil(t, changed.ConversationID)
+	assert.Equal(t, c1.ID, *changed.ConversationID)
+	assert.False(t, changed.TitleChanged)
+	require.Len(t, changed.AddedMessages, 1)
+	assert.Equal(t, importer.MessageDiff{SourceID: "m3", Role: "user", Preview: "one more question"}, changed.AddedMessages[0])
+	require.Len(t, changed.ChangedMessages, 1)
+	assert.Equal(t, importer.MessageDiff{SourceID: "m2", Role: "assistant", Preview: "hello, edited"}, changed.ChangedMessages[0])
+	require.Len(t, changed.RemovedMessages, 1)
+	assert.Equal(t, "m0", changed.RemovedMessages[0].SourceID)
+
+	added := diff.Conversations[1]
+	assert.Equal(t, importer.DiffStatusNew, added.Status)
+	assert.Nil(t, added.ConversationID)
+	require.Len(t, added.AddedMessages, 1)
+	assert.Equal(t, "m4", added.AddedMessages[0].SourceID)
+
+	same := diff.Conversations[2]
+	assert.Equal(t, importer.DiffStatusUnchanged, same.Status)
+	assert.Empty(t, same.AddedMessages)
+	assert.Empty(t, same.ChangedMessages)
+	assert.Empty(t, same.RemovedMessages)
+
+	t.Run("Requires a database", func(t *testing.T) {
+		_, err := importer.NewImporter(newOfflineImporterConfig()).DiffAgainstExisting([]byte(export), userID)
+		assert.Error(t, err)
+	})
+}
+
+func TestConversationMetadata_IndexedFromImport(t *testing.T) {
+	parsers.RegisterAll()
+	parser, err := parsers.GetParser("claude")
+	require.NoError(t, err)
+
+	data := `[{"uuid":"c1","name":"Hello","summary":"greeting chat","account":{"uuid":"acc-1"},` +
+		`"created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-01T00:00:00Z",` +
+		`"chat_messages":[{"uuid":"m1","sender":"human","text":"hi","created_at":"2024-01-01T00:00:00Z"}]}]`
+	standardData, err := parser.Parse([]byte(data))
+	require.NoError(t, err)
+
+	conversations, _, err := importer.NewTransformer().Transform(standardData, uuid.New(), "claude")
+	require.NoError(t, err)
+	require.Len(t, conversations, 1)
+
+	// 持久化为 JSON
+	meta := conversations[0].GetMetadata()
+	require.NotNil(t, meta)
+	assert.Equal(t, "greeting chat", meta.Summary)
+	assert.Equal(t, "acc-1", meta.Account)
+
+	// 索引为扁平字段
+	doc := conversations[0].ToESDocument()
+	assert.Equal(t, map[string]string{"summary": "greeting chat", "account": "acc-1"}, doc.Metadata)
+}
+
+func TestConversationMetadata_Empty(t *testing.T) {
+	conversation := &models.Conversation{}
+	conversation.SetMetadata(&models.ConversationMetadata{})
+
+	assert.Empty(t, conversation.Metadata)
+	assert.Nil(t, conversation.GetMetadata())
+	assert.Nil(t, conversation.ToESDocument().Metadata)
+}
+
+// claudeExportWithAttachments is a Claude export whose first message has an attachment and a file
+const claudeExportWithAttachments = `[{"uuid":"c-att","name":"Files","account":{"uuid":"acc-1"},` +
+	`"created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-01T00:00:00Z",` +
+	`"chat_messages":[` +
+	`{"uuid":"m-att","sender":"human","text":"see attached","created_at":"2024-01-01T00:00:00Z",` +
+	`"attachments":[{"file_name":"report.pdf","file_size":1024,"file_type":"pdf","extracted_content":"..."}],` +
+	`"files":[{"file_name":"chart.png","file_kind":"image","file_uuid":"f-1","preview_url":"/api/files/f-1/preview"}]},` +
+	`{"uuid":"m-plain","sender":"assistant","text":"thanks","created_at":"2024-01-01T00:00:01Z"}]}]`
+
+func TestClaudeParser_Attachments(t *testing.T) {
+	parsers.RegisterAll()
+	parser, err := parsers.GetParser("claude")
+	require.NoError(t, err)
+
+	standardData, err := parser.Parse([]byte(claudeExportWithAttachments))
+	require.NoError(t, err)
+
+	_, messages, err := importer.NewTransformer().Transform(standardData, uuid.New(), "claude")
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+
+	attachments := messages[0].Message.Attachments
+	require.Len(t, attachments, 2)
+	assert.Equal(t, "pdf", attachments[0].Type)
+	assert.Equal(t, "report.pdf", attachments[0].Name)
+	assert.Empty(t, attachments[0].URLOrRef)
+	assert.Equal(t, "image", attachments[1].Type)
+	assert.Equal(t, "chart.png", attachments[1].Name)
+	assert.Equal(t, "/api/files/f-1/preview", attachments[1].URLOrRef)
+	assert.Equal(t, messages[0].Message.ID, attachments[1].MessageID)
+
+	assert.Empty(t, messages[1].Message.Attachments)
+
+	// 附件通过 MessageResponse 返回
+	resp := response.NewMessageResponse(messages[0].Message)
+	require.Len(t, resp.Attachments, 2)
+	assert.Equal(t, "chart.png", resp.Attachments[1].Name)
+	assert.Nil(t, response.NewMessageResponse(messages[1].Message).Attachments)
+}
+
+func TestParseFlexibleTime(t *testing.T) {
+	want := time.Date(2025, 9, 22, 9, 17, 21, 0, time.UTC)
+	cases := []struct {
+		name  string
+		value string
+		want  time.Time
+	}{
+		{"RFC3339", "2025-09-22T09:17:21Z", want},
+		{"RFC3339 with offset", "2025-09-22T17:17:21+08:00", want},
+		{"RFC3339 with microseconds", "2025-09-22T09:17:21.803710Z", want.Add(803710 * time.Microsecond)},
+		{"RFC3339Nano", "2025-09-22T09:17:21.123456789Z", want.Add(123456789)},
+		{"Without zone", "2025-09-22T09:17:21.5", want.Add(500 * time.Millisecond)},
+		{"Space separated", "2025-09-22 09:17:21", want},
+		{"Space separated with zone", "2025-09-22 09:17:21+00:00", want},
+		{"Date only", "2025-09-22", time.Date(2025, 9, 22, 0, 0, 0, 0, time.UTC)},
+		{"Unix seconds", "1758532641", want},
+		{"Unix float seconds", "1758532641.25", want.Add(250 * time.Millisecond)},
+		{"Unix milliseconds", "1758532641250", want.Add(250 * time.Millisecond)},
+		{"Surrounding whitespace", " 2025-09-22T09:17:21Z ", want},
+		{"Empty", "", time.Time{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := timestamp.ParseFlexibleTime(tc.value)
+			require.NoError(t, err)
+			assert.True(t, tc.want.Equal(got), "got %s", got)
+			assert.Equal(t, time.UTC, got.Location())
+		})
+	}
+
+	t.Run("Malformed", func(t *testing.T) {
+		for _, value := range []string{"yesterday", "2025-13-01", "22/09/2025", "NaN"} {
+			got, err := timestamp.ParseFlexibleTime(value)
+			assert.Error(t, err, value)
+			assert.True(t, got.IsZero(), value)
+		}
+	})
+}
+
+func TestClaudeParser_Timestamps(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	previous := logger.Logger
+	logger.Logger = zap.New(core)
+	t.Cleanup(func() { logger.Logger = previous })
+
+	data := `[{"uuid":"c1","name":"Times","created_at":"2025-09-22T09:17:21.803710Z","updated_at":"2025-09-22 10:00:00",` +
+		`"chat_messages":[` +
+		`{"uuid":"m1","sender":"human","text":"hi","created_at":"1758532641"},` +
+		`{"uuid":"m2","sender":"assistant","text":"hello","created_at":"not a time"}]}]`
+
+	parser, err := parsers.GetParser("claude")
+	require.NoError(t, err)
+	standardData, err := parser.Parse([]byte(data))
+	require.NoError(t, err)
+
+	conversation := standardData.Conversations[0]
+	assert.Equal(t, time.Date(2025, 9, 22, 9, 17, 21, 803710000, time.UTC), conversation.CreatedAt)
+	assert.Equal(t, time.Date(2025, 9, 22, 10, 0, 0, 0, time.UTC), conversation.UpdatedAt)
+	assert.Equal(t, time.Date(2025, 9, 22, 9, 17, 21, 0, time.UTC), conversation.Messages[0].CreatedAt)
+	// 无法解析的时间留空由 Transformer 补齐，并记录警告
+	assert.True(t, conversation.Messages[1].CreatedAt.IsZero())
+
+	entries := logs.FilterMessage("Unparseable timestamp in Claude export").All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "m2", entries[0].ContextMap()["id"])
+	assert.Equal(t, "not a time", entries[0].ContextMap()["value"])
+}
+
+func TestLoader_PersistsAttachments(t *testing.T) {
+	db := openTestDB(t)
+
+	user := &models.User{Username: "test-" + uuid.NewString()[:8]}
+	require.NoError(t, db.Create(user).Error)
+
+	parsers.RegisterAll()
+	parser, err := parsers.GetParser("claude")
+	require.NoError(t, err)
+	standardData, err := parser.Parse([]byte(claudeExportWithAttachments))
+	require.NoError(t, err)
+
+	loader := importer.NewLoader(&config.Config{})
+	loader.SetDependencies(db, nil, nil)
+
+	// 重复导入不会产生重复的附件
+	for i := 0; i < 2; i++ {
+		conversations, messages, err := importer.NewTransformer().Transform(standardData, user.ID, "claude")
+		require.NoError(t, err)
+		require.NoError(t, loader.Load(context.Background(), conversations, messages))
+	}
+
+	var conversation models.Conversation
+	require.NoError(t, db.Where("user_id = ? AND source_id = ?", user.ID, "c-att").First(&conversation).Error)
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM message_attachments WHERE message_id IN (SELECT id FROM messages WHERE conversation_id = ?)", conversation.ID)
+		db.Unscoped().Where("conversation_id = ?", conversation.ID).Delete(&models.Message{})
+		db.Unscoped().Delete(&conversation)
+		db.Unscoped().Delete(user)
+	})
+
+	message, err := repositories.NewMessageRepository(db).GetByID(conversationMessageID(t, db, conversation.ID, "m-att"))
+	require.NoError(t, err)
+	require.Len(t, message.Attachments, 2)
+
+	// 导入后按消息计算最后活跃时间
+	require.NotNil(t, conversation.LastMessageAt)
+	assert.True(t, conversation.LastMessageAt.Equal(message.CreatedAt) || conversation.LastMessageAt.After(message.CreatedAt))
+
+	names := []string{message.Attachments[0].Name, message.Attachments[1].Name}
+	assert.ElementsMatch(t, []string{"report.pdf", "chart.png"}, names)
+}
+
+// TestLoader_DeterministicIDsAfterTransfer re-imports an export for its original user after
+// the conversation was transferred away; the derived IDs are taken, so random ones are used
+func TestLoader_DeterministicIDsAfterTransfer(t *testing.T) {
+	db := openTestDB(t)
+
+	owner := &models.User{Username: "test-" + uuid.NewString()[:8]}
+	receiver := &models.User{Username: "test-" + uuid.NewString()[:8]}
+	require.NoError(t, db.Create(owner).Error)
+	require.NoError(t, db.Create(receiver).Error)
+	t.Cleanup(func() {
+		conversations := db.Model(&models.Conversation{}).Select("id").Where("user_id IN ?", []uuid.UUID{owner.ID, receiver.ID})
+		db.Exec("DELETE FROM message_attachments WHERE message_id IN (SELECT id FROM messages WHERE conversation_id IN (?))", conversations)
+		db.Unscoped().Where("conversation_id IN (?)", conversations).Delete(&models.Message{})
+		db.Unscoped().Where("user_id IN ?", []uuid.UUID{owner.ID, receiver.ID}).Delete(&models.Conversation{})
+		db.Unscoped().Delete([]*models.User{owner, receiver})
+	})
+
+	parsers.RegisterAll()
+	parser, err := parsers.GetParser("claude")
+	require.NoError(t, err)
+	standardData, err := parser.Parse([]byte(claudeExportWithAttachments))
+	require.NoError(t, err)
+
+	cfg := &config.Config{}
+	cfg.Import.DeterministicIDs = true
+	loader := importer.NewLoader(cfg)
+	loader.SetDependencies(db, nil, nil)
+	load := func() {
+		transformer := importer.NewTransformerWithOptions(importer.TransformerOptions{DeterministicIDs: true})
+		conversations, messages, err := transformer.Transform(standardData, owner.ID, "claude")
+		require.NoError(t, err)
+		require.NoError(t, loader.Load(context.Background(), conversations, messages))
+	}
+
+	load()
+	var first models.Conversation
+	require.NoError(t, db.Where("user_id = ? AND source_id = ?", owner.ID, "c-att").First(&first).Error)
+	updated, err := repositories.NewConversationRepository(db).UpdateUserID(first.ID, receiver.ID)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	load()
+	var second models.Conversation
+	require.NoError(t, db.Where("user_id = ? AND source_id = ?", owner.ID, "c-att").First(&second).Error)
+	assert.NotEqual(t, first.ID, second.ID)
+
+	message, err := repositories.NewMessageRepository(db).GetByID(conversationMessageID(t, db, second.ID, "m-att"))
+	require.NoError(t, err)
+	assert.Len(t, message.Attachments, 2)
+}
+
+// conversationMessageID looks up a message ID by its source ID within a conversation
+func conversationMessageID(t *testing.T, db *gorm.DB, conversationID uuid.UUID, sourceID string) uuid.UUID {
+	var message models.Message
+	require.NoError(t, db.Where("conversation_id = ? AND source_id = ?", conversationID, sourceID).First(&message).Error)
+	return message.ID
+}
@@ -0,0 +1,183 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/jobs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitForJob(t *testing.T, manager *jobs.Manager, jobID string) *jobs.Job {
+	t.Helper()
+
+	var job *jobs.Job
+	require.Eventually(t, func() bool {
+		var ok bool
+		job, ok = manager.Get(jobID)
+		require.True(t, ok)
+		return job.Finished()
+	}, time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestJobManager_Lifecycle(t *testing.T) {
+	manager := jobs.NewManager(10, time.Hour)
+	release := make(chan struct{})
+
+	jobID := manager.Submit(func(ctx context.Context, job *jobs.Job) error {
+		job.SetProgress(1, 3)
+		<-release
+		job.SetProgress(3, 3)
+		return nil
+	})
+
+	require.Eventually(t, func() bool {
+		job, _ := manager.Get(jobID)
+		return job.State == jobs.StateRunning && job.Processed == 1
+	}, time.Second, 5*time.Millisecond)
+
+	close(release)
+	job := waitForJob(t, manager, jobID)
+
+	assert.Equal(t, jobs.StateDone, job.State)
+	assert.Equal(t, 3, job.Processed)
+	assert.Equal(t, 3, job.Total)
+	assert.NotNil(t, job.StartedAt)
+	assert.NotNil(t, job.FinishedAt)
+	assert.Empty(t, job.Error)
+}
+
+func TestJobManager_Failures(t *testing.T) {
+	manager := jobs.NewManager(10, time.Hour)
+
+	t.Run("Returned error", func(t *testing.T) {
+		jobID := manager.Submit(func(ctx context.Context, job *jobs.Job) error {
+			return fmt.Errorf("boom")
+		})
+		job := waitForJob(t, manager, jobID)
+
+		assert.Equal(t, jobs.StateFailed, job.State)
+		assert.Equal(t, "boom", job.Error)
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		jobID := manager.Submit(func(ctx context.Context, job *jobs.Job) error {
+			panic("unexpected")
+		})
+		job := waitForJob(t, manager, jobID)
+
+		assert.Equal(t, jobs.StateFailed, job.State)
+		assert.Contains(t, job.Error, "unexpected")
+	})
+
+	t.Run("Unknown job", func(t *testing.T) {
+		_, ok := manager.Get("missing")
+		assert.False(t, ok)
+	})
+}
+
+func TestJobManager_HistoryAndExpiry(t *testing.T) {
+	t.Run("History is capped", func(t *testing.T) {
+		manager := jobs.NewManager(2, time.Hour)
+		noop := func(ctx context.Context, job *jobs.Job) error { return nil }
+
+		var ids []string
+		for i := 0; i < 3; i++ {
+			id := manager.Submit(noop)
+			waitForJob(t, manager, id)
+			ids = append(ids, id)
+		}
+
+		// 下一次提交时淘汰最早结束的任务
+		waitForJob(t, manager, manager.Submit(noop))
+
+		_, ok := manager.Get(ids[0])
+		assert.False(t, ok)
+		_, ok = manager.Get(ids[2])
+		assert.True(t, ok)
+	})
+
+	t.Run("Finished jobs expire", func(t *testing.T) {
+		manager := jobs.NewManager(10, 20*time.Millisecond)
+		jobID := manager.Submit(func(ctx context.Context, job *jobs.Job) error { return nil })
+		waitForJob(t, manager, jobID)
+
+		require.Eventually(t, func() bool {
+			_, ok := manager.Get(jobID)
+			return !ok
+		}, time.Second, 5*time.Millisecond)
+	})
+}
+
+func TestJobManager_ConcurrentUse(t *testing.T) {
+	manager := jobs.NewManager(100, time.Hour)
+
+	var wg sync.WaitGroup
+	ids := make(chan string, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := manager.Submit(func(ctx context.Context, job *jobs.Job) error {
+				for p := 1; p <= 10; p++ {
+					job.SetProgress(p, 10)
+				}
+				return nil
+			})
+			manager.Get(id)
+			ids <- id
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	for id := range ids {
+		job := waitForJob(t, manager, id)
+		assert.Equal(t, jobs.StateDone, job.State)
+		assert.Equal(t, 10, job.Processed)
+	}
+}
+
+func TestManager_StopWaitsForRunningJobs(t *testing.T) {
+	t.Run("Drains running jobs", func(t *testing.T) {
+		manager := jobs.NewManager(10, time.Minute)
+		release := make(chan struct{})
+		jobID := manager.Submit(func(ctx context.Context, job *jobs.Job) error {
+			<-release
+			return nil
+		})
+
+		time.AfterFunc(50*time.Millisecond, func() { close(release) })
+		require.NoError(t, manager.Stop(context.Background()))
+
+		job, ok := manager.Get(jobID)
+		require.True(t, ok)
+		assert.Equal(t, jobs.StateDone, job.State)
+	})
+
+	t.Run("Cancels jobs when the deadline expires", func(t *testing.T) {
+		manager := jobs.NewManager(10, time.Minute)
+		cancelled := make(chan struct{})
+		manager.Submit(func(ctx context.Context, job *jobs.Job) error {
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		assert.ErrorIs(t, manager.Stop(ctx), context.DeadlineExceeded)
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatal("job context was not cancelled")
+		}
+	})
+}
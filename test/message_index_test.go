@@ -0,0 +1,934 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/repositories"
+
+	es "github.com/elastic/go-elasticsearch/v8"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// indexES 是一个内存中的 ES，保存对话索引和独立消息索引的文档，按简化的规则执行查询：
+// multi_match 按小写子串匹配，每个匹配的 should 子句得 1 分，constant_score 得 boost 分
+type indexES struct {
+	mu      sync.Mutex
+	indexes map[string]map[string]map[string]interface{}
+	// deletes 收到的 _delete_by_query 请求
+	deletes []deleteByQueryRequest
+}
+
+type deleteByQueryRequest struct {
+	index   string
+	refresh string
+	query   map[string]interface{}
+}
+
+func newIndexES(t *testing.T) (*indexES, *es.Client) {
+	store := &indexES{indexes: map[string]map[string]map[string]interface{}{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		store.mu.Lock()
+		defer store.mu.Unlock()
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case parts[len(parts)-1] == "_bulk":
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": false, "items": store.bulk(t, r)})
+		case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodGet:
+			doc, ok := store.docs(parts[0])[parts[2]]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]interface{}{"_id": parts[2], "found": false})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"_id": parts[2], "found": true, "_source": doc})
+		case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodHead:
+			if _, ok := store.docs(parts[0])[parts[2]]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodDelete:
+			delete(store.docs(parts[0]), parts[2])
+			w.Write([]byte(`{"result":"deleted"}`))
+		case len(parts) == 3 && parts[1] == "_doc":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			result := "created"
+			if _, ok := store.docs(parts[0])[parts[2]]; ok {
+				result = "updated"
+			}
+			store.docs(parts[0])[parts[2]] = body
+			json.NewEncoder(w).Encode(map[string]interface{}{"_id": parts[2], "result": result})
+		case len(parts) == 3 && parts[1] == "_update":
+			doc, ok := store.docs(parts[0])[parts[2]]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			var body struct {
+				Doc    map[string]interface{} `json:"doc"`
+				Script struct {
+					Params map[string]interface{} `json:"params"`
+				} `json:"script"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			for key, value := range body.Doc {
+				doc[key] = value
+			}
+			updateMessages(doc, body.Script.Params)
+			w.Write([]byte(`{"result":"updated"}`))
+		case parts[len(parts)-1] == "_delete_by_query":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			store.deletes = append(store.deletes, deleteByQueryRequest{
+				index:   parts[0],
+				refresh: r.URL.Query().Get("refresh"),
+				query:   body["query"].(map[string]interface{}),
+			})
+			deleted := 0
+			for id, doc := range store.docs(parts[0]) {
+				if ok, _ := matchQuery(doc, body["query"], ""); ok {
+					delete(store.docs(parts[0]), id)
+					deleted++
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"deleted": deleted})
+		case parts[len(parts)-1] == "_update_by_query":
+			var body struct {
+				Query  interface{} `json:"query"`
+				Script struct {
+					Params map[string]interface{} `json:"params"`
+				} `json:"script"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			for _, doc := range store.docs(parts[0]) {
+				if ok, _ := matchQuery(doc, body.Query, ""); ok {
+					updateFlatMessage(doc, body.Script.Params)
+				}
+			}
+			w.Write([]byte(`{"updated":1}`))
+		case parts[len(parts)-1] == "_mget":
+			var body struct {
+				IDs []string `json:"ids"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			docs := make([]map[string]interface{}, 0, len(body.IDs))
+			for _, id := range body.IDs {
+				doc, ok := store.docs(parts[0])[id]
+				docs = append(docs, map[string]interface{}{"_id": id, "found": ok, "_source": doc})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"docs": docs})
+		case parts[len(parts)-1] == "_search":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			if aggs, ok := body["aggs"].(map[string]interface{}); ok {
+				json.NewEncoder(w).Encode(store.composite(parts[0], body["query"], aggs))
+				return
+			}
+			json.NewEncoder(w).Encode(store.search(parts[0], body))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := es.NewClient(es.Config{Addresses: []string{server.URL}})
+	require.NoError(t, err)
+	return store, client
+}
+
+func (s *indexES) docs(index string) map[string]map[string]interface{} {
+	if s.indexes[index] == nil {
+		s.indexes[index] = map[string]map[string]interface{}{}
+	}
+	return s.indexes[index]
+}
+
+// bulk 执行 index 和 delete 操作，没有 _index 时使用 URL 中的索引，返回各操作的结果
+func (s *indexES) bulk(t *testing.T, r *http.Request) []map[string]interface{} {
+	var items []map[string]interface{}
+	defaultIndex, _, _ := strings.Cut(strings.Trim(r.URL.Path, "/"), "/")
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &action))
+		for op, meta := range action {
+			index := meta.Index
+			if index == "" {
+				index = defaultIndex
+			}
+			switch op {
+			case "index":
+				require.True(t, scanner.Scan())
+				var doc map[string]interface{}
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &doc))
+				result := "created"
+				if _, ok := s.docs(index)[meta.ID]; ok {
+					result = "updated"
+				}
+				s.docs(index)[meta.ID] = doc
+				items = append(items, map[string]interface{}{op: map[string]interface{}{"_id": meta.ID, "result": result}})
+			case "delete":
+				delete(s.docs(index), meta.ID)
+				items = append(items, map[string]interface{}{op: map[string]interface{}{"_id": meta.ID, "result": "deleted"}})
+			default:
+				t.Errorf("unexpected bulk action %s", op)
+			}
+		}
+	}
+	return items
+}
+
+// search 返回得分大于 0 的文档，_source.excludes 为 messages 时不返回嵌套消息
+func (s *indexES) search(index string, body map[string]interface{}) map[string]interface{} {
+	type hit struct {
+		id    string
+		score float64
+		doc   map[string]interface{}
+	}
+	var hits []hit
+	for id, doc := range s.docs(index) {
+		if ok, score := matchQuery(doc, body["query"], ""); ok && score > 0 {
+			hits = append(hits, hit{id, score, doc})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].id < hits[j].id
+	})
+
+	excludeMessages := false
+	if source, ok := body["_source"].(map[string]interface{}); ok {
+		excludeMessages = fmt.Sprint(source["excludes"]) == "[messages]"
+	}
+	results := make([]map[string]interface{}, len(hits))
+	for i, h := range hits {
+		source := make(map[string]interface{}, len(h.doc))
+		for key, value := range h.doc {
+			if key != "messages" || !excludeMessages {
+				source[key] = value
+			}
+		}
+		results[i] = map[string]interface{}{"_id": h.id, "_score": h.score, "_source": source, "highlight": highlight(h.doc, body)}
+		if inner := innerHits(h.doc, body["query"]); len(inner) > 0 {
+			results[i]["inner_hits"] = inner
+		}
+	}
+
+	return map[string]interface{}{
+		"took":      1,
+		"timed_out": false,
+		"_shards":   map[string]interface{}{"total": 1, "successful": 1, "skipped": 0, "failed": 0},
+		"hits": map[string]interface{}{
+			"total": map[string]interface{}{"value": len(results), "relation": "eq"},
+			"hits":  results,
+		},
+	}
+}
+
+// innerHits 为查询中带 inner_hits 的嵌套子句返回 doc 中匹配的嵌套对象（最多 size 个）
+func innerHits(doc map[string]interface{}, query interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if nested, ok := v["nested"].(map[string]interface{}); ok {
+				if spec, ok := nested["inner_hits"].(map[string]interface{}); ok {
+					path := nested["path"].(string)
+					items, _ := doc[path].([]interface{})
+					var hits []interface{}
+					for _, item := range items {
+						if len(hits) == int(spec["size"].(float64)) {
+							break
+						}
+						if ok, _ := matchQuery(item.(map[string]interface{}), nested["query"], path); ok {
+							hits = append(hits, map[string]interface{}{"_source": item})
+						}
+					}
+					if len(hits) > 0 {
+						result[spec["name"].(string)] = map[string]interface{}{"hits": map[string]interface{}{"hits": hits}}
+					}
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(query)
+	return result
+}
+
+// composite 执行名为 ids 的单字段 composite 聚合，按值排序后从 after 开始返回 size 个桶
+func (s *indexES) composite(index string, query interface{}, aggs map[string]interface{}) map[string]interface{} {
+	spec := aggs["ids"].(map[string]interface{})["composite"].(map[string]interface{})
+	source := spec["sources"].([]interface{})[0].(map[string]interface{})["id"].(map[string]interface{})
+	field := source["terms"].(map[string]interface{})["field"].(string)
+
+	seen := map[string]bool{}
+	var values []string
+	for _, doc := range s.docs(index) {
+		if ok, _ := matchQuery(doc, query, ""); ok {
+			value := fmt.Sprint(doc[field])
+			if !seen[value] {
+				seen[value] = true
+				values = append(values, value)
+			}
+		}
+	}
+	sort.Strings(values)
+
+	if after, ok := spec["after"].(map[string]interface{}); ok {
+		start := sort.SearchStrings(values, fmt.Sprint(after["id"]))
+		if start < len(values) && values[start] == fmt.Sprint(after["id"]) {
+			start++
+		}
+		values = values[start:]
+	}
+	if size := int(spec["size"].(float64)); len(values) > size {
+		values = values[:size]
+	}
+
+	buckets := make([]map[string]interface{}, len(values))
+	for i, value := range values {
+		buckets[i] = map[string]interface{}{"key": map[string]interface{}{"id": value}, "doc_count": 1}
+	}
+	ids := map[string]interface{}{"buckets": buckets}
+	if len(values) > 0 {
+		ids["after_key"] = map[string]interface{}{"id": values[len(values)-1]}
+	}
+	return map[string]interface{}{"hits": map[string]interface{}{"hits": []interface{}{}}, "aggregations": map[string]interface{}{"ids": ids}}
+}
+
+// highlight 为请求的高亮字段中包含关键词的值生成片段（不加标记），嵌套字段取第一条包含关键词的消息
+func highlight(doc map[string]interface{}, body map[string]interface{}) map[string]interface{} {
+	text := strings.ToLower(queryText(body["query"]))
+	fields, _ := body["highlight"].(map[string]interface{})["fields"].(map[string]interface{})
+	result := map[string]interface{}{}
+	for field := range fields {
+		values := []interface{}{fieldValue(doc, field, "")}
+		if path, sub, nested := strings.Cut(field, "."); nested && path == "messages" {
+			items, _ := doc[path].([]interface{})
+			values = make([]interface{}, len(items))
+			for i, item := range items {
+				values[i] = fieldValue(item.(map[string]interface{}), sub, "")
+			}
+		}
+		for _, value := range values {
+			if str, ok := value.(string); ok && text != "" && strings.Contains(strings.ToLower(str), text) {
+				result[field] = []interface{}{str}
+				break
+			}
+		}
+	}
+	return result
+}
+
+// queryText 返回查询中第一个 multi_match 的关键词
+func queryText(query interface{}) string {
+	switch q := query.(type) {
+	case map[string]interface{}:
+		if match, ok := q["multi_match"].(map[string]interface{}); ok {
+			return fmt.Sprint(match["query"])
+		}
+		for _, value := range q {
+			if text := queryText(value); text != "" {
+				return text
+			}
+		}
+	case []interface{}:
+		for _, value := range q {
+			if text := queryText(value); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// matchQuery 判断 doc 是否匹配 query 并返回得分；path 为嵌套查询的路径，字段名去掉该前缀。
+// 未识别的查询类型视为匹配且不计分
+func matchQuery(doc map[string]interface{}, query interface{}, path string) (bool, float64) {
+	q, _ := query.(map[string]interface{})
+	for kind, value := range q {
+		spec, _ := value.(map[string]interface{})
+		switch kind {
+		case "bool":
+			score := 0.0
+			for _, clause := range clauses(spec["filter"]) {
+				if ok, _ := matchQuery(doc, clause, path); !ok {
+					return false, 0
+				}
+			}
+			for _, clause := range clauses(spec["must"]) {
+				ok, s := matchQuery(doc, clause, path)
+				if !ok {
+					return false, 0
+				}
+				score += s
+			}
+			for _, clause := range clauses(spec["must_not"]) {
+				if ok, _ := matchQuery(doc, clause, path); ok {
+					return false, 0
+				}
+			}
+			matched := 0
+			for _, clause := range clauses(spec["should"]) {
+				if ok, s := matchQuery(doc, clause, path); ok && s > 0 {
+					matched++
+					score += s
+				}
+			}
+			minimum := 0
+			if spec["filter"] == nil && spec["must"] == nil && spec["should"] != nil {
+				minimum = 1
+			}
+			if m, ok := spec["minimum_should_match"].(float64); ok {
+				minimum = int(m)
+			}
+			return matched >= minimum, score
+		case "term":
+			for field, expected := range spec {
+				return containsValue(fieldValue(doc, field, path), expected), 0
+			}
+		case "terms":
+			for field, expected := range spec {
+				actual := fieldValue(doc, field, path)
+				for _, v := range expected.([]interface{}) {
+					if containsValue(actual, v) {
+						return true, 0
+					}
+				}
+				return false, 0
+			}
+		case "range":
+			for field, bounds := range spec {
+				actual, err := time.Parse(time.RFC3339, fmt.Sprint(fieldValue(doc, field, path)))
+				if err != nil {
+					return false, 0
+				}
+				b := bounds.(map[string]interface{})
+				if gte, ok := b["gte"].(string); ok {
+					if limit, _ := time.Parse(time.RFC3339, gte); actual.Before(limit) {
+						return false, 0
+					}
+				}
+				if lte, ok := b["lte"].(string); ok {
+					if limit, _ := time.Parse(time.RFC3339, lte); actual.After(limit) {
+						return false, 0
+					}
+				}
+				return true, 0
+			}
+		case "ids":
+			for _, v := range spec["values"].([]interface{}) {
+				if v == doc["id"] {
+					return true, 0
+				}
+			}
+			return false, 0
+		case "constant_score":
+			ok, _ := matchQuery(doc, spec["filter"], path)
+			boost, _ := spec["boost"].(float64)
+			return ok, boost
+		case "multi_match":
+			text := strings.ToLower(fmt.Sprint(spec["query"]))
+			for _, field := range spec["fields"].([]interface{}) {
+				if strings.Contains(strings.ToLower(fmt.Sprint(fieldValue(doc, field.(string), path))), text) {
+					return true, 1
+				}
+			}
+			return false, 0
+		case "nested":
+			nestedPath := spec["path"].(string)
+			items, _ := doc[nestedPath].([]interface{})
+			best := 0.0
+			found := false
+			for _, item := range items {
+				if ok, s := matchQuery(item.(map[string]interface{}), spec["query"], nestedPath); ok {
+					found = true
+					best = max(best, s)
+				}
+			}
+			return found, best
+		}
+	}
+	return true, 0
+}
+
+// containsValue 判断字段值等于 expected，数组字段包含 expected 即可
+func containsValue(actual, expected interface{}) bool {
+	if values, ok := actual.([]interface{}); ok {
+		for _, value := range values {
+			if fmt.Sprint(value) == fmt.Sprint(expected) {
+				return true
+			}
+		}
+		return false
+	}
+	return fmt.Sprint(actual) == fmt.Sprint(expected)
+}
+
+func clauses(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case nil:
+		return nil
+	default:
+		return []interface{}{v}
+	}
+}
+
+// fieldValue 读取字段值，忽略 ^boost 和 .exact 等子字段
+func fieldValue(doc map[string]interface{}, field, path string) interface{} {
+	field, _, _ = strings.Cut(field, "^")
+	if path != "" {
+		field = strings.TrimPrefix(field, path+".")
+	}
+	field, _, _ = strings.Cut(field, ".")
+	return doc[field]
+}
+
+// updateMessages 模拟索引器更新嵌套消息的脚本
+func updateMessages(doc map[string]interface{}, params map[string]interface{}) {
+	messages, _ := doc["messages"].([]interface{})
+	removed := map[interface{}]bool{}
+	if id, ok := params["messageId"]; ok {
+		removed[id] = true
+	}
+	if ids, ok := params["ids"].([]interface{}); ok {
+		for _, id := range ids {
+			removed[id] = true
+		}
+	}
+
+	var kept []interface{}
+	for _, message := range messages {
+		if !removed[message.(map[string]interface{})["id"]] {
+			kept = append(kept, message)
+		}
+	}
+	if message, ok := params["message"]; ok {
+		kept = append(kept, message)
+	}
+	doc["messages"] = kept
+}
+
+// updateFlatMessage 模拟索引器更新独立消息索引中对话字段的脚本：带 tagIds 时整体替换，
+// 只带 tagId 时移除该标签
+func updateFlatMessage(doc map[string]interface{}, params map[string]interface{}) {
+	if tagIDs, ok := params["tagIds"]; ok {
+		doc["user_id"] = params["userId"]
+		doc["provider"] = params["provider"]
+		doc["conversation_created_at"] = params["createdAt"]
+		doc["tag_ids"] = tagIDs
+		return
+	}
+	var kept []interface{}
+	for _, id := range clauses(doc["tag_ids"]) {
+		if id != params["tagId"] {
+			kept = append(kept, id)
+		}
+	}
+	doc["tag_ids"] = kept
+}
+
+// newLongConversation 创建一个有 count 条消息的对话，第 i 条消息的内容为 contents[i]（没有时为填充内容）
+func newLongConversation(userID uuid.UUID, title string, count int, contents map[int]string) *models.ConversationDocument {
+	conversation := &models.Conversation{Base: models.Base{ID: uuid.New()}, UserID: userID, Title: title}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < count; i++ {
+		content, ok := contents[i]
+		if !ok {
+			content = fmt.Sprintf("filler message %d", i)
+		}
+		conversation.Messages = append(conversation.Messages, models.Message{
+			Base:           models.Base{ID: uuid.New(), CreatedAt: start.Add(time.Duration(i) * time.Minute)},
+			ConversationID: conversation.ID,
+			Role:           []string{"user", "assistant"}[i%2],
+			Content:        content,
+			Sequence:       i + 1,
+		})
+	}
+	return conversation.ToESDocument()
+}
+
+// searchSummary 搜索结果中的对话 ID 和各对话匹配的消息 ID
+func searchSummary(result *repositories.SearchResult) ([]uuid.UUID, map[uuid.UUID][]uuid.UUID) {
+	ids := make([]uuid.UUID, len(result.Documents))
+	messages := make(map[uuid.UUID][]uuid.UUID)
+	for i, doc := range result.Documents {
+		ids[i] = doc.ID
+		for _, message := range result.MatchedMessages[doc.ID] {
+			messages[doc.ID] = append(messages[doc.ID], message.ID)
+		}
+	}
+	return ids, messages
+}
+
+func TestFlatMessageIndex_MatchesNestedSearch(t *testing.T) {
+	store, client := newIndexES(t)
+	userID := uuid.New()
+	early := newLongConversation(userID, "Concurrency notes", 4, map[int]string{0: "how do golang channels work"})
+	recent := newLongConversation(userID, "Tooling", 4, map[int]string{3: "golang modules and workspaces"})
+	titled := newLongConversation(userID, "Golang generics", 2, nil)
+	unrelated := newLongConversation(userID, "Cooking", 3, nil)
+	otherUser := newLongConversation(uuid.New(), "Other", 2, map[int]string{1: "golang too"})
+	work := models.TagDocument{ID: uuid.New(), Name: "work"}
+	early.Provider, recent.Provider, titled.Provider = "openai", "claude", "openai"
+	early.Tags = []models.TagDocument{work}
+	recent.CreatedAt = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	indexer := repositories.NewElasticsearchIndexerWithOptions(client, "conversations", repositories.IndexerOptions{MessageIndex: "messages"})
+	require.NoError(t, indexer.BulkIndexConversations([]*models.ConversationDocument{early, recent, titled, unrelated, otherUser}))
+
+	// 每条消息一个文档，带上对话的 user_id
+	require.Len(t, store.docs("messages"), 4+4+2+3+2)
+	flat := store.docs("messages")[early.Messages[0].ID.String()]
+	assert.Equal(t, userID.String(), flat["user_id"])
+	assert.Equal(t, early.ID.String(), flat["conversation_id"])
+	assert.Equal(t, "openai", flat["provider"])
+	assert.Equal(t, []interface{}{work.ID.String()}, flat["tag_ids"])
+
+	nested := repositories.NewElasticsearchRepository(client, "conversations", 0)
+	flatRepo := repositories.NewFlatMessageSearchRepository(client, "conversations", "messages", repositories.SearchRepositoryOptions{})
+	provider := "openai"
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name   string
+		params repositories.SearchParams
+	}{
+		{"All fields", repositories.SearchParams{Query: "golang", UserID: &userID, Page: 1, Limit: 10}},
+		{"Messages only", repositories.SearchParams{Query: "golang", UserID: &userID, Fields: []string{repositories.SearchFieldMessages}, Page: 1, Limit: 10}},
+		{"Title only", repositories.SearchParams{Query: "golang", UserID: &userID, Fields: []string{repositories.SearchFieldTitle}, Page: 1, Limit: 10}},
+		{"No match", repositories.SearchParams{Query: "rust", UserID: &userID, Fields: []string{repositories.SearchFieldMessages}, Page: 1, Limit: 10}},
+		{"Provider filter", repositories.SearchParams{Query: "golang", UserID: &userID, ProviderID: &provider, Page: 1, Limit: 10}},
+		{"Tag filter", repositories.SearchParams{Query: "golang", UserID: &userID, TagIDs: []uuid.UUID{work.ID}, Page: 1, Limit: 10}},
+		{"Date filter", repositories.SearchParams{Query: "golang", UserID: &userID, StartDate: &since, Page: 1, Limit: 10}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			nestedResult, err := nested.SearchConversationsWithMatchedMessages(context.Background(), tc.params)
+			require.NoError(t, err)
+			flatResult, err := flatRepo.SearchConversationsWithMatchedMessages(context.Background(), tc.params)
+			require.NoError(t, err)
+
+			nestedIDs, nestedMessages := searchSummary(nestedResult)
+			flatIDs, flatMessages := searchSummary(flatResult)
+			assert.ElementsMatch(t, nestedIDs, flatIDs)
+			assert.Equal(t, nestedMessages, flatMessages)
+			assert.Equal(t, nestedResult.Total, flatResult.Total)
+			assert.NotContains(t, flatIDs, otherUser.ID)
+		})
+	}
+
+	t.Run("Messages beyond the nested cap", func(t *testing.T) {
+		store, client := newIndexES(t)
+		indexer := repositories.NewElasticsearchIndexerWithOptions(client, "conversations", repositories.IndexerOptions{
+			MaxMessages:  2,
+			MessageIndex: "messages",
+		})
+		require.NoError(t, indexer.IndexConversation(early))
+
+		// 嵌套文档只保留最近两条消息，独立索引保留全部消息
+		assert.Len(t, store.docs("conversations")[early.ID.String()]["messages"], 2)
+		assert.Len(t, store.docs("messages"), 4)
+
+		params := repositories.SearchParams{Query: "channels", UserID: &userID, Page: 1, Limit: 10}
+		nestedResult, err := repositories.NewElasticsearchRepository(client, "conversations", 0).
+			SearchConversationsWithMatchedMessages(context.Background(), params)
+		require.NoError(t, err)
+		assert.Empty(t, nestedResult.Documents)
+
+		flatResult, err := repositories.NewFlatMessageSearchRepository(client, "conversations", "messages", repositories.SearchRepositoryOptions{}).
+			SearchConversationsWithMatchedMessages(context.Background(), params)
+		require.NoError(t, err)
+		require.Len(t, flatResult.Documents, 1)
+		assert.Equal(t, early.ID, flatResult.Documents[0].ID)
+		// 匹配的消息在前，其余为嵌套文档中的上下文消息
+		matched := flatResult.MatchedMessages[early.ID]
+		require.Len(t, matched, 3)
+		assert.Equal(t, early.Messages[0].ID, matched[0].ID)
+		assert.False(t, flatResult.ContextMessages[matched[0].ID])
+		assert.True(t, flatResult.ContextMessages[matched[1].ID])
+	})
+
+	t.Run("Matched messages keep the conversation order", func(t *testing.T) {
+		_, client := newIndexES(t)
+		indexer := repositories.NewElasticsearchIndexerWithOptions(client, "conversations", repositories.IndexerOptions{
+			MaxMessages:  1,
+			MessageIndex: "messages",
+		})
+		// 回填时间的导入消息：时间与对话内顺序相反
+		conversation := newLongConversation(userID, "Backfilled", 3, map[int]string{0: "golang first", 2: "golang last"})
+		for i := range conversation.Messages {
+			conversation.Messages[i].CreatedAt = time.Date(2024, 1, 1, 0, 10-i, 0, 0, time.UTC)
+		}
+		require.NoError(t, indexer.IndexConversation(conversation))
+
+		params := repositories.SearchParams{Query: "golang", UserID: &userID, Fields: []string{repositories.SearchFieldMessages}, Page: 1, Limit: 10}
+		result, err := repositories.NewFlatMessageSearchRepository(client, "conversations", "messages", repositories.SearchRepositoryOptions{}).
+			SearchConversationsWithMatchedMessages(context.Background(), params)
+		require.NoError(t, err)
+		require.Len(t, result.Documents, 1)
+
+		var matched []uuid.UUID
+		for _, message := range result.MatchedMessages[conversation.ID] {
+			if !result.ContextMessages[message.ID] {
+				matched = append(matched, message.ID)
+			}
+		}
+		assert.Equal(t, []uuid.UUID{conversation.Messages[0].ID, conversation.Messages[2].ID}, matched)
+	})
+}
+
+func TestFlatMessageIndex_DualWrite(t *testing.T) {
+	store, client := newIndexES(t)
+	userID := uuid.New()
+	doc := newLongConversation(userID, "Channels", 3, nil)
+	indexer := repositories.NewElasticsearchIndexerWithOptions(client, "conversations", repositories.IndexerOptions{MessageIndex: "messages"})
+	require.NoError(t, indexer.IndexConversation(doc))
+	require.Len(t, store.docs("messages"), 3)
+	// 新建的对话没有旧消息，不需要删除
+	assert.Empty(t, store.deletes)
+
+	messageIDs := func() []string {
+		ids := make([]string, 0, len(store.docs("messages")))
+		for id := range store.docs("messages") {
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	t.Run("Add and update message", func(t *testing.T) {
+		message := models.MessageDocument{ID: uuid.New(), ConversationID: doc.ID, Role: "user", Content: "new message"}
+		require.NoError(t, indexer.AddMessageToConversation(doc.ID, message))
+		flat := store.docs("messages")[message.ID.String()]
+		require.NotNil(t, flat)
+		assert.Equal(t, userID.String(), flat["user_id"])
+
+		message.Content = "edited message"
+		require.NoError(t, indexer.UpdateMessageInConversation(doc.ID, message))
+		assert.Equal(t, "edited message", store.docs("messages")[message.ID.String()]["content"])
+
+		require.NoError(t, indexer.RemoveMessageFromConversation(doc.ID, message.ID))
+		assert.NotContains(t, messageIDs(), message.ID.String())
+	})
+
+	t.Run("Reindex drops stale messages", func(t *testing.T) {
+		reindexed := *doc
+		reindexed.Messages = doc.Messages[1:]
+		require.NoError(t, indexer.IndexConversation(&reindexed))
+		assert.ElementsMatch(t, []string{doc.Messages[1].ID.String(), doc.Messages[2].ID.String()}, messageIDs())
+
+		// 按对话和批次删除，不在查询中列出消息 ID；单条写入的 wait_for 对应 refresh=true
+		require.NotEmpty(t, store.deletes)
+		last := store.deletes[len(store.deletes)-1]
+		assert.Equal(t, "messages", last.index)
+		assert.Equal(t, "true", last.refresh)
+		body, err := json.Marshal(last.query)
+		require.NoError(t, err)
+		assert.NotContains(t, string(body), doc.Messages[1].ID.String())
+		assert.Contains(t, string(body), "index_generation")
+	})
+
+	t.Run("Owner change", func(t *testing.T) {
+		newOwner := uuid.New()
+		updated := *doc
+		updated.UserID = newOwner
+		require.NoError(t, indexer.UpdateConversation(&updated))
+		for _, flat := range store.docs("messages") {
+			assert.Equal(t, newOwner.String(), flat["user_id"])
+		}
+	})
+
+	t.Run("Tag changes", func(t *testing.T) {
+		tag := models.TagDocument{ID: uuid.New(), Name: "golang"}
+		updated := *doc
+		updated.Tags = []models.TagDocument{tag}
+		require.NoError(t, indexer.UpdateConversation(&updated))
+		for _, flat := range store.docs("messages") {
+			assert.Equal(t, []interface{}{tag.ID.String()}, flat["tag_ids"])
+		}
+
+		require.NoError(t, indexer.RemoveTagFromConversations(tag.ID))
+		for _, flat := range store.docs("messages") {
+			assert.Empty(t, flat["tag_ids"])
+		}
+	})
+
+	t.Run("Partial update writes activity fields", func(t *testing.T) {
+		stored := store.docs("conversations")[doc.ID.String()]
+		require.NotNil(t, stored)
+		assert.Contains(t, stored, "message_count")
+
+		lastMessageAt := time.Now().UTC().Truncate(time.Second)
+		count := 1
+		updated := *doc
+		updated.Messages = nil
+		updated.LastMessageAt = &lastMessageAt
+		updated.MessageCount = &count
+		require.NoError(t, indexer.UpdateConversation(&updated))
+		assert.Equal(t, lastMessageAt.Format(time.RFC3339), stored["last_message_at"])
+		assert.EqualValues(t, 1, stored["message_count"])
+
+		// 未加载消息（如只改标题）时保留 message_count，last_message_at 为空时清除
+		updated.MessageCount = nil
+		updated.LastMessageAt = nil
+		require.NoError(t, indexer.UpdateConversation(&updated))
+		assert.EqualValues(t, 1, stored["message_count"])
+		assert.Nil(t, stored["last_message_at"])
+	})
+
+	t.Run("Delete conversation", func(t *testing.T) {
+		require.NoError(t, indexer.DeleteConversation(doc.ID))
+		assert.Empty(t, store.docs("messages"))
+	})
+
+	t.Run("Bulk writes keep the bulk refresh policy", func(t *testing.T) {
+		store, client := newIndexES(t)
+		indexer := repositories.NewElasticsearchIndexerWithOptions(client, "conversations", repositories.IndexerOptions{
+			Refresh:      repositories.RefreshPolicy{Write: repositories.RefreshWaitFor, Bulk: repositories.RefreshFalse},
+			MessageIndex: "messages",
+		})
+		first := newLongConversation(userID, "First", 2, nil)
+		second := newLongConversation(userID, "Second", 2, nil)
+		require.NoError(t, indexer.BulkIndexConversations([]*models.ConversationDocument{first, second}))
+		assert.Empty(t, store.deletes)
+
+		// 再次同步时只清理已存在的对话，不强制刷新
+		first.Messages = first.Messages[:1]
+		third := newLongConversation(userID, "Third", 1, nil)
+		require.NoError(t, indexer.BulkIndexConversations([]*models.ConversationDocument{first, third}))
+		require.Len(t, store.deletes, 1)
+		assert.Equal(t, "false", store.deletes[0].refresh)
+		body, err := json.Marshal(store.deletes[0].query)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), first.ID.String())
+		assert.NotContains(t, string(body), third.ID.String())
+		assert.Len(t, store.docs("messages"), 1+2+1)
+	})
+
+	t.Run("Delete user conversations in batches", func(t *testing.T) {
+		store, client := newIndexES(t)
+		indexer := repositories.NewElasticsearchIndexerWithOptions(client, "conversations", repositories.IndexerOptions{MessageIndex: "messages"})
+		other := uuid.New()
+
+		// 超过一个批次的对话，只保留其中一部分；其他用户的文档不受影响
+		var keep []uuid.UUID
+		for n := 0; n < 2500; n++ {
+			id := uuid.New()
+			store.docs("conversations")[id.String()] = map[string]interface{}{"id": id.String(), "user_id": userID.String()}
+			store.docs("messages")[uuid.NewString()] = map[string]interface{}{"conversation_id": id.String(), "user_id": userID.String()}
+			if n%2 == 0 {
+				keep = append(keep, id)
+			}
+		}
+		otherID := uuid.NewString()
+		store.docs("conversations")[otherID] = map[string]interface{}{"id": otherID, "user_id": other.String()}
+
+		deleted, err := indexer.DeleteUserConversationsExcept(userID, keep)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1250), deleted)
+		assert.Len(t, store.docs("conversations"), 1250+1)
+		assert.Len(t, store.docs("messages"), 1250)
+		for _, id := range keep {
+			assert.Contains(t, store.docs("conversations"), id.String())
+		}
+
+		// 每个删除请求最多列出一个批次的 ID，且不列出保留的对话
+		require.Greater(t, len(store.deletes), 2)
+		for _, req := range store.deletes {
+			body, err := json.Marshal(req.query)
+			require.NoError(t, err)
+			assert.NotContains(t, string(body), keep[0].String())
+			filter := req.query["bool"].(map[string]interface{})["filter"].([]interface{})
+			require.Len(t, filter, 2)
+			var listed []interface{}
+			if ids, ok := filter[1].(map[string]interface{})["ids"]; ok {
+				listed = ids.(map[string]interface{})["values"].([]interface{})
+			} else {
+				listed = filter[1].(map[string]interface{})["terms"].(map[string]interface{})["conversation_id"].([]interface{})
+			}
+			assert.NotEmpty(t, listed)
+			assert.LessOrEqual(t, len(listed), 1000)
+		}
+
+		// keep 为空时按 user_id 删除该用户的全部文档
+		deleted, err = indexer.DeleteUserConversationsExcept(userID, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1250), deleted)
+		assert.Len(t, store.docs("conversations"), 1)
+		assert.Empty(t, store.docs("messages"))
+	})
+
+	t.Run("Nested only", func(t *testing.T) {
+		store, client := newIndexES(t)
+		indexer := repositories.NewElasticsearchIndexer(client, "conversations")
+		require.NoError(t, indexer.IndexConversation(doc))
+		assert.Empty(t, store.docs("messages"))
+	})
+}
+
+func TestElasticsearchIndexer_RemoveMessagesFromConversation(t *testing.T) {
+	store, client := newIndexES(t)
+	doc := newLongConversation(uuid.New(), "Cleanup", 5, nil)
+	indexer := repositories.NewElasticsearchIndexerWithOptions(client, "conversations", repositories.IndexerOptions{MessageIndex: "messages"})
+	require.NoError(t, indexer.IndexConversation(doc))
+
+	storedMessageIDs := func() []string {
+		var ids []string
+		messages, _ := store.docs("conversations")[doc.ID.String()]["messages"].([]interface{})
+		for _, message := range messages {
+			ids = append(ids, message.(map[string]interface{})["id"].(string))
+		}
+		return ids
+	}
+
+	removed := []uuid.UUID{doc.Messages[0].ID, doc.Messages[2].ID, doc.Messages[3].ID}
+	require.NoError(t, indexer.RemoveMessagesFromConversation(doc.ID, removed))
+
+	// 嵌套消息和独立消息索引中都只剩未删除的消息
+	kept := []string{doc.Messages[1].ID.String(), doc.Messages[4].ID.String()}
+	assert.Equal(t, kept, storedMessageIDs())
+	var flat []string
+	for id := range store.docs("messages") {
+		flat = append(flat, id)
+	}
+	assert.ElementsMatch(t, kept, flat)
+
+	t.Run("No IDs is a no-op", func(t *testing.T) {
+		require.NoError(t, indexer.RemoveMessagesFromConversation(doc.ID, nil))
+		assert.Equal(t, kept, storedMessageIDs())
+	})
+
+	t.Run("Missing conversation", func(t *testing.T) {
+		assert.Error(t, indexer.RemoveMessagesFromConversation(uuid.New(), removed))
+	})
+}
@@ -0,0 +1,727 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/handlers"
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/repositories"
+	"chat-assistant-backend/internal/services"
+
+	es "github.com/elastic/go-elasticsearch/v8"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockMessageService is a mock implementation of services.MessageService
+type MockMessageService struct {
+	services.MessageService
+	mock.Mock
+}
+
+func (m *MockMessageService) GetMessageContext(id uuid.UUID, before, after int) (*models.MessageContext, error) {
+	args := m.Called(id, before, after)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.MessageContext), args.Error(1)
+}
+
+func (m *MockMessageService) DeleteMessage(ctx context.Context, id uuid.UUID) error {
+	return m.Called(id).Error(0)
+}
+
+func (m *MockMessageService) GetMessagesByConversationID(conversationID uuid.UUID, page, limit int, order string) ([]*models.Message, int64, error) {
+	args := m.Called(conversationID, page, limit, order)
+	return args.Get(0).([]*models.Message), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockMessageService) GetMessagesBefore(conversationID uuid.UUID, cursor repositories.MessageCursor, limit int) ([]*models.Message, int64, bool, error) {
+	args := m.Called(conversationID, cursor, limit)
+	return args.Get(0).([]*models.Message), args.Get(1).(int64), args.Bool(2), args.Error(3)
+}
+
+// MockMessageRepository is a mock implementation of repositories.MessageRepository
+type MockMessageRepository struct {
+	repositories.MessageRepository
+	mock.Mock
+}
+
+func (m *MockMessageRepository) GetByID(id uuid.UUID) (*models.Message, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Message), args.Error(1)
+}
+
+func (m *MockMessageRepository) Delete(id uuid.UUID) error {
+	return m.Called(id).Error(0)
+}
+
+func (m *MockMessageRepository) CountByConversationID(conversationID uuid.UUID) (int64, error) {
+	args := m.Called(conversationID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestMessageService_DeleteTouchesConversation(t *testing.T) {
+	lastActive := time.Now().Add(-24 * time.Hour)
+	conversation := &models.Conversation{
+		Base:  models.Base{ID: uuid.New(), UpdatedAt: lastActive},
+		Title: "Active",
+		Tags:  []models.Tag{{Base: models.Base{ID: uuid.New()}, Name: "work"}},
+	}
+	message := &models.Message{Base: models.Base{ID: uuid.New()}, ConversationID: conversation.ID, Role: "user"}
+
+	messageRepo := new(MockMessageRepository)
+	messageRepo.On("GetByID", message.ID).Return(message, nil)
+	messageRepo.On("Delete", message.ID).Return(nil)
+	messageRepo.On("CountByConversationID", conversation.ID).Return(int64(4), nil)
+
+	var touchedAt time.Time
+	previousMessageAt := lastActive.Add(-time.Hour)
+	convRepo := new(MockConversationRepository)
+	convRepo.On("GetByID", conversation.ID).Return(conversation, nil)
+	convRepo.On("RefreshLastMessageAt", conversation.ID).Return(&previousMessageAt, nil)
+	convRepo.On("Touch", conversation.ID, mock.AnythingOfType("time.Time")).Run(func(args mock.Arguments) {
+		touchedAt = args.Get(1).(time.Time)
+	}).Return(nil)
+
+	indexer := new(MockIndexer)
+	indexer.On("RemoveMessageFromConversation", conversation.ID, message.ID).Return(nil)
+	indexer.On("UpdateConversation", mock.MatchedBy(func(doc *models.ConversationDocument) bool {
+		// 索引中的 updated_at、last_message_at、message_count 与数据库一致，且保留标签
+		return doc.ID == conversation.ID && doc.UpdatedAt.Equal(touchedAt) && len(doc.Tags) == 1 &&
+			doc.LastMessageAt != nil && doc.LastMessageAt.Equal(previousMessageAt) &&
+			doc.MessageCount != nil && *doc.MessageCount == 4
+	})).Return(nil)
+
+	service := services.NewMessageService(messageRepo, convRepo, indexer, nil)
+	require.NoError(t, service.DeleteMessage(context.Background(), message.ID))
+
+	assert.True(t, touchedAt.After(lastActive))
+	messageRepo.AssertExpectations(t)
+	convRepo.AssertExpectations(t)
+	indexer.AssertExpectations(t)
+
+	t.Run("Index failure does not fail the delete", func(t *testing.T) {
+		failingIndexer := new(MockIndexer)
+		failingIndexer.On("RemoveMessageFromConversation", conversation.ID, message.ID).Return(assert.AnError)
+		failingIndexer.On("UpdateConversation", mock.Anything).Return(assert.AnError)
+
+		service := services.NewMessageService(messageRepo, convRepo, failingIndexer, nil)
+		assert.NoError(t, service.DeleteMessage(context.Background(), message.ID))
+	})
+
+	t.Run("Touch failure does not fail the committed delete", func(t *testing.T) {
+		failingConvRepo := new(MockConversationRepository)
+		failingConvRepo.On("GetByID", conversation.ID).Return(nil, assert.AnError)
+
+		service := services.NewMessageService(messageRepo, failingConvRepo, indexer, nil)
+		assert.NoError(t, service.DeleteMessage(context.Background(), message.ID))
+		failingConvRepo.AssertExpectations(t)
+	})
+
+	t.Run("Database updated_at advances", func(t *testing.T) {
+		db := openTestDB(t)
+		_, stored := createTestConversation(t, db)
+		require.NoError(t, db.Model(stored).UpdateColumn("updated_at", lastActive).Error)
+
+		stale := &models.Message{ConversationID: stored.ID, Role: "user", Content: "bye", SourceID: uuid.NewString()}
+		require.NoError(t, db.Create(stale).Error)
+
+		indexer := new(MockIndexer)
+		indexer.On("RemoveMessageFromConversation", stored.ID, stale.ID).Return(nil)
+		indexer.On("UpdateConversation", mock.Anything).Return(nil)
+
+		service := services.NewMessageService(repositories.NewMessageRepository(db), repositories.NewConversationRepository(db), indexer, nil)
+		require.NoError(t, service.DeleteMessage(context.Background(), stale.ID))
+
+		var reloaded models.Conversation
+		require.NoError(t, db.First(&reloaded, "id = ?", stored.ID).Error)
+		assert.True(t, reloaded.UpdatedAt.After(lastActive))
+	})
+
+	t.Run("Database last_message_at follows remaining messages", func(t *testing.T) {
+		db := openTestDB(t)
+		_, stored := createTestConversation(t, db)
+
+		base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+		first := &models.Message{Base: models.Base{CreatedAt: base}, ConversationID: stored.ID, Role: "user", Content: "hi", SourceID: uuid.NewString()}
+		last := &models.Message{Base: models.Base{CreatedAt: base.Add(time.Minute)}, ConversationID: stored.ID, Role: "assistant", Content: "hello", SourceID: uuid.NewString()}
+		require.NoError(t, db.Create(first).Error)
+		require.NoError(t, db.Create(last).Error)
+
+		indexer := new(MockIndexer)
+		indexer.On("RemoveMessageFromConversation", stored.ID, mock.Anything).Return(nil)
+		indexer.On("UpdateConversation", mock.Anything).Return(nil)
+		service := services.NewMessageService(repositories.NewMessageRepository(db), repositories.NewConversationRepository(db), indexer, nil)
+
+		reload := func() *time.Time {
+			var reloaded models.Conversation
+			require.NoError(t, db.First(&reloaded, "id = ?", stored.ID).Error)
+			return reloaded.LastMessageAt
+		}
+
+		// 删除最后一条消息后回退到上一条
+		require.NoError(t, service.DeleteMessage(context.Background(), last.ID))
+		require.NotNil(t, reload())
+		assert.True(t, reload().Equal(base))
+
+		// 没有消息时为空
+		require.NoError(t, service.DeleteMessage(context.Background(), first.ID))
+		assert.Nil(t, reload())
+	})
+}
+
+// documentES is an Elasticsearch stand-in that stores conversation documents and applies
+// the indexer's updates: partial doc updates and the message removal script
+type documentES struct {
+	mu   sync.Mutex
+	docs map[string]map[string]interface{}
+}
+
+func newDocumentES(t *testing.T) (*documentES, *es.Client) {
+	store := &documentES{docs: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		store.mu.Lock()
+		defer store.mu.Unlock()
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		require.Len(t, parts, 3, r.URL.Path)
+		id := parts[2]
+		doc, exists := store.docs[id]
+
+		switch {
+		case parts[1] == "_doc" && r.Method == http.MethodHead:
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case parts[1] == "_doc":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			store.docs[id] = body
+			w.Write([]byte(`{"result":"created"}`))
+		case parts[1] == "_update":
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			var body struct {
+				Doc    map[string]interface{} `json:"doc"`
+				Script struct {
+					Params map[string]interface{} `json:"params"`
+				} `json:"script"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			for key, value := range body.Doc {
+				doc[key] = value
+			}
+			if messageID, ok := body.Script.Params["messageId"]; ok {
+				var kept []interface{}
+				for _, message := range doc["messages"].([]interface{}) {
+					if message.(map[string]interface{})["id"] != messageID {
+						kept = append(kept, message)
+					}
+				}
+				doc["messages"] = kept
+			}
+			w.Write([]byte(`{"result":"updated"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := es.NewClient(es.Config{Addresses: []string{server.URL}})
+	require.NoError(t, err)
+	return store, client
+}
+
+// messageIDs returns the IDs of the messages in a stored document
+func (s *documentES) messageIDs(conversationID uuid.UUID) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	messages, _ := s.docs[conversationID.String()]["messages"].([]interface{})
+	for _, message := range messages {
+		ids = append(ids, message.(map[string]interface{})["id"].(string))
+	}
+	return ids
+}
+
+func TestMessageService_DeleteRemovesMessageFromIndex(t *testing.T) {
+	conversation := &models.Conversation{Base: models.Base{ID: uuid.New()}, Title: "Cleanup"}
+	kept := models.Message{Base: models.Base{ID: uuid.New(), CreatedAt: time.Now().Add(-time.Minute)}, ConversationID: conversation.ID, Role: "user", Content: "keep me"}
+	deleted := models.Message{Base: models.Base{ID: uuid.New(), CreatedAt: time.Now()}, ConversationID: conversation.ID, Role: "assistant", Content: "secret"}
+	conversation.Messages = []models.Message{kept, deleted}
+
+	store, client := newDocumentES(t)
+	indexer := repositories.NewElasticsearchIndexer(client, "conversations")
+	require.NoError(t, indexer.IndexConversation(conversation.ToESDocument()))
+	require.Equal(t, []string{kept.ID.String(), deleted.ID.String()}, store.messageIDs(conversation.ID))
+
+	messageRepo := new(MockMessageRepository)
+	messageRepo.On("GetByID", deleted.ID).Return(&deleted, nil)
+	messageRepo.On("Delete", deleted.ID).Return(nil)
+	messageRepo.On("CountByConversationID", conversation.ID).Return(int64(1), nil)
+	convRepo := new(MockConversationRepository)
+	convRepo.On("GetByID", conversation.ID).Return(&models.Conversation{Base: conversation.Base, Title: conversation.Title}, nil)
+	convRepo.On("RefreshLastMessageAt", conversation.ID).Return(&kept.CreatedAt, nil)
+	convRepo.On("Touch", conversation.ID, mock.AnythingOfType("time.Time")).Return(nil)
+
+	service := services.NewMessageService(messageRepo, convRepo, indexer, nil)
+	require.NoError(t, service.DeleteMessage(context.Background(), deleted.ID))
+
+	// 删除的消息不再出现在索引中，其余消息保留
+	assert.Equal(t, []string{kept.ID.String()}, store.messageIDs(conversation.ID))
+	messageRepo.AssertExpectations(t)
+	convRepo.AssertExpectations(t)
+}
+
+func TestMessageRepository_GetContext(t *testing.T) {
+	db := openTestDB(t)
+	_, conversation := createTestConversation(t, db)
+
+	// 按时间顺序创建 6 条消息，插入顺序打乱以验证按 created_at 排序
+	base := time.Now().UTC().Add(-time.Hour)
+	messages := make([]*models.Message, 6)
+	for _, i := range []int{3, 0, 5, 1, 4, 2} {
+		messages[i] = &models.Message{
+			Base:           models.Base{CreatedAt: base.Add(time.Duration(i) * time.Minute)},
+			ConversationID: conversation.ID,
+			Role:           "user",
+			Content:        string(rune('a' + i)),
+			SourceID:       uuid.NewString(),
+		}
+		require.NoError(t, db.Create(messages[i]).Error)
+	}
+
+	// 其他对话的消息不应出现在上下文中
+	_, other := createTestConversation(t, db)
+	require.NoError(t, db.Create(&models.Message{
+		Base:           models.Base{CreatedAt: base.Add(150 * time.Second)},
+		ConversationID: other.ID, Role: "user", Content: "other", SourceID: uuid.NewString(),
+	}).Error)
+
+	repo := repositories.NewMessageRepository(db)
+	contents := func(messages []*models.Message) []string {
+		result := make([]string, len(messages))
+		for i, m := range messages {
+			result[i] = m.Content
+		}
+		return result
+	}
+
+	t.Run("Neighbors in order", func(t *testing.T) {
+		ctx, err := repo.GetContext(messages[2].ID, 2, 2)
+
+		require.NoError(t, err)
+		assert.Equal(t, "c", ctx.Message.Content)
+		assert.Equal(t, []string{"a", "b"}, contents(ctx.Before))
+		assert.Equal(t, []string{"d", "e"}, contents(ctx.After))
+	})
+
+	t.Run("Truncated at conversation boundaries", func(t *testing.T) {
+		ctx, err := repo.GetContext(messages[1].ID, 3, 10)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, contents(ctx.Before))
+		assert.Equal(t, []string{"c", "d", "e", "f"}, contents(ctx.After))
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		ctx, err := repo.GetContext(uuid.New(), 2, 2)
+
+		assert.NoError(t, err)
+		assert.Nil(t, ctx)
+	})
+}
+
+func TestGetMessageContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	conversationID := uuid.New()
+	message := func(content string) *models.Message {
+		return &models.Message{Base: models.Base{ID: uuid.New()}, ConversationID: conversationID, Role: "user", Content: content}
+	}
+	target := message("target")
+
+	get := func(service *MockMessageService, id uuid.UUID, query string) (int, map[string]interface{}) {
+		router := gin.New()
+		router.GET("/messages/:id/context", handlers.NewMessageHandler(service).GetMessageContext)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/messages/"+id.String()+"/context"+query, nil))
+
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body.Data
+	}
+
+	t.Run("Returns message with neighbors", func(t *testing.T) {
+		service := new(MockMessageService)
+		service.On("GetMessageContext", target.ID, 2, 2).Return(&models.MessageContext{
+			Message: target,
+			Before:  []*models.Message{message("one"), message("two")},
+			After:   []*models.Message{message("three")},
+		}, nil)
+
+		code, data := get(service, target.ID, "")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "target", data["message"].(map[string]interface{})["content"])
+		require.Len(t, data["before"], 2)
+		assert.Equal(t, "two", data["before"].([]interface{})[1].(map[string]interface{})["content"])
+		assert.Len(t, data["after"], 1)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Clamps before and after", func(t *testing.T) {
+		service := new(MockMessageService)
+		service.On("GetMessageContext", target.ID, 10, 0).Return(&models.MessageContext{Message: target}, nil)
+
+		code, data := get(service, target.ID, "?before=500&after=0")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Empty(t, data["before"])
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Message not found", func(t *testing.T) {
+		service := new(MockMessageService)
+		service.On("GetMessageContext", mock.Anything, 2, 2).Return(nil, errors.ErrMessageNotFound)
+
+		code, _ := get(service, uuid.New(), "")
+
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+}
+
+func TestMessageCursor_RoundTrip(t *testing.T) {
+	cursor := repositories.MessageCursor{
+		Sequence:  42,
+		CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC),
+		ID:        uuid.New(),
+	}
+
+	parsed, err := repositories.ParseMessageCursor(cursor.Encode())
+
+	require.NoError(t, err)
+	assert.Equal(t, 42, parsed.Sequence)
+	assert.True(t, parsed.CreatedAt.Equal(cursor.CreatedAt))
+	assert.Equal(t, cursor.ID, parsed.ID)
+
+	// 不含 sequence 的旧游标
+	legacy := base64.RawURLEncoding.EncodeToString([]byte(cursor.CreatedAt.Format(time.RFC3339Nano) + "|" + cursor.ID.String()))
+	for _, invalid := range []string{"not base64!", "bm8tc2VwYXJhdG9y", cursor.Encode() + "x", legacy} {
+		_, err := repositories.ParseMessageCursor(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestMessageRepository_GetByConversationIDOrder(t *testing.T) {
+	db := openTestDB(t)
+	_, conversation := createTestConversation(t, db)
+
+	// 5 条消息 a..e，其中 c、d 的 created_at 相同，用于验证按 id 打破并列
+	base := time.Now().UTC().Truncate(time.Microsecond).Add(-time.Hour)
+	offsets := []int{0, 1, 2, 2, 3}
+	for i, offset := range offsets {
+		require.NoError(t, db.Create(&models.Message{
+			Base:           models.Base{CreatedAt: base.Add(time.Duration(offset) * time.Minute)},
+			ConversationID: conversation.ID,
+			Role:           "user",
+			Content:        string(rune('a' + i)),
+			SourceID:       uuid.NewString(),
+		}).Error)
+	}
+
+	repo := repositories.NewMessageRepository(db)
+	contents := func(messages []*models.Message) []string {
+		result := make([]string, len(messages))
+		for i, m := range messages {
+			result[i] = m.Content
+		}
+		return result
+	}
+
+	asc, total, err := repo.GetByConversationID(conversation.ID, 1, 10, repositories.MessageOrderAsc)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+
+	t.Run("Desc is the reverse of asc", func(t *testing.T) {
+		desc, _, err := repo.GetByConversationID(conversation.ID, 1, 10, repositories.MessageOrderDesc)
+		require.NoError(t, err)
+
+		reversed := contents(asc)
+		for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+			reversed[i], reversed[j] = reversed[j], reversed[i]
+		}
+		assert.Equal(t, reversed, contents(desc))
+	})
+
+	t.Run("Cursor pages walk back without gaps", func(t *testing.T) {
+		first, _, err := repo.GetByConversationID(conversation.ID, 1, 2, repositories.MessageOrderDesc)
+		require.NoError(t, err)
+		seen := contents(first)
+
+		cursor := repositories.NewMessageCursor(first[len(first)-1])
+		for {
+			page, total, err := repo.GetByConversationIDBefore(conversation.ID, cursor, 2)
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), total)
+			if len(page) == 0 {
+				break
+			}
+			seen = append(seen, contents(page)...)
+			cursor = repositories.NewMessageCursor(page[len(page)-1])
+		}
+
+		desc, _, err := repo.GetByConversationID(conversation.ID, 1, 10, repositories.MessageOrderDesc)
+		require.NoError(t, err)
+		assert.Equal(t, contents(desc), seen)
+	})
+
+	t.Run("Cursor at oldest message returns empty page", func(t *testing.T) {
+		page, _, err := repo.GetByConversationIDBefore(conversation.ID, repositories.NewMessageCursor(asc[0]), 2)
+
+		require.NoError(t, err)
+		assert.Empty(t, page)
+	})
+
+	t.Run("Offset past the end returns empty page", func(t *testing.T) {
+		page, _, err := repo.GetByConversationID(conversation.ID, 3, 5, repositories.MessageOrderDesc)
+
+		require.NoError(t, err)
+		assert.Empty(t, page)
+	})
+}
+
+func TestMessageRepository_SequenceOrder(t *testing.T) {
+	db := openTestDB(t)
+	_, conversation := createTestConversation(t, db)
+
+	// 4 条消息时间完全相同，按打乱的顺序插入，顺序只能由 sequence 决定
+	at := time.Now().UTC().Truncate(time.Microsecond).Add(-time.Hour)
+	for _, i := range []int{2, 0, 3, 1} {
+		require.NoError(t, db.Create(&models.Message{
+			Base:           models.Base{CreatedAt: at},
+			ConversationID: conversation.ID,
+			Role:           "user",
+			Content:        string(rune('a' + i)),
+			SourceID:       uuid.NewString(),
+			Sequence:       i + 1,
+		}).Error)
+	}
+
+	repo := repositories.NewMessageRepository(db)
+	contents := func(messages []*models.Message) []string {
+		result := make([]string, len(messages))
+		for i, m := range messages {
+			result[i] = m.Content
+		}
+		return result
+	}
+
+	t.Run("Asc and desc follow sequence", func(t *testing.T) {
+		asc, _, err := repo.GetByConversationID(conversation.ID, 1, 10, repositories.MessageOrderAsc)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c", "d"}, contents(asc))
+
+		desc, _, err := repo.GetByConversationID(conversation.ID, 1, 10, repositories.MessageOrderDesc)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"d", "c", "b", "a"}, contents(desc))
+	})
+
+	t.Run("Cursor pages follow sequence", func(t *testing.T) {
+		first, _, err := repo.GetByConversationID(conversation.ID, 1, 1, repositories.MessageOrderDesc)
+		require.NoError(t, err)
+
+		page, _, err := repo.GetByConversationIDBefore(conversation.ID, repositories.NewMessageCursor(first[0]), 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c", "b"}, contents(page))
+	})
+
+	t.Run("Context follows sequence", func(t *testing.T) {
+		asc, _, err := repo.GetByConversationID(conversation.ID, 1, 10, repositories.MessageOrderAsc)
+		require.NoError(t, err)
+
+		ctx, err := repo.GetContext(asc[1].ID, 1, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, contents(ctx.Before))
+		assert.Equal(t, []string{"c"}, contents(ctx.After))
+	})
+
+	t.Run("Preloaded messages follow sequence", func(t *testing.T) {
+		conversations, err := repositories.NewConversationRepository(db).FindByIDs([]uuid.UUID{conversation.ID})
+		require.NoError(t, err)
+		require.Len(t, conversations, 1)
+
+		var loaded []string
+		for _, m := range conversations[0].Messages {
+			loaded = append(loaded, m.Content)
+		}
+		assert.Equal(t, []string{"a", "b", "c", "d"}, loaded)
+	})
+}
+
+func TestConversation_ToESDocumentMessageOrder(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	conversation := &models.Conversation{Base: models.Base{ID: uuid.New()}}
+	// 时间相同时按 sequence 排序；sequence 相同（都为 0）时按时间
+	for _, m := range []struct {
+		content  string
+		sequence int
+		at       time.Time
+	}{
+		{"third", 3, at},
+		{"first", 1, at},
+		{"second", 2, at},
+		{"legacy-late", 0, at.Add(time.Minute)},
+		{"legacy-early", 0, at.Add(-time.Minute)},
+	} {
+		conversation.Messages = append(conversation.Messages, models.Message{
+			Base:     models.Base{ID: uuid.New(), CreatedAt: m.at},
+			Content:  m.content,
+			Sequence: m.sequence,
+		})
+	}
+
+	var indexed []string
+	for _, m := range conversation.ToESDocument().Messages {
+		indexed = append(indexed, m.Content)
+	}
+	assert.Equal(t, []string{"legacy-early", "legacy-late", "first", "second", "third"}, indexed)
+
+	var exported []string
+	for _, m := range services.NewConversationExport(conversation).Messages {
+		exported = append(exported, m.Content)
+	}
+	assert.Equal(t, indexed, exported)
+}
+
+func TestGetConversationMessages_Order(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	conversationID := uuid.New()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	message := func(minute int) *models.Message {
+		return &models.Message{
+			Base:           models.Base{ID: uuid.New(), CreatedAt: base.Add(time.Duration(minute) * time.Minute)},
+			ConversationID: conversationID,
+			Role:           "user",
+			Content:        strconv.Itoa(minute),
+		}
+	}
+
+	type pagination struct {
+		Page       int    `json:"page"`
+		Total      int64  `json:"total"`
+		TotalPages int    `json:"total_pages"`
+		HasNext    bool   `json:"has_next"`
+		NextCursor string `json:"next_cursor"`
+	}
+	get := func(service *MockMessageService, query string) (int, pagination) {
+		router := gin.New()
+		router.GET("/conversations/:id/messages", handlers.NewMessageHandler(service).GetConversationMessages)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/conversations/"+conversationID.String()+"/messages"+query, nil))
+
+		var body struct {
+			Pagination pagination `json:"pagination"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body.Pagination
+	}
+
+	t.Run("Defaults to asc without cursor", func(t *testing.T) {
+		service := new(MockMessageService)
+		service.On("GetMessagesByConversationID", conversationID, 1, 2, repositories.MessageOrderAsc).
+			Return([]*models.Message{message(0), message(1)}, int64(5), nil)
+
+		code, p := get(service, "?limit=2")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, p.HasNext)
+		assert.Empty(t, p.NextCursor)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Desc first page returns cursor of oldest message", func(t *testing.T) {
+		oldest := message(3)
+		service := new(MockMessageService)
+		service.On("GetMessagesByConversationID", conversationID, 1, 2, repositories.MessageOrderDesc).
+			Return([]*models.Message{message(4), oldest}, int64(5), nil)
+
+		code, p := get(service, "?limit=2&order=desc")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, repositories.NewMessageCursor(oldest).Encode(), p.NextCursor)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Desc last offset page has no cursor", func(t *testing.T) {
+		service := new(MockMessageService)
+		service.On("GetMessagesByConversationID", conversationID, 3, 2, repositories.MessageOrderDesc).
+			Return([]*models.Message{message(0)}, int64(5), nil)
+
+		_, p := get(service, "?limit=2&order=desc&page=3")
+
+		assert.Empty(t, p.NextCursor)
+		assert.False(t, p.HasNext)
+	})
+
+	t.Run("Cursor page continues until no older messages", func(t *testing.T) {
+		cursor := repositories.NewMessageCursor(message(3))
+		service := new(MockMessageService)
+		service.On("GetMessagesBefore", conversationID, mock.MatchedBy(func(c repositories.MessageCursor) bool {
+			return c.ID == cursor.ID && c.CreatedAt.Equal(cursor.CreatedAt)
+		}), 2).Return([]*models.Message{message(2), message(1)}, int64(5), true, nil).Once()
+
+		code, p := get(service, "?limit=2&order=desc&cursor="+cursor.Encode())
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 0, p.Page)
+		assert.Equal(t, 0, p.TotalPages)
+		assert.True(t, p.HasNext)
+		assert.NotEmpty(t, p.NextCursor)
+
+		service.On("GetMessagesBefore", conversationID, mock.Anything, 2).
+			Return([]*models.Message{message(0)}, int64(5), false, nil).Once()
+
+		_, p = get(service, "?limit=2&order=desc&cursor="+p.NextCursor)
+
+		assert.False(t, p.HasNext)
+		assert.Empty(t, p.NextCursor)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Invalid order and cursor", func(t *testing.T) {
+		service := new(MockMessageService)
+		cursor := repositories.NewMessageCursor(message(0)).Encode()
+
+		for _, query := range []string{"?order=newest", "?order=desc&cursor=garbage", "?cursor=" + cursor} {
+			code, _ := get(service, query)
+			assert.Equal(t, http.StatusBadRequest, code, query)
+		}
+		service.AssertNotCalled(t, "GetMessagesByConversationID", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,202 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/handlers"
+	"chat-assistant-backend/internal/logger"
+	"chat-assistant-backend/internal/middleware"
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newBodyLimitRouter(maxBytes int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/echo", middleware.BodyLimitMiddleware(maxBytes), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	return router
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	router := newBodyLimitRouter(16)
+
+	t.Run("Body within limit passes through", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"name":"go"}`))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"name":"go"}`, w.Body.String())
+	})
+
+	t.Run("Oversized body returns 413", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(strings.Repeat("x", 17)))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), "REQUEST_BODY_TOO_LARGE")
+	})
+
+	t.Run("Oversized body without content length returns 413", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/echo", io.NopCloser(strings.NewReader(strings.Repeat("x", 32))))
+		req.ContentLength = -1
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+}
+
+func TestBodyLimitMiddleware_RouteOverride(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	echo := func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	}
+	// 与 server.go 相同：分组使用默认上限，创建接口叠加更小的上限
+	api := router.Group("/api", middleware.BodyLimitMiddleware(64))
+	api.POST("/tags", middleware.BodyLimitMiddleware(16), echo)
+	api.POST("/search", echo)
+
+	post := func(path string, size int) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(strings.Repeat("x", size))))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, post("/api/tags", 16))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post("/api/tags", 32))
+	assert.Equal(t, http.StatusOK, post("/api/search", 32))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post("/api/search", 65))
+}
+
+func newRequireJSONRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequireJSON())
+	handler := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router.GET("/items", handler)
+	router.POST("/items", handler)
+	router.PUT("/items/1", handler)
+	router.PATCH("/items/1", handler)
+	router.DELETE("/items/1", handler)
+	return router
+}
+
+func TestRequireJSON(t *testing.T) {
+	router := newRequireJSONRouter()
+
+	serve := func(method, path, contentType, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("text/plain POST returns 415", func(t *testing.T) {
+		w := serve(http.MethodPost, "/items", "text/plain", `{"name":"go"}`)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.Contains(t, w.Body.String(), "UNSUPPORTED_MEDIA_TYPE")
+	})
+
+	t.Run("Missing content type on PUT and PATCH returns 415", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnsupportedMediaType, serve(http.MethodPut, "/items/1", "", `{}`).Code)
+		assert.Equal(t, http.StatusUnsupportedMediaType, serve(http.MethodPatch, "/items/1", "", `{}`).Code)
+	})
+
+	t.Run("application/json with charset passes through", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodPost, "/items", "application/json", `{}`).Code)
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodPatch, "/items/1", "application/json; charset=utf-8", `{}`).Code)
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodPut, "/items/1", "Application/JSON;charset=UTF-8", `{}`).Code)
+	})
+
+	t.Run("GET and DELETE are not checked", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, "/items", "text/plain", "").Code)
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/items/1", "text/plain", "x").Code)
+	})
+
+	t.Run("POST without body passes through", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodPost, "/items", "", "").Code)
+	})
+}
+
+func TestRequestIDCorrelation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zapcore.ErrorLevel)
+	previous := logger.Logger
+	logger.Logger = zap.New(core)
+	t.Cleanup(func() { logger.Logger = previous })
+
+	conversation := &models.Conversation{Base: models.Base{ID: uuid.New()}, UserID: uuid.New()}
+	missing := uuid.New()
+	convRepo := new(MockConversationRepository)
+	convRepo.On("GetByID", conversation.ID).Return(conversation, nil)
+	convRepo.On("GetByID", missing).Return(nil, nil)
+	convRepo.On("Delete", conversation.ID).Return(nil)
+	indexer := new(MockIndexer)
+	indexer.On("DeleteConversation", conversation.ID).Return(assert.AnError)
+
+	service := services.NewConversationService(convRepo, new(MockTagRepository), new(MockUserRepository), indexer, nil, &config.Config{})
+	router := gin.New()
+	router.Use(middleware.RequestIDMiddleware())
+	router.DELETE("/conversations/:id", handlers.NewConversationHandler(service).DeleteConversation)
+
+	remove := func(id uuid.UUID) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodDelete, "/conversations/"+id.String(), nil)
+		req.Header.Set("X-Request-ID", "req-"+id.String())
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Service logs carry the request ID", func(t *testing.T) {
+		remove(conversation.ID)
+
+		entries := logs.FilterMessage("Failed to delete conversation from Elasticsearch").All()
+		require.Len(t, entries, 1)
+		assert.Equal(t, "req-"+conversation.ID.String(), entries[0].ContextMap()["request_id"])
+	})
+
+	t.Run("Error bodies echo the request ID", func(t *testing.T) {
+		w := remove(missing)
+
+		var body struct {
+			Error struct {
+				Code      string `json:"code"`
+				RequestID string `json:"request_id"`
+			} `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "req-"+missing.String(), body.Error.RequestID)
+		assert.Equal(t, w.Header().Get("X-Request-ID"), body.Error.RequestID)
+	})
+
+	t.Run("Background work logs without a request ID", func(t *testing.T) {
+		assert.Same(t, logger.GetLogger(), logger.FromContext(context.Background()))
+	})
+}
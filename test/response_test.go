@@ -0,0 +1,159 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"chat-assistant-backend/internal/handlers"
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/response"
+	"chat-assistant-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaginationInfo_SetNavigation(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+
+	cases := []struct {
+		name     string
+		page     int
+		total    int
+		hasNext  bool
+		hasPrev  bool
+		nextPage *int
+		prevPage *int
+	}{
+		{"first page", 1, 3, true, false, intPtr(2), nil},
+		{"middle page", 2, 3, true, true, intPtr(3), intPtr(1)},
+		{"last page", 3, 3, false, true, nil, intPtr(2)},
+		{"single page", 1, 1, false, false, nil, nil},
+		{"empty result", 1, 0, false, false, nil, nil},
+		{"beyond last page", 5, 3, false, true, nil, intPtr(3)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &response.PaginationInfo{Page: tc.page, Limit: 10, TotalPages: tc.total}
+			p.SetNavigation()
+
+			assert.Equal(t, tc.hasNext, p.HasNext)
+			assert.Equal(t, tc.hasPrev, p.HasPrev)
+			assert.Equal(t, tc.nextPage, p.NextPage)
+			assert.Equal(t, tc.prevPage, p.PrevPage)
+		})
+	}
+}
+
+func TestSuccessPaginated_IncludesNavigation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	response.SuccessPaginated(c, []string{}, &response.PaginationInfo{Page: 2, Limit: 10, Total: 25, TotalPages: 3})
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	pagination := body["pagination"].(map[string]interface{})
+	assert.Equal(t, true, pagination["has_next"])
+	assert.Equal(t, true, pagination["has_prev"])
+	assert.Equal(t, float64(3), pagination["next_page"])
+	assert.Equal(t, float64(1), pagination["prev_page"])
+	assert.NotContains(t, pagination, "next_cursor")
+}
+
+func TestNewConversationResponse_SourceFields(t *testing.T) {
+	t.Run("Round-trips source fields", func(t *testing.T) {
+		conversation := &models.Conversation{
+			Provider:    "openai",
+			Model:       "gpt-4",
+			SourceID:    "chatgpt-123",
+			SourceTitle: "Original title",
+		}
+
+		data, err := json.Marshal(response.NewConversationResponse(conversation))
+		require.NoError(t, err)
+
+		var decoded response.ConversationResponse
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, "chatgpt-123", decoded.SourceID)
+		assert.Equal(t, "Original title", decoded.SourceTitle)
+		assert.Equal(t, "openai/gpt-4", decoded.ProviderModel)
+		// 没有 title 时回退为 source_title
+		assert.Equal(t, "Original title", decoded.Title)
+	})
+
+	t.Run("Omits empty source fields", func(t *testing.T) {
+		data, err := json.Marshal(response.NewConversationResponse(&models.Conversation{Provider: "claude"}))
+		require.NoError(t, err)
+
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &body))
+		assert.NotContains(t, body, "source_id")
+		assert.NotContains(t, body, "source_title")
+		assert.Equal(t, "claude", body["provider_model"])
+	})
+}
+
+func TestMutationResponses_ConsistentShape(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	id := uuid.New()
+	conversationService := new(MockConversationService)
+	conversationService.On("DeleteConversation", id).Return(nil)
+	conversationService.On("UpdateConversationTags", id, []string{"go"}).Return(nil)
+	messageService := new(MockMessageService)
+	messageService.On("DeleteMessage", id).Return(nil)
+	tagRepo := new(MockTagRepository)
+	tagRepo.On("GetByID", id).Return(&models.Tag{Base: models.Base{ID: id}, Name: "go"}, nil)
+	tagRepo.On("Delete", id).Return(nil)
+
+	router := gin.New()
+	router.DELETE("/conversations/:id", handlers.NewConversationHandler(conversationService).DeleteConversation)
+	router.PUT("/conversations/:id/tags", handlers.NewConversationHandler(conversationService).UpdateConversationTags)
+	router.DELETE("/messages/:id", handlers.NewMessageHandler(messageService).DeleteMessage)
+	router.DELETE("/tags/:id", handlers.NewTagHandler(services.NewTagService(tagRepo, nil, nil, newTagConfig(true))).DeleteTag)
+
+	send := func(method, path, body string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Success bool                   `json:"success"`
+			Data    map[string]interface{} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, resp.Success)
+		return resp.Data
+	}
+
+	for _, path := range []string{"/conversations/", "/messages/", "/tags/"} {
+		t.Run("DELETE "+path, func(t *testing.T) {
+			data := send(http.MethodDelete, path+id.String(), "")
+			assert.Equal(t, id.String(), data["id"])
+			assert.Equal(t, true, data["deleted"])
+			assert.NotEmpty(t, data["message"])
+			assert.Len(t, data, 3)
+		})
+	}
+
+	t.Run("PUT conversation tags", func(t *testing.T) {
+		data := send(http.MethodPut, "/conversations/"+id.String()+"/tags", `{"tags":[{"name":"go"}]}`)
+		assert.Equal(t, id.String(), data["id"])
+		assert.Equal(t, true, data["updated"])
+		assert.Equal(t, "Conversation tags updated successfully", data["message"])
+	})
+
+	conversationService.AssertExpectations(t)
+	messageService.AssertExpectations(t)
+	tagRepo.AssertExpectations(t)
+}
@@ -0,0 +1,1946 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/handlers"
+	"chat-assistant-backend/internal/infra/elasticsearch"
+	"chat-assistant-backend/internal/logger"
+	"chat-assistant-backend/internal/middleware"
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/repositories"
+	"chat-assistant-backend/internal/response"
+	"chat-assistant-backend/internal/services"
+
+	es "github.com/elastic/go-elasticsearch/v8"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// esStub is a minimal Elasticsearch stand-in that records search requests
+// and replies with canned hits, dropping hits below the request's min_score like ES does
+// and leaving out messages when the request excludes them from _source.
+// Document GETs and mgets are answered from the same hits
+type esStub struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	requests []map[string]interface{}
+	queries  []url.Values
+	hits     []map[string]interface{}
+	shards   map[string]interface{}
+	delay    time.Duration
+	// mgets 记录每次 mget 请求的文档 ID
+	mgets [][]string
+}
+
+func newESStub(t testing.TB, hits ...map[string]interface{}) (*esStub, *es.Client) {
+	stub := &esStub{
+		hits:   hits,
+		shards: map[string]interface{}{"total": 1, "successful": 1, "skipped": 0, "failed": 0},
+	}
+	stub.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(stub.delay)
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		if _, id, ok := strings.Cut(r.URL.Path, "/_doc/"); ok && r.Method == http.MethodGet {
+			for _, hit := range stub.hits {
+				if hit["_id"] == id {
+					json.NewEncoder(w).Encode(map[string]interface{}{"_id": id, "found": true, "_source": hit["_source"]})
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"_id": id, "found": false})
+			return
+		}
+
+		if strings.HasSuffix(r.URL.Path, "/_mget") {
+			var req struct {
+				IDs []string `json:"ids"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			stub.mu.Lock()
+			stub.mgets = append(stub.mgets, req.IDs)
+			stub.mu.Unlock()
+
+			docs := make([]map[string]interface{}, 0, len(req.IDs))
+			for _, id := range req.IDs {
+				doc := map[string]interface{}{"_id": id, "found": false}
+				for _, hit := range stub.hits {
+					if hit["_id"] == id {
+						doc = map[string]interface{}{"_id": id, "found": true, "_source": hit["_source"]}
+					}
+				}
+				docs = append(docs, doc)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"docs": docs})
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var req map[string]interface{}
+		stub.mu.Lock()
+		stub.queries = append(stub.queries, r.URL.Query())
+		if len(body) > 0 && json.Unmarshal(body, &req) == nil {
+			stub.requests = append(stub.requests, req)
+		}
+		stub.mu.Unlock()
+
+		hits := stub.hits
+		if minScore, ok := req["min_score"].(float64); ok {
+			hits = make([]map[string]interface{}, 0, len(stub.hits))
+			for _, hit := range stub.hits {
+				if score, _ := hit["_score"].(float64); score >= minScore {
+					hits = append(hits, hit)
+				}
+			}
+		}
+
+		if source, ok := req["_source"].(map[string]interface{}); ok && fmt.Sprint(source["excludes"]) == "[messages]" {
+			projected := make([]map[string]interface{}, len(hits))
+			for i, hit := range hits {
+				doc := make(map[string]interface{})
+				for key, value := range hit["_source"].(map[string]interface{}) {
+					if key != "messages" {
+						doc[key] = value
+					}
+				}
+				projected[i] = map[string]interface{}{"_id": hit["_id"], "_score": hit["_score"], "_source": doc, "highlight": hit["highlight"]}
+				if inner := stubInnerHits(hit, req["query"]); len(inner) > 0 {
+					projected[i]["inner_hits"] = inner
+				}
+			}
+			hits = projected
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"took":      3,
+			"timed_out": false,
+			"_shards":   stub.shards,
+			"hits": map[string]interface{}{
+				"total": map[string]interface{}{"value": len(hits), "relation": "eq"},
+				"hits":  hits,
+			},
+		})
+	}))
+	t.Cleanup(stub.server.Close)
+
+	client, err := es.NewClient(es.Config{Addresses: []string{stub.server.URL}})
+	require.NoError(t, err)
+	return stub, client
+}
+
+// stubInnerHits 为查询中带 inner_hits 的嵌套消息子句返回命中中的消息：消息内容包含关键词中
+// 任一个词（不区分大小写），查询带 messages.role 过滤时只返回该角色的消息，最多 size 条
+func stubInnerHits(hit map[string]interface{}, query interface{}) map[string]interface{} {
+	messages, _ := hit["_source"].(map[string]interface{})["messages"].([]interface{})
+	result := map[string]interface{}{}
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if nested, ok := v["nested"].(map[string]interface{}); ok {
+				if spec, ok := nested["inner_hits"].(map[string]interface{}); ok {
+					body, _ := json.Marshal(nested["query"])
+					var role string
+					if _, after, ok := strings.Cut(string(body), `"messages.role":"`); ok {
+						role, _, _ = strings.Cut(after, `"`)
+					}
+					words := strings.Fields(strings.ToLower(queryText(nested["query"])))
+
+					var hits []interface{}
+					for _, item := range messages {
+						message := item.(map[string]interface{})
+						if len(hits) == int(spec["size"].(float64)) {
+							break
+						}
+						if role != "" && message["role"] != role {
+							continue
+						}
+						text := strings.ToLower(fmt.Sprint(message["content"], " ", message["source_content"]))
+						for _, word := range words {
+							if strings.Contains(text, word) {
+								hits = append(hits, map[string]interface{}{"_source": message})
+								break
+							}
+						}
+					}
+					if len(hits) > 0 {
+						result[spec["name"].(string)] = map[string]interface{}{"hits": map[string]interface{}{"hits": hits}}
+					}
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(query)
+	return result
+}
+
+// esHit builds a search hit for a conversation with the given messages (role, content pairs)
+func esHit(id uuid.UUID, title string, messages ...[2]string) map[string]interface{} {
+	msgs := make([]interface{}, 0, len(messages))
+	highlight := map[string]interface{}{}
+	for i, m := range messages {
+		msgs = append(msgs, map[string]interface{}{
+			"id":              uuid.New().String(),
+			"conversation_id": id.String(),
+			"role":            m[0],
+			"content":         m[1],
+			"sequence":        i + 1,
+		})
+		highlight["messages.content"] = []interface{}{m[1]}
+	}
+
+	return map[string]interface{}{
+		"_id":       id.String(),
+		"_score":    1.0,
+		"_source":   map[string]interface{}{"id": id.String(), "title": title, "messages": msgs},
+		"highlight": highlight,
+	}
+}
+
+func TestSearch_RoleFilter(t *testing.T) {
+	userOnly := uuid.New()
+	assistantMatch := uuid.New()
+	stub, client := newESStub(t,
+		esHit(userOnly, "Channels", [2]string{"user", "how do golang channels work"}, [2]string{"assistant", "they pass values"}),
+		esHit(assistantMatch, "Concurrency", [2]string{"user", "explain goroutines"}, [2]string{"assistant", "in golang a goroutine is cheap"}),
+	)
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	role := "assistant"
+	result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{
+		Query: "golang",
+		Role:  &role,
+		Page:  1,
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	docs, matchedMessages := result.Documents, result.MatchedMessages
+
+	// 只有 assistant 消息匹配的对话被保留
+	require.Len(t, docs, 1)
+	assert.Equal(t, assistantMatch, docs[0].ID)
+	for _, msg := range matchedMessages[assistantMatch] {
+		assert.Equal(t, "assistant", msg.Role)
+	}
+
+	// 查询中包含 messages.role 过滤
+	require.Len(t, stub.requests, 1)
+	body, _ := json.Marshal(stub.requests[0])
+	assert.Contains(t, string(body), `{"term":{"messages.role":"assistant"}}`)
+}
+
+func TestSearch_NoRoleFilter(t *testing.T) {
+	stub, client := newESStub(t,
+		esHit(uuid.New(), "Channels", [2]string{"user", "how do golang channels work"}),
+	)
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "golang", Page: 1, Limit: 10})
+	require.NoError(t, err)
+
+	assert.Len(t, result.Documents, 1)
+	body, _ := json.Marshal(stub.requests[0])
+	assert.NotContains(t, string(body), `{"term":{"messages.role"`)
+}
+
+func TestSearch_MetaWithFailedShard(t *testing.T) {
+	stub, client := newESStub(t, esHit(uuid.New(), "Channels", [2]string{"user", "golang"}))
+	stub.shards = map[string]interface{}{"total": 3, "successful": 2, "skipped": 0, "failed": 1}
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "golang", Page: 1, Limit: 10})
+	require.NoError(t, err)
+
+	// 部分分片失败时仍返回结果，并在 meta 中体现
+	assert.Len(t, result.Documents, 1)
+	require.NotNil(t, result.Meta)
+	assert.Equal(t, int64(3), result.Meta.TookMs)
+	assert.Equal(t, 3, result.Meta.ShardsTotal)
+	assert.Equal(t, 2, result.Meta.ShardsSuccessful)
+	assert.Equal(t, 1, result.Meta.ShardsFailed)
+}
+
+func TestSearch_TitleOnlyMatchIncludesContextMessages(t *testing.T) {
+	conversationID := uuid.New()
+	hit := esHit(conversationID, "Golang tips",
+		[2]string{"user", "first question"}, [2]string{"assistant", "first answer"},
+		[2]string{"user", "second question"}, [2]string{"assistant", "second answer"})
+	hit["highlight"] = map[string]interface{}{"title": []interface{}{"<mark>Golang</mark> tips"}}
+	_, client := newESStub(t, hit)
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	t.Run("Context messages enabled", func(t *testing.T) {
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{
+			Query: "golang", Page: 1, Limit: 10, IncludeContextMessages: true,
+		})
+		require.NoError(t, err)
+
+		messages := result.MatchedMessages[conversationID]
+		require.Len(t, messages, 3)
+		assert.Equal(t, "first question", messages[0].Content)
+		for _, msg := range messages {
+			assert.True(t, result.ContextMessages[msg.ID])
+		}
+		assert.Equal(t, []string{"title"}, result.MatchedFields[conversationID])
+	})
+
+	t.Run("Context messages disabled", func(t *testing.T) {
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{
+			Query: "golang", Page: 1, Limit: 10,
+		})
+		require.NoError(t, err)
+
+		assert.NotContains(t, result.MatchedMessages, conversationID)
+	})
+}
+
+func TestSearch_MessageOnlyMatch(t *testing.T) {
+	conversationID := uuid.New()
+	_, client := newESStub(t, esHit(conversationID, "Untitled",
+		[2]string{"user", "hello"}, [2]string{"assistant", "hi"},
+		[2]string{"user", "tell me about golang"}, [2]string{"assistant", "golang is fun"}))
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{
+		Query: "golang", Page: 1, Limit: 10, IncludeContextMessages: true,
+	})
+	require.NoError(t, err)
+
+	// 匹配的消息在前，不足 3 条时补充的消息标记为上下文
+	messages := result.MatchedMessages[conversationID]
+	require.Len(t, messages, 3)
+	assert.Equal(t, "tell me about golang", messages[0].Content)
+	assert.Equal(t, "golang is fun", messages[1].Content)
+	assert.Equal(t, "hello", messages[2].Content)
+	assert.False(t, result.ContextMessages[messages[0].ID])
+	assert.False(t, result.ContextMessages[messages[1].ID])
+	assert.True(t, result.ContextMessages[messages[2].ID])
+}
+
+func TestSearch_MinScore(t *testing.T) {
+	strong := esHit(uuid.New(), "Golang", [2]string{"user", "golang generics"})
+	strong["_score"] = 12.5
+	weak := esHit(uuid.New(), "Gold", [2]string{"user", "gold prices and golang"})
+	weak["_score"] = 0.4
+
+	t.Run("Low-relevance hits are excluded", func(t *testing.T) {
+		stub, client := newESStub(t, strong, weak)
+		repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+		minScore := 1.0
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{
+			Query: "golang", MinScore: &minScore, Page: 1, Limit: 10,
+		})
+		require.NoError(t, err)
+
+		require.Len(t, result.Documents, 1)
+		assert.Equal(t, "Golang", result.Documents[0].Title)
+		assert.Equal(t, 1.0, stub.requests[0]["min_score"])
+
+		// 过滤条件不参与评分
+		boolQuery := stub.requests[0]["query"].(map[string]interface{})["bool"].(map[string]interface{})
+		assert.Contains(t, boolQuery, "filter")
+		assert.NotContains(t, boolQuery, "must")
+	})
+
+	t.Run("Zero disables the threshold", func(t *testing.T) {
+		stub, client := newESStub(t, strong, weak)
+		repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+		minScore := 0.0
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{
+			Query: "golang", MinScore: &minScore, Page: 1, Limit: 10,
+		})
+		require.NoError(t, err)
+
+		assert.Len(t, result.Documents, 2)
+		assert.NotContains(t, stub.requests[0], "min_score")
+	})
+
+	t.Run("Filter-only queries ignore the threshold", func(t *testing.T) {
+		stub, client := newESStub(t, strong, weak)
+		repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+		minScore := 1.0
+		_, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{
+			MinScore: &minScore, Page: 1, Limit: 10,
+		})
+		require.NoError(t, err)
+
+		assert.NotContains(t, stub.requests[0], "min_score")
+	})
+}
+
+func TestSearch_ExactPhraseBoost(t *testing.T) {
+	stub, client := newESStub(t, esHit(uuid.New(), "缓存 失效 排查", [2]string{"user", "缓存 失效 怎么办"}))
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	_, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{
+		Query: "缓存 失效", Page: 1, Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, stub.requests, 1)
+
+	// 收集所有 multi_match 子句中每个字段的权重和查询类型
+	boosts := make(map[string]float64)
+	types := make(map[string]string)
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch v := node.(type) {
+		case map[string]interface{}:
+			if multiMatch, ok := v["multi_match"].(map[string]interface{}); ok {
+				for _, f := range multiMatch["fields"].([]interface{}) {
+					name, boost, _ := strings.Cut(f.(string), "^")
+					var weight float64
+					fmt.Sscan(boost, &weight)
+					boosts[name] = max(boosts[name], weight)
+					types[name] = multiMatch["type"].(string)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(stub.requests[0]["query"])
+
+	// exact 子字段按短语匹配，关键词作为短语出现在字段中即命中。短语命中同时满足同一字段的
+	// 其他匹配方式，exact 权重不低于其中最高的一项，因此短语命中的得分最高
+	for _, field := range []string{"title", "source_title", "messages.content", "messages.source_content", "tags.name"} {
+		exact := field + ".exact"
+		require.Contains(t, boosts, exact)
+		assert.Equal(t, "phrase", types[exact], exact)
+		assert.GreaterOrEqual(t, boosts[exact], boosts[field], exact)
+	}
+}
+
+func TestSearch_MetadataFilter(t *testing.T) {
+	conversationID := uuid.New()
+	hit := esHit(conversationID, "Roadmap", [2]string{"user", "plan the backend roadmap"})
+	hit["_source"].(map[string]interface{})["metadata"] = map[string]interface{}{"project": "backend", "account": "acc-1"}
+	stub, client := newESStub(t, hit)
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{
+		Metadata: map[string]string{"project": "backend", "account": "acc-1"},
+		Page:     1,
+		Limit:    10,
+	})
+	require.NoError(t, err)
+
+	// 元信息从 ES 文档中解析
+	require.Len(t, result.Documents, 1)
+	assert.Equal(t, map[string]string{"project": "backend", "account": "acc-1"}, result.Documents[0].Metadata)
+
+	// 每个元信息 key 生成一个 term 过滤
+	body, _ := json.Marshal(stub.requests[0])
+	assert.Contains(t, string(body), `{"term":{"metadata.account":"acc-1"}}`)
+	assert.Contains(t, string(body), `{"term":{"metadata.project":"backend"}}`)
+}
+
+func TestParseEnums(t *testing.T) {
+	t.Run("Known values", func(t *testing.T) {
+		for _, order := range repositories.SearchOrders {
+			parsed, err := repositories.ParseSearchOrder(order.String())
+			require.NoError(t, err)
+			assert.Equal(t, order, parsed)
+		}
+		for _, order := range repositories.ListOrders {
+			parsed, err := repositories.ParseListOrder(order.String())
+			require.NoError(t, err)
+			assert.Equal(t, order, parsed)
+		}
+		for _, order := range repositories.MessageOrders {
+			parsed, err := repositories.ParseMessageOrder(order.String())
+			require.NoError(t, err)
+			assert.Equal(t, order, parsed)
+		}
+		for _, sort := range repositories.SearchSorts {
+			_, err := repositories.ParseSearchSort(sort.String())
+			require.NoError(t, err)
+		}
+		for _, match := range repositories.TagMatches {
+			parsed, err := repositories.ParseTagMatch(match.String())
+			require.NoError(t, err)
+			assert.Equal(t, match, parsed)
+		}
+	})
+
+	t.Run("Empty uses the default", func(t *testing.T) {
+		searchOrder, err := repositories.ParseSearchOrder("")
+		require.NoError(t, err)
+		assert.Equal(t, repositories.SearchOrder(repositories.OrderRelevance), searchOrder)
+
+		listOrder, err := repositories.ParseListOrder("")
+		require.NoError(t, err)
+		assert.Equal(t, repositories.ListOrder(repositories.OrderCreated), listOrder)
+
+		messageOrder, err := repositories.ParseMessageOrder("")
+		require.NoError(t, err)
+		assert.Equal(t, repositories.MessageOrder(repositories.MessageOrderAsc), messageOrder)
+
+		tagMatch, err := repositories.ParseTagMatch("")
+		require.NoError(t, err)
+		assert.Equal(t, repositories.TagMatch(repositories.TagMatchAll), tagMatch)
+	})
+
+	t.Run("Unknown values", func(t *testing.T) {
+		_, err := repositories.ParseSearchOrder("Relevance")
+		assert.EqualError(t, err, "order must be one of: relevance, activity, created")
+
+		// 对话列表不支持按相关性排序
+		_, err = repositories.ParseListOrder(repositories.OrderRelevance)
+		assert.EqualError(t, err, "order must be one of: created, activity")
+
+		_, err = repositories.ParseMessageOrder("newest")
+		assert.EqualError(t, err, "order must be one of: asc, desc")
+
+		_, err = repositories.ParseTagMatch("some")
+		assert.EqualError(t, err, "tag_match must be one of: all, any")
+
+		// sort 只接受 relevance 和 date
+		_, err = repositories.ParseSearchSort("created")
+		assert.EqualError(t, err, "sort must be one of: relevance, date")
+	})
+}
+
+func TestSearch_OrderQueryParam(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	get := func(service *MockSearchService, query string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.GET("/search", handlers.NewSearchHandler(service).Search)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?"+query, nil))
+		return w
+	}
+
+	t.Run("Valid order", func(t *testing.T) {
+		service := new(MockSearchService)
+		service.On("SearchWithMatchedMessages", mock.MatchedBy(func(params repositories.SearchParams) bool {
+			return params.Order == repositories.OrderActivity
+		})).Return(&response.SearchResponse{}, int64(0), &models.SearchMeta{}, nil)
+
+		assert.Equal(t, http.StatusOK, get(service, "q=go&order=activity").Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Sort", func(t *testing.T) {
+		for query, expected := range map[string]repositories.SearchOrder{
+			"q=go&sort=date":                      repositories.OrderCreated,
+			"q=go&sort=relevance":                 repositories.OrderRelevance,
+			"q=go":                                repositories.OrderRelevance,
+			"q=go&sort=date&order=created":        repositories.OrderCreated,
+			"q=go&order=created":                  repositories.OrderCreated,
+			"q=go&sort=relevance&order=relevance": repositories.OrderRelevance,
+		} {
+			service := new(MockSearchService)
+			service.On("SearchWithMatchedMessages", mock.MatchedBy(func(params repositories.SearchParams) bool {
+				return params.Order == expected
+			})).Return(&response.SearchResponse{}, int64(0), &models.SearchMeta{}, nil)
+
+			assert.Equal(t, http.StatusOK, get(service, query).Code, query)
+			service.AssertExpectations(t)
+		}
+	})
+
+	t.Run("Invalid sort", func(t *testing.T) {
+		w := get(new(MockSearchService), "q=go&sort=created")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "sort must be one of: relevance, date")
+
+		// sort 与别名 order 含义不同
+		w = get(new(MockSearchService), "q=go&sort=date&order=activity")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "INVALID_ORDER")
+	})
+
+	t.Run("Unknown order", func(t *testing.T) {
+		w := get(new(MockSearchService), "q=go&order=score")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "INVALID_ORDER")
+		assert.Contains(t, w.Body.String(), "order must be one of: relevance, activity, created")
+	})
+}
+
+func TestParseFilterExpression(t *testing.T) {
+	t.Run("Valid expressions", func(t *testing.T) {
+		valid := []string{
+			`{"provider":"openai"}`,
+			`{"or":[{"provider":"openai"},{"tag":"work"}]}`,
+			`{"and":[{"not":{"role":"system"}},{"meta.project":"backend"}]}`,
+			`{"tag_id":"` + uuid.New().String() + `"}`,
+		}
+		for _, input := range valid {
+			_, err := repositories.ParseFilterExpression(input)
+			assert.NoError(t, err, input)
+		}
+	})
+
+	t.Run("Invalid expressions", func(t *testing.T) {
+		invalid := []string{
+			`not json`,
+			`[]`,
+			`{}`,
+			`{"xor":[{"provider":"openai"}]}`,
+			`{"title":"golang"}`,
+			`{"meta.unknown":"x"}`,
+			`{"or":[]}`,
+			`{"provider":"openai","tag":"work"}`,
+			`{"role":"bot"}`,
+			`{"tag_id":"not-a-uuid"}`,
+			`{"provider":1}`,
+			`{"not":{"not":{"not":{"not":{"not":{"provider":"openai"}}}}}}`,
+		}
+		for _, input := range invalid {
+			_, err := repositories.ParseFilterExpression(input)
+			assert.Error(t, err, input)
+		}
+	})
+}
+
+func TestSearch_FilterExpression(t *testing.T) {
+	search := func(t *testing.T, filter string, params repositories.SearchParams) string {
+		expr, err := repositories.ParseFilterExpression(filter)
+		require.NoError(t, err)
+
+		stub, client := newESStub(t, esHit(uuid.New(), "Golang", [2]string{"user", "golang"}))
+		repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+		params.Filter = expr
+		params.Page, params.Limit = 1, 10
+		_, err = repo.SearchConversationsWithMatchedMessages(context.Background(), params)
+		require.NoError(t, err)
+
+		body, _ := json.Marshal(stub.requests[0]["query"])
+		return string(body)
+	}
+
+	t.Run("OR combines clauses with should", func(t *testing.T) {
+		body := search(t, `{"or":[{"provider":"openai"},{"tag":"work"}]}`, repositories.SearchParams{})
+
+		assert.Contains(t, body, `{"bool":{"minimum_should_match":1,"should":[{"term":{"provider":"openai"}},{"nested":{"path":"tags","query":{"term":{"tags.name.keyword":"work"}}}}]}}`)
+	})
+
+	t.Run("NOT becomes must_not", func(t *testing.T) {
+		body := search(t, `{"and":[{"not":{"provider":"openai"}},{"meta.project":"backend"}]}`, repositories.SearchParams{})
+
+		assert.Contains(t, body, `{"bool":{"filter":[{"bool":{"must_not":[{"term":{"provider":"openai"}}]}},{"term":{"metadata.project":"backend"}}]}}`)
+	})
+
+	t.Run("Combined with simple params via AND", func(t *testing.T) {
+		provider := "claude"
+		body := search(t, `{"not":{"role":"system"}}`, repositories.SearchParams{Query: "golang", ProviderID: &provider})
+
+		var query map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(body), &query))
+		filters := query["bool"].(map[string]interface{})["filter"].([]interface{})
+		require.Len(t, filters, 2)
+		assert.Equal(t, map[string]interface{}{"term": map[string]interface{}{"provider": "claude"}}, filters[0])
+		assert.Contains(t, filters[1].(map[string]interface{})["bool"], "must_not")
+	})
+}
+
+func TestSearch_SlowSearchLogging(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	previous := logger.Logger
+	logger.Logger = zap.New(core)
+	t.Cleanup(func() { logger.Logger = previous })
+
+	hit := esHit(uuid.New(), "Golang", [2]string{"user", "golang generics"})
+
+	t.Run("Slow search is logged", func(t *testing.T) {
+		stub, client := newESStub(t, hit)
+		stub.delay = 30 * time.Millisecond
+		repo := repositories.NewElasticsearchRepository(client, "conversations", 10*time.Millisecond)
+
+		provider := "openai"
+		_, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{
+			Query: "golang", ProviderID: &provider, Page: 1, Limit: 10,
+		})
+		require.NoError(t, err)
+
+		entries := logs.FilterMessage("Slow Elasticsearch search").All()
+		logs.TakeAll()
+		require.Len(t, entries, 1)
+		fields := entries[0].ContextMap()
+		assert.Equal(t, "golang", fields["query"])
+		assert.Equal(t, int64(1), fields["hits"])
+		assert.Equal(t, int64(3), fields["took_ms"])
+		assert.Equal(t, map[string]interface{}{"provider_id": "openai"}, fields["filters"])
+
+		assert.Equal(t, repositories.SearchStats{Searches: 1, SlowSearches: 1}, repo.Stats())
+	})
+
+	t.Run("Fast search is not logged", func(t *testing.T) {
+		_, client := newESStub(t, hit)
+		repo := repositories.NewElasticsearchRepository(client, "conversations", time.Minute)
+
+		_, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "golang", Page: 1, Limit: 10})
+		require.NoError(t, err)
+
+		assert.Zero(t, logs.FilterMessage("Slow Elasticsearch search").Len())
+		assert.Equal(t, repositories.SearchStats{Searches: 1}, repo.Stats())
+	})
+
+	t.Run("Failed searches are counted", func(t *testing.T) {
+		stub, client := newESStub(t)
+		stub.server.Close()
+		repo := repositories.NewElasticsearchRepository(client, "conversations", 10*time.Millisecond)
+
+		_, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "golang", Page: 1, Limit: 10})
+		require.Error(t, err)
+
+		assert.Equal(t, repositories.SearchStats{Searches: 1, FailedSearches: 1}, repo.Stats())
+	})
+}
+
+// MockSearchService is a mock implementation of services.SearchService
+type MockSearchService struct {
+	mock.Mock
+}
+
+func (m *MockSearchService) SearchWithMatchedMessages(ctx context.Context, params repositories.SearchParams) (*response.SearchResponse, int64, *models.SearchMeta, error) {
+	args := m.Called(params)
+	return args.Get(0).(*response.SearchResponse), args.Get(1).(int64), args.Get(2).(*models.SearchMeta), args.Error(3)
+}
+
+func (m *MockSearchService) FindSimilarConversations(conversationID uuid.UUID, limit int) (*response.SimilarConversationsResponse, error) {
+	args := m.Called(conversationID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*response.SimilarConversationsResponse), args.Error(1)
+}
+
+func TestSearchPost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	post := func(service *MockSearchService, body string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.POST("/search", handlers.NewSearchHandler(service).SearchPost)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/search", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Multiple tag IDs in the body", func(t *testing.T) {
+		tagA, tagB := uuid.New(), uuid.New()
+		service := new(MockSearchService)
+		service.On("SearchWithMatchedMessages", mock.MatchedBy(func(params repositories.SearchParams) bool {
+			return params.Query == "golang" &&
+				assert.ObjectsAreEqual([]uuid.UUID{tagA, tagB}, params.TagIDs) &&
+				params.Role != nil && *params.Role == "assistant" &&
+				params.Filter != nil && len(params.Filter.Or) == 2 &&
+				params.Page == 1 && params.Limit == 10
+		})).Return(&response.SearchResponse{}, int64(0), &models.SearchMeta{}, nil)
+
+		w := post(service, `{"q":"golang","tag_ids":["`+tagA.String()+`","`+tagB.String()+`"],"role":"assistant",`+
+			`"filter":{"or":[{"provider":"openai"},{"tag":"work"}]}}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Sort in the body", func(t *testing.T) {
+		service := new(MockSearchService)
+		service.On("SearchWithMatchedMessages", mock.MatchedBy(func(params repositories.SearchParams) bool {
+			return params.Order == repositories.OrderCreated
+		})).Return(&response.SearchResponse{}, int64(0), &models.SearchMeta{}, nil)
+
+		assert.Equal(t, http.StatusOK, post(service, `{"q":"golang","sort":"date"}`).Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Invalid input returns 422", func(t *testing.T) {
+		invalid := []string{
+			`{"tag_ids":["not-a-uuid"]}`,
+			`{"role":"bot"}`,
+			`{"limit":1000}`,
+			`{"tag_match":"some"}`,
+			`{"start_date":"2024/01/01"}`,
+			`{"meta":{"unknown":"x"}}`,
+			`{"filter":{"xor":[]}}`,
+			`{"sort":"created"}`,
+			`{"sort":"date","order":"relevance"}`,
+			`{"q":`,
+		}
+		for _, body := range invalid {
+			service := new(MockSearchService)
+			w := post(service, body)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code, body)
+			service.AssertNotCalled(t, "SearchWithMatchedMessages", mock.Anything)
+		}
+	})
+}
+
+func TestSearch_MultipleTagIDs(t *testing.T) {
+	tagA, tagB := uuid.New(), uuid.New()
+	hit := esHit(uuid.New(), "Golang", [2]string{"user", "golang"})
+	hit["_source"].(map[string]interface{})["tags"] = []interface{}{
+		map[string]interface{}{"id": tagA.String(), "name": "go"},
+		map[string]interface{}{"id": tagB.String(), "name": "backend"},
+	}
+
+	search := func(t *testing.T, tagMatch repositories.TagMatch) map[string]interface{} {
+		stub, client := newESStub(t, hit)
+		repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{
+			TagIDs: []uuid.UUID{tagA, tagB}, TagMatch: tagMatch, Page: 1, Limit: 10,
+		})
+		require.NoError(t, err)
+		require.Len(t, result.Documents, 1)
+		assert.Len(t, result.Documents[0].Tags, 2)
+
+		filters := stub.requests[0]["query"].(map[string]interface{})["bool"].(map[string]interface{})["must"].([]interface{})
+		require.Len(t, filters, 1)
+		return filters[0].(map[string]interface{})["bool"].(map[string]interface{})
+	}
+
+	nestedTag := func(id uuid.UUID) interface{} {
+		return map[string]interface{}{"nested": map[string]interface{}{
+			"path":  "tags",
+			"query": map[string]interface{}{"term": map[string]interface{}{"tags.id": id.String()}},
+		}}
+	}
+
+	t.Run("all requires every tag", func(t *testing.T) {
+		clause := search(t, repositories.TagMatchAll)
+
+		assert.Equal(t, []interface{}{nestedTag(tagA), nestedTag(tagB)}, clause["must"])
+		assert.NotContains(t, clause, "should")
+	})
+
+	t.Run("any requires one tag", func(t *testing.T) {
+		clause := search(t, repositories.TagMatchAny)
+
+		assert.Equal(t, []interface{}{nestedTag(tagA), nestedTag(tagB)}, clause["should"])
+		assert.Equal(t, float64(1), clause["minimum_should_match"])
+		assert.NotContains(t, clause, "must")
+	})
+}
+
+func TestSearch_TagIDsQueryParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tagA, tagB := uuid.New(), uuid.New()
+
+	get := func(service *MockSearchService, query string) int {
+		router := gin.New()
+		router.GET("/search", handlers.NewSearchHandler(service).Search)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?"+query, nil))
+		return w.Code
+	}
+
+	t.Run("Comma-separated and repeated tag_ids", func(t *testing.T) {
+		service := new(MockSearchService)
+		service.On("SearchWithMatchedMessages", mock.MatchedBy(func(params repositories.SearchParams) bool {
+			return assert.ObjectsAreEqual([]uuid.UUID{tagA, tagB, tagA}, params.TagIDs) && params.TagMatch == repositories.TagMatchAny
+		})).Return(&response.SearchResponse{}, int64(0), &models.SearchMeta{}, nil)
+
+		code := get(service, "tag_ids="+tagA.String()+","+tagB.String()+"&tag_ids="+tagA.String()+"&tag_match=any")
+
+		assert.Equal(t, http.StatusOK, code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Defaults to all", func(t *testing.T) {
+		service := new(MockSearchService)
+		service.On("SearchWithMatchedMessages", mock.MatchedBy(func(params repositories.SearchParams) bool {
+			return params.TagMatch == repositories.TagMatchAll
+		})).Return(&response.SearchResponse{}, int64(0), &models.SearchMeta{}, nil)
+
+		assert.Equal(t, http.StatusOK, get(service, "tag_ids="+tagA.String()))
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Invalid tag ID or mode", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get(new(MockSearchService), "tag_ids="+tagA.String()+",nope"))
+		assert.Equal(t, http.StatusBadRequest, get(new(MockSearchService), "tag_ids="+tagA.String()+"&tag_match=some"))
+	})
+}
+
+func TestSearch_FieldSelection(t *testing.T) {
+	titleMatch := uuid.New()
+	messageMatch := uuid.New()
+	stub, client := newESStub(t,
+		esHit(titleMatch, "Golang tips", [2]string{"user", "what should I read first"}),
+		esHit(messageMatch, "Misc", [2]string{"user", "how do golang channels work"}),
+	)
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	search := func(fields []string) []uuid.UUID {
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "golang", Fields: fields, Page: 1, Limit: 10})
+		require.NoError(t, err)
+		ids := make([]uuid.UUID, len(result.Documents))
+		for i, doc := range result.Documents {
+			ids[i] = doc.ID
+		}
+		return ids
+	}
+
+	// 默认匹配全部字段
+	assert.ElementsMatch(t, []uuid.UUID{titleMatch, messageMatch}, search(nil))
+
+	// 只搜索标题时，仅消息匹配的对话被排除
+	assert.Equal(t, []uuid.UUID{titleMatch}, search([]string{repositories.SearchFieldTitle}))
+	body := stub.requests[len(stub.requests)-1]
+	should := body["query"].(map[string]interface{})["bool"].(map[string]interface{})["should"].([]interface{})
+	assert.Len(t, should, 4)
+	for _, clause := range should {
+		assert.NotContains(t, clause, "nested")
+	}
+	highlightFields := body["highlight"].(map[string]interface{})["fields"].(map[string]interface{})
+	assert.Len(t, highlightFields, 2)
+	assert.Contains(t, highlightFields, "title")
+	assert.Contains(t, highlightFields, "source_title")
+
+	// 只搜索消息时，仅标题匹配的对话被排除
+	assert.Equal(t, []uuid.UUID{messageMatch}, search([]string{repositories.SearchFieldMessages}))
+
+	t.Run("Query parameter", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		get := func(service *MockSearchService, query string) int {
+			router := gin.New()
+			router.GET("/search", handlers.NewSearchHandler(service).Search)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?"+query, nil))
+			return w.Code
+		}
+
+		service := new(MockSearchService)
+		service.On("SearchWithMatchedMessages", mock.MatchedBy(func(params repositories.SearchParams) bool {
+			return assert.ObjectsAreEqual([]string{"title", "tags"}, params.Fields)
+		})).Return(&response.SearchResponse{}, int64(0), &models.SearchMeta{}, nil)
+		assert.Equal(t, http.StatusOK, get(service, "q=golang&fields=title,+tags,title"))
+		service.AssertExpectations(t)
+
+		assert.Equal(t, http.StatusBadRequest, get(new(MockSearchService), "q=golang&fields=title,body"))
+	})
+
+	t.Run("Request body", func(t *testing.T) {
+		router := gin.New()
+		router.POST("/search", handlers.NewSearchHandler(new(MockSearchService)).SearchPost)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/search", strings.NewReader(`{"q": "golang", "fields": ["metadata"]}`)))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "INVALID_FIELDS")
+	})
+}
+
+func TestSearch_PaginationQueryParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	get := func(service *MockSearchService, path string) *httptest.ResponseRecorder {
+		handler := handlers.NewSearchHandler(service)
+		router := gin.New()
+		router.GET("/search", handler.Search)
+		router.GET("/search/similar/:conversationId", handler.FindSimilar)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	t.Run("Absent parameters use defaults", func(t *testing.T) {
+		service := new(MockSearchService)
+		service.On("SearchWithMatchedMessages", mock.MatchedBy(func(params repositories.SearchParams) bool {
+			return params.Page == 1 && params.Limit == 10
+		})).Return(&response.SearchResponse{}, int64(0), &models.SearchMeta{}, nil)
+
+		assert.Equal(t, http.StatusOK, get(service, "/search?q=go").Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Valid parameters are used", func(t *testing.T) {
+		service := new(MockSearchService)
+		service.On("SearchWithMatchedMessages", mock.MatchedBy(func(params repositories.SearchParams) bool {
+			return params.Page == 3 && params.Limit == 100
+		})).Return(&response.SearchResponse{}, int64(0), &models.SearchMeta{}, nil)
+
+		assert.Equal(t, http.StatusOK, get(service, "/search?q=go&page=3&limit=100").Code)
+		service.AssertExpectations(t)
+	})
+
+	for _, tc := range []struct {
+		query string
+		code  string
+	}{
+		{"page=abc", "INVALID_PAGE"},
+		{"page=0", "INVALID_PAGE"},
+		{"limit=0", "INVALID_LIMIT"},
+		{"limit=99999", "INVALID_LIMIT"},
+		{"limit=ten", "INVALID_LIMIT"},
+	} {
+		t.Run("Rejects "+tc.query, func(t *testing.T) {
+			service := new(MockSearchService)
+			w := get(service, "/search?q=go&"+tc.query)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.code)
+			service.AssertNotCalled(t, "SearchWithMatchedMessages", mock.Anything)
+		})
+	}
+
+	t.Run("Similar rejects limit above its maximum", func(t *testing.T) {
+		w := get(new(MockSearchService), "/search/similar/"+uuid.New().String()+"?limit=21")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "INVALID_LIMIT")
+	})
+}
+
+func TestElasticsearchIndexer_RefreshPolicy(t *testing.T) {
+	document := &models.ConversationDocument{ID: uuid.New(), Title: "Refresh"}
+
+	t.Run("Defaults", func(t *testing.T) {
+		stub, client := newESStub(t)
+		indexer := repositories.NewElasticsearchIndexer(client, "conversations")
+
+		require.NoError(t, indexer.IndexConversation(document))
+		require.NoError(t, indexer.BulkIndexConversations([]*models.ConversationDocument{document}))
+
+		require.Len(t, stub.queries, 2)
+		assert.Equal(t, repositories.RefreshWaitFor, stub.queries[0].Get("refresh"))
+		assert.Equal(t, repositories.RefreshFalse, stub.queries[1].Get("refresh"))
+	})
+
+	t.Run("Custom policy", func(t *testing.T) {
+		stub, client := newESStub(t)
+		indexer := repositories.NewElasticsearchIndexerWithOptions(client, "conversations", repositories.IndexerOptions{
+			Refresh: repositories.RefreshPolicy{Write: repositories.RefreshTrue, Bulk: repositories.RefreshWaitFor},
+		})
+
+		require.NoError(t, indexer.IndexConversation(document))
+		require.NoError(t, indexer.BulkIndexConversations([]*models.ConversationDocument{document}))
+
+		require.Len(t, stub.queries, 2)
+		assert.Equal(t, repositories.RefreshTrue, stub.queries[0].Get("refresh"))
+		assert.Equal(t, repositories.RefreshWaitFor, stub.queries[1].Get("refresh"))
+	})
+
+	t.Run("Invalid values fall back to defaults", func(t *testing.T) {
+		stub, client := newESStub(t)
+		indexer := repositories.NewElasticsearchIndexerWithOptions(client, "conversations", repositories.IndexerOptions{
+			Refresh: repositories.RefreshPolicy{Write: "sometimes"},
+		})
+
+		require.NoError(t, indexer.IndexConversation(document))
+
+		assert.Equal(t, repositories.RefreshWaitFor, stub.queries[0].Get("refresh"))
+	})
+}
+
+func TestElasticsearchIndexer_TruncatesLongMessages(t *testing.T) {
+	long := strings.Repeat("汉字", 10) // 20 个字符
+	newConversation := func() *models.Conversation {
+		conversationID := uuid.New()
+		return &models.Conversation{
+			Base:  models.Base{ID: conversationID},
+			Title: "Pasted file",
+			Messages: []models.Message{
+				{Base: models.Base{ID: uuid.New()}, ConversationID: conversationID, Role: "user", Content: long, SourceContent: long},
+				{Base: models.Base{ID: uuid.New()}, ConversationID: conversationID, Role: "assistant", Content: "short"},
+			},
+		}
+	}
+
+	conversation := newConversation()
+	assert.True(t, conversation.Messages[0].MarkIndexTruncation(8))
+	assert.False(t, conversation.Messages[1].MarkIndexTruncation(8))
+
+	stub, client := newESStub(t)
+	indexer := repositories.NewElasticsearchIndexerWithOptions(client, "conversations", repositories.IndexerOptions{MaxMessageChars: 8})
+	require.NoError(t, indexer.IndexConversation(conversation.ToESDocument()))
+
+	// ES 文档中只保留前 8 个字符并带上截断标记
+	require.Len(t, stub.requests, 1)
+	messages := stub.requests[0]["messages"].([]interface{})
+	first := messages[0].(map[string]interface{})
+	assert.Equal(t, "汉字汉字汉字汉字", first["content"])
+	assert.Equal(t, "汉字汉字汉字汉字", first["source_content"])
+	assert.Equal(t, true, first["content_truncated"])
+	second := messages[1].(map[string]interface{})
+	assert.Equal(t, "short", second["content"])
+	assert.NotContains(t, second, "content_truncated")
+
+	// 模型中保留完整内容，并在元信息中记录截断
+	assert.Equal(t, long, conversation.Messages[0].Content)
+	assert.Equal(t, &models.MessageMetadata{IndexTruncated: true, ContentChars: 20}, conversation.Messages[0].GetMetadata())
+	assert.Nil(t, conversation.Messages[1].GetMetadata())
+
+	t.Run("Database keeps full content", func(t *testing.T) {
+		db := openTestDB(t)
+		_, stored := createTestConversation(t, db)
+
+		message := newConversation().Messages[0]
+		message.ConversationID = stored.ID
+		message.SourceID = uuid.NewString()
+		message.MarkIndexTruncation(8)
+		require.NoError(t, db.Create(&message).Error)
+
+		var loaded models.Message
+		require.NoError(t, db.First(&loaded, "id = ?", message.ID).Error)
+		assert.Equal(t, long, loaded.Content)
+		assert.True(t, loaded.GetMetadata().IndexTruncated)
+	})
+}
+
+func TestElasticsearchIndexer_CapsIndexedMessages(t *testing.T) {
+	conversationID := uuid.New()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	conversation := &models.Conversation{Base: models.Base{ID: conversationID}, Title: "Long chat"}
+	// 故意打乱加载顺序，保留的应是时间上最近的消息
+	for _, minute := range []int{4, 0, 3, 1, 2} {
+		conversation.Messages = append(conversation.Messages, models.Message{
+			Base:           models.Base{ID: uuid.New(), CreatedAt: start.Add(time.Duration(minute) * time.Minute)},
+			ConversationID: conversationID,
+			Role:           "user",
+			Content:        fmt.Sprintf("message %d", minute),
+		})
+	}
+	doc := conversation.ToESDocument()
+
+	stub, client := newESStub(t)
+	indexer := repositories.NewElasticsearchIndexerWithOptions(client, "conversations", repositories.IndexerOptions{MaxMessages: 3})
+	require.NoError(t, indexer.IndexConversation(doc))
+
+	require.Len(t, stub.requests, 1)
+	indexed := stub.requests[0]
+	messages := indexed["messages"].([]interface{})
+	require.Len(t, messages, 3)
+	for i, content := range []string{"message 2", "message 3", "message 4"} {
+		assert.Equal(t, content, messages[i].(map[string]interface{})["content"])
+	}
+	assert.Equal(t, true, indexed["messages_truncated"])
+	// 内容哈希基于全部消息，调整上限不会让同步误判为内容一致
+	assert.Equal(t, doc.ComputeContentHash(), indexed["content_hash"])
+	// 调用方的文档保留全部消息
+	assert.Len(t, doc.Messages, 5)
+	assert.False(t, doc.MessagesTruncated)
+
+	t.Run("Under the limit", func(t *testing.T) {
+		assert.Same(t, doc, doc.WithRecentMessages(5))
+		assert.Same(t, doc, doc.WithRecentMessages(0))
+	})
+
+	t.Run("Add message passes the limit to the script", func(t *testing.T) {
+		message := models.MessageDocument{ID: uuid.New(), ConversationID: conversationID, Role: "user", Content: "message 5"}
+		require.NoError(t, indexer.AddMessageToConversation(conversationID, message))
+
+		require.Len(t, stub.requests, 2)
+		script := stub.requests[1]["script"].(map[string]interface{})
+		params := script["params"].(map[string]interface{})
+		assert.Equal(t, float64(3), params["maxMessages"])
+		assert.Contains(t, script["source"], "messages_truncated = true")
+	})
+}
+
+func TestSearch_PostFilterToggle(t *testing.T) {
+	fuzzy := uuid.New()
+	mention := uuid.New()
+	titled := uuid.New()
+	_, client := newESStub(t,
+		// ES 按 fuzziness 命中，但不包含关键词原文
+		esHit(fuzzy, "Channel basics", [2]string{"user", "what are chanels in go"}),
+		esHit(mention, "Misc", [2]string{"user", "buffered channels"}),
+		esHit(titled, "Channels", [2]string{"user", "channels and select"}),
+	)
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	search := func(postFilter bool) ([]uuid.UUID, int64) {
+		cfg := &config.Config{Search: config.SearchConfig{PostFilter: postFilter}}
+		result, total, _, err := services.NewSearchService(repo, new(MockConversationRepository), cfg, nil).SearchWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "channels", Page: 1, Limit: 10})
+		require.NoError(t, err)
+
+		ids := make([]uuid.UUID, len(result.Conversations))
+		for i, conversation := range result.Conversations {
+			ids[i] = conversation.ID
+		}
+		return ids, total
+	}
+
+	t.Run("On drops fuzzy matches and re-sorts", func(t *testing.T) {
+		ids, total := search(true)
+		assert.Equal(t, []uuid.UUID{titled, mention}, ids)
+		assert.Equal(t, int64(2), total)
+	})
+
+	t.Run("Off keeps ES order", func(t *testing.T) {
+		ids, total := search(false)
+		assert.Equal(t, []uuid.UUID{fuzzy, mention, titled}, ids)
+		assert.Equal(t, int64(3), total)
+	})
+}
+
+func TestSearch_SourceFieldsMatchedSeparately(t *testing.T) {
+	conversationID := uuid.New()
+	messageID := uuid.New()
+	// 导入的对话：原始语言的标题和内容包含关键词，规范化后的译文不包含
+	hit := map[string]interface{}{
+		"_id":    conversationID.String(),
+		"_score": 1.0,
+		"_source": map[string]interface{}{
+			"id":           conversationID.String(),
+			"title":        "Weekend trip",
+			"source_title": "杭州 旅行 计划",
+			"messages": []interface{}{
+				map[string]interface{}{
+					"id":              messageID.String(),
+					"conversation_id": conversationID.String(),
+					"role":            "user",
+					"content":         "How should I plan two days there?",
+					"source_content":  "杭州 两天 怎么 安排",
+				},
+			},
+		},
+		"highlight": map[string]interface{}{
+			"source_title":            []interface{}{"<mark>杭州</mark> 旅行 计划"},
+			"messages.source_content": []interface{}{"<mark>杭州</mark> 两天 怎么 安排"},
+		},
+	}
+	_, client := newESStub(t, hit)
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	cfg := &config.Config{Search: config.SearchConfig{PostFilter: true}}
+	result, total, _, err := services.NewSearchService(repo, new(MockConversationRepository), cfg, nil).SearchWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "杭州", Page: 1, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, result.Conversations, 1)
+
+	conversation := result.Conversations[0]
+	assert.Equal(t, "Weekend trip", conversation.Title)
+	assert.Equal(t, "杭州 旅行 计划", conversation.SourceTitle)
+	assert.Equal(t, []string{"source_title", "messages.source_content"}, conversation.MatchedFields)
+	assert.Equal(t, map[string][]string{"source_title": {"<mark>杭州</mark> 旅行 计划"}}, conversation.Highlights)
+
+	require.Len(t, conversation.Messages, 1)
+	message := conversation.Messages[0]
+	assert.Equal(t, messageID, message.ID)
+	assert.False(t, message.IsContext)
+	assert.Equal(t, "How should I plan two days there?", message.Content)
+	assert.Equal(t, "杭州 两天 怎么 安排", message.SourceContent)
+	assert.Equal(t, []string{"source_content"}, message.MatchedFields)
+	assert.Equal(t, map[string][]string{"source_content": {"<mark>杭州</mark> 两天 怎么 安排"}}, message.Highlights)
+}
+
+func TestSearch_Snippets(t *testing.T) {
+	conversationID := uuid.New()
+	long := strings.Repeat("a", 500) + " goroutines are cheap " + strings.Repeat("b", 500)
+	_, client := newESStub(t, esHit(conversationID, "Concurrency", [2]string{"user", long}))
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	search := func(snippetChars int) response.SearchMessageResponse {
+		cfg := &config.Config{Search: config.SearchConfig{PostFilter: true, SnippetChars: snippetChars}}
+		result, _, _, err := services.NewSearchService(repo, new(MockConversationRepository), cfg, nil).SearchWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "Goroutines", Page: 1, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, result.Conversations, 1)
+		require.Len(t, result.Conversations[0].Messages, 1)
+		return result.Conversations[0].Messages[0]
+	}
+
+	t.Run("Snippet centers on the match", func(t *testing.T) {
+		message := search(10)
+
+		assert.Equal(t, "…aaaaaaaaa goroutines are cheap…", message.Snippet)
+		assert.Empty(t, message.Content)
+	})
+
+	t.Run("Disabled returns full content", func(t *testing.T) {
+		message := search(0)
+
+		assert.Empty(t, message.Snippet)
+		assert.Equal(t, long, message.Content)
+	})
+}
+
+func TestSearch_PostProcessMaxMessages(t *testing.T) {
+	conversationID := uuid.New()
+	// 关键词只出现在第三条消息中
+	_, client := newESStub(t, esHit(conversationID, "Untitled",
+		[2]string{"user", "hello"}, [2]string{"assistant", "hi"}, [2]string{"user", "what about goroutines"}))
+
+	search := func(maxMessages int) int {
+		repo := repositories.NewElasticsearchRepositoryWithOptions(client, "conversations", repositories.SearchRepositoryOptions{
+			PostProcessConcurrency: 1,
+			PostProcessMaxMessages: maxMessages,
+		})
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "goroutines", Page: 1, Limit: 10})
+		require.NoError(t, err)
+		return len(result.Documents)
+	}
+
+	assert.Equal(t, 1, search(0))
+	assert.Equal(t, 1, search(3))
+	// 上限只限制评分，超出范围的消息仍参与精确匹配
+	assert.Equal(t, 1, search(2))
+}
+
+func TestSearch_PostProcessMaxMessagesOnlyLimitsScoring(t *testing.T) {
+	late, early := uuid.New(), uuid.New()
+	// late 的关键词集中在第三条消息，early 只在第一条消息出现一次
+	_, client := newESStub(t,
+		esHit(late, "Untitled", [2]string{"user", "hello"}, [2]string{"assistant", "hi"}, [2]string{"user", "goroutines goroutines goroutines"}),
+		esHit(early, "Untitled", [2]string{"user", "goroutines"}, [2]string{"assistant", "hi"}, [2]string{"user", "bye"}))
+
+	search := func(maxMessages int) []uuid.UUID {
+		repo := repositories.NewElasticsearchRepositoryWithOptions(client, "conversations", repositories.SearchRepositoryOptions{
+			PostProcessConcurrency: 1,
+			PostProcessMaxMessages: maxMessages,
+		})
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "goroutines", Page: 1, Limit: 10})
+		require.NoError(t, err)
+		ids := make([]uuid.UUID, len(result.Documents))
+		for i, doc := range result.Documents {
+			ids[i] = doc.ID
+		}
+		return ids
+	}
+
+	assert.Equal(t, []uuid.UUID{late, early}, search(0))
+	// 超出上限的消息不计分，但对话仍然返回
+	assert.Equal(t, []uuid.UUID{early, late}, search(2))
+}
+
+// BenchmarkSearch_ConcurrentPostFilter runs keyword searches over large conversations
+// from many goroutines and reports latency percentiles; with the shared post-processing
+// slots the tail latency stays close to the median as parallelism grows
+func BenchmarkSearch_ConcurrentPostFilter(b *testing.B) {
+	messages := make([][2]string, 200)
+	for i := range messages {
+		messages[i] = [2]string{"user", strings.Repeat("lorem ipsum ", 10) + "goroutines"}
+	}
+	hits := make([]map[string]interface{}, 50)
+	for i := range hits {
+		hits[i] = esHit(uuid.New(), "Concurrency", messages...)
+	}
+	_, client := newESStub(b, hits...)
+
+	repo := repositories.NewElasticsearchRepositoryWithOptions(client, "conversations", repositories.SearchRepositoryOptions{
+		PostProcessConcurrency: 4,
+		PostProcessMaxMessages: 100,
+	})
+
+	var mu sync.Mutex
+	var latencies []time.Duration
+
+	b.SetParallelism(8)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			start := time.Now()
+			if _, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "goroutines", Page: 1, Limit: 10}); err != nil {
+				b.Error(err)
+				return
+			}
+			mu.Lock()
+			latencies = append(latencies, time.Since(start))
+			mu.Unlock()
+		}
+	})
+	b.StopTimer()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) float64 {
+		return float64(latencies[int(p*float64(len(latencies)-1))].Microseconds()) / 1000
+	}
+	b.ReportMetric(percentile(0.5), "p50-ms")
+	b.ReportMetric(percentile(0.99), "p99-ms")
+}
+
+func TestSearch_SourceProjection(t *testing.T) {
+	first := uuid.New()
+	second := uuid.New()
+	// 第二个对话只有标题匹配
+	titleOnly := esHit(second, "More channels", [2]string{"user", "hello"})
+	titleOnly["highlight"] = map[string]interface{}{"title": []interface{}{"More <mark>channels</mark>"}}
+	hits := []map[string]interface{}{
+		esHit(first, "Channels", [2]string{"user", "buffered channels"}, [2]string{"assistant", "use make"}),
+		titleOnly,
+	}
+
+	t.Run("Messages loaded only where shown", func(t *testing.T) {
+		stub, client := newESStub(t, hits...)
+		repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "channels", SkipPostFilter: true, Page: 1, Limit: 10})
+		require.NoError(t, err)
+
+		require.Len(t, stub.requests, 1)
+		assert.Equal(t, map[string]interface{}{"excludes": []interface{}{"messages"}}, stub.requests[0]["_source"])
+
+		// 只为有消息匹配的对话读取消息，标题匹配且不返回上下文消息的对话不读取
+		assert.Equal(t, [][]string{{first.String()}}, stub.mgets)
+		require.Len(t, result.Documents, 2)
+		assert.Empty(t, result.MatchedMessages[second])
+		require.Len(t, result.MatchedMessages[first], 2)
+		assert.Equal(t, "buffered channels", result.MatchedMessages[first][0].Content)
+	})
+
+	t.Run("Post filter matches on inner hits", func(t *testing.T) {
+		stub, client := newESStub(t, hits...)
+		repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "channels", Page: 1, Limit: 10})
+		require.NoError(t, err)
+
+		// 候选窗口不读取消息，精确匹配和评分使用 inner_hits 返回的匹配消息，只为当前页读取完整消息
+		require.Len(t, stub.requests, 1)
+		assert.Equal(t, map[string]interface{}{"excludes": []interface{}{"messages"}}, stub.requests[0]["_source"])
+		assert.Equal(t, [][]string{{first.String()}}, stub.mgets)
+		require.Len(t, result.Documents, 2)
+		require.Len(t, result.MatchedMessages[first], 2)
+		assert.Equal(t, "buffered channels", result.MatchedMessages[first][0].Content)
+	})
+
+	t.Run("No keyword skips messages", func(t *testing.T) {
+		// 没有关键词时 ES 不返回高亮
+		plain := make([]map[string]interface{}, len(hits))
+		for i, hit := range hits {
+			plain[i] = map[string]interface{}{"_id": hit["_id"], "_score": hit["_score"], "_source": hit["_source"]}
+		}
+		stub, client := newESStub(t, plain...)
+		repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Page: 1, Limit: 10})
+		require.NoError(t, err)
+
+		assert.Contains(t, stub.requests[0], "_source")
+		assert.Empty(t, stub.mgets)
+		assert.Len(t, result.Documents, 2)
+		assert.Empty(t, result.MatchedMessages)
+	})
+}
+
+func TestSearch_PostFilterRefillsPage(t *testing.T) {
+	// 12 个命中中有 4 个只是模糊匹配，会被后置过滤丢弃
+	var hits []map[string]interface{}
+	var exact []uuid.UUID
+	for i := 0; i < 12; i++ {
+		id := uuid.New()
+		if i%3 == 1 {
+			hits = append(hits, esHit(id, "Fuzzy", [2]string{"user", "kubernets pods"}))
+			continue
+		}
+		hits = append(hits, esHit(id, "Cluster", [2]string{"user", "kubernetes pods"}))
+		exact = append(exact, id)
+	}
+	stub, client := newESStub(t, hits...)
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	page := func(n int) []uuid.UUID {
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "kubernetes", Page: n, Limit: 5})
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(exact)), result.Total)
+
+		ids := make([]uuid.UUID, len(result.Documents))
+		for i, doc := range result.Documents {
+			ids[i] = doc.ID
+		}
+		return ids
+	}
+
+	first, second, third := page(1), page(2), page(3)
+
+	// 第一页补满，各页不重复，合起来正好是全部精确匹配的对话
+	assert.Len(t, first, 5)
+	assert.Len(t, second, 3)
+	assert.Empty(t, third)
+	assert.Equal(t, exact, append(first, second...))
+
+	// 从 ES 读取固定的候选窗口，而不是按页读取
+	for _, req := range stub.requests {
+		assert.Equal(t, 0.0, req["from"])
+		assert.Equal(t, 500.0, req["size"])
+	}
+}
+
+func TestSearch_DeduplicatesConversations(t *testing.T) {
+	id := uuid.New()
+	other := uuid.New()
+	_, client := newESStub(t,
+		esHit(id, "Golang tips", [2]string{"user", "golang generics"}),
+		esHit(other, "Other", [2]string{"user", "golang modules"}),
+		esHit(id, "Golang tips", [2]string{"user", "golang generics"}),
+	)
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	for _, query := range []string{"golang", ""} {
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: query, Page: 1, Limit: 10})
+		require.NoError(t, err)
+
+		require.Len(t, result.Documents, 2, "query %q", query)
+		ids := []uuid.UUID{result.Documents[0].ID, result.Documents[1].ID}
+		assert.ElementsMatch(t, []uuid.UUID{id, other}, ids)
+		assert.Equal(t, int64(2), result.Total)
+	}
+}
+
+func TestSearch_ActivityOrder(t *testing.T) {
+	// ES 已按 last_message_at 排好序；第二个命中标题也匹配，相关性更高
+	recent, older := uuid.New(), uuid.New()
+	stub, client := newESStub(t,
+		esHit(recent, "Notes", [2]string{"user", "kubernetes pods"}),
+		esHit(older, "Kubernetes", [2]string{"user", "kubernetes kubernetes pods"}),
+	)
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	search := func(order repositories.SearchOrder) []uuid.UUID {
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "kubernetes", Order: order, Page: 1, Limit: 10})
+		require.NoError(t, err)
+
+		ids := make([]uuid.UUID, len(result.Documents))
+		for i, doc := range result.Documents {
+			ids[i] = doc.ID
+		}
+		return ids
+	}
+
+	t.Run("Relevance reorders hits", func(t *testing.T) {
+		assert.Equal(t, []uuid.UUID{older, recent}, search(repositories.OrderRelevance))
+
+		sortFields := stub.requests[len(stub.requests)-1]["sort"].([]interface{})
+		assert.Contains(t, sortFields[0], "_score")
+	})
+
+	t.Run("Activity keeps ES order", func(t *testing.T) {
+		assert.Equal(t, []uuid.UUID{recent, older}, search(repositories.OrderActivity))
+
+		sortFields := stub.requests[len(stub.requests)-1]["sort"].([]interface{})
+		lastMessageAt := sortFields[0].(map[string]interface{})["last_message_at"].(map[string]interface{})
+		assert.Equal(t, "desc", lastMessageAt["order"])
+		assert.Equal(t, "_last", lastMessageAt["missing"])
+	})
+}
+
+func TestSearch_CreatedOrder(t *testing.T) {
+	hit := func(title, content string, createdAt string) (uuid.UUID, map[string]interface{}) {
+		id := uuid.New()
+		h := esHit(id, title, [2]string{"user", content})
+		h["_source"].(map[string]interface{})["created_at"] = createdAt
+		return id, h
+	}
+	best, bestHit := hit("Kubernetes", "kubernetes kubernetes", "2023-01-01T00:00:00Z")
+	tieOld, tieOldHit := hit("Notes", "kubernetes pods", "2024-01-01T00:00:00Z")
+	tieNew, tieNewHit := hit("Misc", "kubernetes pods", "2024-06-01T00:00:00Z")
+	_, fuzzyHit := hit("Fuzzy", "kubernetis pods", "2024-09-01T00:00:00Z")
+
+	search := func(order repositories.SearchOrder, hits ...map[string]interface{}) ([]uuid.UUID, map[string]interface{}) {
+		stub, client := newESStub(t, hits...)
+		repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), repositories.SearchParams{Query: "kubernetes", Order: order, Page: 1, Limit: 10})
+		require.NoError(t, err)
+
+		ids := make([]uuid.UUID, len(result.Documents))
+		for i, doc := range result.Documents {
+			ids[i] = doc.ID
+		}
+		return ids, stub.requests[0]
+	}
+
+	t.Run("Relevance breaks ties by date", func(t *testing.T) {
+		// ES 返回的同分命中旧的在前，Go 端重排后新的在前
+		ids, _ := search(repositories.OrderRelevance, tieOldHit, tieNewHit, bestHit, fuzzyHit)
+		assert.Equal(t, []uuid.UUID{best, tieNew, tieOld}, ids)
+	})
+
+	t.Run("Created sorts by date without re-ranking", func(t *testing.T) {
+		// ES 已按 created_at 倒序返回，不精确匹配的命中仍被过滤
+		ids, request := search(repositories.OrderCreated, fuzzyHit, tieNewHit, tieOldHit, bestHit)
+		assert.Equal(t, []uuid.UUID{tieNew, tieOld, best}, ids)
+
+		sortFields := request["sort"].([]interface{})
+		require.Len(t, sortFields, 1)
+		assert.Equal(t, "desc", sortFields[0].(map[string]interface{})["created_at"].(map[string]interface{})["order"])
+	})
+}
+
+func TestSearch_FindSimilar(t *testing.T) {
+	userID := uuid.New()
+	source, duplicate := uuid.New(), uuid.New()
+	stub, client := newESStub(t,
+		esHit(source, "Postgres vacuum tuning",
+			[2]string{"user", "How do I tune autovacuum for a write-heavy postgres table?"},
+			[2]string{"assistant", "Lower autovacuum_vacuum_scale_factor and raise the cost limit."}),
+		esHit(duplicate, "Tuning postgres autovacuum",
+			[2]string{"user", "How should autovacuum be tuned for a write-heavy postgres table?"}),
+	)
+	stub.hits[0]["_source"].(map[string]interface{})["user_id"] = userID.String()
+	repo := repositories.NewElasticsearchRepository(client, "conversations", 0)
+
+	t.Run("Near duplicate is found and source excluded", func(t *testing.T) {
+		docs, err := repo.FindSimilar(source, nil, 5)
+
+		require.NoError(t, err)
+		require.Len(t, docs, 1)
+		assert.Equal(t, duplicate, docs[0].ID)
+
+		req := stub.requests[len(stub.requests)-1]
+		assert.Equal(t, 5.0, req["size"])
+		body, _ := json.Marshal(req["query"])
+		assert.Contains(t, string(body), `"more_like_this"`)
+		assert.Contains(t, string(body), "autovacuum")
+		// 默认限定在源对话的用户内，并排除源对话
+		assert.Contains(t, string(body), `{"term":{"user_id":"`+userID.String()+`"}}`)
+		assert.Contains(t, string(body), `{"ids":{"values":["`+source.String()+`"]}}`)
+	})
+
+	t.Run("Little text returns empty", func(t *testing.T) {
+		short := uuid.New()
+		shortStub, shortClient := newESStub(t, esHit(short, "Hi", [2]string{"user", "ok"}))
+
+		docs, err := repositories.NewElasticsearchRepository(shortClient, "conversations", 0).FindSimilar(short, &userID, 5)
+
+		require.NoError(t, err)
+		assert.Empty(t, docs)
+		// 文本太少时不发起搜索
+		assert.Empty(t, shortStub.requests)
+	})
+
+	t.Run("Source not indexed", func(t *testing.T) {
+		docs, err := repo.FindSimilar(uuid.New(), &userID, 5)
+
+		require.NoError(t, err)
+		assert.Empty(t, docs)
+	})
+
+	t.Run("Service scopes to the conversation's user", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", source).Return(&models.Conversation{Base: models.Base{ID: source}, UserID: userID}, nil)
+		convRepo.On("GetByID", mock.Anything).Return(nil, nil)
+		service := services.NewSearchService(repo, convRepo, &config.Config{}, nil)
+
+		similar, err := service.FindSimilarConversations(source, 3)
+		require.NoError(t, err)
+		require.Len(t, similar.Conversations, 1)
+		assert.Equal(t, duplicate, similar.Conversations[0].ID)
+
+		_, err = service.FindSimilarConversations(uuid.New(), 3)
+		assert.ErrorIs(t, err, errors.ErrConversationNotFound)
+	})
+}
+
+func TestSearch_RequireUserID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const adminToken = "secret"
+	userID := uuid.New()
+
+	search := func(requireUserID bool, method, query, body, token string) (*httptest.ResponseRecorder, *esStub) {
+		stub, client := newESStub(t, esHit(uuid.New(), "Golang", [2]string{"user", "golang"}))
+		cfg := &config.Config{Search: config.SearchConfig{RequireUserID: requireUserID}}
+		service := services.NewSearchService(repositories.NewElasticsearchRepository(client, "conversations", 0), new(MockConversationRepository), cfg, nil)
+		handler := handlers.NewSearchHandler(service)
+
+		router := gin.New()
+		group := router.Group("/search", middleware.AdminIdentityMiddleware(adminToken))
+		group.GET("", handler.Search)
+		group.POST("", handler.SearchPost)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/search"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set(middleware.AdminTokenHeader, token)
+		}
+		router.ServeHTTP(w, req)
+		return w, stub
+	}
+
+	t.Run("Rejects searches without user_id", func(t *testing.T) {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			w, stub := search(true, method, "?q=golang", `{"q":"golang"}`, "")
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, method)
+			assert.Contains(t, w.Body.String(), errors.ErrCodeUserIDRequired)
+			assert.Empty(t, stub.requests, "no query should reach Elasticsearch")
+		}
+	})
+
+	t.Run("Wrong admin token does not bypass", func(t *testing.T) {
+		w, _ := search(true, http.MethodGet, "?q=golang", "", "wrong")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Scoped searches are allowed", func(t *testing.T) {
+		w, stub := search(true, http.MethodGet, "?q=golang&user_id="+userID.String(), "", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		require.Len(t, stub.requests, 1)
+		assert.Contains(t, fmt.Sprint(stub.requests[0]["query"]), userID.String())
+
+		w, _ = search(true, http.MethodPost, "", `{"q":"golang","user_id":"`+userID.String()+`"}`, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Admin may search across users", func(t *testing.T) {
+		w, stub := search(true, http.MethodGet, "?q=golang", "", adminToken)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, stub.requests, 1)
+	})
+
+	t.Run("Optional when disabled", func(t *testing.T) {
+		w, _ := search(false, http.MethodGet, "?q=golang", "", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestSearchCache(t *testing.T) {
+	stub, client := newESStub(t, esHit(uuid.New(), "Golang", [2]string{"user", "golang channels"}))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := services.NewSearchCacheWithOptions(services.SearchCacheOptions{
+		Size: 2,
+		TTL:  30 * time.Second,
+		Now:  func() time.Time { return now },
+	})
+	service := services.NewSearchService(repositories.NewElasticsearchRepository(client, "conversations", 0),
+		new(MockConversationRepository), &config.Config{}, cache)
+
+	userID := uuid.New()
+	search := func(query string, page int) (*models.SearchMeta, int) {
+		result, total, meta, err := service.SearchWithMatchedMessages(context.Background(),
+			repositories.SearchParams{Query: query, UserID: &userID, Page: page, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, result.Conversations, 1)
+		assert.Equal(t, int64(1), total)
+
+		stub.mu.Lock()
+		defer stub.mu.Unlock()
+		return meta, len(stub.requests)
+	}
+
+	t.Run("Miss then hit", func(t *testing.T) {
+		meta, requests := search("golang", 1)
+		assert.Equal(t, 1, requests)
+		assert.False(t, meta.Cached)
+
+		meta, requests = search("golang", 1)
+		assert.Equal(t, 1, requests, "cached result should bypass Elasticsearch")
+		assert.True(t, meta.Cached)
+
+		stats := cache.Stats()
+		assert.Equal(t, int64(1), stats.Hits)
+		assert.Equal(t, int64(1), stats.Misses)
+	})
+
+	t.Run("Different page misses", func(t *testing.T) {
+		_, requests := search("golang", 2)
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		now = now.Add(30 * time.Second)
+		meta, requests := search("golang", 1)
+		assert.Equal(t, 3, requests)
+		assert.False(t, meta.Cached)
+	})
+
+	t.Run("Evicts least recently used", func(t *testing.T) {
+		// 缓存中为 page 1 和 page 2，page 1 刚被使用
+		search("channels", 1)
+		assert.Equal(t, int64(1), cache.Stats().Evictions)
+		assert.Equal(t, 2, cache.Stats().Entries)
+
+		_, before := search("golang", 1)
+		_, after := search("golang", 2)
+		assert.Equal(t, before+1, after, "page 2 should have been evicted")
+	})
+}
+
+func TestAdminHandler_GetSearchCacheStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	get := func(cache *services.SearchCache) response.SearchCacheStatsResponse {
+		router := gin.New()
+		router.GET("/admin/search/cache", handlers.NewAdminHandler(nil, nil, nil, cache).GetSearchCacheStats)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/search/cache", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Data response.SearchCacheStatsResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		assert.False(t, get(nil).Enabled)
+		assert.Nil(t, services.NewSearchCacheFromConfig(&config.Config{}))
+	})
+
+	t.Run("Counters", func(t *testing.T) {
+		cache := services.NewSearchCacheFromConfig(&config.Config{Search: config.SearchConfig{CacheTTL: time.Minute}})
+		require.NotNil(t, cache)
+		cache.Set("a", &response.SearchResponse{}, 0, nil)
+		cache.Get("a")
+		cache.Get("b")
+		cache.Get("a")
+
+		stats := get(cache)
+		assert.True(t, stats.Enabled)
+		assert.Equal(t, services.DefaultSearchCacheSize, stats.Size)
+		assert.Equal(t, 60.0, stats.TTLSeconds)
+		assert.Equal(t, int64(2), stats.Hits)
+		assert.Equal(t, int64(1), stats.Misses)
+		assert.InDelta(t, 2.0/3.0, stats.HitRate, 1e-9)
+	})
+}
+
+// fallbackSearchRepository 模拟 Postgres 降级搜索，返回固定的对话并记录调用次数
+type fallbackSearchRepository struct {
+	repositories.SearchRepository
+	doc      *models.ConversationDocument
+	searches int
+}
+
+func (r *fallbackSearchRepository) SearchConversationsWithMatchedMessages(ctx context.Context, params repositories.SearchParams) (*repositories.SearchResult, error) {
+	r.searches++
+	return &repositories.SearchResult{
+		Documents: []*models.ConversationDocument{r.doc},
+		Total:     1,
+		Meta:      &models.SearchMeta{Fallback: true},
+	}, nil
+}
+
+func TestSearch_FallbackWhileElasticsearchUnavailable(t *testing.T) {
+	// ES 在启动时不可用，服务以降级模式启动
+	server, healthy := newFlakyES(t)
+	esClient, err := elasticsearch.ConnectWithRetry(&elasticsearch.Config{Hosts: []string{server.URL}}, 50*time.Millisecond, 20*time.Millisecond)
+	require.NoError(t, err)
+	require.False(t, esClient.Available())
+
+	stub, client := newESStub(t, esHit(uuid.New(), "Golang from ES", [2]string{"user", "golang channels"}))
+	fallback := &fallbackSearchRepository{doc: &models.ConversationDocument{ID: uuid.New(), Title: "Golang from Postgres"}}
+	searchRepo := repositories.NewFallbackSearchRepository(repositories.NewElasticsearchRepository(client, "conversations", 0), fallback, esClient.Available)
+	cache := services.NewSearchCacheWithOptions(services.SearchCacheOptions{Size: 10, TTL: time.Minute})
+	service := services.NewSearchService(searchRepo, new(MockConversationRepository), &config.Config{}, cache)
+
+	userID := uuid.New()
+	search := func() (*response.SearchResponse, *models.SearchMeta) {
+		result, _, meta, err := service.SearchWithMatchedMessages(context.Background(),
+			repositories.SearchParams{Query: "golang", UserID: &userID, Page: 1, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, result.Conversations, 1)
+		return result, meta
+	}
+
+	result, meta := search()
+	assert.Equal(t, "Golang from Postgres", result.Conversations[0].Title)
+	assert.True(t, meta.Fallback)
+	assert.Equal(t, int64(1), searchRepo.Stats().FallbackSearches)
+
+	// 降级结果不缓存，再次搜索仍然查询数据库
+	search()
+	assert.Equal(t, 2, fallback.searches)
+	stub.mu.Lock()
+	assert.Empty(t, stub.requests)
+	stub.mu.Unlock()
+
+	// ES 恢复后切回 ES 搜索
+	healthy.Store(true)
+	require.Eventually(t, esClient.Available, 2*time.Second, 10*time.Millisecond)
+	result, meta = search()
+	assert.Equal(t, "Golang from ES", result.Conversations[0].Title)
+	assert.False(t, meta.Fallback)
+	assert.Equal(t, 2, fallback.searches)
+
+	t.Run("Fallback disabled", func(t *testing.T) {
+		cfg := &config.Config{}
+		cfg.Elasticsearch.Index.Conversations = "conversations"
+		repo := elasticsearch.NewSearchRepositoryFromClient(esClient, cfg, nil)
+		_, isFallback := repo.(*repositories.FallbackSearchRepository)
+		assert.False(t, isFallback)
+
+		cfg.Search.Fallback = true
+		_, isFallback = elasticsearch.NewSearchRepositoryFromClient(esClient, cfg, nil).(*repositories.FallbackSearchRepository)
+		assert.True(t, isFallback)
+	})
+}
+
+func TestPostgresSearchRepository(t *testing.T) {
+	db := openTestDB(t)
+	user, conversation := createTestConversation(t, db)
+	require.NoError(t, db.Model(conversation).Update("title", "Learning Go_lang 100%").Error)
+	matching := &models.Message{ConversationID: conversation.ID, Role: "assistant", Content: "Use GOLANG channels", SourceID: uuid.NewString(), Sequence: 1}
+	other := &models.Message{ConversationID: conversation.ID, Role: "user", Content: "unrelated", SourceID: uuid.NewString(), Sequence: 2}
+	require.NoError(t, db.Create(matching).Error)
+	require.NoError(t, db.Create(other).Error)
+
+	repo := repositories.NewPostgresSearchRepository(db)
+	search := func(params repositories.SearchParams) *repositories.SearchResult {
+		params.UserID = &user.ID
+		params.Page, params.Limit = 1, 10
+		result, err := repo.SearchConversationsWithMatchedMessages(context.Background(), params)
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("Message match", func(t *testing.T) {
+		result := search(repositories.SearchParams{Query: "golang"})
+		require.Len(t, result.Documents, 1)
+		assert.True(t, result.Meta.Fallback)
+		require.Len(t, result.MatchedMessages[conversation.ID], 1)
+		assert.Equal(t, matching.ID, result.MatchedMessages[conversation.ID][0].ID)
+		assert.Contains(t, result.MatchedFields[conversation.ID], "messages.content")
+	})
+
+	t.Run("Wildcards match literally", func(t *testing.T) {
+		assert.Len(t, search(repositories.SearchParams{Query: "go_lang 100%"}).Documents, 1)
+		assert.Empty(t, search(repositories.SearchParams{Query: "go%lang"}).Documents)
+	})
+
+	t.Run("Role and field filters", func(t *testing.T) {
+		role := "user"
+		assert.Empty(t, search(repositories.SearchParams{Query: "channels", Role: &role}).Documents)
+		assert.Empty(t, search(repositories.SearchParams{Query: "channels", Fields: []string{repositories.SearchFieldTitle}}).Documents)
+	})
+
+	t.Run("Unsupported filters", func(t *testing.T) {
+		_, err := repo.SearchConversationsWithMatchedMessages(context.Background(),
+			repositories.SearchParams{Query: "golang", Metadata: map[string]string{"model": "gpt-4"}, Page: 1, Limit: 10})
+		assert.ErrorIs(t, err, repositories.ErrSearchFallbackUnsupported)
+	})
+}
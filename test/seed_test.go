@@ -0,0 +1,67 @@
+package test
+
+import (
+	"testing"
+
+	"chat-assistant-backend/internal/seed"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedGenerate(t *testing.T) {
+	opts := seed.Options{Users: 2, ConversationsPerUser: 3, MessagesPerConversation: 4, Seed: 42}
+
+	t.Run("Counts and structure", func(t *testing.T) {
+		dataset := seed.Generate(opts)
+
+		require.Len(t, dataset.Users, 2)
+		require.Len(t, dataset.Conversations, 6)
+		for _, conversation := range dataset.Conversations {
+			assert.NotEmpty(t, conversation.Provider)
+			assert.NotEmpty(t, conversation.Model)
+			require.Len(t, conversation.Messages, 4)
+			assert.Equal(t, "user", conversation.Messages[0].Role)
+			assert.Equal(t, "assistant", conversation.Messages[1].Role)
+			// 消息按时间递增，last_message_at 与最后一条一致
+			for i := 1; i < len(conversation.Messages); i++ {
+				assert.True(t, conversation.Messages[i].CreatedAt.After(conversation.Messages[i-1].CreatedAt))
+			}
+			require.NotNil(t, conversation.LastMessageAt)
+			assert.True(t, conversation.LastMessageAt.Equal(conversation.Messages[3].CreatedAt))
+			for _, tag := range conversation.Tags {
+				assert.Contains(t, dataset.TagNames, tag.Name)
+			}
+		}
+	})
+
+	t.Run("Same seed is reproducible", func(t *testing.T) {
+		assert.Equal(t, seed.Generate(opts), seed.Generate(opts))
+	})
+
+	t.Run("Different seed differs", func(t *testing.T) {
+		other := opts
+		other.Seed = 43
+		a, b := seed.Generate(opts), seed.Generate(other)
+
+		assert.NotEqual(t, a.Users[0].ID, b.Users[0].ID)
+		assert.NotEqual(t, a.Users[0].Username, b.Users[0].Username)
+	})
+
+	t.Run("No messages", func(t *testing.T) {
+		dataset := seed.Generate(seed.Options{Users: 1, ConversationsPerUser: 1, Seed: 1})
+
+		require.Len(t, dataset.Conversations, 1)
+		assert.Empty(t, dataset.Conversations[0].Messages)
+		assert.Nil(t, dataset.Conversations[0].LastMessageAt)
+	})
+}
+
+func TestSeedIsLocalHost(t *testing.T) {
+	for _, host := range []string{"localhost", "LOCALHOST", "127.0.0.1", "127.0.1.1", "::1", "[::1]", "postgres", "/var/run/postgresql"} {
+		assert.True(t, seed.IsLocalHost(host), host)
+	}
+	for _, host := range []string{"db.example.com", "10.0.0.5", "prod-postgres", ""} {
+		assert.False(t, seed.IsLocalHost(host), host)
+	}
+}
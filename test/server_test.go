@@ -0,0 +1,113 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/server"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resolveClientIP returns the client IP gin resolves for a request from remoteAddr with the given headers
+func resolveClientIP(t *testing.T, trustedProxies []string, remoteAddr string, headers map[string]string) string {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	require.NoError(t, server.ConfigureTrustedProxies(router, trustedProxies))
+	router.GET("/ip", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	req.RemoteAddr = remoteAddr
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	router.ServeHTTP(w, req)
+	return w.Body.String()
+}
+
+func TestConfigureTrustedProxies(t *testing.T) {
+	t.Run("Trusts nothing by default", func(t *testing.T) {
+		ip := resolveClientIP(t, nil, "10.0.0.2:4321", map[string]string{"X-Forwarded-For": "203.0.113.7"})
+		assert.Equal(t, "10.0.0.2", ip)
+	})
+
+	t.Run("Uses X-Forwarded-For from a trusted proxy", func(t *testing.T) {
+		ip := resolveClientIP(t, []string{"10.0.0.0/8"}, "10.0.0.2:4321", map[string]string{"X-Forwarded-For": "203.0.113.7, 10.0.0.5"})
+		assert.Equal(t, "203.0.113.7", ip)
+	})
+
+	t.Run("Falls back to X-Real-IP", func(t *testing.T) {
+		ip := resolveClientIP(t, []string{"10.0.0.2"}, "10.0.0.2:4321", map[string]string{"X-Real-IP": "198.51.100.9"})
+		assert.Equal(t, "198.51.100.9", ip)
+	})
+
+	t.Run("Ignores forwarded header from an untrusted peer", func(t *testing.T) {
+		ip := resolveClientIP(t, []string{"10.0.0.0/8"}, "192.0.2.1:4321", map[string]string{"X-Forwarded-For": "203.0.113.7"})
+		assert.Equal(t, "192.0.2.1", ip)
+	})
+
+	t.Run("Rejects invalid proxy", func(t *testing.T) {
+		assert.Error(t, server.ConfigureTrustedProxies(gin.New(), []string{"not-an-ip"}))
+	})
+}
+
+// recordingComponent records its lifecycle calls into a shared log
+type recordingComponent struct {
+	name string
+	mu   *sync.Mutex
+	log  *[]string
+}
+
+func (c *recordingComponent) Start(ctx context.Context) error {
+	c.record("start " + c.name)
+	return nil
+}
+
+func (c *recordingComponent) Stop(ctx context.Context) error {
+	c.record("stop " + c.name)
+	return nil
+}
+
+func (c *recordingComponent) record(event string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.log = append(*c.log, event)
+}
+
+func TestServer_ComponentLifecycle(t *testing.T) {
+	cfg := &config.Config{
+		Server:   config.ServerConfig{Host: "127.0.0.1", Port: 0},
+		CORS:     config.CORSConfig{AllowedOrigins: []string{"*"}},
+		Shutdown: config.ShutdownConfig{Timeout: time.Second},
+	}
+	srv := server.New(cfg, nil, nil, nil, nil, nil, nil, nil)
+
+	var mu sync.Mutex
+	var events []string
+	srv.Register(&recordingComponent{name: "outbox", mu: &mu, log: &events})
+	srv.Register(&recordingComponent{name: "hub", mu: &mu, log: &events})
+
+	snapshot := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), events...)
+	}
+
+	go srv.Start()
+	assert.Eventually(t, func() bool { return len(snapshot()) == 2 }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, srv.Stop(context.Background()))
+
+	// 按注册顺序启动，按相反顺序停止
+	assert.Equal(t, []string{"start outbox", "start hub", "stop hub", "stop outbox"}, snapshot())
+}
@@ -0,0 +1,350 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/repositories"
+	"chat-assistant-backend/internal/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newSyncConversation(title string, contents ...string) *models.Conversation {
+	conversation := &models.Conversation{
+		Base:     models.Base{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		UserID:   uuid.New(),
+		Title:    title,
+		Provider: "chatgpt",
+	}
+	for _, content := range contents {
+		conversation.Messages = append(conversation.Messages, models.Message{
+			Base:           models.Base{ID: uuid.New()},
+			ConversationID: conversation.ID,
+			Role:           "user",
+			Content:        content,
+		})
+	}
+	return conversation
+}
+
+func TestConversationDocument_ContentHash(t *testing.T) {
+	conversation := newSyncConversation("Hash", "hello", "world")
+	conversation.Tags = []models.Tag{
+		{Base: models.Base{ID: uuid.New()}, Name: "a"},
+		{Base: models.Base{ID: uuid.New()}, Name: "b"},
+	}
+	hash := conversation.ToESDocument().ComputeContentHash()
+
+	t.Run("Ignores tag order, time zone and sub-second precision", func(t *testing.T) {
+		doc := conversation.ToESDocument()
+		doc.Tags[0], doc.Tags[1] = doc.Tags[1], doc.Tags[0]
+		doc.CreatedAt = doc.CreatedAt.In(time.FixedZone("UTC+8", 8*3600))
+		doc.UpdatedAt = doc.UpdatedAt.Truncate(time.Second)
+
+		assert.Equal(t, hash, doc.ComputeContentHash())
+	})
+
+	t.Run("Changes with timestamps", func(t *testing.T) {
+		doc := conversation.ToESDocument()
+		doc.UpdatedAt = doc.UpdatedAt.Add(time.Hour)
+		assert.NotEqual(t, hash, doc.ComputeContentHash())
+
+		doc = conversation.ToESDocument()
+		doc.Messages[1].CreatedAt = doc.Messages[1].CreatedAt.Add(time.Second)
+		assert.NotEqual(t, hash, doc.ComputeContentHash())
+	})
+
+	t.Run("Changes with content", func(t *testing.T) {
+		doc := conversation.ToESDocument()
+		doc.Messages[1].Content = "world!"
+		assert.NotEqual(t, hash, doc.ComputeContentHash())
+
+		doc = conversation.ToESDocument()
+		doc.Title = "Renamed"
+		assert.NotEqual(t, hash, doc.ComputeContentHash())
+
+		// 字段边界不同但拼接结果相同
+		doc = conversation.ToESDocument()
+		doc.Messages[0].Content, doc.Messages[1].Content = "hellow", "orld"
+		assert.NotEqual(t, hash, doc.ComputeContentHash())
+	})
+}
+
+func TestSyncService_Plan(t *testing.T) {
+	unchanged := newSyncConversation("Unchanged", "same")
+	changed := newSyncConversation("Changed", "before")
+	legacy := newSyncConversation("Legacy", "no hash")
+	added := newSyncConversation("New", "fresh")
+	stale := uuid.New()
+
+	changedHash := changed.ToESDocument().ComputeContentHash()
+	changed.Messages[0].Content = "after"
+
+	convRepo := new(MockConversationRepository)
+	convRepo.On("FindAllStream", mock.Anything).Return([]*models.Conversation{unchanged, changed, legacy, added}, nil)
+
+	indexer := new(MockIndexer)
+	indexer.On("ContentHashes").Return(map[uuid.UUID]string{
+		unchanged.ID: unchanged.ToESDocument().ComputeContentHash(),
+		changed.ID:   changedHash,
+		legacy.ID:    "", // 记录哈希之前索引的文档
+		stale:        "deleted-from-db",
+	}, nil)
+
+	plan, err := services.NewSyncService(convRepo, indexer).Plan()
+
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{added.ID}, plan.ToIndex)
+	assert.ElementsMatch(t, []uuid.UUID{changed.ID, legacy.ID}, plan.ToUpdate)
+	assert.Equal(t, []uuid.UUID{stale}, plan.Stale)
+	assert.Equal(t, 1, plan.Unchanged)
+
+	// 试运行不写入索引
+	indexer.AssertNotCalled(t, "BulkIndexConversations")
+	indexer.AssertExpectations(t)
+	convRepo.AssertExpectations(t)
+
+	t.Run("Index error", func(t *testing.T) {
+		indexer := new(MockIndexer)
+		indexer.On("ContentHashes").Return(nil, assert.AnError)
+
+		_, err := services.NewSyncService(convRepo, indexer).Plan()
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+// memoryIndexer records the content hash of every bulk indexed document, like the
+// ES indexer does, and counts the documents written per call
+type memoryIndexer struct {
+	repositories.ElasticsearchIndexer
+	hashes  map[uuid.UUID]string
+	owners  map[uuid.UUID]uuid.UUID // 文档 ID -> user_id，为 nil 时不记录
+	batches [][]uuid.UUID
+	failing bool // ContentHashes 返回错误
+}
+
+func (m *memoryIndexer) BulkIndexConversations(docs []*models.ConversationDocument) error {
+	var ids []uuid.UUID
+	for _, doc := range docs {
+		m.hashes[doc.ID] = doc.ComputeContentHash()
+		if m.owners != nil {
+			m.owners[doc.ID] = doc.UserID
+		}
+		ids = append(ids, doc.ID)
+	}
+	m.batches = append(m.batches, ids)
+	return nil
+}
+
+func (m *memoryIndexer) ContentHashes() (map[uuid.UUID]string, error) {
+	if m.failing {
+		return nil, assert.AnError
+	}
+	hashes := make(map[uuid.UUID]string, len(m.hashes))
+	for id, hash := range m.hashes {
+		hashes[id] = hash
+	}
+	return hashes, nil
+}
+
+func (m *memoryIndexer) DeleteUserConversationsExcept(userID uuid.UUID, keep []uuid.UUID) (int64, error) {
+	kept := make(map[uuid.UUID]bool, len(keep))
+	for _, id := range keep {
+		kept[id] = true
+	}
+	var deleted int64
+	for id, owner := range m.owners {
+		if owner == userID && !kept[id] {
+			delete(m.hashes, id)
+			delete(m.owners, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func TestSyncService_SkipsUnchanged(t *testing.T) {
+	unchanged := newSyncConversation("Unchanged", "same")
+	edited := newSyncConversation("Edited", "before")
+
+	convRepo := new(MockConversationRepository)
+	convRepo.On("FindAllStream", mock.Anything).Return([]*models.Conversation{unchanged, edited}, nil)
+
+	indexer := &memoryIndexer{hashes: map[uuid.UUID]string{}}
+	service := services.NewSyncService(convRepo, indexer)
+
+	require.NoError(t, service.SyncAll())
+	require.Len(t, indexer.batches, 1)
+	assert.ElementsMatch(t, []uuid.UUID{unchanged.ID, edited.ID}, indexer.batches[0])
+
+	// 第二次同步只重新索引内容变化的对话
+	edited.Messages[0].Content = "after"
+	require.NoError(t, service.SyncAll())
+	require.Len(t, indexer.batches, 2)
+	assert.Equal(t, []uuid.UUID{edited.ID}, indexer.batches[1])
+	assert.Equal(t, edited.ContentHash(), indexer.hashes[edited.ID])
+
+	// 没有变化时不写入任何文档
+	require.NoError(t, service.SyncAll())
+	assert.Len(t, indexer.batches, 2)
+
+	t.Run("Force reindexes everything", func(t *testing.T) {
+		force := services.NewSyncServiceWithOptions(convRepo, indexer, services.SyncOptions{Force: true})
+		require.NoError(t, force.SyncAll())
+		assert.Len(t, indexer.batches[len(indexer.batches)-1], 2)
+	})
+
+	t.Run("Falls back to full sync when hashes are unavailable", func(t *testing.T) {
+		indexer := &memoryIndexer{hashes: map[uuid.UUID]string{}, failing: true}
+		require.NoError(t, services.NewSyncService(convRepo, indexer).SyncAll())
+		require.Len(t, indexer.batches, 1)
+		assert.Len(t, indexer.batches[0], 2)
+	})
+}
+
+func TestSyncService_SyncAllInBatches(t *testing.T) {
+	var conversations []*models.Conversation
+	for i := 0; i < 5; i++ {
+		conversations = append(conversations, newSyncConversation("Batch", "content"))
+	}
+
+	convRepo := new(MockConversationRepository)
+	convRepo.On("FindAllStream", 2).Return(conversations, nil)
+
+	indexer := &memoryIndexer{hashes: map[uuid.UUID]string{}}
+	service := services.NewSyncServiceWithOptions(convRepo, indexer, services.SyncOptions{BatchSize: 2})
+	require.NoError(t, service.SyncAll())
+
+	// 每批单独写入，每个对话只写入一次
+	require.Len(t, indexer.batches, 3)
+	assert.Len(t, indexer.batches[0], 2)
+	assert.Len(t, indexer.batches[2], 1)
+	assert.Len(t, indexer.hashes, 5)
+	convRepo.AssertExpectations(t)
+
+	t.Run("Stream error", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("FindAllStream", mock.Anything).Return(nil, assert.AnError)
+
+		err := services.NewSyncService(convRepo, &memoryIndexer{hashes: map[uuid.UUID]string{}}).SyncAll()
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+func TestSyncService_SyncUser(t *testing.T) {
+	target := uuid.New()
+	kept := newSyncConversation("Kept", "content")
+	kept.UserID = target
+	stale := newSyncConversation("Deleted in postgres", "content")
+	stale.UserID = target
+	other := newSyncConversation("Other user", "content")
+
+	indexer := &memoryIndexer{hashes: map[uuid.UUID]string{}, owners: map[uuid.UUID]uuid.UUID{}}
+	for _, conversation := range []*models.Conversation{kept, stale, other} {
+		require.NoError(t, indexer.BulkIndexConversations([]*models.ConversationDocument{conversation.ToESDocument()}))
+	}
+	indexer.batches = nil
+
+	added := newSyncConversation("Added", "content")
+	added.UserID = target
+	convRepo := new(MockConversationRepository)
+	convRepo.On("FindByUserIDStream", target, 1).Return([]*models.Conversation{kept, added}, nil)
+
+	service := services.NewSyncServiceWithOptions(convRepo, indexer, services.SyncOptions{BatchSize: 1})
+	require.NoError(t, service.SyncUser(target))
+
+	// 目标用户的对话全部重新索引（不跳过未变化的），已删除的对话从索引移除
+	require.Len(t, indexer.batches, 2)
+	assert.ElementsMatch(t, []uuid.UUID{kept.ID, added.ID}, append(indexer.batches[0], indexer.batches[1]...))
+	assert.NotContains(t, indexer.hashes, stale.ID)
+	// 其他用户的文档不受影响
+	assert.Contains(t, indexer.hashes, other.ID)
+	assert.Equal(t, other.UserID, indexer.owners[other.ID])
+	convRepo.AssertExpectations(t)
+	convRepo.AssertNotCalled(t, "FindAllStream", mock.Anything)
+
+	t.Run("Stream error", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("FindByUserIDStream", target, mock.Anything).Return(nil, assert.AnError)
+
+		indexer := new(MockIndexer)
+		err := services.NewSyncService(convRepo, indexer).SyncUser(target)
+		assert.ErrorIs(t, err, assert.AnError)
+		// 读取失败时不能删除文档
+		indexer.AssertNotCalled(t, "DeleteUserConversationsExcept", mock.Anything, mock.Anything)
+	})
+}
+
+func TestConversationRepository_FindAllStream(t *testing.T) {
+	db := openTestDB(t)
+	repo := repositories.NewConversationRepository(db)
+
+	created := make(map[uuid.UUID]bool)
+	for i := 0; i < 5; i++ {
+		_, conversation := createTestConversation(t, db)
+		created[conversation.ID] = true
+	}
+
+	visits := make(map[uuid.UUID]int)
+	var batches int
+	err := repo.FindAllStream(2, func(conversations []*models.Conversation) error {
+		batches++
+		assert.LessOrEqual(t, len(conversations), 2)
+		for _, conversation := range conversations {
+			visits[conversation.ID]++
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	// 测试库中可能已有其他对话，只检查本次创建的对话各被访问一次
+	for id := range created {
+		assert.Equal(t, 1, visits[id], "conversation %s", id)
+	}
+	for id, count := range visits {
+		assert.Equal(t, 1, count, "conversation %s", id)
+	}
+	assert.GreaterOrEqual(t, batches, 3)
+
+	t.Run("Callback error stops the stream", func(t *testing.T) {
+		calls := 0
+		err := repo.FindAllStream(2, func([]*models.Conversation) error {
+			calls++
+			return assert.AnError
+		})
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Rejects non-positive batch size", func(t *testing.T) {
+		assert.Error(t, repo.FindAllStream(0, func([]*models.Conversation) error { return nil }))
+	})
+}
+
+func TestConversationRepository_FindByUserIDStream(t *testing.T) {
+	db := openTestDB(t)
+	repo := repositories.NewConversationRepository(db)
+
+	user, first := createTestConversation(t, db)
+	second := &models.Conversation{UserID: user.ID, Title: "Second", Provider: "openai", SourceID: uuid.NewString()}
+	require.NoError(t, db.Create(second).Error)
+	t.Cleanup(func() { db.Unscoped().Delete(second) })
+	_, other := createTestConversation(t, db)
+
+	var visited []uuid.UUID
+	err := repo.FindByUserIDStream(user.ID, 1, func(conversations []*models.Conversation) error {
+		for _, conversation := range conversations {
+			assert.Equal(t, user.ID, conversation.UserID)
+			visited = append(visited, conversation.ID)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{first.ID, second.ID}, visited)
+	assert.NotContains(t, visited, other.ID)
+}
@@ -0,0 +1,451 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chat-assistant-backend/internal/config"
+	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/handlers"
+	"chat-assistant-backend/internal/models"
+	"chat-assistant-backend/internal/repositories"
+	"chat-assistant-backend/internal/request"
+	"chat-assistant-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockTagRepository is a mock implementation of repositories.TagRepository
+type MockTagRepository struct {
+	repositories.TagRepository
+	mock.Mock
+}
+
+func (m *MockTagRepository) GetByID(id uuid.UUID) (*models.Tag, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Tag), args.Error(1)
+}
+
+func (m *MockTagRepository) GetByName(name string) (*models.Tag, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Tag), args.Error(1)
+}
+
+func (m *MockTagRepository) GetByNames(names []string) ([]*models.Tag, error) {
+	args := m.Called(names)
+	return args.Get(0).([]*models.Tag), args.Error(1)
+}
+
+func (m *MockTagRepository) Create(tag *models.Tag) error {
+	return m.Called(tag).Error(0)
+}
+
+func (m *MockTagRepository) Update(tag *models.Tag) error {
+	return m.Called(tag).Error(0)
+}
+
+func (m *MockTagRepository) Delete(id uuid.UUID) error {
+	return m.Called(id).Error(0)
+}
+
+func (m *MockTagRepository) FindAll() ([]*models.Tag, error) {
+	args := m.Called()
+	return args.Get(0).([]*models.Tag), args.Error(1)
+}
+
+func (m *MockTagRepository) CreateOrGetTags(names []string) ([]*models.Tag, error) {
+	args := m.Called(names)
+	return args.Get(0).([]*models.Tag), args.Error(1)
+}
+
+func (m *MockTagRepository) MergeTags(targetID uuid.UUID, name string, duplicateIDs []uuid.UUID) error {
+	return m.Called(targetID, name, duplicateIDs).Error(0)
+}
+
+func newTagConfig(caseInsensitive bool) *config.Config {
+	return &config.Config{Tags: config.TagsConfig{CaseInsensitive: caseInsensitive}}
+}
+
+func TestNormalizeTagName(t *testing.T) {
+	cases := []struct {
+		name            string
+		input           string
+		caseInsensitive bool
+		expected        string
+	}{
+		{"trim surrounding whitespace", "  Golang \t", false, "Golang"},
+		{"collapse inner whitespace", "Machine \n  Learning", false, "Machine Learning"},
+		{"preserve case by default", "GoLang", false, "GoLang"},
+		{"fold case when enabled", "GoLang", true, "golang"},
+		{"full case folding", "STRASSE", true, "strasse"},
+		{"sharp s folds like its uppercase form", "Straße", true, "strasse"},
+		{"final sigma folds like sigma", "ΣΑΣ", true, "σασ"},
+		{"final sigma in lowercase input", "σας", true, "σασ"},
+		{"NFC composes decomposed characters", "Cafe\u0301", false, "Caf\u00e9"},
+		{"full-width space is whitespace", "　标签　", false, "标签"},
+		{"whitespace only becomes empty", " \t\n ", false, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, services.NormalizeTagName(tc.input, tc.caseInsensitive))
+		})
+	}
+}
+
+func TestNormalizeTagNames(t *testing.T) {
+	names := []string{" Golang", "golang", "Golang ", "", "   ", "Rust"}
+
+	assert.Equal(t, []string{"Golang", "golang", "Rust"}, services.NormalizeTagNames(names, false))
+	assert.Equal(t, []string{"golang", "rust"}, services.NormalizeTagNames(names, true))
+}
+
+func TestTagService_CreateTag(t *testing.T) {
+	t.Run("Returns existing tag for normalized name", func(t *testing.T) {
+		mockRepo := new(MockTagRepository)
+		tagService := services.NewTagService(mockRepo, nil, nil, newTagConfig(true))
+
+		existing := &models.Tag{Base: models.Base{ID: uuid.New()}, Name: "golang"}
+		mockRepo.On("GetByName", "golang").Return(existing, nil)
+
+		tag, err := tagService.CreateTag("  GoLang ")
+
+		assert.NoError(t, err)
+		assert.Equal(t, existing, tag)
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+	})
+
+	t.Run("Rejects whitespace-only name", func(t *testing.T) {
+		mockRepo := new(MockTagRepository)
+		tagService := services.NewTagService(mockRepo, nil, nil, newTagConfig(false))
+
+		tag, err := tagService.CreateTag("   ")
+
+		assert.Equal(t, errors.ErrTagNameEmpty, err)
+		assert.Nil(t, tag)
+	})
+}
+
+func TestTagService_UpdateTag(t *testing.T) {
+	mockRepo := new(MockTagRepository)
+	tagService := services.NewTagService(mockRepo, nil, nil, newTagConfig(true))
+
+	tagID := uuid.New()
+	otherTag := &models.Tag{Base: models.Base{ID: uuid.New()}, Name: "golang"}
+	mockRepo.On("GetByID", tagID).Return(&models.Tag{Base: models.Base{ID: tagID}, Name: "go"}, nil)
+	mockRepo.On("GetByName", "golang").Return(otherTag, nil)
+
+	tag, err := tagService.UpdateTag(context.Background(), tagID, "Golang ")
+
+	assert.Equal(t, errors.ErrTagNameExists, err)
+	assert.Nil(t, tag)
+}
+
+func TestTagService_UpdateTagPropagatesToElasticsearch(t *testing.T) {
+	mockRepo := new(MockTagRepository)
+	indexer := new(MockIndexer)
+	tagService := services.NewTagService(mockRepo, indexer, nil, newTagConfig(true))
+
+	tagID := uuid.New()
+	mockRepo.On("GetByID", tagID).Return(&models.Tag{Base: models.Base{ID: tagID}, Name: "go"}, nil)
+	mockRepo.On("GetByName", "golang").Return(nil, nil)
+	mockRepo.On("Update", mock.Anything).Return(nil)
+
+	propagated := make(chan struct{})
+	indexer.On("UpdateTagInConversations", tagID, "golang").Return(nil).Run(func(mock.Arguments) {
+		close(propagated)
+	})
+
+	tag, err := tagService.UpdateTag(context.Background(), tagID, "Golang")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "golang", tag.Name)
+
+	select {
+	case <-propagated:
+	case <-time.After(time.Second):
+		t.Fatal("tag rename was not propagated to Elasticsearch")
+	}
+	indexer.AssertExpectations(t)
+}
+
+func TestElasticsearchIndexer_UpdateTagInConversations(t *testing.T) {
+	stub, client := newESStub(t)
+	indexer := repositories.NewElasticsearchIndexer(client, "conversations")
+
+	tagID := uuid.New()
+	require.NoError(t, indexer.UpdateTagInConversations(tagID, "golang"))
+
+	require.Len(t, stub.requests, 1)
+	body := stub.requests[0]
+
+	nested := body["query"].(map[string]interface{})["nested"].(map[string]interface{})
+	assert.Equal(t, "tags", nested["path"])
+	assert.Equal(t, tagID.String(), nested["query"].(map[string]interface{})["term"].(map[string]interface{})["tags.id"])
+
+	params := body["script"].(map[string]interface{})["params"].(map[string]interface{})
+	assert.Equal(t, tagID.String(), params["tagId"])
+	assert.Equal(t, "golang", params["name"])
+}
+
+func TestTagService_DeleteTagPropagatesToElasticsearch(t *testing.T) {
+	mockRepo := new(MockTagRepository)
+	indexer := new(MockIndexer)
+	tagService := services.NewTagService(mockRepo, indexer, nil, newTagConfig(true))
+
+	tagID := uuid.New()
+	mockRepo.On("GetByID", tagID).Return(&models.Tag{Base: models.Base{ID: tagID}, Name: "go"}, nil)
+	mockRepo.On("Delete", tagID).Return(nil)
+
+	propagated := make(chan struct{})
+	indexer.On("RemoveTagFromConversations", tagID).Return(nil).Run(func(mock.Arguments) {
+		close(propagated)
+	})
+
+	assert.NoError(t, tagService.DeleteTag(context.Background(), tagID))
+
+	select {
+	case <-propagated:
+	case <-time.After(time.Second):
+		t.Fatal("tag deletion was not propagated to Elasticsearch")
+	}
+	indexer.AssertExpectations(t)
+}
+
+func TestElasticsearchIndexer_RemoveTagFromConversations(t *testing.T) {
+	stub, client := newESStub(t)
+	indexer := repositories.NewElasticsearchIndexer(client, "conversations")
+
+	tagID := uuid.New()
+	require.NoError(t, indexer.RemoveTagFromConversations(tagID))
+
+	require.Len(t, stub.requests, 1)
+	body := stub.requests[0]
+
+	nested := body["query"].(map[string]interface{})["nested"].(map[string]interface{})
+	assert.Equal(t, tagID.String(), nested["query"].(map[string]interface{})["term"].(map[string]interface{})["tags.id"])
+
+	script := body["script"].(map[string]interface{})
+	assert.Contains(t, script["source"], "removeIf")
+	assert.Equal(t, tagID.String(), script["params"].(map[string]interface{})["tagId"])
+}
+
+func TestConversationService_UpdateConversationTagsReindexesTags(t *testing.T) {
+	message := models.Message{Base: models.Base{ID: uuid.New(), CreatedAt: time.Now()}, Role: "user", Content: "hello"}
+	oldTag := models.Tag{Base: models.Base{ID: uuid.New()}, Name: "old"}
+	conversation := models.Conversation{
+		Base:     models.Base{ID: uuid.New()},
+		Title:    "Tagged",
+		Tags:     []models.Tag{oldTag},
+		Messages: []models.Message{message},
+	}
+
+	store, client := newDocumentES(t)
+	indexer := repositories.NewElasticsearchIndexer(client, "conversations")
+	require.NoError(t, indexer.IndexConversation(conversation.ToESDocument()))
+
+	tagNames := func() []string {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		var names []string
+		tags, _ := store.docs[conversation.ID.String()]["tags"].([]interface{})
+		for _, tag := range tags {
+			names = append(names, tag.(map[string]interface{})["name"].(string))
+		}
+		return names
+	}
+	require.Equal(t, []string{"old"}, tagNames())
+
+	// GetByID 和数据库一样不预加载消息
+	withTags := func(tags ...models.Tag) *models.Conversation {
+		return &models.Conversation{Base: conversation.Base, Title: conversation.Title, Tags: tags}
+	}
+
+	t.Run("Replaced tags reach the index", func(t *testing.T) {
+		golang := &models.Tag{Base: models.Base{ID: uuid.New()}, Name: "golang"}
+		search := &models.Tag{Base: models.Base{ID: uuid.New()}, Name: "search"}
+		tagRepo := new(MockTagRepository)
+		tagRepo.On("CreateOrGetTags", []string{"golang", "search"}).Return([]*models.Tag{golang, search}, nil)
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", conversation.ID).Return(withTags(oldTag), nil).Once()
+		convRepo.On("ReplaceTags", conversation.ID, []string{golang.ID.String(), search.ID.String()}).Return(nil)
+		convRepo.On("GetByID", conversation.ID).Return(withTags(*golang, *search), nil).Once()
+
+		service := services.NewConversationService(convRepo, tagRepo, new(MockUserRepository), indexer, nil, &config.Config{})
+		require.NoError(t, service.UpdateConversationTags(context.Background(), conversation.ID, []string{"golang", "search"}))
+
+		assert.Equal(t, []string{"golang", "search"}, tagNames())
+		// 部分更新不影响已索引的消息
+		assert.Equal(t, []string{message.ID.String()}, store.messageIDs(conversation.ID))
+		convRepo.AssertExpectations(t)
+	})
+
+	t.Run("Clearing tags empties the indexed tags", func(t *testing.T) {
+		convRepo := new(MockConversationRepository)
+		convRepo.On("GetByID", conversation.ID).Return(withTags(oldTag), nil).Once()
+		convRepo.On("ReplaceTags", conversation.ID, []string(nil)).Return(nil)
+		convRepo.On("GetByID", conversation.ID).Return(withTags(), nil).Once()
+
+		service := services.NewConversationService(convRepo, new(MockTagRepository), new(MockUserRepository), indexer, nil, &config.Config{})
+		require.NoError(t, service.UpdateConversationTags(context.Background(), conversation.ID, nil))
+
+		assert.Empty(t, tagNames())
+		assert.Equal(t, []string{message.ID.String()}, store.messageIDs(conversation.ID))
+	})
+}
+
+func TestTagService_CreateOrGetTags(t *testing.T) {
+	mockRepo := new(MockTagRepository)
+	tagService := services.NewTagService(mockRepo, nil, nil, newTagConfig(true))
+
+	expected := []*models.Tag{{Name: "golang"}}
+	mockRepo.On("CreateOrGetTags", []string{"golang"}).Return(expected, nil)
+
+	tags, err := tagService.CreateOrGetTags([]string{" Golang", "golang", "Golang "})
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, tags)
+	mockRepo.AssertExpectations(t)
+}
+
+// memoryTagRepository keeps tags in memory so repeated batch creations can be checked
+type memoryTagRepository struct {
+	repositories.TagRepository
+	tags map[string]*models.Tag
+}
+
+func (r *memoryTagRepository) GetByNames(names []string) ([]*models.Tag, error) {
+	var tags []*models.Tag
+	for _, name := range names {
+		if tag, ok := r.tags[name]; ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+func (r *memoryTagRepository) CreateOrGetTags(names []string) ([]*models.Tag, error) {
+	tags := make([]*models.Tag, 0, len(names))
+	for _, name := range names {
+		tag, ok := r.tags[name]
+		if !ok {
+			tag = &models.Tag{Base: models.Base{ID: uuid.New()}, Name: name}
+			r.tags[name] = tag
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func TestTagHandler_BatchCreateTags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	existing := &models.Tag{Base: models.Base{ID: uuid.New()}, Name: "golang"}
+	repo := &memoryTagRepository{tags: map[string]*models.Tag{"golang": existing}}
+	router := gin.New()
+	router.POST("/tags/batch", handlers.NewTagHandler(services.NewTagService(repo, nil, nil, newTagConfig(true))).BatchCreateTags)
+
+	type batchTag struct {
+		ID      uuid.UUID `json:"id"`
+		Name    string    `json:"name"`
+		Created bool      `json:"created"`
+	}
+	post := func(body string) (int, []batchTag) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/tags/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		var resp struct {
+			Data struct {
+				Tags []batchTag `json:"tags"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp.Data.Tags
+	}
+
+	code, first := post(`{"names":["Rust", " golang ", "rust", "Go  Lang", ""]}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, first, 3)
+	assert.Equal(t, batchTag{ID: first[0].ID, Name: "rust", Created: true}, first[0])
+	assert.Equal(t, batchTag{ID: existing.ID, Name: "golang", Created: false}, first[1])
+	assert.Equal(t, "go lang", first[2].Name)
+	assert.True(t, first[2].Created)
+
+	t.Run("Repeating the batch creates nothing", func(t *testing.T) {
+		code, second := post(`{"names":["rust", "GOLANG", "go lang"]}`)
+
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, second, 3)
+		for i, tag := range second {
+			assert.False(t, tag.Created, tag.Name)
+			assert.Equal(t, first[i].ID, tag.ID)
+		}
+		assert.Len(t, repo.tags, 3)
+	})
+
+	t.Run("Rejects empty and oversized batches", func(t *testing.T) {
+		names := make([]string, request.MaxTagBatchSize+1)
+		for i := range names {
+			names[i] = fmt.Sprintf("tag-%d", i)
+		}
+		oversized, _ := json.Marshal(map[string][]string{"names": names})
+
+		for _, body := range []string{`{"names":[]}`, `{}`, `{"names":["  "]}`, string(oversized)} {
+			code, _ := post(body)
+			assert.Equal(t, http.StatusBadRequest, code, body)
+		}
+	})
+}
+
+func TestTagService_NormalizeExistingTags(t *testing.T) {
+	now := time.Now()
+	oldest := &models.Tag{Base: models.Base{ID: uuid.New(), CreatedAt: now.Add(-time.Hour)}, Name: "Golang "}
+	duplicate := &models.Tag{Base: models.Base{ID: uuid.New(), CreatedAt: now}, Name: " golang"}
+	clean := &models.Tag{Base: models.Base{ID: uuid.New(), CreatedAt: now}, Name: "rust"}
+
+	t.Run("Dry run reports changes without merging", func(t *testing.T) {
+		mockRepo := new(MockTagRepository)
+		tagService := services.NewTagService(mockRepo, nil, nil, newTagConfig(true))
+		mockRepo.On("FindAll").Return([]*models.Tag{duplicate, clean, oldest}, nil)
+
+		changes, err := tagService.NormalizeExistingTags(true)
+
+		assert.NoError(t, err)
+		assert.Len(t, changes, 1)
+		assert.Equal(t, oldest.ID, changes[0].TargetID)
+		assert.Equal(t, "golang", changes[0].NewName)
+		assert.Equal(t, []uuid.UUID{duplicate.ID}, changes[0].DuplicateIDs)
+		mockRepo.AssertNotCalled(t, "MergeTags", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Merges duplicates into the oldest tag", func(t *testing.T) {
+		mockRepo := new(MockTagRepository)
+		tagService := services.NewTagService(mockRepo, nil, nil, newTagConfig(true))
+		mockRepo.On("FindAll").Return([]*models.Tag{duplicate, clean, oldest}, nil)
+		mockRepo.On("MergeTags", oldest.ID, "golang", []uuid.UUID{duplicate.ID}).Return(nil)
+
+		changes, err := tagService.NormalizeExistingTags(false)
+
+		assert.NoError(t, err)
+		assert.Len(t, changes, 1)
+		mockRepo.AssertExpectations(t)
+	})
+}
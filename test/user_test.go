@@ -1,15 +1,23 @@
 package test
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"chat-assistant-backend/internal/errors"
+	"chat-assistant-backend/internal/handlers"
 	"chat-assistant-backend/internal/models"
 	"chat-assistant-backend/internal/repositories"
+	"chat-assistant-backend/internal/response"
+	"chat-assistant-backend/internal/services"
 
+	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockUserRepository is a mock implementation of repositories.UserRepository
@@ -25,6 +33,10 @@ func (m *MockUserRepository) GetByID(id uuid.UUID) (*models.User, error) {
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
+func (m *MockUserRepository) Create(user *models.User) error {
+	return m.Called(user).Error(0)
+}
+
 // TestUserService is a test version of UserService that accepts interface
 type TestUserService struct {
 	userRepo repositories.UserRepository
@@ -96,3 +108,117 @@ func TestUserService_GetUserByID(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestUserService_GetUserProviders(t *testing.T) {
+	t.Run("Returns grouped counts", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		conversationRepo := new(MockConversationRepository)
+		userService := services.NewUserService(userRepo, conversationRepo)
+
+		userID := uuid.New()
+		counts := []*models.ProviderModelCount{
+			{Provider: "claude", Model: "claude-3", Count: 2},
+			{Provider: "openai", Model: "gpt-4", Count: 3},
+		}
+		userRepo.On("GetByID", userID).Return(&models.User{Base: models.Base{ID: userID}}, nil)
+		conversationRepo.On("CountByProviderModel", userID).Return(counts, nil)
+
+		result, err := userService.GetUserProviders(userID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, counts, result)
+	})
+
+	t.Run("User not found", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		conversationRepo := new(MockConversationRepository)
+		userService := services.NewUserService(userRepo, conversationRepo)
+
+		userID := uuid.New()
+		userRepo.On("GetByID", userID).Return(nil, nil)
+
+		result, err := userService.GetUserProviders(userID)
+
+		assert.Equal(t, errors.ErrUserNotFound, err)
+		assert.Nil(t, result)
+		conversationRepo.AssertNotCalled(t, "CountByProviderModel", mock.Anything)
+	})
+}
+
+func TestUserService_GetUserTags(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	aliceTags := []*models.TagCount{
+		{Tag: models.Tag{Base: models.Base{ID: uuid.New()}, Name: "go"}, Count: 3},
+		{Tag: models.Tag{Base: models.Base{ID: uuid.New()}, Name: "rust"}, Count: 1},
+	}
+	bobTags := []*models.TagCount{
+		{Tag: models.Tag{Base: models.Base{ID: uuid.New()}, Name: "cooking"}, Count: 2},
+	}
+
+	userRepo := new(MockUserRepository)
+	conversationRepo := new(MockConversationRepository)
+	for _, id := range []uuid.UUID{alice, bob} {
+		userRepo.On("GetByID", id).Return(&models.User{Base: models.Base{ID: id}}, nil)
+	}
+	conversationRepo.On("CountByTag", alice).Return(aliceTags, nil)
+	conversationRepo.On("CountByTag", bob).Return(bobTags, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/users/:id/tags", handlers.NewUserHandler(services.NewUserService(userRepo, conversationRepo)).GetUserTags)
+
+	get := func(id string) (int, []response.TagResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/"+id+"/tags", nil))
+
+		var resp struct {
+			Data response.TagListResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp.Data.Tags
+	}
+
+	t.Run("Each user sees only their own tags", func(t *testing.T) {
+		code, tags := get(alice.String())
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, tags, 2)
+		assert.Equal(t, "go", tags[0].Name)
+		assert.Equal(t, int64(3), tags[0].Count)
+		assert.Equal(t, "rust", tags[1].Name)
+
+		code, tags = get(bob.String())
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, tags, 1)
+		assert.Equal(t, "cooking", tags[0].Name)
+		assert.Equal(t, int64(2), tags[0].Count)
+	})
+
+	t.Run("User not found", func(t *testing.T) {
+		missing := uuid.New()
+		userRepo.On("GetByID", missing).Return(nil, nil)
+
+		code, _ := get(missing.String())
+		assert.Equal(t, http.StatusNotFound, code)
+		conversationRepo.AssertNotCalled(t, "CountByTag", missing)
+	})
+
+	t.Run("Invalid user ID", func(t *testing.T) {
+		code, _ := get("nope")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestNewProviderListResponse(t *testing.T) {
+	resp := response.NewProviderListResponse([]*models.ProviderModelCount{
+		{Provider: "claude", Model: "claude-3", Count: 2},
+		{Provider: "openai", Model: "gpt-3.5", Count: 1},
+		{Provider: "openai", Model: "gpt-4", Count: 3},
+	})
+
+	assert.Equal(t, []response.ProviderResponse{
+		{Provider: "claude", Count: 2, Models: []response.ProviderModelResponse{{Model: "claude-3", Count: 2}}},
+		{Provider: "openai", Count: 4, Models: []response.ProviderModelResponse{{Model: "gpt-3.5", Count: 1}, {Model: "gpt-4", Count: 3}}},
+	}, resp.Providers)
+
+	assert.NotNil(t, response.NewProviderListResponse(nil).Providers)
+}